@@ -0,0 +1,265 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/bhupixb/pushshift-go/internal/processor"
+	"github.com/bhupixb/pushshift-go/pkg/pushshift"
+)
+
+// pauser is a job SIGUSR2 pauses and resumes
+type pauser interface {
+	TogglePause() bool
+}
+
+// batchResult is the outcome of one input of a batch
+type batchResult struct {
+	flags *processFlags
+	stats processor.ProcessStats
+	err   error
+}
+
+// runBatch processes every dump a directory or glob -input names, each into its own output
+// prefix in the -output directory, running up to -file-parallelism of them at once, and reports
+// their statistics combined
+func runBatch(flags *processFlags) {
+	if err := flags.applyPreset(flag.CommandLine); err != nil {
+		log.Fatal("❌ ", err)
+	}
+	checkRunFlags(flags)
+	if flags.fileParallelism < 1 {
+		log.Fatal("❌ -file-parallelism must be at least 1")
+	}
+	// These write one file per run, which the inputs of a batch would overwrite. -schema-report
+	// given alone writes a report next to each input's outputs instead.
+	for _, single := range []struct {
+		name string
+		set  bool
+	}{
+		{"-tui", flags.tui},
+		{"-control-socket", flags.controlSocket != ""},
+		{"-export-run-spec", flags.exportRunSpec != ""},
+		{"-subreddit-report", flags.subredditReport != ""},
+		{"-schema-report with a file", flags.schemaReport.path != ""},
+		{"-dead-letter", flags.deadLetter != ""},
+	} {
+		if single.set {
+			log.Fatalf("❌ %s applies to a single input and can't be used with the directory or glob %s", single.name, flags.input)
+		}
+	}
+	inputs, err := processor.BatchInputs(flags.input)
+	if err != nil {
+		log.Fatal("❌ ", err)
+	}
+	if len(inputs) == 0 {
+		log.Fatalf("❌ No .zst, .tar, .zip or .7z dumps match %s", flags.input)
+	}
+	if !flags.countOnly {
+		if err := os.MkdirAll(flags.output, 0o755); err != nil {
+			log.Fatalf("❌ Failed to create output directory: %v", err)
+		}
+	}
+
+	var runs []*processFlags
+	replaced := make(map[*processFlags]*processor.ReplacedOutputs)
+	prefixes := make(map[string]string, len(inputs))
+	for _, input := range inputs {
+		run := *flags
+		run.input, run.output = input, processor.BatchOutputPrefix(flags.output, input)
+		if other, ok := prefixes[run.output]; ok {
+			log.Fatalf("❌ %s and %s would both write to output prefix %s", other, input, run.output)
+		}
+		prefixes[run.output] = input
+		// A resumed batch continues the inputs it was interrupted in and starts the others afresh
+		if run.resume {
+			cp, err := processor.ReadCheckpoint(run.output)
+			if err != nil {
+				log.Fatal("❌ ", err)
+			}
+			run.resume = cp != nil
+		}
+		if !run.countOnly && !run.appendOutput && !run.resume {
+			earlier, skip := checkExistingOutputs(&run)
+			if skip {
+				continue
+			}
+			replaced[&run] = earlier
+		}
+		runs = append(runs, &run)
+	}
+
+	if len(runs) == 0 {
+		log.Printf("✅ All %d dumps matching %s were processed already, nothing to do", len(inputs), flags.input)
+		return
+	}
+	log.Printf("📚 Processing %d of %d dumps matching %s, %d at a time", len(runs), len(inputs), flags.input, min(flags.fileParallelism, len(runs)))
+	log.Printf("📝 Output directory: %s", flags.output)
+	controls := &batchControls{controls: make(map[*processor.Control]struct{})}
+	// SIGUSR2 pauses and resumes every input being processed
+	handlePauseSignal(controls)
+
+	started := time.Now()
+	results := make([]batchResult, len(runs))
+	var ledgerMu sync.Mutex
+	next := make(chan int)
+	var wg sync.WaitGroup
+	for range min(flags.fileParallelism, len(runs)) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range next {
+				results[i] = runBatchInput(runs[i], replaced[runs[i]], controls, &ledgerMu)
+			}
+		}()
+	}
+	for i := range runs {
+		next <- i
+	}
+	close(next)
+	wg.Wait()
+
+	var combined processor.ProcessStats
+	var failed []error
+	for _, result := range results {
+		if result.err != nil {
+			failed = append(failed, fmt.Errorf("%s: %w", result.flags.input, result.err))
+			log.Printf("❌ %s failed: %v", result.flags.input, result.err)
+			if cp, _ := processor.ReadCheckpoint(result.flags.output); cp != nil && !result.flags.countOnly && replaced[result.flags] == nil {
+				log.Printf("💾 Parts of %s up to %d were converted, rerun with -resume to continue after them", result.flags.input, cp.Part)
+			}
+		} else if result.flags.countOnly {
+			log.Printf("✅ %s: %d lines in %v", result.flags.input, result.stats.TotalLines, result.stats.ExecutionTime.Round(time.Millisecond))
+		} else {
+			log.Printf("✅ %s: %d lines into %d parts in %v", result.flags.input, result.stats.TotalLines, len(result.stats.Parts), result.stats.ExecutionTime.Round(time.Millisecond))
+		}
+		combined.Merge(result.stats)
+	}
+	// Inputs processed at once overlap, so the batch took its wall-clock time rather than the sum
+	combined.ExecutionTime = time.Since(started)
+	runErr := errors.Join(failed...)
+
+	if flags.emailTo != "" {
+		sendReport(flags, combined, runErr)
+	}
+	if flags.statsJSON != "" {
+		if err := processor.WriteStatsFile(flags.statsJSON, combined); err != nil {
+			log.Fatal("❌ ", err)
+		}
+		log.Printf("🧾 Combined statistics written to %s", flags.statsJSON)
+	}
+
+	fmt.Println("\n" + combined.String())
+	if runErr != nil {
+		log.Fatalf("❌ %d of %d dumps failed", len(failed), len(runs))
+	}
+	log.Printf("✅ All done!")
+}
+
+// runBatchInput processes one input of a batch, recording it in the ledger like a single run.
+// The outputs of an earlier run replaced by -force are removed or restored once it ends.
+func runBatchInput(run *processFlags, replaced *processor.ReplacedOutputs, controls *batchControls, ledgerMu *sync.Mutex) batchResult {
+	result := batchResult{flags: run}
+	// Messages of inputs processed at once are told apart by the input's output name
+	logger := slog.New(prefixHandler{prefix: filepath.Base(run.output)})
+	run.logger = log.New(log.Writer(), "["+filepath.Base(run.output)+"] ", log.Flags()|log.Lmsgprefix)
+	proc, closeTransforms, err := newProcessor(run, pushshift.WithLogger(logger))
+	logf := func(format string, args ...any) { logger.Info(fmt.Sprintf(format, args...)) }
+	if err != nil {
+		replaceOutputs(replaced, err, logf)
+		result.err = err
+		return result
+	}
+	ctl := proc.Options().Control
+	controls.add(ctl)
+	defer controls.remove(ctl)
+
+	log.Printf("🚀 Processing %s into %s", run.input, run.output)
+	started := time.Now()
+	result.stats, result.err = proc.ProcessFile(context.Background(), run.input, run.output)
+	if run.ledger != "" {
+		ledgerMu.Lock()
+		recordRun(run.ledger, started, run.input, run.output, result.stats, result.err)
+		ledgerMu.Unlock()
+	}
+	// Closed first, as transforms write their side files on closing
+	closeTransforms()
+	replaceOutputs(replaced, result.err, logf)
+	return result
+}
+
+// batchControls pauses and resumes the inputs of a batch together, including inputs started
+// while the batch is paused
+type batchControls struct {
+	mu       sync.Mutex
+	paused   bool
+	controls map[*processor.Control]struct{}
+}
+
+// TogglePause pauses every running input, or resumes them when paused, and reports whether the
+// batch is now paused
+func (b *batchControls) TogglePause() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.paused = !b.paused
+	for ctl := range b.controls {
+		if b.paused {
+			ctl.Pause()
+		} else {
+			ctl.Resume()
+		}
+	}
+	return b.paused
+}
+
+// add registers the control of an input starting, pausing it when the batch is paused
+func (b *batchControls) add(ctl *processor.Control) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.paused {
+		ctl.Pause()
+	}
+	b.controls[ctl] = struct{}{}
+}
+
+// remove forgets the control of an input that finished
+func (b *batchControls) remove(ctl *processor.Control) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	delete(b.controls, ctl)
+}
+
+// prefixHandler logs the messages of a run through the standard logger, prefixed so that the
+// messages of inputs processed at once can be told apart
+type prefixHandler struct {
+	prefix string
+}
+
+// Enabled implements slog.Handler
+func (h prefixHandler) Enabled(context.Context, slog.Level) bool {
+	return true
+}
+
+// Handle implements slog.Handler
+func (h prefixHandler) Handle(_ context.Context, r slog.Record) error {
+	log.Printf("[%s] %s", h.prefix, r.Message)
+	return nil
+}
+
+// WithAttrs implements slog.Handler; attributes are left out of the messages
+func (h prefixHandler) WithAttrs([]slog.Attr) slog.Handler {
+	return h
+}
+
+// WithGroup implements slog.Handler
+func (h prefixHandler) WithGroup(string) slog.Handler {
+	return h
+}
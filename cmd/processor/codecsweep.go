@@ -0,0 +1,88 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+
+	"github.com/bhupixb/pushshift-go/internal/processor"
+)
+
+// codecSweepFlags select the -codec-sweep analysis mode of the process command
+type codecSweepFlags struct {
+	enabled    bool
+	codecs     string
+	sampleSize byteSize
+}
+
+// register defines the codec sweep flags on fs
+func (f *codecSweepFlags) register(fs *flag.FlagSet) {
+	fs.BoolVar(&f.enabled, "codec-sweep", false, "Instead of processing the input, convert a sample of it with several Parquet codecs and report their size and speed")
+	fs.StringVar(&f.codecs, "sweep-codecs", "", "Comma-separated codecs compared by -codec-sweep, as codec or codec:level, e.g. snappy,zstd:3,zstd:19 (a built-in selection if empty)")
+	f.sampleSize = byteSize(256 * 1024 * 1024)
+	fs.Var(&f.sampleSize, "sweep-sample-size", "Decompressed input sampled by -codec-sweep (defaults to 256MB)")
+}
+
+// runCodecSweep converts a sample of the input with each codec of the sweep, through the filters
+// and transforms selected by the processing flags, and prints how they compare
+func runCodecSweep(flags *processFlags, sweep *codecSweepFlags) {
+	if flags.countOnly || flags.appendOutput {
+		log.Fatal("❌ -count-only and -append do not apply to -codec-sweep")
+	}
+	if flags.format != "parquet" || flags.vectorStore != "" {
+		log.Fatal("❌ -codec-sweep compares Parquet codecs, -format and -vector-store do not apply")
+	}
+	if flags.maxNullFraction > 0 {
+		log.Fatal("❌ -max-null-fraction samples the whole input and does not apply to -codec-sweep")
+	}
+	if flags.compression != "" || flags.compressionLevel != 0 {
+		log.Fatal("❌ -codec-sweep selects the codecs with -sweep-codecs, -parquet-compression and -parquet-compression-level do not apply")
+	}
+	codecs, err := processor.ParseCodecSettings(splitList(sweep.codecs))
+	if err != nil {
+		log.Fatal("❌ ", err)
+	}
+	if err := flags.applyPreset(flag.CommandLine); err != nil {
+		log.Fatal("❌ ", err)
+	}
+
+	// The sample is converted next to where the run would write, so conversions are timed on the
+	// same volume
+	scratch, err := os.MkdirTemp(filepath.Dir(flags.output), ".codec-sweep-")
+	if err != nil {
+		log.Fatal("❌ Failed to create a directory for the sample: ", err)
+	}
+	defer os.RemoveAll(scratch)
+	flags.output = filepath.Join(scratch, filepath.Base(flags.output))
+	flags.cacheDir = ""
+
+	opts := flags.options()
+	if err := opts.Parquet.Validate(); err != nil {
+		log.Fatal("❌ ", err)
+	}
+	if err := processor.ValidateLineEndings(opts.LineEndings); err != nil {
+		log.Fatal("❌ ", err)
+	}
+	filters, err := flags.filters()
+	if err != nil {
+		log.Fatal("❌ ", err)
+	}
+	opts.Filters = filters
+	transforms, err := flags.transforms()
+	if err != nil {
+		log.Fatal("❌ ", err)
+	}
+	opts.Transforms = transforms
+	opts.BatchTransforms = flags.batchTransforms()
+	proc := &processor.PushshiftProcessor{Options: opts}
+
+	result, err := proc.CodecSweep(flags.input, flags.output, processor.CodecSweepOptions{Codecs: codecs, SampleBytes: int64(sweep.sampleSize)})
+	processor.CloseTransforms(filters)
+	processor.CloseTransforms(transforms)
+	if err != nil {
+		log.Fatal("❌ ", err)
+	}
+	fmt.Println("\n" + result.String())
+}
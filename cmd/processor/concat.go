@@ -0,0 +1,80 @@
+package main
+
+import (
+	"flag"
+	"log"
+	"strings"
+
+	"github.com/bhupixb/pushshift-go/internal/processor"
+)
+
+// runConcat merges several outputs or inputs into one dataset, dropping duplicate records and
+// giving columns typed differently across the inputs one type. It takes the processing flags, so
+// the merged records can be filtered and transformed on the way.
+func runConcat(args []string) {
+	var flags processFlags
+	flags.register(flag.CommandLine)
+	flag.CommandLine.BoolVar(&flags.dedup, "dedup", true, "Drop records whose kind and id were already read, keeping the first")
+	inputs := parseInterspersed(flag.CommandLine, args)
+	if len(inputs) < 2 {
+		log.Fatal("❌ At least two inputs are required, e.g. concat out/RC_2023-01 out/RC_2023-02 -output out/RC_2023-Q1")
+	}
+	if flags.input != "" {
+		log.Fatal("❌ concat reads the inputs given as its arguments, -input does not apply")
+	}
+	if flags.countOnly {
+		log.Fatal("❌ -count-only does not apply to concat")
+	}
+	if flags.maxNullFraction > 0 {
+		log.Fatal("❌ -max-null-fraction samples a single input and does not apply to concat")
+	}
+	if flags.canonicalSchema != "" && flags.dumpVintage == "auto" {
+		log.Fatal("❌ The dump vintage of concat inputs can't be detected, set -dump-vintage with -canonical-schema")
+	}
+	for _, input := range inputs {
+		if overlapsDataset(input, flags.output) {
+			log.Fatalf("❌ Output prefix %s would write into the input %s being read, choose another -output", flags.output, input)
+		}
+	}
+	sources, err := processor.ConcatSources(inputs)
+	if err != nil {
+		log.Fatal("❌ ", err)
+	}
+
+	// The canonical schema already gives every record the same columns and types
+	if flags.canonicalSchema == "" {
+		schema, err := processor.ReconcileSchemas(sources, nil)
+		if err != nil {
+			log.Fatal("❌ ", err)
+		}
+		if flags.fields != "" {
+			keep, err := processor.NewSelectFieldsTransform(splitList(flags.fields))
+			if err != nil {
+				log.Fatal("❌ ", err)
+			}
+			for name := range schema {
+				if !keep.Keeps(name) {
+					delete(schema, name)
+				}
+			}
+		}
+		if flags.dropFields != "" {
+			drop, err := processor.NewDropFieldsTransform(splitList(flags.dropFields))
+			if err != nil {
+				log.Fatal("❌ ", err)
+			}
+			for name := range schema {
+				if drop.Drops(name) {
+					delete(schema, name)
+				}
+			}
+		}
+		flags.reconciledSchema = schema
+	}
+	flags.input = strings.Join(inputs, ",")
+	flags.concatInputs = sources
+	// The input cache keys dumps by their compressed bytes, which a merged input doesn't have
+	flags.cacheDir = ""
+
+	process(&flags)
+}
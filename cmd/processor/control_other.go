@@ -0,0 +1,6 @@
+//go:build !unix
+
+package main
+
+// handlePauseSignal is a no-op on platforms without SIGUSR2; use -control-socket instead
+func handlePauseSignal(ctl pauser) {}
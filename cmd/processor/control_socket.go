@@ -0,0 +1,118 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bhupixb/pushshift-go/internal/processor"
+)
+
+// eventStreamDrainTimeout bounds how long closing the control socket waits for event streams
+const eventStreamDrainTimeout = 2 * time.Second
+
+// serveControlSocket listens on a unix socket for line-based commands steering the running job:
+// pause, resume, skip, cancel and status, plus events, which streams the run's events as JSON
+// lines until it ends. It returns a function that closes the socket.
+func serveControlSocket(path string, ctl *processor.Control) (func(), error) {
+	// Remove a stale socket left behind by a previous run
+	os.Remove(path)
+
+	listener, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen on control socket: %v", err)
+	}
+	log.Printf("🎛️ Control socket listening at %s", path)
+
+	// Event streams are given a moment to send the run's final event before the socket closes
+	var streams sync.WaitGroup
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go handleControlConn(conn, ctl, &streams)
+		}
+	}()
+
+	return func() {
+		listener.Close()
+		done := make(chan struct{})
+		go func() {
+			streams.Wait()
+			close(done)
+		}()
+		select {
+		case <-done:
+		case <-time.After(eventStreamDrainTimeout):
+		}
+		os.Remove(path)
+	}, nil
+}
+
+// handleControlConn executes each command line received on a control connection
+func handleControlConn(conn net.Conn, ctl *processor.Control, streams *sync.WaitGroup) {
+	defer conn.Close()
+
+	scanner := bufio.NewScanner(conn)
+	for scanner.Scan() {
+		var reply string
+		switch cmd := strings.TrimSpace(scanner.Text()); cmd {
+		case "pause":
+			ctl.Pause()
+			log.Printf("⏸️ Pause requested via control socket")
+			reply = "ok paused"
+		case "resume":
+			ctl.Resume()
+			log.Printf("▶️ Resume requested via control socket")
+			reply = "ok resumed"
+		case "skip":
+			ctl.SkipPart()
+			reply = "ok skipping current part"
+		case "cancel":
+			ctl.Cancel()
+			log.Printf("🛑 Cancel requested via control socket")
+			reply = "ok cancelling"
+		case "status":
+			snap := ctl.Snapshot()
+			reply = fmt.Sprintf("stage=%s paused=%t part=%d lines=%d bytes=%d",
+				snap.Stage, snap.Paused, snap.PartNumber, snap.LinesProcessed, snap.BytesRead)
+			for _, q := range snap.Queues {
+				reply += fmt.Sprintf(" queue_%s=%d/%d", q.Name, q.Depth, q.Capacity)
+			}
+		case "events":
+			streams.Add(1)
+			defer streams.Done()
+			streamEvents(conn, ctl)
+			return
+		case "":
+			continue
+		default:
+			reply = "error unknown command " + cmd + " (use pause, resume, skip, cancel, status or events)"
+		}
+		fmt.Fprintln(conn, reply)
+	}
+}
+
+// streamEvents writes each event of the run to conn as a JSON line until the run finishes or
+// fails, or the client goes away
+func streamEvents(conn net.Conn, ctl *processor.Control) {
+	events, unsubscribe := ctl.Subscribe(256)
+	defer unsubscribe()
+	enc := json.NewEncoder(conn)
+	for ev := range events {
+		if err := enc.Encode(ev); err != nil {
+			return
+		}
+		if ev.Kind == processor.EventRunFinished || ev.Kind == processor.EventError {
+			return
+		}
+	}
+}
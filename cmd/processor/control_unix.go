@@ -0,0 +1,25 @@
+//go:build unix
+
+package main
+
+import (
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// handlePauseSignal toggles pause/resume on the running job whenever SIGUSR2 is received
+func handlePauseSignal(ctl pauser) {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGUSR2)
+	go func() {
+		for range signals {
+			if ctl.TogglePause() {
+				log.Printf("⏸️ SIGUSR2 received, pausing")
+			} else {
+				log.Printf("▶️ SIGUSR2 received, resuming")
+			}
+		}
+	}()
+}
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"flag"
+	"log"
+	"strings"
+
+	"github.com/bhupixb/pushshift-go/internal/processor"
+)
+
+// runConvert converts one JSONL file to one Parquet file with the converter used for parts, for
+// JSONL already filtered by other tools
+func runConvert(args []string) {
+	fs := flag.NewFlagSet("convert", flag.ExitOnError)
+	var flags processFlags
+	flags.registerConverter(fs)
+	columnTypesFlag := fs.String("column-types", "", "Comma-separated name:TYPE columns cast to DuckDB types, e.g. score:BIGINT,edited:VARCHAR")
+
+	files := parseInterspersed(fs, args)
+	if len(files) != 2 {
+		log.Fatal("❌ An input and an output file are required, e.g. convert filtered.jsonl filtered.parquet")
+	}
+	if !strings.HasSuffix(files[1], ".parquet") {
+		log.Fatal("❌ The output file must have a .parquet extension: ", files[1])
+	}
+
+	opts := processor.Options{
+		Parquet:            flags.parquetOptions(),
+		FallbackConverters: flags.fallbacks,
+		ParquetColumns:     processor.ColumnCasts(processor.ParseSchemaFields(splitList(*columnTypesFlag))),
+	}
+	proc := &processor.PushshiftProcessor{Options: opts}
+
+	log.Printf("🔄 Converting %s to %s", files[0], files[1])
+	part, err := proc.ConvertFile(files[0], files[1])
+	if err != nil {
+		log.Fatal("❌ ", err)
+	}
+	log.Printf("✅ Wrote %s (%.2f MB from %.2f MB of JSONL) with the %s converter", part.Path,
+		float64(part.ParquetBytes)/1024/1024, float64(part.JSONLBytes)/1024/1024, part.Converter)
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+
+	"github.com/bhupixb/pushshift-go/internal/processor"
+)
+
+// dumpMonthPattern matches the kind and month of a monthly dump name, e.g. RC_2023-01.zst
+var dumpMonthPattern = regexp.MustCompile(`^R([CS])_(\d{4}-\d{2})`)
+
+// runCrossCheck reports how many comments reference submissions missing from the submissions
+// dump of the same month, and vice versa
+func runCrossCheck(args []string) {
+	fs := flag.NewFlagSet("crosscheck", flag.ExitOnError)
+	commentsFlag := fs.String("comments", "", "Comments dump (RC_) to check, instead of pairing positional files by month")
+	submissionsFlag := fs.String("submissions", "", "Submissions dump (RS_) to check against -comments")
+	reportFlag := fs.String("report", "", "Also write the reports to this JSON file")
+
+	files := parseInterspersed(fs, args)
+
+	var pairs [][2]string
+	switch {
+	case *commentsFlag != "" || *submissionsFlag != "":
+		if *commentsFlag == "" || *submissionsFlag == "" || len(files) > 0 {
+			log.Fatal("❌ -comments and -submissions are required together, without positional files")
+		}
+		pairs = [][2]string{{*commentsFlag, *submissionsFlag}}
+	default:
+		var err error
+		if pairs, err = pairDumpsByMonth(files); err != nil {
+			log.Fatal("❌ ", err)
+		}
+	}
+	for _, pair := range pairs {
+		for _, path := range pair {
+			if !processor.InputExists(path) {
+				log.Fatal("❌ Input file does not exist:", path)
+			}
+		}
+	}
+
+	var reports []processor.CrossCheckReport
+	for _, pair := range pairs {
+		report, err := processor.CrossCheckDumps(pair[0], pair[1], nil)
+		if err != nil {
+			log.Fatal("❌ Cross-check failed:", err)
+		}
+		fmt.Println(report.String())
+		reports = append(reports, report)
+	}
+
+	if *reportFlag != "" {
+		data, err := json.MarshalIndent(reports, "", "  ")
+		if err != nil {
+			log.Fatal("❌ Failed to encode reports:", err)
+		}
+		if err := os.WriteFile(*reportFlag, append(data, '\n'), 0644); err != nil {
+			log.Fatal("❌ Failed to write reports:", err)
+		}
+		log.Printf("🧾 Cross-check reports written to %s", *reportFlag)
+	}
+}
+
+// pairDumpsByMonth pairs RC_ and RS_ dumps of the same month by file name, ordered by month
+func pairDumpsByMonth(files []string) ([][2]string, error) {
+	if len(files) == 0 {
+		return nil, fmt.Errorf("RC_ and RS_ files of the same month are required, e.g. crosscheck RC_2023-01.zst RS_2023-01.zst")
+	}
+	byMonth := make(map[string]*[2]string)
+	for _, path := range files {
+		m := dumpMonthPattern.FindStringSubmatch(filepath.Base(path))
+		if m == nil {
+			return nil, fmt.Errorf("cannot tell the kind and month of %s from its name, use -comments and -submissions", path)
+		}
+		pair := byMonth[m[2]]
+		if pair == nil {
+			pair = &[2]string{}
+			byMonth[m[2]] = pair
+		}
+		slot := 0
+		if m[1] == "S" {
+			slot = 1
+		}
+		if pair[slot] != "" {
+			return nil, fmt.Errorf("more than one R%s_ file for %s: %s and %s", m[1], m[2], pair[slot], path)
+		}
+		pair[slot] = path
+	}
+
+	months := make([]string, 0, len(byMonth))
+	for month := range byMonth {
+		months = append(months, month)
+	}
+	sort.Strings(months)
+	pairs := make([][2]string, 0, len(months))
+	for _, month := range months {
+		pair := byMonth[month]
+		if pair[0] == "" || pair[1] == "" {
+			return nil, fmt.Errorf("both an RC_ and an RS_ file are required for %s", month)
+		}
+		pairs = append(pairs, *pair)
+	}
+	return pairs, nil
+}
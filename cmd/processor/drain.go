@@ -0,0 +1,44 @@
+package main
+
+import (
+	"flag"
+	"log"
+	"os"
+
+	"github.com/bhupixb/pushshift-go/internal/processor"
+)
+
+// runDrain replays the records of a dead-letter queue into the sink they failed to reach
+func runDrain(args []string) {
+	fs := flag.NewFlagSet("drain", flag.ExitOnError)
+	storeFlag := fs.String("vector-store", "", "Qdrant collection the records were meant for, e.g. qdrant://localhost:6333/reddit (required)")
+	keyFlag := fs.String("vector-key", "name", "Idempotency key field used by the original run")
+	batchFlag := fs.Int("batch-size", 500, "Records per write")
+	upsertFlag := fs.Int("vector-batch-size", 100, "Points per upsert request; 0 sends each batch of records in one request")
+	waitFlag := fs.Bool("vector-wait", true, "Have upserts applied before Qdrant answers")
+
+	queues := parseInterspersed(fs, args)
+	if len(queues) != 1 {
+		log.Fatal("❌ Exactly one dead-letter queue is required, e.g. drain -vector-store=qdrant://localhost:6333/reddit output_deadletter.jsonl")
+	}
+	if *storeFlag == "" {
+		log.Fatal("❌ -vector-store is required")
+	}
+	if _, err := os.Stat(queues[0]); os.IsNotExist(err) {
+		log.Fatal("❌ Dead-letter queue does not exist:", queues[0])
+	}
+
+	sink, err := processor.NewQdrantSink(*storeFlag, "")
+	if err != nil {
+		log.Fatal("❌ ", err)
+	}
+	sink.KeyField, sink.BatchSize, sink.Wait = *keyFlag, *upsertFlag, *waitFlag
+	delivered, kept, err := processor.DrainDeadLetters(queues[0], sink, *batchFlag, nil)
+	if err != nil {
+		log.Fatal("❌ Drain failed: ", err)
+	}
+	if kept > 0 {
+		log.Fatalf("❌ Delivered %d records, %d are still failing and remain in %s", delivered, kept, queues[0])
+	}
+	log.Printf("✅ Delivered %d records, %s is empty and was removed", delivered, queues[0])
+}
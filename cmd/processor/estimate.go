@@ -0,0 +1,185 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/bhupixb/pushshift-go/internal/processor"
+)
+
+// runEstimate samples an input, runs the samples through the pipeline selected by the processing
+// flags and projects the wall time, scratch space and output size of a full run, for planning
+// machine allocations before committing to one
+func runEstimate(args []string) {
+	var flags processFlags
+	flags.register(flag.CommandLine)
+	configFlag := flag.CommandLine.String("config", "", "YAML or JSON file of processing flags, or a run spec written by -export-run-spec; command-line flags take precedence")
+	sampleSize := byteSize(256 * 1024 * 1024)
+	flag.CommandLine.Var(&sampleSize, "sample-size", "Decompressed input read for each sample, from the start and the middle of the input (defaults to 256MB)")
+	jsonFlag := flag.CommandLine.Bool("json", false, "Print the estimate as JSON")
+	files := parseInterspersed(flag.CommandLine, args)
+	if len(files) > 1 {
+		log.Fatal("❌ One input is required, e.g. estimate RC_2023-01.zst -config run.yaml")
+	}
+	if *configFlag != "" {
+		input, err := applyConfig(flag.CommandLine, *configFlag)
+		if err != nil {
+			log.Fatal("❌ ", err)
+		}
+		if len(files) == 0 && input != "" {
+			files = append(files, input)
+		}
+	}
+	if len(files) == 0 {
+		log.Fatal("❌ One input is required, e.g. estimate RC_2023-01.zst -config run.yaml")
+	}
+	if flags.input != "" {
+		log.Fatal("❌ estimate reads the input given as its argument, -input does not apply")
+	}
+	flags.input = files[0]
+	requireInput(&flags)
+	if flags.countOnly || flags.appendOutput {
+		log.Fatal("❌ -count-only and -append do not apply to estimate")
+	}
+	if flags.format != "parquet" || flags.vectorStore != "" {
+		log.Fatal("❌ estimate covers runs writing Parquet parts, -format and -vector-store do not apply")
+	}
+	if flags.maxNullFraction > 0 {
+		log.Fatal("❌ -max-null-fraction samples the whole input and does not apply to estimate")
+	}
+	if err := flags.applyPreset(flag.CommandLine); err != nil {
+		log.Fatal("❌ ", err)
+	}
+
+	est, err := estimate(&flags, int64(sampleSize))
+	if err != nil {
+		log.Fatal("❌ ", err)
+	}
+	if *jsonFlag {
+		data, err := json.MarshalIndent(est, "", "  ")
+		if err != nil {
+			log.Fatal("❌ Failed to encode the estimate: ", err)
+		}
+		fmt.Println(string(data))
+		return
+	}
+	fmt.Println(est)
+}
+
+// estimate runs the estimate of the flags' input in a scratch directory it removes afterwards
+func estimate(flags *processFlags, sampleSize int64) (processor.RunEstimate, error) {
+	// The samples are processed next to where the run would write, so writes and conversions
+	// are timed on the same volume
+	scratch, err := os.MkdirTemp(filepath.Dir(flags.output), ".estimate-")
+	if err != nil {
+		return processor.RunEstimate{}, fmt.Errorf("failed to create a directory for the samples: %v", err)
+	}
+	defer os.RemoveAll(scratch)
+	flags.output = filepath.Join(scratch, filepath.Base(flags.output))
+	flags.cacheDir = ""
+	// Side outputs named by path are written in the scratch directory too, so the samples'
+	// reports don't overwrite those of real runs
+	for _, path := range []*string{&flags.subredditReport, &flags.schemaReport.path, &flags.droppedFile} {
+		if *path != "" {
+			*path = filepath.Join(scratch, "side_"+filepath.Base(*path))
+		}
+	}
+
+	opts := flags.options()
+	if err := opts.Parquet.Validate(); err != nil {
+		return processor.RunEstimate{}, err
+	}
+	if err := processor.ValidateLineEndings(opts.LineEndings); err != nil {
+		return processor.RunEstimate{}, err
+	}
+	if err := processor.ValidatePartCompressionLevel(opts.PartCompressionLevel); err != nil {
+		return processor.RunEstimate{}, err
+	}
+	if opts.Workers < 1 {
+		return processor.RunEstimate{}, fmt.Errorf("-workers must be at least 1")
+	}
+	filters, err := flags.filters()
+	if err != nil {
+		return processor.RunEstimate{}, err
+	}
+	defer processor.CloseTransforms(filters)
+	opts.Filters = filters
+	transforms, err := flags.transforms()
+	if err != nil {
+		return processor.RunEstimate{}, err
+	}
+	defer processor.CloseTransforms(transforms)
+	opts.Transforms = transforms
+	opts.BatchTransforms = flags.batchTransforms()
+	proc := &processor.PushshiftProcessor{Options: opts}
+	return proc.Estimate(flags.input, flags.output, processor.EstimateOptions{SampleBytes: sampleSize})
+}
+
+// applyConfig sets the flags named in a YAML or JSON config file that were not given on the
+// command line, returning the input it names. A run spec's flags are read from its "flags" key.
+func applyConfig(fs *flag.FlagSet, path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to read config: %v", err)
+	}
+	var config map[string]any
+	if err := yaml.Unmarshal(data, &config); err != nil {
+		return "", fmt.Errorf("invalid config %s: %v", path, err)
+	}
+	var input string
+	if spec, ok := config["flags"].(map[string]any); ok {
+		if value, ok := config["input"].(string); ok {
+			input = value
+		}
+		config = spec
+	}
+
+	set := make(map[string]bool)
+	fs.Visit(func(fl *flag.Flag) { set[fl.Name] = true })
+	names := make([]string, 0, len(config))
+	for name := range config {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		value := configValue(config[name])
+		if name == "input" {
+			input = value
+			continue
+		}
+		if set[name] || replayExcludedFlags[name] {
+			continue
+		}
+		if fs.Lookup(name) == nil {
+			return "", fmt.Errorf("config %s: unknown flag -%s", path, name)
+		}
+		if err := fs.Set(name, value); err != nil {
+			return "", fmt.Errorf("config %s: -%s: %v", path, name, err)
+		}
+	}
+	log.Printf("🎛️ Using the flags of %s", path)
+	return input, nil
+}
+
+// configValue formats a config value as a flag value, joining lists with commas
+func configValue(value any) string {
+	if list, ok := value.([]any); ok {
+		items := make([]string, len(list))
+		for i, item := range list {
+			items[i] = fmt.Sprint(item)
+		}
+		return strings.Join(items, ",")
+	}
+	if value == nil {
+		return ""
+	}
+	return fmt.Sprint(value)
+}
@@ -0,0 +1,1079 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"maps"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bhupixb/pushshift-go/internal/processor"
+	"github.com/bhupixb/pushshift-go/pkg/pushshift"
+)
+
+// processFlags holds the command-line options of the default process command
+type processFlags struct {
+	input            string
+	output           string
+	countOnly        bool
+	appendOutput     bool
+	resume           bool
+	singleFile       bool
+	partitionBy      string
+	routes           string
+	maxOpenFiles     int
+	spillBuffer      byteSize
+	fileParallelism  int
+	readAhead        int
+	readAheadChunk   byteSize
+	writeBehind      int
+	workers          int
+	compressParts    int
+	continueOnPart   bool
+	ioHints          bool
+	lineEndings      string
+	waitForData      time.Duration
+	startAt          startTime
+	endAt            startTime
+	skipLines        int64
+	takeLines        int64
+	subreddits       string
+	minScore         optionalInt
+	authorsFile      string
+	preset           string
+	presetsFile      string
+	cacheDir         string
+	deadLetter       string
+	vectorKey        string
+	vectorBatchSize  int
+	vectorWait       bool
+	minThroughput    throughputFloor
+	emailTo          string
+	emailFrom        string
+	emailOn          string
+	smtpServer       string
+	sinkRetries      int
+	force            bool
+	skipExisting     bool
+	countBySubreddit bool
+	tui              bool
+	ledger           string
+	controlSocket    string
+	exportRunSpec    string
+	statsJSON        string
+	wasmTransforms   string
+	script           string
+	scriptBudget     time.Duration
+	joins            stringList
+	fallbacks        stringList
+	converter        string
+	joinMemoryMB     int64
+	subredditMeta    string
+	domainCategory   bool
+	domainList       string
+	subredditStatus  bool
+	statusList       string
+	scoreEndpoint    string
+	scoreFields      string
+	scorePrefix      string
+	scoreBatchSize   int
+	scoreConcurrency int
+	scoreCacheSize   int
+	scoreTimeout     time.Duration
+	scoreRetries     int
+	embedEndpoint    string
+	embedModel       string
+	embedFields      string
+	embedMetadata    string
+	embedBatchSize   int
+	embedConcurrency int
+	vectorStore      string
+	format           string
+	corpusFormat     string
+	corpusDocument   string
+	corpusFields     string
+	corpusMinChars   int
+	corpusMaxChars   int
+	corpusDedup      bool
+	corpusDedupMB    int64
+	corpusShardMB    int64
+	pairsMinScore    int64
+	pairsMinChars    int
+	pairsMaxChars    int
+	pairsSelfReplies bool
+	pairsSkipAuthors string
+	maxRecordBytes   int
+	oversizedPolicy  string
+	badRecords       string
+	onBadLine        string
+	droppedSample    int
+	droppedFile      string
+	textFields       string
+	htmlUnescape     bool
+	emoji            string
+	unicodeNorm      string
+	createdFormats   string
+	deriveTZ         string
+	retrievalLag     bool
+	edited           string
+	nonCommunity     string
+	excludeStickied  bool
+	distinguished    string
+	mediaTable       bool
+	pollData         string
+	awards           string
+	commentDepth     bool
+	threadTable      bool
+	normalizedNames  bool
+	maxRetrievalLag  time.Duration
+	fields           string
+	dropFields       string
+	maxNullFraction  float64
+	nullSampleSize   int
+	subredditReport  string
+	schemaReport     optionalPath
+	redactionPolicy  string
+	removeIDs        string
+	removeAuthors    string
+	// splitOnly and partSize are set by the split command
+	splitOnly bool
+	partSize  byteSize
+	// concatInputs, dedup and reconciledSchema are set by the concat command
+	concatInputs     []string
+	dedup            bool
+	reconciledSchema map[string]string
+	quantiles        bool
+	targetParquet    byteSize
+	rowGroupRows     int64
+	rowGroupBytes    byteSize
+	compression      string
+	compressionLevel int
+	duckdbSQL        string
+	pageSize         byteSize
+	statistics       bool
+	extraJSON        bool
+	canonicalSchema  string
+	dumpVintage      string
+	schemaFields     string
+
+	// logger receives the messages of loading tables and sampling the input while building the
+	// transforms; the standard logger when nil. Batch runs prefix it with the input's name.
+	logger *log.Logger
+}
+
+// register defines the process command's flags on fs
+func (f *processFlags) register(fs *flag.FlagSet) {
+	fs.StringVar(&f.input, "input", "", "Path to input .zst file, or .tar, .tar.zst, .zip or .7z archive of JSONL files; a directory or glob (RC_2022-*.zst) processes each dump it names")
+	fs.StringVar(&f.output, "output", "output", "Prefix for output files, or the directory of per-file output prefixes when -input names many dumps")
+	fs.IntVar(&f.fileParallelism, "file-parallelism", 1, "Process up to this many dumps of a directory or glob -input at once")
+	fs.BoolVar(&f.appendOutput, "append", false, "Add parts to an existing output, continuing its part numbering and extending its manifest")
+	fs.BoolVar(&f.singleFile, "single-file", false, "Merge the converted parts into one Parquet file with many row groups once the run is done")
+	fs.StringVar(&f.partitionBy, "partition-by", "", "Write a Hive-style partitioned dataset under -output, e.g. subreddit,month for subreddit=<name>/year=2021/month=06/part-*.parquet (keys: "+strings.Join(processor.PartitionKeys, ", ")+")")
+	fs.StringVar(&f.routes, "routes", "", "JSON routing config sending each record to the outputs of the routes it meets in one pass, a directory of Parquet files per output under -output")
+	fs.IntVar(&f.maxOpenFiles, "max-open-files", 0, "Keep at most this many -partition-by or -routes staging files open, closing the least recently written (0 uses 256, lowered below ulimit -n)")
+	fs.Var(&f.spillBuffer, "spill-buffer", "Memory -partition-by and -routes buffer records in before writing the largest partition buffers to disk (defaults to 64MB)")
+	fs.BoolVar(&f.resume, "resume", false, "Continue an interrupted run from the checkpoint written after its last converted part, keeping the parts before it")
+	fs.BoolVar(&f.force, "force", false, "Overwrite the outputs of an earlier run with the same -output prefix")
+	fs.BoolVar(&f.skipExisting, "skip-existing", false, "Exit successfully without processing when outputs with the same -output prefix already exist")
+	fs.IntVar(&f.readAhead, "read-ahead", 0, "Prefetch this many chunks of the input concurrently, for NFS, S3 mounts and other high-latency storage (0 reads sequentially)")
+	fs.Var(&f.readAheadChunk, "read-ahead-chunk-size", "Size of each -read-ahead chunk (defaults to 8MB)")
+	fs.IntVar(&f.writeBehind, "write-behind", 0, "Write part files in the background with up to this many 8MB chunks queued (0 writes synchronously)")
+	fs.IntVar(&f.workers, "workers", 1, "Convert up to this many parts to Parquet at once while the next part is written (1 converts each part before writing the next)")
+	fs.IntVar(&f.compressParts, "compress-parts", 0, "Compress intermediate JSONL parts with zstd at this level, 1 to 3, to save scratch space (0 disables)")
+	fs.BoolVar(&f.ioHints, "io-hints", false, "Use posix_fadvise on Linux so the input and output streams don't flush the page cache of a shared host")
+	fs.DurationVar(&f.waitForData, "wait-for-data", 0, "Wait up to this long for input data that is still downloading (FUSE/torrent mounts, sparse files) instead of failing (0 disables)")
+	fs.Var(&f.startAt, "start-at", "Skip records created before this UTC time (e.g. 2023-06-15), seeking past earlier zstd frames of multi-frame inputs")
+	fs.Var(&f.endAt, "end-at", "Skip records created at or after this UTC time (e.g. 2023-07-01)")
+	fs.Var(&f.startAt, "after", "Alias of -start-at")
+	fs.Var(&f.endAt, "before", "Alias of -end-at")
+	fs.Int64Var(&f.skipLines, "skip-lines", 0, "Skip this many lines of the decoded input before processing, e.g. to reproduce a failure deep into a dump")
+	fs.Int64Var(&f.takeLines, "take-lines", 0, "Process only this many lines, after -skip-lines, and stop reading (0 processes the rest)")
+	fs.StringVar(&f.subreddits, "subreddits", "", "Comma-separated subreddits to keep, dropping records of all others; globs (ask*, *politics*) and /regex/ patterns match the subreddits in the input")
+	fs.Var(&f.minScore, "min-score", "Skip records scored below this, keeping records without a score")
+	fs.StringVar(&f.authorsFile, "authors-file", "", "File of authors to keep, one per line, dropping records of all others")
+	fs.StringVar(&f.preset, "preset", "", "Named study setup supplying -subreddits, -start-at and -end-at unless given (see the presets command)")
+	fs.StringVar(&f.presetsFile, "presets-file", processor.DefaultPresetsPath(), "JSON file of presets extending and overriding the bundled ones")
+	fs.StringVar(&f.lineEndings, "line-endings", processor.LineEndingsAny, "Input line terminators: any (LF, CRLF or lone CR) or lf (LF only)")
+	fs.Var(&f.minThroughput, "min-throughput", "Abort when input throughput stays below this floor, e.g. \"20MB/s for 10m\" (conversions and pauses don't count)")
+	fs.BoolVar(&f.countOnly, "count-only", false, "Only count lines without writing any output")
+	fs.BoolVar(&f.countBySubreddit, "count-by-subreddit", false, "With -count-only, also report counts per subreddit")
+	fs.BoolVar(&f.tui, "tui", false, "Show an interactive terminal UI with live progress")
+	fs.StringVar(&f.ledger, "ledger", "", "Record runs in this SQLite run history ledger, e.g. "+processor.DefaultLedgerPath()+" (off by default)")
+	fs.StringVar(&f.cacheDir, "cache-dir", processor.DefaultCacheDir(), "Directory caching frame indexes, schema samples and line counts per input (empty to disable)")
+	fs.StringVar(&f.controlSocket, "control-socket", "", "Path of a unix socket accepting pause/resume/skip/cancel/status commands")
+	fs.StringVar(&f.exportRunSpec, "export-run-spec", "", "Write a reproducibility spec (version, flags, input checksum) to this JSON file")
+	fs.StringVar(&f.statsJSON, "stats-json", "", "Write the run's statistics, including distribution sketches, to this JSON file for the stats merge command")
+	fs.StringVar(&f.emailTo, "email-to", "", "Comma-separated addresses to email a run report to, with stats.json attached")
+	fs.StringVar(&f.emailFrom, "email-from", "", "Sender address of -email-to reports")
+	fs.StringVar(&f.emailOn, "email-on", "always", "When to send -email-to reports: always or failure")
+	fs.StringVar(&f.smtpServer, "smtp-server", "", "SMTP server as host:port for -email-to (credentials from SMTP_USERNAME and SMTP_PASSWORD)")
+	fs.Var(&f.joins, "join", "Join a CSV lookup table as table.csv:field (first CSV column is the key); repeatable")
+	fs.Int64Var(&f.joinMemoryMB, "join-memory-limit-mb", processor.DefaultJoinMemoryLimit/1024/1024, "Join tables larger than this are indexed on disk instead of loaded into memory")
+	fs.StringVar(&f.subredditMeta, "subreddit-metadata", "", "Pushshift subreddit metadata dump (.zst) joined on subreddit_id, adding subreddit_subscribers, subreddit_created_utc and subreddit_description")
+	fs.BoolVar(&f.domainCategory, "domain-category", false, "Add a domain_category column (news, video, social, ...) from the record's link domain")
+	fs.StringVar(&f.domainList, "domain-list", "", "CSV of domain,category rows extending the bundled list (implies -domain-category)")
+	fs.BoolVar(&f.subredditStatus, "subreddit-status", false, "Add a subreddit_status_at_post column (active, quarantined, banned) from dated status changes")
+	fs.StringVar(&f.statusList, "subreddit-status-list", "", "CSV of subreddit,status,effective_date rows replacing the bundled history of the subreddits listed (implies -subreddit-status)")
+	fs.StringVar(&f.scoreEndpoint, "score-endpoint", "", "HTTP endpoint scoring record text (sentiment, toxicity, ...) in batches")
+	fs.StringVar(&f.scoreFields, "score-fields", "body,selftext,title", "Comma-separated text fields to score, first non-empty wins")
+	fs.StringVar(&f.scorePrefix, "score-prefix", "score_", "Prefix for score columns returned by -score-endpoint")
+	fs.IntVar(&f.scoreBatchSize, "score-batch-size", 64, "Texts sent per scoring request")
+	fs.IntVar(&f.scoreConcurrency, "score-concurrency", 4, "Scoring requests in flight at once")
+	fs.IntVar(&f.scoreCacheSize, "score-cache-size", 100000, "Distinct texts whose scores are cached")
+	fs.DurationVar(&f.scoreTimeout, "score-timeout", 30*time.Second, "Timeout for each scoring request")
+	fs.IntVar(&f.scoreRetries, "score-retries", 3, "Retries for failed scoring requests before the run fails")
+	fs.StringVar(&f.embedEndpoint, "embed-endpoint", "", "OpenAI-compatible embeddings endpoint; records become {id, metadata..., vector} rows")
+	fs.StringVar(&f.embedModel, "embed-model", "", "Model name sent to -embed-endpoint")
+	fs.StringVar(&f.embedFields, "embed-fields", "body,selftext,title", "Comma-separated text fields to embed, first non-empty wins")
+	fs.StringVar(&f.embedMetadata, "embed-metadata", "subreddit,author,created_utc,score", "Comma-separated fields kept next to each vector")
+	fs.IntVar(&f.embedBatchSize, "embed-batch-size", 64, "Texts sent per embedding request")
+	fs.IntVar(&f.embedConcurrency, "embed-concurrency", 4, "Embedding requests in flight at once")
+	fs.StringVar(&f.vectorStore, "vector-store", "", "Write vectors to a Qdrant collection (qdrant://host:6333/collection) instead of Parquet")
+	fs.StringVar(&f.vectorKey, "vector-key", "name", "Record field used as idempotency key for -vector-store point ids, e.g. id for numeric ids in a collection of one kind")
+	fs.IntVar(&f.vectorBatchSize, "vector-batch-size", 100, "Points per -vector-store upsert request; 0 sends each batch of records in one request")
+	fs.BoolVar(&f.vectorWait, "vector-wait", true, "Have -vector-store upserts applied before Qdrant answers, so failures to apply them are retried and dead-lettered")
+	fs.StringVar(&f.deadLetter, "dead-letter", "", "File queueing records a -vector-store write failed for (defaults to <output>_deadletter.jsonl)")
+	fs.IntVar(&f.sinkRetries, "sink-retries", 3, "Retries with exponential backoff before a failed -vector-store batch is queued in the dead-letter file")
+	fs.StringVar(&f.format, "format", "parquet", "Output format: parquet, corpus for sharded LLM-training text files, or pairs for prompt/response JSONL")
+	fs.StringVar(&f.corpusFormat, "corpus-format", "jsonl", "Corpus shard format: jsonl ({\"text\": ...} lines) or txt (documents separated by blank lines)")
+	fs.StringVar(&f.corpusDocument, "corpus-document", "comment", "Corpus document construction: comment (one per record) or thread (comments of a link_id concatenated)")
+	fs.StringVar(&f.corpusFields, "corpus-fields", "body,selftext", "Comma-separated text fields used for corpus documents, first non-empty wins")
+	fs.IntVar(&f.corpusMinChars, "corpus-min-chars", 0, "Drop corpus documents shorter than this many characters")
+	fs.IntVar(&f.corpusMaxChars, "corpus-max-chars", 0, "Truncate corpus documents longer than this many characters (0 for no limit)")
+	fs.BoolVar(&f.corpusDedup, "corpus-dedup", true, "Drop corpus documents whose exact text was already written")
+	fs.Int64Var(&f.corpusDedupMB, "corpus-dedup-mb", 512, "Memory in megabytes remembering written corpus texts for -corpus-dedup")
+	fs.Int64Var(&f.corpusShardMB, "corpus-shard-mb", 256, "Start a new corpus or pairs shard after this many megabytes")
+	fs.Int64Var(&f.pairsMinScore, "pairs-min-score", 1, "Drop pairs whose response scored lower than this")
+	fs.IntVar(&f.pairsMinChars, "pairs-min-chars", 1, "Drop pairs whose prompt or response is shorter than this many characters")
+	fs.IntVar(&f.pairsMaxChars, "pairs-max-chars", 0, "Drop pairs whose prompt or response is longer than this many characters (0 for no limit)")
+	fs.BoolVar(&f.pairsSelfReplies, "pairs-self-replies", false, "Keep pairs where authors reply to themselves")
+	fs.StringVar(&f.pairsSkipAuthors, "pairs-skip-authors", "AutoModerator", "Comma-separated accounts (e.g. bots) whose comments never appear in pairs")
+	fs.StringVar(&f.createdFormats, "created-formats", "", "Comma-separated created_utc representations: epoch (int64), iso (created_iso string), timestamp (created_at Parquet TIMESTAMP)")
+	fs.BoolVar(&f.excludeStickied, "exclude-stickied", false, "Drop stickied posts and comments")
+	fs.StringVar(&f.distinguished, "only-distinguished", "", "Keep only records distinguished as these comma-separated roles: moderator, admin, special")
+	fs.BoolVar(&f.mediaTable, "media-table", false, "Extract submission galleries and media_metadata into <output>_media.parquet (submission_id, media_id, type, url, ...)")
+	fs.StringVar(&f.pollData, "poll-data", "keep", "Poll submissions: keep, columns (poll_end_utc, poll_total_votes, poll_options, poll_option_votes), table (<output>_polls.parquet) or both")
+	fs.StringVar(&f.awards, "awards", "keep", "Replace all_awardings with: columns (award_count, award_coin_total), table (<output>_awards.parquet) or both; keep leaves it")
+	fs.BoolVar(&f.commentDepth, "comment-depth", false, "Add a depth column to comments (0 for top-level) by chaining parent_id within the input; null when a parent is missing")
+	fs.BoolVar(&f.threadTable, "thread-table", false, "Aggregate comments per link_id into <output>_threads.parquet (counts, unique commenters, first/last comment time, score stats)")
+	fs.BoolVar(&f.normalizedNames, "normalized-names", false, "Add subreddit_normalized and author_normalized columns: lowercased names as used by filters and reports")
+	fs.StringVar(&f.nonCommunity, "non-community", "keep", "Promoted posts and user profile content: keep, flag (content_kind column), drop or separate (to <output>_noncommunity.jsonl)")
+	fs.StringVar(&f.edited, "edited", "keep", "Edited content: keep (unchanged), flag (boolean edited plus edited_utc column) or drop (drop edited records)")
+	fs.BoolVar(&f.retrievalLag, "retrieval-lag", false, "Add a retrieval_lag_seconds column: retrieved_on minus created_utc")
+	fs.DurationVar(&f.maxRetrievalLag, "max-retrieval-lag", 0, "Drop records retrieved more than this long after creation (e.g. 72h), whose scores are unreliable")
+	fs.StringVar(&f.deriveTZ, "derive-tz", "", "Add hour/weekday columns in UTC and in this IANA timezone (e.g. America/New_York)")
+	fs.StringVar(&f.textFields, "text-fields", "body,selftext,title", "Comma-separated fields affected by -html-unescape, -emoji and -unicode-normalize")
+	fs.BoolVar(&f.htmlUnescape, "html-unescape", false, "Decode HTML entities in text fields, including double-escaped ones like &amp;gt;")
+	fs.StringVar(&f.emoji, "emoji", "keep", "Emoji in text fields: keep, normalize (drop variation selectors and skin tones) or strip")
+	fs.StringVar(&f.unicodeNorm, "unicode-normalize", "", "Unicode normalization of text fields: nfc or nfkc")
+	fs.BoolVar(&f.extraJSON, "extra-json", false, "Keep a fixed typed schema and move all other fields into one extra_json string column")
+	fs.StringVar(&f.canonicalSchema, "canonical-schema", "", "Normalize records of every dump vintage to a canonical schema version (v1), dropping other fields unless -extra-json is set")
+	fs.StringVar(&f.dumpVintage, "dump-vintage", "auto", "Field layout of the input for -canonical-schema: auto (detect from a sample), "+strings.Join(processor.Vintages, ", "))
+	fs.StringVar(&f.schemaFields, "schema-fields", "", "Comma-separated name:TYPE fields of the -extra-json schema, replacing the built-in comment/submission schema")
+	fs.StringVar(&f.fields, "fields", "", "Comma-separated fields, globs or /regex/ patterns to keep, removing every other field of each record (e.g. id,author,subreddit,body,created_utc,score)")
+	fs.StringVar(&f.dropFields, "drop-fields", "", "Comma-separated field globs (media*,*_flair_richtext) or /regex/ patterns to remove from every record")
+	fs.Float64Var(&f.maxNullFraction, "max-null-fraction", 0, "Drop columns that are absent or null in more than this fraction of sampled records, e.g. 0.99 (0 to disable)")
+	fs.IntVar(&f.nullSampleSize, "null-sample-size", 100000, "Records sampled from the start of the input for -max-null-fraction")
+	fs.Var(&f.targetParquet, "target-parquet-size", "Size parts so each Parquet file is about this large (e.g. 1GB), learning the JSONL/Parquet ratio as parts convert")
+	f.registerConverter(fs)
+	fs.BoolVar(&f.continueOnPart, "continue-on-part-error", false, "Keep going when a part fails to convert, recording it in the manifest for a later retry")
+	fs.BoolVar(&f.quantiles, "quantiles", false, "Report p50/p90/p99 of score, num_comments and body length of the output (t-digest, no second pass)")
+	fs.StringVar(&f.subredditReport, "subreddit-report", "", "Write per-subreddit counts, unique authors, score quantiles and date ranges to this .csv or .json file")
+	fs.StringVar(&f.removeIDs, "remove-ids", "", "File of record ids or fullnames, one per line, to remove from the output to honor deletion requests")
+	fs.StringVar(&f.removeAuthors, "remove-authors", "", "File of authors, one per line, whose records are removed from the output to honor deletion requests")
+	fs.StringVar(&f.redactionPolicy, "redaction-policy", processor.DefaultRedactionPolicyPath(), "JSON file of column redaction rules (drop, hash, truncate) applied to every run when it exists; empty to disable")
+	fs.Var(&f.schemaReport, "schema-report", "Write the inferred schema of the output, every field with its JSON types, to this JSON file (<output>_schema.json when given alone) with sorted keys for diffing across dumps")
+	fs.IntVar(&f.maxRecordBytes, "max-record-bytes", 0, "Maximum encoded size of an output record (0 for no limit)")
+	fs.StringVar(&f.oversizedPolicy, "oversized-policy", "drop", "What to do with records over -max-record-bytes: drop, truncate or quarantine (to <output>_oversized.jsonl)")
+	fs.IntVar(&f.droppedSample, "dropped-sample", 0, "Keep a random sample of up to this many records dropped by each filter, written to -dropped-sample-file to check what the filters exclude")
+	fs.StringVar(&f.droppedFile, "dropped-sample-file", "", "File of the -dropped-sample records (defaults to <output>_dropped_sample.jsonl)")
+	fs.StringVar(&f.onBadLine, "on-bad-line", "", "Check that each input line is valid JSON: skip invalid lines, quarantine them (to <output>_bad_lines.jsonl) or fail the run (unchecked if empty)")
+	fs.StringVar(&f.badRecords, "bad-records", "", "Report records a transform fails on with their offset, part and a hexdump: log (then fail) or quarantine (to <output>_bad_records.jsonl, and go on)")
+	fs.StringVar(&f.wasmTransforms, "wasm-transform", "", "Comma-separated WebAssembly transform modules applied to every record")
+	fs.StringVar(&f.script, "script", "", "Lua script defining per-record filter and/or transform functions")
+	fs.DurationVar(&f.scriptBudget, "script-budget", processor.DefaultScriptBudget, "Maximum time a -script may spend on a single record")
+}
+
+// options converts the parsed flags into the options of a run, without its filters, transforms,
+// sink and routes
+func (f *processFlags) options() pushshift.Options {
+	return pushshift.Options{
+		CountOnly:            f.countOnly,
+		CountBySubreddit:     f.countBySubreddit,
+		Quantiles:            f.quantiles,
+		Append:               f.appendOutput,
+		Resume:               f.resume,
+		SingleFile:           f.singleFile,
+		PartitionBy:          splitList(f.partitionBy),
+		MaxOpenFiles:         f.maxOpenFiles,
+		SpillBufferBytes:     int64(f.spillBuffer),
+		WriteBehind:          f.writeBehind,
+		Workers:              f.workers,
+		PartCompressionLevel: f.compressParts,
+		SplitOnly:            f.splitOnly,
+		ConcatInputs:         f.concatInputs,
+		PartSize:             int64(f.partSize),
+		FallbackConverters:   f.fallbacks,
+		ContinueOnPartError:  f.continueOnPart,
+		IOHints:              f.ioHints,
+		LineEndings:          f.lineEndings,
+		WaitForData:          f.waitForData,
+		StartAt:              time.Time(f.startAt),
+		SkipLines:            f.skipLines,
+		BadRecords:           f.badRecords,
+		BadRecordsPath:       f.output + "_bad_records.jsonl",
+		OnBadLine:            f.onBadLine,
+		BadLinesPath:         f.output + "_bad_lines.jsonl",
+		DroppedSample:        f.droppedSample,
+		DroppedSamplePath:    f.droppedSamplePath(),
+		TakeLines:            f.takeLines,
+		Cache:                f.inputCache(),
+		MinThroughput:        processor.ThroughputFloor(f.minThroughput),
+		ReadAhead:            processor.ReadAhead{Chunks: f.readAhead, ChunkSize: int64(f.readAheadChunk)},
+		TargetParquetSize:    int64(f.targetParquet),
+		Parquet:              f.parquetOptions(),
+		ParquetMetadata:      f.parquetMetadata(),
+	}
+}
+
+// droppedSamplePath returns the file of the -dropped-sample records
+func (f *processFlags) droppedSamplePath() string {
+	if f.droppedFile != "" {
+		return f.droppedFile
+	}
+	return f.output + "_dropped_sample.jsonl"
+}
+
+// routingConfig loads the -routes config, nil without one
+func (f *processFlags) routingConfig() (*pushshift.RoutingConfig, error) {
+	if f.routes == "" {
+		return nil, nil
+	}
+	config, err := processor.LoadRoutingConfig(f.routes)
+	if err != nil {
+		return nil, err
+	}
+	log.Printf("🧭 Routing records to %s", strings.Join(config.Outputs(), ", "))
+	return config, nil
+}
+
+// parquetMetadata returns the provenance stamped into every Parquet part besides what the
+// processor adds itself: the tool version
+func (f *processFlags) parquetMetadata() map[string]string {
+	return map[string]string{processor.MetadataToolVersion: toolVersion()}
+}
+
+// registerConverter defines the flags of the Parquet writer and converters, shared with the
+// convert command
+func (f *processFlags) registerConverter(fs *flag.FlagSet) {
+	fs.StringVar(&f.converter, "converter", processor.ConverterDuckDB, "Parquet converter: duckdb runs DuckDB in-process (the duckdb command in builds without cgo), native writes Parquet with no DuckDB at all")
+	fs.StringVar(&f.duckdbSQL, "duckdb-sql", "", "SQL template the DuckDB converter runs on each part instead of the default, with {{.Input}}, {{.Output}}, {{.Replace}}, {{.CopyOptions}}, {{.Compression}} and {{.Settings}}")
+	fs.Func("duckdb-sql-file", "File holding the -duckdb-sql template", func(path string) error {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("failed to read the DuckDB SQL template: %v", err)
+		}
+		f.duckdbSQL = string(data)
+		return nil
+	})
+	fs.Int64Var(&f.rowGroupRows, "parquet-row-group-rows", 0, "Maximum rows per Parquet row group (0 for the converter default)")
+	fs.Var(&f.rowGroupBytes, "parquet-row-group-size", "Maximum Parquet row group size, e.g. 128MB (lets DuckDB reorder rows within a part)")
+	fs.StringVar(&f.compression, "parquet-compression", "", "Parquet codec: snappy, zstd, gzip, lz4, brotli or uncompressed (converter default if empty)")
+	fs.IntVar(&f.compressionLevel, "parquet-compression-level", 0, "Compression level for zstd, gzip and brotli")
+	fs.Var(&f.fallbacks, "fallback-converter", "Shell command converting a part the primary converter fails on, given the JSONL part as $1 and the Parquet file to write as $2; repeatable, tried in order")
+	fs.Var(&f.pageSize, "parquet-page-size", "Target Parquet data page size, e.g. 1MB (not supported by the DuckDB converter)")
+	fs.BoolVar(&f.statistics, "parquet-statistics", true, "Write min/max column statistics (the DuckDB converter always does)")
+}
+
+// parquetOptions returns the Parquet writer settings of the flags
+func (f *processFlags) parquetOptions() processor.ParquetOptions {
+	return processor.ParquetOptions{
+		Converter:         f.converter,
+		DuckDBSQL:         f.duckdbSQL,
+		RowGroupRows:      f.rowGroupRows,
+		RowGroupBytes:     int64(f.rowGroupBytes),
+		Compression:       f.compression,
+		CompressionLevel:  f.compressionLevel,
+		PageSize:          int64(f.pageSize),
+		DisableStatistics: !f.statistics,
+	}
+}
+
+// applyPreset fills -subreddits, -start-at and -end-at from the selected -preset, keeping the
+// ones given on the command line
+func (f *processFlags) applyPreset(fs *flag.FlagSet) error {
+	if f.preset == "" {
+		return nil
+	}
+	presets, err := processor.LoadPresets(f.presetsFile)
+	if err != nil {
+		return err
+	}
+	preset, ok := presets[f.preset]
+	if !ok {
+		return fmt.Errorf("unknown preset %q, expected one of %s", f.preset, strings.Join(processor.PresetNames(presets), ", "))
+	}
+	set := make(map[string]bool)
+	fs.Visit(func(fl *flag.Flag) { set[fl.Name] = true })
+	set["start-at"] = set["start-at"] || set["after"]
+	set["end-at"] = set["end-at"] || set["before"]
+	values := map[string]string{"subreddits": strings.Join(preset.Subreddits, ","), "start-at": preset.Start, "end-at": preset.End}
+	for _, name := range []string{"subreddits", "start-at", "end-at"} {
+		if set[name] || values[name] == "" {
+			continue
+		}
+		if err := fs.Set(name, values[name]); err != nil {
+			return fmt.Errorf("preset %s: %v", f.preset, err)
+		}
+	}
+	log.Printf("🎛️ Using preset %s: %s", f.preset, preset)
+	return nil
+}
+
+// filters loads the record filters selected by the flags, which pick the records a run keeps
+// before any transform sees them
+func (f *processFlags) filters() ([]processor.Transform, error) {
+	var filters []processor.Transform
+	if f.removeIDs != "" || f.removeAuthors != "" {
+		// First, so no other filter, transform or side output sees the removed records
+		lists, err := processor.LoadDeletionLists(f.removeIDs, f.removeAuthors)
+		if err != nil {
+			return nil, err
+		}
+		log.Printf("🗑️ Removing %d ids and %d authors on the deletion lists", len(lists.IDs), len(lists.Authors))
+		filters = append(filters, processor.NewDeletionFilter(lists))
+	}
+	if start := time.Time(f.startAt); !start.IsZero() {
+		filters = append(filters, &processor.StartAtFilter{Start: start})
+	}
+	if end := time.Time(f.endAt); !end.IsZero() {
+		filters = append(filters, &processor.EndAtFilter{End: end})
+	}
+	if subreddits := splitList(f.subreddits); len(subreddits) > 0 {
+		t, err := processor.NewSubredditFilter(subreddits)
+		if err != nil {
+			return nil, err
+		}
+		filters = append(filters, t)
+	}
+	if f.excludeStickied || f.distinguished != "" {
+		t, err := processor.NewOfficialContentFilter(f.excludeStickied, splitList(f.distinguished))
+		if err != nil {
+			return nil, err
+		}
+		filters = append(filters, t)
+	}
+	if f.minScore.set {
+		filters = append(filters, &processor.ScoreFilter{Min: f.minScore.value})
+	}
+	if f.authorsFile != "" {
+		t, err := processor.LoadAuthorFilter(f.authorsFile)
+		if err != nil {
+			return nil, err
+		}
+		log.Printf("👤 Keeping records of %d authors", t.Len())
+		filters = append(filters, t)
+	}
+	return filters, nil
+}
+
+// transforms loads the record transforms selected by the flags, in the order they are applied
+func (f *processFlags) transforms() ([]processor.Transform, error) {
+	var transforms []processor.Transform
+	if f.dedup {
+		transforms = append(transforms, processor.NewDedupTransform())
+	}
+	// Reconciled columns are typed before derived fields, whose own types take precedence
+	if f.reconciledSchema != nil {
+		transforms = append(transforms, processor.NewReconciledSchemaTransform(f.reconciledSchema))
+	}
+	// Classification, edited normalization, media, poll and award extraction, comment depth and
+	// name normalization read fields the fixed schemas move or drop, so they run before them, and the fixed schemas
+	// keep their columns typed
+	derivedFields := make(map[string]string)
+	nonCommunity, err := processor.NewNonCommunityTransform(f.nonCommunity, f.output+"_noncommunity.jsonl")
+	if err != nil {
+		processor.CloseTransforms(transforms)
+		return nil, err
+	}
+	if nonCommunity != nil {
+		transforms = append(transforms, nonCommunity)
+		maps.Copy(derivedFields, nonCommunity.Fields())
+	}
+	edited, err := processor.NewEditedTransform(f.edited)
+	if err != nil {
+		processor.CloseTransforms(transforms)
+		return nil, err
+	}
+	if edited != nil {
+		transforms = append(transforms, edited)
+		maps.Copy(derivedFields, edited.Fields())
+	}
+	if f.mediaTable {
+		transforms = append(transforms, processor.NewMediaTableTransform(f.output))
+	}
+	polls, err := processor.NewPollTransform(f.pollData, f.output)
+	if err != nil {
+		processor.CloseTransforms(transforms)
+		return nil, err
+	}
+	if polls != nil {
+		transforms = append(transforms, polls)
+		maps.Copy(derivedFields, polls.Fields())
+	}
+	awards, err := processor.NewAwardTransform(f.awards, f.output)
+	if err != nil {
+		processor.CloseTransforms(transforms)
+		return nil, err
+	}
+	if awards != nil {
+		transforms = append(transforms, awards)
+		maps.Copy(derivedFields, awards.Fields())
+	}
+	if f.commentDepth {
+		t, err := processor.NewCommentDepthTransform()
+		if err != nil {
+			processor.CloseTransforms(transforms)
+			return nil, err
+		}
+		transforms = append(transforms, t)
+		maps.Copy(derivedFields, t.Fields())
+	}
+	if f.normalizedNames {
+		t := &processor.NormalizedNamesTransform{}
+		transforms = append(transforms, t)
+		maps.Copy(derivedFields, t.Fields())
+	}
+	// The canonical schema and overflow column run next so columns added by later transforms stay
+	// typed columns
+	if f.canonicalSchema != "" {
+		t, err := f.canonicalSchemaTransform(derivedFields)
+		if err != nil {
+			processor.CloseTransforms(transforms)
+			return nil, err
+		}
+		transforms = append(transforms, t)
+	}
+	if f.extraJSON {
+		schema := processor.ParseSchemaFields(splitList(f.schemaFields))
+		if len(schema) == 0 {
+			schema = maps.Clone(processor.CanonicalFields)
+		}
+		maps.Copy(schema, derivedFields)
+		t, err := processor.NewOverflowTransform(schema)
+		if err != nil {
+			processor.CloseTransforms(transforms)
+			return nil, err
+		}
+		transforms = append(transforms, t)
+	}
+	if f.createdFormats != "" {
+		t, err := processor.NewCreatedTimeTransform(splitList(f.createdFormats))
+		if err != nil {
+			processor.CloseTransforms(transforms)
+			return nil, err
+		}
+		transforms = append(transforms, t)
+	}
+	if f.retrievalLag || f.maxRetrievalLag > 0 {
+		transforms = append(transforms, &processor.RetrievalLagTransform{Column: f.retrievalLag, MaxLag: f.maxRetrievalLag})
+	}
+	if f.deriveTZ != "" {
+		t, err := processor.NewLocalTimeTransform(f.deriveTZ)
+		if err != nil {
+			processor.CloseTransforms(transforms)
+			return nil, err
+		}
+		transforms = append(transforms, t)
+	}
+	if f.htmlUnescape || f.emoji != "keep" || f.unicodeNorm != "" {
+		t, err := processor.NewTextNormalizeTransform(processor.TextNormalizeOptions{
+			Fields:       splitList(f.textFields),
+			UnescapeHTML: f.htmlUnescape,
+			Emoji:        f.emoji,
+			Unicode:      f.unicodeNorm,
+		})
+		if err != nil {
+			processor.CloseTransforms(transforms)
+			return nil, err
+		}
+		transforms = append(transforms, t)
+	}
+	for _, spec := range f.joins {
+		csvPath, field, ok := strings.Cut(spec, ":")
+		if !ok || csvPath == "" || field == "" {
+			processor.CloseTransforms(transforms)
+			return nil, fmt.Errorf("invalid -join %q, expected table.csv:field", spec)
+		}
+		t, err := processor.LoadJoinTransform(csvPath, field, f.joinMemoryMB*1024*1024, f.logger)
+		if err != nil {
+			processor.CloseTransforms(transforms)
+			return nil, err
+		}
+		transforms = append(transforms, t)
+	}
+	if f.subredditMeta != "" {
+		t, err := processor.LoadSubredditMetadata(f.subredditMeta, f.joinMemoryMB*1024*1024, f.logger)
+		if err != nil {
+			processor.CloseTransforms(transforms)
+			return nil, err
+		}
+		transforms = append(transforms, t)
+	}
+	if f.domainCategory || f.domainList != "" {
+		t, err := processor.NewDomainCategoryTransform(f.domainList)
+		if err != nil {
+			processor.CloseTransforms(transforms)
+			return nil, err
+		}
+		transforms = append(transforms, t)
+	}
+	if f.subredditStatus || f.statusList != "" {
+		t, err := processor.NewSubredditStatusTransform(f.statusList)
+		if err != nil {
+			processor.CloseTransforms(transforms)
+			return nil, err
+		}
+		transforms = append(transforms, t)
+	}
+	for _, path := range splitList(f.wasmTransforms) {
+		t, err := processor.LoadWASMTransform(path)
+		if err != nil {
+			processor.CloseTransforms(transforms)
+			return nil, err
+		}
+		log.Printf("🧩 Loaded WebAssembly transform %s", path)
+		transforms = append(transforms, t)
+	}
+	if f.script != "" {
+		t, err := processor.LoadScriptTransform(f.script, f.scriptBudget, 0)
+		if err != nil {
+			processor.CloseTransforms(transforms)
+			return nil, err
+		}
+		log.Printf("📜 Loaded Lua script %s", f.script)
+		transforms = append(transforms, t)
+	}
+	if f.fields != "" {
+		t, err := processor.NewSelectFieldsTransform(splitList(f.fields))
+		if err != nil {
+			processor.CloseTransforms(transforms)
+			return nil, fmt.Errorf("invalid -fields: %v", err)
+		}
+		transforms = append(transforms, t)
+	}
+	if f.dropFields != "" {
+		t, err := processor.NewDropFieldsTransform(splitList(f.dropFields))
+		if err != nil {
+			processor.CloseTransforms(transforms)
+			return nil, err
+		}
+		transforms = append(transforms, t)
+	}
+	if f.maxNullFraction > 0 {
+		t, err := processor.NewNullFractionTransform(f.input, f.nullSampleSize, f.maxNullFraction, f.inputCache(), f.logger)
+		if err != nil {
+			processor.CloseTransforms(transforms)
+			return nil, err
+		}
+		transforms = append(transforms, t)
+	}
+	if f.redactionPolicy != "" {
+		// After the filters, which may need the original values, and before the size limit and
+		// the reports, so they see the redacted records
+		policy, err := processor.LoadRedactionPolicy(f.redactionPolicy, f.redactionPolicy != processor.DefaultRedactionPolicyPath())
+		if err != nil {
+			processor.CloseTransforms(transforms)
+			return nil, err
+		}
+		if policy != nil {
+			t, err := processor.NewRedactionTransform(policy)
+			if err != nil {
+				processor.CloseTransforms(transforms)
+				return nil, err
+			}
+			log.Printf("🕶️ Applying redaction policy %s (%d rules)", policy.Source, len(policy.Rules))
+			transforms = append(transforms, t)
+		}
+	}
+	if f.maxRecordBytes > 0 {
+		// Checked last so the limit applies to the record as it will be written
+		t, err := processor.NewSizeLimitTransform(f.maxRecordBytes, f.oversizedPolicy, f.output+"_oversized.jsonl")
+		if err != nil {
+			processor.CloseTransforms(transforms)
+			return nil, err
+		}
+		transforms = append(transforms, t)
+	}
+	if f.threadTable {
+		// After the filters, so threads aggregate the comments as they are written
+		t, err := processor.NewThreadTableTransform(f.output)
+		if err != nil {
+			processor.CloseTransforms(transforms)
+			return nil, err
+		}
+		transforms = append(transforms, t)
+	}
+	if f.subredditReport != "" {
+		// Last, so the report describes the records as they are written
+		transforms = append(transforms, processor.NewSubredditReport(f.subredditReport))
+	}
+	if f.schemaReport.set {
+		transforms = append(transforms, processor.NewSchemaReport(f.schemaReport.or(f.output+"_schema.json")))
+	}
+	return transforms, nil
+}
+
+// batchTransforms builds the batched enrichments selected by the flags
+func (f *processFlags) batchTransforms() []processor.BatchTransform {
+	var transforms []processor.BatchTransform
+	if f.scoreEndpoint != "" {
+		transforms = append(transforms, processor.NewScoringTransform(processor.ScoringOptions{
+			Endpoint:     f.scoreEndpoint,
+			TextFields:   splitList(f.scoreFields),
+			ColumnPrefix: f.scorePrefix,
+			BatchSize:    f.scoreBatchSize,
+			Concurrency:  f.scoreConcurrency,
+			CacheSize:    f.scoreCacheSize,
+			Timeout:      f.scoreTimeout,
+			Retries:      f.scoreRetries,
+		}))
+	}
+	if f.embedEndpoint != "" {
+		// The vector store's idempotency key must survive into the vector rows
+		metadata := splitList(f.embedMetadata)
+		if f.vectorStore != "" && f.vectorKey != "id" && !slices.Contains(metadata, f.vectorKey) {
+			metadata = append(metadata, f.vectorKey)
+		}
+		transforms = append(transforms, processor.NewEmbeddingTransform(processor.EmbeddingOptions{
+			Endpoint:       f.embedEndpoint,
+			Model:          f.embedModel,
+			TextFields:     splitList(f.embedFields),
+			MetadataFields: metadata,
+			BatchSize:      f.embedBatchSize,
+			Concurrency:    f.embedConcurrency,
+			Retries:        3,
+		}))
+	}
+	return transforms
+}
+
+// sink builds the output sink selected by the flags, or nil for Parquet part files
+func (f *processFlags) sink() (pushshift.Sink, error) {
+	if f.appendOutput && (f.format != "parquet" || f.vectorStore != "") {
+		return nil, fmt.Errorf("-append only applies to Parquet output")
+	}
+	if f.resume && (f.format != "parquet" || f.vectorStore != "") {
+		return nil, fmt.Errorf("-resume only applies to Parquet output")
+	}
+	if f.partitionBy != "" && (f.format != "parquet" || f.vectorStore != "") {
+		return nil, fmt.Errorf("-partition-by only applies to Parquet output")
+	}
+	if f.routes != "" && (f.format != "parquet" || f.vectorStore != "") {
+		return nil, fmt.Errorf("-routes only applies to Parquet output")
+	}
+	if f.partitionBy == "" && f.routes == "" && (f.maxOpenFiles != 0 || f.spillBuffer != 0) {
+		return nil, fmt.Errorf("-max-open-files and -spill-buffer only apply to -partition-by and -routes")
+	}
+	if tables := f.sideTableFlags(); len(tables) > 0 {
+		if f.format != "parquet" || f.vectorStore != "" {
+			return nil, fmt.Errorf("%s only applies to Parquet output", strings.Join(tables, ", "))
+		}
+		if f.appendOutput {
+			return nil, fmt.Errorf("%s cannot be combined with -append", strings.Join(tables, ", "))
+		}
+	}
+	switch f.format {
+	case "parquet":
+	case "corpus":
+		if f.vectorStore != "" {
+			return nil, fmt.Errorf("-format corpus cannot be combined with -vector-store")
+		}
+		return processor.NewCorpusSink(processor.CorpusOptions{
+			OutputPrefix: f.output,
+			Format:       f.corpusFormat,
+			Document:     f.corpusDocument,
+			TextFields:   splitList(f.corpusFields),
+			MinChars:     f.corpusMinChars,
+			MaxChars:     f.corpusMaxChars,
+			Dedup:        f.corpusDedup,
+			DedupBytes:   f.corpusDedupMB * 1024 * 1024,
+			ShardBytes:   f.corpusShardMB * 1024 * 1024,
+		})
+	case "pairs":
+		if f.vectorStore != "" {
+			return nil, fmt.Errorf("-format pairs cannot be combined with -vector-store")
+		}
+		return processor.NewPairsSink(processor.PairsOptions{
+			OutputPrefix:    f.output,
+			MinScore:        f.pairsMinScore,
+			MinChars:        f.pairsMinChars,
+			MaxChars:        f.pairsMaxChars,
+			SkipSelfReplies: !f.pairsSelfReplies,
+			SkipAuthors:     splitList(f.pairsSkipAuthors),
+			ShardBytes:      f.corpusShardMB * 1024 * 1024,
+		})
+	default:
+		return nil, fmt.Errorf("unsupported -format %q, expected parquet, corpus or pairs", f.format)
+	}
+
+	if f.vectorStore == "" {
+		return nil, nil
+	}
+	if f.embedEndpoint == "" {
+		return nil, fmt.Errorf("-vector-store requires -embed-endpoint")
+	}
+	sink, err := processor.NewQdrantSink(f.vectorStore, "")
+	if err != nil {
+		return nil, err
+	}
+	sink.KeyField, sink.BatchSize, sink.Wait = f.vectorKey, f.vectorBatchSize, f.vectorWait
+	deadLetter := f.deadLetter
+	if deadLetter == "" {
+		deadLetter = processor.DeadLetterPath(f.output)
+	}
+	return processor.NewDeadLetterSink(sink, deadLetter, f.sinkRetries), nil
+}
+
+// stringList is a repeatable string flag
+type stringList []string
+
+// String implements flag.Value
+func (l *stringList) String() string {
+	return strings.Join(*l, ",")
+}
+
+// Set implements flag.Value, appending each occurrence
+func (l *stringList) Set(value string) error {
+	*l = append(*l, value)
+	return nil
+}
+
+// optionalPath is a file flag that can be given alone, for a file named after the output, or
+// with a path
+type optionalPath struct {
+	set  bool
+	path string
+}
+
+// String implements flag.Value
+func (p *optionalPath) String() string {
+	if p.set && p.path == "" {
+		return "true"
+	}
+	return p.path
+}
+
+// Set implements flag.Value; "true" is what the flag package sets when the flag is given alone
+func (p *optionalPath) Set(value string) error {
+	switch value {
+	case "true":
+		p.set, p.path = true, ""
+	case "false", "":
+		p.set, p.path = false, ""
+	default:
+		p.set, p.path = true, value
+	}
+	return nil
+}
+
+// IsBoolFlag lets the flag be given without a value
+func (p *optionalPath) IsBoolFlag() bool {
+	return true
+}
+
+// or returns the path given, or fallback when the flag was given alone
+func (p *optionalPath) or(fallback string) string {
+	if p.path == "" {
+		return fallback
+	}
+	return p.path
+}
+
+// byteSize is a size flag accepting plain bytes or KB/MB/GB/TB suffixes (powers of 1024)
+type byteSize int64
+
+// String implements flag.Value
+func (b *byteSize) String() string {
+	if *b == 0 {
+		return "0"
+	}
+	return strconv.FormatInt(int64(*b), 10)
+}
+
+// Set implements flag.Value
+func (b *byteSize) Set(value string) error {
+	upper := strings.ToUpper(strings.TrimSpace(value))
+	multiplier := int64(1)
+	for i, suffix := range []string{"KB", "MB", "GB", "TB"} {
+		if strings.HasSuffix(upper, suffix) {
+			multiplier = 1 << (10 * (i + 1))
+			upper = strings.TrimSpace(strings.TrimSuffix(upper, suffix))
+			break
+		}
+	}
+	upper = strings.TrimSuffix(upper, "B")
+	n, err := strconv.ParseFloat(upper, 64)
+	if err != nil || n < 0 {
+		return fmt.Errorf("invalid size %q, expected e.g. 512MB or 1GB", value)
+	}
+	*b = byteSize(n * float64(multiplier))
+	return nil
+}
+
+// throughputFloor is a flag of the form "20MB/s for 10m"; the window defaults to 10 minutes
+type throughputFloor processor.ThroughputFloor
+
+// String implements flag.Value
+func (t *throughputFloor) String() string {
+	if t.BytesPerSecond == 0 {
+		return ""
+	}
+	return fmt.Sprintf("%dB/s for %s", t.BytesPerSecond, t.Window)
+}
+
+// Set implements flag.Value
+func (t *throughputFloor) Set(value string) error {
+	rate, window, hasWindow := strings.Cut(value, " for ")
+	var size byteSize
+	rate = strings.TrimSpace(rate)
+	if !strings.HasSuffix(rate, "/s") || size.Set(strings.TrimSuffix(rate, "/s")) != nil {
+		return fmt.Errorf("invalid throughput %q, expected e.g. \"20MB/s for 10m\"", value)
+	}
+	t.BytesPerSecond = int64(size)
+	t.Window = 10 * time.Minute
+	if hasWindow {
+		d, err := time.ParseDuration(strings.TrimSpace(window))
+		if err != nil || d <= 0 {
+			return fmt.Errorf("invalid throughput window in %q, expected e.g. \"20MB/s for 10m\"", value)
+		}
+		t.Window = d
+	}
+	return nil
+}
+
+// canonicalSchemaTransform creates the -canonical-schema transform, detecting the dump vintage
+// unless -dump-vintage names one
+func (f *processFlags) canonicalSchemaTransform(derivedFields map[string]string) (*processor.CanonicalSchemaTransform, error) {
+	if f.schemaFields != "" {
+		return nil, fmt.Errorf("-canonical-schema cannot be combined with -schema-fields")
+	}
+	vintage := f.dumpVintage
+	if vintage == "auto" {
+		var err error
+		if vintage, err = processor.DetectVintage(f.input, f.inputCache(), f.logger); err != nil {
+			return nil, err
+		}
+		log.Printf("🏷️ Detected dump vintage %s", vintage)
+	}
+	return processor.NewCanonicalSchemaTransform(f.canonicalSchema, vintage, f.extraJSON, derivedFields)
+}
+
+// sideTableFlags returns the set flags that write side tables next to the Parquet parts
+func (f *processFlags) sideTableFlags() []string {
+	var tables []string
+	if f.mediaTable {
+		tables = append(tables, "-media-table")
+	}
+	if f.pollData == "table" || f.pollData == "both" {
+		tables = append(tables, "-poll-data="+f.pollData)
+	}
+	if f.awards == "table" || f.awards == "both" {
+		tables = append(tables, "-awards="+f.awards)
+	}
+	if f.threadTable {
+		tables = append(tables, "-thread-table")
+	}
+	return tables
+}
+
+// inputCache returns the cache of -cache-dir, nil when disabled
+func (f *processFlags) inputCache() *processor.InputCache {
+	if f.cacheDir == "" {
+		return nil
+	}
+	return &processor.InputCache{Dir: f.cacheDir}
+}
+
+// optionalInt is an integer flag that tells being unset apart from being set to 0
+type optionalInt struct {
+	value int64
+	set   bool
+}
+
+// String implements flag.Value
+func (i *optionalInt) String() string {
+	if !i.set {
+		return ""
+	}
+	return strconv.FormatInt(i.value, 10)
+}
+
+// Set implements flag.Value
+func (i *optionalInt) Set(value string) error {
+	parsed, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid integer %q", value)
+	}
+	i.value, i.set = parsed, true
+	return nil
+}
+
+// startTime is a flag holding a UTC date or time, see processor.ParseStartAt
+type startTime time.Time
+
+// String implements flag.Value
+func (t *startTime) String() string {
+	if time.Time(*t).IsZero() {
+		return ""
+	}
+	return time.Time(*t).Format(time.RFC3339)
+}
+
+// Set implements flag.Value
+func (t *startTime) Set(value string) error {
+	parsed, err := processor.ParseStartAt(value)
+	*t = startTime(parsed)
+	return err
+}
+
+// parseInterspersed parses flags that may appear before or after positional arguments
+// and returns the positional arguments in order
+func parseInterspersed(fs *flag.FlagSet, args []string) []string {
+	var positional []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			os.Exit(2)
+		}
+		args = fs.Args()
+		if len(args) == 0 {
+			return positional
+		}
+		positional = append(positional, args[0])
+		args = args[1:]
+	}
+}
+
+// splitList splits a comma-separated flag value into trimmed, non-empty items
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// flagValues returns the value of every defined flag, for provenance records
+func flagValues(fs *flag.FlagSet) map[string]string {
+	values := make(map[string]string)
+	fs.VisitAll(func(f *flag.Flag) {
+		values[f.Name] = f.Value.String()
+	})
+	return values
+}
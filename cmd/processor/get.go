@@ -0,0 +1,44 @@
+package main
+
+import (
+	"flag"
+	"log"
+	"os"
+
+	"github.com/bhupixb/pushshift-go/internal/processor"
+)
+
+// runGet looks up records by fullname or id, either in converted Parquet outputs or by scanning a zst dump
+func runGet(args []string) {
+	fs := flag.NewFlagSet("get", flag.ExitOnError)
+	datasetFlag := fs.String("dataset", "", "Directory or output prefix of converted Parquet files to query")
+	inputFlag := fs.String("input", "", "Path to input .zst file to scan when no dataset is available")
+
+	ids := parseInterspersed(fs, args)
+
+	if len(ids) == 0 {
+		log.Fatal("❌ At least one record id is required, e.g. get t1_abc123 -dataset ./output")
+	}
+	if *datasetFlag == "" && *inputFlag == "" {
+		log.Fatal("❌ Either -dataset or -input is required")
+	}
+
+	if *datasetFlag != "" {
+		if err := processor.FindRecordsInDataset(*datasetFlag, ids, os.Stdout); err != nil {
+			log.Fatal("❌ Lookup failed:", err)
+		}
+		return
+	}
+
+	// Check if input file exists
+	if !processor.InputExists(*inputFlag) {
+		log.Fatal("❌ Input file does not exist:", *inputFlag)
+	}
+
+	log.Printf("🔍 Scanning %s for %d record(s)", *inputFlag, len(ids))
+	found, err := processor.FindRecordsInInput(*inputFlag, ids, os.Stdout, nil)
+	if err != nil {
+		log.Fatal("❌ Lookup failed:", err)
+	}
+	log.Printf("✅ Found %d of %d record(s)", found, len(ids))
+}
@@ -0,0 +1,31 @@
+package main
+
+import (
+	"flag"
+	"log"
+	"os"
+
+	"github.com/bhupixb/pushshift-go/internal/processor"
+)
+
+// runHead prints the first records of a zst dump for a quick look at unfamiliar data
+func runHead(args []string) {
+	fs := flag.NewFlagSet("head", flag.ExitOnError)
+	countFlag := fs.Int("n", 10, "Number of records to print")
+	fieldsFlag := fs.String("fields", "", "Comma-separated fields to print as a table instead of pretty-printed JSON")
+
+	files := parseInterspersed(fs, args)
+
+	if len(files) != 1 {
+		log.Fatal("❌ Exactly one input file is required, e.g. head -n 20 file.zst")
+	}
+
+	// Check if input file exists
+	if !processor.InputExists(files[0]) {
+		log.Fatal("❌ Input file does not exist:", files[0])
+	}
+
+	if err := processor.PreviewRecords(files[0], *countFlag, splitList(*fieldsFlag), os.Stdout); err != nil {
+		log.Fatal("❌ Preview failed:", err)
+	}
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/bhupixb/pushshift-go/internal/processor"
+)
+
+// runHistory lists past runs recorded in the local ledger
+func runHistory(args []string) {
+	fs := flag.NewFlagSet("history", flag.ExitOnError)
+	ledgerFlag := fs.String("ledger", processor.DefaultLedgerPath(), "SQLite run history ledger")
+	inputFlag := fs.String("input", "", "Only show runs whose input path contains this text")
+	statusFlag := fs.String("status", "", "Only show runs with this status (ok or failed)")
+	sinceFlag := fs.String("since", "", "Only show runs started on or after this date (YYYY-MM-DD)")
+	limitFlag := fs.Int("n", 20, "Maximum number of runs to show")
+	jsonFlag := fs.Bool("json", false, "Print full run records, including parameters and manifests, as JSON")
+
+	fs.Parse(args)
+
+	filter := processor.RunFilter{
+		InputContains: *inputFlag,
+		Status:        *statusFlag,
+		Limit:         *limitFlag,
+	}
+	if *sinceFlag != "" {
+		since, err := time.Parse("2006-01-02", *sinceFlag)
+		if err != nil {
+			log.Fatal("❌ Invalid -since date, expected YYYY-MM-DD: ", err)
+		}
+		filter.Since = since
+	}
+
+	if _, err := os.Stat(*ledgerFlag); os.IsNotExist(err) {
+		log.Fatal("❌ No run history found at ", *ledgerFlag, "; runs are only recorded with -ledger")
+	}
+
+	ledger, err := processor.OpenLedger(*ledgerFlag)
+	if err != nil {
+		log.Fatal("❌ ", err)
+	}
+	defer ledger.Close()
+
+	runs, err := ledger.ListRuns(filter)
+	if err != nil {
+		log.Fatal("❌ ", err)
+	}
+
+	if *jsonFlag {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(runs); err != nil {
+			log.Fatal("❌ Failed to encode runs: ", err)
+		}
+		return
+	}
+
+	table := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(table, "ID\tSTARTED\tSTATUS\tLINES\tDURATION\tPARTS\tINPUT\tOUTPUT")
+	for _, run := range runs {
+		parts := 0
+		if run.Manifest != nil {
+			parts = len(run.Manifest.Parts)
+		}
+		fmt.Fprintf(table, "%d\t%s\t%s\t%d\t%s\t%d\t%s\t%s\n",
+			run.ID, run.StartedAt.Local().Format("2006-01-02 15:04:05"), run.Status, run.TotalLines,
+			(time.Duration(run.ExecutionMs) * time.Millisecond).String(), parts, run.InputPath, run.OutputPrefix)
+	}
+	table.Flush()
+}
@@ -1,50 +1,356 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"fmt"
 	"log"
+	"log/slog"
 	"os"
+	"slices"
+	"strings"
+	"time"
 
 	"github.com/bhupixb/pushshift-go/internal/processor"
+	"github.com/bhupixb/pushshift-go/pkg/pushshift"
 )
 
+// subcommands maps subcommand names to their entry points.
+// Running the binary without a subcommand processes an input file as before.
+var subcommands = map[string]func(args []string){
+	"concat":      runConcat,
+	"convert":     runConvert,
+	"crosscheck":  runCrossCheck,
+	"drain":       runDrain,
+	"estimate":    runEstimate,
+	"get":         runGet,
+	"head":        runHead,
+	"history":     runHistory,
+	"presets":     runPresets,
+	"recompress":  runRecompress,
+	"remove":      runRemove,
+	"reprocess":   runReprocess,
+	"replay":      runReplay,
+	"split":       runSplit,
+	"retry-parts": runRetryParts,
+	"stats":       runStats,
+}
+
 func main() {
-	// Initialize logger
-	processor.InitializeLogger()
+	// Initialize logger; the processor package leaves the standard logger's format alone
+	log.SetFlags(log.Ldate | log.Ltime | log.Lmicroseconds)
+
+	if len(os.Args) > 1 {
+		if run, ok := subcommands[os.Args[1]]; ok {
+			run(os.Args[2:])
+			return
+		}
+	}
+
+	runProcess(os.Args[1:])
+}
 
+// runProcess splits the input file into parts and converts them to Parquet
+func runProcess(args []string) {
 	// Define command-line flags
-	inputFlag := flag.String("input", "", "Path to input .zst file")
-	outputFlag := flag.String("output", "output", "Prefix for output files")
+	var flags processFlags
+	flags.register(flag.CommandLine)
+	var sweep codecSweepFlags
+	sweep.register(flag.CommandLine)
+	flag.CommandLine.Parse(args)
+	if processor.IsBatchInput(flags.input) {
+		if sweep.enabled {
+			log.Fatal("❌ -codec-sweep samples a single input, name one dump with -input")
+		}
+		runBatch(&flags)
+		return
+	}
+	requireInput(&flags)
+	if flags.fileParallelism != 1 {
+		log.Fatal("❌ -file-parallelism applies to a directory or glob of dumps given as -input")
+	}
 
-	flag.Parse()
+	if sweep.enabled {
+		runCodecSweep(&flags, &sweep)
+		return
+	}
+	process(&flags)
+}
 
+// requireInput stops the run unless -input names an existing input
+func requireInput(flags *processFlags) {
 	// Validate command line arguments
-	if *inputFlag == "" {
+	if flags.input == "" {
 		log.Fatal("❌ Input file path is required. Use -input flag")
 	}
 
 	// Check if input file exists
-	if _, err := os.Stat(*inputFlag); os.IsNotExist(err) {
-		log.Fatal("❌ Input file does not exist:", *inputFlag)
+	if !processor.InputExists(flags.input) {
+		log.Fatal("❌ Input file does not exist:", flags.input)
+	}
+}
+
+// process runs the input selected by flags registered on the command line flag set through the
+// transforms into the output, then records and reports the run
+func process(flags *processFlags) {
+	if err := flags.applyPreset(flag.CommandLine); err != nil {
+		log.Fatal("❌ ", err)
+	}
+
+	checkRunFlags(flags)
+	// Refuse to clobber an earlier run's outputs unless asked to; a resumed run keeps them
+	var replaced *processor.ReplacedOutputs
+	if !flags.countOnly && !flags.appendOutput && !flags.resume {
+		var skip bool
+		if replaced, skip = checkExistingOutputs(flags); skip {
+			return
+		}
+	}
+
+	// Initialize processor. Under the terminal UI the run logs at the level chosen there.
+	var extra []pushshift.Option
+	var logLevel slog.LevelVar
+	if flags.tui {
+		extra = append(extra, pushshift.WithLogger(slog.New(levelHandler{level: &logLevel})))
+	}
+	proc, closeTransforms, err := newProcessor(flags, extra...)
+	if err != nil {
+		replaceOutputs(replaced, err, log.Printf)
+		log.Fatal("❌ ", err)
+	}
+	defer closeTransforms()
+
+	// SIGUSR2 toggles pause/resume so operators can free I/O bandwidth without killing the job
+	ctl := proc.Options().Control
+	handlePauseSignal(ctl)
+	closeSocket := func() {}
+	if flags.controlSocket != "" {
+		if closeSocket, err = serveControlSocket(flags.controlSocket, ctl); err != nil {
+			replaceOutputs(replaced, err, log.Printf)
+			log.Fatal("❌ ", err)
+		}
 	}
 
-	// Initialize processor
-	proc := &processor.PushshiftProcessor{}
 	strategyName := "Pushshift Processor (split into parts and convert to Parquet)"
+	if flags.countOnly {
+		strategyName = "Pushshift Processor (count only)"
+	} else if flags.splitOnly {
+		strategyName = "Pushshift Processor (split into JSONL parts)"
+	}
 
 	log.Printf("🚀 Starting %s", strategyName)
-	log.Printf("📖 Input file: %s", *inputFlag)
-	log.Printf("📝 Output prefix: %s", *outputFlag)
+	log.Printf("📖 Input file: %s", flags.input)
+	log.Printf("📝 Output prefix: %s", flags.output)
 
 	// Process the file
-	stats, err := proc.Process(*inputFlag, *outputFlag)
+	started := time.Now()
+	var stats processor.ProcessStats
+	if flags.tui {
+		stats, err = runWithTUI(proc, &logLevel, flags.input, flags.output)
+	} else {
+		stats, err = proc.ProcessFile(context.Background(), flags.input, flags.output)
+	}
+	// Closed before any log.Fatal below so event streams receive the run's outcome
+	closeSocket()
+
+	if flags.ledger != "" {
+		recordRun(flags.ledger, started, flags.input, flags.output, stats, err)
+	}
+	if flags.emailTo != "" {
+		sendReport(flags, stats, err)
+	}
+	replaceOutputs(replaced, err, log.Printf)
 	if err != nil {
+		if cp, _ := processor.ReadCheckpoint(flags.output); cp != nil && !flags.countOnly && replaced == nil {
+			log.Printf("💾 Parts up to %d were converted, rerun with -resume to continue after them", cp.Part)
+		}
 		log.Fatal("❌ Processing failed:", err)
 	}
 
+	if flags.statsJSON != "" {
+		if err := processor.WriteStatsFile(flags.statsJSON, stats); err != nil {
+			log.Fatal("❌ ", err)
+		}
+		log.Printf("🧾 Statistics written to %s", flags.statsJSON)
+	}
+
+	if flags.exportRunSpec != "" {
+		if err := writeRunSpec(flags.exportRunSpec, flag.CommandLine, stats); err != nil {
+			log.Fatal("❌ ", err)
+		}
+		log.Printf("🧾 Run spec written to %s", flags.exportRunSpec)
+	}
+
 	// Print final stats
 	fmt.Println("\n" + stats.String())
 
 	log.Printf("✅ All done!")
 }
+
+// checkRunFlags stops the run when flags combine options that don't go together
+func checkRunFlags(flags *processFlags) {
+	if flags.resume && (flags.countOnly || flags.appendOutput || flags.splitOnly) {
+		log.Fatal("❌ -resume cannot be combined with -count-only, -append or split")
+	}
+	if flags.singleFile && (flags.appendOutput || flags.splitOnly) {
+		log.Fatal("❌ -single-file cannot be combined with -append or split")
+	}
+	if flags.emailTo != "" {
+		if flags.smtpServer == "" || flags.emailFrom == "" {
+			log.Fatal("❌ -email-to requires -smtp-server and -email-from")
+		}
+		if flags.emailOn != "always" && flags.emailOn != "failure" {
+			log.Fatalf("❌ Unsupported -email-on %q, expected always or failure", flags.emailOn)
+		}
+	}
+}
+
+// newProcessor validates the options given by flags and builds the processor of a run with new
+// filters, transforms and sink, adjusted by extra. The returned function closes the filters and
+// transforms.
+func newProcessor(flags *processFlags, extra ...pushshift.Option) (*pushshift.Processor, func(), error) {
+	opts := flags.options()
+	if opts.Workers < 1 {
+		return nil, nil, fmt.Errorf("-workers must be at least 1")
+	}
+	if opts.SkipLines < 0 || opts.TakeLines < 0 {
+		return nil, nil, fmt.Errorf("-skip-lines and -take-lines can't be negative")
+	}
+	if flags.countOnly && (opts.SkipLines > 0 || opts.TakeLines > 0) {
+		return nil, nil, fmt.Errorf("-skip-lines and -take-lines don't apply to -count-only, which counts the whole input")
+	}
+	filters, err := flags.filters()
+	if err != nil {
+		return nil, nil, err
+	}
+	transforms, err := flags.transforms()
+	if err != nil {
+		processor.CloseTransforms(filters)
+		return nil, nil, err
+	}
+	closeTransforms := func() {
+		processor.CloseTransforms(transforms)
+		processor.CloseTransforms(filters)
+	}
+	options := []pushshift.Option{
+		pushshift.WithOptions(opts),
+		pushshift.WithControl(pushshift.NewControl()),
+		pushshift.WithFilter(filters...),
+		pushshift.WithTransform(transforms...),
+		pushshift.WithBatchTransform(flags.batchTransforms()...),
+	}
+	sink, err := flags.sink()
+	if err != nil {
+		closeTransforms()
+		return nil, nil, err
+	}
+	if sink != nil {
+		options = append(options, pushshift.WithSink(sink))
+	}
+	routes, err := flags.routingConfig()
+	if err != nil {
+		closeTransforms()
+		return nil, nil, err
+	}
+	if routes != nil {
+		options = append(options, pushshift.WithRoutes(routes))
+	}
+	proc, err := pushshift.New(append(options, extra...)...)
+	if err != nil {
+		closeTransforms()
+		return nil, nil, err
+	}
+	return proc, closeTransforms, nil
+}
+
+// checkExistingOutputs stops the run when its output prefix already holds results, unless -force
+// (move them aside and continue) or -skip-existing (report true to skip this input) was given.
+// Outputs moved aside are returned for replaceOutputs to remove or restore once the run ends.
+func checkExistingOutputs(flags *processFlags) (*processor.ReplacedOutputs, bool) {
+	if flags.force && flags.skipExisting {
+		log.Fatal("❌ -force and -skip-existing cannot be combined")
+	}
+	existing, err := processor.ExistingOutputs(flags.output)
+	if err != nil {
+		log.Fatal("❌ ", err)
+	}
+	if len(existing) == 0 {
+		return nil, false
+	}
+
+	switch {
+	case flags.skipExisting:
+		log.Printf("⏭️ Output %s already exists (%d files), skipping %s", flags.output, len(existing), flags.input)
+		return nil, true
+	case flags.force:
+		replaced, err := processor.MoveOutputsAside(flags.output, existing)
+		if err != nil {
+			log.Fatal("❌ ", err)
+		}
+		log.Printf("🧹 Moved %d files of an earlier run with output prefix %s aside, they are removed once this run succeeds", len(existing), flags.output)
+		return replaced, false
+	case slices.Contains(existing, processor.CheckpointPath(flags.output)):
+		log.Fatalf("❌ Output prefix %s holds an interrupted run, pass -resume to continue it or -force to start over", flags.output)
+	default:
+		shown := existing
+		if len(shown) > 5 {
+			shown = shown[:5]
+		}
+		log.Fatalf("❌ Output prefix %s already has %d files (%s), pass -force to overwrite them, -skip-existing to skip this input or -append to add to them",
+			flags.output, len(existing), strings.Join(shown, ", "))
+	}
+	return nil, false
+}
+
+// replaceOutputs removes the outputs of an earlier run moved aside by -force once the run that
+// replaces them succeeded, and puts them back when it failed
+func replaceOutputs(replaced *processor.ReplacedOutputs, runErr error, logf func(string, ...any)) {
+	if replaced == nil {
+		return
+	}
+	if runErr == nil {
+		if err := replaced.Remove(); err != nil {
+			logf("⚠️ Warning: %v", err)
+		}
+		return
+	}
+	if err := replaced.Restore(); err != nil {
+		logf("⚠️ Warning: %v", err)
+		return
+	}
+	logf("↩️ Restored the earlier run's outputs, as this run failed")
+}
+
+// sendReport emails the run outcome; failures only warn so they never fail a finished run
+func sendReport(flags *processFlags, stats processor.ProcessStats, runErr error) {
+	report := processor.EmailReport{
+		Server:       flags.smtpServer,
+		From:         flags.emailFrom,
+		To:           splitList(flags.emailTo),
+		FailuresOnly: flags.emailOn == "failure",
+	}
+	if err := report.Send(flags.input, flags.output, stats, runErr); err != nil {
+		log.Printf("⚠️ Warning: %v", err)
+		return
+	}
+	if runErr != nil || !report.FailuresOnly {
+		log.Printf("📧 Sent run report to %s", flags.emailTo)
+	}
+}
+
+// recordRun stores the run in the local ledger; failures only warn so they never fail a finished run
+func recordRun(ledgerPath string, started time.Time, inputPath, outputPrefix string, stats processor.ProcessStats, runErr error) {
+	ledger, err := processor.OpenLedger(ledgerPath)
+	if err != nil {
+		log.Printf("⚠️ Warning: Failed to open run ledger: %v", err)
+		return
+	}
+	defer ledger.Close()
+
+	id, err := ledger.RecordRun(started, inputPath, outputPrefix, flagValues(flag.CommandLine), stats, runErr)
+	if err != nil {
+		log.Printf("⚠️ Warning: %v", err)
+		return
+	}
+	log.Printf("🗂️ Recorded run #%d in %s", id, ledgerPath)
+}
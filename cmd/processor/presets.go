@@ -0,0 +1,32 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/bhupixb/pushshift-go/internal/processor"
+)
+
+// runPresets lists the presets available to -preset, or shows one in full
+func runPresets(args []string) {
+	fs := flag.NewFlagSet("presets", flag.ExitOnError)
+	presetsFile := fs.String("presets-file", processor.DefaultPresetsPath(), "JSON file of presets extending and overriding the bundled ones")
+	names := parseInterspersed(fs, args)
+
+	presets, err := processor.LoadPresets(*presetsFile)
+	if err != nil {
+		log.Fatal("❌ ", err)
+	}
+	if len(names) == 0 {
+		names = processor.PresetNames(presets)
+	}
+	for _, name := range names {
+		preset, ok := presets[name]
+		if !ok {
+			log.Fatalf("❌ Unknown preset %q, expected one of %s", name, strings.Join(processor.PresetNames(presets), ", "))
+		}
+		fmt.Printf("%s [%s]\n  %s\n", name, preset.Source, preset)
+	}
+}
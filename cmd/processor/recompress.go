@@ -0,0 +1,36 @@
+package main
+
+import (
+	"flag"
+	"log"
+
+	"github.com/bhupixb/pushshift-go/internal/processor"
+)
+
+// runRecompress compresses an input again with zstd at a chosen level and window, for
+// re-archiving filtered subsets without the zstd command-line tool
+func runRecompress(args []string) {
+	fs := flag.NewFlagSet("recompress", flag.ExitOnError)
+	levelFlag := fs.Int("level", 19, "zstd compression level, 1 to 22")
+	longFlag := fs.Int("long", 0, "Base 2 logarithm of the match window as with zstd --long, 10 to 31 (0 for the default)")
+
+	files := parseInterspersed(fs, args)
+	if len(files) != 2 {
+		log.Fatal("❌ An input and an output file are required, e.g. recompress in.zst out.zst -level 19 -long 31")
+	}
+	opts := processor.RecompressOptions{Level: *levelFlag, WindowLog: *longFlag}
+	if err := opts.Validate(); err != nil {
+		log.Fatal("❌ ", err)
+	}
+
+	result, err := processor.Recompress(files[0], files[1], opts)
+	if err != nil {
+		log.Fatal("❌ ", err)
+	}
+	if result.InputBytes > 0 {
+		log.Printf("✅ Wrote %s: %.2f MB, down from %.2f MB (%.1f%%)", files[1], float64(result.OutputBytes)/1024/1024,
+			float64(result.InputBytes)/1024/1024, 100*float64(result.OutputBytes)/float64(result.InputBytes))
+		return
+	}
+	log.Printf("✅ Wrote %s: %.2f MB", files[1], float64(result.OutputBytes)/1024/1024)
+}
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"flag"
+	"log"
+
+	"github.com/bhupixb/pushshift-go/internal/processor"
+)
+
+// runRemove excises the records named by deletion lists from converted Parquet outputs, to
+// honor deletion requests in maintained datasets without reprocessing the dumps
+func runRemove(args []string) {
+	fs := flag.NewFlagSet("remove", flag.ExitOnError)
+	idsFlag := fs.String("remove-ids", "", "File of record ids or fullnames, one per line, to remove")
+	authorsFlag := fs.String("remove-authors", "", "File of authors, one per line, whose records are removed")
+	compressionFlag := fs.String("compression", "", "Parquet codec of the rewritten parts: snappy, zstd, gzip, lz4, brotli or uncompressed (default: DuckDB's)")
+	compressionLevelFlag := fs.Int("compression-level", 0, "Compression level of the rewritten parts for zstd, gzip and brotli")
+
+	datasets := parseInterspersed(fs, args)
+	if len(datasets) != 1 {
+		log.Fatal("❌ Exactly one dataset is required, e.g. remove -remove-authors authors.txt out/RC_2023-01")
+	}
+	if *idsFlag == "" && *authorsFlag == "" {
+		log.Fatal("❌ -remove-ids or -remove-authors is required")
+	}
+	parquet := processor.ParquetOptions{Compression: *compressionFlag, CompressionLevel: *compressionLevelFlag}
+	if err := parquet.Validate(); err != nil {
+		log.Fatal("❌ ", err)
+	}
+	lists, err := processor.LoadDeletionLists(*idsFlag, *authorsFlag)
+	if err != nil {
+		log.Fatal("❌ ", err)
+	}
+
+	log.Printf("🗑️ Removing %d ids and %d authors from %s", len(lists.IDs), len(lists.Authors), datasets[0])
+	removed, err := processor.RemoveFromDataset(datasets[0], processor.RemoveOptions{Lists: lists, Parquet: parquet})
+	if err != nil {
+		log.Fatalf("❌ Removal failed after removing %d records: %v", removed, err)
+	}
+	log.Printf("✅ Removed %d records", removed)
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"flag"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/bhupixb/pushshift-go/internal/processor"
+)
+
+// runReprocess runs an existing Parquet dataset through the filters and transforms selected by
+// the processing flags into a new output, to refine a dataset without going back to the dumps
+func runReprocess(args []string) {
+	var flags processFlags
+	flags.register(flag.CommandLine)
+	datasets := parseInterspersed(flag.CommandLine, args)
+	if len(datasets) != 1 {
+		log.Fatal("❌ Exactly one dataset is required, e.g. reprocess out/RC_2023-01 -output out/RC_2023-01_ask -subreddits='ask*'")
+	}
+	dataset := datasets[0]
+	if flags.input != "" {
+		log.Fatal("❌ reprocess reads the dataset given as its argument, -input does not apply")
+	}
+	if !processor.IsParquetDataset(dataset) {
+		log.Fatal("❌ Not a Parquet dataset, expected a .parquet file or glob, a directory or an output prefix: ", dataset)
+	}
+	if overlapsDataset(dataset, flags.output) {
+		log.Fatalf("❌ Output prefix %s would write into the dataset %s being read, choose another -output", flags.output, dataset)
+	}
+	if flags.countOnly {
+		log.Fatal("❌ -count-only does not apply to reprocess")
+	}
+	flags.input = dataset
+	// The input cache keys dumps by their compressed bytes, which a dataset doesn't have
+	flags.cacheDir = ""
+
+	process(&flags)
+}
+
+// overlapsDataset reports whether output files with prefix output would be part of dataset
+func overlapsDataset(dataset, output string) bool {
+	output = filepath.Clean(output)
+	if info, err := os.Stat(dataset); err == nil && info.IsDir() {
+		rel, err := filepath.Rel(dataset, output)
+		return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+	}
+	if strings.HasSuffix(dataset, ".parquet") {
+		matched, _ := filepath.Match(dataset, output+"_part_001.parquet")
+		return matched
+	}
+	return filepath.Clean(dataset) == output
+}
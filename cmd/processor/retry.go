@@ -0,0 +1,61 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"log"
+
+	"github.com/bhupixb/pushshift-go/internal/processor"
+	"github.com/bhupixb/pushshift-go/pkg/pushshift"
+)
+
+// runRetryParts rebuilds the parts of an output that failed to convert or went missing, reading
+// only their lines of the input. It takes the processing flags of the original run, so the
+// parts get the same transforms.
+func runRetryParts(args []string) {
+	fs := flag.NewFlagSet("retry-parts", flag.ExitOnError)
+	var flags processFlags
+	flags.register(fs)
+	skipVerifyFlag := fs.Bool("skip-verify", false, "Do not verify the input checksum before reading the parts")
+
+	prefixes := parseInterspersed(fs, args)
+	if len(prefixes) != 1 {
+		log.Fatal("❌ Exactly one output prefix is required, e.g. retry-parts out/RS_2023-01")
+	}
+	if err := flags.applyPreset(fs); err != nil {
+		log.Fatal("❌ ", err)
+	}
+	flags.output = prefixes[0]
+
+	filters, err := flags.filters()
+	if err != nil {
+		log.Fatal("❌ ", err)
+	}
+	defer processor.CloseTransforms(filters)
+	transforms, err := flags.transforms()
+	if err != nil {
+		log.Fatal("❌ ", err)
+	}
+	defer processor.CloseTransforms(transforms)
+	proc, err := pushshift.New(
+		pushshift.WithOptions(flags.options()),
+		pushshift.WithFilter(filters...),
+		pushshift.WithTransform(transforms...),
+		pushshift.WithBatchTransform(flags.batchTransforms()...),
+	)
+	if err != nil {
+		log.Fatal("❌ ", err)
+	}
+
+	log.Printf("🚀 Retrying failed and missing parts of %s", prefixes[0])
+	retried, err := proc.RetryParts(context.Background(), prefixes[0], pushshift.RetryOptions{Input: flags.input, SkipVerify: *skipVerifyFlag})
+	var failed *pushshift.ErrPartsFailed
+	if errors.As(err, &failed) {
+		log.Fatalf("❌ Rebuilt %d parts, but %v, failed parts remain listed in the manifest", len(retried), err)
+	}
+	if err != nil {
+		log.Fatal("❌ Retry failed: ", err)
+	}
+	log.Printf("✅ All done!")
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/bhupixb/pushshift-go/internal/processor"
+)
+
+// runSpec captures everything needed to re-execute a run identically
+type runSpec struct {
+	ToolVersion string            `json:"tool_version"`
+	CreatedAt   time.Time         `json:"created_at"`
+	Flags       map[string]string `json:"flags"`
+	Input       string            `json:"input"`
+	InputSHA256 string            `json:"input_sha256"`
+}
+
+// replayExcludedFlags are not carried over on replay so the replay doesn't overwrite the spec it reads
+var replayExcludedFlags = map[string]bool{
+	"export-run-spec": true,
+	"force":           true,
+	"skip-existing":   true,
+}
+
+// writeRunSpec writes the reproducibility spec of a finished run
+func writeRunSpec(path string, fs *flag.FlagSet, stats processor.ProcessStats) error {
+	flags := flagValues(fs)
+	spec := runSpec{
+		ToolVersion: toolVersion(),
+		CreatedAt:   time.Now().UTC(),
+		Flags:       flags,
+		Input:       flags["input"],
+		InputSHA256: stats.InputSHA256,
+	}
+
+	data, err := json.MarshalIndent(spec, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode run spec: %v", err)
+	}
+	if err := os.WriteFile(path, append(data, '\n'), 0644); err != nil {
+		return fmt.Errorf("failed to write run spec: %v", err)
+	}
+	return nil
+}
+
+// runReplay re-executes the pipeline described by a run spec
+func runReplay(args []string) {
+	fs := flag.NewFlagSet("replay", flag.ExitOnError)
+	inputFlag := fs.String("input", "", "Override the input path recorded in the spec (checksum must still match)")
+	skipVerifyFlag := fs.Bool("skip-verify", false, "Do not verify the input checksum before replaying")
+	forceFlag := fs.Bool("force", false, "Overwrite the outputs left by the original run")
+
+	specs := parseInterspersed(fs, args)
+	if len(specs) != 1 {
+		log.Fatal("❌ Exactly one run spec is required, e.g. replay run.json")
+	}
+
+	data, err := os.ReadFile(specs[0])
+	if err != nil {
+		log.Fatal("❌ Failed to read run spec: ", err)
+	}
+	var spec runSpec
+	if err := json.Unmarshal(data, &spec); err != nil {
+		log.Fatal("❌ Invalid run spec: ", err)
+	}
+
+	if current := toolVersion(); current != spec.ToolVersion {
+		log.Printf("⚠️ Warning: Spec was produced by %s, replaying with %s", spec.ToolVersion, current)
+	}
+
+	input := spec.Input
+	if *inputFlag != "" {
+		input = *inputFlag
+	}
+
+	if !*skipVerifyFlag && spec.InputSHA256 != "" {
+		log.Printf("🔍 Verifying checksum of %s", input)
+		sum, err := fileSHA256(input)
+		if err != nil {
+			log.Fatal("❌ ", err)
+		}
+		if sum != spec.InputSHA256 {
+			log.Fatalf("❌ Input checksum mismatch: spec has %s, %s has %s", spec.InputSHA256, input, sum)
+		}
+	}
+
+	// Rebuild the original command line in a stable order
+	names := make([]string, 0, len(spec.Flags))
+	for name := range spec.Flags {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	// Flags removed in newer versions are skipped instead of failing the replay
+	var known processFlags
+	knownFlags := flag.NewFlagSet("known", flag.ContinueOnError)
+	known.register(knownFlags)
+
+	replayArgs := []string{"-input=" + input}
+	for _, name := range names {
+		if name == "input" || replayExcludedFlags[name] {
+			continue
+		}
+		if knownFlags.Lookup(name) == nil {
+			log.Printf("⚠️ Warning: Ignoring flag -%s not supported by this version", name)
+			continue
+		}
+		replayArgs = append(replayArgs, "-"+name+"="+spec.Flags[name])
+	}
+
+	if *forceFlag {
+		replayArgs = append(replayArgs, "-force")
+	}
+
+	log.Printf("🔁 Replaying run spec %s", specs[0])
+	runProcess(replayArgs)
+}
+
+// fileSHA256 computes the hex checksum of a file
+func fileSHA256(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to open %s: %v", path, err)
+	}
+	defer f.Close()
+
+	hasher := sha256.New()
+	if _, err := io.Copy(hasher, f); err != nil {
+		return "", fmt.Errorf("failed to hash %s: %v", path, err)
+	}
+	return hex.EncodeToString(hasher.Sum(nil)), nil
+}
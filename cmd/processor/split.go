@@ -0,0 +1,41 @@
+package main
+
+import (
+	"flag"
+	"log"
+	"strings"
+)
+
+// runSplit decompresses the input into JSONL parts without converting them to Parquet, for
+// downstream tools that consume JSONL. It takes the processing flags, so parts can be filtered
+// and transformed on the way.
+func runSplit(args []string) {
+	var flags processFlags
+	flags.register(flag.CommandLine)
+	flag.CommandLine.Var(&flags.partSize, "part-size", "Close parts at this much uncompressed JSONL, e.g. 1GB (defaults to 8GB)")
+	compressFlag := flag.CommandLine.Int("compress", 0, "Compress the parts with zstd at this level, 1 to 22 (0 keeps them plain JSONL)")
+	flag.CommandLine.Parse(args)
+	requireInput(&flags)
+
+	if flags.countOnly {
+		log.Fatal("❌ -count-only does not apply to split")
+	}
+	if *compressFlag != 0 {
+		if flags.compressParts != 0 {
+			log.Fatal("❌ -compress and -compress-parts cannot be combined, split compresses its parts with -compress")
+		}
+		flags.compressParts = *compressFlag
+	}
+	if flags.partSize < 0 {
+		log.Fatal("❌ -part-size can't be negative")
+	}
+	if flags.format != "parquet" || flags.vectorStore != "" {
+		log.Fatal("❌ split writes JSONL parts, -format and -vector-store do not apply")
+	}
+	if tables := flags.sideTableFlags(); len(tables) > 0 {
+		log.Fatalf("❌ split does not write side tables, %s do not apply", strings.Join(tables, ", "))
+	}
+	flags.splitOnly = true
+
+	process(&flags)
+}
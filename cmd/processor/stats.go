@@ -0,0 +1,45 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+
+	"github.com/bhupixb/pushshift-go/internal/processor"
+)
+
+// runStats dispatches the stats subcommands
+func runStats(args []string) {
+	if len(args) == 0 || args[0] != "merge" {
+		log.Fatal("❌ Usage: stats merge [-output merged.json] stats.json... (or _manifest.json files)")
+	}
+	runStatsMerge(args[1:])
+}
+
+// runStatsMerge sums the statistics of many runs, such as one run per monthly dump, into a
+// batch summary
+func runStatsMerge(args []string) {
+	fs := flag.NewFlagSet("stats merge", flag.ExitOnError)
+	outputFlag := fs.String("output", "", "Also write the merged statistics to this JSON file")
+	files := parseInterspersed(fs, args)
+	if len(files) == 0 {
+		log.Fatal("❌ At least one -stats-json file or manifest is required")
+	}
+
+	var merged processor.ProcessStats
+	for _, path := range files {
+		stats, err := processor.ReadStatsFile(path)
+		if err != nil {
+			log.Fatal("❌ ", err)
+		}
+		merged.Merge(stats)
+	}
+	fmt.Println(merged.String())
+
+	if *outputFlag != "" {
+		if err := processor.WriteStatsFile(*outputFlag, merged); err != nil {
+			log.Fatal("❌ ", err)
+		}
+		log.Printf("🧾 Merged statistics of %d runs written to %s", merged.Runs, *outputFlag)
+	}
+}
@@ -0,0 +1,282 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"log/slog"
+	"os"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+
+	"github.com/bhupixb/pushshift-go/internal/processor"
+	"github.com/bhupixb/pushshift-go/pkg/pushshift"
+)
+
+const (
+	tuiRefreshInterval = 500 * time.Millisecond
+	tuiMaxLogLines     = 8
+	tuiMaxParts        = 3
+)
+
+// logLevels are the levels the TUI cycles through: the run logs messages at or above the chosen
+// level, and the log pane shows the captured lines at or above it
+var logLevels = []slog.Level{slog.LevelInfo, slog.LevelWarn, slog.LevelError}
+
+// levelHandler logs the messages of a run at or above a level through the standard logger, which
+// the TUI captures
+type levelHandler struct {
+	level slog.Leveler
+}
+
+// Enabled implements slog.Handler
+func (h levelHandler) Enabled(_ context.Context, level slog.Level) bool {
+	return level >= h.level.Level()
+}
+
+// Handle implements slog.Handler
+func (h levelHandler) Handle(_ context.Context, r slog.Record) error {
+	log.Print(r.Message)
+	return nil
+}
+
+// WithAttrs implements slog.Handler; attributes are left out of the messages
+func (h levelHandler) WithAttrs([]slog.Attr) slog.Handler {
+	return h
+}
+
+// WithGroup implements slog.Handler
+func (h levelHandler) WithGroup(string) slog.Handler {
+	return h
+}
+
+// logBuffer captures log output so it can be shown inside the TUI instead of corrupting the screen
+type logBuffer struct {
+	mu    sync.Mutex
+	lines []string
+}
+
+// Write implements io.Writer for the standard logger
+func (b *logBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for _, line := range strings.Split(strings.TrimRight(string(p), "\n"), "\n") {
+		b.lines = append(b.lines, line)
+	}
+	if len(b.lines) > 1000 {
+		b.lines = b.lines[len(b.lines)-1000:]
+	}
+	return len(p), nil
+}
+
+// recent returns the last n captured lines at or above the given level
+func (b *logBuffer) recent(n, level int) []string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	var out []string
+	for i := len(b.lines) - 1; i >= 0 && len(out) < n; i-- {
+		if logLineLevel(b.lines[i]) >= level {
+			out = append([]string{b.lines[i]}, out...)
+		}
+	}
+	return out
+}
+
+// logLineLevel classifies a log line by the marker the processor uses for it
+func logLineLevel(line string) int {
+	switch {
+	case strings.Contains(line, "❌"):
+		return 2
+	case strings.Contains(line, "⚠️"):
+		return 1
+	default:
+		return 0
+	}
+}
+
+type tickMsg time.Time
+
+type eventMsg processor.Event
+
+type doneMsg struct {
+	stats processor.ProcessStats
+	err   error
+}
+
+// tuiModel is the bubbletea model rendering live progress of a run
+type tuiModel struct {
+	ctl      *processor.Control
+	logs     *logBuffer
+	logLevel *slog.LevelVar
+	input    string
+	start    time.Time
+	prev     processor.ControlSnapshot
+	prevAt   time.Time
+	readRate float64
+	lineRate float64
+	level    int
+	parts    []processor.Event
+	aborted  bool
+}
+
+func tick() tea.Cmd {
+	return tea.Tick(tuiRefreshInterval, func(t time.Time) tea.Msg { return tickMsg(t) })
+}
+
+// Init starts the refresh ticker
+func (m *tuiModel) Init() tea.Cmd {
+	return tick()
+}
+
+// Update handles key presses, refresh ticks, events and completion of the run
+func (m *tuiModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.KeyMsg:
+		switch msg.String() {
+		case "p", " ":
+			m.ctl.TogglePause()
+		case "s":
+			m.ctl.SkipPart()
+		case "l":
+			m.level = (m.level + 1) % len(logLevels)
+			m.logLevel.Set(logLevels[m.level])
+		case "q", "ctrl+c":
+			// Keep the program running until the run has stopped so its outputs and scratch
+			// space are settled before process() decides what to keep
+			if !m.aborted {
+				m.aborted = true
+				m.ctl.Cancel()
+			}
+		}
+	case tickMsg:
+		now := time.Time(msg)
+		snap := m.ctl.Snapshot()
+		if elapsed := now.Sub(m.prevAt).Seconds(); elapsed > 0 {
+			m.readRate = float64(snap.BytesRead-m.prev.BytesRead) / elapsed / 1024 / 1024
+			m.lineRate = float64(snap.LinesProcessed-m.prev.LinesProcessed) / elapsed
+		}
+		m.prev, m.prevAt = snap, now
+		return m, tick()
+	case eventMsg:
+		if msg.Kind == processor.EventPartFinished || msg.Kind == processor.EventPartFailed {
+			m.parts = append(m.parts, processor.Event(msg))
+			if len(m.parts) > tuiMaxParts {
+				m.parts = m.parts[1:]
+			}
+		}
+	case doneMsg:
+		return m, tea.Quit
+	}
+	return m, nil
+}
+
+// View renders the dashboard
+func (m *tuiModel) View() string {
+	snap := m.ctl.Snapshot()
+	var mem runtime.MemStats
+	runtime.ReadMemStats(&mem)
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "🚀 Pushshift Processor — %s\n\n", m.input)
+	fmt.Fprintf(&b, "  Stage:       %s\n", snap.Stage)
+	fmt.Fprintf(&b, "  Elapsed:     %s\n", time.Since(m.start).Round(time.Second))
+	fmt.Fprintf(&b, "  Part:        %d (%.2f MB written)\n", snap.PartNumber, float64(snap.PartBytes)/1024/1024)
+	fmt.Fprintf(&b, "  Lines:       %d\n\n", snap.LinesProcessed)
+
+	fmt.Fprintf(&b, "  Decompress:  %.2f MB/s, %.0f lines/s\n", m.readRate, m.lineRate)
+	avgConvert := time.Duration(0)
+	if snap.PartsConverted > 0 {
+		avgConvert = snap.ConvertTime / time.Duration(snap.PartsConverted)
+	}
+	fmt.Fprintf(&b, "  Convert:     %d parts, last %s, avg %s\n", snap.PartsConverted,
+		snap.LastConvertTime.Round(time.Millisecond), avgConvert.Round(time.Millisecond))
+	fmt.Fprintf(&b, "  Memory:      %.1f MB heap, %.1f MB sys\n",
+		float64(mem.HeapAlloc)/1024/1024, float64(mem.Sys)/1024/1024)
+	if len(snap.Queues) > 0 {
+		queues := make([]string, len(snap.Queues))
+		for i, q := range snap.Queues {
+			queues[i] = fmt.Sprintf("%s %d/%d", q.Name, q.Depth, q.Capacity)
+		}
+		fmt.Fprintf(&b, "  Queues:      %s\n", strings.Join(queues, ", "))
+	}
+	b.WriteString("\n")
+
+	if len(m.parts) > 0 {
+		b.WriteString("  Finished parts:\n")
+		for _, part := range m.parts {
+			if part.Kind == processor.EventPartFailed {
+				fmt.Fprintf(&b, "    %d: %d lines, %.2f MB ❌ failed at %s\n", part.Part, part.Lines,
+					float64(part.Bytes)/1024/1024, part.Time.Format(time.TimeOnly))
+				continue
+			}
+			fmt.Fprintf(&b, "    %d: %d lines, %.2f MB → %s at %s\n", part.Part, part.Lines,
+				float64(part.Bytes)/1024/1024, part.Path, part.Time.Format(time.TimeOnly))
+		}
+		b.WriteString("\n")
+	}
+
+	fmt.Fprintf(&b, "  Recent log (level %s):\n", strings.ToLower(logLevels[m.level].String()))
+	for _, line := range m.logs.recent(tuiMaxLogLines, m.level) {
+		fmt.Fprintf(&b, "    %s\n", line)
+	}
+
+	if m.aborted {
+		b.WriteString("\n  Stopping after the current line…\n")
+	}
+	b.WriteString("\n  [p] pause/resume  [s] skip current part  [l] log level  [q] quit\n")
+	return b.String()
+}
+
+// runWithTUI runs the processor while rendering an interactive dashboard. The processor logs
+// through a levelHandler of logLevel, which the dashboard's log level key sets.
+func runWithTUI(proc *pushshift.Processor, logLevel *slog.LevelVar, inputPath, outputPath string) (processor.ProcessStats, error) {
+	logs := &logBuffer{}
+	log.SetOutput(logs)
+	defer log.SetOutput(os.Stderr)
+
+	// newProcessor gives every processor a control
+	ctl := proc.Options().Control
+	model := &tuiModel{
+		ctl:      ctl,
+		logs:     logs,
+		logLevel: logLevel,
+		input:    inputPath,
+		start:    time.Now(),
+		prevAt:   time.Now(),
+	}
+	program := tea.NewProgram(model)
+
+	events, unsubscribe := ctl.Subscribe(64)
+	defer unsubscribe()
+	go func() {
+		for ev := range events {
+			program.Send(eventMsg(ev))
+		}
+	}()
+
+	done := make(chan doneMsg, 1)
+	go func() {
+		stats, err := proc.ProcessFile(context.Background(), inputPath, outputPath)
+		done <- doneMsg{stats: stats, err: err}
+		program.Send(doneMsg{stats: stats, err: err})
+	}()
+
+	if _, err := program.Run(); err != nil {
+		// Stop the run and wait for it rather than leaving it writing parts behind our back
+		ctl.Cancel()
+		result := <-done
+		return result.stats, fmt.Errorf("terminal UI failed: %v", err)
+	}
+	result := <-done
+
+	// Replay recent warnings and errors so they survive the screen being cleared
+	log.SetOutput(os.Stderr)
+	for _, line := range logs.recent(tuiMaxLogLines, 1) {
+		fmt.Fprintln(os.Stderr, line)
+	}
+	return result.stats, result.err
+}
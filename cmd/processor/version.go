@@ -0,0 +1,35 @@
+package main
+
+import (
+	"runtime/debug"
+	"strings"
+)
+
+// version is set at build time with -ldflags "-X main.version=v1.2.3"
+var version = "dev"
+
+// toolVersion describes the running binary, including the VCS revision when available
+func toolVersion() string {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return version
+	}
+
+	// Module-aware builds already stamp a pseudo-version containing the revision
+	if version == "dev" && info.Main.Version != "" && info.Main.Version != "(devel)" {
+		return info.Main.Version
+	}
+
+	v := version
+	for _, setting := range info.Settings {
+		switch setting.Key {
+		case "vcs.revision":
+			v += "+" + setting.Value
+		case "vcs.modified":
+			if setting.Value == "true" && !strings.HasSuffix(v, "dirty") {
+				v += ".dirty"
+			}
+		}
+	}
+	return v
+}
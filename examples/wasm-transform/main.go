@@ -0,0 +1,58 @@
+//go:build wasip1
+
+// Example record transform plugin. It drops records whose author was deleted and
+// lowercases the subreddit name of everything else.
+//
+// Build it as a WASI reactor module and pass it to the processor:
+//
+//	GOOS=wasip1 GOARCH=wasm go build -buildmode=c-shared -o transform.wasm ./examples/wasm-transform
+//	./pushshift-processor -input=RC_2023-01.zst -wasm-transform=transform.wasm
+package main
+
+import (
+	"encoding/json"
+	"strings"
+	"unsafe"
+)
+
+// buffers keeps memory handed to the host alive until it is released with dealloc
+var buffers = map[uintptr][]byte{}
+
+//go:wasmexport alloc
+func alloc(size int32) int32 {
+	buf := make([]byte, size)
+	ptr := uintptr(unsafe.Pointer(unsafe.SliceData(buf)))
+	buffers[ptr] = buf
+	return int32(ptr)
+}
+
+//go:wasmexport dealloc
+func dealloc(ptr int32, size int32) {
+	delete(buffers, uintptr(ptr))
+}
+
+//go:wasmexport transform
+func transform(ptr int32, size int32) int64 {
+	in := buffers[uintptr(ptr)][:size]
+
+	var record map[string]any
+	if err := json.Unmarshal(in, &record); err != nil {
+		return int64(ptr)<<32 | int64(size) // pass malformed records through unchanged
+	}
+	if record["author"] == "[deleted]" {
+		return -1
+	}
+	if subreddit, ok := record["subreddit"].(string); ok {
+		record["subreddit"] = strings.ToLower(subreddit)
+	}
+
+	out, err := json.Marshal(record)
+	if err != nil {
+		return int64(ptr)<<32 | int64(size)
+	}
+	outPtr := alloc(int32(len(out)))
+	copy(buffers[uintptr(outPtr)], out)
+	return int64(outPtr)<<32 | int64(len(out))
+}
+
+func main() {}
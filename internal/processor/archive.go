@@ -0,0 +1,189 @@
+package processor
+
+import (
+	"archive/tar"
+	"bytes"
+	"fmt"
+	"io"
+	"log"
+	"path"
+	"strings"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// Kinds of archive inputs
+const (
+	archiveTar    = "tar"
+	archiveTarZst = "tar.zst"
+	archiveZip    = "zip"
+	archive7z     = "7z"
+)
+
+// archiveKind tells from its name whether an input is an archive, and which kind
+func archiveKind(name string) string {
+	name = strings.ToLower(name)
+	switch {
+	case strings.HasSuffix(name, ".tar"):
+		return archiveTar
+	case strings.HasSuffix(name, ".tar.zst"), strings.HasSuffix(name, ".tzst"):
+		return archiveTarZst
+	case strings.HasSuffix(name, ".zip"):
+		return archiveZip
+	case strings.HasSuffix(name, ".7z"):
+		return archive7z
+	default:
+		return ""
+	}
+}
+
+// MemberInfo describes one member of an archive input
+type MemberInfo struct {
+	Name string `json:"name"`
+	// Lines counts the lines of the member, before filters
+	Lines int64 `json:"lines"`
+	// Bytes counts the member's decompressed JSON lines
+	Bytes int64 `json:"bytes"`
+}
+
+// memberIterator walks the regular files of an archive in order
+type memberIterator interface {
+	// next returns the name and content of the next file, or io.EOF after the last. The content
+	// is only valid until the following call.
+	next() (string, io.Reader, error)
+	Close() error
+}
+
+// tarIterator walks the files of a tar stream
+type tarIterator struct {
+	tr *tar.Reader
+}
+
+// next implements memberIterator
+func (t *tarIterator) next() (string, io.Reader, error) {
+	for {
+		header, err := t.tr.Next()
+		if err == io.EOF {
+			return "", nil, io.EOF
+		}
+		if err != nil {
+			return "", nil, fmt.Errorf("failed to read tar archive: %w", err)
+		}
+		if header.Typeflag == tar.TypeReg {
+			return header.Name, t.tr, nil
+		}
+	}
+}
+
+// Close implements memberIterator
+func (t *tarIterator) Close() error {
+	return nil
+}
+
+// archiveMembers reads the JSON lines members of an archive one after another as a single
+// stream, as re-packs ship dumps split per day or per subreddit. Members named .zst are
+// decompressed; members that don't look like JSON lines (README files, checksums) are skipped.
+// A member not ending in a newline gets one, so its last record isn't joined with the next
+// member's first.
+type archiveMembers struct {
+	files   memberIterator
+	dec     *zstd.Decoder
+	logger  *log.Logger
+	current io.Reader
+	members []MemberInfo
+	// last is the final byte of the current member, and newline is set when a newline must be
+	// added after it
+	last    byte
+	newline bool
+}
+
+// newArchiveMembers reads the JSON lines members of the files of an archive
+func newArchiveMembers(files memberIterator, logger *log.Logger) *archiveMembers {
+	return &archiveMembers{files: files, logger: logger}
+}
+
+// memberIsJSONLines reports whether a member's name ends in a JSON lines extension, compressed or
+// not. Members without an extension, such as LICENSE or Makefile, are not read.
+func memberIsJSONLines(name string) bool {
+	switch path.Ext(strings.TrimSuffix(strings.ToLower(name), ".zst")) {
+	case ".json", ".jsonl", ".ndjson":
+		return true
+	}
+	return false
+}
+
+// Read implements io.Reader over the members' content
+func (a *archiveMembers) Read(p []byte) (int, error) {
+	for {
+		if a.newline {
+			a.newline = false
+			a.members[len(a.members)-1].Lines++
+			p[0] = '\n'
+			return 1, nil
+		}
+		if a.current == nil {
+			if err := a.nextMember(); err != nil {
+				return 0, err
+			}
+			continue
+		}
+
+		n, err := a.current.Read(p)
+		if n > 0 {
+			member := &a.members[len(a.members)-1]
+			member.Bytes += int64(n)
+			member.Lines += int64(bytes.Count(p[:n], []byte("\n")))
+			a.last = p[n-1]
+		}
+		if err == io.EOF {
+			a.current = nil
+			a.newline = a.members[len(a.members)-1].Bytes > 0 && a.last != '\n'
+			if n > 0 {
+				return n, nil
+			}
+			continue
+		}
+		if err != nil {
+			return n, fmt.Errorf("failed to read archive member %s: %w", a.members[len(a.members)-1].Name, err)
+		}
+		return n, nil
+	}
+}
+
+// nextMember moves to the next JSON lines member, returning io.EOF after the last
+func (a *archiveMembers) nextMember() error {
+	for {
+		name, r, err := a.files.next()
+		if err != nil {
+			return err
+		}
+		if !memberIsJSONLines(name) {
+			a.logger.Printf("📦 Skipping archive member %s, which is not JSON lines", name)
+			continue
+		}
+
+		a.logger.Printf("📦 Reading archive member %s", name)
+		a.members = append(a.members, MemberInfo{Name: name})
+		a.current = r
+		if strings.HasSuffix(strings.ToLower(name), ".zst") {
+			if a.dec == nil {
+				if a.dec, err = zstd.NewReader(nil); err != nil {
+					return fmt.Errorf("failed to create zstd reader: %v", err)
+				}
+			}
+			if err := a.dec.Reset(r); err != nil {
+				return fmt.Errorf("failed to read archive member %s: %w", name, err)
+			}
+			a.current = a.dec
+		}
+		return nil
+	}
+}
+
+// Close releases the members' decompressor and the archive
+func (a *archiveMembers) Close() error {
+	if a.dec != nil {
+		a.dec.Close()
+	}
+	return a.files.Close()
+}
@@ -0,0 +1,29 @@
+package processor
+
+import "testing"
+
+func TestMemberIsJSONLines(t *testing.T) {
+	tests := []struct {
+		name string
+		want bool
+	}{
+		{"RC_2020-01-01.jsonl", true},
+		{"day/RS_2020-01-01.ndjson", true},
+		{"comments.JSON", true},
+		{"RC_2020-01-01.jsonl.zst", true},
+		{"RC_2020-01-01.ndjson.ZST", true},
+		{"README", false},
+		{"LICENSE", false},
+		{"RC_2020-01", false},
+		{"RC_2020-01.zst", false},
+		{"README.md", false},
+		{"sha256sums.txt", false},
+		{"dump.jsonl.gz", false},
+		{"data.json/", false},
+	}
+	for _, tt := range tests {
+		if got := memberIsJSONLines(tt.name); got != tt.want {
+			t.Errorf("%s: got %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,192 @@
+package processor
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync/atomic"
+)
+
+// AwardTableName names the side table of awards
+const AwardTableName = "awards"
+
+// Award columns added in columns mode
+const (
+	AwardCountColumn     = "award_count"
+	AwardCoinTotalColumn = "award_coin_total"
+)
+
+// awarding is an entry of a record's all_awardings array
+type awarding struct {
+	ID            string `json:"id"`
+	Name          string `json:"name"`
+	AwardType     string `json:"award_type"`
+	Count         int64  `json:"count"`
+	CoinPrice     int64  `json:"coin_price"`
+	CoinReward    int64  `json:"coin_reward"`
+	DaysOfPremium *int64 `json:"days_of_premium"`
+}
+
+// awardRow is a row of the awards table: one award type given to a post or comment
+type awardRow struct {
+	// RecordID is the awarded record's fullname (t1_ for comments, t3_ for submissions)
+	RecordID      string `json:"record_id"`
+	AwardID       string `json:"award_id"`
+	Name          string `json:"name"`
+	AwardType     string `json:"award_type"`
+	Count         int64  `json:"count"`
+	CoinPrice     int64  `json:"coin_price"`
+	CoinReward    int64  `json:"coin_reward"`
+	DaysOfPremium *int64 `json:"days_of_premium"`
+}
+
+// recordFullname returns a record's fullname, from name or derived from id
+func recordFullname(rec *Record) string {
+	if name, ok := rec.GetString("name"); ok {
+		if kind, _ := ParseFullname(name); kind != "" {
+			return name
+		}
+	}
+	id, _ := rec.GetString("id")
+	if id == "" {
+		return ""
+	}
+	if rec.Has("link_id") {
+		return "t1_" + id
+	}
+	return "t3_" + id
+}
+
+// AwardTransform replaces the all_awardings array, whose nested award objects break schema
+// inference, with totals and/or a side table.
+//
+// Modes:
+//
+//	columns - award_count (awards received) and award_coin_total (their total coin price);
+//	          0 for an empty all_awardings and null when the record predates the field
+//	table   - one row per award type and record in the awards side table
+//	both    - columns and table
+//
+// all_awardings is removed in every mode but keep.
+type AwardTransform struct {
+	Columns bool
+
+	table   *SideTable
+	awarded atomic.Int64
+
+	runLogger
+}
+
+// NewAwardTransform creates the transform for a mode; keep needs no transform and returns nil.
+// The table is written to <outputPrefix>_awards.parquet.
+func NewAwardTransform(mode, outputPrefix string) (*AwardTransform, error) {
+	t := &AwardTransform{}
+	switch mode {
+	case "keep":
+		return nil, nil
+	case "columns", "both":
+		t.Columns = true
+	case "table":
+	default:
+		return nil, fmt.Errorf("unsupported awards mode %q, expected keep, columns, table or both", mode)
+	}
+	if mode == "table" || mode == "both" {
+		t.table = NewSideTable(outputPrefix, AwardTableName, map[string]string{
+			"days_of_premium": "CAST(days_of_premium AS BIGINT)",
+		})
+	}
+	return t, nil
+}
+
+// Apply summarizes and removes the record's all_awardings
+func (t *AwardTransform) Apply(rec *Record) (bool, error) {
+	raw, ok := rec.Get("all_awardings")
+	var awards []awarding
+	if ok {
+		// A malformed array counts as no awards rather than failing the run
+		_ = json.Unmarshal(raw, &awards)
+		rec.Delete("all_awardings")
+	}
+	if len(awards) > 0 {
+		t.awarded.Add(1)
+		if t.table != nil {
+			if err := t.writeAwards(recordFullname(rec), awards); err != nil {
+				return false, err
+			}
+		}
+	}
+	if !t.Columns {
+		return true, nil
+	}
+	if !ok {
+		if err := rec.SetRaw(AwardCountColumn, json.RawMessage("null")); err != nil {
+			return false, err
+		}
+		return true, rec.SetRaw(AwardCoinTotalColumn, json.RawMessage("null"))
+	}
+	var count, coins int64
+	for _, award := range awards {
+		count += award.Count
+		coins += award.Count * award.CoinPrice
+	}
+	if err := rec.Set(AwardCountColumn, count); err != nil {
+		return false, err
+	}
+	return true, rec.Set(AwardCoinTotalColumn, coins)
+}
+
+// writeAwards writes a table row for each award type a record received
+func (t *AwardTransform) writeAwards(recordID string, awards []awarding) error {
+	for _, award := range awards {
+		row := awardRow{
+			RecordID:      recordID,
+			AwardID:       award.ID,
+			Name:          award.Name,
+			AwardType:     award.AwardType,
+			Count:         award.Count,
+			CoinPrice:     award.CoinPrice,
+			CoinReward:    award.CoinReward,
+			DaysOfPremium: award.DaysOfPremium,
+		}
+		if err := t.table.writeRow(row); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Fields returns the award columns added in columns mode, which fixed schemas such as
+// -extra-json and -canonical-schema have to include
+func (t *AwardTransform) Fields() map[string]string {
+	if !t.Columns {
+		return nil
+	}
+	return map[string]string{AwardCountColumn: "BIGINT", AwardCoinTotalColumn: "BIGINT"}
+}
+
+// ParquetColumns types the award columns, which are null throughout dumps predating awards
+func (t *AwardTransform) ParquetColumns() map[string]string {
+	columns := make(map[string]string)
+	for name, typ := range t.Fields() {
+		columns[name] = fmt.Sprintf("CAST(%s AS %s)", name, typ)
+	}
+	return columns
+}
+
+// SideTables returns the awards table in table mode
+func (t *AwardTransform) SideTables() []*SideTable {
+	if t.table == nil {
+		return nil
+	}
+	return []*SideTable{t.table}
+}
+
+// Close reports how many records had awards and removes the table's intermediate file
+func (t *AwardTransform) Close() error {
+	if n := t.awarded.Load(); n > 0 {
+		loggerOrDefault(t.logger).Printf("🏅 Summarized awards of %d records", n)
+	}
+	if t.table == nil {
+		return nil
+	}
+	return t.table.Close()
+}
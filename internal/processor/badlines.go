@@ -0,0 +1,72 @@
+package processor
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+)
+
+// Policies of Options.OnBadLine for input lines that are not valid JSON
+const (
+	// BadLineSkip drops invalid lines, counting them in ProcessStats.BadLines
+	BadLineSkip = "skip"
+	// BadLineQuarantine drops invalid lines and writes them to Options.BadLinesPath with their
+	// position in the input
+	BadLineQuarantine = "quarantine"
+	// BadLineFail fails the run on the first invalid line with an ErrBadLine
+	BadLineFail = "fail"
+)
+
+// ValidateOnBadLine checks a bad line policy and, for quarantine, that a file was given
+func ValidateOnBadLine(policy, path string) error {
+	switch policy {
+	case "", BadLineSkip, BadLineFail:
+		return nil
+	case BadLineQuarantine:
+		if path == "" {
+			return fmt.Errorf("quarantining bad lines needs a file to write them to")
+		}
+		return nil
+	}
+	return fmt.Errorf("unsupported bad line policy %q, expected skip, quarantine or fail", policy)
+}
+
+// checkLine returns the error of a line that is not valid JSON, or nil. Valid lines are only
+// scanned; the slower decoder runs on invalid ones to tell what is wrong with them.
+func checkLine(rec *Record) *ErrBadLine {
+	line := rec.Bytes()
+	if json.Valid(line) {
+		return nil
+	}
+	var raw json.RawMessage
+	err := json.Unmarshal(line, &raw)
+	if err == nil {
+		err = fmt.Errorf("invalid JSON")
+	}
+	return &ErrBadLine{ErrBadRecord{Line: rec.line, Offset: rec.offset, Record: bytes.Clone(line), Err: err}}
+}
+
+// badLineHandler returns the function handling the invalid lines skipped or quarantined, as
+// configured by Options.OnBadLine, and the function closing the quarantine file. Quarantined lines
+// are written like bad records, see recordQuarantine.
+func (s *PushshiftProcessor) badLineHandler() (func(*ErrBadLine) error, func()) {
+	logger := s.logger()
+	if s.Options.OnBadLine == BadLineQuarantine {
+		quarantine := newRecordQuarantine(s.Options.BadLinesPath, "bad line quarantine", "🚧 Quarantining lines that are not valid JSON to", logger)
+		handle := func(bad *ErrBadLine) error {
+			return quarantine.write(&bad.ErrBadRecord)
+		}
+		return handle, func() { quarantine.close("lines that are not valid JSON") }
+	}
+	var skipped int64
+	handle := func(*ErrBadLine) error {
+		skipped++
+		return nil
+	}
+	closeSkipped := func() {
+		if skipped > 0 {
+			logger.Printf("🚧 Skipped %d lines that are not valid JSON", skipped)
+		}
+	}
+	return handle, closeSkipped
+}
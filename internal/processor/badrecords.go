@@ -0,0 +1,142 @@
+package processor
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+)
+
+// Modes of Options.BadRecords
+const (
+	// BadRecordsLog logs the context of the record a transform fails on before the run fails
+	BadRecordsLog = "log"
+	// BadRecordsQuarantine writes records transforms fail on to a file with their context and
+	// drops them, so the run goes on
+	BadRecordsQuarantine = "quarantine"
+)
+
+// badRecordDumpBytes is how much of a bad record is hexdumped
+const badRecordDumpBytes = 256
+
+// ValidateBadRecords checks a bad record mode and, for quarantine, that a file was given
+func ValidateBadRecords(mode, path string) error {
+	switch mode {
+	case "", BadRecordsLog:
+		return nil
+	case BadRecordsQuarantine:
+		if path == "" {
+			return fmt.Errorf("quarantining bad records needs a file to write them to")
+		}
+		return nil
+	}
+	return fmt.Errorf("unsupported bad record mode %q, expected log or quarantine", mode)
+}
+
+// badRecordReport is a quarantined record with the context to locate it in the input
+type badRecordReport struct {
+	Line    int64  `json:"line"`
+	Offset  int64  `json:"offset"`
+	Part    int    `json:"part,omitempty"`
+	Error   string `json:"error"`
+	Bytes   int    `json:"bytes"`
+	Record  string `json:"record"`
+	Hexdump string `json:"hexdump"`
+	// Withheld is set when the record is left out because the redaction policy couldn't be
+	// applied to it
+	Withheld bool `json:"withheld,omitempty"`
+}
+
+// recordQuarantine writes records dropped from the run to a side file with the context to locate
+// them in the input. It quarantines both the records transforms fail on and, with
+// BadLineQuarantine, the lines that are not valid JSON.
+type recordQuarantine struct {
+	file  *sideFile
+	count int64
+}
+
+// newRecordQuarantine returns a quarantine writing to path through logger, where kind names the
+// file in errors and announce is logged when it is created
+func newRecordQuarantine(path, kind, announce string, logger *log.Logger) *recordQuarantine {
+	file := &sideFile{path: path, kind: kind, announce: announce}
+	file.setLogger(logger)
+	return &recordQuarantine{file: file}
+}
+
+// write quarantines a bad record
+func (q *recordQuarantine) write(bad *ErrBadRecord) error {
+	line, err := json.Marshal(badRecordReport{
+		Line:     bad.Line,
+		Offset:   bad.Offset,
+		Part:     bad.Part,
+		Error:    bad.Err.Error(),
+		Bytes:    len(bad.Record),
+		Record:   string(bad.Record),
+		Hexdump:  bad.hexdump(),
+		Withheld: bad.Withheld,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to encode %s entry: %v", q.file.kind, err)
+	}
+	q.count++
+	return q.file.write(line)
+}
+
+// close closes the file and logs how many records, described by what, were quarantined
+func (q *recordQuarantine) close(what string) {
+	logger := loggerOrDefault(q.file.logger)
+	if err := q.file.Close(); err != nil {
+		warnf(logger, "⚠️ Warning: %v", err)
+	}
+	if q.count > 0 {
+		logger.Printf("🚧 Quarantined %d %s to %s", q.count, what, q.file.path)
+	}
+}
+
+// hexdump returns a hexdump of the start of a bad record
+func (e *ErrBadRecord) hexdump() string {
+	return hex.Dump(e.Record[:min(len(e.Record), badRecordDumpBytes)])
+}
+
+// where describes the position of a bad record in the input
+func (e *ErrBadRecord) where() string {
+	out := fmt.Sprintf("input line %d (byte %d of the decompressed input", e.Line, e.Offset)
+	if e.Part > 0 {
+		out += fmt.Sprintf(", part %d", e.Part)
+	}
+	return out + ")"
+}
+
+// badRecordHandler returns the function reporting records transforms fail on, as configured by
+// Options.BadRecords, and the function closing the quarantine file. Both are nil when bad
+// records aren't reported.
+func (s *PushshiftProcessor) badRecordHandler() (func(*ErrBadRecord) error, func()) {
+	logger := s.logger()
+	dump := func(bad *ErrBadRecord) {
+		if bad.Withheld {
+			logger.Printf("🔬 Bad record at %s, withheld by the redaction policy: %v", bad.where(), bad.Err)
+			return
+		}
+		shown := min(len(bad.Record), badRecordDumpBytes)
+		logger.Printf("🔬 Bad record at %s, %d bytes: %v\n%s", bad.where(), len(bad.Record), bad.Err, bad.hexdump())
+		if shown < len(bad.Record) {
+			logger.Printf("🔬 Showing the first %d bytes, %d more not shown", shown, len(bad.Record)-shown)
+		}
+	}
+
+	switch s.Options.BadRecords {
+	case BadRecordsLog:
+		return func(bad *ErrBadRecord) error {
+			dump(bad)
+			return nil
+		}, func() {}
+	case BadRecordsQuarantine:
+		quarantine := newRecordQuarantine(s.Options.BadRecordsPath, "bad record quarantine", "🚧 Quarantining bad records to", logger)
+		report := func(bad *ErrBadRecord) error {
+			dump(bad)
+			return quarantine.write(bad)
+		}
+		return report, func() { quarantine.close("bad records") }
+	}
+	return nil, nil
+}
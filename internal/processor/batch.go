@@ -0,0 +1,91 @@
+package processor
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// dumpExtensions are the file name endings of inputs a batch picks up, compressed dumps and
+// archives of them
+var dumpExtensions = []string{".zst", ".tar", ".zip", ".7z"}
+
+// IsBatchInput reports whether an input names many dumps rather than one: a glob such as
+// RC_2022-*.zst, or a directory holding dumps. Parquet globs and directories of Parquet files
+// remain datasets.
+func IsBatchInput(path string) bool {
+	if strings.HasSuffix(path, ".parquet") {
+		return false
+	}
+	info, err := os.Stat(path)
+	if err == nil {
+		if !info.IsDir() {
+			return false
+		}
+		if parquet, _ := filepath.Glob(filepath.Join(escapeGlob(path), "*.parquet")); len(parquet) > 0 {
+			return false
+		}
+		inputs, _ := BatchInputs(path)
+		return len(inputs) > 0
+	}
+	return strings.ContainsAny(path, "*?[")
+}
+
+// BatchInputs returns the dumps a batch input names, sorted: the files of a directory or the
+// matches of a glob ending in .zst, .tar, .zip or .7z. A file split into numbered chunks is
+// listed once, by its name without the chunk suffix.
+func BatchInputs(path string) ([]string, error) {
+	pattern := path
+	if info, err := os.Stat(path); err == nil && info.IsDir() {
+		pattern = filepath.Join(escapeGlob(path), "*")
+	}
+	matches, err := filepath.Glob(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("invalid input pattern %q: %v", path, err)
+	}
+	seen := make(map[string]bool)
+	var inputs []string
+	for _, match := range matches {
+		input := match
+		if m := chunkSuffix.FindStringSubmatch(match); m != nil {
+			input = strings.TrimSuffix(match, "."+m[1])
+		}
+		if seen[input] || !isDumpName(input) {
+			continue
+		}
+		if info, err := os.Stat(match); err != nil || info.IsDir() {
+			continue
+		}
+		seen[input] = true
+		inputs = append(inputs, input)
+	}
+	sort.Strings(inputs)
+	return inputs, nil
+}
+
+// isDumpName reports whether a file name ends like a dump a batch picks up
+func isDumpName(name string) bool {
+	for _, ext := range dumpExtensions {
+		if strings.HasSuffix(name, ext) {
+			return true
+		}
+	}
+	return false
+}
+
+// BatchOutputPrefix returns the output prefix of one input of a batch: the input's name without
+// its extensions, in the output directory, e.g. out/RC_2022-01 for RC_2022-01.zst
+func BatchOutputPrefix(outputDir, input string) string {
+	name := filepath.Base(input)
+	for trimmed := true; trimmed; {
+		trimmed = false
+		for _, ext := range dumpExtensions {
+			if stem := strings.TrimSuffix(name, ext); stem != name && stem != "" {
+				name, trimmed = stem, true
+			}
+		}
+	}
+	return filepath.Join(outputDir, name)
+}
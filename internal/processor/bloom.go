@@ -0,0 +1,40 @@
+package processor
+
+import (
+	"hash/maphash"
+	"math/bits"
+)
+
+// bloomHashes is the number of bits set per key, which keeps false positives near 1% while the
+// filter holds up to one key per 10 bits
+const bloomHashes = 7
+
+// bloomFilter is a fixed-size set of string hashes. It never reports a key it was given as
+// absent, but may report an absent key as present.
+type bloomFilter struct {
+	words []uint64
+	seed  maphash.Seed
+}
+
+// newBloomFilter creates an empty filter taking about size bytes
+func newBloomFilter(size int64) *bloomFilter {
+	return &bloomFilter{words: make([]uint64, max(size/8, 1)), seed: maphash.MakeSeed()}
+}
+
+// add adds a key and reports whether it was already present
+func (b *bloomFilter) add(key string) bool {
+	n := uint64(len(b.words)) * 64
+	// Double hashing derives the bit positions from one hash
+	h1 := maphash.String(b.seed, key)
+	h2 := bits.RotateLeft64(h1*0x9e3779b97f4a7c15, 32) | 1
+	present := true
+	for i := range uint64(bloomHashes) {
+		bit := (h1 + i*h2) % n
+		word, mask := bit/64, uint64(1)<<(bit%64)
+		if b.words[word]&mask == 0 {
+			present = false
+			b.words[word] |= mask
+		}
+	}
+	return present
+}
@@ -0,0 +1,42 @@
+package processor
+
+import (
+	"strconv"
+	"testing"
+)
+
+func TestBloomFilter(t *testing.T) {
+	tests := []struct {
+		name    string
+		size    int64
+		keys    int
+		maxRate float64
+	}{
+		{"10 bits per key", 10_000 * 10 / 8, 10_000, 0.02},
+		{"roomy", 1 << 20, 10_000, 0.001},
+		{"tiny", 1, 100, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := newBloomFilter(tt.size)
+			for i := range tt.keys {
+				b.add("key " + strconv.Itoa(i))
+			}
+			for i := range tt.keys {
+				if !b.add("key " + strconv.Itoa(i)) {
+					t.Fatalf("key %d was added but reported absent", i)
+				}
+			}
+			// Probing adds the keys too, so only a few are probed to keep the load steady
+			falsePositives, probes := 0, tt.keys/10
+			for i := range probes {
+				if b.add("other " + strconv.Itoa(i)) {
+					falsePositives++
+				}
+			}
+			if rate := float64(falsePositives) / float64(probes); rate > tt.maxRate {
+				t.Errorf("false positive rate %.4f, want at most %.4f", rate, tt.maxRate)
+			}
+		})
+	}
+}
@@ -0,0 +1,234 @@
+package processor
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+)
+
+// cacheAliasFile maps input files to the checksums their cache entries are keyed by
+const cacheAliasFile = "inputs.json"
+
+// cacheLockFile serializes updates of the cache between processes, such as the runs of a batch
+// or concurrent runs sharing the default cache
+const cacheLockFile = "inputs.lock"
+
+// InputCache stores what earlier runs discovered about each input, so repeated runs over the same
+// dump skip rediscovering it. Entries are keyed by input checksum; an alias from path, size and
+// modification time to the checksum finds them without hashing the file again. Until a run has
+// read an input through to learn its checksum, its entry is keyed by that alias alone.
+type InputCache struct {
+	Dir string
+}
+
+// CacheEntry is everything cached about one input
+type CacheEntry struct {
+	SHA256 string `json:"sha256,omitempty"`
+	// Frames is the zstd frame index, used by -start-at instead of walking the frame headers
+	Frames []frameSpan `json:"frames,omitempty"`
+	// LineCounts are the exact line counts of the input by line endings mode
+	LineCounts map[string]int64 `json:"line_counts,omitempty"`
+	// NullFractions are schema samples from the start of the input, by requested sample size
+	NullFractions map[int]NullSample `json:"null_fractions,omitempty"`
+	// Vintage is the detected dump vintage, see DetectVintage
+	Vintage   string    `json:"vintage,omitempty"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// NullSample is the result of SampleNullFractions
+type NullSample struct {
+	Records   int                  `json:"records"`
+	Fractions []ColumnNullFraction `json:"fractions"`
+}
+
+// DefaultCacheDir returns the cache location in the user's home directory
+func DefaultCacheDir() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "pushshift_cache"
+	}
+	return filepath.Join(home, ".pushshift", "cache")
+}
+
+// inputAlias identifies an input by path, size and modification time
+func inputAlias(inputPath string) (string, error) {
+	abs, err := filepath.Abs(inputPath)
+	if err != nil {
+		return "", err
+	}
+	size, modTime, err := statInput(abs)
+	if err != nil {
+		return "", err
+	}
+	return abs + "|" + strconv.FormatInt(size, 10) + "|" + strconv.FormatInt(modTime.UnixNano(), 10), nil
+}
+
+// aliases reads the alias map, which is empty when the cache is new
+func (c *InputCache) aliases() map[string]string {
+	aliases := make(map[string]string)
+	if data, err := os.ReadFile(filepath.Join(c.Dir, cacheAliasFile)); err == nil {
+		json.Unmarshal(data, &aliases)
+	}
+	return aliases
+}
+
+// entryPath returns the file of the entry keyed by a checksum, or by an alias before the checksum
+// is known
+func (c *InputCache) entryPath(sha, alias string) string {
+	if sha != "" {
+		return filepath.Join(c.Dir, sha+".json")
+	}
+	sum := sha256.Sum256([]byte(alias))
+	return filepath.Join(c.Dir, "alias-"+hex.EncodeToString(sum[:8])+".json")
+}
+
+// readEntry loads an entry file, returning nil when it doesn't exist or can't be parsed
+func readEntry(path string) *CacheEntry {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil
+	}
+	var entry CacheEntry
+	if err := json.Unmarshal(data, &entry); err != nil {
+		return nil
+	}
+	return &entry
+}
+
+// Lookup returns the cached entry for an input, if any
+func (c *InputCache) Lookup(inputPath string) (*CacheEntry, bool) {
+	if c == nil {
+		return nil, false
+	}
+	alias, err := inputAlias(inputPath)
+	if err != nil {
+		return nil, false
+	}
+	entry := readEntry(c.entryPath(c.aliases()[alias], alias))
+	return entry, entry != nil
+}
+
+// Update applies update to the entry of an input and saves it. sha is the input's checksum when
+// the caller knows it, which rekeys an alias-only entry; otherwise the alias map is consulted.
+// Updates hold a lock on the cache directory, so concurrent ones don't lose each other's entries
+// and aliases.
+func (c *InputCache) Update(inputPath, sha string, update func(*CacheEntry)) error {
+	if c == nil {
+		return nil
+	}
+	if err := os.MkdirAll(c.Dir, 0755); err != nil {
+		return fmt.Errorf("failed to create cache directory: %v", err)
+	}
+	unlock, err := lockFile(filepath.Join(c.Dir, cacheLockFile))
+	if err != nil {
+		return fmt.Errorf("failed to lock the cache: %v", err)
+	}
+	defer unlock()
+	alias, err := inputAlias(inputPath)
+	if err != nil {
+		return err
+	}
+	aliases := c.aliases()
+	known := aliases[alias]
+	if sha == "" {
+		sha = known
+	}
+
+	entry := readEntry(c.entryPath(sha, alias))
+	aliasPath := c.entryPath("", alias)
+	if sha != "" {
+		// Merge what was cached before the checksum was learned
+		if pending := readEntry(aliasPath); pending != nil {
+			if entry == nil {
+				entry = pending
+			} else {
+				mergeCacheEntry(entry, pending)
+			}
+		}
+	}
+	if entry == nil {
+		entry = &CacheEntry{}
+	}
+	entry.SHA256 = sha
+	update(entry)
+	entry.UpdatedAt = time.Now().UTC()
+
+	data, err := json.MarshalIndent(entry, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := writeFileAtomic(c.entryPath(sha, alias), data); err != nil {
+		return fmt.Errorf("failed to write cache entry: %v", err)
+	}
+	if sha != "" && known != sha {
+		os.Remove(aliasPath)
+		aliases[alias] = sha
+		data, err := json.MarshalIndent(aliases, "", "  ")
+		if err != nil {
+			return err
+		}
+		if err := writeFileAtomic(filepath.Join(c.Dir, cacheAliasFile), data); err != nil {
+			return fmt.Errorf("failed to write cache aliases: %v", err)
+		}
+	}
+	return nil
+}
+
+// mergeCacheEntry fills the fields of entry that are missing from other
+func mergeCacheEntry(entry, other *CacheEntry) {
+	if entry.Frames == nil {
+		entry.Frames = other.Frames
+	}
+	if entry.Vintage == "" {
+		entry.Vintage = other.Vintage
+	}
+	for mode, n := range other.LineCounts {
+		if _, ok := entry.LineCounts[mode]; !ok {
+			if entry.LineCounts == nil {
+				entry.LineCounts = make(map[string]int64)
+			}
+			entry.LineCounts[mode] = n
+		}
+	}
+	for size, sample := range other.NullFractions {
+		if _, ok := entry.NullFractions[size]; !ok {
+			if entry.NullFractions == nil {
+				entry.NullFractions = make(map[int]NullSample)
+			}
+			entry.NullFractions[size] = sample
+		}
+	}
+}
+
+// lineEndingsMode returns the line endings option with its default filled in
+func lineEndingsMode(mode string) string {
+	if mode == "" {
+		return LineEndingsAny
+	}
+	return mode
+}
+
+// updateCache records what a run that read the whole input learned about it
+func (s *PushshiftProcessor) updateCache(inputPath string, in *zstInput, stats ProcessStats) {
+	// A slice of the input's lines doesn't tell its line count
+	if s.Options.Cache == nil || s.Options.InputReader != nil || in.startOffset > 0 || s.Options.SkipLines > 0 || s.Options.TakeLines > 0 {
+		return
+	}
+	err := s.Options.Cache.Update(inputPath, stats.InputSHA256, func(entry *CacheEntry) {
+		if in.frames != nil {
+			entry.Frames = in.frames.spans
+		}
+		if entry.LineCounts == nil {
+			entry.LineCounts = make(map[string]int64)
+		}
+		entry.LineCounts[lineEndingsMode(s.Options.LineEndings)] = stats.TotalLines
+	})
+	if err != nil {
+		warnf(s.logger(), "⚠️ Warning: failed to update the input cache: %v", err)
+	}
+}
@@ -0,0 +1,41 @@
+package processor
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+func TestInputCacheConcurrentUpdates(t *testing.T) {
+	dir := t.TempDir()
+	cache := &InputCache{Dir: filepath.Join(dir, "cache")}
+	const inputs = 16
+	var wg sync.WaitGroup
+	for i := range inputs {
+		path := filepath.Join(dir, fmt.Sprintf("RC_%02d.zst", i))
+		if err := os.WriteFile(path, []byte{byte(i)}, 0644); err != nil {
+			t.Fatal(err)
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			sha := fmt.Sprintf("%064x", i)
+			if err := cache.Update(path, sha, func(e *CacheEntry) { e.Vintage = path }); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+	if n := len(cache.aliases()); n != inputs {
+		t.Errorf("got %d aliases, want %d", n, inputs)
+	}
+	for i := range inputs {
+		path := filepath.Join(dir, fmt.Sprintf("RC_%02d.zst", i))
+		entry, ok := cache.Lookup(path)
+		if !ok || entry.Vintage != path {
+			t.Errorf("%s: got %+v, want its entry", path, entry)
+		}
+	}
+}
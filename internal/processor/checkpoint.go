@@ -0,0 +1,297 @@
+package processor
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"time"
+)
+
+// Checkpoint records how far a run got, rewritten after each part is converted, so an interrupted
+// run can resume after its last converted part instead of starting over
+type Checkpoint struct {
+	Input         string    `json:"input"`
+	InputBytes    int64     `json:"input_bytes"`
+	InputModified time.Time `json:"input_modified"`
+	// Part is the last part converted; Parts and FailedParts are all the parts recorded so far
+	Part        int        `json:"part"`
+	Parts       []PartInfo `json:"parts"`
+	FailedParts []PartInfo `json:"failed_parts,omitempty"`
+	// Lines is the number of input lines up to and including the last line of Part
+	Lines int64 `json:"lines"`
+	// StartOffset is where the run began reading the compressed input, after seeking for a start
+	// time
+	StartOffset int64 `json:"start_offset,omitempty"`
+	// Offset is where the last line of Part starts in the decompressed input. Frame locates the
+	// zstd frame holding it, counting from 1, with where the frame starts in the compressed and
+	// decompressed input. Frame is 0 when the frame can't be located: its header or an earlier
+	// one declares no content size, or the input is an archive or was entered at a start time.
+	Offset             int64 `json:"offset"`
+	Frame              int   `json:"frame,omitempty"`
+	FrameOffset        int64 `json:"frame_offset,omitempty"`
+	FrameContentOffset int64 `json:"frame_content_offset,omitempty"`
+	// Stats are the run's statistics when Part was written, restored when the run resumes
+	Stats   *CheckpointStats `json:"stats,omitempty"`
+	Updated time.Time        `json:"updated"`
+}
+
+// CheckpointStats are the statistics of the lines a checkpoint covers, so a resumed run's
+// statistics and manifest describe the whole input rather than the lines after the checkpoint
+type CheckpointStats struct {
+	// CountedLines is the last input line counted below. Lines are counted in batches, so it can
+	// lie past the checkpoint's Lines; the resumed run doesn't count the lines up to it again.
+	CountedLines int64               `json:"counted_lines"`
+	DroppedLines int64               `json:"dropped_lines,omitempty"`
+	MatchedLines int64               `json:"matched_lines,omitempty"`
+	SkippedLines int64               `json:"skipped_lines,omitempty"`
+	Filters      []FilterStats       `json:"filters,omitempty"`
+	BadRecords   int64               `json:"bad_records,omitempty"`
+	BadLines     int64               `json:"bad_lines,omitempty"`
+	Quantiles    map[string]*TDigest `json:"quantiles,omitempty"`
+}
+
+// checkpointStats copies the statistics a checkpoint saves, which count the input lines up to
+// counted
+func (ps *ProcessStats) checkpointStats(counted int64) *CheckpointStats {
+	c := &CheckpointStats{
+		CountedLines: counted,
+		DroppedLines: ps.DroppedLines,
+		MatchedLines: ps.MatchedLines,
+		SkippedLines: ps.SkippedLines,
+		Filters:      slices.Clone(ps.Filters),
+		BadRecords:   ps.BadRecords,
+		BadLines:     ps.BadLines,
+	}
+	if ps.Quantiles != nil {
+		c.Quantiles = make(map[string]*TDigest, len(ps.Quantiles))
+		for name, d := range ps.Quantiles {
+			c.Quantiles[name] = NewTDigest()
+			c.Quantiles[name].Merge(d)
+		}
+	}
+	return c
+}
+
+// restore adds the statistics of a checkpoint to those of the run resuming from it
+func (c *CheckpointStats) restore(ps *ProcessStats) {
+	ps.DroppedLines += c.DroppedLines
+	ps.MatchedLines += c.MatchedLines
+	ps.SkippedLines += c.SkippedLines
+	ps.Filters = slices.Clone(c.Filters)
+	ps.BadRecords += c.BadRecords
+	ps.BadLines += c.BadLines
+	if ps.Quantiles != nil {
+		for name, d := range c.Quantiles {
+			quantileDigest(ps.Quantiles, name).Merge(d)
+		}
+	}
+}
+
+// CheckpointPath returns the checkpoint file of an output prefix
+func CheckpointPath(outputPrefix string) string {
+	return outputPrefix + "_checkpoint.json"
+}
+
+// ReadCheckpoint reads the checkpoint of an output prefix, returning nil when there is none
+func ReadCheckpoint(outputPrefix string) (*Checkpoint, error) {
+	data, err := os.ReadFile(CheckpointPath(outputPrefix))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read checkpoint: %v", err)
+	}
+	var cp Checkpoint
+	if err := json.Unmarshal(data, &cp); err != nil {
+		return nil, fmt.Errorf("invalid checkpoint %s: %v", CheckpointPath(outputPrefix), err)
+	}
+	return &cp, nil
+}
+
+// resumePoint is where a resumed run enters the compressed input: the frame at offset, after
+// discarding the given number of decompressed bytes. Unless that lands on the start of a line,
+// the partial line there is skipped, as after seeking for a start time.
+type resumePoint struct {
+	offset    int64
+	discard   int64
+	lineStart bool
+}
+
+// checkpointer rewrites the checkpoint of a run as its parts are recorded
+type checkpointer struct {
+	s    *PushshiftProcessor
+	path string
+	cp   Checkpoint
+	in   *zstInput
+	// locate is set when decompressed offsets can be mapped to frames. frameBase, frameNumber
+	// and contentBase place the first frame the input read in the whole input.
+	locate      bool
+	frameBase   int64
+	frameNumber int
+	contentBase int64
+}
+
+// newCheckpointer returns the checkpointer of a run reading in, or nil when the run can't be
+// resumed: runs appending to or splitting into an output, reading a slice of their input, merging
+// inputs or reading a Parquet dataset
+func (s *PushshiftProcessor) newCheckpointer(inputPath, outputPath string, in *zstInput, resumed *Checkpoint) *checkpointer {
+	o := s.Options
+	if o.Append || o.SplitOnly || o.SkipLines > 0 || o.TakeLines > 0 || len(o.ConcatInputs) > 0 || IsParquetDataset(inputPath) {
+		return nil
+	}
+	size, modified, err := statInput(inputPath)
+	if err != nil {
+		return nil
+	}
+	c := &checkpointer{
+		s:    s,
+		path: CheckpointPath(outputPath),
+		cp:   Checkpoint{Input: inputPath, InputBytes: size, InputModified: modified, StartOffset: in.startOffset},
+		in:   in,
+	}
+	// Offsets after the partial line skipped at a start time don't match the frames' content
+	c.locate = in.frames != nil && in.members == nil && in.startOffset == 0
+	if resumed != nil {
+		c.cp.StartOffset = resumed.StartOffset
+		if resumed.Frame > 1 && in.startOffset == resumed.FrameOffset {
+			c.locate = in.frames != nil
+			c.frameBase, c.frameNumber, c.contentBase = resumed.FrameOffset, resumed.Frame-1, resumed.FrameContentOffset
+		}
+	}
+	return c
+}
+
+// record rewrites the checkpoint after part, the latest part recorded in stats, whose last line
+// starts at offset in the decompressed input. counts are the statistics when the part was
+// written. A checkpoint that can't be written only warns.
+func (c *checkpointer) record(stats *ProcessStats, part PartInfo, offset int64, counts *CheckpointStats) {
+	if c == nil {
+		return
+	}
+	cp := c.cp
+	cp.Part, cp.Lines, cp.Offset, cp.Stats = part.Number, part.LastLine, offset, counts
+	cp.Parts, cp.FailedParts = slices.Clone(stats.Parts), slices.Clone(stats.FailedParts)
+	cp.Updated = time.Now().UTC()
+	if c.locate {
+		if span, index, start, ok := c.in.frames.frameAt(offset - c.contentBase); ok {
+			cp.Frame = c.frameNumber + index + 1
+			cp.FrameOffset = c.frameBase + span.Offset
+			cp.FrameContentOffset = c.contentBase + start
+		}
+	}
+	data, err := json.MarshalIndent(cp, "", "  ")
+	if err == nil {
+		err = writeFileAtomic(c.path, append(data, '\n'))
+	}
+	if err != nil {
+		warnf(c.s.logger(), "⚠️ Warning: Failed to write checkpoint: %v", err)
+	}
+}
+
+// remove deletes the checkpoint of a finished run
+func (c *checkpointer) remove() {
+	if c == nil {
+		return
+	}
+	if err := os.Remove(c.path); err != nil && !os.IsNotExist(err) {
+		warnf(c.s.logger(), "⚠️ Warning: Failed to remove checkpoint: %v", err)
+	}
+}
+
+// loadResume reads the checkpoint a resumed run continues from and checks that the run can
+// continue it
+func (s *PushshiftProcessor) loadResume(inputPath, outputPath string) (*Checkpoint, error) {
+	o := s.Options
+	switch {
+	case o.Append:
+		return nil, fmt.Errorf("resuming is not supported for runs appending to an existing dataset")
+	case o.SplitOnly:
+		return nil, fmt.Errorf("resuming is not supported for JSONL parts")
+	case o.SkipLines > 0 || o.TakeLines > 0:
+		return nil, fmt.Errorf("resuming is not supported for runs reading a slice of the input")
+	case len(o.ConcatInputs) > 0 || IsParquetDataset(inputPath):
+		return nil, fmt.Errorf("resuming is only supported for compressed dumps")
+	}
+	for _, transform := range o.Transforms {
+		switch transform.(type) {
+		case SideTableWriter:
+			return nil, fmt.Errorf("side tables cover the whole input and can't be resumed, run again without resuming")
+		case *DedupTransform, *SubredditReport, *SchemaReport, *CommentDepthTransform:
+			return nil, fmt.Errorf("%s keeps state over the whole input that checkpoints don't save, run again without resuming", transformName(transform))
+		}
+	}
+	cp, err := ReadCheckpoint(outputPath)
+	if err != nil {
+		return nil, err
+	}
+	if cp == nil {
+		return nil, fmt.Errorf("no checkpoint to resume from at %s", CheckpointPath(outputPath))
+	}
+	size, modified, err := statInput(inputPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to stat input file: %v", err)
+	}
+	if size != cp.InputBytes || !modified.Equal(cp.InputModified) {
+		return nil, fmt.Errorf("%s changed since the checkpoint of %s was written, run again without resuming", inputPath, cp.Input)
+	}
+	return cp, nil
+}
+
+// openResumed opens the input of a run resuming from cp and returns the input lines left to
+// process, those after the last line of the checkpoint's last part. The frame holding that line
+// is entered directly when the checkpoint locates it; otherwise the input is decompressed again
+// from where the interrupted run began and the lines it processed are skipped.
+func (s *PushshiftProcessor) openResumed(inputPath string, cp *Checkpoint) (*zstInput, []lineRange, error) {
+	opts := s.inputOptions()
+	opts.startAt = time.Time{}
+	rest := []lineRange{{first: cp.Lines + 1, last: math.MaxInt64, partial: true}}
+	if cp.Frame > 1 {
+		opts.resumeAt = &resumePoint{offset: cp.FrameOffset, discard: cp.Offset - cp.FrameContentOffset, lineStart: true}
+		in, err := openZstInput(inputPath, opts)
+		if err != nil {
+			return nil, nil, err
+		}
+		// The first line read is the last line of the checkpoint's last part, skipped by rest
+		in.lineBase = cp.Lines - 1
+		in.lines.consumed = cp.Offset
+		s.logger().Printf("⏩ Resuming after part %d at zstd frame %d (offset %d, %.1f%% into the input)",
+			cp.Part, cp.Frame, cp.FrameOffset, float64(cp.FrameOffset)*100/float64(max(cp.InputBytes, 1)))
+		return in, rest, nil
+	}
+	if cp.StartOffset > 0 {
+		opts.resumeAt = &resumePoint{offset: cp.StartOffset}
+	}
+	in, err := openZstInput(inputPath, opts)
+	if err != nil {
+		return nil, nil, err
+	}
+	s.logger().Printf("⏩ Resuming after part %d; the input can't be entered there, so the %d lines before it are decompressed again and skipped",
+		cp.Part, cp.Lines)
+	return in, rest, nil
+}
+
+// removePartsAfter removes the Parquet parts of an output prefix numbered after part, which an
+// interrupted run converted, or started to, after writing its last checkpoint
+func (s *PushshiftProcessor) removePartsAfter(outputPrefix string, part int) error {
+	matches, err := filepath.Glob(escapeGlob(outputPrefix) + "_part_*.parquet")
+	if err != nil {
+		return fmt.Errorf("failed to list existing parts: %v", err)
+	}
+	for _, path := range matches {
+		m := partFilePattern.FindStringSubmatch(path)
+		if m == nil || path != outputPrefix+m[0] {
+			continue
+		}
+		if n, _ := strconv.Atoi(m[1]); n > part {
+			s.logger().Printf("🧹 Removing part %d, written after the checkpoint", n)
+			if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+				return fmt.Errorf("failed to remove %s: %v", path, err)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,211 @@
+package processor
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// chunkSuffix matches the numbered suffix of a piece of a split file, e.g. .001
+var chunkSuffix = regexp.MustCompile(`\.(\d{3,})$`)
+
+// SplitChunks returns the pieces of a file split into numbered chunks for sharing on platforms
+// with file size limits (RC_2023-01.zst.001, RC_2023-01.zst.002, ...), in order. The input can
+// be named by its first chunk or by the name without the suffix. It returns nil for an ordinary
+// file, and an error when a chunk other than the first is given or one is missing.
+func SplitChunks(path string) ([]string, error) {
+	base := path
+	width := 3
+	if m := chunkSuffix.FindStringSubmatch(path); m != nil {
+		base, width = strings.TrimSuffix(path, "."+m[1]), len(m[1])
+		if n, _ := strconv.Atoi(m[1]); n != 1 {
+			first := fmt.Sprintf("%s.%0*d", base, width, 1)
+			if _, err := os.Stat(first); err != nil {
+				return nil, nil
+			}
+			return nil, fmt.Errorf("%s is chunk %d of a split file, name its first chunk %s instead", path, n, first)
+		}
+	} else if _, err := os.Stat(path); !errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+
+	var chunks []string
+	for n := 1; ; n++ {
+		chunk := fmt.Sprintf("%s.%0*d", base, width, n)
+		if _, err := os.Stat(chunk); err != nil {
+			break
+		}
+		chunks = append(chunks, chunk)
+	}
+	if len(chunks) == 0 {
+		return nil, nil
+	}
+
+	// A chunk missing in the middle would silently drop data, so look for later ones
+	entries, _ := os.ReadDir(filepath.Dir(base))
+	for _, entry := range entries {
+		other := filepath.Join(filepath.Dir(base), entry.Name())
+		m := chunkSuffix.FindStringSubmatch(other)
+		if m == nil || strings.TrimSuffix(other, "."+m[1]) != filepath.Clean(base) {
+			continue
+		}
+		if n, _ := strconv.Atoi(m[1]); n > len(chunks) {
+			return nil, fmt.Errorf("chunk %d of %s is missing, but %s exists", len(chunks)+1, base, other)
+		}
+	}
+	return chunks, nil
+}
+
+// InputExists reports whether an input names an existing file or a file split into chunks. A
+// split file with a chunk missing counts as existing, so opening it reports which one.
+func InputExists(path string) bool {
+	if _, err := os.Stat(path); err == nil {
+		return true
+	}
+	chunks, err := SplitChunks(path)
+	return err != nil || len(chunks) > 0
+}
+
+// statInput returns the total size and latest modification time of an input, summed over its
+// chunks when it is split
+func statInput(path string) (int64, time.Time, error) {
+	chunks, err := SplitChunks(path)
+	if err != nil {
+		return 0, time.Time{}, err
+	}
+	if chunks == nil {
+		chunks = []string{path}
+	}
+	var size int64
+	var modTime time.Time
+	for _, chunk := range chunks {
+		info, err := os.Stat(chunk)
+		if err != nil {
+			return 0, time.Time{}, err
+		}
+		size += info.Size()
+		if info.ModTime().After(modTime) {
+			modTime = info.ModTime()
+		}
+	}
+	return size, modTime, nil
+}
+
+// inputSHA256 returns the checksum of an input file, of its chunks joined together when it is
+// split, as recorded in manifests
+func inputSHA256(path string) (string, error) {
+	chunks, err := SplitChunks(path)
+	if err != nil {
+		return "", err
+	}
+	if chunks == nil {
+		chunks = []string{path}
+	}
+	hasher := sha256.New()
+	for _, chunk := range chunks {
+		f, err := os.Open(chunk)
+		if err != nil {
+			return "", fmt.Errorf("failed to open %s: %v", chunk, err)
+		}
+		_, err = io.Copy(hasher, f)
+		f.Close()
+		if err != nil {
+			return "", fmt.Errorf("failed to read %s: %v", chunk, err)
+		}
+	}
+	return hex.EncodeToString(hasher.Sum(nil)), nil
+}
+
+// chunkedFile reads the chunks of a split file as one file
+type chunkedFile struct {
+	chunks []io.ReaderAt
+	files  []*os.File
+	// starts holds the offset of each chunk in the joined file
+	starts []int64
+	size   int64
+	pos    int64
+}
+
+// openChunkedFile opens the chunks of a split file, wrapping each in wrap when it is set
+func openChunkedFile(paths []string, wrap func(*os.File) io.ReaderAt) (*chunkedFile, error) {
+	c := &chunkedFile{}
+	for _, path := range paths {
+		f, err := os.Open(path)
+		if err != nil {
+			c.Close()
+			return nil, err
+		}
+		c.files = append(c.files, f)
+		info, err := f.Stat()
+		if err != nil {
+			c.Close()
+			return nil, err
+		}
+		var chunk io.ReaderAt = f
+		if wrap != nil {
+			chunk = wrap(f)
+		}
+		c.chunks = append(c.chunks, chunk)
+		c.starts = append(c.starts, c.size)
+		c.size += info.Size()
+	}
+	return c, nil
+}
+
+// ReadAt implements io.ReaderAt across chunk boundaries
+func (c *chunkedFile) ReadAt(p []byte, offset int64) (int, error) {
+	total := 0
+	for total < len(p) {
+		at := offset + int64(total)
+		if at >= c.size {
+			return total, io.EOF
+		}
+		i := sort.Search(len(c.starts), func(i int) bool { return c.starts[i] > at }) - 1
+		end := c.size
+		if i+1 < len(c.starts) {
+			end = c.starts[i+1]
+		}
+		want := p[total:min(int64(len(p)), int64(total)+end-at)]
+		n, err := c.chunks[i].ReadAt(want, at-c.starts[i])
+		total += n
+		if err != nil && !(err == io.EOF && n == len(want)) {
+			if err == io.EOF {
+				err = io.ErrUnexpectedEOF
+			}
+			return total, err
+		}
+	}
+	return total, nil
+}
+
+// Read implements io.Reader
+func (c *chunkedFile) Read(p []byte) (int, error) {
+	if c.pos >= c.size {
+		return 0, io.EOF
+	}
+	n, err := c.ReadAt(p, c.pos)
+	c.pos += int64(n)
+	if err == io.EOF && n > 0 {
+		err = nil
+	}
+	return n, err
+}
+
+// Close closes every chunk
+func (c *chunkedFile) Close() error {
+	var errs []error
+	for _, f := range c.files {
+		errs = append(errs, f.Close())
+	}
+	return errors.Join(errs...)
+}
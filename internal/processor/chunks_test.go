@@ -0,0 +1,58 @@
+package processor
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSplitChunks(t *testing.T) {
+	tests := []struct {
+		name    string
+		files   []string
+		input   string
+		want    []string
+		wantErr string
+	}{
+		{"ordinary file", []string{"RC.zst"}, "RC.zst", nil, ""},
+		{"ordinary file next to chunks", []string{"RC.zst", "RC.zst.001"}, "RC.zst", nil, ""},
+		{"missing file", nil, "RC.zst", nil, ""},
+		{"by base name", []string{"RC.zst.001", "RC.zst.002", "RC.zst.003"}, "RC.zst", []string{"RC.zst.001", "RC.zst.002", "RC.zst.003"}, ""},
+		{"by first chunk", []string{"RC.zst.001", "RC.zst.002"}, "RC.zst.001", []string{"RC.zst.001", "RC.zst.002"}, ""},
+		{"single chunk", []string{"RC.zst.001"}, "RC.zst", []string{"RC.zst.001"}, ""},
+		{"wide suffixes", []string{"RC.zst.0001", "RC.zst.0002"}, "RC.zst.0001", []string{"RC.zst.0001", "RC.zst.0002"}, ""},
+		{"later chunk", []string{"RC.zst.001", "RC.zst.002"}, "RC.zst.002", nil, "name its first chunk"},
+		{"later chunk of no split file", []string{"RC.zst.002"}, "RC.zst.002", nil, ""},
+		{"missing chunk", []string{"RC.zst.001", "RC.zst.002", "RC.zst.004"}, "RC.zst", nil, "chunk 3 of"},
+		{"chunks of another file", []string{"RC.zst.001", "RS.zst.003"}, "RC.zst", []string{"RC.zst.001"}, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			for _, name := range tt.files {
+				if err := os.WriteFile(filepath.Join(dir, name), nil, 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			got, err := SplitChunks(filepath.Join(dir, tt.input))
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("got error %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			var want []string
+			for _, name := range tt.want {
+				want = append(want, filepath.Join(dir, name))
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("got %v, want %v", got, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,284 @@
+package processor
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/parquet-go/parquet-go"
+)
+
+// DefaultCodecSweep is the codecs and levels CodecSweep compares when none are given
+var DefaultCodecSweep = []CodecSetting{
+	{Codec: "uncompressed"}, {Codec: "snappy"}, {Codec: "lz4"}, {Codec: "gzip"},
+	{Codec: "zstd", Level: 1}, {Codec: "zstd", Level: 3}, {Codec: "zstd", Level: 9}, {Codec: "zstd", Level: 19},
+}
+
+// CodecSetting is a Parquet codec and, for codecs that take one, its level
+type CodecSetting struct {
+	Codec string `json:"codec"`
+	// Level is the codec's level, 0 for the converter's default
+	Level int `json:"level,omitempty"`
+}
+
+// String formats the setting as codec:level, or the codec alone at its default level
+func (c CodecSetting) String() string {
+	if c.Level > 0 {
+		return fmt.Sprintf("%s:%d", c.Codec, c.Level)
+	}
+	return c.Codec
+}
+
+// ParseCodecSettings parses codec settings written as codec or codec:level, e.g. zstd:9
+func ParseCodecSettings(entries []string) ([]CodecSetting, error) {
+	var settings []CodecSetting
+	for _, entry := range entries {
+		codec, levelText, hasLevel := strings.Cut(strings.ToLower(strings.TrimSpace(entry)), ":")
+		setting := CodecSetting{Codec: codec}
+		if hasLevel {
+			level, err := strconv.Atoi(levelText)
+			if err != nil || level < 1 {
+				return nil, fmt.Errorf("invalid level in codec setting %q, expected a positive integer", entry)
+			}
+			setting.Level = level
+		}
+		if err := (ParquetOptions{Compression: setting.Codec, CompressionLevel: setting.Level}).Validate(); err != nil {
+			return nil, err
+		}
+		settings = append(settings, setting)
+	}
+	return settings, nil
+}
+
+// CodecSweepOptions configures CodecSweep
+type CodecSweepOptions struct {
+	// Codecs are the settings compared, DefaultCodecSweep when empty
+	Codecs []CodecSetting
+	// SampleBytes is how much decompressed input the sample covers, 256MB when 0
+	SampleBytes int64
+}
+
+// CodecResult is the outcome of converting the sample with one codec setting
+type CodecResult struct {
+	CodecSetting
+	ParquetBytes int64 `json:"parquet_bytes"`
+	// WriteTime is the conversion of the sample, ReadTime the decoding of every page it wrote
+	WriteTime time.Duration `json:"write_ns"`
+	ReadTime  time.Duration `json:"read_ns"`
+	// ProjectedBytes and ProjectedWriteTime scale the sample's results up to the whole input
+	ProjectedBytes     int64         `json:"projected_bytes"`
+	ProjectedWriteTime time.Duration `json:"projected_write_ns"`
+	// Error is set when the converter rejected the setting
+	Error string `json:"error,omitempty"`
+}
+
+// CodecSweep compares Parquet codecs on a sample of an input
+type CodecSweep struct {
+	InputBytes int64          `json:"input_bytes"`
+	Sample     EstimateSample `json:"sample"`
+	// Complete is set when the sample covered the whole input, so nothing is projected
+	Complete bool `json:"complete"`
+	// Records and JSONLBytes are the records of the sample kept by the filters and their JSONL
+	Records    int64         `json:"records"`
+	JSONLBytes int64         `json:"jsonl_bytes"`
+	Converter  string        `json:"converter"`
+	Results    []CodecResult `json:"results"`
+}
+
+// CodecSweep converts a sample of the input to Parquet with each codec setting and measures the
+// size of the output and the time taken to write and read it back, so an archival run can pick its
+// codec before committing to one. The sample is the first SampleBytes of content, run through the
+// processor's filters and transforms into a single JSONL part with outputPath as the output prefix.
+// The part and the Parquet files written from it are removed afterwards; side tables of the
+// sample run are left for the caller to remove.
+func (s *PushshiftProcessor) CodecSweep(inputPath, outputPath string, opts CodecSweepOptions) (CodecSweep, error) {
+	var sweep CodecSweep
+	if s.Options.CountOnly || s.Options.SplitOnly || s.Options.Sink != nil {
+		return sweep, fmt.Errorf("codec sweeps cover runs converting parts to Parquet")
+	}
+	if IsParquetDataset(inputPath) {
+		return sweep, fmt.Errorf("codec sweeps sample compressed dumps, not Parquet datasets")
+	}
+	codecs := opts.Codecs
+	if len(codecs) == 0 {
+		codecs = DefaultCodecSweep
+	}
+	sampleBytes := opts.SampleBytes
+	if sampleBytes <= 0 {
+		sampleBytes = defaultEstimateSampleBytes
+	}
+	size, _, err := statInput(inputPath)
+	if err != nil {
+		return sweep, fmt.Errorf("failed to stat input file: %v", err)
+	}
+	sweep.InputBytes = size
+
+	samplePath := outputPath + "_sample_start.jsonl"
+	defer func() {
+		if _, err := os.Stat(samplePath); err == nil {
+			removeScratch(s.logger(), samplePath)
+		}
+	}()
+	in, err := openZstInput(inputPath, inputOptions{lineEndings: s.Options.LineEndings, logger: s.logger()})
+	if err != nil {
+		return sweep, err
+	}
+	s.logger().Printf("🧪 Sampling %.0f MB from the start of %s", float64(sampleBytes)/1024/1024, inputPath)
+	sweep.Sample, sweep.Complete, err = takeSample(in, samplePath, sampleBytes, false)
+	sweep.Sample.Name, sweep.Sample.CompressedBytes = "start", in.compressed.bytes.Load()
+	in.Close()
+	if err != nil {
+		return sweep, err
+	}
+
+	// The sample goes through the configured pipeline into one JSONL part, converted once per codec
+	sample := PushshiftProcessor{Options: s.Options}
+	sample.Options.ConcatInputs = []string{samplePath}
+	sample.Options.SplitOnly = true
+	sample.Options.PartCompressionLevel = 0
+	sample.Options.PartSize = math.MaxInt64
+	sample.Options.TargetParquetSize = 0
+	sample.Options.Append = false
+	sample.Options.Cache = nil
+	sample.Options.SkipLines, sample.Options.TakeLines = 0, 0
+	sample.Options.Control = NewControl()
+	sample.Options.OnEvent = nil
+	s.logger().Printf("🧪 Processing the sample into %s", outputPath)
+	stats, err := sample.Process(samplePath, outputPath)
+	if err != nil {
+		return sweep, fmt.Errorf("failed to process the sample: %w", err)
+	}
+	for _, part := range stats.Parts {
+		defer removeScratch(s.logger(), part.Path)
+	}
+	if len(stats.Parts) == 0 {
+		return sweep, fmt.Errorf("no records of the sample were kept, there is nothing to convert")
+	}
+	part := stats.Parts[0]
+	sweep.Records, sweep.JSONLBytes = part.Lines, part.JSONLBytes
+
+	scale := 1.0
+	if !sweep.Complete && sweep.Sample.CompressedBytes > 0 {
+		scale = float64(size) / float64(sweep.Sample.CompressedBytes)
+	}
+	columns := s.parquetColumns()
+	for _, codec := range codecs {
+		conv := PushshiftProcessor{Options: s.Options}
+		conv.Options.Parquet.Compression, conv.Options.Parquet.CompressionLevel = codec.Codec, codec.Level
+		// A failing setting is reported rather than converted by a fallback
+		conv.Options.FallbackConverters = nil
+		converter := conv.primaryConverter()
+		sweep.Converter = converter.name
+		result := CodecResult{CodecSetting: codec}
+		base := fmt.Sprintf("%s_sweep_%s", outputPath, strings.ReplaceAll(codec.String(), ":", "_"))
+		s.logger().Printf("🔄 Converting the sample with %s", codec)
+		start := time.Now()
+		err := converter.convert(part.Path, base, columns)
+		result.WriteTime = time.Since(start)
+		if err == nil {
+			start = time.Now()
+			result.ParquetBytes, err = readParquetPages(base + ".parquet")
+			result.ReadTime = time.Since(start)
+		}
+		if err != nil {
+			warnf(s.logger(), "⚠️ Warning: %s failed: %v", codec, err)
+			result = CodecResult{CodecSetting: codec, Error: err.Error()}
+		} else {
+			result.ProjectedBytes = int64(float64(result.ParquetBytes) * scale)
+			result.ProjectedWriteTime = time.Duration(float64(result.WriteTime) * scale)
+		}
+		if _, err := os.Stat(base + ".parquet"); err == nil {
+			removeScratch(s.logger(), base+".parquet")
+		}
+		sweep.Results = append(sweep.Results, result)
+	}
+	return sweep, nil
+}
+
+// readParquetPages decompresses and decodes every page of a Parquet file, returning its size
+func readParquetPages(path string) (int64, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return 0, fmt.Errorf("failed to open %s: %v", path, err)
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil {
+		return 0, fmt.Errorf("failed to open %s: %v", path, err)
+	}
+	pf, err := parquet.OpenFile(file, info.Size(), parquet.SkipPageIndex(true), parquet.SkipBloomFilters(true))
+	if err != nil {
+		return 0, fmt.Errorf("failed to read %s: %v", path, err)
+	}
+	for _, group := range pf.RowGroups() {
+		for _, chunk := range group.ColumnChunks() {
+			pages := chunk.Pages()
+			for {
+				_, err := pages.ReadPage()
+				if err == io.EOF {
+					break
+				}
+				if err != nil {
+					pages.Close()
+					return 0, fmt.Errorf("failed to read %s: %v", path, err)
+				}
+			}
+			pages.Close()
+		}
+	}
+	return info.Size(), nil
+}
+
+// String formats the sweep as a table for the terminal
+func (sweep CodecSweep) String() string {
+	var out strings.Builder
+	fmt.Fprintf(&out, "🧪 Codec sweep of %s of content: %s records in %s of JSONL, converted by %s\n",
+		estimateSize(sweep.Sample.ContentBytes), formatCount(sweep.Records), estimateSize(sweep.JSONLBytes), sweep.Converter)
+	projected := !sweep.Complete && sweep.Sample.CompressedBytes > 0
+	tw := tabwriter.NewWriter(&out, 0, 0, 2, ' ', tabwriter.AlignRight)
+	header := "  codec\tsize\tratio\twrite\twrite MB/s\tread\t"
+	if projected {
+		header += "whole input\twrite time\t"
+	}
+	fmt.Fprintln(tw, header)
+	var smallest, fastestWrite, fastestRead *CodecResult
+	var failures []string
+	for i := range sweep.Results {
+		r := &sweep.Results[i]
+		if r.Error != "" {
+			// Failed rows keep every cell, so tabwriter aligns the rows after them
+			fmt.Fprintf(tw, "  %s\tfailed%s\t\n", r.CodecSetting, strings.Repeat("\t-", strings.Count(header, "\t")-2))
+			failures = append(failures, fmt.Sprintf("  ❌ %s: %s\n", r.CodecSetting, r.Error))
+			continue
+		}
+		row := fmt.Sprintf("  %s\t%s\t%.2fx\t%v\t%.1f\t%v\t", r.CodecSetting, estimateSize(r.ParquetBytes),
+			float64(sweep.JSONLBytes)/float64(max(r.ParquetBytes, 1)), r.WriteTime.Round(time.Millisecond),
+			float64(sweep.JSONLBytes)/1024/1024/max(r.WriteTime.Seconds(), 1e-9), r.ReadTime.Round(time.Millisecond))
+		if projected {
+			row += fmt.Sprintf("%s\t%v\t", estimateSize(r.ProjectedBytes), r.ProjectedWriteTime.Round(time.Second))
+		}
+		fmt.Fprintln(tw, row)
+		if smallest == nil || r.ParquetBytes < smallest.ParquetBytes {
+			smallest = r
+		}
+		if fastestWrite == nil || r.WriteTime < fastestWrite.WriteTime {
+			fastestWrite = r
+		}
+		if fastestRead == nil || r.ReadTime < fastestRead.ReadTime {
+			fastestRead = r
+		}
+	}
+	tw.Flush()
+	for _, failure := range failures {
+		out.WriteString(failure)
+	}
+	if smallest != nil {
+		fmt.Fprintf(&out, "  🏆 Smallest: %s, fastest to write: %s, fastest to read: %s", smallest.CodecSetting, fastestWrite.CodecSetting, fastestRead.CodecSetting)
+	}
+	return strings.TrimSuffix(out.String(), "\n")
+}
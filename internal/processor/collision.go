@@ -0,0 +1,124 @@
+package processor
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// outputFilePattern matches the names of files a run writes after its output prefix: Parquet
+// parts, the JSONL parts of split runs, side tables, corpus and pairs shards, quarantined, separated and sampled records, the
+// vector store's dead-letter queue, the schema report, the manifest and the checkpoint of an interrupted run. Intermediate JSONL parts are not outputs; an interrupted run's leftovers are swept when the next run starts.
+var outputFilePattern = regexp.MustCompile(`^_(part_\d+\.parquet|(` + strings.Join(sideTableNames, "|") + `)\.parquet|(corpus|pairs)_\d+\.(jsonl|txt)|split_\d+\.jsonl(\.zst)?|(oversized|noncommunity|bad_records|bad_lines|dropped_sample|deadletter)\.jsonl|schema\.json|manifest\.json|checkpoint\.json)$`)
+
+// ExistingOutputs lists the files and partition directories of an earlier run with the same
+// output prefix that a new run would overwrite or mix with its own outputs
+func ExistingOutputs(outputPrefix string) ([]string, error) {
+	matches, err := filepath.Glob(escapeGlob(outputPrefix) + "_*")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list existing outputs: %v", err)
+	}
+	var existing []string
+	for _, path := range matches {
+		if outputFilePattern.MatchString(strings.TrimPrefix(path, outputPrefix)) {
+			existing = append(existing, path)
+		}
+	}
+	// Partitioned runs write directories such as subreddit=pics under the output path
+	partitions, err := filepath.Glob(filepath.Join(escapeGlob(outputPrefix), "*=*"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list existing outputs: %v", err)
+	}
+	for _, path := range partitions {
+		column, _, _ := strings.Cut(filepath.Base(path), "=")
+		if info, err := os.Stat(path); err == nil && info.IsDir() && slices.Contains(PartitionKeys, column) {
+			existing = append(existing, path)
+		}
+	}
+	return existing, nil
+}
+
+// ReplacedOutputs are the outputs of an earlier run moved aside while a new run with the same
+// output prefix replaces them, so a failed run doesn't lose them
+type ReplacedOutputs struct {
+	outputPrefix string
+	dir          string
+	paths        []string
+}
+
+// MoveOutputsAside moves the files and partition directories returned by ExistingOutputs into a
+// hidden directory next to the outputs, out of the way of the new run's outputs
+func MoveOutputsAside(outputPrefix string, paths []string) (*ReplacedOutputs, error) {
+	dir, err := os.MkdirTemp(filepath.Dir(outputPrefix), "."+filepath.Base(outputPrefix)+"_replaced_")
+	if err != nil {
+		return nil, fmt.Errorf("failed to move the earlier outputs aside: %v", err)
+	}
+	replaced := &ReplacedOutputs{outputPrefix: outputPrefix, dir: dir}
+	for _, path := range paths {
+		if err := os.Rename(path, replaced.asidePath(len(replaced.paths))); err != nil {
+			err = fmt.Errorf("failed to move %s aside: %v", path, err)
+			if restoreErr := replaced.moveBack(); restoreErr != nil {
+				err = fmt.Errorf("%v; %v", err, restoreErr)
+			}
+			return nil, err
+		}
+		replaced.paths = append(replaced.paths, path)
+	}
+	return replaced, nil
+}
+
+// asidePath returns where the ith moved output is kept; outputs are numbered as partition
+// directories can share their names
+func (r *ReplacedOutputs) asidePath(i int) string {
+	return filepath.Join(r.dir, strconv.Itoa(i))
+}
+
+// Dir returns the directory the earlier outputs were moved to
+func (r *ReplacedOutputs) Dir() string {
+	return r.dir
+}
+
+// Remove deletes the earlier outputs once the new run succeeded
+func (r *ReplacedOutputs) Remove() error {
+	if err := os.RemoveAll(r.dir); err != nil {
+		return fmt.Errorf("failed to remove the earlier outputs in %s: %v", r.dir, err)
+	}
+	return nil
+}
+
+// Restore puts the earlier outputs back after the new run failed, removing what the new run
+// wrote so the two don't mix
+func (r *ReplacedOutputs) Restore() error {
+	written, err := ExistingOutputs(r.outputPrefix)
+	if err == nil {
+		err = RemoveOutputs(written)
+	}
+	if err != nil {
+		return fmt.Errorf("%v, the earlier outputs are in %s", err, r.dir)
+	}
+	return r.moveBack()
+}
+
+// moveBack returns the outputs moved aside to their paths
+func (r *ReplacedOutputs) moveBack() error {
+	for i, path := range r.paths {
+		if err := os.Rename(r.asidePath(i), path); err != nil {
+			return fmt.Errorf("failed to restore %s, the earlier outputs are in %s: %v", path, r.dir, err)
+		}
+	}
+	return os.Remove(r.dir)
+}
+
+// RemoveOutputs deletes the files and partition directories returned by ExistingOutputs
+func RemoveOutputs(paths []string) error {
+	for _, path := range paths {
+		if err := os.RemoveAll(path); err != nil {
+			return fmt.Errorf("failed to remove %s: %v", path, err)
+		}
+	}
+	return nil
+}
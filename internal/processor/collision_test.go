@@ -0,0 +1,70 @@
+package processor
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestReplacedOutputs(t *testing.T) {
+	tests := []struct {
+		name    string
+		succeed bool
+		want    []string
+	}{
+		{"run succeeded", true, []string{"out_deadletter.jsonl", "out_part_001.parquet", "unrelated.txt"}},
+		{"run failed", false, []string{"out_manifest.json", "out_part_001.parquet", "out_part_002.parquet", "unrelated.txt"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			prefix := filepath.Join(dir, "out")
+			for _, name := range []string{"out_part_001.parquet", "out_part_002.parquet", "out_manifest.json", "unrelated.txt"} {
+				if err := os.WriteFile(filepath.Join(dir, name), []byte("earlier"), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			existing, err := ExistingOutputs(prefix)
+			if err != nil || len(existing) != 3 {
+				t.Fatalf("got %v, %v", existing, err)
+			}
+			replaced, err := MoveOutputsAside(prefix, existing)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if left, _ := ExistingOutputs(prefix); len(left) != 0 {
+				t.Fatalf("outputs left in place: %v", left)
+			}
+			// The new run writes fewer parts, and a dead-letter queue
+			for _, name := range []string{"out_part_001.parquet", "out_deadletter.jsonl"} {
+				if err := os.WriteFile(filepath.Join(dir, name), []byte("new"), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if tt.succeed {
+				err = replaced.Remove()
+			} else {
+				err = replaced.Restore()
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			entries, _ := os.ReadDir(dir)
+			var got []string
+			for _, entry := range entries {
+				got = append(got, entry.Name())
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+			want := "new"
+			if !tt.succeed {
+				want = "earlier"
+			}
+			if data, _ := os.ReadFile(filepath.Join(dir, "out_part_001.parquet")); string(data) != want {
+				t.Errorf("got part %q, want %q", data, want)
+			}
+		})
+	}
+}
@@ -2,7 +2,8 @@ package processor
 
 import (
 	"fmt"
-	"log"
+	"slices"
+	"sort"
 	"time"
 )
 
@@ -13,23 +14,275 @@ type Processor interface {
 
 // ProcessStats holds statistics about the processed data
 type ProcessStats struct {
-	TotalLines    int64
-	ExecutionTime time.Duration
+	TotalLines    int64         `json:"total_lines"`
+	ExecutionTime time.Duration `json:"execution_ns"`
+	// DroppedLines counts lines removed by transforms or filters
+	DroppedLines int64 `json:"dropped_lines"`
+	// MatchedLines and SkippedLines count the lines Options.Filters kept and removed, 0 when the
+	// run has no filters. Skipped lines are part of DroppedLines.
+	MatchedLines int64 `json:"matched_lines,omitempty"`
+	SkippedLines int64 `json:"skipped_lines,omitempty"`
+	// Filters breaks the dropped lines down by the filter or transform that removed them: every
+	// filter of Options.Filters in order, then the transforms. See FilterStats.
+	Filters []FilterStats `json:"filters,omitempty"`
+	// BadRecords counts the records transforms failed on that were quarantined
+	BadRecords int64 `json:"bad_records,omitempty"`
+	// BadLines counts the input lines that were not valid JSON and were skipped or quarantined
+	// by Options.OnBadLine. They are part of DroppedLines.
+	BadLines int64 `json:"bad_lines,omitempty"`
+	// Routes counts the records written to each output of a routed run
+	Routes []RouteStats `json:"routes,omitempty"`
+	// SubredditCounts holds per-subreddit record counts when they were collected
+	SubredditCounts map[string]int64 `json:"subreddit_counts,omitempty"`
+	// InputSHA256 is the checksum of the compressed input file
+	InputSHA256 string `json:"input_sha256,omitempty"`
+	// StartOffset is where reading began in the compressed input after seeking for
+	// Options.StartAt. Part line ranges count lines from there.
+	StartOffset int64 `json:"start_offset,omitempty"`
+	// Parts lists the output files produced by the run
+	Parts []PartInfo `json:"parts"`
+	// FailedParts lists the parts that failed to convert in runs with ContinueOnPartError
+	FailedParts []PartInfo `json:"failed_parts,omitempty"`
+	// Members lists the JSON lines members read from an archive input
+	Members []MemberInfo `json:"members,omitempty"`
+	// PeakScratchBytes is the largest amount of intermediate data on disk at any time during the run
+	PeakScratchBytes int64 `json:"peak_scratch_bytes"`
+	// Stages breaks the execution time down by pipeline stage
+	Stages StageTelemetry `json:"stages"`
+	// SideTables lists the side tables converted after the parts
+	SideTables []SideTableInfo `json:"side_tables,omitempty"`
+	// Quantiles holds t-digest sketches of score, num_comments and body length when collected
+	Quantiles map[string]*TDigest `json:"quantiles,omitempty"`
+	// Runs counts the runs these statistics cover: 1 for a single run, more after Merge
+	Runs int `json:"runs"`
+}
+
+// Merge adds the statistics of another run, for summarizing a batch of runs over separate
+// inputs. Counts, times, parts and distributions add up; the peak scratch usage is the largest
+// of either, as runs of a batch usually share a disk one after another. The checksum only
+// describes a single input and is cleared.
+func (ps *ProcessStats) Merge(other ProcessStats) {
+	ps.TotalLines += other.TotalLines
+	ps.ExecutionTime += other.ExecutionTime
+	ps.DroppedLines += other.DroppedLines
+	ps.MatchedLines += other.MatchedLines
+	ps.SkippedLines += other.SkippedLines
+	ps.mergeFilters(other.Filters)
+	ps.BadRecords += other.BadRecords
+	ps.BadLines += other.BadLines
+	ps.mergeRoutes(other.Routes)
+	if len(other.SubredditCounts) > 0 && ps.SubredditCounts == nil {
+		ps.SubredditCounts = make(map[string]int64, len(other.SubredditCounts))
+	}
+	for name, count := range other.SubredditCounts {
+		ps.SubredditCounts[name] += count
+	}
+	ps.InputSHA256 = ""
+	ps.Parts = append(ps.Parts, other.Parts...)
+	ps.FailedParts = append(ps.FailedParts, other.FailedParts...)
+	ps.Members = append(ps.Members, other.Members...)
+	ps.PeakScratchBytes = max(ps.PeakScratchBytes, other.PeakScratchBytes)
+	ps.Stages.Add(other.Stages)
+	ps.SideTables = append(ps.SideTables, other.SideTables...)
+	for name, d := range other.Quantiles {
+		if ps.Quantiles == nil {
+			ps.Quantiles = make(map[string]*TDigest)
+		}
+		if ps.Quantiles[name] == nil {
+			ps.Quantiles[name] = NewTDigest()
+		}
+		ps.Quantiles[name].Merge(d)
+	}
+	ps.Runs += max(other.Runs, 1)
+}
+
+// FilterStats counts the records a filter or transform saw and the ones it dropped. Records a
+// filter drops never reach the filters and transforms after it.
+type FilterStats struct {
+	Name string `json:"name"`
+	// Transform is set for transforms, which run after every filter
+	Transform bool  `json:"transform,omitempty"`
+	Seen      int64 `json:"seen"`
+	Dropped   int64 `json:"dropped"`
+}
+
+// String returns the counts as a line of the statistics summary
+func (f FilterStats) String() string {
+	out := f.Name + ": dropped " + formatCount(f.Dropped) + " of " + formatCount(f.Seen)
+	if f.Seen > 0 {
+		out += fmt.Sprintf(" (%.1f%%)", float64(f.Dropped)*100/float64(f.Seen))
+	}
+	return out
+}
+
+// mergeFilters adds the filter counts of another run to those of the filter or transform of the
+// same name, keeping the first run's order
+func (ps *ProcessStats) mergeFilters(other []FilterStats) {
+	for _, f := range other {
+		i := slices.IndexFunc(ps.Filters, func(g FilterStats) bool { return g.Name == f.Name && g.Transform == f.Transform })
+		if i < 0 {
+			ps.Filters = append(ps.Filters, f)
+			continue
+		}
+		ps.Filters[i].Seen += f.Seen
+		ps.Filters[i].Dropped += f.Dropped
+	}
+}
+
+// mergeRoutes adds the record counts of another run's outputs to those of the same name
+func (ps *ProcessStats) mergeRoutes(other []RouteStats) {
+	for _, r := range other {
+		i := slices.IndexFunc(ps.Routes, func(q RouteStats) bool { return q.Output == r.Output })
+		if i < 0 {
+			ps.Routes = append(ps.Routes, r)
+			continue
+		}
+		ps.Routes[i].Records += r.Records
+	}
+}
+
+// PartInfo describes one converted output part
+type PartInfo struct {
+	Number       int    `json:"number"`
+	Path         string `json:"path"`
+	Lines        int64  `json:"lines"`
+	JSONLBytes   int64  `json:"jsonl_bytes"`
+	ParquetBytes int64  `json:"parquet_bytes"`
+	// Converter names the converter that produced the Parquet file
+	Converter string `json:"converter,omitempty"`
+	// FailedConverters lists the converters that failed on the part before Converter succeeded
+	FailedConverters []ConverterFailure `json:"failed_converters,omitempty"`
+	// FirstLine and LastLine are the range of input lines, counting from 1, read into the part
+	FirstLine int64 `json:"first_line,omitempty"`
+	LastLine  int64 `json:"last_line,omitempty"`
+	// Error is why a failed part could not be converted
+	Error string `json:"error,omitempty"`
+	// RemovedRecords counts the records later removed from the part to honor deletion requests
+	RemovedRecords int64 `json:"removed_records,omitempty"`
+	// NestedAsJSON lists the columns of objects or arrays the native converter wrote as JSON
+	// strings, where DuckDB writes STRUCT or LIST columns
+	NestedAsJSON []string `json:"nested_as_json,omitempty"`
 }
 
 // String returns a formatted string with process statistics
 func (ps ProcessStats) String() string {
-	return "📊 Statistics:\n" +
+	out := "📊 Statistics:\n" +
 		"  📝 Total lines processed: " + formatCount(ps.TotalLines) + "\n" +
 		"  ⏱️  Execution time: " + ps.ExecutionTime.String()
+
+	if ps.Runs > 1 {
+		out += "\n  🧮 Runs: " + formatCount(int64(ps.Runs))
+	}
+	if ps.MatchedLines > 0 || ps.SkippedLines > 0 {
+		out += "\n  🔎 Lines matching the filters: " + formatCount(ps.MatchedLines) + ", skipped: " + formatCount(ps.SkippedLines)
+		for _, f := range ps.Filters {
+			if !f.Transform {
+				out += "\n    " + f.String()
+			}
+		}
+	}
+	if ps.DroppedLines > 0 {
+		out += "\n  🗑️  Lines dropped: " + formatCount(ps.DroppedLines)
+		for _, f := range ps.Filters {
+			if f.Transform && f.Dropped > 0 {
+				out += "\n    " + f.String()
+			}
+		}
+	}
+	if len(ps.Routes) > 0 {
+		out += "\n  🧭 Records per output:"
+		for _, route := range ps.Routes {
+			out += "\n    " + route.Output + ": " + formatCount(route.Records)
+		}
+	}
+	if ps.BadRecords > 0 {
+		out += "\n  🚧 Bad records quarantined: " + formatCount(ps.BadRecords)
+	}
+	if ps.BadLines > 0 {
+		out += "\n  🚧 Lines that are not valid JSON: " + formatCount(ps.BadLines)
+	}
+	for _, part := range ps.FailedParts {
+		out += fmt.Sprintf("\n  ❗ Part %d failed (input lines %s to %s): %s", part.Number, formatCount(part.FirstLine), formatCount(part.LastLine), part.Error)
+	}
+	if len(ps.SubredditCounts) > 0 {
+		out += "\n  🏷️  Records per subreddit (" + formatCount(int64(len(ps.SubredditCounts))) + " subreddits):"
+		for _, sc := range topCounts(ps.SubredditCounts, maxSubredditsInSummary) {
+			out += "\n    " + sc.name + ": " + formatCount(sc.count)
+		}
+	}
+	if len(ps.Members) > 0 {
+		out += "\n  📦 Archive members read (" + formatCount(int64(len(ps.Members))) + "):"
+		for i, m := range ps.Members {
+			if i == maxMembersInSummary {
+				out += "\n    … " + formatCount(int64(len(ps.Members)-i)) + " more"
+				break
+			}
+			out += "\n    " + m.Name + ": " + formatCount(m.Lines) + " lines"
+		}
+	}
+	for _, table := range ps.SideTables {
+		out += "\n  🗂️  " + table.Name + " table: " + formatCount(table.Rows) + " rows in " + table.Path
+	}
+	if ps.PeakScratchBytes > 0 {
+		out += fmt.Sprintf("\n  💽 Peak scratch disk usage: %.2f MB", float64(ps.PeakScratchBytes)/1024/1024)
+	}
+	if ps.Stages.DecompressedBytes > 0 {
+		out += "\n  🔬 Stages:\n" + ps.Stages.String()
+	}
+	if len(ps.Quantiles) > 0 {
+		out += "\n  📈 Distributions:"
+		for _, name := range []string{QuantileScore, QuantileNumComments, QuantileBodyLength} {
+			if d := ps.Quantiles[name]; d != nil && d.Count() > 0 {
+				out += "\n    " + name + ": " + d.Summary().String()
+			}
+		}
+	}
+	return out
+}
+
+// quantileSummaries returns the readable summaries of the collected distributions
+func (ps ProcessStats) quantileSummaries() map[string]QuantileSummary {
+	if len(ps.Quantiles) == 0 {
+		return nil
+	}
+	summaries := make(map[string]QuantileSummary, len(ps.Quantiles))
+	for name, d := range ps.Quantiles {
+		summaries[name] = d.Summary()
+	}
+	return summaries
+}
+
+// maxSubredditsInSummary limits how many subreddits String lists
+const maxSubredditsInSummary = 25
+
+// maxMembersInSummary limits how many archive members String lists
+const maxMembersInSummary = 20
+
+// namedCount pairs a name with its count for sorted output
+type namedCount struct {
+	name  string
+	count int64
+}
+
+// topCounts returns up to limit entries of counts ordered by descending count, then name
+func topCounts(counts map[string]int64, limit int) []namedCount {
+	sorted := make([]namedCount, 0, len(counts))
+	for name, count := range counts {
+		sorted = append(sorted, namedCount{name, count})
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		if sorted[i].count != sorted[j].count {
+			return sorted[i].count > sorted[j].count
+		}
+		return sorted[i].name < sorted[j].name
+	})
+	if limit > 0 && len(sorted) > limit {
+		sorted = sorted[:limit]
+	}
+	return sorted
 }
 
 // formatCount formats a count with thousands separator
 func formatCount(count int64) string {
 	return fmt.Sprintf("%d", count)
 }
-
-// InitializeLogger sets up the logger with appropriate format
-func InitializeLogger() {
-	log.SetFlags(log.Ldate | log.Ltime | log.Lmicroseconds)
-}
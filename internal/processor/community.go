@@ -0,0 +1,117 @@
+package processor
+
+import (
+	"fmt"
+	"strings"
+	"sync/atomic"
+)
+
+// Content kinds of records
+const (
+	// ContentCommunity is a regular comment or post in a community subreddit
+	ContentCommunity = "community"
+	// ContentPromoted is an ad: a promoted post or one created from the ads interface
+	ContentPromoted = "promoted"
+	// ContentProfile is a post or comment on a user profile (a u_ subreddit)
+	ContentProfile = "profile"
+)
+
+// ContentKindColumn holds the content kind in flag mode
+const ContentKindColumn = "content_kind"
+
+// contentKind classifies a record as community, promoted or profile content
+func contentKind(rec *Record) string {
+	for _, field := range []string{"promoted", "is_created_from_ads_ui"} {
+		if raw, ok := rec.Get(field); ok && string(raw) == "true" {
+			return ContentPromoted
+		}
+	}
+	if by, ok := rec.GetString("promoted_by"); ok && by != "" {
+		return ContentPromoted
+	}
+	if subreddit, ok := rec.GetString("subreddit"); ok && strings.HasPrefix(strings.ToLower(subreddit), "u_") {
+		return ContentProfile
+	}
+	if kind, ok := rec.GetString("subreddit_type"); ok && kind == "user" {
+		return ContentProfile
+	}
+	return ContentCommunity
+}
+
+// NonCommunityTransform routes promoted posts and user profile content, which contaminate
+// community-level statistics.
+//
+// Policies:
+//
+//	flag     - a content_kind column (community, promoted or profile) is added to every record
+//	drop     - non-community records are dropped
+//	separate - non-community records are written unchanged to a side file and dropped
+type NonCommunityTransform struct {
+	Policy string
+
+	side     *sideFile
+	promoted atomic.Int64
+	profile  atomic.Int64
+
+	runLogger
+}
+
+// NewNonCommunityTransform creates the transform for a policy; keep needs no transform and
+// returns nil. separatePath is only used by the separate policy.
+func NewNonCommunityTransform(policy, separatePath string) (*NonCommunityTransform, error) {
+	switch policy {
+	case "keep":
+		return nil, nil
+	case "flag", "drop", "separate":
+	default:
+		return nil, fmt.Errorf("unsupported non-community policy %q, expected keep, flag, drop or separate", policy)
+	}
+	side := &sideFile{path: separatePath, kind: "non-community file", announce: "📣 Writing promoted and profile content to"}
+	return &NonCommunityTransform{Policy: policy, side: side}, nil
+}
+
+// Apply classifies the record and handles non-community content according to the policy
+func (t *NonCommunityTransform) Apply(rec *Record) (bool, error) {
+	kind := contentKind(rec)
+	switch kind {
+	case ContentPromoted:
+		t.promoted.Add(1)
+	case ContentProfile:
+		t.profile.Add(1)
+	}
+
+	switch {
+	case t.Policy == "flag":
+		return true, rec.Set(ContentKindColumn, kind)
+	case kind == ContentCommunity:
+		return true, nil
+	case t.Policy == "separate":
+		if err := t.side.write(rec.Bytes()); err != nil {
+			return false, err
+		}
+	}
+	return false, nil
+}
+
+// Fields returns the content_kind column added in flag mode, which fixed schemas such as
+// -extra-json and -canonical-schema have to include
+func (t *NonCommunityTransform) Fields() map[string]string {
+	if t.Policy != "flag" {
+		return nil
+	}
+	return map[string]string{ContentKindColumn: "VARCHAR"}
+}
+
+// Close reports the non-community counts and closes the side file
+func (t *NonCommunityTransform) Close() error {
+	if n := t.promoted.Load() + t.profile.Load(); n > 0 {
+		action := map[string]string{"flag": "flagged", "drop": "dropped", "separate": "separated"}[t.Policy]
+		loggerOrDefault(t.logger).Printf("📣 Non-community records %s: %d promoted, %d profile", action, t.promoted.Load(), t.profile.Load())
+	}
+	return t.side.Close()
+}
+
+// sideFiles returns the file non-community records are separated to
+func (t *NonCommunityTransform) sideFiles() []*sideFile {
+	return []*sideFile{t.side}
+}
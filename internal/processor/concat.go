@@ -0,0 +1,406 @@
+package processor
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/parquet-go/parquet-go"
+	"github.com/parquet-go/parquet-go/format"
+)
+
+// ConcatSources expands the inputs of a concat run into the sources read one after another:
+// dumps, JSONL files and Parquet datasets as they are, and output prefixes of split runs as their
+// JSONL parts in order
+func ConcatSources(inputs []string) ([]string, error) {
+	var sources []string
+	for _, input := range inputs {
+		if IsParquetDataset(input) {
+			if parts, _ := filepath.Glob(escapeGlob(input) + "_part_*.parquet"); len(parts) > 0 || InputExists(input) || strings.HasSuffix(input, ".parquet") {
+				sources = append(sources, input)
+				continue
+			}
+		} else if InputExists(input) {
+			sources = append(sources, input)
+			continue
+		}
+		var parts []string
+		// Split runs made before parts were named _split_NNN kept them as _part_NNN
+		for _, pattern := range []string{"_split_*.jsonl", "_split_*.jsonl.zst", "_part_*.jsonl", "_part_*.jsonl.zst"} {
+			matches, _ := filepath.Glob(escapeGlob(input) + pattern)
+			parts = append(parts, matches...)
+		}
+		if len(parts) == 0 {
+			return nil, fmt.Errorf("%s is neither an input, a Parquet dataset nor an output prefix with JSONL parts", input)
+		}
+		sort.Strings(parts)
+		sources = append(sources, parts...)
+	}
+	return sources, nil
+}
+
+// openConcatSource opens one source of a concat run. Plain JSONL files are read as they are,
+// everything else as processing runs read their input.
+func openConcatSource(path string, opts inputOptions) (io.ReadCloser, error) {
+	if strings.HasSuffix(path, ".jsonl") {
+		file, err := os.Open(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open input: %v", err)
+		}
+		return file, nil
+	}
+	return openZstInput(path, opts)
+}
+
+// concatReader reads sources one after another, ending each with a newline so the last line of
+// one never runs into the first line of the next
+type concatReader struct {
+	sources []string
+	opts    inputOptions
+	logger  *log.Logger
+	next    int
+	current io.ReadCloser
+	// last is the last byte read from the current source
+	last byte
+}
+
+// Read implements io.Reader
+func (c *concatReader) Read(p []byte) (int, error) {
+	for {
+		if c.current == nil {
+			if c.next == len(c.sources) {
+				return 0, io.EOF
+			}
+			source, err := openConcatSource(c.sources[c.next], c.opts)
+			if err != nil {
+				return 0, err
+			}
+			c.logger.Printf("📖 Reading input %d of %d: %s", c.next+1, len(c.sources), c.sources[c.next])
+			c.current, c.last = source, '\n'
+			c.next++
+		}
+		n, err := c.current.Read(p)
+		if n > 0 {
+			c.last = p[n-1]
+			return n, nil
+		}
+		if err != io.EOF {
+			return 0, err
+		}
+		c.current.Close()
+		c.current = nil
+		if c.last != '\n' && len(p) > 0 {
+			p[0] = '\n'
+			return 1, nil
+		}
+	}
+}
+
+// Close closes the source being read
+func (c *concatReader) Close() error {
+	if c.current == nil {
+		return nil
+	}
+	err := c.current.Close()
+	c.current = nil
+	return err
+}
+
+// openConcatInput reads the sources of a concat run as one input. It has no checksum, as no
+// single file holds it.
+func openConcatInput(sources []string, opts inputOptions) (*zstInput, error) {
+	logger := loggerOrDefault(opts.logger)
+	concat := &concatReader{sources: sources, opts: opts, logger: logger}
+	decoded := &timedReader{r: concat}
+	return &zstInput{
+		file:         concat,
+		compressed:   &timedReader{r: strings.NewReader("")},
+		decompressed: decoded,
+		lines:        &lineSplitter{loneCR: opts.lineEndings != LineEndingsLF, logger: logger},
+		Reader:       bufio.NewReaderSize(decoded, bufferSize),
+	}, nil
+}
+
+// openInput opens the input of a run: Options.InputReader or the sources of Options.ConcatInputs
+// when set, inputPath otherwise
+func (s *PushshiftProcessor) openInput(inputPath string) (*zstInput, error) {
+	if s.Options.InputReader != nil {
+		return openStreamInput(s.Options.InputReader, s.inputOptions())
+	}
+	if len(s.Options.ConcatInputs) > 0 {
+		return openConcatInput(s.Options.ConcatInputs, s.inputOptions())
+	}
+	return openZstInput(inputPath, s.inputOptions())
+}
+
+// ReconcileSchemas returns one DuckDB type for each top-level column of the concat sources, so the
+// parts of the merged dataset share a schema. Parquet datasets are typed from their file footers,
+// other sources by reading them through once. Columns typed differently get a type holding both:
+// DOUBLE for integers and decimals, VARCHAR for other scalars and JSON once nested values are
+// involved. Nested columns of one kind are typed STRUCT or LIST and left to DuckDB's inference;
+// columns that are always null are typed "". Messages go to logger, or the standard logger when
+// nil.
+func ReconcileSchemas(sources []string, logger *log.Logger) (map[string]string, error) {
+	logger = loggerOrDefault(logger)
+	schema := make(map[string]string)
+	origins := make(map[string]string)
+	for _, source := range sources {
+		types, err := sourceColumnTypes(source, logger)
+		if err != nil {
+			return nil, err
+		}
+		names := make([]string, 0, len(types))
+		for name := range types {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			typ := types[name]
+			previous, seen := schema[name]
+			switch {
+			case !seen || previous == "":
+				schema[name], origins[name] = typ, source
+			case typ == "" || typ == previous:
+			default:
+				merged := mergeColumnTypes(previous, typ)
+				if merged != previous {
+					logger.Printf("🧩 Column %s is %s in %s and %s in %s, writing it as %s", name, previous, origins[name], typ, source, merged)
+				}
+				schema[name] = merged
+			}
+		}
+	}
+	return schema, nil
+}
+
+// sourceColumnTypes returns the DuckDB type of each top-level column of one concat source
+func sourceColumnTypes(source string, logger *log.Logger) (map[string]string, error) {
+	if IsParquetDataset(source) && !strings.HasSuffix(source, ".jsonl") {
+		return parquetDatasetTypes(source)
+	}
+	logger.Printf("🧩 Inferring the schema of %s", source)
+	in, err := openConcatSource(source, inputOptions{})
+	if err != nil {
+		return nil, err
+	}
+	defer in.Close()
+	seen := make(fieldTypes)
+	err = scanLines(in, source, func(lineNum int64, line []byte) error {
+		if !seen.observe(line) {
+			return fmt.Errorf("line %d of %s is not a JSON object", lineNum, source)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	types := make(map[string]string, len(seen))
+	for name, kinds := range seen {
+		types[name] = inferredType(kinds)
+	}
+	return types, nil
+}
+
+// parquetDatasetTypes returns the DuckDB types of the columns of the files of a Parquet dataset,
+// read from their footers
+func parquetDatasetTypes(dataset string) (map[string]string, error) {
+	files, err := filepath.Glob(datasetGlob(dataset))
+	if err != nil || len(files) == 0 {
+		return nil, fmt.Errorf("no Parquet files match %s", datasetGlob(dataset))
+	}
+	types := make(map[string]string)
+	for _, path := range files {
+		file, err := os.Open(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open %s: %v", path, err)
+		}
+		info, err := file.Stat()
+		if err != nil {
+			file.Close()
+			return nil, fmt.Errorf("failed to open %s: %v", path, err)
+		}
+		pf, err := parquet.OpenFile(file, info.Size(), parquet.SkipPageIndex(true), parquet.SkipBloomFilters(true))
+		if err != nil {
+			file.Close()
+			return nil, fmt.Errorf("failed to read the Parquet schema of %s: %v", path, err)
+		}
+		for _, field := range pf.Schema().Fields() {
+			typ := parquetColumnType(field)
+			if previous, ok := types[field.Name()]; ok && previous != "" {
+				typ = mergeColumnTypes(previous, typ)
+			}
+			types[field.Name()] = typ
+		}
+		file.Close()
+	}
+	return types, nil
+}
+
+// parquetColumnType returns the DuckDB type of a top-level Parquet column
+func parquetColumnType(field parquet.Field) string {
+	if field.Repeated() {
+		return "LIST"
+	}
+	logical := logicalValue(field.Type())
+	if !field.Leaf() {
+		if _, ok := logical.(*format.ListType); ok {
+			return "LIST"
+		}
+		return "STRUCT"
+	}
+	switch lt := logical.(type) {
+	case *format.JsonType:
+		return "JSON"
+	case *format.TimestampType:
+		return "TIMESTAMP"
+	case *format.DateType:
+		return "DATE"
+	case *format.DecimalType:
+		return "DOUBLE"
+	case *format.IntType:
+		if lt.BitWidth < 64 {
+			return "INTEGER"
+		}
+	}
+	switch field.Type().Kind() {
+	case parquet.Boolean:
+		return "BOOLEAN"
+	case parquet.Int32:
+		return "INTEGER"
+	case parquet.Int64:
+		return "BIGINT"
+	case parquet.Int96:
+		return "TIMESTAMP"
+	case parquet.Float, parquet.Double:
+		return "DOUBLE"
+	}
+	return "VARCHAR"
+}
+
+// mergeColumnTypes returns a type holding the values of two different column types
+func mergeColumnTypes(a, b string) string {
+	numeric := map[string]int{"INTEGER": 1, "BIGINT": 2, "DOUBLE": 3}
+	switch {
+	case a == b:
+		return a
+	case numeric[a] > 0 && numeric[b] > 0:
+		if numeric[a] > numeric[b] {
+			return a
+		}
+		return b
+	case a == "JSON" || b == "JSON" || a == "STRUCT" || b == "STRUCT" || a == "LIST" || b == "LIST":
+		return "JSON"
+	}
+	return "VARCHAR"
+}
+
+// ReconciledSchemaTransform gives every record the columns of a reconciled schema, null where a
+// record lacks them, and types them alike in every part
+type ReconciledSchemaTransform struct {
+	schema map[string]string
+	names  []string
+}
+
+// NewReconciledSchemaTransform creates the transform of a schema returned by ReconcileSchemas
+func NewReconciledSchemaTransform(schema map[string]string) *ReconciledSchemaTransform {
+	t := &ReconciledSchemaTransform{schema: schema}
+	for name := range schema {
+		t.names = append(t.names, name)
+	}
+	sort.Strings(t.names)
+	return t
+}
+
+// Apply adds the columns the record lacks as nulls
+func (t *ReconciledSchemaTransform) Apply(rec *Record) (bool, error) {
+	for _, name := range t.names {
+		if !rec.Has(name) {
+			if err := rec.SetRaw(name, json.RawMessage("null")); err != nil {
+				return false, err
+			}
+		}
+	}
+	return true, nil
+}
+
+// ParquetColumns casts the scalar columns to their reconciled types. JSON columns are encoded
+// with to_json, which unlike a cast also accepts plain strings. Nested columns are left to DuckDB.
+func (t *ReconciledSchemaTransform) ParquetColumns() map[string]string {
+	columns := make(map[string]string)
+	for name, typ := range t.schema {
+		switch typ {
+		case "", "STRUCT", "LIST":
+		case "JSON":
+			columns[name] = "to_json(" + quoteIdentifier(name) + ")"
+		default:
+			columns[name] = fmt.Sprintf("CAST(%s AS %s)", quoteIdentifier(name), typ)
+		}
+	}
+	return columns
+}
+
+// DedupTransform drops records whose id was already seen, keeping the first of each comment or
+// submission. Base36 ids are kept as integers, so a billion ids take about 40 bytes each.
+type DedupTransform struct {
+	// seen holds the parsed ids of comments and submissions, and other the ids that don't parse
+	seen    [2]map[uint64]struct{}
+	other   map[string]struct{}
+	dropped int64
+
+	runLogger
+}
+
+// NewDedupTransform creates an empty dedup transform
+func NewDedupTransform() *DedupTransform {
+	return &DedupTransform{
+		seen:  [2]map[uint64]struct{}{make(map[uint64]struct{}), make(map[uint64]struct{})},
+		other: make(map[string]struct{}),
+	}
+}
+
+// Apply drops the record if a record of the same kind and id came before it. Records without an
+// id are kept.
+func (t *DedupTransform) Apply(rec *Record) (bool, error) {
+	id, ok := rec.GetString("id")
+	if !ok || id == "" {
+		return true, nil
+	}
+	kind := recordKind(rec)
+	if n, err := strconv.ParseUint(strings.ToLower(id), 36, 64); err == nil {
+		seen := t.seen[0]
+		if kind == "t3" {
+			seen = t.seen[1]
+		}
+		if _, dup := seen[n]; dup {
+			t.dropped++
+			return false, nil
+		}
+		seen[n] = struct{}{}
+		return true, nil
+	}
+	key := kind + "_" + id
+	if _, dup := t.other[key]; dup {
+		t.dropped++
+		return false, nil
+	}
+	t.other[key] = struct{}{}
+	return true, nil
+}
+
+// Close reports how many duplicates were dropped
+func (t *DedupTransform) Close() error {
+	loggerOrDefault(t.logger).Printf("♊ Dropped %d duplicate records", t.dropped)
+	return nil
+}
+
+// provenance implements provenanced
+func (t *DedupTransform) provenance() map[string]any {
+	return map[string]any{"dedup": true}
+}
@@ -0,0 +1,268 @@
+package processor
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Control lets callers observe and steer a running Process call.
+// All methods are safe to call from other goroutines while processing is in progress.
+type Control struct {
+	mu     sync.Mutex
+	paused atomic.Bool
+	// resumed is closed by Resume to wake the stages waiting while paused
+	resumed chan struct{}
+
+	skipPart atomic.Bool
+	aborted  atomic.Pointer[error]
+
+	bytesRead      atomic.Int64
+	linesProcessed atomic.Int64
+	partNumber     atomic.Int64
+	partBytes      atomic.Int64
+	partsConverted atomic.Int64
+	convertNanos   atomic.Int64
+	lastConvert    atomic.Int64
+	stage          atomic.Value
+
+	subMu       sync.Mutex
+	subscribers map[chan Event]struct{}
+
+	queues atomic.Pointer[[]*stageQueue]
+}
+
+// NewControl creates a Control ready to be passed in Options
+func NewControl() *Control {
+	c := &Control{}
+	c.stage.Store("starting")
+	return c
+}
+
+// ControlSnapshot is a point-in-time view of processing progress
+type ControlSnapshot struct {
+	Stage           string
+	Paused          bool
+	BytesRead       int64
+	LinesProcessed  int64
+	PartNumber      int64
+	PartBytes       int64
+	PartsConverted  int64
+	ConvertTime     time.Duration
+	LastConvertTime time.Duration
+	SkipPartPending bool
+	// Queues is the fill of each queue between pipeline stages, empty between runs
+	Queues []QueueDepth
+}
+
+// Snapshot returns the current progress counters
+func (c *Control) Snapshot() ControlSnapshot {
+	return ControlSnapshot{
+		Stage:           c.stage.Load().(string),
+		Paused:          c.paused.Load(),
+		BytesRead:       c.bytesRead.Load(),
+		LinesProcessed:  c.linesProcessed.Load(),
+		PartNumber:      c.partNumber.Load(),
+		PartBytes:       c.partBytes.Load(),
+		PartsConverted:  c.partsConverted.Load(),
+		ConvertTime:     time.Duration(c.convertNanos.Load()),
+		LastConvertTime: time.Duration(c.lastConvert.Load()),
+		SkipPartPending: c.skipPart.Load(),
+		Queues:          c.queueDepths(),
+	}
+}
+
+// queueDepths returns the current fill of the pipeline's queues
+func (c *Control) queueDepths() []QueueDepth {
+	queues := c.queues.Load()
+	if queues == nil {
+		return nil
+	}
+	depths := make([]QueueDepth, len(*queues))
+	for i, q := range *queues {
+		depths[i] = q.depth()
+	}
+	return depths
+}
+
+// setQueues records the queues of the running pipeline, or nil once it stops
+func (c *Control) setQueues(queues []*stageQueue) {
+	if c == nil {
+		return
+	}
+	if queues == nil {
+		c.queues.Store(nil)
+		return
+	}
+	c.queues.Store(&queues)
+}
+
+// Pause stops reading input at the next line boundary until Resume is called
+func (c *Control) Pause() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.paused.Load() {
+		c.resumed = make(chan struct{})
+		c.paused.Store(true)
+	}
+}
+
+// Resume continues reading after Pause
+func (c *Control) Resume() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.paused.Load() {
+		c.paused.Store(false)
+		close(c.resumed)
+	}
+}
+
+// TogglePause pauses a running job or resumes a paused one and reports the new state
+func (c *Control) TogglePause() bool {
+	paused := !c.paused.Load()
+	if paused {
+		c.Pause()
+	} else {
+		c.Resume()
+	}
+	return paused
+}
+
+// Paused reports whether the job is currently paused
+func (c *Control) Paused() bool {
+	return c != nil && c.paused.Load()
+}
+
+// SkipPart closes the current part file early and moves on to converting it
+func (c *Control) SkipPart() {
+	c.skipPart.Store(true)
+}
+
+// Cancel stops the run at the next line boundary, resuming it first if paused. The run fails
+// with ErrCancelled and leaves the parts converted so far.
+func (c *Control) Cancel() {
+	c.abort(ErrCancelled)
+	c.Resume()
+}
+
+// waitIfPaused blocks while the job is paused
+func (c *Control) waitIfPaused() {
+	if c == nil || !c.paused.Load() {
+		return
+	}
+	prev := c.stage.Load()
+	c.stage.Store("paused")
+	c.wait(nil)
+	c.stage.Store(prev)
+}
+
+// wait blocks while the job is paused, without changing the reported stage, or until done is
+// closed
+func (c *Control) wait(done <-chan struct{}) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	resumed := c.resumed
+	paused := c.paused.Load()
+	c.mu.Unlock()
+	if !paused {
+		return
+	}
+	select {
+	case <-resumed:
+	case <-done:
+	}
+}
+
+// takeSkipPart reports and clears a pending skip request
+func (c *Control) takeSkipPart() bool {
+	return c != nil && c.skipPart.Swap(false)
+}
+
+// setStage records the pipeline stage currently running
+func (c *Control) setStage(stage string) {
+	if c != nil {
+		c.stage.Store(stage)
+	}
+}
+
+// startPart records that a new part file is being written
+func (c *Control) startPart(partNum int) {
+	if c != nil {
+		c.partNumber.Store(int64(partNum))
+		c.partBytes.Store(0)
+		c.stage.Store("decompressing")
+	}
+}
+
+// addLine records one line read from the input for the current part
+func (c *Control) addLine(bytes int64) {
+	if c != nil {
+		c.linesProcessed.Add(1)
+		c.bytesRead.Add(bytes)
+		c.partBytes.Add(bytes)
+	}
+}
+
+// finishConvert records how long a part conversion took
+func (c *Control) finishConvert(d time.Duration) {
+	if c != nil {
+		c.partsConverted.Add(1)
+		c.convertNanos.Add(int64(d))
+		c.lastConvert.Store(int64(d))
+	}
+}
+
+// Subscribe returns a channel receiving the run's events and a function ending the
+// subscription. Events are dropped for a subscriber whose buffer is full rather than stalling
+// the run, so slow readers should use a buffer of a few hundred events.
+func (c *Control) Subscribe(buffer int) (<-chan Event, func()) {
+	ch := make(chan Event, buffer)
+	c.subMu.Lock()
+	if c.subscribers == nil {
+		c.subscribers = make(map[chan Event]struct{})
+	}
+	c.subscribers[ch] = struct{}{}
+	c.subMu.Unlock()
+	var once sync.Once
+	return ch, func() {
+		once.Do(func() {
+			c.subMu.Lock()
+			delete(c.subscribers, ch)
+			c.subMu.Unlock()
+			close(ch)
+		})
+	}
+}
+
+// publish sends an event to the subscribers that have room for it
+func (c *Control) publish(ev Event) {
+	if c == nil {
+		return
+	}
+	c.subMu.Lock()
+	defer c.subMu.Unlock()
+	for ch := range c.subscribers {
+		select {
+		case ch <- ev:
+		default:
+		}
+	}
+}
+
+// abort asks the run to stop with err at the next line boundary
+func (c *Control) abort(err error) {
+	c.aborted.CompareAndSwap(nil, &err)
+}
+
+// abortErr returns the error of a pending abort, if any
+func (c *Control) abortErr() error {
+	if c == nil {
+		return nil
+	}
+	if err := c.aborted.Load(); err != nil {
+		return *err
+	}
+	return nil
+}
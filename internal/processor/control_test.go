@@ -0,0 +1,39 @@
+package processor
+
+import (
+	"testing"
+	"time"
+)
+
+func TestControlWait(t *testing.T) {
+	tests := []struct {
+		name    string
+		pause   bool
+		release func(c *Control, done chan struct{})
+	}{
+		{"not paused", false, func(*Control, chan struct{}) {}},
+		{"resumed", true, func(c *Control, _ chan struct{}) { c.Resume() }},
+		{"stopped while paused", true, func(_ *Control, done chan struct{}) { close(done) }},
+		{"cancelled", true, func(c *Control, _ chan struct{}) { c.Cancel() }},
+		{"paused twice", true, func(c *Control, _ chan struct{}) { c.Pause(); c.Resume() }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, done := NewControl(), make(chan struct{})
+			if tt.pause {
+				c.Pause()
+			}
+			returned := make(chan struct{})
+			go func() {
+				c.wait(done)
+				close(returned)
+			}()
+			tt.release(c, done)
+			select {
+			case <-returned:
+			case <-time.After(5 * time.Second):
+				t.Fatal("wait did not return")
+			}
+		})
+	}
+}
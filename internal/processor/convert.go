@@ -0,0 +1,49 @@
+package processor
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// ColumnCasts returns column expressions casting each column of a name:TYPE schema to its type,
+// for Options.ParquetColumns. Values that don't convert become null rather than failing the part.
+func ColumnCasts(schema map[string]string) map[string]string {
+	columns := make(map[string]string, len(schema))
+	for name, typ := range schema {
+		columns[name] = fmt.Sprintf("TRY_CAST(%s AS %s)", quoteIdentifier(name), typ)
+	}
+	return columns
+}
+
+// ConvertFile converts a JSONL file, plain or zstd-compressed, to a single Parquet file without
+// splitting or transforming it, for JSONL prepared by other tools. It applies the Parquet options,
+// column expressions and fallback converters as Process does for its parts, and replaces
+// parquetPath only once a converter succeeds. The returned PartInfo describes the file written.
+func (s *PushshiftProcessor) ConvertFile(jsonlPath, parquetPath string) (PartInfo, error) {
+	part := PartInfo{Number: 1, Path: parquetPath}
+	if err := s.Options.Parquet.Validate(); err != nil {
+		return part, err
+	}
+	info, err := os.Stat(jsonlPath)
+	if err != nil {
+		return part, fmt.Errorf("failed to read input: %v", err)
+	}
+	part.JSONLBytes = info.Size()
+	s.Options.Parquet.warnUnsupported(s.logger())
+
+	// Converters write <base>.parquet, which is renamed over the target once complete
+	base := strings.TrimSuffix(parquetPath, ".parquet") + ".converting"
+	if err := s.convertPart(&part, jsonlPath, base, s.parquetColumns()); err != nil {
+		removeScratch(s.logger(), base+".parquet")
+		return part, fmt.Errorf("failed to convert %s: %v", jsonlPath, err)
+	}
+	if err := os.Rename(base+".parquet", parquetPath); err != nil {
+		removeScratch(s.logger(), base+".parquet")
+		return part, fmt.Errorf("failed to move the Parquet file into place: %v", err)
+	}
+	if info, err := os.Stat(parquetPath); err == nil {
+		part.ParquetBytes = info.Size()
+	}
+	return part, nil
+}
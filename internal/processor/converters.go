@@ -0,0 +1,93 @@
+package processor
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// ConverterDuckDB names the built-in converter, which runs DuckDB in process through go-duckdb
+const ConverterDuckDB = "duckdb"
+
+// ConverterFailure records a converter that failed on a part before another one converted it
+type ConverterFailure struct {
+	Converter string `json:"converter"`
+	Error     string `json:"error"`
+}
+
+// partConverter converts a JSONL file to <outputBaseName>.parquet
+type partConverter struct {
+	name    string
+	convert func(jsonlPath, outputBaseName string, columns map[string]string) error
+}
+
+// partConverters returns the converters tried on each part, in order: the primary converter,
+// DuckDB when the primary is the native converter, then the configured fallbacks
+func (s *PushshiftProcessor) partConverters() []partConverter {
+	converters := []partConverter{s.primaryConverter()}
+	if s.Options.Parquet.Converter == ConverterNative {
+		// DuckDB writes the expressions and nested values the native converter can't
+		converters = append(converters, partConverter{name: ConverterDuckDB, convert: s.convertToParquet})
+	}
+	for _, command := range s.Options.FallbackConverters {
+		converters = append(converters, partConverter{name: command, convert: s.commandConverter(command)})
+	}
+	return converters
+}
+
+// primaryConverter returns the converter selected by ParquetOptions.Converter, DuckDB by default
+func (s *PushshiftProcessor) primaryConverter() partConverter {
+	if s.Options.Parquet.Converter == ConverterNative {
+		return partConverter{name: ConverterNative, convert: s.convertNative}
+	}
+	return partConverter{name: ConverterDuckDB, convert: s.convertToParquet}
+}
+
+// commandConverter runs a fallback converter command with bash, passing the JSONL file as $1 and
+// the Parquet file to write as $2
+func (s *PushshiftProcessor) commandConverter(command string) func(string, string, map[string]string) error {
+	return func(jsonlPath, outputBaseName string, _ map[string]string) error {
+		parquetPath := outputBaseName + ".parquet"
+		output, err := exec.Command("bash", "-c", command, "converter", jsonlPath, parquetPath).CombinedOutput()
+		if len(output) > 0 {
+			s.logger().Printf("🔄 Converter output: %s", strings.TrimSpace(string(output)))
+		}
+		if err != nil {
+			return fmt.Errorf("converter %q failed: %v", command, err)
+		}
+		if _, err := os.Stat(parquetPath); err != nil {
+			return fmt.Errorf("converter %q did not create %s", command, parquetPath)
+		}
+		return nil
+	}
+}
+
+// convertPart converts a part with the first converter that succeeds, removing what a failed one
+// left behind before trying the next. It records in part the converter that produced the Parquet
+// file, the failures of those tried before it, and the nested columns written as JSON.
+func (s *PushshiftProcessor) convertPart(part *PartInfo, jsonlPath, outputBaseName string, columns map[string]string) error {
+	converters := s.partConverters()
+	part.Converter, part.FailedConverters, part.NestedAsJSON = "", nil, nil
+	var err error
+	for i, converter := range converters {
+		if i > 0 {
+			removeScratch(s.logger(), outputBaseName+".parquet")
+			warnf(s.logger(), "⚠️ Warning: Converter %s failed on part %d, falling back to %s: %v", converters[i-1].name, part.Number, converter.name, err)
+		}
+		if err = converter.convert(jsonlPath, outputBaseName, columns); err == nil {
+			if i > 0 {
+				s.logger().Printf("🛟 Part %d was converted by the fallback converter %s", part.Number, converter.name)
+			}
+			part.Converter = converter.name
+			if converter.name == ConverterNative {
+				if part.NestedAsJSON, err = nestedAsJSON(outputBaseName + ".parquet"); err != nil {
+					warnf(s.logger(), "⚠️ Warning: Can't tell which columns of part %d hold nested values as JSON: %v", part.Number, err)
+				}
+			}
+			return nil
+		}
+		part.FailedConverters = append(part.FailedConverters, ConverterFailure{Converter: converter.name, Error: err.Error()})
+	}
+	return err
+}
@@ -0,0 +1,33 @@
+package processor
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestPartConverters(t *testing.T) {
+	tests := []struct {
+		name      string
+		converter string
+		fallbacks []string
+		want      []string
+	}{
+		{"duckdb", ConverterDuckDB, nil, []string{ConverterDuckDB}},
+		{"default", "", []string{"conv $1 $2"}, []string{ConverterDuckDB, "conv $1 $2"}},
+		{"native falls back to duckdb", ConverterNative, nil, []string{ConverterNative, ConverterDuckDB}},
+		{"native then duckdb then commands", ConverterNative, []string{"a $1 $2", "b $1 $2"}, []string{ConverterNative, ConverterDuckDB, "a $1 $2", "b $1 $2"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &PushshiftProcessor{Options: Options{FallbackConverters: tt.fallbacks}}
+			s.Options.Parquet.Converter = tt.converter
+			var got []string
+			for _, converter := range s.partConverters() {
+				got = append(got, converter.name)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
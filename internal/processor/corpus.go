@@ -0,0 +1,331 @@
+package processor
+
+import (
+	"bufio"
+	"database/sql"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"unicode/utf8"
+)
+
+// CorpusOptions configures the LLM-training corpus export
+type CorpusOptions struct {
+	// OutputPrefix names the shards: <prefix>_corpus_00001.jsonl
+	OutputPrefix string
+	// Format is "jsonl" for {"text": ...} lines or "txt" for plain text separated by blank lines
+	Format string
+	// Document is "comment" for one document per record or "thread" to concatenate the
+	// comments of each link_id in creation order
+	Document string
+	// TextFields are tried in order to find a record's text
+	TextFields []string
+	// MinChars drops documents shorter than this
+	MinChars int
+	// MaxChars truncates longer documents; 0 disables the limit
+	MaxChars int
+	// Dedup drops documents whose text was already written
+	Dedup bool
+	// DedupBytes is the memory of the Bloom filter remembering written texts, 512 MB when unset.
+	// Up to one document per 10 bits of it, about 1% of unique documents are dropped as
+	// duplicates by mistake, and more beyond that.
+	DedupBytes int64
+	// ShardBytes starts a new shard once the current one reaches this size
+	ShardBytes int64
+}
+
+// removedTexts are placeholder bodies of deleted content, never useful as training text
+var removedTexts = map[string]bool{"[deleted]": true, "[removed]": true, "": true}
+
+// CorpusSink writes record text as sharded plain-text or JSONL files for LLM pretraining pipelines
+type CorpusSink struct {
+	opts   CorpusOptions
+	seen   *bloomFilter
+	shards *shardWriter
+
+	threads    *sql.DB
+	threadsTx  *sql.Tx
+	threadStmt *sql.Stmt
+	threadPath string
+
+	Documents  int64
+	Duplicates int64
+	TooShort   int64
+
+	runLogger
+}
+
+// NewCorpusSink creates a corpus writer, filling in defaults for unset options
+func NewCorpusSink(opts CorpusOptions) (*CorpusSink, error) {
+	if len(opts.TextFields) == 0 {
+		opts.TextFields = []string{"body", "selftext"}
+	}
+	if opts.Format == "" {
+		opts.Format = "jsonl"
+	}
+	if opts.Format != "jsonl" && opts.Format != "txt" {
+		return nil, fmt.Errorf("unsupported corpus format %q, expected jsonl or txt", opts.Format)
+	}
+	if opts.Document == "" {
+		opts.Document = "comment"
+	}
+	if opts.ShardBytes <= 0 {
+		opts.ShardBytes = 256 * 1024 * 1024
+	}
+	if opts.DedupBytes <= 0 {
+		opts.DedupBytes = 512 * 1024 * 1024
+	}
+
+	c := &CorpusSink{
+		opts:   opts,
+		shards: &shardWriter{prefix: opts.OutputPrefix + "_corpus", ext: opts.Format, maxBytes: opts.ShardBytes},
+	}
+	if opts.Dedup {
+		c.seen = newBloomFilter(opts.DedupBytes)
+	}
+
+	switch opts.Document {
+	case "comment":
+	case "thread":
+		if err := c.openThreadStore(); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("unsupported corpus document mode %q, expected comment or thread", opts.Document)
+	}
+	return c, nil
+}
+
+// openThreadStore creates the temporary on-disk table used to group comments by thread
+func (c *CorpusSink) openThreadStore() error {
+	var err error
+	c.threads, c.threadPath, err = openTempSQLite("pushshift-threads-*.db",
+		"CREATE TABLE comments (link_id TEXT NOT NULL, created_utc INTEGER NOT NULL, text TEXT NOT NULL)")
+	if err != nil {
+		return fmt.Errorf("failed to create thread store: %v", err)
+	}
+	if c.threadsTx, err = c.threads.Begin(); err != nil {
+		return err
+	}
+	c.threadStmt, err = c.threadsTx.Prepare("INSERT INTO comments (link_id, created_utc, text) VALUES (?, ?, ?)")
+	return err
+}
+
+// openTempSQLite creates a throwaway SQLite database with the given schema for staging
+// records on disk. The caller closes the database and removes the returned path.
+func openTempSQLite(pattern, schema string) (*sql.DB, string, error) {
+	tmp, err := os.CreateTemp("", pattern)
+	if err != nil {
+		return nil, "", err
+	}
+	tmp.Close()
+
+	db, err := sql.Open("sqlite", tmp.Name())
+	if err == nil {
+		_, err = db.Exec("PRAGMA journal_mode=OFF; PRAGMA synchronous=OFF; " + schema)
+	}
+	if err != nil {
+		if db != nil {
+			db.Close()
+		}
+		os.Remove(tmp.Name())
+		return nil, "", err
+	}
+	return db, tmp.Name(), nil
+}
+
+// text returns the first usable configured text field of a record
+func (c *CorpusSink) text(rec *Record) (string, bool) {
+	for _, field := range c.opts.TextFields {
+		if text, ok := rec.GetString(field); ok && !removedTexts[strings.TrimSpace(text)] {
+			return strings.TrimSpace(text), true
+		}
+	}
+	return "", false
+}
+
+// WriteBatch adds the records' text to the corpus
+func (c *CorpusSink) WriteBatch(recs []*Record) error {
+	for _, rec := range recs {
+		text, ok := c.text(rec)
+		if !ok {
+			continue
+		}
+
+		if c.opts.Document == "thread" {
+			linkID, _ := rec.GetString("link_id")
+			if linkID == "" {
+				linkID, _ = rec.GetString("id")
+			}
+			created, _ := rec.GetInt("created_utc")
+			if _, err := c.threadStmt.Exec(linkID, created, text); err != nil {
+				return fmt.Errorf("failed to stage comment for thread %s: %v", linkID, err)
+			}
+			continue
+		}
+
+		if err := c.writeDocument(text); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeDocument applies length limits and dedup, then appends the document to the current shard
+func (c *CorpusSink) writeDocument(text string) error {
+	if utf8.RuneCountInString(text) < c.opts.MinChars {
+		c.TooShort++
+		return nil
+	}
+	if c.opts.MaxChars > 0 && utf8.RuneCountInString(text) > c.opts.MaxChars {
+		text = string([]rune(text)[:c.opts.MaxChars])
+	}
+
+	if c.seen != nil {
+		if c.seen.add(text) {
+			c.Duplicates++
+			return nil
+		}
+	}
+
+	var entry []byte
+	if c.opts.Format == "jsonl" {
+		encoded, err := marshalJSON(map[string]string{"text": text})
+		if err != nil {
+			return fmt.Errorf("failed to encode document: %v", err)
+		}
+		entry = append(encoded, '\n')
+	} else {
+		entry = []byte(text + "\n\n")
+	}
+
+	if err := c.shards.write(entry); err != nil {
+		return err
+	}
+	c.Documents++
+	return nil
+}
+
+// shardWriter appends entries to numbered files, starting a new one once maxBytes is reached
+type shardWriter struct {
+	prefix   string
+	ext      string
+	maxBytes int64
+	shard    int
+	file     *os.File
+	writer   *bufio.Writer
+	bytes    int64
+	runLogger
+}
+
+// write appends an entry to the current shard, opening the next shard if needed
+func (w *shardWriter) write(entry []byte) error {
+	if w.writer == nil || w.bytes >= w.maxBytes {
+		if err := w.next(); err != nil {
+			return err
+		}
+	}
+	n, err := w.writer.Write(entry)
+	if err != nil {
+		return fmt.Errorf("failed to write shard: %v", err)
+	}
+	w.bytes += int64(n)
+	return nil
+}
+
+// next closes the current shard and opens the next one
+func (w *shardWriter) next() error {
+	if err := w.close(); err != nil {
+		return err
+	}
+	w.shard++
+	path := fmt.Sprintf("%s_%05d.%s", w.prefix, w.shard, w.ext)
+	file, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to create shard: %v", err)
+	}
+	loggerOrDefault(w.logger).Printf("📚 Writing shard %s", path)
+	w.file = file
+	w.writer = bufio.NewWriterSize(file, 4*1024*1024)
+	w.bytes = 0
+	return nil
+}
+
+// close flushes and closes the current shard, if any
+func (w *shardWriter) close() error {
+	if w.writer == nil {
+		return nil
+	}
+	if err := w.writer.Flush(); err != nil {
+		return fmt.Errorf("failed to flush shard: %v", err)
+	}
+	w.writer = nil
+	return w.file.Close()
+}
+
+// setLogger sets the logger of the run, also used by the shards
+func (c *CorpusSink) setLogger(logger *log.Logger) {
+	c.runLogger.setLogger(logger)
+	c.shards.setLogger(logger)
+}
+
+// writeThreads emits one document per thread from the staged comments
+func (c *CorpusSink) writeThreads() error {
+	c.threadStmt.Close()
+	if err := c.threadsTx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit thread store: %v", err)
+	}
+
+	loggerOrDefault(c.logger).Printf("🧵 Assembling thread documents")
+	rows, err := c.threads.Query("SELECT link_id, text FROM comments ORDER BY link_id, created_utc")
+	if err != nil {
+		return fmt.Errorf("failed to read thread store: %v", err)
+	}
+	defer rows.Close()
+
+	var current string
+	var parts []string
+	flush := func() error {
+		if len(parts) == 0 {
+			return nil
+		}
+		err := c.writeDocument(strings.Join(parts, "\n\n"))
+		parts = parts[:0]
+		return err
+	}
+	for rows.Next() {
+		var linkID, text string
+		if err := rows.Scan(&linkID, &text); err != nil {
+			return fmt.Errorf("failed to read thread store: %v", err)
+		}
+		if linkID != current {
+			if err := flush(); err != nil {
+				return err
+			}
+			current = linkID
+		}
+		parts = append(parts, text)
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	return flush()
+}
+
+// Close assembles thread documents if needed and closes the last shard
+func (c *CorpusSink) Close() error {
+	if c.threads != nil {
+		defer os.Remove(c.threadPath)
+		defer c.threads.Close()
+		if err := c.writeThreads(); err != nil {
+			return err
+		}
+	}
+	if err := c.shards.close(); err != nil {
+		return err
+	}
+	loggerOrDefault(c.logger).Printf("📚 Corpus: %d documents in %d shards, %d duplicates and %d too-short documents dropped",
+		c.Documents, c.shards.shard, c.Duplicates, c.TooShort)
+	return nil
+}
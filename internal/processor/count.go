@@ -0,0 +1,150 @@
+package processor
+
+import (
+	"bytes"
+	"io"
+	"time"
+)
+
+// countChunkSize is the read size used when counting newlines without scanning lines
+const countChunkSize = 4 * 1024 * 1024
+
+// countOnly decompresses the input and reports line counts without writing anything. With
+// Options.Filters, it also counts the lines they keep and drop as a processing run would.
+func (s *PushshiftProcessor) countOnly(inputPath string) (ProcessStats, error) {
+	start := time.Now()
+	stats := ProcessStats{Runs: 1}
+
+	s.logger().Printf("🔢 Counting lines in zst file: %s", inputPath)
+
+	if entry, ok := s.Options.Cache.Lookup(inputPath); ok && !s.Options.CountBySubreddit && len(s.Options.Filters) == 0 && s.Options.StartAt.IsZero() {
+		if n, ok := entry.LineCounts[lineEndingsMode(s.Options.LineEndings)]; ok {
+			stats.TotalLines = n
+			stats.ExecutionTime = time.Since(start)
+			stats.InputSHA256 = entry.SHA256
+			s.logger().Printf("🗃️ Using the cached line count of this input")
+			s.logger().Printf("%s", stats.String())
+			return stats, nil
+		}
+	}
+
+	in, err := s.openInput(inputPath)
+	if err != nil {
+		return stats, err
+	}
+	defer in.Close()
+
+	if s.Options.CountBySubreddit || len(s.Options.Filters) > 0 {
+		if err := s.countLines(in, &stats); err != nil {
+			return stats, err
+		}
+	} else {
+		// Counting newlines in raw chunks avoids per-line overhead entirely. Lone CRs end lines too
+		// unless only LF is accepted: every CR counts, minus those directly followed by LF.
+		buf := make([]byte, countChunkSize)
+		var lastByte byte = '\n'
+		for {
+			n, err := in.Read(buf)
+			if n > 0 {
+				chunk := buf[:n]
+				stats.TotalLines += int64(bytes.Count(chunk, []byte("\n")))
+				if in.lines.loneCR {
+					stats.TotalLines += int64(bytes.Count(chunk, []byte("\r")) - bytes.Count(chunk, []byte("\r\n")))
+					if lastByte == '\r' && chunk[0] == '\n' {
+						stats.TotalLines--
+					}
+				}
+				lastByte = chunk[n-1]
+			}
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				return stats, inputError(err)
+			}
+		}
+		// Account for a final line without a trailing newline
+		if lastByte != '\n' && !(lastByte == '\r' && in.lines.loneCR) {
+			stats.TotalLines++
+		}
+	}
+
+	stats.ExecutionTime = time.Since(start)
+	stats.InputSHA256 = in.SHA256()
+	stats.Members = in.Members()
+	s.updateCache(inputPath, in, stats)
+	in.frames.logChecksums()
+	s.logger().Printf("✅ Counting complete")
+	s.logger().Printf("%s", stats.String())
+	return stats, nil
+}
+
+// countLines counts the input line by line, running the filters on each line and counting the
+// records they keep per subreddit with Options.CountBySubreddit. A filter failing on a record
+// fails the count, as it would fail a processing run.
+func (s *PushshiftProcessor) countLines(in *zstInput, stats *ProcessStats) error {
+	filters := s.Options.Filters
+	seen, dropped := make([]int64, len(filters)), make([]int64, len(filters))
+	if len(filters) > 0 {
+		s.setLoggers()
+	}
+	redaction, redactionAt := s.redaction()
+	if s.Options.CountBySubreddit {
+		stats.SubredditCounts = make(map[string]int64)
+	}
+	// Case variants of a subreddit are counted under the first spelling seen
+	spellings := make(map[string]string)
+	rec := &Record{}
+	scanner := in.scanner(scannerBufferSize)
+	for scanner.Scan() {
+		stats.TotalLines++
+		if stats.TotalLines%progressEventLines == 0 {
+			s.emit(Event{Kind: EventProgress, Lines: stats.TotalLines})
+		}
+		if stats.TotalLines%10000000 == 0 {
+			s.logger().Printf("🔄 Progress: Counted %d lines", stats.TotalLines)
+		}
+
+		if len(filters) > 0 {
+			rec.Reset(scanner.Bytes())
+			rec.line = in.lineBase + stats.TotalLines
+			drop, err := applyCounted(filters, rec, seen, dropped)
+			if err != nil {
+				bad := &ErrBadRecord{Line: rec.line, Record: bytes.Clone(scanner.Bytes()), Err: err}
+				if redaction != nil {
+					var ok bool
+					if bad.Record, ok = redaction.sideOutput(redactionAt, drop, bad.Record); !ok {
+						bad.Record, bad.Withheld = nil, true
+					}
+				}
+				return bad
+			}
+			if drop >= 0 {
+				stats.SkippedLines++
+				continue
+			}
+			stats.MatchedLines++
+		}
+		if stats.SubredditCounts == nil {
+			continue
+		}
+		subreddit, ok := extractStringField(scanner.Bytes(), "subreddit")
+		if !ok {
+			subreddit = "(unknown)"
+		}
+		key := normalizeSubreddit(subreddit)
+		if spelling, seen := spellings[key]; seen {
+			subreddit = spelling
+		} else {
+			spellings[key] = subreddit
+		}
+		stats.SubredditCounts[subreddit]++
+	}
+	if err := scanner.Err(); err != nil {
+		return inputError(err)
+	}
+	for i, f := range filters {
+		stats.Filters = append(stats.Filters, FilterStats{Name: transformName(f), Seen: seen[i], Dropped: dropped[i]})
+	}
+	return nil
+}
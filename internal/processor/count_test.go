@@ -0,0 +1,41 @@
+package processor
+
+import (
+	"maps"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestCountOnlyFilters(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "RC_2020-01.zst")
+	writeTestZst(t, path,
+		`{"subreddit":"AskHistorians","score":12}`,
+		`{"subreddit":"askhistorians","score":3}`,
+		`{"subreddit":"pics","score":40}`,
+		`{"subreddit":"AskHistorians","score":50}`,
+	)
+	subreddits, err := NewSubredditFilter([]string{"AskHistorians"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &PushshiftProcessor{Options: Options{
+		CountOnly:        true,
+		CountBySubreddit: true,
+		Filters:          []Transform{subreddits, &ScoreFilter{Min: 10}},
+	}}
+	stats, err := s.countOnly(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.TotalLines != 4 || stats.MatchedLines != 2 || stats.SkippedLines != 2 {
+		t.Errorf("got %d lines, %d matched, %d skipped", stats.TotalLines, stats.MatchedLines, stats.SkippedLines)
+	}
+	want := []FilterStats{{Name: transformName(subreddits), Seen: 4, Dropped: 1}, {Name: transformName(&ScoreFilter{}), Seen: 3, Dropped: 1}}
+	if !slices.Equal(stats.Filters, want) {
+		t.Errorf("got filters %v, want %v", stats.Filters, want)
+	}
+	if want := map[string]int64{"AskHistorians": 2}; !maps.Equal(stats.SubredditCounts, want) {
+		t.Errorf("got subreddit counts %v, want %v", stats.SubredditCounts, want)
+	}
+}
@@ -0,0 +1,129 @@
+package processor
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+
+	// Bundled so -derive-tz works on systems without a zoneinfo database
+	_ "time/tzdata"
+)
+
+// CreatedTimeTransform normalizes created_utc, which older dumps store as a string or float, to
+// an int64 epoch and optionally adds other representations of the same instant:
+//
+//	iso       - created_iso, an ISO-8601 UTC string such as 2023-01-31T23:59:59Z
+//	timestamp - created_at, a Parquet TIMESTAMP column (an ISO-8601 string in JSON outputs)
+//
+// Records whose created_utc is missing or unparsable get null columns so every part has the
+// same schema.
+type CreatedTimeTransform struct {
+	ISO       bool
+	Timestamp bool
+}
+
+// NewCreatedTimeTransform parses a comma-separated list of representations: epoch, iso, timestamp
+func NewCreatedTimeTransform(formats []string) (*CreatedTimeTransform, error) {
+	t := &CreatedTimeTransform{}
+	for _, format := range formats {
+		switch format {
+		case "epoch":
+			// created_utc itself, always normalized
+		case "iso":
+			t.ISO = true
+		case "timestamp":
+			t.Timestamp = true
+		default:
+			return nil, fmt.Errorf("unsupported created_utc format %q, expected epoch, iso or timestamp", format)
+		}
+	}
+	return t, nil
+}
+
+// Apply normalizes created_utc and adds the requested representations
+func (t *CreatedTimeTransform) Apply(rec *Record) (bool, error) {
+	created, ok := rec.GetInt("created_utc")
+	if ok {
+		if raw, _ := rec.Get("created_utc"); string(raw) != strconv.FormatInt(created, 10) {
+			rec.SetRaw("created_utc", json.RawMessage(strconv.FormatInt(created, 10)))
+		}
+	} else if t.ISO || t.Timestamp {
+		// created_iso and created_at are derived from created_utc during Parquet conversion
+		rec.SetRaw("created_utc", json.RawMessage("null"))
+	}
+
+	for _, column := range []struct {
+		name    string
+		enabled bool
+	}{{"created_iso", t.ISO}, {"created_at", t.Timestamp}} {
+		if !column.enabled {
+			continue
+		}
+		if !ok {
+			rec.SetRaw(column.name, json.RawMessage("null"))
+			continue
+		}
+		if err := rec.Set(column.name, time.Unix(created, 0).UTC().Format(time.RFC3339)); err != nil {
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+// ParquetColumns returns the SQL expressions that give the added columns their Parquet types.
+// Both are computed from the integer epoch rather than relying on DuckDB's string detection.
+func (t *CreatedTimeTransform) ParquetColumns() map[string]string {
+	columns := make(map[string]string)
+	if t.ISO {
+		columns["created_iso"] = "strftime(epoch_ms(created_utc * 1000), '%Y-%m-%dT%H:%M:%SZ')"
+	}
+	if t.Timestamp {
+		columns["created_at"] = "epoch_ms(created_utc * 1000)"
+	}
+	return columns
+}
+
+// LocalTimeTransform derives hour-of-day and weekday columns from created_utc, both in UTC and in
+// a chosen timezone, for activity-rhythm analysis:
+//
+//	created_hour_utc, created_weekday_utc     - 0-23 and 0-6 (Sunday = 0)
+//	created_hour_local, created_weekday_local - the same in Location, daylight saving included
+type LocalTimeTransform struct {
+	Location *time.Location
+}
+
+// NewLocalTimeTransform loads the IANA timezone, e.g. America/New_York
+func NewLocalTimeTransform(zone string) (*LocalTimeTransform, error) {
+	location, err := time.LoadLocation(zone)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load timezone: %v", err)
+	}
+	return &LocalTimeTransform{Location: location}, nil
+}
+
+// Apply adds the derived time columns, null when created_utc is missing
+func (t *LocalTimeTransform) Apply(rec *Record) (bool, error) {
+	created, ok := rec.GetInt("created_utc")
+	utc := time.Unix(created, 0).UTC()
+	local := utc.In(t.Location)
+
+	for _, column := range []struct {
+		name  string
+		value int
+	}{
+		{"created_hour_utc", utc.Hour()},
+		{"created_weekday_utc", int(utc.Weekday())},
+		{"created_hour_local", local.Hour()},
+		{"created_weekday_local", int(local.Weekday())},
+	} {
+		raw := json.RawMessage("null")
+		if ok {
+			raw = json.RawMessage(strconv.Itoa(column.value))
+		}
+		if err := rec.SetRaw(column.name, raw); err != nil {
+			return false, err
+		}
+	}
+	return true, nil
+}
@@ -0,0 +1,201 @@
+package processor
+
+import (
+	"cmp"
+	"fmt"
+	"log"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// maxCrossCheckSamples limits how many missing submission ids a cross-check report lists
+const maxCrossCheckSamples = 20
+
+// CrossCheckReport quantifies how complete a month's comment (RC_) and submission (RS_) dumps
+// are relative to each other. Reddit ids are base36 counters, so a comment's link_id can be
+// placed before, within or after the id range of the submissions dump: submissions before it
+// are older threads still receiving comments, while those within it should be in the dump.
+type CrossCheckReport struct {
+	Comments    string `json:"comments"`
+	Submissions string `json:"submissions"`
+	// CommentRecords and SubmissionRecords count the records read from each dump
+	CommentRecords    int64 `json:"comment_records"`
+	SubmissionRecords int64 `json:"submission_records"`
+
+	// MatchedComments reference a submission present in the submissions dump
+	MatchedComments int64 `json:"matched_comments"`
+	// MissingSubmissions are distinct submissions within the dump's id range that comments
+	// reference but the dump lacks, with OrphanedComments comments on them
+	MissingSubmissions int64 `json:"missing_submissions"`
+	OrphanedComments   int64 `json:"orphaned_comments"`
+	// EarlierThreads are distinct submissions older than the dump's first one that received
+	// EarlierThreadComments comments, which is expected
+	EarlierThreads        int64 `json:"earlier_threads"`
+	EarlierThreadComments int64 `json:"earlier_thread_comments"`
+	// LaterThreads are distinct submissions newer than the dump's last one that received
+	// LaterThreadComments comments, usually from the boundary of the next month
+	LaterThreads        int64 `json:"later_threads"`
+	LaterThreadComments int64 `json:"later_thread_comments"`
+	// UnparsableComments have no usable link_id
+	UnparsableComments int64 `json:"unparsable_comments"`
+
+	// SubmissionsWithoutComments report num_comments above zero but have no comment in the
+	// comments dump. Comments made after the month are in later dumps, so a few are expected
+	// near the end of the month.
+	SubmissionsWithoutComments int64 `json:"submissions_without_comments"`
+	// ExpectedComments sums num_comments of the submissions, to compare with MatchedComments
+	ExpectedComments int64 `json:"expected_comments"`
+
+	// SampleMissing lists some missing submission fullnames for spot checks
+	SampleMissing []string `json:"sample_missing,omitempty"`
+}
+
+// crossCheckSubmission is a submission of the dump, keyed by its numeric id
+type crossCheckSubmission struct {
+	id          uint64
+	numComments int64
+	found       int64
+}
+
+// base36ID decodes a Reddit id or fullname into its numeric value
+func base36ID(s string) (uint64, bool) {
+	_, id := ParseFullname(s)
+	n, err := strconv.ParseUint(strings.ToLower(id), 36, 64)
+	return n, err == nil && id != ""
+}
+
+// CrossCheckDumps reads a month's submissions dump and then its comments dump and reports how
+// many comments reference submissions missing from the submissions dump, and vice versa.
+// Progress goes to logger, or the standard logger when nil.
+func CrossCheckDumps(commentsPath, submissionsPath string, logger *log.Logger) (CrossCheckReport, error) {
+	report := CrossCheckReport{Comments: commentsPath, Submissions: submissionsPath}
+	logger = loggerOrDefault(logger)
+
+	logger.Printf("📖 Reading submissions from %s", submissionsPath)
+	var submissions []crossCheckSubmission
+	err := scanRecords(submissionsPath, logger, func(rec *Record) error {
+		report.SubmissionRecords++
+		id, ok := rec.GetString("id")
+		n, valid := base36ID(id)
+		if !ok || !valid {
+			return nil
+		}
+		numComments, _ := rec.GetInt("num_comments")
+		submissions = append(submissions, crossCheckSubmission{id: n, numComments: numComments})
+		return nil
+	})
+	if err != nil {
+		return report, err
+	}
+	if len(submissions) == 0 {
+		return report, fmt.Errorf("no submissions with an id in %s", submissionsPath)
+	}
+	slices.SortFunc(submissions, func(a, b crossCheckSubmission) int { return cmp.Compare(a.id, b.id) })
+	first, last := submissions[0].id, submissions[len(submissions)-1].id
+
+	logger.Printf("📖 Reading comments from %s", commentsPath)
+	missing := make(map[uint64]bool)
+	earlier := make(map[uint64]bool)
+	later := make(map[uint64]bool)
+	err = scanRecords(commentsPath, logger, func(rec *Record) error {
+		report.CommentRecords++
+		linkID, _ := rec.GetString("link_id")
+		n, ok := base36ID(linkID)
+		switch {
+		case !ok:
+			report.UnparsableComments++
+		case n < first:
+			report.EarlierThreadComments++
+			earlier[n] = true
+		case n > last:
+			report.LaterThreadComments++
+			later[n] = true
+		default:
+			i, found := slices.BinarySearchFunc(submissions, n, func(s crossCheckSubmission, id uint64) int { return cmp.Compare(s.id, id) })
+			if found {
+				report.MatchedComments++
+				submissions[i].found++
+				return nil
+			}
+			report.OrphanedComments++
+			if !missing[n] && len(report.SampleMissing) < maxCrossCheckSamples {
+				report.SampleMissing = append(report.SampleMissing, "t3_"+strconv.FormatUint(n, 36))
+			}
+			missing[n] = true
+		}
+		return nil
+	})
+	if err != nil {
+		return report, err
+	}
+	report.MissingSubmissions = int64(len(missing))
+	report.EarlierThreads = int64(len(earlier))
+	report.LaterThreads = int64(len(later))
+
+	for _, s := range submissions {
+		report.ExpectedComments += s.numComments
+		if s.numComments > 0 && s.found == 0 {
+			report.SubmissionsWithoutComments++
+		}
+	}
+	return report, nil
+}
+
+// scanRecords calls fn with each record of a zst dump, stopping at the first error
+func scanRecords(inputPath string, logger *log.Logger, fn func(rec *Record) error) error {
+	in, err := openZstInput(inputPath, inputOptions{})
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	scanner := in.scanner(scannerBufferSize)
+	var rec Record
+	var lines int64
+	for scanner.Scan() {
+		rec.Reset(scanner.Bytes())
+		if err := fn(&rec); err != nil {
+			return err
+		}
+		if lines++; lines%10000000 == 0 {
+			loggerOrDefault(logger).Printf("🔄 Progress: Scanned %d lines", lines)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return inputError(err)
+	}
+	return nil
+}
+
+// percent formats part as a percentage of total
+func percent(part, total int64) string {
+	if total == 0 {
+		return "0%"
+	}
+	return fmt.Sprintf("%.2f%%", float64(part)*100/float64(total))
+}
+
+// String returns the report as readable text
+func (r CrossCheckReport) String() string {
+	out := fmt.Sprintf("🔗 Cross-check of %s and %s:\n", r.Comments, r.Submissions)
+	out += fmt.Sprintf("  💬 %s comments, 📝 %s submissions\n", formatCount(r.CommentRecords), formatCount(r.SubmissionRecords))
+	out += fmt.Sprintf("  ✅ Comments on submissions in the dump: %s (%s)\n", formatCount(r.MatchedComments), percent(r.MatchedComments, r.CommentRecords))
+	out += fmt.Sprintf("  ❓ Comments on submissions missing from the dump: %s (%s) across %s submissions\n",
+		formatCount(r.OrphanedComments), percent(r.OrphanedComments, r.CommentRecords), formatCount(r.MissingSubmissions))
+	out += fmt.Sprintf("  ⏪ Comments on older submissions: %s across %s threads\n", formatCount(r.EarlierThreadComments), formatCount(r.EarlierThreads))
+	if r.LaterThreadComments > 0 {
+		out += fmt.Sprintf("  ⏩ Comments on newer submissions: %s across %s threads\n", formatCount(r.LaterThreadComments), formatCount(r.LaterThreads))
+	}
+	if r.UnparsableComments > 0 {
+		out += fmt.Sprintf("  ⚠️  Comments without a usable link_id: %s\n", formatCount(r.UnparsableComments))
+	}
+	out += fmt.Sprintf("  🕳️  Submissions with comments but none in the comments dump: %s (%s)\n",
+		formatCount(r.SubmissionsWithoutComments), percent(r.SubmissionsWithoutComments, r.SubmissionRecords))
+	out += fmt.Sprintf("  📊 Comments found on the dump's submissions: %s of %s reported by num_comments (%s)",
+		formatCount(r.MatchedComments), formatCount(r.ExpectedComments), percent(r.MatchedComments, r.ExpectedComments))
+	if len(r.SampleMissing) > 0 {
+		out += "\n  🔎 Missing submissions, e.g. " + strings.Join(r.SampleMissing, ", ")
+	}
+	return out
+}
@@ -0,0 +1,179 @@
+package processor
+
+import (
+	"bufio"
+	"fmt"
+	"log"
+	"os"
+	"time"
+)
+
+// DeadLetterPath returns the default dead-letter queue path for an output prefix
+func DeadLetterPath(outputPrefix string) string {
+	return outputPrefix + "_deadletter.jsonl"
+}
+
+// DeadLetterSink retries failed batches of a network sink with exponential backoff and appends
+// the records of batches that still fail to an on-disk JSONL queue instead of failing the run, so
+// a transient downstream outage doesn't force reprocessing the dump. Queued records are replayed
+// later with DrainDeadLetters.
+type DeadLetterSink struct {
+	sink    Sink
+	path    string
+	retries int
+	backoff time.Duration
+
+	file   *os.File
+	writer *bufio.Writer
+	// Queued counts the records appended to the dead-letter queue
+	Queued int64
+
+	runLogger
+}
+
+// NewDeadLetterSink wraps sink, retrying each failed batch up to retries times starting with a
+// one second backoff
+func NewDeadLetterSink(sink Sink, path string, retries int) *DeadLetterSink {
+	return &DeadLetterSink{sink: sink, path: path, retries: retries, backoff: time.Second}
+}
+
+// setLogger sets the logger of the run, also used by the wrapped sink
+func (d *DeadLetterSink) setLogger(logger *log.Logger) {
+	d.runLogger.setLogger(logger)
+	if sink, ok := d.sink.(loggerSetter); ok {
+		sink.setLogger(logger)
+	}
+}
+
+// WriteBatch writes the batch to the wrapped sink, queueing it on disk if every attempt fails
+func (d *DeadLetterSink) WriteBatch(recs []*Record) error {
+	err := d.writeWithRetry(recs)
+	if err == nil {
+		return nil
+	}
+	warnf(d.logger, "⚠️ Warning: Sink write failed after %d retries, queueing %d records in %s: %v", d.retries, len(recs), d.path, err)
+	return d.enqueue(recs)
+}
+
+// writeWithRetry writes a batch, retrying with exponential backoff
+func (d *DeadLetterSink) writeWithRetry(recs []*Record) error {
+	var err error
+	for attempt := 0; attempt <= d.retries; attempt++ {
+		if attempt > 0 {
+			time.Sleep(d.backoff << (attempt - 1))
+		}
+		if err = d.sink.WriteBatch(recs); err == nil {
+			return nil
+		}
+	}
+	return err
+}
+
+// enqueue appends records to the dead-letter queue, one JSON record per line
+func (d *DeadLetterSink) enqueue(recs []*Record) error {
+	if d.file == nil {
+		f, err := os.OpenFile(d.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+		if err != nil {
+			return fmt.Errorf("failed to open dead-letter queue: %v", err)
+		}
+		d.file = f
+		d.writer = bufio.NewWriter(f)
+	}
+	for _, rec := range recs {
+		d.writer.Write(rec.Bytes())
+		if err := d.writer.WriteByte('\n'); err != nil {
+			return fmt.Errorf("failed to write dead-letter queue: %v", err)
+		}
+	}
+	// Flush every batch so queued records survive a crash later in the run
+	if err := d.writer.Flush(); err != nil {
+		return fmt.Errorf("failed to write dead-letter queue: %v", err)
+	}
+	d.Queued += int64(len(recs))
+	return nil
+}
+
+// Close closes the wrapped sink and the queue, reporting how many records were queued
+func (d *DeadLetterSink) Close() error {
+	err := d.sink.Close()
+	if d.file != nil {
+		if closeErr := d.file.Close(); err == nil {
+			err = closeErr
+		}
+		loggerOrDefault(d.logger).Printf("📮 %d records are waiting in the dead-letter queue %s; replay them with: pushshift-processor drain -vector-store=<url> %s", d.Queued, d.path, d.path)
+	}
+	return err
+}
+
+// DrainDeadLetters replays the records of a dead-letter queue into sink in batches. Batches that
+// fail again are kept in the queue, which is rewritten atomically and removed once empty. It
+// returns the number of records delivered and still queued. Messages go to logger, or the
+// standard logger when nil.
+func DrainDeadLetters(path string, sink Sink, batchSize int, logger *log.Logger) (int64, int64, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to open dead-letter queue: %v", err)
+	}
+	defer f.Close()
+
+	remainingPath := path + ".remaining"
+	remaining, err := os.Create(remainingPath)
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to create %s: %v", remainingPath, err)
+	}
+	defer os.Remove(remainingPath)
+	defer remaining.Close()
+	remainingWriter := bufio.NewWriter(remaining)
+
+	var delivered, kept int64
+	var batch []*Record
+	flush := func() {
+		if len(batch) == 0 {
+			return
+		}
+		if err := sink.WriteBatch(batch); err != nil {
+			warnf(logger, "⚠️ Warning: %d records still failing, keeping them queued: %v", len(batch), err)
+			for _, rec := range batch {
+				remainingWriter.Write(rec.Bytes())
+				remainingWriter.WriteByte('\n')
+			}
+			kept += int64(len(batch))
+		} else {
+			delivered += int64(len(batch))
+		}
+		batch = batch[:0]
+	}
+
+	scanner := newLineScanner(f, scannerBufferSize)
+	for scanner.Scan() {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		rec := &Record{}
+		rec.Reset(append([]byte(nil), scanner.Bytes()...))
+		if batch = append(batch, rec); len(batch) >= batchSize {
+			flush()
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return delivered, kept, fmt.Errorf("failed to read dead-letter queue: %v", err)
+	}
+	flush()
+	if err := sink.Close(); err != nil {
+		return delivered, kept, fmt.Errorf("failed to close sink: %v", err)
+	}
+
+	if err := remainingWriter.Flush(); err != nil {
+		return delivered, kept, fmt.Errorf("failed to write %s: %v", remainingPath, err)
+	}
+	if err := remaining.Close(); err != nil {
+		return delivered, kept, fmt.Errorf("failed to write %s: %v", remainingPath, err)
+	}
+	if kept == 0 {
+		return delivered, kept, os.Remove(path)
+	}
+	if err := os.Rename(remainingPath, path); err != nil {
+		return delivered, kept, fmt.Errorf("failed to update dead-letter queue: %v", err)
+	}
+	return delivered, kept, nil
+}
@@ -0,0 +1,431 @@
+package processor
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// DeletionLists are the records to excise from a dataset to honor deletion requests: records by
+// id and everything by an author
+type DeletionLists struct {
+	// IDs maps base36 ids to the kind of record they name, t1 or t3 when given as a fullname, or
+	// "" for either
+	IDs map[string]string
+	// Authors holds lowercased usernames, as Reddit usernames are case-insensitive
+	Authors map[string]bool
+}
+
+// LoadDeletionLists reads deletion lists from files of one id or author per line. Ids may be
+// fullnames (t1_abc123) to match only comments or submissions, and authors may start with u/.
+// Blank lines and lines starting with # are skipped. Either path may be empty.
+func LoadDeletionLists(idsPath, authorsPath string) (DeletionLists, error) {
+	lists := DeletionLists{IDs: make(map[string]string), Authors: make(map[string]bool)}
+	if idsPath != "" {
+		err := readListFile(idsPath, lists.addID)
+		if err != nil {
+			return lists, fmt.Errorf("failed to read id deletion list: %v", err)
+		}
+	}
+	if authorsPath != "" {
+		err := readListFile(authorsPath, func(entry string) {
+			entry = strings.TrimPrefix(strings.TrimPrefix(entry, "/"), "u/")
+			lists.Authors[strings.ToLower(entry)] = true
+		})
+		if err != nil {
+			return lists, fmt.Errorf("failed to read author deletion list: %v", err)
+		}
+	}
+	return lists, nil
+}
+
+// readListFile calls fn with each entry of a list file
+func readListFile(path string, fn func(entry string)) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		entry := strings.TrimSpace(scanner.Text())
+		if entry != "" && !strings.HasPrefix(entry, "#") {
+			fn(entry)
+		}
+	}
+	return scanner.Err()
+}
+
+// addID lists an id, or a fullname naming only a comment or a submission. An id listed with both
+// kinds matches either.
+func (l DeletionLists) addID(entry string) {
+	kind, id := ParseFullname(strings.ToLower(entry))
+	if previous, ok := l.IDs[id]; ok && previous != kind {
+		kind = ""
+	}
+	l.IDs[id] = kind
+}
+
+// Empty reports whether the lists name nothing to remove
+func (l DeletionLists) Empty() bool {
+	return len(l.IDs) == 0 && len(l.Authors) == 0
+}
+
+// idsOfKind returns the ids that match records of a kind, t1 or t3, sorted
+func (l DeletionLists) idsOfKind(kind string) []string {
+	ids := make([]string, 0, len(l.IDs))
+	for id, k := range l.IDs {
+		if k == "" || k == kind {
+			ids = append(ids, id)
+		}
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// DeletionFilter drops the records named by deletion lists as they are processed
+type DeletionFilter struct {
+	lists   DeletionLists
+	removed atomic.Int64
+
+	runLogger
+}
+
+// NewDeletionFilter creates a filter removing the records of the lists
+func NewDeletionFilter(lists DeletionLists) *DeletionFilter {
+	return &DeletionFilter{lists: lists}
+}
+
+// Apply drops the record when its id or author is listed
+func (f *DeletionFilter) Apply(rec *Record) (bool, error) {
+	if len(f.lists.Authors) > 0 {
+		if author, ok := rec.GetString("author"); ok && f.lists.Authors[strings.ToLower(author)] {
+			f.removed.Add(1)
+			return false, nil
+		}
+	}
+	if len(f.lists.IDs) > 0 {
+		id, _ := rec.GetString("id")
+		if kind, ok := f.lists.IDs[strings.ToLower(id)]; ok && (kind == "" || kind == recordKind(rec)) {
+			f.removed.Add(1)
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// Name implements Named
+func (f *DeletionFilter) Name() string {
+	return "deletion-lists"
+}
+
+// recordKind returns t1 for comments and t3 for submissions
+func recordKind(rec *Record) string {
+	if _, ok := rec.Get("parent_id"); ok {
+		return "t1"
+	}
+	return "t3"
+}
+
+// Close reports how many records were removed
+func (f *DeletionFilter) Close() error {
+	loggerOrDefault(f.logger).Printf("🗑️ Removed %d records on the deletion lists", f.removed.Load())
+	return nil
+}
+
+// RemoveOptions configures RemoveFromDataset
+type RemoveOptions struct {
+	Lists DeletionLists
+	// Parquet tunes the rewritten part files; it should match the options the parts were
+	// converted with
+	Parquet ParquetOptions
+	// Logger receives the messages of the removal; the standard logger when nil
+	Logger *log.Logger
+}
+
+// ManifestRemoval records a pass removing records from an output, for auditing deletion requests
+type ManifestRemoval struct {
+	At      time.Time `json:"at"`
+	IDs     int       `json:"ids"`
+	Authors int       `json:"authors"`
+	Records int64     `json:"records"`
+	// Parts lists the numbers of the parts rewritten
+	Parts []int `json:"parts,omitempty"`
+}
+
+// datasetColumns tells which of the columns deletion matches on each file of a dataset has
+type datasetColumns struct {
+	Filename string `json:"filename"`
+	Comments int64  `json:"comments"`
+	Authors  int64  `json:"authors"`
+}
+
+// partRemoval is the number of records to remove from one Parquet file
+type partRemoval struct {
+	Filename string `json:"filename"`
+	Removed  int64  `json:"removed"`
+}
+
+// datasetFiles lists the Parquet files of a dataset with the columns deletion matches on
+func datasetFiles(dataset string) ([]datasetColumns, error) {
+	glob := datasetGlob(dataset)
+	var files []datasetColumns
+	err := queryDuckDB(fmt.Sprintf("SELECT file_name AS filename, count(*) FILTER (WHERE name = 'parent_id') AS comments, "+
+		"count(*) FILTER (WHERE name = 'author') AS authors FROM parquet_schema(%s) GROUP BY file_name ORDER BY file_name;", sqlString(glob)), &files)
+	if err != nil {
+		return nil, err
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("no Parquet files match %s", glob)
+	}
+	return files, nil
+}
+
+// deletionConditions builds the DuckDB conditions matching the records of deletion lists, whose
+// entries are read from temporary list files
+type deletionConditions struct {
+	// comments, submissions and authors are the list tables of t1 ids, t3 ids and authors, or
+	// empty when nothing of that kind is listed
+	comments, submissions, authors string
+	files                          []string
+}
+
+// newDeletionConditions writes the list files of the lists
+func newDeletionConditions(lists DeletionLists) (*deletionConditions, error) {
+	c := &deletionConditions{}
+	write := func(entries []string) (string, error) {
+		if len(entries) == 0 {
+			return "", nil
+		}
+		path, err := writeListFile(entries)
+		if err != nil {
+			return "", err
+		}
+		c.files = append(c.files, path)
+		return listTable(path), nil
+	}
+	authors := make([]string, 0, len(lists.Authors))
+	for author := range lists.Authors {
+		authors = append(authors, author)
+	}
+	sort.Strings(authors)
+	var err error
+	if c.comments, err = write(lists.idsOfKind("t1")); err == nil {
+		if c.submissions, err = write(lists.idsOfKind("t3")); err == nil {
+			c.authors, err = write(authors)
+		}
+	}
+	if err != nil {
+		c.close()
+		return nil, err
+	}
+	return c, nil
+}
+
+// close removes the list files
+func (c *deletionConditions) close() {
+	for _, path := range c.files {
+		os.Remove(path)
+	}
+}
+
+// condition returns the condition matching the listed records of a file, or "" when none of
+// them can be in it. Files without a parent_id column hold submissions; in files with one, rows
+// with a null parent_id are submissions too, since RC and RS records can share a file.
+func (c *deletionConditions) condition(file datasetColumns) string {
+	var conditions []string
+	if file.Comments > 0 {
+		if c.comments != "" {
+			conditions = append(conditions, "(parent_id IS NOT NULL AND lower(id) IN (SELECT v FROM "+c.comments+"))")
+		}
+		if c.submissions != "" {
+			conditions = append(conditions, "(parent_id IS NULL AND lower(id) IN (SELECT v FROM "+c.submissions+"))")
+		}
+	} else if c.submissions != "" {
+		conditions = append(conditions, "lower(id) IN (SELECT v FROM "+c.submissions+")")
+	}
+	if c.authors != "" && file.Authors > 0 {
+		conditions = append(conditions, "lower(author) IN (SELECT v FROM "+c.authors+")")
+	}
+	if len(conditions) == 0 {
+		return ""
+	}
+	// Rows with a null author or id match neither list rather than making the condition null
+	return "coalesce(" + strings.Join(conditions, " OR ") + ", false)"
+}
+
+// RemoveFromDataset excises the records named by deletion lists from converted Parquet outputs,
+// given a directory or an output prefix, with DuckDB. Only the parts holding such records are
+// rewritten, each replaced atomically. Ids given as fullnames are matched against comments or
+// submissions by file and row, so datasets mixing RC and RS outputs are handled. An output prefix
+// with a manifest gets its part sizes updated and the pass recorded under removals. It returns
+// the number of records removed.
+func RemoveFromDataset(dataset string, opts RemoveOptions) (int64, error) {
+	if opts.Lists.Empty() {
+		return 0, fmt.Errorf("the deletion lists are empty")
+	}
+	files, err := datasetFiles(dataset)
+	if err != nil {
+		return 0, err
+	}
+	if len(opts.Lists.Authors) > 0 {
+		for _, file := range files {
+			if file.Authors == 0 {
+				return 0, fmt.Errorf("%s has no author column to match the author deletion list against", file.Filename)
+			}
+		}
+	}
+
+	conditions, err := newDeletionConditions(opts.Lists)
+	if err != nil {
+		return 0, err
+	}
+	defer conditions.close()
+	// Files are grouped by the condition that applies to them, so each group is scanned once
+	groups := make(map[string][]string)
+	listed := make(map[string]string)
+	for _, file := range files {
+		if condition := conditions.condition(file); condition != "" {
+			groups[condition] = append(groups[condition], sqlString(file.Filename))
+			listed[file.Filename] = condition
+		}
+	}
+	if len(groups) == 0 {
+		loggerOrDefault(opts.Logger).Printf("🗑️ None of the listed ids name submissions, nothing to remove")
+		return 0, nil
+	}
+
+	var removals []partRemoval
+	for condition, group := range groups {
+		var found []partRemoval
+		err = queryDuckDB(fmt.Sprintf("SELECT filename, count(*) AS removed FROM read_parquet([%s], filename=true, union_by_name=true) "+
+			"WHERE %s GROUP BY filename;", strings.Join(group, ", "), condition), &found)
+		if err != nil {
+			return 0, err
+		}
+		removals = append(removals, found...)
+	}
+	sort.Slice(removals, func(i, j int) bool { return removals[i].Filename < removals[j].Filename })
+
+	copyOptions, settings := opts.Parquet.duckdbCopyOptions()
+	if copyOptions != "" {
+		copyOptions = ", " + copyOptions
+	}
+	limits := duckdbLimits{threads: runtime.NumCPU()}
+	removed := make(map[string]int64)
+	var total int64
+	for _, part := range removals {
+		tmp := part.Filename + ".removing"
+		query := fmt.Sprintf("%s COPY (SELECT * FROM read_parquet(%s) WHERE NOT %s) TO %s (FORMAT PARQUET%s);",
+			settings, sqlString(part.Filename), listed[part.Filename], sqlString(tmp), copyOptions)
+		if err := runDuckDB(query, limits); err != nil {
+			os.Remove(tmp)
+			return total, fmt.Errorf("DuckDB failed to rewrite %s: %v", part.Filename, err)
+		}
+		if _, err := os.Stat(tmp); err != nil {
+			return total, fmt.Errorf("DuckDB did not write %s: %v", tmp, err)
+		}
+		if err := os.Rename(tmp, part.Filename); err != nil {
+			os.Remove(tmp)
+			return total, fmt.Errorf("failed to replace %s: %v", part.Filename, err)
+		}
+		loggerOrDefault(opts.Logger).Printf("🗑️ Removed %d records from %s", part.Removed, part.Filename)
+		removed[filepath.Clean(part.Filename)] = part.Removed
+		total += part.Removed
+	}
+
+	if err := recordRemoval(dataset, opts.Lists, removed, total); err != nil {
+		return total, err
+	}
+	return total, nil
+}
+
+// recordRemoval updates the manifest of an output prefix after a removal pass, if it has one
+func recordRemoval(outputPrefix string, lists DeletionLists, removed map[string]int64, total int64) error {
+	if info, err := os.Stat(outputPrefix); err == nil && info.IsDir() {
+		return nil
+	}
+	manifest, err := readManifest(outputPrefix)
+	if err != nil || manifest == nil {
+		return err
+	}
+	removal := ManifestRemoval{At: time.Now().UTC(), IDs: len(lists.IDs), Authors: len(lists.Authors), Records: total}
+	for i := range manifest.Parts {
+		part := &manifest.Parts[i]
+		n, ok := removed[filepath.Clean(part.Path)]
+		if !ok {
+			continue
+		}
+		part.RemovedRecords += n
+		if info, err := os.Stat(part.Path); err == nil {
+			part.ParquetBytes = info.Size()
+		}
+		removal.Parts = append(removal.Parts, part.Number)
+	}
+	manifest.Removals = append(manifest.Removals, removal)
+	return writeManifestFile(outputPrefix, *manifest)
+}
+
+// queryDuckDB runs a query with DuckDB and decodes its JSON rows into rows
+func queryDuckDB(query string, rows any) error {
+	var array bytes.Buffer
+	array.WriteByte('[')
+	err := queryDuckDBRows(context.Background(), query, func(row []byte) error {
+		if array.Len() > 1 {
+			array.WriteByte(',')
+		}
+		array.Write(row)
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("DuckDB query failed: %v", err)
+	}
+	array.WriteByte(']')
+	if err := json.Unmarshal(array.Bytes(), rows); err != nil {
+		return fmt.Errorf("failed to parse DuckDB output: %v", err)
+	}
+	return nil
+}
+
+// writeListFile writes entries one per line to a temporary file for DuckDB to read
+func writeListFile(entries []string) (string, error) {
+	file, err := os.CreateTemp("", "pushshift_deletion_*.txt")
+	if err != nil {
+		return "", fmt.Errorf("failed to create deletion list file: %v", err)
+	}
+	_, err = file.WriteString(strings.Join(entries, "\n") + "\n")
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(file.Name())
+		return "", fmt.Errorf("failed to write deletion list file: %v", err)
+	}
+	return file.Name(), nil
+}
+
+// listTable returns the DuckDB table expression reading a list file written by writeListFile
+func listTable(path string) string {
+	return "read_csv(" + sqlString(path) + ", header=false, auto_detect=false, delim='\\t', columns={'v': 'VARCHAR'})"
+}
+
+// sqlString quotes a string literal for DuckDB
+func sqlString(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+}
+
+// provenance implements provenanced; the lists themselves are left out, as they name what
+// deletion requests asked to remove
+func (f *DeletionFilter) provenance() map[string]any {
+	return map[string]any{"deletion_lists": map[string]int{"ids": len(f.lists.IDs), "authors": len(f.lists.Authors)}}
+}
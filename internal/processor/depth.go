@@ -0,0 +1,164 @@
+package processor
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"os"
+	"slices"
+	"strconv"
+	"sync"
+)
+
+// DepthColumn holds a comment's depth in its thread
+const DepthColumn = "depth"
+
+// depthCacheEntries is how many recent comment depths each in-memory generation holds before
+// the older one is moved to the on-disk map
+const depthCacheEntries = 1 << 20
+
+// CommentDepthTransform adds a depth column to comments by chaining parent_id lookups within
+// the input: top-level comments have depth 0 and replies their parent's depth plus one. Parents
+// must appear earlier in the input, as they do in the time-ordered dumps; replies to comments
+// outside the input (an earlier month, or removed before capture) get a null depth, as do
+// their replies.
+//
+// Recent depths are kept in memory, where nearly all parents are found, and older ones in a
+// temporary on-disk map, so memory stays bounded on dumps with hundreds of millions of comments.
+type CommentDepthTransform struct {
+	mu      sync.Mutex
+	recent  map[string]int64
+	older   map[string]int64
+	spilled bool
+
+	db     *sql.DB
+	dbPath string
+	tx     *sql.Tx
+	insert *sql.Stmt
+	lookup *sql.Stmt
+
+	known   int64
+	unknown int64
+
+	runLogger
+}
+
+// NewCommentDepthTransform creates the transform and its temporary on-disk map
+func NewCommentDepthTransform() (*CommentDepthTransform, error) {
+	t := &CommentDepthTransform{recent: make(map[string]int64), older: make(map[string]int64)}
+	var err error
+	t.db, t.dbPath, err = openTempSQLite("pushshift-depth-*.db", `PRAGMA cache_size=-65536;
+		CREATE TABLE depths (id TEXT PRIMARY KEY, depth INTEGER NOT NULL) WITHOUT ROWID`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create comment depth map: %v", err)
+	}
+	if t.tx, err = t.db.Begin(); err == nil {
+		if t.insert, err = t.tx.Prepare(`INSERT OR REPLACE INTO depths (id, depth) VALUES (?, ?)`); err == nil {
+			t.lookup, err = t.tx.Prepare(`SELECT depth FROM depths WHERE id = ?`)
+		}
+	}
+	if err != nil {
+		t.Close()
+		return nil, fmt.Errorf("failed to prepare comment depth map: %v", err)
+	}
+	return t, nil
+}
+
+// depthOf returns the depth of an earlier comment
+func (t *CommentDepthTransform) depthOf(id string) (int64, bool, error) {
+	if depth, ok := t.recent[id]; ok {
+		return depth, true, nil
+	}
+	if depth, ok := t.older[id]; ok {
+		return depth, true, nil
+	}
+	if !t.spilled {
+		return 0, false, nil
+	}
+	var depth int64
+	err := t.lookup.QueryRow(id).Scan(&depth)
+	if err == sql.ErrNoRows {
+		return 0, false, nil
+	}
+	if err != nil {
+		return 0, false, fmt.Errorf("failed to look up comment depth: %v", err)
+	}
+	return depth, true, nil
+}
+
+// remember records a comment's depth, moving the older generation to disk when the recent one
+// is full
+func (t *CommentDepthTransform) remember(id string, depth int64) error {
+	if len(t.recent) >= depthCacheEntries {
+		// Inserting in key order keeps the index writes sequential
+		for _, olderID := range slices.Sorted(maps.Keys(t.older)) {
+			if _, err := t.insert.Exec(olderID, t.older[olderID]); err != nil {
+				return fmt.Errorf("failed to store comment depth: %v", err)
+			}
+		}
+		t.spilled = t.spilled || len(t.older) > 0
+		t.older, t.recent = t.recent, make(map[string]int64, depthCacheEntries)
+	}
+	t.recent[id] = depth
+	return nil
+}
+
+// Apply sets the depth of comments; submissions get a null depth
+func (t *CommentDepthTransform) Apply(rec *Record) (bool, error) {
+	parent, isComment := rec.GetString("parent_id")
+	id, _ := rec.GetString("id")
+	if !isComment || id == "" {
+		return true, rec.SetRaw(DepthColumn, json.RawMessage("null"))
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	var depth int64
+	known := true
+	switch kind, parentID := ParseFullname(parent); kind {
+	case "t3":
+	case "t1":
+		parentDepth, ok, err := t.depthOf(parentID)
+		if err != nil {
+			return false, err
+		}
+		depth, known = parentDepth+1, ok
+	default:
+		known = false
+	}
+	if !known {
+		t.unknown++
+		return true, rec.SetRaw(DepthColumn, json.RawMessage("null"))
+	}
+	t.known++
+	if err := t.remember(id, depth); err != nil {
+		return false, err
+	}
+	return true, rec.SetRaw(DepthColumn, json.RawMessage(strconv.FormatInt(depth, 10)))
+}
+
+// Fields returns the depth column, which fixed schemas such as -extra-json and
+// -canonical-schema have to include
+func (t *CommentDepthTransform) Fields() map[string]string {
+	return map[string]string{DepthColumn: "INTEGER"}
+}
+
+// ParquetColumns types the depth column, which is null throughout submission dumps
+func (t *CommentDepthTransform) ParquetColumns() map[string]string {
+	return map[string]string{DepthColumn: "CAST(" + DepthColumn + " AS INTEGER)"}
+}
+
+// Close reports how many comment depths were resolved and removes the on-disk map
+func (t *CommentDepthTransform) Close() error {
+	if t.known+t.unknown > 0 {
+		loggerOrDefault(t.logger).Printf("🌳 Comment depth known for %d comments, unknown for %d replying to comments outside the input", t.known, t.unknown)
+	}
+	if t.tx != nil {
+		t.tx.Rollback()
+	}
+	err := t.db.Close()
+	os.Remove(t.dbPath)
+	return err
+}
@@ -0,0 +1,87 @@
+package processor
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"sync/atomic"
+)
+
+// distinguishedRoles are the values of the distinguished field for official communication
+var distinguishedRoles = []string{"moderator", "admin", "special"}
+
+// OfficialContentFilter selects records by the stickied and distinguished fields, for studies
+// that exclude or focus on official moderator and admin communication
+type OfficialContentFilter struct {
+	// ExcludeStickied drops stickied posts and comments
+	ExcludeStickied bool
+	// Distinguished, when set, keeps only records distinguished as one of these roles
+	Distinguished []string
+
+	stickied        atomic.Int64
+	undistinguished atomic.Int64
+
+	runLogger
+}
+
+// NewOfficialContentFilter creates the filter; roles are moderator, admin or special
+func NewOfficialContentFilter(excludeStickied bool, roles []string) (*OfficialContentFilter, error) {
+	for _, role := range roles {
+		if !slices.Contains(distinguishedRoles, role) {
+			return nil, fmt.Errorf("unsupported distinguished role %q, expected %s", role, strings.Join(distinguishedRoles, ", "))
+		}
+	}
+	return &OfficialContentFilter{ExcludeStickied: excludeStickied, Distinguished: roles}, nil
+}
+
+// Apply drops stickied records and records not distinguished as a selected role
+func (f *OfficialContentFilter) Apply(rec *Record) (bool, error) {
+	if f.ExcludeStickied {
+		if raw, ok := rec.Get("stickied"); ok && string(raw) == "true" {
+			f.stickied.Add(1)
+			return false, nil
+		}
+	}
+	if len(f.Distinguished) > 0 {
+		role, _ := rec.GetString("distinguished")
+		if !slices.Contains(f.Distinguished, role) {
+			f.undistinguished.Add(1)
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// Name implements Named, after the options the filter applies
+func (f *OfficialContentFilter) Name() string {
+	switch {
+	case f.ExcludeStickied && len(f.Distinguished) > 0:
+		return "exclude-stickied,only-distinguished"
+	case f.ExcludeStickied:
+		return "exclude-stickied"
+	}
+	return "only-distinguished"
+}
+
+// Close reports how many records were dropped
+func (f *OfficialContentFilter) Close() error {
+	if n := f.stickied.Load(); n > 0 {
+		loggerOrDefault(f.logger).Printf("📌 Dropped %d stickied records", n)
+	}
+	if n := f.undistinguished.Load(); n > 0 {
+		loggerOrDefault(f.logger).Printf("🛡️ Dropped %d records not distinguished as %s", n, strings.Join(f.Distinguished, " or "))
+	}
+	return nil
+}
+
+// provenance implements provenanced
+func (f *OfficialContentFilter) provenance() map[string]any {
+	settings := make(map[string]any)
+	if f.ExcludeStickied {
+		settings["exclude_stickied"] = true
+	}
+	if len(f.Distinguished) > 0 {
+		settings["only_distinguished"] = f.Distinguished
+	}
+	return settings
+}
@@ -0,0 +1,120 @@
+package processor
+
+import (
+	_ "embed"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"regexp"
+	"strings"
+)
+
+//go:embed data/domain_categories.csv
+var bundledDomainCategories string
+
+// urlPattern finds links embedded in comment bodies and selftexts
+var urlPattern = regexp.MustCompile(`https?://[^\s)\]>"']+`)
+
+// DomainCategoryTransform adds a domain_category column derived from the record's link domain.
+// Submissions use their domain/url fields; comments and self posts use the first link in their text.
+type DomainCategoryTransform struct {
+	categories map[string]string
+}
+
+// NewDomainCategoryTransform builds the transform from the bundled domain list, extended and
+// overridden by an optional user-supplied domain,category CSV
+func NewDomainCategoryTransform(userListPath string) (*DomainCategoryTransform, error) {
+	t := &DomainCategoryTransform{categories: make(map[string]string)}
+	if err := t.load(strings.NewReader(bundledDomainCategories)); err != nil {
+		return nil, fmt.Errorf("failed to load bundled domain list: %v", err)
+	}
+
+	if userListPath != "" {
+		f, err := os.Open(userListPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open domain list: %v", err)
+		}
+		defer f.Close()
+		if err := t.load(f); err != nil {
+			return nil, fmt.Errorf("failed to load domain list %s: %v", userListPath, err)
+		}
+	}
+	return t, nil
+}
+
+// load reads domain,category rows, skipping a header row if present
+func (t *DomainCategoryTransform) load(r io.Reader) error {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = 2
+	for first := true; ; first = false {
+		row, err := reader.Read()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if first && strings.EqualFold(row[0], "domain") {
+			continue
+		}
+		t.categories[normalizeDomain(row[0])] = strings.TrimSpace(row[1])
+	}
+}
+
+// normalizeDomain lowercases a host and strips a leading www.
+func normalizeDomain(host string) string {
+	host = strings.ToLower(strings.TrimSpace(host))
+	return strings.TrimPrefix(host, "www.")
+}
+
+// Category returns the category for a host, matching parent domains so that
+// e.g. m.youtube.com resolves through youtube.com
+func (t *DomainCategoryTransform) Category(host string) (string, bool) {
+	host = normalizeDomain(host)
+	for host != "" {
+		if category, ok := t.categories[host]; ok {
+			return category, true
+		}
+		dot := strings.IndexByte(host, '.')
+		if dot < 0 {
+			break
+		}
+		host = host[dot+1:]
+	}
+	return "", false
+}
+
+// linkDomain extracts the host a record links to, if any
+func linkDomain(rec *Record) string {
+	// Submissions carry a domain field; self posts use self.<subreddit>
+	if domain, ok := rec.GetString("domain"); ok && domain != "" && !strings.HasPrefix(domain, "self.") {
+		return domain
+	}
+	if link, ok := rec.GetString("url"); ok {
+		if u, err := url.Parse(link); err == nil && u.Host != "" {
+			return u.Host
+		}
+	}
+	for _, field := range []string{"body", "selftext"} {
+		text, ok := rec.GetString(field)
+		if !ok {
+			continue
+		}
+		if match := urlPattern.FindString(text); match != "" {
+			if u, err := url.Parse(match); err == nil && u.Host != "" {
+				return u.Host
+			}
+		}
+	}
+	return ""
+}
+
+// Apply sets domain_category to the category of the record's link, or null
+func (t *DomainCategoryTransform) Apply(rec *Record) (bool, error) {
+	if category, ok := t.Category(linkDomain(rec)); ok {
+		return true, rec.Set("domain_category", category)
+	}
+	return true, rec.SetRaw("domain_category", []byte("null"))
+}
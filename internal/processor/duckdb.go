@@ -0,0 +1,144 @@
+package processor
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"text/template"
+)
+
+// DefaultDuckDBSQL is the statement template the DuckDB converter runs on each part when
+// ParquetOptions.DuckDBSQL is empty, reading the part as json_to_parquet_duckdb.sh does
+const DefaultDuckDBSQL = `{{.Settings}}
+COPY (
+  SELECT * {{.Replace}}
+  FROM read_json({{.Input}}, union_by_name=true, maximum_object_size=256000000)
+) TO {{.Output}} (FORMAT PARQUET{{.CopyOptions}});
+`
+
+// DuckDBSQLData is what a DuckDB statement template is rendered with for each part
+type DuckDBSQLData struct {
+	// Input is the JSONL part as a quoted SQL string, ready to pass to read_json_auto
+	Input string
+	// Output is the Parquet file to write as a quoted SQL string
+	Output string
+	// Replace is the "REPLACE (expr AS column, ...)" clause typing columns JSON can't, or empty
+	Replace string
+	// CopyOptions are the COPY options from the Parquet flags, each preceded by ", " so they can
+	// follow FORMAT PARQUET
+	CopyOptions string
+	// Compression is the codec from -parquet-compression, empty for DuckDB's default
+	Compression string
+	// Settings are statements that must run before the COPY, or empty
+	Settings string
+}
+
+// duckdbTemplate parses the DuckDB statement template of the options, the default when unset
+func (o ParquetOptions) duckdbTemplate() (*template.Template, error) {
+	text := o.DuckDBSQL
+	if text == "" {
+		text = DefaultDuckDBSQL
+	}
+	tmpl, err := template.New("duckdb-sql").Option("missingkey=error").Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("invalid DuckDB SQL template: %v", err)
+	}
+	return tmpl, nil
+}
+
+// renderDuckDBSQL renders the statements converting a JSONL part to <outputBaseName>.parquet
+func (o ParquetOptions) renderDuckDBSQL(jsonlPath, outputBaseName string, columns map[string]string) (string, error) {
+	tmpl, err := o.duckdbTemplate()
+	if err != nil {
+		return "", err
+	}
+	copyOptions, settings := o.duckdbCopyOptions()
+	if copyOptions != "" {
+		copyOptions = ", " + copyOptions
+	}
+	data := DuckDBSQLData{
+		Input:       sqlString(jsonlPath),
+		Output:      sqlString(outputBaseName + ".parquet"),
+		Replace:     replaceClause(columns),
+		CopyOptions: copyOptions,
+		Compression: strings.ToLower(o.Compression),
+		Settings:    settings,
+	}
+	var sql strings.Builder
+	if err := tmpl.Execute(&sql, data); err != nil {
+		return "", fmt.Errorf("failed to render the DuckDB SQL template: %v", err)
+	}
+	return sql.String(), nil
+}
+
+// jsonRowsQuery wraps a query so it returns each of its rows as one JSON object string
+func jsonRowsQuery(query string) string {
+	return "SELECT CAST(to_json(q) AS VARCHAR) FROM (" + strings.TrimSuffix(strings.TrimSpace(query), ";") + ") AS q"
+}
+
+// minDuckDBMemory is the least memory a DuckDB conversion is limited to, however many workers
+// share the machine. read_json buffers twice its maximum_object_size, about 500 MB, on its own
+const minDuckDBMemory = 2 << 30
+
+// duckdbLimits are the memory limit and threads of the DuckDB database converting one part
+type duckdbLimits struct {
+	// memoryBytes is 0 when the available memory is unknown, leaving DuckDB's default of 80% of
+	// the machine's memory
+	memoryBytes int64
+	threads     int
+}
+
+// duckdbLimits divides the available memory and the cores between the workers converting parts
+// at once, as each DuckDB database would otherwise size itself to the whole machine
+func (s *PushshiftProcessor) duckdbLimits() duckdbLimits {
+	workers := max(s.Options.Workers, 1)
+	limits := duckdbLimits{threads: max(runtime.NumCPU()/workers, 1)}
+	if available, ok := availableMemory(); ok {
+		// The same share of it DuckDB takes by default
+		limits.memoryBytes = max(available*8/10/int64(workers), minDuckDBMemory)
+	}
+	return limits
+}
+
+// settings returns the limits as DuckDB settings by name
+func (l duckdbLimits) settings() map[string]string {
+	settings := map[string]string{"threads": strconv.Itoa(l.threads)}
+	if l.memoryBytes > 0 {
+		settings["memory_limit"] = strconv.FormatInt(l.memoryBytes/(1024*1024), 10) + "MB"
+	}
+	return settings
+}
+
+// String describes the limits for the conversion log
+func (l duckdbLimits) String() string {
+	if l.memoryBytes == 0 {
+		return fmt.Sprintf("%d thread%s", l.threads, plural(l.threads))
+	}
+	return fmt.Sprintf("%d thread%s, %s memory", l.threads, plural(l.threads), estimateSize(l.memoryBytes))
+}
+
+// convertToParquet converts a JSONL file to Parquet format using DuckDB, running the configured
+// statement template with the column expressions and Parquet writer options
+func (s *PushshiftProcessor) convertToParquet(jsonlPath, outputBaseName string, columns map[string]string) error {
+	sql, err := s.Options.Parquet.renderDuckDBSQL(jsonlPath, outputBaseName, columns)
+	if err != nil {
+		return err
+	}
+	limits := s.duckdbLimits()
+	s.logger().Printf("🔧 Converting %s to %s.parquet with DuckDB (%s, %s)", jsonlPath, outputBaseName, duckdbEngine, limits)
+	if err := runDuckDB(sql, limits); err != nil {
+		return fmt.Errorf("DuckDB conversion failed: %v", err)
+	}
+
+	// Verify the parquet file was created
+	parquetPath := outputBaseName + ".parquet"
+	if _, err := os.Stat(parquetPath); os.IsNotExist(err) {
+		return fmt.Errorf("parquet file was not created at %s", parquetPath)
+	}
+
+	s.logger().Printf("✅ Successfully converted %s to %s", filepath.Base(jsonlPath), parquetPath)
+	return nil
+}
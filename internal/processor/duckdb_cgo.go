@@ -0,0 +1,55 @@
+//go:build cgo
+
+package processor
+
+import (
+	"context"
+	"database/sql"
+	"net/url"
+
+	_ "github.com/marcboeker/go-duckdb"
+)
+
+// duckdbEngine names where DuckDB runs in messages: in process through the go-duckdb driver
+const duckdbEngine = "in-process"
+
+// runDuckDB runs statements in a fresh in-memory DuckDB database configured with limits, so parts
+// converted at once don't share temporary tables or overcommit the machine
+func runDuckDB(statements string, limits duckdbLimits) error {
+	config := make(url.Values)
+	for name, value := range limits.settings() {
+		config.Set(name, value)
+	}
+	db, err := sql.Open("duckdb", "?"+config.Encode())
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	_, err = db.Exec(statements)
+	return err
+}
+
+// queryDuckDBRows runs a query in a fresh in-memory DuckDB database and calls row with each
+// result row encoded as a JSON object, stopping at the first error row returns or when ctx ends
+func queryDuckDBRows(ctx context.Context, query string, row func(json []byte) error) error {
+	db, err := sql.Open("duckdb", "")
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	rows, err := db.QueryContext(ctx, jsonRowsQuery(query))
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	var line []byte
+	for rows.Next() {
+		if err := rows.Scan(&line); err != nil {
+			return err
+		}
+		if err := row(line); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
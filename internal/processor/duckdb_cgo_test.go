@@ -0,0 +1,75 @@
+//go:build cgo
+
+package processor
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"path/filepath"
+	"testing"
+)
+
+// writeDuckDBParquet writes the rows of a DuckDB query to a Parquet file
+func writeDuckDBParquet(t *testing.T, path, query string) {
+	t.Helper()
+	if err := runDuckDB("COPY ("+query+") TO "+sqlString(path)+" (FORMAT PARQUET);", duckdbLimits{threads: 1}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestQueryDuckDBRows(t *testing.T) {
+	var rows []string
+	err := queryDuckDBRows(context.Background(), "SELECT * FROM (VALUES (1, 'a\nb'), (2, NULL)) AS t(n, s) ORDER BY n;", func(row []byte) error {
+		rows = append(rows, string(row))
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{`{"n":1,"s":"a\nb"}`, `{"n":2,"s":null}`}
+	if len(rows) != len(want) || rows[0] != want[0] || rows[1] != want[1] {
+		t.Errorf("got %q, want %q", rows, want)
+	}
+	if err := queryDuckDBRows(context.Background(), "SELECT * FROM missing_table", func([]byte) error { return nil }); err == nil {
+		t.Error("a failing query returned no error")
+	}
+}
+
+func TestFindRecordsInDataset(t *testing.T) {
+	dir := t.TempDir()
+	writeDuckDBParquet(t, filepath.Join(dir, "RC_2020-01_part_001.parquet"),
+		`SELECT * FROM (VALUES ('abc', 't3_x', 'found'), ('def', 't3_x', 'other')) AS t(id, parent_id, body)`)
+	var out bytes.Buffer
+	if err := FindRecordsInDataset(dir, []string{"t1_abc"}, &out); err != nil {
+		t.Fatal(err)
+	}
+	var records []map[string]any
+	if err := json.Unmarshal(out.Bytes(), &records); err != nil {
+		t.Fatalf("%v in %q", err, out.String())
+	}
+	if len(records) != 1 || records[0]["body"] != "found" {
+		t.Errorf("got %v", records)
+	}
+}
+
+func TestParquetInput(t *testing.T) {
+	dir := t.TempDir()
+	writeDuckDBParquet(t, filepath.Join(dir, "out_part_001.parquet"), `SELECT * FROM range(3) AS t(n)`)
+	in, err := openParquetInput(dir, inputOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer in.Close()
+	scanner := in.scanner(scannerBufferSize)
+	var lines []string
+	for scanner.Scan() {
+		lines = append(lines, scanner.Text())
+	}
+	if err := scanner.Err(); err != nil {
+		t.Fatal(err)
+	}
+	if len(lines) != 3 || lines[0] != `{"n":0}` || lines[2] != `{"n":2}` {
+		t.Errorf("got %q", lines)
+	}
+}
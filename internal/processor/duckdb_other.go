@@ -0,0 +1,59 @@
+//go:build !cgo
+
+package processor
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"os/exec"
+	"slices"
+	"strings"
+)
+
+// duckdbEngine names where DuckDB runs in messages: builds without cgo can't link the go-duckdb
+// driver and run the duckdb command instead
+const duckdbEngine = "duckdb command"
+
+// runDuckDB runs statements with the duckdb command, which must be on the PATH, after setting
+// limits
+func runDuckDB(statements string, limits duckdbLimits) error {
+	var settings strings.Builder
+	for _, name := range slices.Sorted(maps.Keys(limits.settings())) {
+		fmt.Fprintf(&settings, "SET %s = %s;\n", name, sqlString(limits.settings()[name]))
+	}
+	output, err := exec.Command("duckdb", "-c", settings.String()+statements).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("%v\nOutput: %s", err, strings.TrimSpace(string(output)))
+	}
+	return nil
+}
+
+// queryDuckDBRows runs a query with the duckdb command and calls row with each result row
+// encoded as a JSON object, stopping at the first error row returns or when ctx ends
+func queryDuckDBRows(ctx context.Context, query string, row func(json []byte) error) error {
+	cmd := exec.CommandContext(ctx, "duckdb", "-noheader", "-list", "-c", jsonRowsQuery(query))
+	var stderr strings.Builder
+	cmd.Stderr = &stderr
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("failed to start the duckdb command: %v", err)
+	}
+	// to_json escapes newlines, so each row is one line
+	scanner := newLineScanner(stdout, scannerBufferSize)
+	for scanner.Scan() {
+		if err := row(scanner.Bytes()); err != nil {
+			cmd.Process.Kill()
+			cmd.Wait()
+			return err
+		}
+	}
+	scanErr := scanner.Err()
+	if err := cmd.Wait(); err != nil {
+		return fmt.Errorf("%v\nOutput: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	return scanErr
+}
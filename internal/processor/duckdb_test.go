@@ -0,0 +1,46 @@
+package processor
+
+import (
+	"maps"
+	"runtime"
+	"strconv"
+	"testing"
+)
+
+func TestDuckDBLimits(t *testing.T) {
+	cpus := runtime.NumCPU()
+	for _, workers := range []int{0, 1, 2, cpus, cpus * 4} {
+		t.Run(strconv.Itoa(workers), func(t *testing.T) {
+			s := &PushshiftProcessor{Options: Options{Workers: workers}}
+			limits := s.duckdbLimits()
+			if want := max(cpus/max(workers, 1), 1); limits.threads != want {
+				t.Errorf("got %d threads, want %d", limits.threads, want)
+			}
+			available, ok := availableMemory()
+			if !ok {
+				if limits.memoryBytes != 0 {
+					t.Errorf("got a memory limit of %d without knowing the available memory", limits.memoryBytes)
+				}
+				return
+			}
+			if limits.memoryBytes < minDuckDBMemory || limits.memoryBytes > max(available, minDuckDBMemory) {
+				t.Errorf("got a memory limit of %d with %d available", limits.memoryBytes, available)
+			}
+		})
+	}
+}
+
+func TestDuckDBLimitSettings(t *testing.T) {
+	tests := []struct {
+		limits duckdbLimits
+		want   map[string]string
+	}{
+		{duckdbLimits{threads: 4}, map[string]string{"threads": "4"}},
+		{duckdbLimits{threads: 1, memoryBytes: 3 << 30}, map[string]string{"threads": "1", "memory_limit": "3072MB"}},
+	}
+	for _, tt := range tests {
+		if got := tt.limits.settings(); !maps.Equal(got, tt.want) {
+			t.Errorf("got %v, want %v", got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,93 @@
+package processor
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"sync/atomic"
+)
+
+// EditedTransform handles the edited field, which Reddit stores as false for unedited content and
+// as the edit's epoch timestamp otherwise (true in some old records, which lack the time).
+//
+// Modes:
+//
+//	flag - edited becomes a boolean and edited_utc holds the edit time, null when unedited or unknown
+//	drop - edited records are dropped; the rest are normalized as in flag mode
+type EditedTransform struct {
+	Drop bool
+
+	dropped atomic.Int64
+
+	runLogger
+}
+
+// NewEditedTransform creates the transform for a mode; keep needs no transform and returns nil
+func NewEditedTransform(mode string) (*EditedTransform, error) {
+	switch mode {
+	case "keep":
+		return nil, nil
+	case "flag":
+		return &EditedTransform{}, nil
+	case "drop":
+		return &EditedTransform{Drop: true}, nil
+	default:
+		return nil, fmt.Errorf("unsupported edited mode %q, expected keep, drop or flag", mode)
+	}
+}
+
+// editedTime interprets the edited field, returning whether the record was edited and when
+func editedTime(rec *Record) (edited bool, at int64, known bool) {
+	raw, ok := rec.Get("edited")
+	if !ok {
+		return false, 0, false
+	}
+	switch string(raw) {
+	case "false", "null", "0", `"false"`:
+		return false, 0, false
+	case "true", `"true"`:
+		return true, 0, false
+	}
+	at, known = rec.GetInt("edited")
+	return true, at, known
+}
+
+// Apply normalizes edited and adds edited_utc, dropping edited records in drop mode
+func (t *EditedTransform) Apply(rec *Record) (bool, error) {
+	edited, at, known := editedTime(rec)
+	if edited && t.Drop {
+		t.dropped.Add(1)
+		return false, nil
+	}
+	if err := rec.SetRaw("edited", json.RawMessage(strconv.FormatBool(edited))); err != nil {
+		return false, err
+	}
+	raw := json.RawMessage("null")
+	if known {
+		raw = json.RawMessage(strconv.FormatInt(at, 10))
+	}
+	return true, rec.SetRaw("edited_utc", raw)
+}
+
+// Fields returns the normalized columns and their DuckDB types, which fixed schemas such as
+// -extra-json and -canonical-schema have to include
+func (t *EditedTransform) Fields() map[string]string {
+	return map[string]string{"edited": "BOOLEAN", "edited_utc": "BIGINT"}
+}
+
+// ParquetColumns types the normalized columns, which may be all null in a part
+func (t *EditedTransform) ParquetColumns() map[string]string {
+	columns := make(map[string]string)
+	for name, typ := range t.Fields() {
+		columns[name] = fmt.Sprintf("CAST(%s AS %s)", name, typ)
+	}
+	return columns
+}
+
+// Close reports how many edited records were dropped
+func (t *EditedTransform) Close() error {
+	if n := t.dropped.Load(); n > 0 {
+		loggerOrDefault(t.logger).Printf("✏️ Dropped %d edited records", n)
+	}
+	return nil
+}
@@ -0,0 +1,134 @@
+package processor
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net"
+	"net/smtp"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// EmailReport configures the completion report sent over SMTP
+type EmailReport struct {
+	// Server is the SMTP server as host:port; STARTTLS is used when the server offers it
+	Server string
+	From   string
+	To     []string
+	// FailuresOnly skips the report for successful runs
+	FailuresOnly bool
+	// Username and Password authenticate with PLAIN auth; they fall back to the SMTP_USERNAME
+	// and SMTP_PASSWORD environment variables
+	Username string
+	Password string
+}
+
+// Send emails the outcome of a run: the statistics and manifest summary in the body, and the
+// manifest JSON as a stats.json attachment
+func (e EmailReport) Send(inputPath, outputPrefix string, stats ProcessStats, runErr error) error {
+	if runErr == nil && e.FailuresOnly {
+		return nil
+	}
+
+	manifest := newManifest(outputPrefix, inputPath, stats)
+	attachment, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode report: %v", err)
+	}
+
+	subject := fmt.Sprintf("✅ pushshift-processor finished %s", filepath.Base(inputPath))
+	var body strings.Builder
+	if runErr != nil {
+		subject = fmt.Sprintf("❌ pushshift-processor failed on %s", filepath.Base(inputPath))
+		fmt.Fprintf(&body, "Error: %v\n\n", runErr)
+	}
+	fmt.Fprintf(&body, "Input: %s\nInput SHA-256: %s\nOutput prefix: %s\n\n", inputPath, stats.InputSHA256, outputPrefix)
+	var jsonlBytes, parquetBytes int64
+	for _, part := range stats.Parts {
+		jsonlBytes += part.JSONLBytes
+		parquetBytes += part.ParquetBytes
+	}
+	fmt.Fprintf(&body, "Parts: %d (%.2f MB of JSONL, %.2f MB of Parquet)\n", len(stats.Parts),
+		float64(jsonlBytes)/1024/1024, float64(parquetBytes)/1024/1024)
+	for _, part := range stats.Parts {
+		fmt.Fprintf(&body, "  %s: %d lines, %.2f MB\n", part.Path, part.Lines, float64(part.ParquetBytes)/1024/1024)
+	}
+	fmt.Fprintf(&body, "\n%s\n", stats.String())
+
+	message, err := e.compose(subject, body.String(), attachment)
+	if err != nil {
+		return err
+	}
+
+	host, _, err := net.SplitHostPort(e.Server)
+	if err != nil {
+		return fmt.Errorf("invalid SMTP server %q, expected host:port: %v", e.Server, err)
+	}
+	username, password := e.Username, e.Password
+	if username == "" {
+		username, password = os.Getenv("SMTP_USERNAME"), os.Getenv("SMTP_PASSWORD")
+	}
+	var auth smtp.Auth
+	if username != "" {
+		auth = smtp.PlainAuth("", username, password, host)
+	}
+	if err := smtp.SendMail(e.Server, auth, e.From, e.To, message); err != nil {
+		return fmt.Errorf("failed to send report email: %v", err)
+	}
+	return nil
+}
+
+// compose builds a multipart MIME message with a text body and a JSON attachment
+func (e EmailReport) compose(subject, body string, attachment []byte) ([]byte, error) {
+	var msg bytes.Buffer
+	parts := multipart.NewWriter(&msg)
+
+	fmt.Fprintf(&msg, "From: %s\r\n", e.From)
+	fmt.Fprintf(&msg, "To: %s\r\n", strings.Join(e.To, ", "))
+	fmt.Fprintf(&msg, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", subject))
+	fmt.Fprintf(&msg, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	fmt.Fprintf(&msg, "MIME-Version: 1.0\r\n")
+	fmt.Fprintf(&msg, "Content-Type: multipart/mixed; boundary=%s\r\n\r\n", parts.Boundary())
+
+	text, err := parts.CreatePart(textproto.MIMEHeader{
+		"Content-Type":              {"text/plain; charset=utf-8"},
+		"Content-Transfer-Encoding": {"base64"},
+	})
+	if err != nil {
+		return nil, err
+	}
+	writeBase64(text, []byte(body))
+
+	file, err := parts.CreatePart(textproto.MIMEHeader{
+		"Content-Type":              {"application/json"},
+		"Content-Disposition":       {`attachment; filename="stats.json"`},
+		"Content-Transfer-Encoding": {"base64"},
+	})
+	if err != nil {
+		return nil, err
+	}
+	writeBase64(file, attachment)
+
+	if err := parts.Close(); err != nil {
+		return nil, err
+	}
+	return msg.Bytes(), nil
+}
+
+// writeBase64 writes data base64-encoded in 76-character lines, as MIME requires
+func writeBase64(w io.Writer, data []byte) {
+	encoded := base64.StdEncoding.EncodeToString(data)
+	for len(encoded) > 76 {
+		w.Write([]byte(encoded[:76] + "\r\n"))
+		encoded = encoded[76:]
+	}
+	w.Write([]byte(encoded + "\r\n"))
+}
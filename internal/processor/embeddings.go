@@ -0,0 +1,187 @@
+package processor
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"time"
+)
+
+// EmbeddingOptions configures the embedding enrichment
+type EmbeddingOptions struct {
+	// Endpoint is an OpenAI-compatible embeddings URL (POST {"model", "input"} -> {"data": [{"embedding"}]})
+	Endpoint string
+	// Model is passed through to the endpoint
+	Model string
+	// APIKey, when set, is sent as a bearer token
+	APIKey string
+	// TextFields are tried in order to find the text to embed
+	TextFields []string
+	// MetadataFields are carried over from the record next to the id and vector
+	MetadataFields []string
+	// BatchSize is the number of texts sent per request
+	BatchSize int
+	// Concurrency is the number of requests in flight at once
+	Concurrency int
+	// Timeout bounds each request
+	Timeout time.Duration
+	// Retries is how often a failed request is retried before the run fails
+	Retries int
+}
+
+// embeddingRequest and embeddingResponse are the OpenAI-compatible wire format
+type embeddingRequest struct {
+	Model string   `json:"model,omitempty"`
+	Input []string `json:"input"`
+}
+
+type embeddingResponse struct {
+	Data []struct {
+		Index     int       `json:"index"`
+		Embedding []float32 `json:"embedding"`
+	} `json:"data"`
+}
+
+// EmbeddingTransform replaces each record with {id, metadata..., vector}, where vector is the
+// embedding of the record's text. Records without text are dropped since they cannot be embedded.
+type EmbeddingTransform struct {
+	opts   EmbeddingOptions
+	client *http.Client
+}
+
+// NewEmbeddingTransform creates an embedding enrichment, filling in defaults for unset options.
+// The API key falls back to the EMBEDDING_API_KEY environment variable.
+func NewEmbeddingTransform(opts EmbeddingOptions) *EmbeddingTransform {
+	if len(opts.TextFields) == 0 {
+		opts.TextFields = []string{"body", "selftext", "title"}
+	}
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = 64
+	}
+	if opts.Concurrency <= 0 {
+		opts.Concurrency = 4
+	}
+	if opts.Timeout <= 0 {
+		opts.Timeout = 60 * time.Second
+	}
+	if opts.APIKey == "" {
+		opts.APIKey = os.Getenv("EMBEDDING_API_KEY")
+	}
+	return &EmbeddingTransform{
+		opts:   opts,
+		client: &http.Client{Timeout: opts.Timeout},
+	}
+}
+
+// BatchSize buffers enough records to keep every concurrent request busy
+func (t *EmbeddingTransform) BatchSize() int {
+	return t.opts.BatchSize * t.opts.Concurrency
+}
+
+// ApplyBatch embeds the text of each record and reshapes it into a vector row
+func (t *EmbeddingTransform) ApplyBatch(recs []*Record) ([]bool, error) {
+	keep := make([]bool, len(recs))
+	var texts []string
+	var owners []int
+	for i, rec := range recs {
+		for _, field := range t.opts.TextFields {
+			if text, ok := rec.GetString(field); ok && text != "" {
+				texts = append(texts, text)
+				owners = append(owners, i)
+				keep[i] = true
+				break
+			}
+		}
+	}
+
+	vectors := make([][]float32, len(texts))
+	err := forEachBatch(len(texts), t.opts.BatchSize, t.opts.Concurrency, func(start, end int) error {
+		embedded, err := t.request(texts[start:end])
+		if err != nil {
+			return err
+		}
+		copy(vectors[start:end], embedded)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for j, i := range owners {
+		if err := t.reshape(recs[i], vectors[j]); err != nil {
+			return nil, err
+		}
+	}
+	return keep, nil
+}
+
+// reshape rewrites a record to its id, metadata fields and vector
+func (t *EmbeddingTransform) reshape(rec *Record, vector []float32) error {
+	row := map[string]json.RawMessage{}
+	order := []string{"id"}
+	if id, ok := rec.Get("id"); ok {
+		row["id"] = id
+	} else {
+		row["id"] = json.RawMessage("null")
+	}
+	for _, field := range t.opts.MetadataFields {
+		if value, ok := rec.Get(field); ok {
+			row[field] = value
+		} else {
+			row[field] = json.RawMessage("null")
+		}
+		order = append(order, field)
+	}
+	encodedVector, err := json.Marshal(vector)
+	if err != nil {
+		return fmt.Errorf("failed to encode vector: %v", err)
+	}
+	row["vector"] = encodedVector
+	order = append(order, "vector")
+
+	// Build the new line through a fresh record so key order follows the configuration
+	next := NewRecord([]byte("{}"))
+	for _, key := range order {
+		if err := next.SetRaw(key, row[key]); err != nil {
+			return err
+		}
+	}
+	rec.Reset(next.Bytes())
+	return nil
+}
+
+// request embeds one batch of texts
+func (t *EmbeddingTransform) request(texts []string) ([][]float32, error) {
+	body, err := json.Marshal(embeddingRequest{Model: t.opts.Model, Input: texts})
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode embedding request: %v", err)
+	}
+
+	var headers map[string]string
+	if t.opts.APIKey != "" {
+		headers = map[string]string{"Authorization": "Bearer " + t.opts.APIKey}
+	}
+	data, err := sendJSON(t.client, http.MethodPost, t.opts.Endpoint, headers, body, t.opts.Retries)
+	if err != nil {
+		return nil, fmt.Errorf("embedding failed: %v", err)
+	}
+
+	var parsed embeddingResponse
+	if err := json.Unmarshal(data, &parsed); err != nil {
+		return nil, fmt.Errorf("invalid embedding response: %v", err)
+	}
+	if len(parsed.Data) != len(texts) {
+		return nil, fmt.Errorf("embedding endpoint returned %d vectors for %d texts", len(parsed.Data), len(texts))
+	}
+
+	vectors := make([][]float32, len(texts))
+	for i, item := range parsed.Data {
+		index := item.Index
+		if index < 0 || index >= len(texts) {
+			index = i
+		}
+		vectors[index] = item.Embedding
+	}
+	return vectors, nil
+}
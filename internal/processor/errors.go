@@ -0,0 +1,131 @@
+package processor
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"strconv"
+	"strings"
+)
+
+// Errors a run can fail with, for callers that handle failures differently by cause. Returned
+// errors wrap them with details, so test with errors.Is and errors.As.
+var (
+	// ErrCorruptInput is returned when the input cannot be decompressed or split into lines:
+	// damaged zstd data, unexpected bytes between frames or lines beyond the scanner limit
+	ErrCorruptInput = errors.New("corrupt input")
+	// ErrSinkUnavailable is returned when a sink keeps failing to store records
+	ErrSinkUnavailable = errors.New("sink unavailable")
+	// ErrCancelled is returned when a run was stopped through Control.Cancel
+	ErrCancelled = errors.New("run cancelled")
+)
+
+// ErrConversionFailed is returned when the converter fails on a part or side table
+type ErrConversionFailed struct {
+	// Part is the number of the failed part, 0 for a side table
+	Part int
+	// Path is the JSONL file that failed to convert
+	Path string
+	Err  error
+}
+
+// Error implements error
+func (e *ErrConversionFailed) Error() string {
+	if e.Part == 0 {
+		return fmt.Sprintf("failed to convert %s to parquet: %v", e.Path, e.Err)
+	}
+	return fmt.Sprintf("failed to convert part %d to parquet: %v", e.Part, e.Err)
+}
+
+// Unwrap returns the converter's error
+func (e *ErrConversionFailed) Unwrap() error {
+	return e.Err
+}
+
+// ErrBadRecord is returned when a transform fails on a record, e.g. because its line is not
+// valid JSON
+type ErrBadRecord struct {
+	// Line is the record's input line, counting from 1
+	Line int64
+	// Offset is where the line starts in the decompressed input
+	Offset int64
+	// Part is the part the record was read into, 0 outside of parts
+	Part int
+	// Record is the line as the failing transform saw it
+	Record []byte
+	// Withheld is set when Record is left out because the run's redaction policy couldn't be
+	// applied to it
+	Withheld bool
+	Err      error
+}
+
+// Error implements error
+func (e *ErrBadRecord) Error() string {
+	return fmt.Sprintf("transform failed on line %d: %v", e.Line, e.Err)
+}
+
+// Unwrap returns the transform's error
+func (e *ErrBadRecord) Unwrap() error {
+	return e.Err
+}
+
+// ErrBadLine is returned by runs with Options.OnBadLine set to BadLineFail when an input line is
+// not valid JSON
+type ErrBadLine struct {
+	ErrBadRecord
+}
+
+// Error implements error
+func (e *ErrBadLine) Error() string {
+	return fmt.Sprintf("line %d is not valid JSON: %v", e.Line, e.Err)
+}
+
+// ErrPartsFailed is returned by runs with ContinueOnPartError when some parts failed to convert.
+// The other parts were converted, and the manifest lists the failed ones with their input lines.
+type ErrPartsFailed struct {
+	Parts []int
+}
+
+// Error implements error
+func (e *ErrPartsFailed) Error() string {
+	numbers := make([]string, len(e.Parts))
+	for i, n := range e.Parts {
+		numbers[i] = strconv.Itoa(n)
+	}
+	if len(numbers) == 1 {
+		return fmt.Sprintf("part %s failed to convert", numbers[0])
+	}
+	return fmt.Sprintf("parts %s failed to convert", strings.Join(numbers, ", "))
+}
+
+// ErrFrameCorrupt is returned when a zstd frame of the input fails its content checksum or
+// declared size, i.e. the compressed data was damaged after it was written. Runs fail with it
+// wrapped in ErrCorruptInput.
+type ErrFrameCorrupt struct {
+	// Frame is the number of the data frame, counting from 1 where reading began
+	Frame int
+	// Offset and Size locate the frame in the compressed file
+	Offset int64
+	Size   int64
+	Err    error
+}
+
+// Error implements error
+func (e *ErrFrameCorrupt) Error() string {
+	return fmt.Sprintf("zstd frame %d at offset %d (%d bytes) failed verification: %v", e.Frame, e.Offset, e.Size, e.Err)
+}
+
+// Unwrap returns the decoder's error
+func (e *ErrFrameCorrupt) Unwrap() error {
+	return e.Err
+}
+
+// inputError classifies an error met while reading the decompressed input: failures of the
+// underlying file are returned as they are, anything else means the data itself is damaged
+func inputError(err error) error {
+	var pathErr *fs.PathError
+	if errors.As(err, &pathErr) {
+		return fmt.Errorf("failed to read input: %w", err)
+	}
+	return fmt.Errorf("%w: %w", ErrCorruptInput, err)
+}
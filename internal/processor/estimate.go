@@ -0,0 +1,368 @@
+package processor
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// defaultEstimateSampleBytes is how much decompressed input each sample of Estimate covers
+const defaultEstimateSampleBytes = 256 * 1024 * 1024
+
+// EstimateOptions configures Estimate
+type EstimateOptions struct {
+	// SampleBytes is how much decompressed input each sample covers, 256MB when 0
+	SampleBytes int64
+}
+
+// EstimateSample describes one portion of the input read by Estimate
+type EstimateSample struct {
+	// Name is "start" or "middle"
+	Name string `json:"name"`
+	// Offset is where the sample starts in the compressed input
+	Offset          int64         `json:"offset"`
+	CompressedBytes int64         `json:"compressed_bytes"`
+	ContentBytes    int64         `json:"content_bytes"`
+	Lines           int64         `json:"lines"`
+	DecodeTime      time.Duration `json:"decode_ns"`
+}
+
+// RunEstimate projects the size and duration of a full run from samples of its input
+type RunEstimate struct {
+	InputBytes int64            `json:"input_bytes"`
+	Samples    []EstimateSample `json:"samples"`
+	// Complete is set when the samples covered the whole input, so nothing is projected
+	Complete     bool  `json:"complete"`
+	ContentBytes int64 `json:"content_bytes"`
+	Lines        int64 `json:"lines"`
+	// Records counts the lines kept by the filters
+	Records      int64 `json:"records"`
+	JSONLBytes   int64 `json:"jsonl_bytes"`
+	ParquetBytes int64 `json:"parquet_bytes"`
+	Parts        int   `json:"parts"`
+	// ScratchBytes is the peak intermediate data on disk, as PeakScratchBytes of a run
+	ScratchBytes int64 `json:"scratch_bytes"`
+	// DecodeTime, ProcessTime and ConvertTime are the projected time spent reading and
+	// decompressing, running transforms and writing parts, and converting parts. Decoding and
+	// processing overlap; WallTime accounts for that and for Options.Workers.
+	DecodeTime  time.Duration `json:"decode_ns"`
+	ProcessTime time.Duration `json:"process_ns"`
+	ConvertTime time.Duration `json:"convert_ns"`
+	WallTime    time.Duration `json:"wall_ns"`
+}
+
+// Estimate projects a full run of the input with the processor's options from samples of it: the
+// first SampleBytes of content and, for inputs made of many zstd frames, as much again from the
+// frame nearest the middle. Decoding is timed on the samples, which are then processed and
+// converted with outputPath as the output prefix. The sample files are removed afterwards; the
+// Parquet files and side tables of the sample run are left for the caller to inspect or remove.
+func (s *PushshiftProcessor) Estimate(inputPath, outputPath string, opts EstimateOptions) (RunEstimate, error) {
+	var est RunEstimate
+	if s.Options.CountOnly || s.Options.SplitOnly || s.Options.Sink != nil {
+		return est, fmt.Errorf("estimates cover runs converting parts to Parquet")
+	}
+	if IsParquetDataset(inputPath) {
+		return est, fmt.Errorf("estimates cover compressed dumps, not Parquet datasets")
+	}
+	sampleBytes := opts.SampleBytes
+	if sampleBytes <= 0 {
+		sampleBytes = defaultEstimateSampleBytes
+	}
+	size, _, err := statInput(inputPath)
+	if err != nil {
+		return est, fmt.Errorf("failed to stat input file: %v", err)
+	}
+	est.InputBytes = size
+
+	var paths []string
+	removeSamples := []string{outputPath + "_sample_start.jsonl", outputPath + "_sample_middle.jsonl"}
+	defer func() {
+		for _, path := range removeSamples {
+			if _, err := os.Stat(path); err == nil {
+				removeScratch(s.logger(), path)
+			}
+		}
+	}()
+
+	// The start of the input, read as processing runs read it
+	in, err := openZstInput(inputPath, inputOptions{lineEndings: s.Options.LineEndings, logger: s.logger()})
+	if err != nil {
+		return est, err
+	}
+	startPath := outputPath + "_sample_start.jsonl"
+	paths = append(paths, startPath)
+	s.logger().Printf("🧪 Sampling %.0f MB from the start of %s", float64(sampleBytes)/1024/1024, inputPath)
+	start, complete, err := takeSample(in, startPath, sampleBytes, false)
+	start.Name, start.CompressedBytes = "start", in.compressed.bytes.Load()
+	in.Close()
+	if err != nil {
+		return est, err
+	}
+	if !complete && start.CompressedBytes == 0 {
+		return est, fmt.Errorf("the compression of %s can't be measured, estimates need a zstd or tar input", inputPath)
+	}
+	est.Samples = append(est.Samples, start)
+	est.Complete = complete
+
+	if !complete {
+		middle, ok, err := s.sampleMiddle(inputPath, outputPath+"_sample_middle.jsonl", sampleBytes)
+		if err != nil {
+			return est, err
+		}
+		if ok {
+			paths = append(paths, outputPath+"_sample_middle.jsonl")
+			est.Samples = append(est.Samples, middle)
+		} else {
+			warnf(s.logger(), "⚠️ Warning: %s can't be entered mid-stream, estimating from its start only", inputPath)
+		}
+	}
+
+	// The samples go through the configured pipeline as one input
+	sample := PushshiftProcessor{Options: s.Options}
+	sample.Options.ConcatInputs = paths
+	sample.Options.Append = false
+	sample.Options.Cache = nil
+	sample.Options.SkipLines, sample.Options.TakeLines = 0, 0
+	sample.Options.Control = NewControl()
+	sample.Options.OnEvent = nil
+	s.logger().Printf("🧪 Processing the samples into %s", outputPath)
+	stats, err := sample.Process(strings.Join(paths, ","), outputPath)
+	if err != nil {
+		return est, fmt.Errorf("failed to process the samples: %w", err)
+	}
+	est.project(stats, s.Options)
+	return est, nil
+}
+
+// sampleMiddle copies a sample starting at the data frame nearest the middle of the input. ok is
+// false for inputs that can't be entered there: archives and inputs of a single frame.
+func (s *PushshiftProcessor) sampleMiddle(inputPath, samplePath string, sampleBytes int64) (EstimateSample, bool, error) {
+	sample := EstimateSample{Name: "middle"}
+	chunks, err := SplitChunks(inputPath)
+	if err != nil {
+		return sample, false, err
+	}
+	var raw io.ReaderAt
+	var size int64
+	if chunks != nil {
+		if archiveKind(strings.TrimSuffix(chunks[0], filepath.Ext(chunks[0]))) != "" {
+			return sample, false, nil
+		}
+		joined, err := openChunkedFile(chunks, nil)
+		if err != nil {
+			return sample, false, fmt.Errorf("failed to open input file: %v", err)
+		}
+		defer joined.Close()
+		raw, size = joined, joined.size
+	} else {
+		if archiveKind(inputPath) != "" {
+			return sample, false, nil
+		}
+		file, err := os.Open(inputPath)
+		if err != nil {
+			return sample, false, fmt.Errorf("failed to open input file: %v", err)
+		}
+		defer file.Close()
+		info, err := file.Stat()
+		if err != nil {
+			return sample, false, fmt.Errorf("failed to stat input file: %v", err)
+		}
+		raw, size = file, info.Size()
+	}
+
+	spans, err := indexFrames(raw, size)
+	if err != nil || len(spans) < 2 {
+		return sample, false, nil
+	}
+	middle := spans[len(spans)-1]
+	for _, span := range spans[1:] {
+		if span.Offset >= size/2 {
+			middle = span
+			break
+		}
+	}
+	sample.Offset = middle.Offset
+	compressed := &timedReader{r: io.NewSectionReader(raw, middle.Offset, size-middle.Offset)}
+	zr, err := zstd.NewReader(newFrameReader(compressed, s.logger()))
+	if err != nil {
+		return sample, false, fmt.Errorf("failed to create zstd reader: %v", err)
+	}
+	defer zr.Close()
+
+	s.logger().Printf("🧪 Sampling %.0f MB from frame %d of %d, at %.0f%% of the input", float64(sampleBytes)/1024/1024,
+		indexOfSpan(spans, middle)+1, len(spans), float64(middle.Offset)/float64(size)*100)
+	decoded, _, err := takeSample(zr, samplePath, sampleBytes, true)
+	decoded.Name, decoded.Offset, decoded.CompressedBytes = sample.Name, sample.Offset, compressed.bytes.Load()
+	return decoded, err == nil, err
+}
+
+// indexOfSpan returns the position of span in spans
+func indexOfSpan(spans []frameSpan, span frameSpan) int {
+	for i, s := range spans {
+		if s == span {
+			return i
+		}
+	}
+	return -1
+}
+
+// takeSample copies whole lines from r to path until at least limit bytes are copied, timing the
+// reads. skipFirst drops the first line, which a frame entered mid-stream usually starts partway
+// through. complete reports that r ended before the limit.
+func takeSample(r io.Reader, path string, limit int64, skipFirst bool) (sample EstimateSample, complete bool, err error) {
+	file, err := os.Create(path)
+	if err != nil {
+		return sample, false, fmt.Errorf("failed to create sample file: %v", err)
+	}
+	defer file.Close()
+	timed := &timedReader{r: r}
+	br := bufio.NewReaderSize(timed, bufferSize)
+	w := bufio.NewWriterSize(file, bufferSize)
+	for sample.ContentBytes < limit {
+		line, readErr := br.ReadSlice('\n')
+		if readErr == bufio.ErrBufferFull {
+			// A line longer than the buffer is copied in pieces
+			readErr = nil
+		} else if len(line) > 0 {
+			if skipFirst {
+				skipFirst = false
+				continue
+			}
+			sample.Lines++
+		}
+		if !skipFirst {
+			if _, err := w.Write(line); err != nil {
+				return sample, false, fmt.Errorf("failed to write sample file: %v", err)
+			}
+			sample.ContentBytes += int64(len(line))
+		}
+		if readErr == io.EOF {
+			complete = true
+			break
+		}
+		if readErr != nil {
+			return sample, false, inputError(readErr)
+		}
+	}
+	sample.DecodeTime = time.Duration(timed.nanos.Load())
+	if err := w.Flush(); err != nil {
+		return sample, false, fmt.Errorf("failed to write sample file: %v", err)
+	}
+	return sample, complete, nil
+}
+
+// project scales the statistics of the sample run up to the whole input
+func (est *RunEstimate) project(stats ProcessStats, opts Options) {
+	var compressed, content int64
+	var decode time.Duration
+	for _, sample := range est.Samples {
+		compressed += sample.CompressedBytes
+		content += sample.ContentBytes
+		decode += sample.DecodeTime
+	}
+	if content == 0 {
+		return
+	}
+	scale := 1.0
+	if !est.Complete && compressed > 0 {
+		est.ContentBytes = int64(float64(est.InputBytes) * float64(content) / float64(compressed))
+		scale = float64(est.ContentBytes) / float64(content)
+	} else {
+		est.ContentBytes = content
+	}
+	scaled := func(n int64) int64 { return int64(float64(n) * scale) }
+
+	var records, jsonl, parquet, largestPart int64
+	for _, part := range stats.Parts {
+		records += part.Lines
+		jsonl += part.JSONLBytes
+		parquet += part.ParquetBytes
+		largestPart = max(largestPart, part.JSONLBytes)
+	}
+	est.Lines = scaled(stats.TotalLines)
+	est.Records = scaled(records)
+	est.JSONLBytes = scaled(jsonl)
+	est.ParquetBytes = scaled(parquet)
+	est.DecodeTime = time.Duration(float64(decode) * scale)
+	est.ProcessTime = time.Duration(float64(stats.ExecutionTime-stats.Stages.ConvertTime) * scale)
+	est.ConvertTime = time.Duration(float64(stats.Stages.ConvertTime) * scale)
+	if est.JSONLBytes == 0 {
+		est.WallTime = max(est.DecodeTime, est.ProcessTime)
+		return
+	}
+
+	// Parts are sized as the run would size them, from the ratio observed on the samples
+	partLimit := int64(partSizeThreshold)
+	if opts.PartSize > 0 {
+		partLimit = opts.PartSize
+	}
+	if opts.TargetParquetSize > 0 && parquet > 0 {
+		partLimit = max(int64(float64(opts.TargetParquetSize)*float64(jsonl)/float64(parquet)), minPartSize)
+	}
+	est.Parts = int(math.Ceil(float64(est.JSONLBytes) / float64(partLimit)))
+	workers := max(opts.Workers, 1)
+	partsOnDisk := 1
+	if workers > 1 {
+		// The parts being converted and the one being written
+		partsOnDisk = min(workers+1, est.Parts)
+	}
+	scratchPerByte := 1.0
+	if largestPart > 0 && opts.PartCompressionLevel > 0 {
+		scratchPerByte = float64(stats.PeakScratchBytes) / float64(largestPart)
+	}
+	est.ScratchBytes = int64(float64(min(partLimit, est.JSONLBytes)*int64(partsOnDisk)) * scratchPerByte)
+
+	stream := max(est.DecodeTime, est.ProcessTime)
+	if workers == 1 {
+		est.WallTime = stream + est.ConvertTime
+	} else {
+		perPart := est.ConvertTime / time.Duration(est.Parts)
+		est.WallTime = max(stream, est.ConvertTime/time.Duration(min(workers, est.Parts))) + perPart
+	}
+}
+
+// String formats the estimate for the terminal
+func (est RunEstimate) String() string {
+	var sampled int64
+	var names []string
+	for _, sample := range est.Samples {
+		sampled += sample.ContentBytes
+		names = append(names, sample.Name)
+	}
+	out := "🔮 Estimate:\n"
+	if est.Complete {
+		out += "  🧪 The sample covered the whole input, nothing was projected\n"
+	} else {
+		out += fmt.Sprintf("  🧪 Projected from %s of samples (%s)\n", estimateSize(sampled), strings.Join(names, ", "))
+	}
+	out += fmt.Sprintf("  📦 Input: %s compressed, about %s decompressed\n", estimateSize(est.InputBytes), estimateSize(est.ContentBytes)) +
+		fmt.Sprintf("  📝 Lines: about %s, %s kept by the filters\n", formatCount(est.Lines), formatCount(est.Records)) +
+		fmt.Sprintf("  ⏱️  Wall time: about %v (decode %v, transform and write %v, convert %v)\n",
+			est.WallTime.Round(time.Second), est.DecodeTime.Round(time.Second), est.ProcessTime.Round(time.Second), est.ConvertTime.Round(time.Second)) +
+		fmt.Sprintf("  💽 Peak scratch disk usage: about %s\n", estimateSize(est.ScratchBytes)) +
+		fmt.Sprintf("  🗂️  Output: about %s of Parquet in %d part%s, from %s of JSONL", estimateSize(est.ParquetBytes), est.Parts, plural(est.Parts), estimateSize(est.JSONLBytes))
+	return out
+}
+
+// estimateSize formats a byte count in MB or GB
+func estimateSize(bytes int64) string {
+	if bytes >= 10*1024*1024*1024 {
+		return fmt.Sprintf("%.1f GB", float64(bytes)/1024/1024/1024)
+	}
+	return fmt.Sprintf("%.1f MB", float64(bytes)/1024/1024)
+}
+
+// plural returns the suffix of a count's noun
+func plural(n int) string {
+	if n == 1 {
+		return ""
+	}
+	return "s"
+}
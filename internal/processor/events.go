@@ -0,0 +1,72 @@
+package processor
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// progressEventLines is how many input lines are read between progress events
+const progressEventLines = 100000
+
+// EventKind tells what an Event reports
+type EventKind string
+
+// Kinds of events emitted during a run
+const (
+	// EventRunStarted is emitted when Process starts reading the input
+	EventRunStarted EventKind = "run_started"
+	// EventPartStarted is emitted when a part file is opened
+	EventPartStarted EventKind = "part_started"
+	// EventProgress is emitted every 100,000 input lines
+	EventProgress EventKind = "progress"
+	// EventPartFinished is emitted once a part has been converted to Parquet
+	EventPartFinished EventKind = "part_finished"
+	// EventPartFailed is emitted when a part fails to convert and the run continues without it
+	EventPartFailed EventKind = "part_failed"
+	// EventRunFinished is emitted when Process succeeds, with the run's statistics
+	EventRunFinished EventKind = "run_finished"
+	// EventError is emitted when Process fails, with the error it returns
+	EventError EventKind = "error"
+)
+
+// Event reports the progress of a run to library callers, the terminal UI and the control
+// socket, so they all observe runs the same way instead of parsing log messages
+type Event struct {
+	Kind EventKind `json:"kind"`
+	Time time.Time `json:"time"`
+	// Part is the part number of part events, and of the part being written for progress events
+	Part int `json:"part,omitempty"`
+	// Lines counts the input lines read so far in the run, or the lines written to the part for
+	// EventPartFinished
+	Lines int64 `json:"lines"`
+	// Bytes counts the JSONL bytes written to the part of part and progress events
+	Bytes int64 `json:"bytes"`
+	// Path is the Parquet file of EventPartFinished
+	Path string `json:"path,omitempty"`
+	// Stats are the run's statistics for EventRunFinished
+	Stats *ProcessStats `json:"stats,omitempty"`
+	// Err is the error of EventError and EventPartFailed
+	Err error `json:"-"`
+}
+
+// MarshalJSON encodes the event with its error as text
+func (e Event) MarshalJSON() ([]byte, error) {
+	type event Event
+	out := struct {
+		event
+		Error string `json:"error,omitempty"`
+	}{event: event(e)}
+	if e.Err != nil {
+		out.Error = e.Err.Error()
+	}
+	return json.Marshal(out)
+}
+
+// emit delivers an event to the OnEvent callback and the Control's subscribers
+func (s *PushshiftProcessor) emit(ev Event) {
+	ev.Time = time.Now()
+	if s.Options.OnEvent != nil {
+		s.Options.OnEvent(ev)
+	}
+	s.Options.Control.publish(ev)
+}
@@ -0,0 +1,251 @@
+package processor
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// namePattern matches names such as top-level fields (media*, *_flair_richtext) or subreddits
+// (ask*) by glob or, when written as /expr/, by regular expression
+type namePattern struct {
+	glob  string
+	regex *regexp.Regexp
+}
+
+// parseNamePatterns compiles glob and /regex/ patterns; kind names what they match in errors
+func parseNamePatterns(patterns []string, kind string) ([]namePattern, error) {
+	parsed := make([]namePattern, 0, len(patterns))
+	for _, pattern := range patterns {
+		if len(pattern) > 2 && strings.HasPrefix(pattern, "/") && strings.HasSuffix(pattern, "/") {
+			re, err := regexp.Compile(pattern[1 : len(pattern)-1])
+			if err != nil {
+				return nil, fmt.Errorf("invalid %s regex %s: %v", kind, pattern, err)
+			}
+			parsed = append(parsed, namePattern{regex: re})
+			continue
+		}
+		if _, err := path.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("invalid %s glob %s: %v", kind, pattern, err)
+		}
+		parsed = append(parsed, namePattern{glob: pattern})
+	}
+	return parsed, nil
+}
+
+// matchesAny reports whether name matches one of the patterns
+func matchesAny(patterns []namePattern, name string) bool {
+	for _, p := range patterns {
+		if p.regex != nil {
+			if p.regex.MatchString(name) {
+				return true
+			}
+		} else if ok, _ := path.Match(p.glob, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// DropFieldsTransform removes top-level fields matching any pattern, for keeping "everything
+// except" noisy nested blobs such as media or secure_media
+type DropFieldsTransform struct {
+	// fields are the patterns or names the transform was created with
+	fields   []string
+	patterns []namePattern
+	// matches caches the decision per field name; dumps only have a few hundred distinct keys
+	matches sync.Map
+}
+
+// NewDropFieldsTransform compiles the drop patterns
+func NewDropFieldsTransform(patterns []string) (*DropFieldsTransform, error) {
+	parsed, err := parseNamePatterns(patterns, "field")
+	if err != nil {
+		return nil, err
+	}
+	return &DropFieldsTransform{fields: patterns, patterns: parsed}, nil
+}
+
+// dropped reports whether a field name matches a drop pattern
+func (t *DropFieldsTransform) dropped(name string) bool {
+	if cached, ok := t.matches.Load(name); ok {
+		return cached.(bool)
+	}
+	drop := matchesAny(t.patterns, name)
+	t.matches.Store(name, drop)
+	return drop
+}
+
+// Drops reports whether the transform removes fields named name
+func (t *DropFieldsTransform) Drops(name string) bool {
+	return t.dropped(name)
+}
+
+// Apply removes the matching fields
+func (t *DropFieldsTransform) Apply(rec *Record) (bool, error) {
+	var drop []string
+	for _, key := range rec.Keys() {
+		if t.dropped(key) {
+			drop = append(drop, key)
+		}
+	}
+	for _, key := range drop {
+		rec.Delete(key)
+	}
+	return true, nil
+}
+
+// NewDropColumnsTransform removes exactly the named fields
+func NewDropColumnsTransform(names []string) *DropFieldsTransform {
+	t := &DropFieldsTransform{fields: names}
+	for _, name := range names {
+		t.matches.Store(name, true)
+	}
+	return t
+}
+
+// SelectFieldsTransform keeps only the top-level fields matching one of its patterns, for
+// outputs that need a handful of columns out of the hundred or so a submission carries
+type SelectFieldsTransform struct {
+	fields   []string
+	patterns []namePattern
+	// matches caches the decision per field name, like DropFieldsTransform
+	matches sync.Map
+}
+
+// NewSelectFieldsTransform compiles the patterns of the fields to keep
+func NewSelectFieldsTransform(patterns []string) (*SelectFieldsTransform, error) {
+	if len(patterns) == 0 {
+		return nil, fmt.Errorf("no fields to keep")
+	}
+	parsed, err := parseNamePatterns(patterns, "field")
+	if err != nil {
+		return nil, err
+	}
+	return &SelectFieldsTransform{fields: patterns, patterns: parsed}, nil
+}
+
+// Keeps reports whether the transform keeps fields named name
+func (t *SelectFieldsTransform) Keeps(name string) bool {
+	if cached, ok := t.matches.Load(name); ok {
+		return cached.(bool)
+	}
+	keep := matchesAny(t.patterns, name)
+	t.matches.Store(name, keep)
+	return keep
+}
+
+// Apply removes every field not selected. Records no earlier transform modified are cut down
+// from their raw line without decoding them.
+func (t *SelectFieldsTransform) Apply(rec *Record) (bool, error) {
+	if err := rec.project(t.Keeps); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// ColumnNullFraction is the share of sampled records where a field was absent or null
+type ColumnNullFraction struct {
+	Name     string  `json:"name"`
+	Fraction float64 `json:"fraction"`
+}
+
+// SampleNullFractions reads up to sampleSize records from the start of a zst input and returns
+// the null fraction of every top-level field seen, sorted by name
+func SampleNullFractions(inputPath string, sampleSize int) ([]ColumnNullFraction, int, error) {
+	input, err := openZstInput(inputPath, inputOptions{})
+	if err != nil {
+		return nil, 0, err
+	}
+	defer input.Close()
+
+	present := make(map[string]int)
+	sampled := 0
+	scanner := input.scanner(scannerBufferSize)
+	for sampled < sampleSize && scanner.Scan() {
+		line := scanner.Bytes()
+		if len(bytes.TrimSpace(line)) == 0 {
+			continue
+		}
+		sampled++
+		forEachField(line, func(name, raw []byte) bool {
+			if string(raw) != "null" {
+				present[string(name)]++
+			} else if _, seen := present[string(name)]; !seen {
+				present[string(name)] = 0
+			}
+			return true
+		})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, 0, fmt.Errorf("failed to sample input: %v", err)
+	}
+
+	fractions := make([]ColumnNullFraction, 0, len(present))
+	for name, n := range present {
+		fractions = append(fractions, ColumnNullFraction{Name: name, Fraction: 1 - float64(n)/float64(sampled)})
+	}
+	sort.Slice(fractions, func(i, j int) bool { return fractions[i].Name < fractions[j].Name })
+	return fractions, sampled, nil
+}
+
+// NewNullFractionTransform samples the input and drops every field whose null fraction exceeds
+// maxFraction, logging the dropped columns to logger (the standard logger when nil). A sample of
+// the same size in cache is reused.
+func NewNullFractionTransform(inputPath string, sampleSize int, maxFraction float64, cache *InputCache, logger *log.Logger) (*DropFieldsTransform, error) {
+	logger = loggerOrDefault(logger)
+	fractions, sampled, err := cachedNullFractions(inputPath, sampleSize, cache, logger)
+	if err != nil {
+		return nil, err
+	}
+
+	var dropped []string
+	for _, column := range fractions {
+		if column.Fraction > maxFraction {
+			dropped = append(dropped, column.Name)
+			logger.Printf("🕳️  Dropping column %s: %.1f%% null in %d sampled records", column.Name, column.Fraction*100, sampled)
+		}
+	}
+	logger.Printf("🕳️  Dropped %d of %d columns above %.1f%% nulls", len(dropped), len(fractions), maxFraction*100)
+	return NewDropColumnsTransform(dropped), nil
+}
+
+// cachedNullFractions samples null fractions, or reuses a sample of the same size from cache
+func cachedNullFractions(inputPath string, sampleSize int, cache *InputCache, logger *log.Logger) ([]ColumnNullFraction, int, error) {
+	if entry, ok := cache.Lookup(inputPath); ok {
+		if sample, ok := entry.NullFractions[sampleSize]; ok {
+			logger.Printf("🗃️ Using the cached schema sample of this input")
+			return sample.Fractions, sample.Records, nil
+		}
+	}
+
+	fractions, sampled, err := SampleNullFractions(inputPath, sampleSize)
+	if err != nil {
+		return nil, 0, err
+	}
+	err = cache.Update(inputPath, "", func(entry *CacheEntry) {
+		if entry.NullFractions == nil {
+			entry.NullFractions = make(map[int]NullSample)
+		}
+		entry.NullFractions[sampleSize] = NullSample{Records: sampled, Fractions: fractions}
+	})
+	if err != nil {
+		warnf(logger, "⚠️ Warning: failed to update the input cache: %v", err)
+	}
+	return fractions, sampled, nil
+}
+
+// provenance implements provenanced
+func (t *DropFieldsTransform) provenance() map[string]any {
+	return map[string]any{"drop_fields": t.fields}
+}
+
+// provenance implements provenanced
+func (t *SelectFieldsTransform) provenance() map[string]any {
+	return map[string]any{"fields": t.fields}
+}
@@ -0,0 +1,38 @@
+//go:build !unix
+
+package processor
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"time"
+)
+
+// staleLockAge is how old a lock file left behind by a process that died holding it has to be
+// before it is taken over
+const staleLockAge = time.Minute
+
+// lockFile creates path exclusively, waiting while another process holds it, and returns the
+// function removing it. Without flock, a lock file older than staleLockAge is taken over.
+func lockFile(path string) (func(), error) {
+	deadline := time.Now().Add(2 * staleLockAge)
+	for {
+		f, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+		if err == nil {
+			f.Close()
+			return func() { os.Remove(path) }, nil
+		}
+		if !errors.Is(err, os.ErrExist) {
+			return nil, err
+		}
+		if info, err := os.Stat(path); err == nil && time.Since(info.ModTime()) > staleLockAge {
+			os.Remove(path)
+			continue
+		}
+		if time.Now().After(deadline) {
+			return nil, fmt.Errorf("timed out waiting for %s", path)
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+}
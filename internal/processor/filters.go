@@ -0,0 +1,71 @@
+package processor
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ScoreFilter drops records scored below Min
+type ScoreFilter struct {
+	Min int64
+}
+
+// Apply keeps records scored at least Min, and records without a usable score
+func (f *ScoreFilter) Apply(rec *Record) (bool, error) {
+	score, ok := rec.GetInt("score")
+	return !ok || score >= f.Min, nil
+}
+
+// Name implements Named
+func (f *ScoreFilter) Name() string {
+	return "min-score"
+}
+
+// AuthorFilter keeps only records of the listed authors, ignoring case
+type AuthorFilter struct {
+	path    string
+	authors map[string]bool
+}
+
+// LoadAuthorFilter reads the authors to keep from a file of one author per line. Authors may
+// start with u/; blank lines and lines starting with # are skipped.
+func LoadAuthorFilter(path string) (*AuthorFilter, error) {
+	f := &AuthorFilter{path: path, authors: make(map[string]bool)}
+	err := readListFile(path, func(entry string) {
+		entry = strings.TrimPrefix(strings.TrimPrefix(entry, "/"), "u/")
+		f.authors[strings.ToLower(entry)] = true
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read author list: %v", err)
+	}
+	if len(f.authors) == 0 {
+		return nil, fmt.Errorf("author list %s is empty", path)
+	}
+	return f, nil
+}
+
+// Len returns the number of authors kept
+func (f *AuthorFilter) Len() int {
+	return len(f.authors)
+}
+
+// Apply drops records of other authors
+func (f *AuthorFilter) Apply(rec *Record) (bool, error) {
+	author, _ := rec.GetString("author")
+	return f.authors[strings.ToLower(author)], nil
+}
+
+// Name implements Named
+func (f *AuthorFilter) Name() string {
+	return "authors-file"
+}
+
+// provenance implements provenanced
+func (f *ScoreFilter) provenance() map[string]any {
+	return map[string]any{"min_score": f.Min}
+}
+
+// provenance implements provenanced
+func (f *AuthorFilter) provenance() map[string]any {
+	return map[string]any{"authors_file": f.path}
+}
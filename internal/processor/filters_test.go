@@ -0,0 +1,88 @@
+package processor
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestScoreFilter(t *testing.T) {
+	tests := []struct {
+		record string
+		keep   bool
+	}{
+		{`{"score":10}`, true},
+		{`{"score":5}`, true},
+		{`{"score":4}`, false},
+		{`{"score":-20}`, false},
+		{`{"score":"3"}`, false},
+		{`{"score":"7"}`, true},
+		{`{"score":null}`, true},
+		{`{"score":"hidden"}`, true},
+		{`{"ups":1}`, true},
+	}
+	f := &ScoreFilter{Min: 5}
+	for _, tt := range tests {
+		keep, err := f.Apply(NewRecord([]byte(tt.record)))
+		if err != nil || keep != tt.keep {
+			t.Errorf("%s: got %v, %v, want %v", tt.record, keep, err, tt.keep)
+		}
+	}
+}
+
+func TestAuthorFilter(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "authors.txt")
+	list := "# moderators\nSpez\n\nu/kn0thing\n/u/Alice\n"
+	if err := os.WriteFile(path, []byte(list), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	f, err := LoadAuthorFilter(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f.Len() != 3 {
+		t.Errorf("got %d authors, want 3", f.Len())
+	}
+
+	tests := []struct {
+		record string
+		keep   bool
+	}{
+		{`{"author":"spez"}`, true},
+		{`{"author":"SPEZ"}`, true},
+		{`{"author":"kn0thing"}`, true},
+		{`{"author":"alice"}`, true},
+		{`{"author":"u/kn0thing"}`, false},
+		{`{"author":"bob"}`, false},
+		{`{"author":"[deleted]"}`, false},
+		{`{"author":null}`, false},
+		{`{"subreddit":"spez"}`, false},
+	}
+	for _, tt := range tests {
+		keep, err := f.Apply(NewRecord([]byte(tt.record)))
+		if err != nil || keep != tt.keep {
+			t.Errorf("%s: got %v, %v, want %v", tt.record, keep, err, tt.keep)
+		}
+	}
+}
+
+func TestLoadAuthorFilterErrors(t *testing.T) {
+	dir := t.TempDir()
+	empty := filepath.Join(dir, "empty.txt")
+	if err := os.WriteFile(empty, []byte("# nobody\n\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		path    string
+		wantErr string
+	}{
+		{empty, "is empty"},
+		{filepath.Join(dir, "missing.txt"), "failed to read author list"},
+	}
+	for _, tt := range tests {
+		if _, err := LoadAuthorFilter(tt.path); err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+			t.Errorf("%s: got error %v, want %q", tt.path, err, tt.wantErr)
+		}
+	}
+}
@@ -0,0 +1,333 @@
+package processor
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"slices"
+	"sync"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// zstd magic numbers
+const (
+	zstdFrameMagic         = 0xFD2FB528
+	zstdSkippableMagicMask = 0xFFFFFFF0
+	zstdSkippableMagic     = 0x184D2A50
+)
+
+// frameReader passes a stream of concatenated zstd frames through unchanged, except for zero
+// bytes between frames, which some mirrors use as padding and the decoder rejects. It parses
+// frame and block headers to find where each frame ends, so anything else between frames is
+// reported with its offset instead of being mistaken for the end of the data.
+type frameReader struct {
+	r      *bufio.Reader
+	logger *log.Logger
+	// offset is the position in the compressed stream
+	offset int64
+	// remaining is the number of bytes left in the current block or skippable frame
+	remaining int64
+	// lastBlock is set once the current frame's last block header has been read
+	lastBlock bool
+	// trailer is the size of the content checksum following the last block
+	trailer int64
+	inFrame bool
+	frames  int64
+	// frameStart is the offset of the current frame, and frameContent its declared content size
+	frameStart   int64
+	frameContent int64
+	// checksummed counts the data frames carrying a content checksum
+	checksummed int64
+	// spans indexes the data frames passed through so far
+	spans []frameSpan
+	// mu guards the position against readers locating a corrupt frame while the decoder
+	// reads on its own goroutine
+	mu sync.Mutex
+}
+
+// newFrameReader wraps a compressed stream
+func newFrameReader(r io.Reader, logger *log.Logger) *frameReader {
+	return &frameReader{r: bufio.NewReaderSize(r, 256*1024), logger: logger}
+}
+
+// Read implements io.Reader
+func (f *frameReader) Read(p []byte) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for f.remaining == 0 {
+		if err := f.next(); err != nil {
+			return 0, err
+		}
+	}
+	n, err := f.r.Read(p[:min(int64(len(p)), f.remaining)])
+	f.remaining -= int64(n)
+	f.offset += int64(n)
+	if err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	return n, err
+}
+
+// next positions the reader at the next run of pass-through bytes, returning io.EOF at the end
+// of the stream. Headers are passed through as well, so next returns them as the next run.
+func (f *frameReader) next() error {
+	if f.inFrame {
+		switch {
+		case !f.lastBlock:
+			return f.blockHeader()
+		case f.trailer > 0:
+			f.remaining, f.trailer = f.trailer, 0
+			return nil
+		default:
+			f.inFrame = false
+			f.spans = append(f.spans, frameSpan{Offset: f.frameStart, Size: f.offset - f.frameStart, Content: f.frameContent})
+		}
+	}
+
+	// Between frames: skip zero padding, then expect a frame magic number
+	skipped := int64(0)
+	for {
+		b, err := f.r.ReadByte()
+		if err == io.EOF {
+			f.offset += skipped
+			f.logPadding(skipped)
+			return io.EOF
+		}
+		if err != nil {
+			return err
+		}
+		if b != 0 {
+			f.r.UnreadByte()
+			break
+		}
+		skipped++
+	}
+	f.offset += skipped
+	f.logPadding(skipped)
+
+	header, err := f.r.Peek(4)
+	if err != nil {
+		return fmt.Errorf("truncated zstd frame header at offset %d", f.offset)
+	}
+	magic := binary.LittleEndian.Uint32(header)
+	switch {
+	case magic == zstdFrameMagic:
+		return f.frameHeader()
+	case magic&zstdSkippableMagicMask == zstdSkippableMagic:
+		sized, err := f.r.Peek(8)
+		if err != nil {
+			return fmt.Errorf("truncated skippable frame at offset %d", f.offset)
+		}
+		f.frames++
+		f.remaining = 8 + int64(binary.LittleEndian.Uint32(sized[4:]))
+		return nil
+	default:
+		return fmt.Errorf("unexpected data at offset %d of the compressed input: not a zstd frame", f.offset)
+	}
+}
+
+// frameHeader reads the size of a frame header and passes it through
+func (f *frameReader) frameHeader() error {
+	header, err := f.r.Peek(5)
+	if err != nil {
+		return fmt.Errorf("truncated zstd frame header at offset %d", f.offset)
+	}
+	f.frames++
+	f.inFrame = true
+	f.frameStart = f.offset
+	f.lastBlock = false
+	f.remaining, f.trailer = frameHeaderSize(header[4])
+	if f.trailer > 0 {
+		f.checksummed++
+	}
+	f.frameContent = 0
+	if full, err := f.r.Peek(int(f.remaining)); err == nil {
+		f.frameContent = frameContentSize(full)
+	}
+	return nil
+}
+
+// frameContentSize returns the decompressed size a frame header declares, or 0 when it declares
+// none
+func frameContentSize(header []byte) int64 {
+	descriptor := header[4]
+	singleSegment := descriptor&0x20 != 0
+	pos := 5
+	if !singleSegment {
+		pos++ // window descriptor
+	}
+	pos += [4]int{0, 1, 2, 4}[descriptor&0x03] // dictionary ID
+	switch fcs := descriptor >> 6; {
+	case fcs == 0 && singleSegment:
+		return int64(header[pos])
+	case fcs == 1:
+		return int64(binary.LittleEndian.Uint16(header[pos:])) + 256
+	case fcs == 2:
+		return int64(binary.LittleEndian.Uint32(header[pos:]))
+	case fcs == 3:
+		return int64(binary.LittleEndian.Uint64(header[pos:]))
+	}
+	return 0
+}
+
+// frameHeaderSize returns the size of a frame header, including the magic number, and of the
+// content checksum trailing the frame, from the header's frame descriptor byte
+func frameHeaderSize(descriptor byte) (size, trailer int64) {
+	size = 5
+	singleSegment := descriptor&0x20 != 0
+	if !singleSegment {
+		size++ // window descriptor
+	}
+	size += [4]int64{0, 1, 2, 4}[descriptor&0x03] // dictionary ID
+	switch fcs := descriptor >> 6; {
+	case fcs == 0 && singleSegment:
+		size++
+	case fcs > 0:
+		size += [4]int64{0, 2, 4, 8}[fcs]
+	}
+	if descriptor&0x04 != 0 {
+		trailer = 4
+	}
+	return size, trailer
+}
+
+// blockHeader peeks at the next block header and passes the header and block through
+func (f *frameReader) blockHeader() error {
+	header, err := f.r.Peek(3)
+	if err != nil {
+		return fmt.Errorf("truncated zstd block header at offset %d", f.offset)
+	}
+	size, last, ok := blockSize(header)
+	if !ok {
+		return fmt.Errorf("corrupt zstd block header at offset %d", f.offset)
+	}
+	f.lastBlock = last
+	f.remaining = size
+	return nil
+}
+
+// blockSize returns the size of a block including its 3-byte header and whether it is the last
+// block of its frame; ok is false for the reserved block type
+func blockSize(header []byte) (size int64, last, ok bool) {
+	h := uint32(header[0]) | uint32(header[1])<<8 | uint32(header[2])<<16
+	size = int64(h >> 3)
+	switch (h >> 1) & 3 {
+	case 1: // RLE blocks store a single byte
+		size = 1
+	case 3:
+		return 0, false, false
+	}
+	return 3 + size, h&1 != 0, true
+}
+
+// logPadding reports skipped padding bytes
+func (f *frameReader) logPadding(skipped int64) {
+	if skipped > 0 {
+		f.logger.Printf("🧩 Skipped %d zero bytes of padding after zstd frame %d", skipped, f.frames)
+	}
+}
+
+// logChecksums reports how many of the data frames read had their checksums verified
+func (f *frameReader) logChecksums() {
+	if f == nil {
+		return
+	}
+	frames := int64(len(f.spans))
+	switch {
+	case frames == 0:
+	case f.checksummed == frames:
+		f.logger.Printf("🔐 Verified the content checksums of all %d zstd frames", frames)
+	default:
+		f.logger.Printf("🔐 Verified the content checksums of %d of %d zstd frames; the others carry none, so damage in them goes undetected",
+			f.checksummed, frames)
+	}
+}
+
+// frameAt locates the data frame holding decompressed offset off among the frames passed through
+// so far. It returns the frame's span, its position counting from 0 and the decompressed offset
+// it starts at; ok is false unless every frame before it declared its content size.
+func (f *frameReader) frameAt(off int64) (span frameSpan, index int, start int64, ok bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for i, s := range f.spans {
+		if s.Content <= 0 {
+			return span, 0, 0, false
+		}
+		if off < start+s.Content {
+			return s, i, start, true
+		}
+		start += s.Content
+	}
+	if !f.inFrame {
+		return span, 0, 0, false
+	}
+	return frameSpan{Offset: f.frameStart, Content: f.frameContent}, len(f.spans), start, true
+}
+
+// readSpans returns the data frames passed through so far, including the current one once it
+// has been read completely
+func (f *frameReader) readSpans() []frameSpan {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	spans := slices.Clone(f.spans)
+	if f.inFrame && f.lastBlock && f.trailer == 0 && f.remaining == 0 {
+		spans = append(spans, frameSpan{Offset: f.frameStart, Size: f.offset - f.frameStart, Content: f.frameContent})
+	}
+	return spans
+}
+
+// frameCheckReader passes decompressed data through and replaces the decoder's checksum and
+// frame size errors, which don't say where the damage is, with an ErrFrameCorrupt locating the
+// frame. The frames read so far are decoded again one at a time, newest first, since the
+// damaged one is usually among the last.
+type frameCheckReader struct {
+	r      io.Reader
+	frames *frameReader
+	// source is the compressed input as read by frames, starting at base in the file
+	source io.ReaderAt
+	base   int64
+}
+
+// Read implements io.Reader
+func (c *frameCheckReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	if err != nil && (errors.Is(err, zstd.ErrCRCMismatch) || errors.Is(err, zstd.ErrFrameSizeMismatch)) {
+		err = c.locate(err)
+	}
+	return n, err
+}
+
+// locate finds the frame failing verification, returning err unchanged when it can't be found,
+// e.g. because the input is a pipe
+func (c *frameCheckReader) locate(err error) error {
+	if c.source == nil {
+		return err
+	}
+	spans := c.frames.readSpans()
+	for i := len(spans) - 1; i >= 0; i-- {
+		frameErr := verifyFrame(c.source, spans[i])
+		if frameErr == nil {
+			continue
+		}
+		if !errors.Is(frameErr, zstd.ErrCRCMismatch) && !errors.Is(frameErr, zstd.ErrFrameSizeMismatch) {
+			return err
+		}
+		return &ErrFrameCorrupt{Frame: i + 1, Offset: c.base + spans[i].Offset, Size: spans[i].Size, Err: frameErr}
+	}
+	return err
+}
+
+// verifyFrame decodes one frame on its own, returning the decoder's error
+func verifyFrame(r io.ReaderAt, span frameSpan) error {
+	zr, err := zstd.NewReader(io.NewSectionReader(r, span.Offset, span.Size), zstd.WithDecoderConcurrency(1))
+	if err != nil {
+		return err
+	}
+	defer zr.Close()
+	_, err = io.Copy(io.Discard, zr)
+	return err
+}
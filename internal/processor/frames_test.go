@@ -0,0 +1,249 @@
+package processor
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"log"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// testFrames builds a stream of zstd frames from the given pieces: content to compress as a
+// frame, "pad:" for a zero byte per character after the colon, or "skip:" for a skippable frame
+// of a byte per character. Frames carry checksums when asked to, and declare their content size
+// when sized. It returns the stream, the decompressed content and the spans of the data frames.
+func testFrames(t *testing.T, checksums, sized bool, pieces ...string) ([]byte, string, []frameSpan) {
+	t.Helper()
+	encoder, err := zstd.NewWriter(nil, zstd.WithEncoderCRC(checksums), zstd.WithSingleSegment(sized))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var stream bytes.Buffer
+	var content strings.Builder
+	var spans []frameSpan
+	for _, piece := range pieces {
+		switch {
+		case strings.HasPrefix(piece, "pad:"):
+			stream.Write(make([]byte, len(strings.TrimPrefix(piece, "pad:"))))
+		case strings.HasPrefix(piece, "skip:"):
+			n := len(strings.TrimPrefix(piece, "skip:"))
+			stream.Write(binary.LittleEndian.AppendUint32(nil, zstdSkippableMagic+3))
+			stream.Write(binary.LittleEndian.AppendUint32(nil, uint32(n)))
+			stream.Write(bytes.Repeat([]byte{0xAA}, n))
+		default:
+			frame := encoder.EncodeAll([]byte(piece), nil)
+			span := frameSpan{Offset: int64(stream.Len()), Size: int64(len(frame))}
+			if sized {
+				span.Content = int64(len(piece))
+			}
+			spans = append(spans, span)
+			stream.Write(frame)
+			content.WriteString(piece)
+		}
+	}
+	return stream.Bytes(), content.String(), spans
+}
+
+func TestFrameReader(t *testing.T) {
+	tests := []struct {
+		name             string
+		checksums, sized bool
+		pieces           []string
+	}{
+		{"one frame", true, true, []string{"a\nb\n"}},
+		{"several frames", true, true, []string{"first\n", "second line\n", "third\n"}},
+		{"without checksums", false, true, []string{"first\n", "second\n"}},
+		{"without content sizes", true, false, []string{"first\n", "second\n"}},
+		{"padding between frames", true, true, []string{"first\n", "pad:xxxx", "second\n", "pad:x"}},
+		{"skippable frames", true, true, []string{"skip:xxxxxxxx", "first\n", "skip:", "second\n"}},
+		{"large frames", false, true, []string{strings.Repeat("{\"id\":\"abc\"}\n", 20000), "next\n"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stream, content, spans := testFrames(t, tt.checksums, tt.sized, tt.pieces...)
+			frames := newFrameReader(bytes.NewReader(stream), log.New(io.Discard, "", 0))
+			passed, err := io.ReadAll(frames)
+			if err != nil {
+				t.Fatal(err)
+			}
+			// Padding is dropped and everything else passed through
+			decoder, err := zstd.NewReader(bytes.NewReader(passed))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer decoder.Close()
+			decoded, err := io.ReadAll(decoder)
+			if err != nil || string(decoded) != content {
+				t.Fatalf("decoded %d bytes (%v), want %d", len(decoded), err, len(content))
+			}
+			if got := frames.readSpans(); !reflect.DeepEqual(got, spans) {
+				t.Errorf("got spans %+v, want %+v", got, spans)
+			}
+			if want := int64(len(spans)); tt.checksums && frames.checksummed != want || !tt.checksums && frames.checksummed != 0 {
+				t.Errorf("got %d checksummed frames", frames.checksummed)
+			}
+
+			indexed, err := indexFrames(bytes.NewReader(stream), int64(len(stream)))
+			if err != nil {
+				t.Fatal(err)
+			}
+			// The index only reads headers, so it leaves out the content sizes
+			var want []frameSpan
+			for _, span := range spans {
+				want = append(want, frameSpan{Offset: span.Offset, Size: span.Size})
+			}
+			if !reflect.DeepEqual(indexed, want) {
+				t.Errorf("indexed %+v, want %+v", indexed, want)
+			}
+		})
+	}
+}
+
+func TestFrameAt(t *testing.T) {
+	stream, _, spans := testFrames(t, true, true, "aaaa\n", "bb\n", "cccccc\n")
+	frames := newFrameReader(bytes.NewReader(stream), log.New(io.Discard, "", 0))
+	if _, err := io.ReadAll(frames); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		off   int64
+		index int
+		start int64
+		ok    bool
+	}{
+		{0, 0, 0, true},
+		{4, 0, 0, true},
+		{5, 1, 5, true},
+		{7, 1, 5, true},
+		{8, 2, 8, true},
+		{14, 2, 8, true},
+		{15, 0, 0, false},
+	}
+	for _, tt := range tests {
+		span, index, start, ok := frames.frameAt(tt.off)
+		if ok != tt.ok || index != tt.index || start != tt.start || (ok && span != spans[index]) {
+			t.Errorf("offset %d: got frame %d at %d (%v), want frame %d at %d (%v)", tt.off, index, start, ok, tt.index, tt.start, tt.ok)
+		}
+	}
+}
+
+func TestFrameAtUnsized(t *testing.T) {
+	stream, _, _ := testFrames(t, true, false, "aaaa\n", "bb\n")
+	frames := newFrameReader(bytes.NewReader(stream), log.New(io.Discard, "", 0))
+	if _, err := io.ReadAll(frames); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, _, ok := frames.frameAt(6); ok {
+		t.Error("located a frame after one without a declared content size")
+	}
+}
+
+func TestFrameReaderInvalid(t *testing.T) {
+	valid, _, _ := testFrames(t, true, true, "first\n")
+	tests := []struct {
+		name   string
+		stream []byte
+		want   string
+	}{
+		{"garbage after a frame", append(append([]byte{}, valid...), "junk"...), "unexpected data at offset"},
+		{"truncated frame", valid[:len(valid)-3], "unexpected EOF"},
+		{"truncated header", valid[:3], "truncated zstd frame header"},
+		{"truncated skippable frame", []byte{0x50, 0x2A, 0x4D, 0x18, 0x01}, "truncated skippable frame"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := io.ReadAll(newFrameReader(bytes.NewReader(tt.stream), log.New(io.Discard, "", 0)))
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("got %v, want %q", err, tt.want)
+			}
+			if _, err := indexFrames(bytes.NewReader(tt.stream), int64(len(tt.stream))); err == nil {
+				t.Error("indexFrames accepted the stream")
+			}
+		})
+	}
+}
+
+func TestFrameHeaderSize(t *testing.T) {
+	tests := []struct {
+		name            string
+		descriptor      byte
+		size, trailer   int64
+		contentSizeSize int
+	}{
+		{"window only", 0x00, 6, 0, 0},
+		{"single segment", 0x20, 6, 0, 1},
+		{"checksum", 0x04, 6, 4, 0},
+		{"2-byte content size", 0x40, 8, 0, 2},
+		{"4-byte content size", 0x80, 10, 0, 4},
+		{"8-byte content size single segment", 0xE0, 13, 0, 8},
+		{"4-byte dictionary", 0x03, 10, 0, 0},
+		{"everything", 0xE7, 17, 4, 8},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			size, trailer := frameHeaderSize(tt.descriptor)
+			if size != tt.size || trailer != tt.trailer {
+				t.Errorf("got %d and %d, want %d and %d", size, trailer, tt.size, tt.trailer)
+			}
+			if tt.contentSizeSize == 0 {
+				return
+			}
+			// A declared content size of 300 reads back from the header
+			header := make([]byte, size)
+			binary.LittleEndian.PutUint32(header, zstdFrameMagic)
+			header[4] = tt.descriptor
+			field := header[size-int64(tt.contentSizeSize):]
+			switch tt.contentSizeSize {
+			case 1:
+				field[0] = 200
+			case 2:
+				binary.LittleEndian.PutUint16(field, 300-256)
+			case 4:
+				binary.LittleEndian.PutUint32(field, 300)
+			case 8:
+				binary.LittleEndian.PutUint64(field, 300)
+			}
+			want := int64(300)
+			if tt.contentSizeSize == 1 {
+				want = 200
+			}
+			if got := frameContentSize(header); got != want {
+				t.Errorf("got content size %d, want %d", got, want)
+			}
+		})
+	}
+}
+
+func TestBlockSize(t *testing.T) {
+	block := func(size uint32, blockType uint32, last bool) []byte {
+		h := size<<3 | blockType<<1
+		if last {
+			h |= 1
+		}
+		return []byte{byte(h), byte(h >> 8), byte(h >> 16)}
+	}
+	tests := []struct {
+		name   string
+		header []byte
+		size   int64
+		last   bool
+		ok     bool
+	}{
+		{"raw", block(100, 0, false), 103, false, true},
+		{"last raw", block(100, 0, true), 103, true, true},
+		{"rle stores one byte", block(5000, 1, true), 4, true, true},
+		{"compressed", block(70000, 2, false), 70003, false, true},
+		{"empty", block(0, 0, true), 3, true, true},
+		{"reserved", block(10, 3, true), 0, false, false},
+	}
+	for _, tt := range tests {
+		size, last, ok := blockSize(tt.header)
+		if size != tt.size || last != tt.last || ok != tt.ok {
+			t.Errorf("%s: got %d, %v, %v, want %d, %v, %v", tt.name, size, last, ok, tt.size, tt.last, tt.ok)
+		}
+	}
+}
@@ -0,0 +1,33 @@
+//go:build linux
+
+package processor
+
+import (
+	"errors"
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// dataExtent reports whether offset lies in a written region of a sparse file and where that
+// region ends. ok is false when the filesystem can't tell, in which case all data is assumed
+// present.
+func dataExtent(f *os.File, offset int64) (present bool, end int64, ok bool) {
+	fd := int(f.Fd())
+	start, err := unix.Seek(fd, offset, unix.SEEK_DATA)
+	if errors.Is(err, unix.ENXIO) {
+		// No data at or after offset: a hole reaching the end of the file, or offset is at the end
+		return false, 0, true
+	}
+	if err != nil {
+		return false, 0, false
+	}
+	if start > offset {
+		return false, 0, true
+	}
+	end, err = unix.Seek(fd, offset, unix.SEEK_HOLE)
+	if err != nil {
+		return false, 0, false
+	}
+	return true, end, true
+}
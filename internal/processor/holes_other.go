@@ -0,0 +1,10 @@
+//go:build !linux
+
+package processor
+
+import "os"
+
+// dataExtent cannot detect holes without SEEK_DATA, so all data is assumed present
+func dataExtent(f *os.File, offset int64) (present bool, end int64, ok bool) {
+	return false, 0, false
+}
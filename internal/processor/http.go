@@ -0,0 +1,89 @@
+package processor
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// sendJSON sends a JSON body and returns the response body. Network errors, 429 and 5xx
+// responses are retried up to retries times with exponential backoff.
+func sendJSON(client *http.Client, method, url string, headers map[string]string, body []byte, retries int) ([]byte, error) {
+	var lastErr error
+	for attempt := 0; attempt <= retries; attempt++ {
+		if attempt > 0 {
+			time.Sleep(time.Duration(1<<(attempt-1)) * time.Second)
+		}
+
+		req, err := http.NewRequest(method, url, bytes.NewReader(body))
+		if err != nil {
+			return nil, fmt.Errorf("invalid request to %s: %v", url, err)
+		}
+		req.Header.Set("Content-Type", "application/json")
+		for name, value := range headers {
+			req.Header.Set(name, value)
+		}
+
+		resp, err := client.Do(req)
+		if err != nil {
+			lastErr = fmt.Errorf("request to %s failed: %v", url, err)
+			continue
+		}
+		data, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			lastErr = fmt.Errorf("failed to read response from %s: %v", url, err)
+			continue
+		}
+		if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+			return data, nil
+		}
+
+		lastErr = fmt.Errorf("%s returned %s: %s", url, resp.Status, bytes.TrimSpace(data))
+		if resp.StatusCode < 500 && resp.StatusCode != http.StatusTooManyRequests {
+			break
+		}
+	}
+	return nil, lastErr
+}
+
+// forEachBatch splits n items into batches of batchSize and calls fn for each batch on up to
+// concurrency goroutines. It returns the first error encountered.
+func forEachBatch(n, batchSize, concurrency int, fn func(start, end int) error) error {
+	type batch struct{ start, end int }
+	batches := make(chan batch)
+	errs := make(chan error, concurrency)
+
+	var wg sync.WaitGroup
+	for w := 0; w < concurrency; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for b := range batches {
+				if err := fn(b.start, b.end); err != nil {
+					errs <- err
+					// Drain remaining work so the producer doesn't block
+					for range batches {
+					}
+					return
+				}
+			}
+		}()
+	}
+
+	for start := 0; start < n; start += batchSize {
+		batches <- batch{start, min(start+batchSize, n)}
+	}
+	close(batches)
+	wg.Wait()
+
+	select {
+	case err := <-errs:
+		return err
+	default:
+		return nil
+	}
+}
@@ -0,0 +1,321 @@
+package processor
+
+import (
+	"archive/tar"
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"log"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// zstInput wraps an open zst file and its decompressor so both can be closed together
+type zstInput struct {
+	file         io.Closer
+	prefetch     *prefetchReader
+	frames       *frameReader
+	zr           *zstd.Decoder
+	hasher       hash.Hash
+	compressed   *timedReader
+	decompressed *timedReader
+	lines        *lineSplitter
+	// hashSource is read into the hasher when the checksum is first needed, for archives that
+	// aren't read as a stream
+	hashSource io.Reader
+	// members reads the members of an archive input, nil for other inputs
+	members *archiveMembers
+	// startOffset is where reading began after seeking for a start time or a checkpoint
+	startOffset int64
+	// lineBase is the number of input lines before the first line read, for inputs entered at a
+	// checkpoint
+	lineBase int64
+	// countedLines is the last input line the statistics of a resumed run already count
+	countedLines int64
+	io.Reader
+}
+
+// SHA256 returns the hex checksum of the compressed bytes read so far.
+// Once the decompressed stream has reached EOF this is the checksum of the whole input file.
+// It is empty when reading started partway through the file, as no checksum of the file exists.
+// Zip and 7z archives are hashed in a separate pass on the first call. Parquet inputs have no
+// checksum either.
+func (in *zstInput) SHA256() string {
+	if in.startOffset > 0 || in.hasher == nil {
+		return ""
+	}
+	if in.hashSource != nil {
+		if _, err := io.Copy(in.hasher, in.hashSource); err != nil {
+			return ""
+		}
+		in.hashSource = nil
+	}
+	return hex.EncodeToString(in.hasher.Sum(nil))
+}
+
+// addTelemetry records the input's read and decompression counters in t
+func (in *zstInput) addTelemetry(t *StageTelemetry) {
+	t.CompressedBytes += in.compressed.bytes.Load()
+	t.ReadTime += time.Duration(in.compressed.nanos.Load())
+	t.DecompressedBytes += in.decompressed.bytes.Load()
+	t.DecompressTime += time.Duration(in.decompressed.nanos.Load())
+}
+
+// Members returns the archive members read so far, or nil when the input is not an archive
+func (in *zstInput) Members() []MemberInfo {
+	if in.members == nil {
+		return nil
+	}
+	return in.members.members
+}
+
+// Close releases the decompressor and the underlying file
+func (in *zstInput) Close() error {
+	if in.zr != nil {
+		in.zr.Close()
+	}
+	if in.members != nil {
+		in.members.Close()
+	}
+	if in.prefetch != nil {
+		in.prefetch.Close()
+	}
+	return in.file.Close()
+}
+
+// inputOptions configures how the compressed input is read
+type inputOptions struct {
+	readAhead   ReadAhead
+	ioHints     bool
+	lineEndings string
+	waitForData time.Duration
+	startAt     time.Time
+	// resumeAt, when set, starts reading at a checkpoint's position instead of seeking for startAt
+	resumeAt *resumePoint
+	cache    *InputCache
+	logger   *log.Logger
+}
+
+// inputOptions returns the input settings of the processor's options
+func (s *PushshiftProcessor) inputOptions() inputOptions {
+	return inputOptions{readAhead: s.Options.ReadAhead, ioHints: s.Options.IOHints, lineEndings: s.Options.LineEndings, waitForData: s.Options.WaitForData, startAt: s.Options.StartAt, cache: s.Options.Cache, logger: s.logger()}
+}
+
+// openZstInput opens a zst file and returns a buffered reader over its decompressed content,
+// waiting for missing data, seeking to a start time, prefetching the compressed file and advising
+// the page cache as configured. A Parquet dataset is read through DuckDB instead.
+func openZstInput(inputPath string, opts inputOptions) (*zstInput, error) {
+	if IsParquetDataset(inputPath) {
+		return openParquetInput(inputPath, opts)
+	}
+	logger := loggerOrDefault(opts.logger)
+	chunks, err := SplitChunks(inputPath)
+	if err != nil {
+		return nil, err
+	}
+	archive := archiveKind(inputPath)
+	if chunks != nil {
+		archive = archiveKind(strings.TrimSuffix(chunks[0], path.Ext(chunks[0])))
+	}
+
+	// A split input is read through its chunks joined together, and has no single file for I/O
+	// hints
+	var inputFile io.Closer
+	var raw io.ReaderAt
+	var sequential io.Reader
+	var osFile *os.File
+	var size int64
+	if chunks != nil {
+		var wrap func(*os.File) io.ReaderAt
+		if opts.waitForData > 0 {
+			wrap = func(f *os.File) io.ReaderAt {
+				return &tolerantFile{f: f, maxWait: opts.waitForData, logger: logger}
+			}
+		}
+		joined, err := openChunkedFile(chunks, wrap)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open input file: %v", err)
+		}
+		logger.Printf("🧩 Reading %s as %d chunks joined together", inputPath, len(chunks))
+		if opts.ioHints {
+			warnf(logger, "⚠️ Warning: I/O hints are not applied to inputs split into chunks")
+			opts.ioHints = false
+		}
+		inputFile, raw, sequential, size = joined, joined, joined, joined.size
+	} else {
+		osFile, err = os.Open(inputPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open input file: %v", err)
+		}
+		info, err := osFile.Stat()
+		if err != nil {
+			osFile.Close()
+			return nil, fmt.Errorf("failed to stat input file: %v", err)
+		}
+		inputFile, raw, sequential, size = osFile, osFile, osFile, info.Size()
+		if opts.waitForData > 0 {
+			raw = &tolerantFile{f: osFile, maxWait: opts.waitForData, logger: logger}
+		}
+	}
+	// Zip and 7z archives aren't read as a stream of compressed bytes: a zip archive's directory
+	// is at its end, and 7z archives are extracted by the 7-Zip tool
+	if archive == archiveZip || archive == archive7z {
+		return openArchiveInput(inputPath, archive, chunks, inputFile, osFile, raw, size, opts, logger)
+	}
+
+	// An archive can't be entered mid-stream, so its records before the start time are only
+	// filtered out
+	startOffset := int64(0)
+	if opts.resumeAt != nil && archive == "" {
+		startOffset = opts.resumeAt.offset
+	} else if !opts.startAt.IsZero() && archive == "" {
+		var frames []frameSpan
+		if entry, ok := opts.cache.Lookup(inputPath); ok {
+			frames = entry.Frames
+		}
+		if startOffset, err = seekStartAt(logger, raw, size, opts.startAt, frames); err != nil {
+			inputFile.Close()
+			return nil, err
+		}
+	}
+	// Plain sequential reads keep pipes working as inputs
+	source := sequential
+	if opts.waitForData > 0 || startOffset > 0 {
+		source = io.NewSectionReader(raw, startOffset, size-startOffset)
+	}
+
+	var prefetch *prefetchReader
+	if opts.readAhead.Chunks > 0 {
+		prefetch = newPrefetchReader(io.NewSectionReader(raw, startOffset, size-startOffset), size-startOffset, opts.readAhead, logger)
+		source = prefetch
+	}
+	if opts.ioHints {
+		adviseSequential(osFile)
+		source = &dropBehindReader{r: source, f: osFile, offset: startOffset, dropped: startOffset}
+	}
+
+	// Hash the compressed bytes as they stream past so provenance needs no second pass. The frame
+	// reader lets the decoder continue across padded concatenated streams. A plain tar archive
+	// skips decompression; its members may be compressed on their own.
+	hasher := sha256.New()
+	compressed := &timedReader{r: source}
+	var content io.Reader = io.TeeReader(compressed, hasher)
+	var frames *frameReader
+	var zr *zstd.Decoder
+	if archive != archiveTar {
+		frames = newFrameReader(content, logger)
+		if zr, err = zstd.NewReader(frames); err != nil {
+			if prefetch != nil {
+				prefetch.Close()
+			}
+			inputFile.Close()
+			return nil, fmt.Errorf("failed to create zstd reader: %v", err)
+		}
+
+		// Frames that fail their checksum are located in the input, which needs random access
+		check := &frameCheckReader{r: zr, frames: frames, base: startOffset}
+		seekable := osFile == nil
+		if !seekable {
+			_, err := osFile.Seek(0, io.SeekCurrent)
+			seekable = err == nil
+		}
+		if seekable {
+			check.source = io.NewSectionReader(raw, startOffset, size-startOffset)
+		}
+		content = check
+	}
+	var members *archiveMembers
+	if archive != "" {
+		members = newArchiveMembers(&tarIterator{tr: tar.NewReader(content)}, logger)
+		content = members
+	}
+	decompressed := &timedReader{r: content}
+	reader := bufio.NewReaderSize(decompressed, bufferSize)
+	if startOffset > 0 {
+		var err error
+		if opts.resumeAt != nil && opts.resumeAt.discard > 0 {
+			_, err = io.CopyN(io.Discard, reader, opts.resumeAt.discard)
+		}
+		if err == nil && (opts.resumeAt == nil || !opts.resumeAt.lineStart) {
+			err = skipPartialLine(reader)
+		}
+		if err != nil && err != io.EOF {
+			zr.Close()
+			if prefetch != nil {
+				prefetch.Close()
+			}
+			inputFile.Close()
+			return nil, fmt.Errorf("failed to read input: %v", err)
+		}
+	}
+	return &zstInput{
+		file:         inputFile,
+		prefetch:     prefetch,
+		frames:       frames,
+		zr:           zr,
+		hasher:       hasher,
+		compressed:   compressed,
+		decompressed: decompressed,
+		lines:        &lineSplitter{loneCR: opts.lineEndings != LineEndingsLF, logger: logger},
+		members:      members,
+		startOffset:  startOffset,
+		Reader:       reader,
+	}, nil
+}
+
+// openArchiveInput reads the JSON lines members of a zip or 7z archive
+func openArchiveInput(inputPath, archive string, chunks []string, inputFile io.Closer, osFile *os.File, raw io.ReaderAt, size int64, opts inputOptions, logger *log.Logger) (*zstInput, error) {
+	if opts.readAhead.Chunks > 0 || opts.ioHints {
+		warnf(logger, "⚠️ Warning: Read-ahead and I/O hints are not applied to %s archives", archive)
+	}
+	compressed := &timedReader{r: io.NewSectionReader(raw, 0, size)}
+	var files memberIterator
+	var err error
+	if archive == archiveZip {
+		if osFile != nil {
+			if _, err := osFile.Seek(0, io.SeekCurrent); err != nil {
+				inputFile.Close()
+				return nil, fmt.Errorf("zip archives need random access, which %s doesn't allow: %v", inputPath, err)
+			}
+		}
+		files, err = newZipIterator(compressed, size)
+	} else {
+		// The tool finds the later volumes of a split archive itself
+		archivePath := inputPath
+		if chunks != nil {
+			archivePath = chunks[0]
+		}
+		files, err = newSevenZipIterator(archivePath)
+	}
+	if err != nil {
+		inputFile.Close()
+		return nil, err
+	}
+
+	members := newArchiveMembers(files, logger)
+	decompressed := &timedReader{r: members}
+	return &zstInput{
+		file:         inputFile,
+		hasher:       sha256.New(),
+		hashSource:   io.NewSectionReader(raw, 0, size),
+		compressed:   compressed,
+		decompressed: decompressed,
+		lines:        &lineSplitter{loneCR: opts.lineEndings != LineEndingsLF, logger: logger},
+		members:      members,
+		Reader:       bufio.NewReaderSize(decompressed, bufferSize),
+	}, nil
+}
+
+// newLineScanner creates a scanner that can handle JSON lines up to maxLineSize bytes
+func newLineScanner(r io.Reader, maxLineSize int) *bufio.Scanner {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), maxLineSize)
+	return scanner
+}
@@ -0,0 +1,50 @@
+package processor
+
+import (
+	"io"
+	"log"
+	"os"
+)
+
+// dropBehindInterval is how much input is read between page cache drops
+const dropBehindInterval = 64 * 1024 * 1024
+
+// dropBehindReader drops input pages from the page cache once they have been consumed, so a
+// multi-hundred-GB read doesn't evict everything else cached on a shared host
+type dropBehindReader struct {
+	r       io.Reader
+	f       *os.File
+	offset  int64
+	dropped int64
+}
+
+// Read implements io.Reader
+func (d *dropBehindReader) Read(p []byte) (int, error) {
+	n, err := d.r.Read(p)
+	d.offset += int64(n)
+	if d.offset-d.dropped >= dropBehindInterval {
+		adviseDontNeed(d.f, d.dropped, d.offset-d.dropped)
+		d.dropped = d.offset
+	}
+	return n, err
+}
+
+// dropFromCache syncs a finished output file and drops it from the page cache; failures only
+// cost cache space, so they are ignored
+func dropFromCache(path string) {
+	f, err := os.Open(path)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+	if err := f.Sync(); err == nil {
+		adviseDontNeed(f, 0, 0)
+	}
+}
+
+// warnIOHintsUnsupported explains that -io-hints does nothing on this platform
+func warnIOHintsUnsupported(logger *log.Logger) {
+	if !ioHintsSupported {
+		warnf(logger, "⚠️ Warning: -io-hints needs posix_fadvise and has no effect on this platform")
+	}
+}
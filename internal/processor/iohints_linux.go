@@ -0,0 +1,23 @@
+//go:build linux
+
+package processor
+
+import (
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// ioHintsSupported reports whether -io-hints has an effect on this platform
+const ioHintsSupported = true
+
+// adviseSequential tells the kernel the file will be read sequentially, doubling its read-ahead
+func adviseSequential(f *os.File) {
+	unix.Fadvise(int(f.Fd()), 0, 0, unix.FADV_SEQUENTIAL)
+}
+
+// adviseDontNeed drops the given range of the file from the page cache. Dirty pages are not
+// dropped, so written files are synced first.
+func adviseDontNeed(f *os.File, offset, length int64) {
+	unix.Fadvise(int(f.Fd()), offset, length, unix.FADV_DONTNEED)
+}
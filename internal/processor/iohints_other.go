@@ -0,0 +1,14 @@
+//go:build !linux
+
+package processor
+
+import "os"
+
+// ioHintsSupported reports whether -io-hints has an effect on this platform
+const ioHintsSupported = false
+
+// adviseSequential is a no-op without posix_fadvise
+func adviseSequential(f *os.File) {}
+
+// adviseDontNeed is a no-op without posix_fadvise
+func adviseDontNeed(f *os.File, offset, length int64) {}
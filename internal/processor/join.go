@@ -0,0 +1,286 @@
+package processor
+
+import (
+	"database/sql"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+)
+
+// DefaultJoinMemoryLimit is the CSV size above which lookup tables are kept on disk
+const DefaultJoinMemoryLimit = 256 * 1024 * 1024
+
+// JoinTransform enriches records with columns from a CSV lookup table.
+// The first CSV column is the key, matched against the record's Field; every other column is
+// added to the record under its header name. Records without a match get null columns so
+// the output schema is the same for every row.
+type JoinTransform struct {
+	Field   string
+	columns []string
+	// normalize folds the keys of subreddit and author joins, which Reddit compares ignoring case
+	normalize func(string) string
+	memory    map[string][]string
+	db        *sql.DB
+	stmt      *sql.Stmt
+	dbPath    string
+}
+
+// LoadJoinTransform reads a CSV lookup table joined on field. Tables larger than memoryLimit
+// bytes are loaded into a temporary on-disk index instead of memory. Messages go to logger, or the
+// standard logger when nil.
+func LoadJoinTransform(csvPath, field string, memoryLimit int64, logger *log.Logger) (*JoinTransform, error) {
+	f, err := os.Open(csvPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open join table: %v", err)
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return nil, fmt.Errorf("failed to stat join table: %v", err)
+	}
+
+	reader := csv.NewReader(f)
+	reader.ReuseRecord = true
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read join table header: %v", err)
+	}
+	if len(header) < 2 {
+		return nil, fmt.Errorf("join table %s needs a key column and at least one value column", csvPath)
+	}
+
+	t := &JoinTransform{
+		Field:     field,
+		columns:   append([]string(nil), header[1:]...),
+		normalize: nameNormalizer(field),
+	}
+
+	if memoryLimit <= 0 || info.Size() <= memoryLimit {
+		t.memory = make(map[string][]string)
+		err = t.readRows(reader, func(key string, values []string) error {
+			t.memory[key] = values
+			return nil
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to load join table %s: %v", csvPath, err)
+		}
+		loggerOrDefault(logger).Printf("🔗 Loaded %d join rows from %s into memory", len(t.memory), csvPath)
+		return t, nil
+	}
+
+	err = t.loadOnDisk(func(fn func(key string, values []string) error) error {
+		return t.readRows(reader, fn)
+	})
+	if err != nil {
+		t.Close()
+		return nil, fmt.Errorf("failed to index join table %s: %v", csvPath, err)
+	}
+	loggerOrDefault(logger).Printf("🔗 Indexed join table %s on disk (%.0f MB)", csvPath, float64(info.Size())/1024/1024)
+	return t, nil
+}
+
+// readRows calls fn with the key and copied values of each CSV data row
+func (t *JoinTransform) readRows(reader *csv.Reader, fn func(key string, values []string) error) error {
+	for {
+		row, err := reader.Read()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		key := row[0]
+		if t.normalize != nil {
+			key = t.normalize(key)
+		}
+		if err := fn(key, append([]string(nil), row[1:]...)); err != nil {
+			return err
+		}
+	}
+}
+
+// joinRows iterates over the key and values of each row of a lookup table
+type joinRows func(fn func(key string, values []string) error) error
+
+// newJoinTransform loads a lookup table of unknown size joined on field, keeping it in memory
+// until its keys and values exceed memoryLimit bytes and moving it to an on-disk index then
+func newJoinTransform(field string, columns []string, memoryLimit int64, rows joinRows) (*JoinTransform, error) {
+	t := &JoinTransform{Field: field, columns: columns, memory: make(map[string][]string)}
+	var used int64
+	var index *joinIndexWriter
+	err := rows(func(key string, values []string) error {
+		if index != nil {
+			return index.insert(key, values)
+		}
+		t.memory[key] = values
+		used += int64(len(key))
+		for _, v := range values {
+			used += int64(len(v))
+		}
+		if memoryLimit <= 0 || used <= memoryLimit {
+			return nil
+		}
+		var err error
+		if index, err = t.openIndex(); err != nil {
+			return err
+		}
+		for key, values := range t.memory {
+			if err := index.insert(key, values); err != nil {
+				return err
+			}
+		}
+		t.memory = nil
+		return nil
+	})
+	if err == nil && index != nil {
+		err = t.finishIndex(index)
+	}
+	if err != nil {
+		if index != nil {
+			index.tx.Rollback()
+		}
+		t.Close()
+		return nil, err
+	}
+	return t, nil
+}
+
+// joinIndexWriter inserts rows into the on-disk index while it is built
+type joinIndexWriter struct {
+	tx   *sql.Tx
+	stmt *sql.Stmt
+}
+
+// insert adds a row to the index
+func (w *joinIndexWriter) insert(key string, values []string) error {
+	encoded, _ := json.Marshal(values)
+	_, err := w.stmt.Exec(key, string(encoded))
+	return err
+}
+
+// openIndex creates the temporary SQLite index of a lookup table too large for memory
+func (t *JoinTransform) openIndex() (*joinIndexWriter, error) {
+	tmp, err := os.CreateTemp("", "pushshift-join-*.db")
+	if err != nil {
+		return nil, err
+	}
+	tmp.Close()
+	t.dbPath = tmp.Name()
+
+	t.db, err = sql.Open("sqlite", t.dbPath)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := t.db.Exec(`PRAGMA journal_mode=OFF; PRAGMA synchronous=OFF;
+		CREATE TABLE lookup (key TEXT PRIMARY KEY, vals TEXT NOT NULL)`); err != nil {
+		return nil, err
+	}
+
+	tx, err := t.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	insert, err := tx.Prepare("INSERT OR REPLACE INTO lookup (key, vals) VALUES (?, ?)")
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+	return &joinIndexWriter{tx: tx, stmt: insert}, nil
+}
+
+// finishIndex commits the rows inserted into the index and prepares lookups
+func (t *JoinTransform) finishIndex(w *joinIndexWriter) error {
+	w.stmt.Close()
+	if err := w.tx.Commit(); err != nil {
+		return err
+	}
+	var err error
+	t.stmt, err = t.db.Prepare("SELECT vals FROM lookup WHERE key = ?")
+	return err
+}
+
+// loadOnDisk builds a temporary SQLite index of the lookup table for tables too large for memory
+func (t *JoinTransform) loadOnDisk(rows joinRows) error {
+	index, err := t.openIndex()
+	if err != nil {
+		return err
+	}
+	if err := rows(index.insert); err != nil {
+		index.tx.Rollback()
+		return err
+	}
+	return t.finishIndex(index)
+}
+
+// lookup returns the value columns for key
+func (t *JoinTransform) lookup(key string) ([]string, bool, error) {
+	if t.memory != nil {
+		values, ok := t.memory[key]
+		return values, ok, nil
+	}
+
+	var encoded string
+	err := t.stmt.QueryRow(key).Scan(&encoded)
+	if err == sql.ErrNoRows {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, fmt.Errorf("join lookup failed: %v", err)
+	}
+	var values []string
+	if err := json.Unmarshal([]byte(encoded), &values); err != nil {
+		return nil, false, fmt.Errorf("corrupt join index entry: %v", err)
+	}
+	return values, true, nil
+}
+
+// Apply adds the joined columns to the record
+func (t *JoinTransform) Apply(rec *Record) (bool, error) {
+	key, ok := rec.GetString(t.Field)
+	if !ok {
+		// Numeric keys (e.g. ids stored as numbers) are matched by their JSON text
+		if raw, found := rec.Get(t.Field); found {
+			key, ok = strings.Trim(string(raw), `"`), true
+		}
+	}
+
+	var values []string
+	if ok {
+		if t.normalize != nil {
+			key = t.normalize(key)
+		}
+		var err error
+		if values, ok, err = t.lookup(key); err != nil {
+			return false, err
+		}
+	}
+
+	for i, column := range t.columns {
+		if !ok || i >= len(values) {
+			rec.SetRaw(column, json.RawMessage("null"))
+			continue
+		}
+		if err := rec.Set(column, values[i]); err != nil {
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+// Close releases the on-disk index, if one was built
+func (t *JoinTransform) Close() error {
+	if t.db == nil {
+		return nil
+	}
+	if t.stmt != nil {
+		t.stmt.Close()
+	}
+	err := t.db.Close()
+	os.Remove(t.dbPath)
+	return err
+}
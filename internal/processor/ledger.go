@@ -0,0 +1,184 @@
+package processor
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	_ "modernc.org/sqlite"
+)
+
+// ledgerSchema creates the runs table on first use
+const ledgerSchema = `
+CREATE TABLE IF NOT EXISTS runs (
+	id            INTEGER PRIMARY KEY AUTOINCREMENT,
+	started_at    TEXT NOT NULL,
+	finished_at   TEXT NOT NULL,
+	status        TEXT NOT NULL,
+	error         TEXT NOT NULL DEFAULT '',
+	input_path    TEXT NOT NULL,
+	input_sha256  TEXT NOT NULL DEFAULT '',
+	output_prefix TEXT NOT NULL,
+	params_json   TEXT NOT NULL,
+	total_lines   INTEGER NOT NULL DEFAULT 0,
+	execution_ms  INTEGER NOT NULL DEFAULT 0,
+	manifest_json TEXT NOT NULL DEFAULT ''
+);
+CREATE INDEX IF NOT EXISTS runs_started_at ON runs (started_at);
+`
+
+// RunRecord is one entry of the local run ledger
+type RunRecord struct {
+	ID           int64             `json:"id"`
+	StartedAt    time.Time         `json:"started_at"`
+	FinishedAt   time.Time         `json:"finished_at"`
+	Status       string            `json:"status"`
+	Error        string            `json:"error,omitempty"`
+	InputPath    string            `json:"input_path"`
+	InputSHA256  string            `json:"input_sha256"`
+	OutputPrefix string            `json:"output_prefix"`
+	Params       map[string]string `json:"params"`
+	TotalLines   int64             `json:"total_lines"`
+	ExecutionMs  int64             `json:"execution_ms"`
+	Manifest     *Manifest         `json:"manifest,omitempty"`
+}
+
+// RunFilter selects ledger entries for listing
+type RunFilter struct {
+	// InputContains matches runs whose input path contains this substring
+	InputContains string
+	// Status matches runs with exactly this status (ok or failed)
+	Status string
+	// Since matches runs started at or after this time
+	Since time.Time
+	// Limit caps the number of runs returned, newest first
+	Limit int
+}
+
+// Ledger is a local SQLite database recording every run for provenance
+type Ledger struct {
+	db *sql.DB
+}
+
+// DefaultLedgerPath returns the ledger location in the user's home directory
+func DefaultLedgerPath() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "pushshift_history.db"
+	}
+	return filepath.Join(home, ".pushshift", "history.db")
+}
+
+// OpenLedger opens (creating if needed) the ledger database at path
+func OpenLedger(path string) (*Ledger, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create ledger directory: %v", err)
+	}
+
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open ledger: %v", err)
+	}
+	if _, err := db.Exec(ledgerSchema); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to initialize ledger: %v", err)
+	}
+	return &Ledger{db: db}, nil
+}
+
+// Close closes the ledger database
+func (l *Ledger) Close() error {
+	return l.db.Close()
+}
+
+// RecordRun stores the parameters, outcome and output manifest of a run
+func (l *Ledger) RecordRun(started time.Time, inputPath, outputPrefix string, params map[string]string, stats ProcessStats, runErr error) (int64, error) {
+	paramsJSON, err := json.Marshal(params)
+	if err != nil {
+		return 0, fmt.Errorf("failed to encode run parameters: %v", err)
+	}
+
+	status, errText := "ok", ""
+	manifestJSON := []byte{}
+	if runErr != nil {
+		status, errText = "failed", runErr.Error()
+	} else {
+		manifestJSON, err = json.Marshal(newManifest(outputPrefix, inputPath, stats))
+		if err != nil {
+			return 0, fmt.Errorf("failed to encode manifest: %v", err)
+		}
+	}
+
+	res, err := l.db.Exec(`INSERT INTO runs
+		(started_at, finished_at, status, error, input_path, input_sha256, output_prefix,
+		 params_json, total_lines, execution_ms, manifest_json)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		started.UTC().Format(time.RFC3339Nano), time.Now().UTC().Format(time.RFC3339Nano),
+		status, errText, inputPath, stats.InputSHA256, outputPrefix,
+		string(paramsJSON), stats.TotalLines, stats.ExecutionTime.Milliseconds(), string(manifestJSON))
+	if err != nil {
+		return 0, fmt.Errorf("failed to record run: %v", err)
+	}
+	return res.LastInsertId()
+}
+
+// ListRuns returns ledger entries matching filter, newest first
+func (l *Ledger) ListRuns(filter RunFilter) ([]RunRecord, error) {
+	query := `SELECT id, started_at, finished_at, status, error, input_path, input_sha256,
+		output_prefix, params_json, total_lines, execution_ms, manifest_json FROM runs WHERE 1=1`
+	var args []any
+	if filter.InputContains != "" {
+		query += " AND instr(input_path, ?) > 0"
+		args = append(args, filter.InputContains)
+	}
+	if filter.Status != "" {
+		query += " AND status = ?"
+		args = append(args, filter.Status)
+	}
+	if !filter.Since.IsZero() {
+		query += " AND started_at >= ?"
+		args = append(args, filter.Since.UTC().Format(time.RFC3339Nano))
+	}
+	query += " ORDER BY id DESC"
+	if filter.Limit > 0 {
+		query += fmt.Sprintf(" LIMIT %d", filter.Limit)
+	}
+
+	rows, err := l.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query ledger: %v", err)
+	}
+	defer rows.Close()
+
+	var runs []RunRecord
+	for rows.Next() {
+		var run RunRecord
+		var started, finished, paramsJSON, manifestJSON string
+		if err := rows.Scan(&run.ID, &started, &finished, &run.Status, &run.Error, &run.InputPath,
+			&run.InputSHA256, &run.OutputPrefix, &paramsJSON, &run.TotalLines, &run.ExecutionMs, &manifestJSON); err != nil {
+			return nil, fmt.Errorf("failed to read ledger row: %v", err)
+		}
+		if run.StartedAt, err = time.Parse(time.RFC3339Nano, started); err != nil {
+			return nil, fmt.Errorf("failed to decode the start time of run %d: %v", run.ID, err)
+		}
+		if run.FinishedAt, err = time.Parse(time.RFC3339Nano, finished); err != nil {
+			return nil, fmt.Errorf("failed to decode the finish time of run %d: %v", run.ID, err)
+		}
+		if paramsJSON != "" {
+			if err := json.Unmarshal([]byte(paramsJSON), &run.Params); err != nil {
+				return nil, fmt.Errorf("failed to decode the parameters of run %d: %v", run.ID, err)
+			}
+		}
+		if manifestJSON != "" {
+			run.Manifest = &Manifest{}
+			if err := json.Unmarshal([]byte(manifestJSON), run.Manifest); err != nil {
+				return nil, fmt.Errorf("failed to decode the manifest of run %d: %v", run.ID, err)
+			}
+		}
+		runs = append(runs, run)
+	}
+	return runs, rows.Err()
+}
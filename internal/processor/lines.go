@@ -0,0 +1,118 @@
+package processor
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"log"
+)
+
+// Line ending modes
+const (
+	// LineEndingsAny splits lines on LF, CRLF and lone CR. JSON strings cannot contain a raw CR,
+	// so a CR outside an escape always ends a line.
+	LineEndingsAny = "any"
+	// LineEndingsLF splits lines on LF only, stripping a CR before it
+	LineEndingsLF = "lf"
+)
+
+// ValidateLineEndings checks a line ending mode
+func ValidateLineEndings(mode string) error {
+	switch mode {
+	case "", LineEndingsAny, LineEndingsLF:
+		return nil
+	}
+	return fmt.Errorf("unsupported line endings %q, expected any or lf", mode)
+}
+
+// utf8BOM is the UTF-8 byte-order mark
+var utf8BOM = []byte{0xEF, 0xBB, 0xBF}
+
+// lineSplitter is a bufio.SplitFunc source that normalizes line terminators and counts the
+// non-LF ones it sees. A final line without a terminator is returned like any other. It also
+// strips byte-order marks from the start of lines: concatenated streams may each begin with one.
+type lineSplitter struct {
+	logger *log.Logger
+	loneCR bool
+	crlf   int64
+	cr     int64
+	boms   int64
+	// lineStart is the offset in the decompressed input of the line last returned, and consumed
+	// the offset after its terminator
+	lineStart int64
+	consumed  int64
+}
+
+// split implements bufio.SplitFunc
+func (l *lineSplitter) split(data []byte, atEOF bool) (int, []byte, error) {
+	advance, token, err := l.splitLine(data, atEOF)
+	if advance > 0 {
+		l.lineStart, l.consumed = l.consumed, l.consumed+int64(advance)
+	}
+	if bytes.HasPrefix(token, utf8BOM) {
+		l.boms++
+		token = token[len(utf8BOM):]
+	}
+	return advance, token, err
+}
+
+// splitLine finds the next line and its terminator
+func (l *lineSplitter) splitLine(data []byte, atEOF bool) (int, []byte, error) {
+	if atEOF && len(data) == 0 {
+		return 0, nil, nil
+	}
+
+	end := bytes.IndexByte(data, '\n')
+	if l.loneCR {
+		// Only the current line is searched, so large reads don't make splitting quadratic
+		search := data
+		if end >= 0 {
+			search = data[:end]
+		}
+		if cr := bytes.IndexByte(search, '\r'); cr >= 0 && (end < 0 || cr < end-1) {
+			switch {
+			case cr+1 < len(data):
+				l.cr++
+				return cr + 1, data[:cr], nil
+			case atEOF:
+				l.cr++
+				return cr + 1, data[:cr], nil
+			default:
+				// The next byte decides between CRLF and a lone CR
+				return 0, nil, nil
+			}
+		}
+	}
+	if end >= 0 {
+		if end > 0 && data[end-1] == '\r' {
+			l.crlf++
+			return end + 1, data[:end-1], nil
+		}
+		return end + 1, data[:end], nil
+	}
+
+	if atEOF {
+		if !l.loneCR && data[len(data)-1] == '\r' {
+			return len(data), data[:len(data)-1], nil
+		}
+		return len(data), data, nil
+	}
+	return 0, nil, nil
+}
+
+// logNormalized reports how many non-LF line endings and byte-order marks were normalized
+func (l *lineSplitter) logNormalized() {
+	if l.crlf > 0 || l.cr > 0 {
+		l.logger.Printf("🧽 Normalized %d CRLF and %d lone CR line endings", l.crlf, l.cr)
+	}
+	if l.boms > 0 {
+		l.logger.Printf("🧽 Removed %d byte-order marks", l.boms)
+	}
+}
+
+// scanner creates a line scanner over the input that handles lines up to maxLineSize bytes
+func (in *zstInput) scanner(maxLineSize int) *bufio.Scanner {
+	scanner := newLineScanner(in, maxLineSize)
+	scanner.Split(in.lines.split)
+	return scanner
+}
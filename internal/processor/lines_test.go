@@ -0,0 +1,87 @@
+package processor
+
+import (
+	"bufio"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+	"testing/iotest"
+)
+
+func TestLineSplitter(t *testing.T) {
+	tests := []struct {
+		name         string
+		loneCR       bool
+		input        string
+		want         []string
+		crlf, cr     int64
+		boms         int64
+		lastConsumed int64
+	}{
+		{"lf", false, "a\nb\n", []string{"a", "b"}, 0, 0, 0, 4},
+		{"no final terminator", false, "a\nb", []string{"a", "b"}, 0, 0, 0, 3},
+		{"empty input", false, "", nil, 0, 0, 0, 0},
+		{"empty lines", false, "\n\na\n", []string{"", "", "a"}, 0, 0, 0, 4},
+		{"crlf", false, "a\r\nb\r\n", []string{"a", "b"}, 2, 0, 0, 6},
+		{"final cr dropped", false, "a\nb\r", []string{"a", "b"}, 0, 0, 0, 4},
+		{"lone cr kept in lf mode", false, "a\rb\n", []string{"a\rb"}, 0, 0, 0, 4},
+		{"lone cr", true, "a\rb\rc", []string{"a", "b", "c"}, 0, 2, 0, 5},
+		{"mixed endings", true, "a\r\nb\rc\nd", []string{"a", "b", "c", "d"}, 1, 1, 0, 8},
+		{"final lone cr", true, "a\r", []string{"a"}, 0, 1, 0, 2},
+		{"cr then crlf", true, "a\r\r\nb", []string{"a", "", "b"}, 1, 1, 0, 5},
+		{"bom on first line", false, "\xef\xbb\xbf{}\n{}\n", []string{"{}", "{}"}, 0, 0, 1, 9},
+		{"bom on concatenated streams", false, "\xef\xbb\xbfa\n\xef\xbb\xbfb\n", []string{"a", "b"}, 0, 0, 2, 10},
+	}
+	readers := map[string]func(io.Reader) io.Reader{
+		"whole":    func(r io.Reader) io.Reader { return r },
+		"one byte": iotest.OneByteReader,
+	}
+	for _, tt := range tests {
+		for readerName, reader := range readers {
+			t.Run(tt.name+"/"+readerName, func(t *testing.T) {
+				l := &lineSplitter{loneCR: tt.loneCR}
+				scanner := bufio.NewScanner(reader(strings.NewReader(tt.input)))
+				scanner.Buffer(make([]byte, 2), 1024)
+				scanner.Split(l.split)
+				var got []string
+				for scanner.Scan() {
+					got = append(got, scanner.Text())
+				}
+				if err := scanner.Err(); err != nil {
+					t.Fatal(err)
+				}
+				if !reflect.DeepEqual(got, tt.want) {
+					t.Errorf("got %q, want %q", got, tt.want)
+				}
+				if l.crlf != tt.crlf || l.cr != tt.cr || l.boms != tt.boms {
+					t.Errorf("counted %d CRLF, %d CR and %d BOMs, want %d, %d and %d", l.crlf, l.cr, l.boms, tt.crlf, tt.cr, tt.boms)
+				}
+				if l.consumed != tt.lastConsumed {
+					t.Errorf("consumed %d bytes, want %d", l.consumed, tt.lastConsumed)
+				}
+			})
+		}
+	}
+}
+
+func TestLineSplitterOffsets(t *testing.T) {
+	l := &lineSplitter{loneCR: true}
+	scanner := bufio.NewScanner(strings.NewReader("ab\r\ncde\rf\n"))
+	scanner.Split(l.split)
+	var starts []int64
+	for scanner.Scan() {
+		starts = append(starts, l.lineStart)
+	}
+	if want := []int64{0, 4, 8}; !reflect.DeepEqual(starts, want) {
+		t.Errorf("got line starts %v, want %v", starts, want)
+	}
+}
+
+func TestValidateLineEndings(t *testing.T) {
+	for mode, valid := range map[string]bool{"": true, LineEndingsAny: true, LineEndingsLF: true, "crlf": false, "LF": false} {
+		if err := ValidateLineEndings(mode); (err == nil) != valid {
+			t.Errorf("%q: got %v", mode, err)
+		}
+	}
+}
@@ -0,0 +1,91 @@
+package processor
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"log/slog"
+	"slices"
+	"strings"
+)
+
+// loggerOrDefault returns logger, or the standard logger when it is nil
+func loggerOrDefault(logger *log.Logger) *log.Logger {
+	if logger == nil {
+		return log.Default()
+	}
+	return logger
+}
+
+// runLogger is embedded by filters, transforms and side files to log through the logger of the
+// run they are part of, which the processor sets when the stages start. Until then, and outside a
+// run, logger is nil and loggerOrDefault falls back to the standard logger.
+type runLogger struct {
+	logger *log.Logger
+}
+
+// setLogger sets the logger of the run
+func (r *runLogger) setLogger(logger *log.Logger) {
+	r.logger = logger
+}
+
+// loggerSetter is implemented by the filters, transforms and side files embedding runLogger
+type loggerSetter interface {
+	setLogger(*log.Logger)
+}
+
+// setLoggers has the filters and transforms of the run, the side files they write and the sink
+// log through the run's logger
+func (s *PushshiftProcessor) setLoggers() {
+	logger := s.logger()
+	if sink, ok := s.Options.Sink.(loggerSetter); ok {
+		sink.setLogger(logger)
+	}
+	for _, t := range slices.Concat(s.Options.Filters, s.Options.Transforms) {
+		if t, ok := t.(loggerSetter); ok {
+			t.setLogger(logger)
+		}
+		for _, file := range transformSideFiles(t) {
+			file.setLogger(logger)
+		}
+	}
+}
+
+// newSlogBridge returns a log.Logger whose messages go to l, so the pipeline's Printf-style
+// messages reach a caller's slog handler. Its messages are logged at slog.LevelInfo; warnf and
+// errorf log at their own levels.
+func newSlogBridge(l *slog.Logger) *log.Logger {
+	return log.New(slogWriter{l}, "", 0)
+}
+
+// slogWriter logs each message written to it at slog.LevelInfo
+type slogWriter struct {
+	l *slog.Logger
+}
+
+// Write implements io.Writer for log.Logger
+func (w slogWriter) Write(p []byte) (int, error) {
+	w.l.Info(strings.TrimSuffix(string(p), "\n"))
+	return len(p), nil
+}
+
+// logAt logs a message at level when logger is a slog bridge, and like Printf otherwise. A nil
+// logger is the standard logger.
+func logAt(logger *log.Logger, level slog.Level, format string, args ...any) {
+	logger = loggerOrDefault(logger)
+	if w, ok := logger.Writer().(slogWriter); ok {
+		w.l.Log(context.Background(), level, fmt.Sprintf(format, args...))
+		return
+	}
+	logger.Printf(format, args...)
+}
+
+// warnf logs a warning, at slog.LevelWarn through a slog bridge
+func warnf(logger *log.Logger, format string, args ...any) {
+	logAt(logger, slog.LevelWarn, format, args...)
+}
+
+// errorf logs a failure the run goes on after, at slog.LevelError through a slog bridge
+func errorf(logger *log.Logger, format string, args ...any) {
+	logAt(logger, slog.LevelError, format, args...)
+}
@@ -0,0 +1,81 @@
+package processor
+
+import (
+	"bytes"
+	"log"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func TestSetLoggers(t *testing.T) {
+	var buf bytes.Buffer
+	filter, err := NewSubredditFilter([]string{"ask*"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	transform, err := NewNonCommunityTransform("separate", t.TempDir()+"/x_noncommunity.jsonl")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &PushshiftProcessor{}
+	s.Options.Filters = []Transform{filter}
+	s.Options.Transforms = []Transform{transform}
+	s.Options.Logger = slog.New(slog.NewTextHandler(&buf, nil))
+	s.setLoggers()
+
+	filter.Apply(NewRecord([]byte(`{"subreddit":"AskScience"}`)))
+	transform.Apply(NewRecord([]byte(`{"subreddit":"u_someone"}`)))
+	filter.Close()
+	transform.Close()
+	for _, want := range []string{"🏷️ Subreddit patterns matched 1 subreddits: askscience", "📣 Writing promoted and profile content to", "📣 Non-community records separated"} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("missing %q in:\n%s", want, buf.String())
+		}
+	}
+}
+
+func TestLogLevels(t *testing.T) {
+	var buf bytes.Buffer
+	s := &PushshiftProcessor{}
+	s.Options.Logger = slog.New(slog.NewTextHandler(&buf, nil))
+	if s.logger() != s.logger() {
+		t.Error("logger built a new bridge on each call")
+	}
+	tests := []struct {
+		log  func(*log.Logger)
+		want string
+	}{
+		{func(l *log.Logger) { l.Printf("📊 Progress") }, `level=INFO msg="📊 Progress"`},
+		{func(l *log.Logger) { l.Printf("quoting ❌ and ⚠️ in a message") }, `level=INFO msg="quoting ❌ and ⚠️ in a message"`},
+		{func(l *log.Logger) { warnf(l, "⚠️ Warning: %d", 1) }, `level=WARN msg="⚠️ Warning: 1"`},
+		{func(l *log.Logger) { errorf(l, "❌ Part %d failed", 2) }, `level=ERROR msg="❌ Part 2 failed"`},
+	}
+	for _, tt := range tests {
+		buf.Reset()
+		tt.log(s.logger())
+		if !strings.Contains(buf.String(), tt.want) {
+			t.Errorf("got %q, want %q", buf.String(), tt.want)
+		}
+	}
+
+	// Without a slog.Logger, warnings are printed like other messages
+	buf.Reset()
+	warnf(log.New(&buf, "", 0), "⚠️ Warning: %d", 1)
+	if buf.String() != "⚠️ Warning: 1\n" {
+		t.Errorf("got %q", buf.String())
+	}
+}
+
+func TestSetLoggersSink(t *testing.T) {
+	var buf bytes.Buffer
+	qdrant := &QdrantSink{}
+	sink := NewDeadLetterSink(qdrant, t.TempDir()+"/x_deadletter.jsonl", 0)
+	s := &PushshiftProcessor{}
+	s.Options.Sink = sink
+	s.Options.Logger = slog.New(slog.NewTextHandler(&buf, nil))
+	s.setLoggers()
+	if sink.logger != s.logger() || qdrant.logger != s.logger() {
+		t.Error("the sink and the sink it wraps don't log through the run's logger")
+	}
+}
@@ -0,0 +1,172 @@
+package processor
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// ParseFullname splits a Reddit fullname (e.g. t1_abc123) into its kind prefix and base36 id.
+// Plain ids without a kind prefix are returned with an empty kind.
+func ParseFullname(fullname string) (kind, id string) {
+	if len(fullname) > 3 && fullname[0] == 't' && fullname[2] == '_' && fullname[1] >= '1' && fullname[1] <= '6' {
+		return fullname[:2], fullname[3:]
+	}
+	return "", fullname
+}
+
+// FindRecordsInInput scans a zst dump and writes every record whose id matches one of ids to w.
+// A fullname only matches a record of its kind, t1 for comments and t3 for submissions. It stops
+// early once all requested ids have been found and returns the number of matches. Progress goes
+// to logger, or the standard logger when nil.
+func FindRecordsInInput(inputPath string, ids []string, w io.Writer, logger *log.Logger) (int, error) {
+	// wanted maps each base36 id to the kinds still to find, "" for a plain id matching either
+	wanted := make(map[string]map[string]bool, len(ids))
+	for _, fullname := range ids {
+		kind, id := ParseFullname(strings.ToLower(fullname))
+		if wanted[id] == nil {
+			wanted[id] = make(map[string]bool)
+		}
+		wanted[id][kind] = true
+	}
+
+	in, err := openZstInput(inputPath, inputOptions{})
+	if err != nil {
+		return 0, err
+	}
+	defer in.Close()
+
+	scanner := in.scanner(scannerBufferSize)
+
+	found := 0
+	var linesScanned int64
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		linesScanned++
+
+		if linesScanned%10000000 == 0 {
+			loggerOrDefault(logger).Printf("🔄 Progress: Scanned %d lines, %d records found", linesScanned, found)
+		}
+
+		// Cheap pre-check before paying for a JSON decode
+		if !containsAnyID(line, wanted) {
+			continue
+		}
+
+		rec := NewRecord(line)
+		id, _ := rec.GetString("id")
+		if id == "" {
+			name, _ := rec.GetString("name")
+			_, id = ParseFullname(name)
+		}
+		id = strings.ToLower(id)
+		kinds := wanted[id]
+		kind := recordKind(rec)
+		if !kinds[kind] {
+			if !kinds[""] {
+				continue
+			}
+			kind = ""
+		}
+
+		if _, err := w.Write(line); err != nil {
+			return found, fmt.Errorf("error writing record: %v", err)
+		}
+		if _, err := w.Write([]byte("\n")); err != nil {
+			return found, fmt.Errorf("error writing newline: %v", err)
+		}
+		found++
+		if delete(kinds, kind); len(kinds) == 0 {
+			delete(wanted, id)
+		}
+		if len(wanted) == 0 {
+			break
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return found, inputError(err)
+	}
+
+	return found, nil
+}
+
+// containsAnyID reports whether any of the wanted ids appears in the raw line
+func containsAnyID(line []byte, wanted map[string]map[string]bool) bool {
+	for id := range wanted {
+		if bytes.Contains(line, []byte(id)) {
+			return true
+		}
+	}
+	return false
+}
+
+// datasetGlob returns the Parquet glob for a dataset given either a directory or an output
+// prefix, whose glob characters are escaped. A .parquet file or glob is used as it is.
+func datasetGlob(dataset string) string {
+	if strings.HasSuffix(dataset, ".parquet") {
+		return dataset
+	}
+	if info, err := os.Stat(dataset); err == nil && info.IsDir() {
+		return filepath.Join(escapeDuckDBGlob(dataset), "**", "*.parquet")
+	}
+	return escapeDuckDBGlob(dataset) + "_part_*.parquet"
+}
+
+// FindRecordsInDataset queries converted Parquet outputs with DuckDB and writes matching records
+// to w as JSON. Fullnames are matched against comments or submissions by file and row, as
+// RemoveFromDataset matches them.
+func FindRecordsInDataset(dataset string, ids []string, w io.Writer) error {
+	files, err := datasetFiles(dataset)
+	if err != nil {
+		return err
+	}
+	lists := DeletionLists{IDs: make(map[string]string, len(ids))}
+	for _, id := range ids {
+		lists.addID(id)
+	}
+	conditions, err := newDeletionConditions(lists)
+	if err != nil {
+		return err
+	}
+	defer conditions.close()
+	groups := make(map[string][]string)
+	for _, file := range files {
+		if condition := conditions.condition(file); condition != "" {
+			groups[condition] = append(groups[condition], sqlString(file.Filename))
+		}
+	}
+	if len(groups) == 0 {
+		return nil
+	}
+	selects := make([]string, 0, len(groups))
+	for condition, group := range groups {
+		selects = append(selects, fmt.Sprintf("SELECT * FROM read_parquet([%s], union_by_name=true) WHERE %s", strings.Join(group, ", "), condition))
+	}
+	sort.Strings(selects)
+	query := strings.Join(selects, " UNION ALL BY NAME ") + ";"
+
+	// Written as a JSON array, as the duckdb command's -json mode prints rows
+	n := 0
+	err = queryDuckDBRows(context.Background(), query, func(row []byte) error {
+		sep := ",\n"
+		if n == 0 {
+			sep = "["
+		}
+		n++
+		_, err := fmt.Fprintf(w, "%s%s", sep, row)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("DuckDB lookup failed: %v", err)
+	}
+	if n > 0 {
+		_, err = io.WriteString(w, "]\n")
+	}
+	return err
+}
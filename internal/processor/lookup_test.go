@@ -0,0 +1,83 @@
+package processor
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// writeTestZst writes lines as a zstd-compressed dump
+func writeTestZst(t *testing.T, path string, lines ...string) {
+	t.Helper()
+	encoder, err := zstd.NewWriter(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := encoder.EncodeAll([]byte(strings.Join(lines, "\n")+"\n"), nil)
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestFindRecordsInInput(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "RX_2020-01.zst")
+	comment := `{"id":"abc","parent_id":"t3_xyz","body":"a comment"}`
+	submission := `{"id":"abc","title":"a submission"}`
+	named := `{"name":"t3_def","title":"no id field"}`
+	writeTestZst(t, path, comment, submission, named, `not json abc`)
+	tests := []struct {
+		name string
+		ids  []string
+		want []string
+	}{
+		{"comment fullname", []string{"t1_abc"}, []string{comment}},
+		{"submission fullname", []string{"t3_abc"}, []string{submission}},
+		{"plain id finds the first", []string{"abc"}, []string{comment}},
+		{"both kinds", []string{"t3_abc", "t1_abc"}, []string{comment, submission}},
+		{"plain id and fullname", []string{"abc", "t3_abc"}, []string{comment, submission}},
+		{"case-insensitive", []string{"T1_ABC"}, []string{comment}},
+		{"id from the name", []string{"t3_def"}, []string{named}},
+		{"other kind", []string{"t1_def"}, nil},
+		{"missing", []string{"zzz"}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+			found, err := FindRecordsInInput(path, tt.ids, &out, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			want := ""
+			if len(tt.want) > 0 {
+				want = strings.Join(tt.want, "\n") + "\n"
+			}
+			if found != len(tt.want) || out.String() != want {
+				t.Errorf("got %d records:\n%s\nwant:\n%s", found, out.String(), want)
+			}
+		})
+	}
+}
+
+func TestDatasetGlob(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "out[1]")
+	if err := os.Mkdir(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		dataset string
+		want    string
+	}{
+		{dir, filepath.Join(filepath.Dir(dir), "out[[]1]", "**", "*.parquet")},
+		{"runs/rc*2020?", "runs/rc[*]2020[?]_part_*.parquet"},
+		{"runs/*.parquet", "runs/*.parquet"},
+	}
+	for _, tt := range tests {
+		if got := datasetGlob(tt.dataset); got != tt.want {
+			t.Errorf("%s: got %s, want %s", tt.dataset, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,224 @@
+package processor
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"time"
+)
+
+// Manifest records what a run produced so outputs can be traced back to their source
+type Manifest struct {
+	Input         string    `json:"input"`
+	InputSHA256   string    `json:"input_sha256"`
+	OutputPrefix  string    `json:"output_prefix"`
+	CreatedAt     time.Time `json:"created_at"`
+	TotalLines    int64     `json:"total_lines"`
+	ExecutionTime string    `json:"execution_time"`
+	// StartOffset is where the run began reading the compressed input after seeking for
+	// -start-at. Part line ranges count lines from there.
+	StartOffset int64 `json:"start_offset,omitempty"`
+	// MatchedLines and SkippedLines count the lines the filters kept and removed
+	MatchedLines int64 `json:"matched_lines,omitempty"`
+	SkippedLines int64 `json:"skipped_lines,omitempty"`
+	// Filters counts the records each filter and transform saw and dropped
+	Filters []FilterStats `json:"filters,omitempty"`
+	// BadLines counts the input lines that were not valid JSON and were left out
+	BadLines int64 `json:"bad_lines,omitempty"`
+	// Routes counts the records written to each output of a routed run
+	Routes []RouteStats `json:"routes,omitempty"`
+	Parts  []PartInfo   `json:"parts"`
+	// FailedParts lists the parts that failed to convert, for retrying them later
+	FailedParts []PartInfo `json:"failed_parts,omitempty"`
+	// PeakScratchBytes is the largest amount of intermediate data the run kept on disk
+	PeakScratchBytes int64 `json:"peak_scratch_bytes"`
+	// Stages breaks the run time down by pipeline stage
+	Stages StageTelemetry `json:"stages"`
+	// SideTables lists secondary tables extracted from the records, such as gallery media
+	SideTables []SideTableInfo `json:"side_tables,omitempty"`
+	// Quantiles summarizes score, num_comments and body length when -quantiles was used
+	Quantiles map[string]QuantileSummary `json:"quantiles,omitempty"`
+	// Runs lists every run that added parts to this output when -append was used, oldest first.
+	// Parts then covers all of them, while the other fields describe the latest run.
+	Runs []ManifestRun `json:"runs,omitempty"`
+	// Removals lists the passes that removed records from the parts to honor deletion requests
+	Removals []ManifestRemoval `json:"removals,omitempty"`
+}
+
+// ManifestRun summarizes one run of an appended dataset
+type ManifestRun struct {
+	Input       string    `json:"input"`
+	InputSHA256 string    `json:"input_sha256"`
+	CreatedAt   time.Time `json:"created_at"`
+	TotalLines  int64     `json:"total_lines"`
+	StartOffset int64     `json:"start_offset,omitempty"`
+	FirstPart   int       `json:"first_part"`
+	PartCount   int       `json:"part_count"`
+}
+
+// ManifestPath returns the manifest file path for an output prefix
+func ManifestPath(outputPrefix string) string {
+	return outputPrefix + "_manifest.json"
+}
+
+// newManifest builds the manifest describing a finished run
+func newManifest(outputPrefix, inputPath string, stats ProcessStats) Manifest {
+	return Manifest{
+		Input:            inputPath,
+		InputSHA256:      stats.InputSHA256,
+		OutputPrefix:     outputPrefix,
+		CreatedAt:        time.Now().UTC(),
+		TotalLines:       stats.TotalLines,
+		ExecutionTime:    stats.ExecutionTime.String(),
+		StartOffset:      stats.StartOffset,
+		MatchedLines:     stats.MatchedLines,
+		SkippedLines:     stats.SkippedLines,
+		Filters:          stats.Filters,
+		BadLines:         stats.BadLines,
+		Routes:           stats.Routes,
+		Parts:            stats.Parts,
+		FailedParts:      stats.FailedParts,
+		PeakScratchBytes: stats.PeakScratchBytes,
+		Stages:           stats.Stages,
+		SideTables:       stats.SideTables,
+		Quantiles:        stats.quantileSummaries(),
+	}
+}
+
+// run summarizes the manifest's own run for the Runs list
+func (m Manifest) run() ManifestRun {
+	r := ManifestRun{Input: m.Input, InputSHA256: m.InputSHA256, CreatedAt: m.CreatedAt, TotalLines: m.TotalLines, StartOffset: m.StartOffset}
+	if len(m.Parts) > 0 {
+		r.FirstPart = m.Parts[0].Number
+		r.PartCount = len(m.Parts)
+	}
+	// A failed first part still starts the run's numbering
+	if len(m.FailedParts) > 0 && (r.FirstPart == 0 || m.FailedParts[0].Number < r.FirstPart) {
+		r.FirstPart = m.FailedParts[0].Number
+	}
+	return r
+}
+
+// appendTo extends a previous manifest of the same output with this run's parts
+func (m Manifest) appendTo(previous *Manifest) Manifest {
+	if previous == nil {
+		return m
+	}
+	runs := previous.Runs
+	if len(runs) == 0 {
+		runs = []ManifestRun{previous.run()}
+	}
+	m.Runs = append(runs, m.run())
+	m.Removals = previous.Removals
+	m.Parts = append(append([]PartInfo{}, previous.Parts...), m.Parts...)
+	m.FailedParts = append(append([]PartInfo{}, previous.FailedParts...), m.FailedParts...)
+	return m
+}
+
+// readManifest loads the manifest of an output prefix, returning nil when there is none
+func readManifest(outputPrefix string) (*Manifest, error) {
+	data, err := os.ReadFile(ManifestPath(outputPrefix))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read manifest: %v", err)
+	}
+	var m Manifest
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, fmt.Errorf("failed to parse manifest %s: %v", ManifestPath(outputPrefix), err)
+	}
+	return &m, nil
+}
+
+// partFilePattern matches the part number of a Parquet part file name
+var partFilePattern = regexp.MustCompile(`_part_(\d+)\.parquet$`)
+
+// nextPartNumber returns the part number following every existing part of an output prefix,
+// whether the part is listed in the manifest or only present on disk. Numbers of failed parts
+// stay reserved for their retry.
+func nextPartNumber(outputPrefix string, previous *Manifest) (int, error) {
+	last := 0
+	if previous != nil {
+		for _, part := range previous.Parts {
+			last = max(last, part.Number)
+		}
+		for _, part := range previous.FailedParts {
+			last = max(last, part.Number)
+		}
+	}
+	matches, err := filepath.Glob(escapeGlob(outputPrefix) + "_part_*.parquet")
+	if err != nil {
+		return 0, fmt.Errorf("failed to list existing parts: %v", err)
+	}
+	for _, path := range matches {
+		if m := partFilePattern.FindStringSubmatch(path); m != nil && path == outputPrefix+m[0] {
+			n, _ := strconv.Atoi(m[1])
+			last = max(last, n)
+		}
+	}
+	return last + 1, nil
+}
+
+// globMeta matches the characters filepath.Match treats specially
+var globMeta = regexp.MustCompile(`[*?[\\]`)
+
+// escapeGlob escapes glob metacharacters in a literal path
+func escapeGlob(path string) string {
+	return globMeta.ReplaceAllString(path, `\$0`)
+}
+
+// duckdbGlobMeta matches the characters DuckDB treats specially in file globs
+var duckdbGlobMeta = regexp.MustCompile(`[*?[]`)
+
+// escapeDuckDBGlob escapes glob metacharacters in a literal path for DuckDB by wrapping each in a
+// character class
+func escapeDuckDBGlob(path string) string {
+	return duckdbGlobMeta.ReplaceAllString(path, `[$0]`)
+}
+
+// writeManifest writes the run manifest next to the output parts, extending previous when the
+// run appended to an existing output. The file is replaced atomically so readers never see a
+// partial manifest.
+func writeManifest(outputPrefix, inputPath string, stats ProcessStats, previous *Manifest) error {
+	return writeManifestFile(outputPrefix, newManifest(outputPrefix, inputPath, stats).appendTo(previous))
+}
+
+// writeManifestFile replaces the manifest of an output prefix with m
+func writeManifestFile(outputPrefix string, m Manifest) error {
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode manifest: %v", err)
+	}
+	if err := writeFileAtomic(ManifestPath(outputPrefix), append(data, '\n')); err != nil {
+		return fmt.Errorf("failed to write manifest: %v", err)
+	}
+	return nil
+}
+
+// writeFileAtomic writes data to a temporary file in the target directory and renames it over path
+func writeFileAtomic(path string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
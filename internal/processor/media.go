@@ -0,0 +1,174 @@
+package processor
+
+import (
+	"encoding/json"
+	"html"
+	"sort"
+	"strings"
+	"sync/atomic"
+)
+
+// MediaTableName names the side table of gallery and media items
+const MediaTableName = "media"
+
+// mediaRow is a row of the media table: one image, animation or video of a submission
+type mediaRow struct {
+	SubmissionID string `json:"submission_id"`
+	MediaID      string `json:"media_id"`
+	// Position is the item's place in the gallery, starting at 1; null for media outside a gallery
+	Position *int   `json:"position"`
+	Type     string `json:"type"`
+	MimeType string `json:"mime_type"`
+	URL      string `json:"url"`
+	Width    *int64 `json:"width"`
+	Height   *int64 `json:"height"`
+	Caption  string `json:"caption"`
+	Status   string `json:"status"`
+}
+
+// mediaMetadata is an entry of a submission's media_metadata object
+type mediaMetadata struct {
+	Status string `json:"status"`
+	Type   string `json:"e"`
+	Mime   string `json:"m"`
+	Source struct {
+		URL    string `json:"u"`
+		GIF    string `json:"gif"`
+		MP4    string `json:"mp4"`
+		Width  *int64 `json:"x"`
+		Height *int64 `json:"y"`
+	} `json:"s"`
+	DashURL string `json:"dashUrl"`
+	HLSURL  string `json:"hlsUrl"`
+	Width   *int64 `json:"x"`
+	Height  *int64 `json:"y"`
+}
+
+// galleryData is a submission's gallery_data object, ordering its media
+type galleryData struct {
+	Items []struct {
+		MediaID string `json:"media_id"`
+		Caption string `json:"caption"`
+	} `json:"items"`
+}
+
+// MediaTableTransform extracts the gallery_data and media_metadata objects of submissions into
+// the media side table, one row per item with its type, source URL, size and gallery position.
+// Records pass through unchanged; -drop-fields can remove the nested objects from the main output.
+type MediaTableTransform struct {
+	table       *SideTable
+	submissions atomic.Int64
+
+	runLogger
+}
+
+// NewMediaTableTransform creates the transform writing <outputPrefix>_media.parquet
+func NewMediaTableTransform(outputPrefix string) *MediaTableTransform {
+	columns := map[string]string{
+		"position": "CAST(position AS INTEGER)",
+		"width":    "CAST(width AS INTEGER)",
+		"height":   "CAST(height AS INTEGER)",
+	}
+	return &MediaTableTransform{table: NewSideTable(outputPrefix, MediaTableName, columns)}
+}
+
+// submissionID returns a submission's id, or "" for comments and records without one
+func submissionID(rec *Record) string {
+	if rec.Has("link_id") {
+		return ""
+	}
+	if id, ok := rec.GetString("id"); ok && id != "" {
+		return id
+	}
+	name, _ := rec.GetString("name")
+	if id, ok := strings.CutPrefix(name, "t3_"); ok {
+		return id
+	}
+	return ""
+}
+
+// Apply writes a row for each media item of a submission
+func (t *MediaTableTransform) Apply(rec *Record) (bool, error) {
+	id := submissionID(rec)
+	if id == "" {
+		return true, nil
+	}
+	var metadata map[string]mediaMetadata
+	if raw, ok := rec.Get("media_metadata"); ok {
+		// Removed or failed media leave the field null or malformed, which yields no rows
+		_ = json.Unmarshal(raw, &metadata)
+	}
+	var gallery galleryData
+	if raw, ok := rec.Get("gallery_data"); ok {
+		_ = json.Unmarshal(raw, &gallery)
+	}
+	if len(metadata) == 0 && len(gallery.Items) == 0 {
+		return true, nil
+	}
+	t.submissions.Add(1)
+
+	// Gallery items come first in gallery order, then media only referenced by the metadata
+	// (e.g. images embedded in a self post) in media id order
+	written := make(map[string]bool)
+	for i, item := range gallery.Items {
+		position := i + 1
+		row := newMediaRow(id, item.MediaID, metadata[item.MediaID])
+		row.Position = &position
+		row.Caption = item.Caption
+		if err := t.table.writeRow(row); err != nil {
+			return false, err
+		}
+		written[item.MediaID] = true
+	}
+	ids := make([]string, 0, len(metadata))
+	for mediaID := range metadata {
+		if !written[mediaID] {
+			ids = append(ids, mediaID)
+		}
+	}
+	sort.Strings(ids)
+	for _, mediaID := range ids {
+		if err := t.table.writeRow(newMediaRow(id, mediaID, metadata[mediaID])); err != nil {
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+// newMediaRow builds the row of a media item from its metadata, which may be empty
+func newMediaRow(submissionID, mediaID string, m mediaMetadata) mediaRow {
+	row := mediaRow{
+		SubmissionID: submissionID,
+		MediaID:      mediaID,
+		Type:         m.Type,
+		MimeType:     m.Mime,
+		Status:       m.Status,
+		Width:        m.Source.Width,
+		Height:       m.Source.Height,
+	}
+	// Images have a source URL, animations a GIF and/or MP4 rendition, videos stream URLs
+	for _, url := range []string{m.Source.URL, m.Source.MP4, m.Source.GIF, m.HLSURL, m.DashURL} {
+		if url != "" {
+			// Reddit stores the URLs HTML-escaped (&amp; in query strings)
+			row.URL = html.UnescapeString(url)
+			break
+		}
+	}
+	if row.Width == nil {
+		row.Width, row.Height = m.Width, m.Height
+	}
+	return row
+}
+
+// SideTables returns the media table
+func (t *MediaTableTransform) SideTables() []*SideTable {
+	return []*SideTable{t.table}
+}
+
+// Close reports how many submissions had media and removes the table's intermediate file
+func (t *MediaTableTransform) Close() error {
+	if n := t.submissions.Load(); n > 0 {
+		loggerOrDefault(t.logger).Printf("🖼️ Extracted %d media items of %d submissions", t.table.rows.Load(), n)
+	}
+	return t.table.Close()
+}
@@ -0,0 +1,51 @@
+//go:build linux
+
+package processor
+
+import (
+	"bufio"
+	"bytes"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// availableMemory returns the memory the process can still use: the kernel's MemAvailable,
+// lowered to what the cgroup v2 limit of a container leaves
+func availableMemory() (int64, bool) {
+	file, err := os.Open("/proc/meminfo")
+	if err != nil {
+		return 0, false
+	}
+	defer file.Close()
+	var available int64
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		if rest, ok := strings.CutPrefix(scanner.Text(), "MemAvailable:"); ok {
+			kb, err := strconv.ParseInt(strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(rest), "kB")), 10, 64)
+			if err != nil {
+				return 0, false
+			}
+			available = kb * 1024
+			break
+		}
+	}
+	if available <= 0 {
+		return 0, false
+	}
+	if limit, ok := readCgroupValue("/sys/fs/cgroup/memory.max"); ok {
+		used, _ := readCgroupValue("/sys/fs/cgroup/memory.current")
+		available = min(available, max(limit-used, 0))
+	}
+	return available, available > 0
+}
+
+// readCgroupValue reads a cgroup file holding a number of bytes; "max" means no limit
+func readCgroupValue(path string) (int64, bool) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0, false
+	}
+	n, err := strconv.ParseInt(string(bytes.TrimSpace(data)), 10, 64)
+	return n, err == nil
+}
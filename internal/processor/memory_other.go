@@ -0,0 +1,8 @@
+//go:build !linux
+
+package processor
+
+// availableMemory is unknown without /proc/meminfo, leaving DuckDB its default memory limit
+func availableMemory() (int64, bool) {
+	return 0, false
+}
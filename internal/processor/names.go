@@ -0,0 +1,67 @@
+package processor
+
+import (
+	"encoding/json"
+	"strings"
+)
+
+// Normalized name columns added by -normalized-names
+const (
+	NormalizedSubredditColumn = "subreddit_normalized"
+	NormalizedAuthorColumn    = "author_normalized"
+)
+
+// normalizeSubreddit lowercases a subreddit name and strips an r/ prefix. Reddit treats names
+// case-insensitively, but dumps preserve whatever casing a record was captured with, so every
+// filter and grouping key compares normalized names.
+func normalizeSubreddit(name string) string {
+	name = strings.ToLower(strings.TrimSpace(name))
+	name = strings.TrimPrefix(name, "/")
+	return strings.TrimPrefix(name, "r/")
+}
+
+// normalizeAuthor lowercases an account name and strips a u/ prefix
+func normalizeAuthor(name string) string {
+	name = strings.ToLower(strings.TrimSpace(name))
+	name = strings.TrimPrefix(name, "/")
+	return strings.TrimPrefix(name, "u/")
+}
+
+// nameNormalizer returns the normalization of a name field, or nil for other fields
+func nameNormalizer(field string) func(string) string {
+	switch field {
+	case "subreddit":
+		return normalizeSubreddit
+	case "author":
+		return normalizeAuthor
+	}
+	return nil
+}
+
+// NormalizedNamesTransform adds subreddit_normalized and author_normalized columns, so queries
+// over the output can group and join on names without handling casing themselves
+type NormalizedNamesTransform struct{}
+
+// Apply sets the normalized columns, null when the record lacks the field
+func (t *NormalizedNamesTransform) Apply(rec *Record) (bool, error) {
+	for _, pair := range [][2]string{{NormalizedSubredditColumn, "subreddit"}, {NormalizedAuthorColumn, "author"}} {
+		column, field := pair[0], pair[1]
+		name, ok := rec.GetString(field)
+		if !ok {
+			if err := rec.SetRaw(column, json.RawMessage("null")); err != nil {
+				return false, err
+			}
+			continue
+		}
+		if err := rec.Set(column, nameNormalizer(field)(name)); err != nil {
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+// Fields returns the normalized columns, which fixed schemas such as -extra-json and
+// -canonical-schema have to include
+func (t *NormalizedNamesTransform) Fields() map[string]string {
+	return map[string]string{NormalizedSubredditColumn: "VARCHAR", NormalizedAuthorColumn: "VARCHAR"}
+}
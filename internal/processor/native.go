@@ -0,0 +1,609 @@
+package processor
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/parquet-go/parquet-go"
+	"github.com/parquet-go/parquet-go/compress"
+	"github.com/parquet-go/parquet-go/compress/brotli"
+	"github.com/parquet-go/parquet-go/compress/gzip"
+	parquetzstd "github.com/parquet-go/parquet-go/compress/zstd"
+)
+
+// ConverterNative names the in-process converter, which writes Parquet itself and needs neither
+// bash nor DuckDB
+const ConverterNative = "native"
+
+// nativeRowGroupRows is the native converter's row group size when none is set, DuckDB's default
+const nativeRowGroupRows = 122880
+
+// MetadataNestedAsJSON is the key-value metadata of native Parquet files listing, as a JSON array,
+// the columns of objects or arrays written as JSON strings rather than STRUCT or LIST columns
+const MetadataNestedAsJSON = "pushshift.nested_as_json"
+
+// nativeColumn is a column written by the native converter
+type nativeColumn struct {
+	name string
+	// source is the field of the records the column's values are read from
+	source string
+	node   parquet.Node
+	// nested is set for columns of objects or arrays, which DuckDB would write as STRUCT or LIST
+	nested bool
+	// value converts the raw JSON of the source field, nil when a record lacks it
+	value func(raw []byte) (parquet.Value, error)
+}
+
+// convertNative converts a JSONL file, plain or zstd-compressed, to Parquet in-process. A first
+// pass infers each top-level field's type as DuckDB would: integers become BIGINT, numbers DOUBLE,
+// and objects, arrays and fields of mixed types JSON. Unlike DuckDB, objects and arrays are not
+// written as nested columns; the columns holding them are listed under MetadataNestedAsJSON.
+// Column expressions are translated rather than run, so the ones the native converter can't
+// express fail the part over to the fallbacks.
+func (s *PushshiftProcessor) convertNative(jsonlPath, outputBaseName string, columns map[string]string) error {
+	parquetPath := outputBaseName + ".parquet"
+	s.logger().Printf("🔧 Converting %s to %s natively", jsonlPath, parquetPath)
+
+	types, err := inferFieldTypes(jsonlPath)
+	if err != nil {
+		return err
+	}
+	var cols []nativeColumn
+	for name, expr := range columns {
+		col, err := compileNativeColumn(name, expr)
+		if err != nil {
+			return err
+		}
+		cols = append(cols, col)
+	}
+	for name, seen := range types {
+		if _, ok := columns[name]; !ok {
+			cols = append(cols, inferredColumn(name, seen))
+		}
+	}
+	if len(cols) == 0 {
+		return fmt.Errorf("%s has no fields to write", jsonlPath)
+	}
+
+	group := make(parquet.Group, len(cols))
+	for _, col := range cols {
+		group[col.name] = parquet.Optional(col.node)
+	}
+	schema := parquet.NewSchema("schema", group)
+	// The schema orders its columns by name; sources map fields to the columns reading them
+	ordered := make([]nativeColumn, len(cols))
+	sources := make(map[string][]int)
+	for _, col := range cols {
+		for i, path := range schema.Columns() {
+			if path[0] == col.name {
+				ordered[i] = col
+				sources[col.source] = append(sources[col.source], i)
+			}
+		}
+	}
+
+	file, err := os.Create(parquetPath)
+	if err != nil {
+		return fmt.Errorf("failed to create %s: %v", parquetPath, err)
+	}
+	rows, err := writeNativeParquet(file, jsonlPath, schema, ordered, sources, s.Options.Parquet)
+	if closeErr := file.Close(); err == nil && closeErr != nil {
+		err = fmt.Errorf("failed to write %s: %v", parquetPath, closeErr)
+	}
+	if err != nil {
+		os.Remove(parquetPath)
+		return err
+	}
+	s.logger().Printf("✅ Successfully converted %s (%d rows) to %s", jsonlPath, rows, parquetPath)
+	return nil
+}
+
+// writeNativeParquet writes the records of a JSONL file as rows of the schema, returning the
+// number of rows written
+func writeNativeParquet(w io.Writer, jsonlPath string, schema *parquet.Schema, cols []nativeColumn, sources map[string][]int, opts ParquetOptions) (int64, error) {
+	codec, err := opts.nativeCodec()
+	if err != nil {
+		return 0, err
+	}
+	groupRows := opts.RowGroupRows
+	if groupRows <= 0 {
+		groupRows = nativeRowGroupRows
+	}
+	options := []parquet.WriterOption{schema, parquet.Compression(codec), parquet.MaxRowsPerRowGroup(groupRows)}
+	var nested []string
+	for _, col := range cols {
+		if col.nested {
+			nested = append(nested, col.name)
+		}
+	}
+	if len(nested) > 0 {
+		sort.Strings(nested)
+		encoded, err := json.Marshal(nested)
+		if err != nil {
+			return 0, err
+		}
+		options = append(options, parquet.KeyValueMetadata(MetadataNestedAsJSON, string(encoded)))
+	}
+	if opts.PageSize > 0 {
+		options = append(options, parquet.PageBufferSize(int(opts.PageSize)))
+	}
+	if opts.DisableStatistics {
+		options = append(options, parquet.DataPageStatistics(false))
+		for _, path := range schema.Columns() {
+			options = append(options, parquet.SkipPageBounds(path...))
+		}
+	}
+	writer := parquet.NewWriter(w, options...)
+
+	raws := make([][]byte, len(cols))
+	row := make(parquet.Row, len(cols))
+	// groupBytes is the size of the values of the rows buffered in the current row group
+	var rows, inGroup, groupBytes int64
+	err = forEachLine(jsonlPath, func(lineNum int64, line []byte) error {
+		clear(raws)
+		ok := forEachField(line, func(name, raw []byte) bool {
+			indexes, found := sources[string(name)]
+			if !found && bytes.IndexByte(name, '\\') >= 0 {
+				indexes = sources[fieldName(name)]
+			}
+			for _, i := range indexes {
+				raws[i] = raw
+			}
+			return true
+		})
+		if !ok {
+			return fmt.Errorf("line %d of %s is not a JSON object", lineNum, jsonlPath)
+		}
+		for i, col := range cols {
+			value, err := col.value(raws[i])
+			if err != nil {
+				return fmt.Errorf("line %d of %s, column %s: %v", lineNum, jsonlPath, col.name, err)
+			}
+			definition := 1
+			if value.IsNull() {
+				definition = 0
+			}
+			row[i] = value.Level(0, definition, i)
+			groupBytes += nativeValueSize(value)
+		}
+		if _, err := writer.WriteRows([]parquet.Row{row}); err != nil {
+			return fmt.Errorf("failed to write Parquet row: %v", err)
+		}
+		rows++
+		inGroup++
+		// Row group sizes are measured before encoding and compression, so groups come out smaller
+		if inGroup >= groupRows || (opts.RowGroupBytes > 0 && groupBytes >= opts.RowGroupBytes) {
+			if err := writer.Flush(); err != nil {
+				return fmt.Errorf("failed to write Parquet row group: %v", err)
+			}
+			inGroup, groupBytes = 0, 0
+		}
+		return nil
+	})
+	if err != nil {
+		return rows, err
+	}
+	if err := writer.Close(); err != nil {
+		return rows, fmt.Errorf("failed to finish the Parquet file: %v", err)
+	}
+	return rows, nil
+}
+
+// nativeValueSize returns the size of a value as the writer buffers it, before encoding
+func nativeValueSize(value parquet.Value) int64 {
+	switch {
+	case value.IsNull():
+		return 0
+	case value.Kind() == parquet.ByteArray || value.Kind() == parquet.FixedLenByteArray:
+		return int64(len(value.ByteArray()))
+	case value.Kind() == parquet.Boolean:
+		return 1
+	case value.Kind() == parquet.Int32 || value.Kind() == parquet.Float:
+		return 4
+	case value.Kind() == parquet.Int96:
+		return 12
+	}
+	return 8
+}
+
+// nativeCodec returns the compression codec of the options for the native converter, snappy by
+// default as with DuckDB
+func (o ParquetOptions) nativeCodec() (compress.Codec, error) {
+	switch strings.ToLower(o.Compression) {
+	case "", "snappy":
+		return &parquet.Snappy, nil
+	case "zstd":
+		if o.CompressionLevel > 0 {
+			return &parquetzstd.Codec{Level: zstd.EncoderLevelFromZstd(o.CompressionLevel)}, nil
+		}
+		return &parquet.Zstd, nil
+	case "gzip":
+		if o.CompressionLevel > 9 {
+			return nil, fmt.Errorf("gzip compression levels go up to 9")
+		}
+		if o.CompressionLevel > 0 {
+			return &gzip.Codec{Level: o.CompressionLevel}, nil
+		}
+		return &gzip.Codec{Level: gzip.DefaultCompression}, nil
+	case "brotli":
+		if o.CompressionLevel > 11 {
+			return nil, fmt.Errorf("brotli compression levels go up to 11")
+		}
+		return &brotli.Codec{Quality: o.CompressionLevel}, nil
+	case "lz4":
+		return &parquet.Lz4Raw, nil
+	case "uncompressed":
+		return &parquet.Uncompressed, nil
+	}
+	return nil, fmt.Errorf("unsupported Parquet compression %q", o.Compression)
+}
+
+// forEachLine calls fn with each non-blank line of a JSONL file, decompressing .zst files
+func forEachLine(path string, fn func(lineNum int64, line []byte) error) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to open %s: %v", path, err)
+	}
+	defer file.Close()
+	var r io.Reader = file
+	if strings.HasSuffix(path, ".zst") {
+		zr, err := zstd.NewReader(file, zstd.WithDecoderConcurrency(1))
+		if err != nil {
+			return fmt.Errorf("failed to create zstd reader: %v", err)
+		}
+		defer zr.Close()
+		r = zr
+	}
+	return scanLines(r, path, fn)
+}
+
+// scanLines calls fn with each non-blank line read from r, the content of the file named path
+func scanLines(r io.Reader, path string, fn func(lineNum int64, line []byte) error) error {
+	scanner := newLineScanner(r, scannerBufferSize)
+	var lineNum int64
+	for scanner.Scan() {
+		lineNum++
+		line := scanner.Bytes()
+		if len(bytes.TrimSpace(line)) == 0 {
+			continue
+		}
+		if err := fn(lineNum, line); err != nil {
+			return err
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("failed to read %s: %v", path, err)
+	}
+	return nil
+}
+
+// fieldTypes holds the JSON types seen for each top-level field of records
+type fieldTypes map[string]map[string]bool
+
+// observe adds the fields of a record line, reporting whether it was a JSON object
+func (t fieldTypes) observe(line []byte) bool {
+	return forEachField(line, func(name, raw []byte) bool {
+		seen, found := t[string(name)]
+		if !found {
+			// Names with escapes are only found once unescaped
+			key := fieldName(name)
+			if seen = t[key]; seen == nil {
+				seen = make(map[string]bool)
+				t[key] = seen
+			}
+		}
+		seen[jsonType(raw)] = true
+		return true
+	})
+}
+
+// inferFieldTypes returns the JSON types seen for each top-level field of a JSONL file
+func inferFieldTypes(path string) (fieldTypes, error) {
+	types := make(fieldTypes)
+	err := forEachLine(path, func(lineNum int64, line []byte) error {
+		if !types.observe(line) {
+			return fmt.Errorf("line %d of %s is not a JSON object", lineNum, path)
+		}
+		return nil
+	})
+	return types, err
+}
+
+// inferredType returns the DuckDB type read_json infers for a field from the JSON types seen for
+// it: STRUCT for objects, LIST for arrays, JSON for values of several types, or "" when only
+// nulls were seen
+func inferredType(seen map[string]bool) string {
+	var kinds []string
+	for typ := range seen {
+		if typ != schemaNull {
+			kinds = append(kinds, typ)
+		}
+	}
+	sort.Strings(kinds)
+	switch strings.Join(kinds, ",") {
+	case "":
+		return ""
+	case schemaInteger:
+		return "BIGINT"
+	case schemaNumber, schemaInteger + "," + schemaNumber:
+		return "DOUBLE"
+	case schemaBoolean:
+		return "BOOLEAN"
+	case schemaString:
+		return "VARCHAR"
+	case schemaObject:
+		return "STRUCT"
+	case schemaArray:
+		return "LIST"
+	}
+	return "JSON"
+}
+
+// inferredColumn returns the column of a field given the JSON types seen for it. Nested values
+// are written as JSON, and fields only ever null as strings.
+func inferredColumn(name string, seen map[string]bool) nativeColumn {
+	typ := inferredType(seen)
+	nested := false
+	switch typ {
+	case "":
+		typ = "VARCHAR"
+	case "STRUCT", "LIST":
+		typ, nested = "JSON", true
+	}
+	node, _ := castNode(typ)
+	return nativeColumn{name: name, source: name, node: node, nested: nested, value: castValue(typ, false)}
+}
+
+// nestedAsJSON returns the columns a native Parquet file lists under MetadataNestedAsJSON
+func nestedAsJSON(parquetPath string) ([]string, error) {
+	metadata, err := ReadParquetMetadata(parquetPath)
+	if err != nil {
+		return nil, err
+	}
+	value, ok := metadata[MetadataNestedAsJSON]
+	if !ok {
+		return nil, nil
+	}
+	var columns []string
+	if err := json.Unmarshal([]byte(value), &columns); err != nil {
+		return nil, fmt.Errorf("invalid %s in %s: %v", MetadataNestedAsJSON, parquetPath, err)
+	}
+	return columns, nil
+}
+
+// Column expressions the native converter translates, on a column name plain or double-quoted
+const nativeIdentifier = `("(?:[^"]|"")*"|[A-Za-z_][A-Za-z0-9_]*)`
+
+var (
+	nativeCast     = regexp.MustCompile(`(?i)^\s*(TRY_)?CAST\s*\(\s*` + nativeIdentifier + `\s+AS\s+(\w+)\s*\)\s*$`)
+	nativeToJSON   = regexp.MustCompile(`(?i)^\s*to_json\s*\(\s*` + nativeIdentifier + `\s*\)\s*$`)
+	nativeEpochMs  = regexp.MustCompile(`(?i)^\s*epoch_ms\s*\(\s*` + nativeIdentifier + `(\s*\*\s*1000)?\s*\)\s*$`)
+	nativeStrftime = regexp.MustCompile(`(?i)^\s*strftime\s*\((.*),\s*'((?:[^']|'')*)'\s*\)\s*$`)
+)
+
+// compileNativeColumn translates a column expression: a CAST or TRY_CAST of a column, to_json of a
+// column, epoch_ms of a column of seconds times 1000 or of milliseconds, or strftime of such an
+// epoch_ms
+func compileNativeColumn(name, expr string) (nativeColumn, error) {
+	unsupported := fmt.Errorf("the native converter does not support the expression %q of column %s", expr, name)
+	if m := nativeCast.FindStringSubmatch(expr); m != nil {
+		typ := strings.ToUpper(m[3])
+		node, ok := castNode(typ)
+		if !ok {
+			return nativeColumn{}, unsupported
+		}
+		return nativeColumn{name: name, source: unquoteIdentifier(m[2]), node: node, value: castValue(typ, m[1] != "")}, nil
+	}
+	if m := nativeToJSON.FindStringSubmatch(expr); m != nil {
+		return nativeColumn{name: name, source: unquoteIdentifier(m[1]), node: parquet.JSON(), value: castValue("JSON", false)}, nil
+	}
+	if m := nativeEpochMs.FindStringSubmatch(expr); m != nil {
+		millis := epochMillis(m[2] != "")
+		return nativeColumn{name: name, source: unquoteIdentifier(m[1]), node: parquet.TimestampAdjusted(parquet.Millisecond, false),
+			value: func(raw []byte) (parquet.Value, error) {
+				ms, ok, err := millis(raw)
+				if !ok || err != nil {
+					return parquet.NullValue(), err
+				}
+				return parquet.Int64Value(ms), nil
+			}}, nil
+	}
+	if m := nativeStrftime.FindStringSubmatch(expr); m != nil {
+		epoch := nativeEpochMs.FindStringSubmatch(m[1])
+		layout, err := strftimeLayout(strings.ReplaceAll(m[2], "''", "'"))
+		if epoch == nil || err != nil {
+			return nativeColumn{}, unsupported
+		}
+		millis := epochMillis(epoch[2] != "")
+		return nativeColumn{name: name, source: unquoteIdentifier(epoch[1]), node: parquet.String(),
+			value: func(raw []byte) (parquet.Value, error) {
+				ms, ok, err := millis(raw)
+				if !ok || err != nil {
+					return parquet.NullValue(), err
+				}
+				return parquet.ByteArrayValue([]byte(time.UnixMilli(ms).UTC().Format(layout))), nil
+			}}, nil
+	}
+	return nativeColumn{}, unsupported
+}
+
+// unquoteIdentifier returns the column name of a plain or double-quoted identifier
+func unquoteIdentifier(ident string) string {
+	if strings.HasPrefix(ident, `"`) {
+		return strings.ReplaceAll(ident[1:len(ident)-1], `""`, `"`)
+	}
+	return ident
+}
+
+// castNode returns the Parquet column of a DuckDB type the native converter can cast to
+func castNode(typ string) (parquet.Node, bool) {
+	switch typ {
+	case "BIGINT":
+		return parquet.Int(64), true
+	case "INTEGER", "INT":
+		return parquet.Int(32), true
+	case "DOUBLE":
+		return parquet.Leaf(parquet.DoubleType), true
+	case "FLOAT", "REAL":
+		return parquet.Leaf(parquet.FloatType), true
+	case "BOOLEAN", "BOOL":
+		return parquet.Leaf(parquet.BooleanType), true
+	case "VARCHAR", "TEXT", "STRING":
+		return parquet.String(), true
+	case "JSON":
+		return parquet.JSON(), true
+	}
+	return nil, false
+}
+
+// castValue returns the conversion of raw JSON values to a type of castNode. Values that don't
+// convert are an error, or null for a TRY_CAST.
+func castValue(typ string, try bool) func(raw []byte) (parquet.Value, error) {
+	return func(raw []byte) (parquet.Value, error) {
+		if len(raw) == 0 || raw[0] == 'n' {
+			return parquet.NullValue(), nil
+		}
+		var value parquet.Value
+		ok := true
+		switch typ {
+		case "BIGINT":
+			var n int64
+			n, ok = jsonInt(raw)
+			value = parquet.Int64Value(n)
+		case "INTEGER", "INT":
+			var n int64
+			n, ok = jsonInt(raw)
+			ok = ok && n >= math.MinInt32 && n <= math.MaxInt32
+			value = parquet.Int32Value(int32(n))
+		case "DOUBLE":
+			var f float64
+			f, ok = jsonFloat(raw)
+			value = parquet.DoubleValue(f)
+		case "FLOAT", "REAL":
+			var f float64
+			f, ok = jsonFloat(raw)
+			value = parquet.FloatValue(float32(f))
+		case "BOOLEAN", "BOOL":
+			var b bool
+			b, ok = jsonBool(raw)
+			value = parquet.BooleanValue(b)
+		case "VARCHAR", "TEXT", "STRING":
+			text, _ := jsonText(raw)
+			value = parquet.ByteArrayValue(text)
+		default:
+			value = parquet.ByteArrayValue(raw)
+		}
+		if !ok {
+			if try {
+				return parquet.NullValue(), nil
+			}
+			return value, fmt.Errorf("cannot convert %s to %s", raw, typ)
+		}
+		return value, nil
+	}
+}
+
+// epochMillis returns the conversion of epoch values to milliseconds, from seconds when scaled.
+// It reports false for null values.
+func epochMillis(scaled bool) func(raw []byte) (int64, bool, error) {
+	return func(raw []byte) (int64, bool, error) {
+		if len(raw) == 0 || raw[0] == 'n' {
+			return 0, false, nil
+		}
+		f, ok := jsonFloat(raw)
+		if !ok {
+			return 0, false, fmt.Errorf("%s is not an epoch time", raw)
+		}
+		if scaled {
+			f *= 1000
+		}
+		return int64(math.Round(f)), true, nil
+	}
+}
+
+// strftimeLayout translates a strftime format to a Go time layout, for the specifiers of dates and
+// times of day
+func strftimeLayout(format string) (string, error) {
+	specifiers := map[byte]string{'Y': "2006", 'm': "01", 'd': "02", 'H': "15", 'M': "04", 'S': "05", '%': "%"}
+	var layout strings.Builder
+	for i := 0; i < len(format); i++ {
+		if format[i] != '%' {
+			layout.WriteByte(format[i])
+			continue
+		}
+		i++
+		if i == len(format) || specifiers[format[i]] == "" {
+			return "", fmt.Errorf("unsupported strftime format %q", format)
+		}
+		layout.WriteString(specifiers[format[i]])
+	}
+	return layout.String(), nil
+}
+
+// jsonText returns the content of a JSON string, or the raw JSON of any other value
+func jsonText(raw []byte) ([]byte, bool) {
+	if raw[0] != '"' {
+		return raw, false
+	}
+	if bytes.IndexByte(raw, '\\') < 0 {
+		return raw[1 : len(raw)-1], true
+	}
+	var s string
+	if err := json.Unmarshal(raw, &s); err != nil {
+		return raw, false
+	}
+	return []byte(s), true
+}
+
+// jsonFloat converts a JSON number, numeric string or boolean to a float
+func jsonFloat(raw []byte) (float64, bool) {
+	switch raw[0] {
+	case 't':
+		return 1, true
+	case 'f':
+		return 0, true
+	}
+	text, _ := jsonText(raw)
+	f, err := strconv.ParseFloat(strings.TrimSpace(string(text)), 64)
+	return f, err == nil
+}
+
+// jsonInt converts a JSON number, numeric string or boolean to an integer, rounding fractions as
+// DuckDB does
+func jsonInt(raw []byte) (int64, bool) {
+	text, _ := jsonText(raw)
+	if n, err := strconv.ParseInt(strings.TrimSpace(string(text)), 10, 64); err == nil {
+		return n, true
+	}
+	f, ok := jsonFloat(raw)
+	if !ok || math.IsNaN(f) || f < math.MinInt64 || f >= math.MaxInt64 {
+		return 0, false
+	}
+	return int64(math.Round(f)), true
+}
+
+// jsonBool converts a JSON boolean, number or boolean string to a boolean
+func jsonBool(raw []byte) (bool, bool) {
+	text, quoted := jsonText(raw)
+	switch strings.ToLower(strings.TrimSpace(string(text))) {
+	case "true", "t", "1":
+		return true, true
+	case "false", "f", "0":
+		return false, true
+	}
+	if quoted {
+		return false, false
+	}
+	f, ok := jsonFloat(raw)
+	return f != 0, ok
+}
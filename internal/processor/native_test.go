@@ -0,0 +1,194 @@
+package processor
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/parquet-go/parquet-go"
+)
+
+// nativeValue returns a Parquet value as the Go value it holds, nil for null
+func nativeValue(v parquet.Value) any {
+	if v.IsNull() {
+		return nil
+	}
+	switch v.Kind() {
+	case parquet.Boolean:
+		return v.Boolean()
+	case parquet.Int32:
+		return v.Int32()
+	case parquet.Int64:
+		return v.Int64()
+	case parquet.Float:
+		return v.Float()
+	case parquet.Double:
+		return v.Double()
+	}
+	return string(v.ByteArray())
+}
+
+func TestCastValue(t *testing.T) {
+	tests := []struct {
+		typ     string
+		raw     string
+		want    any
+		wantErr bool
+	}{
+		{"BIGINT", `42`, int64(42), false},
+		{"BIGINT", `-7`, int64(-7), false},
+		{"BIGINT", `"15"`, int64(15), false},
+		{"BIGINT", `2.5`, int64(3), false},
+		{"BIGINT", `-2.5`, int64(-3), false},
+		{"BIGINT", `1e3`, int64(1000), false},
+		{"BIGINT", `true`, int64(1), false},
+		{"BIGINT", `9223372036854775807`, int64(9223372036854775807), false},
+		{"BIGINT", `1e19`, nil, true},
+		{"BIGINT", `"n/a"`, nil, true},
+		{"BIGINT", `{"a":1}`, nil, true},
+		{"BIGINT", `null`, nil, false},
+		{"INTEGER", `2147483647`, int32(2147483647), false},
+		{"INTEGER", `2147483648`, nil, true},
+		{"INT", `"-3"`, int32(-3), false},
+		{"DOUBLE", `1.5`, 1.5, false},
+		{"DOUBLE", `"2.25"`, 2.25, false},
+		{"DOUBLE", `false`, 0.0, false},
+		{"DOUBLE", `"x"`, nil, true},
+		{"FLOAT", `0.5`, float32(0.5), false},
+		{"REAL", `3`, float32(3), false},
+		{"BOOLEAN", `true`, true, false},
+		{"BOOLEAN", `"False"`, false, false},
+		{"BOOLEAN", `"t"`, true, false},
+		{"BOOLEAN", `0`, false, false},
+		{"BOOLEAN", `2`, true, false},
+		{"BOOL", `"yes"`, nil, true},
+		{"VARCHAR", `"text"`, "text", false},
+		{"VARCHAR", `"a \"quote\"\n"`, "a \"quote\"\n", false},
+		{"VARCHAR", `12`, "12", false},
+		{"VARCHAR", `{"a":1}`, `{"a":1}`, false},
+		{"TEXT", `true`, "true", false},
+		{"JSON", `{"a":[1,2]}`, `{"a":[1,2]}`, false},
+		{"JSON", `"s"`, `"s"`, false},
+	}
+	for _, tt := range tests {
+		for _, try := range []bool{false, true} {
+			got, err := castValue(tt.typ, try)([]byte(tt.raw))
+			switch {
+			case tt.wantErr && !try:
+				if err == nil {
+					t.Errorf("CAST(%s AS %s): got %v, want an error", tt.raw, tt.typ, nativeValue(got))
+				}
+			case tt.wantErr:
+				if err != nil || !got.IsNull() {
+					t.Errorf("TRY_CAST(%s AS %s): got %v, %v, want null", tt.raw, tt.typ, nativeValue(got), err)
+				}
+			case err != nil || nativeValue(got) != tt.want:
+				t.Errorf("CAST(%s AS %s): got %#v, %v, want %#v", tt.raw, tt.typ, nativeValue(got), err, tt.want)
+			}
+		}
+	}
+}
+
+func TestCompileNativeColumn(t *testing.T) {
+	tests := []struct {
+		expr   string
+		source string
+		raw    string
+		want   any
+	}{
+		{`CAST(score AS BIGINT)`, "score", `"12"`, int64(12)},
+		{`cast( "score" as integer )`, "score", `12`, int32(12)},
+		{`TRY_CAST("odd ""name""" AS DOUBLE)`, `odd "name"`, `"x"`, nil},
+		{`to_json(media)`, "media", `{"a":1}`, `{"a":1}`},
+		{`epoch_ms(created_utc * 1000)`, "created_utc", `1577836800`, int64(1577836800000)},
+		{`epoch_ms(created_utc*1000)`, "created_utc", `"1577836800.5"`, int64(1577836800500)},
+		{`epoch_ms(created_ms)`, "created_ms", `1577836800123`, int64(1577836800123)},
+		{`epoch_ms(created_utc * 1000)`, "created_utc", `null`, nil},
+		{`strftime(epoch_ms(created_utc * 1000), '%Y-%m-%d')`, "created_utc", `1577923199`, "2020-01-01"},
+		{`strftime(epoch_ms(created_utc * 1000), '%H:%M:%S')`, "created_utc", `1577923199`, "23:59:59"},
+		{`strftime(epoch_ms(created_utc * 1000), '%Y''s %m')`, "created_utc", `1577923199`, "2020's 01"},
+	}
+	for _, tt := range tests {
+		col, err := compileNativeColumn("out", tt.expr)
+		if err != nil {
+			t.Errorf("%s: %v", tt.expr, err)
+			continue
+		}
+		if col.name != "out" || col.source != tt.source {
+			t.Errorf("%s: got column %s reading %s, want out reading %s", tt.expr, col.name, col.source, tt.source)
+		}
+		got, err := col.value([]byte(tt.raw))
+		if err != nil || nativeValue(got) != tt.want {
+			t.Errorf("%s of %s: got %#v, %v, want %#v", tt.expr, tt.raw, nativeValue(got), err, tt.want)
+		}
+	}
+
+	for _, expr := range []string{
+		`CAST(score AS DECIMAL(10,2))`,
+		`CAST(score AS HUGEINT)`,
+		`score + 1`,
+		`lower(author)`,
+		`CAST(score AS BIGINT) + 1`,
+		`strftime(created_utc, '%Y')`,
+		`strftime(epoch_ms(created_utc * 1000), '%A')`,
+	} {
+		if _, err := compileNativeColumn("out", expr); err == nil || !strings.Contains(err.Error(), "does not support") {
+			t.Errorf("%s: got %v, want it unsupported", expr, err)
+		}
+	}
+}
+
+func TestInferredColumn(t *testing.T) {
+	tests := []struct {
+		name   string
+		seen   []string
+		typ    string
+		nested bool
+	}{
+		{"integers", []string{schemaInteger}, "BIGINT", false},
+		{"integers and nulls", []string{schemaInteger, schemaNull}, "BIGINT", false},
+		{"integers and numbers", []string{schemaInteger, schemaNumber}, "DOUBLE", false},
+		{"numbers", []string{schemaNumber}, "DOUBLE", false},
+		{"booleans", []string{schemaBoolean}, "BOOLEAN", false},
+		{"strings", []string{schemaString}, "VARCHAR", false},
+		{"only nulls", []string{schemaNull}, "", false},
+		{"objects", []string{schemaObject, schemaNull}, "STRUCT", true},
+		{"arrays", []string{schemaArray}, "LIST", true},
+		{"mixed", []string{schemaString, schemaBoolean}, "JSON", false},
+		{"objects and arrays", []string{schemaObject, schemaArray}, "JSON", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			seen := make(map[string]bool)
+			for _, typ := range tt.seen {
+				seen[typ] = true
+			}
+			if got := inferredType(seen); got != tt.typ {
+				t.Errorf("got %s, want %s", got, tt.typ)
+			}
+			if col := inferredColumn("f", seen); col.nested != tt.nested {
+				t.Errorf("got nested %v, want %v", col.nested, tt.nested)
+			}
+		})
+	}
+}
+
+func TestStrftimeLayout(t *testing.T) {
+	tests := []struct {
+		format string
+		want   string
+		ok     bool
+	}{
+		{"%Y-%m-%d", "2006-01-02", true},
+		{"%Y-%m-%dT%H:%M:%S", "2006-01-02T15:04:05", true},
+		{"%%Y", "%Y", true},
+		{"plain", "plain", true},
+		{"%B", "", false},
+		{"%", "", false},
+	}
+	for _, tt := range tests {
+		got, err := strftimeLayout(tt.format)
+		if got != tt.want || (err == nil) != tt.ok {
+			t.Errorf("%q: got %q, %v, want %q", tt.format, got, err, tt.want)
+		}
+	}
+}
@@ -0,0 +1,131 @@
+package processor
+
+import (
+	"fmt"
+	"html"
+	"strings"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// TextNormalizeOptions selects the normalizations applied to text fields
+type TextNormalizeOptions struct {
+	// Fields are the top-level string fields to normalize
+	Fields []string
+	// UnescapeHTML decodes HTML entities, repeatedly, so double-escaped text such as &amp;gt; becomes >
+	UnescapeHTML bool
+	// Emoji is "keep", "normalize" (drop variation selectors and skin-tone modifiers) or "strip"
+	Emoji string
+	// Unicode is "", "nfc" or "nfkc"
+	Unicode string
+}
+
+// TextNormalizeTransform makes text fields consistent across dump years, whose encodings differ
+// enough to trip up tokenizers
+type TextNormalizeTransform struct {
+	opts TextNormalizeOptions
+	form norm.Form
+}
+
+// NewTextNormalizeTransform validates the options and creates the transform
+func NewTextNormalizeTransform(opts TextNormalizeOptions) (*TextNormalizeTransform, error) {
+	if len(opts.Fields) == 0 {
+		opts.Fields = []string{"body", "selftext", "title"}
+	}
+	if opts.Emoji == "" {
+		opts.Emoji = "keep"
+	}
+	switch opts.Emoji {
+	case "keep", "normalize", "strip":
+	default:
+		return nil, fmt.Errorf("unsupported emoji mode %q, expected keep, normalize or strip", opts.Emoji)
+	}
+
+	t := &TextNormalizeTransform{opts: opts}
+	switch strings.ToLower(opts.Unicode) {
+	case "", "none":
+		t.opts.Unicode = ""
+	case "nfc":
+		t.form = norm.NFC
+	case "nfkc":
+		t.form = norm.NFKC
+	default:
+		return nil, fmt.Errorf("unsupported Unicode normalization %q, expected nfc or nfkc", opts.Unicode)
+	}
+	return t, nil
+}
+
+// Apply normalizes the configured text fields, leaving unchanged records untouched
+func (t *TextNormalizeTransform) Apply(rec *Record) (bool, error) {
+	for _, field := range t.opts.Fields {
+		value, ok := rec.GetString(field)
+		if !ok || value == "" {
+			continue
+		}
+		if normalized := t.normalize(value); normalized != value {
+			if err := rec.Set(field, normalized); err != nil {
+				return false, err
+			}
+		}
+	}
+	return true, nil
+}
+
+// normalize applies the selected normalizations to one string
+func (t *TextNormalizeTransform) normalize(s string) string {
+	if t.opts.UnescapeHTML {
+		// Older dumps escape entities twice; a few rounds undo that without looping on odd input
+		for i := 0; i < 3 && strings.IndexByte(s, '&') >= 0; i++ {
+			unescaped := html.UnescapeString(s)
+			if unescaped == s {
+				break
+			}
+			s = unescaped
+		}
+	}
+
+	switch t.opts.Emoji {
+	case "normalize":
+		s = strings.Map(func(r rune) rune {
+			if isEmojiModifier(r) {
+				return -1
+			}
+			return r
+		}, s)
+	case "strip":
+		s = strings.Map(func(r rune) rune {
+			if isEmoji(r) || isEmojiModifier(r) || r == '\u200d' {
+				return -1
+			}
+			return r
+		}, s)
+	}
+
+	if t.opts.Unicode != "" {
+		s = t.form.String(s)
+	}
+	return s
+}
+
+// isEmojiModifier reports whether r only alters the presentation of a neighbouring emoji:
+// variation selectors, skin-tone modifiers and tag characters
+func isEmojiModifier(r rune) bool {
+	return r == '\ufe0e' || r == '\ufe0f' ||
+		(r >= 0x1f3fb && r <= 0x1f3ff) ||
+		(r >= 0xe0020 && r <= 0xe007f)
+}
+
+// isEmoji reports whether r lies in one of the emoji and pictograph blocks
+func isEmoji(r rune) bool {
+	switch {
+	case r >= 0x1f000 && r <= 0x1faff: // pictographs, emoticons, transport, flags, supplemental symbols
+		return true
+	case r >= 0x2600 && r <= 0x27bf: // miscellaneous symbols and dingbats
+		return true
+	case r >= 0x2b00 && r <= 0x2bff: // arrows and shapes such as ⭐
+		return true
+	case r == 0x20e3: // combining keycap
+		return true
+	}
+	return false
+}
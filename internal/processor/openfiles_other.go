@@ -0,0 +1,8 @@
+//go:build !unix
+
+package processor
+
+// openFileLimit is unknown without getrlimit, so only the configured limit applies
+func openFileLimit() (int, bool) {
+	return 0, false
+}
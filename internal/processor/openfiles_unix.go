@@ -0,0 +1,17 @@
+//go:build unix
+
+package processor
+
+import "golang.org/x/sys/unix"
+
+// openFileLimit returns the number of files the process may have open, its RLIMIT_NOFILE soft limit
+func openFileLimit() (int, bool) {
+	var limit unix.Rlimit
+	if err := unix.Getrlimit(unix.RLIMIT_NOFILE, &limit); err != nil {
+		return 0, false
+	}
+	if uint64(limit.Cur) > uint64(1<<31-1) {
+		return 1<<31 - 1, true
+	}
+	return int(limit.Cur), true
+}
@@ -0,0 +1,158 @@
+package processor
+
+import (
+	"io"
+	"log/slog"
+	"time"
+)
+
+// Options configures optional behaviour of the PushshiftProcessor.
+// The zero value processes the input into Parquet parts with default settings.
+type Options struct {
+	// ConcatInputs, when set, are read one after another in place of the input path, as one input
+	// without a checksum. See ConcatSources.
+	ConcatInputs []string
+	// InputReader, when set, is read in place of the input path, which then only names the input
+	// in messages and the manifest. It may hold zstd-compressed or plain JSON lines. A stream
+	// can't be seeked, so it can't be resumed and StartAt only filters, and it isn't cached.
+	InputReader io.Reader
+	// CountOnly skips all writing and conversion and only reports line counts
+	CountOnly bool
+	// CountBySubreddit additionally tallies records per subreddit in count-only mode
+	CountBySubreddit bool
+	// Quantiles sketches the distributions of score, num_comments and body length of the records
+	// written, reported in ProcessStats and the manifest
+	Quantiles bool
+	// ReadAhead prefetches the compressed input concurrently for high-latency storage
+	ReadAhead ReadAhead
+	// LineEndings selects the line terminators of the input: LineEndingsAny (the default when
+	// empty) or LineEndingsLF
+	LineEndings string
+	// IOHints advises the Linux page cache that the input is read sequentially, drops input pages
+	// once consumed and drops finished Parquet files, so large runs don't evict other workloads
+	IOHints bool
+	// WaitForData, when positive, reads an input that is still downloading (a torrent streaming
+	// mount or a sparse file being filled in) by waiting for missing data instead of failing,
+	// giving up after a stall of this long
+	WaitForData time.Duration
+	// StartAt, when set, seeks a multi-frame input to the last zstd frame starting before this
+	// time instead of decoding from the beginning. Earlier records in that frame and after it are
+	// still read; StartAtFilter drops them.
+	StartAt time.Time
+	// SkipLines and TakeLines, when set, limit the run to a slice of the decoded input: the
+	// TakeLines lines (or all remaining ones when 0) after the first SkipLines. Skipped lines are
+	// split but not parsed, and reading stops at the end of the slice.
+	SkipLines int64
+	TakeLines int64
+	// Cache, when set, reuses and records what runs learn about each input: its frame index and
+	// line count
+	Cache *InputCache
+	// WriteBehind, when positive, writes part files on a background goroutine with up to this
+	// many 8MB chunks queued, blocking the pipeline only when the queue is full
+	WriteBehind int
+	// Workers is how many parts are converted to Parquet at once while the next part is written.
+	// 0 and 1 convert each part before writing the next. Each pending conversion keeps its JSONL
+	// part on the scratch disk.
+	Workers int
+	// PartCompressionLevel, when between 1 and 3, compresses the intermediate JSONL parts with
+	// zstd at this level, cutting the scratch space they need about 4x at some CPU cost. DuckDB
+	// decompresses them as it converts them.
+	PartCompressionLevel int
+	// SplitOnly keeps the JSONL parts as the output instead of converting them to Parquet, for
+	// tools that consume JSONL. Parts are compressed when PartCompressionLevel is set, which may
+	// then be any zstd level up to 22.
+	SplitOnly bool
+	// Resume continues an interrupted run of the same input and output from the checkpoint it
+	// wrote after its last converted part, keeping the parts before it
+	Resume bool
+	// PartitionBy, when set, writes a Hive-style partitioned dataset under the output path instead
+	// of flat parts: one directory level per key, such as subreddit=pics/year=2021/month=06, each
+	// holding part-00001.parquet files. See PartitionKeys.
+	PartitionBy []string
+	// Routes, when set, sends each record to the outputs of the routes it meets in one pass,
+	// writing a directory of Parquet files per output under the output path. PartitionBy then
+	// partitions each output.
+	Routes *RoutingConfig
+	// MaxOpenFiles bounds the staging files a partitioned run keeps open at once, closing the least
+	// recently written when another is needed. 0 uses 256; either is lowered to stay below the
+	// process's open file limit.
+	MaxOpenFiles int
+	// SpillBufferBytes is the memory a partitioned run buffers records in before writing the
+	// largest partition buffers to their staging files. 0 uses 64MB.
+	SpillBufferBytes int64
+	// SingleFile merges the converted parts into one Parquet file once the run is done, keeping
+	// their row groups, for tools that want one file per dump. The file takes the place of the first
+	// part. Partitioned runs merge each partition's files instead. Runs with failed parts keep their
+	// parts as they are.
+	SingleFile bool
+	// PartSize, when positive, closes parts at this many bytes of JSONL instead of 8GB.
+	// TargetParquetSize takes precedence.
+	PartSize int64
+	// FallbackConverters are shell commands tried in order on a part the primary converter fails
+	// on, e.g. because of a pathological schema, after DuckDB when the primary is the native
+	// converter. Each gets the JSONL part as $1 and the Parquet file to write as $2.
+	FallbackConverters []string
+	// ContinueOnPartError keeps the run going when a part fails to convert with every converter.
+	// The failed parts are recorded in the statistics and manifest, and Process returns
+	// ErrPartsFailed after converting the others.
+	ContinueOnPartError bool
+	// BadRecords, when set, reports the records a transform fails on, e.g. lines that are not
+	// valid JSON, with their input line, offset, part and a hexdump. BadRecordsLog logs them before
+	// the run fails as it would without; BadRecordsQuarantine also writes them to BadRecordsPath
+	// and drops them, counting them in ProcessStats.BadRecords, so the run goes on.
+	BadRecords     string
+	BadRecordsPath string
+	// OnBadLine, when set, checks that each input line is valid JSON before the filters see it,
+	// so a truncated or corrupt line doesn't fail the conversion of its whole part. BadLineSkip
+	// drops invalid lines, BadLineQuarantine also writes them to BadLinesPath, both counting them
+	// in ProcessStats.BadLines, and BadLineFail fails the run with an ErrBadLine. Empty leaves
+	// lines unchecked.
+	OnBadLine    string
+	BadLinesPath string
+	// DroppedSample, when positive, keeps a random sample of up to this many records dropped by
+	// each filter and transform and writes it to DroppedSamplePath as JSON lines once the input is
+	// read, to check that filters don't drop records they were meant to keep
+	DroppedSample     int
+	DroppedSamplePath string
+	// Filters decide which records the run keeps, before any transform sees them. Records they
+	// keep and drop are counted in ProcessStats.MatchedLines and SkippedLines. Filters should only
+	// read the fields they need with Record.Get and its typed variants, which scan the line
+	// without decoding the rest of it.
+	Filters []Transform
+	// Transforms are applied in order to every record before it is written; any of them may drop it
+	Transforms []Transform
+	// BatchTransforms run after Transforms on buffered groups of records, in order
+	BatchTransforms []BatchTransform
+	// TargetParquetSize, when set, sizes parts from the observed JSONL-to-Parquet ratio so each
+	// Parquet file comes out near this many bytes instead of splitting at a fixed 8GB of JSONL
+	TargetParquetSize int64
+	// Parquet tunes row groups, compression and pages of the converted files
+	Parquet ParquetOptions
+	// ParquetMetadata is key-value metadata written into the footer of every Parquet part, such
+	// as the tool version. The settings of the filters and transforms, the input, its checksum
+	// and the part's input lines are added to it.
+	ParquetMetadata map[string]string
+	// ParquetColumns maps column names to DuckDB SQL expressions that replace them during
+	// Parquet conversion, for columns whose type cannot be inferred from JSON (e.g. TIMESTAMP)
+	ParquetColumns map[string]string
+	// Append adds parts to an existing output instead of starting over at part 1: numbering
+	// continues after the highest existing part and the manifest is extended with the new parts
+	Append bool
+	// MinThroughput aborts the run when input throughput stays below a floor, so scheduled jobs
+	// on degraded storage fail fast
+	MinThroughput ThroughputFloor
+	// Sink, when set, receives the processed records instead of Parquet part files
+	Sink Sink
+	// Control, when set, exposes live progress and pause/skip controls for the run
+	Control *Control
+	// OnEvent, when set, is called with each Event of the run: parts started and finished,
+	// progress every 100,000 lines, and the run's outcome. It is called on the processing
+	// goroutine, so it must return quickly.
+	OnEvent func(Event)
+	// Logger receives the run's progress messages, including those of its filters, transforms,
+	// side files and sink; nil uses the standard logger. Warnings are logged at slog.LevelWarn
+	// and parts failing in a run that goes on at slog.LevelError, everything else at
+	// slog.LevelInfo. Instances running concurrently in one process can each be given their own,
+	// e.g. with an attribute telling their messages apart.
+	Logger *slog.Logger
+}
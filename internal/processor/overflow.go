@@ -0,0 +1,151 @@
+package processor
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/parquet-go/parquet-go"
+)
+
+// CanonicalFields is the typed schema of scalar comment and submission fields that stays stable
+// across dump years, as DuckDB column types
+var CanonicalFields = map[string]string{
+	"id":                "VARCHAR",
+	"name":              "VARCHAR",
+	"author":            "VARCHAR",
+	"author_fullname":   "VARCHAR",
+	"subreddit":         "VARCHAR",
+	"subreddit_id":      "VARCHAR",
+	"created_utc":       "BIGINT",
+	"retrieved_on":      "BIGINT",
+	"score":             "BIGINT",
+	"ups":               "BIGINT",
+	"downs":             "BIGINT",
+	"gilded":            "BIGINT",
+	"stickied":          "BOOLEAN",
+	"distinguished":     "VARCHAR",
+	"author_flair_text": "VARCHAR",
+	"permalink":         "VARCHAR",
+	"locked":            "BOOLEAN",
+	"archived":          "BOOLEAN",
+	// Comments
+	"body":             "VARCHAR",
+	"link_id":          "VARCHAR",
+	"parent_id":        "VARCHAR",
+	"controversiality": "BIGINT",
+	// Submissions
+	"title":           "VARCHAR",
+	"selftext":        "VARCHAR",
+	"url":             "VARCHAR",
+	"domain":          "VARCHAR",
+	"num_comments":    "BIGINT",
+	"over_18":         "BOOLEAN",
+	"is_self":         "BOOLEAN",
+	"spoiler":         "BOOLEAN",
+	"link_flair_text": "VARCHAR",
+}
+
+// OverflowColumn holds the fields outside the fixed schema
+const OverflowColumn = "extra_json"
+
+// OverflowTransform gives every record the same columns: each schema field (null when absent)
+// plus extra_json, a JSON object string holding all remaining fields. No data is lost, and the
+// output schema no longer drifts as Reddit adds and retires fields.
+type OverflowTransform struct {
+	schema map[string]string
+	order  []string
+	// casts convert a schema field's value to its type, failing for values the column's TRY_CAST
+	// would turn into null
+	casts map[string]func(raw []byte) (parquet.Value, error)
+}
+
+// NewOverflowTransform creates the transform for a schema of field names to DuckDB types,
+// defaulting to CanonicalFields
+func NewOverflowTransform(schema map[string]string) (*OverflowTransform, error) {
+	if len(schema) == 0 {
+		schema = CanonicalFields
+	}
+	if _, ok := schema[OverflowColumn]; ok {
+		return nil, fmt.Errorf("the schema cannot contain the overflow column %s", OverflowColumn)
+	}
+	order := make([]string, 0, len(schema))
+	casts := make(map[string]func(raw []byte) (parquet.Value, error), len(schema))
+	for name, typ := range schema {
+		order = append(order, name)
+		casts[name] = castValue(typ, false)
+	}
+	sort.Strings(order)
+	return &OverflowTransform{schema: schema, order: order, casts: casts}, nil
+}
+
+// ParseSchemaFields parses "name:TYPE" pairs (TYPE defaults to VARCHAR) into a schema
+func ParseSchemaFields(specs []string) map[string]string {
+	schema := make(map[string]string, len(specs))
+	for _, spec := range specs {
+		name, typ, ok := strings.Cut(spec, ":")
+		if !ok || typ == "" {
+			typ = "VARCHAR"
+		}
+		schema[name] = strings.ToUpper(typ)
+	}
+	return schema
+}
+
+// Apply moves fields outside the schema into extra_json and fills missing schema fields with null.
+// Schema fields holding a value their type can't take, such as a string score, are moved too, so
+// the column's cast doesn't lose them.
+func (t *OverflowTransform) Apply(rec *Record) (bool, error) {
+	var extra bytes.Buffer
+	var moved []string
+	for _, key := range rec.Keys() {
+		raw, _ := rec.Get(key)
+		if cast, known := t.casts[key]; known {
+			if _, err := cast(raw); err == nil {
+				continue
+			}
+		}
+		if extra.Len() == 0 {
+			extra.WriteByte('{')
+		} else {
+			extra.WriteByte(',')
+		}
+		name, _ := json.Marshal(key)
+		extra.Write(name)
+		extra.WriteByte(':')
+		extra.Write(raw)
+		moved = append(moved, key)
+	}
+	for _, key := range moved {
+		rec.Delete(key)
+	}
+
+	for _, name := range t.order {
+		if !rec.Has(name) {
+			if err := rec.SetRaw(name, json.RawMessage("null")); err != nil {
+				return false, err
+			}
+		}
+	}
+
+	if extra.Len() == 0 {
+		return true, rec.SetRaw(OverflowColumn, json.RawMessage("null"))
+	}
+	extra.WriteByte('}')
+	return true, rec.Set(OverflowColumn, extra.String())
+}
+
+// ParquetColumns casts every schema field to its type so columns keep the same type even in
+// parts where a field is always null
+func (t *OverflowTransform) ParquetColumns() map[string]string {
+	columns := ColumnCasts(t.schema)
+	columns[OverflowColumn] = fmt.Sprintf("CAST(%s AS VARCHAR)", OverflowColumn)
+	return columns
+}
+
+// quoteIdentifier quotes a column name for DuckDB SQL
+func quoteIdentifier(name string) string {
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
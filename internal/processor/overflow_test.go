@@ -0,0 +1,35 @@
+package processor
+
+import "testing"
+
+func TestOverflowTransform(t *testing.T) {
+	transform, err := NewOverflowTransform(map[string]string{"id": "VARCHAR", "score": "BIGINT", "over_18": "BOOLEAN"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{"schema fields only", `{"id":"a","score":5,"over_18":false}`, `{"id":"a","score":5,"over_18":false,"extra_json":null}`},
+		{"missing fields", `{"id":"a"}`, `{"id":"a","over_18":null,"score":null,"extra_json":null}`},
+		{"other fields", `{"id":"a","score":5,"over_18":true,"gilded":1,"edited":false}`, `{"id":"a","score":5,"over_18":true,"extra_json":"{\"gilded\":1,\"edited\":false}"}`},
+		{"values the cast takes", `{"id":7,"score":"12","over_18":"true"}`, `{"id":7,"score":"12","over_18":"true","extra_json":null}`},
+		{"values the cast can't take", `{"id":"a","score":"n/a","over_18":{"x":1}}`, `{"id":"a","over_18":null,"score":null,"extra_json":"{\"score\":\"n/a\",\"over_18\":{\"x\":1}}"}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := NewRecord([]byte(tt.in))
+			if keep, err := transform.Apply(rec); !keep || err != nil {
+				t.Fatalf("got %v, %v", keep, err)
+			}
+			if got := rec.Bytes(); string(got) != tt.want {
+				t.Errorf("got %s, want %s", got, tt.want)
+			}
+		})
+	}
+	if got := transform.ParquetColumns()["score"]; got != `TRY_CAST("score" AS BIGINT)` {
+		t.Errorf("got %s", got)
+	}
+}
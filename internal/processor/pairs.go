@@ -0,0 +1,213 @@
+package processor
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"unicode/utf8"
+)
+
+// PairsOptions configures the prompt/response pair export
+type PairsOptions struct {
+	// OutputPrefix names the shards: <prefix>_pairs_00001.jsonl
+	OutputPrefix string
+	// MinScore drops pairs whose response scored lower than this
+	MinScore int64
+	// MinChars and MaxChars drop pairs whose prompt or response falls outside the range; 0 disables
+	MinChars int
+	MaxChars int
+	// SkipSelfReplies drops responses written by the prompt's author
+	SkipSelfReplies bool
+	// SkipAuthors drops pairs where either side was written by one of these accounts (e.g. bots)
+	SkipAuthors []string
+	// ShardBytes starts a new shard once the current one reaches this size
+	ShardBytes int64
+}
+
+// conversationPair is one line of the pairs export
+type conversationPair struct {
+	Prompt        string `json:"prompt"`
+	Response      string `json:"response"`
+	Subreddit     string `json:"subreddit"`
+	PromptID      string `json:"prompt_id"`
+	ResponseID    string `json:"response_id"`
+	PromptScore   int64  `json:"prompt_score"`
+	ResponseScore int64  `json:"response_score"`
+}
+
+// PairsSink reconstructs parent→child comment pairs from parent_id and writes them as
+// prompt/response JSONL for dialogue fine-tuning. Submissions in the same input become prompts
+// for their top-level comments (title and selftext joined).
+// Records are staged in a temporary on-disk table and paired when the sink is closed.
+type PairsSink struct {
+	opts   PairsOptions
+	skip   map[string]bool
+	shards *shardWriter
+
+	db     *sql.DB
+	dbPath string
+	tx     *sql.Tx
+	insert *sql.Stmt
+
+	Pairs   int64
+	Skipped int64
+
+	runLogger
+}
+
+// NewPairsSink creates a pairs writer, filling in defaults for unset options
+func NewPairsSink(opts PairsOptions) (*PairsSink, error) {
+	if opts.ShardBytes <= 0 {
+		opts.ShardBytes = 256 * 1024 * 1024
+	}
+
+	p := &PairsSink{
+		opts:   opts,
+		skip:   make(map[string]bool),
+		shards: &shardWriter{prefix: opts.OutputPrefix + "_pairs", ext: "jsonl", maxBytes: opts.ShardBytes},
+	}
+	for _, author := range opts.SkipAuthors {
+		p.skip[normalizeAuthor(author)] = true
+	}
+
+	var err error
+	p.db, p.dbPath, err = openTempSQLite("pushshift-pairs-*.db", `CREATE TABLE posts (
+		name TEXT PRIMARY KEY, parent TEXT NOT NULL, author TEXT NOT NULL, subreddit TEXT NOT NULL,
+		score INTEGER NOT NULL, text TEXT NOT NULL)`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create pair store: %v", err)
+	}
+	if p.tx, err = p.db.Begin(); err == nil {
+		p.insert, err = p.tx.Prepare(`INSERT OR IGNORE INTO posts (name, parent, author, subreddit, score, text)
+			VALUES (?, ?, ?, ?, ?, ?)`)
+	}
+	if err != nil {
+		p.db.Close()
+		os.Remove(p.dbPath)
+		return nil, fmt.Errorf("failed to prepare pair store: %v", err)
+	}
+	return p, nil
+}
+
+// postText returns a record's fullname and text: the body of a comment, or the title and
+// selftext of a submission
+func postText(rec *Record) (name, text string, ok bool) {
+	id, _ := rec.GetString("id")
+	if id == "" {
+		return "", "", false
+	}
+
+	if title, isSubmission := rec.GetString("title"); isSubmission {
+		text = strings.TrimSpace(title)
+		if selftext, _ := rec.GetString("selftext"); !removedTexts[strings.TrimSpace(selftext)] {
+			text += "\n\n" + strings.TrimSpace(selftext)
+		}
+		return "t3_" + id, text, text != ""
+	}
+
+	body, _ := rec.GetString("body")
+	body = strings.TrimSpace(body)
+	return "t1_" + id, body, !removedTexts[body]
+}
+
+// WriteBatch stages the records for pairing
+func (p *PairsSink) WriteBatch(recs []*Record) error {
+	for _, rec := range recs {
+		name, text, ok := postText(rec)
+		if !ok {
+			continue
+		}
+		parent, _ := rec.GetString("parent_id")
+		author, _ := rec.GetString("author")
+		subreddit, _ := rec.GetString("subreddit")
+		score, _ := rec.GetInt("score")
+		if _, err := p.insert.Exec(name, parent, author, subreddit, score, text); err != nil {
+			return fmt.Errorf("failed to stage %s: %v", name, err)
+		}
+	}
+	return nil
+}
+
+// keep applies the quality filters to a pair
+func (p *PairsSink) keep(pair *conversationPair, promptAuthor, responseAuthor string) bool {
+	if pair.ResponseScore < p.opts.MinScore {
+		return false
+	}
+	if p.skip[normalizeAuthor(promptAuthor)] || p.skip[normalizeAuthor(responseAuthor)] {
+		return false
+	}
+	if p.opts.SkipSelfReplies && strings.EqualFold(promptAuthor, responseAuthor) && promptAuthor != "[deleted]" {
+		return false
+	}
+	for _, text := range []string{pair.Prompt, pair.Response} {
+		n := utf8.RuneCountInString(text)
+		if n < p.opts.MinChars || (p.opts.MaxChars > 0 && n > p.opts.MaxChars) {
+			return false
+		}
+	}
+	return true
+}
+
+// setLogger sets the logger of the run, also used by the shards
+func (p *PairsSink) setLogger(logger *log.Logger) {
+	p.runLogger.setLogger(logger)
+	p.shards.setLogger(logger)
+}
+
+// writePairs joins every staged comment to its parent and writes the pairs that pass the filters
+func (p *PairsSink) writePairs() error {
+	p.insert.Close()
+	if err := p.tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit pair store: %v", err)
+	}
+
+	loggerOrDefault(p.logger).Printf("💬 Pairing comments with their parents")
+	rows, err := p.db.Query(`SELECT parent.name, parent.text, parent.score, parent.author,
+			child.name, child.text, child.score, child.author, child.subreddit
+		FROM posts AS child JOIN posts AS parent ON parent.name = child.parent
+		ORDER BY child.rowid`)
+	if err != nil {
+		return fmt.Errorf("failed to read pair store: %v", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var pair conversationPair
+		var promptAuthor, responseAuthor string
+		if err := rows.Scan(&pair.PromptID, &pair.Prompt, &pair.PromptScore, &promptAuthor,
+			&pair.ResponseID, &pair.Response, &pair.ResponseScore, &responseAuthor, &pair.Subreddit); err != nil {
+			return fmt.Errorf("failed to read pair store: %v", err)
+		}
+		if !p.keep(&pair, promptAuthor, responseAuthor) {
+			p.Skipped++
+			continue
+		}
+
+		encoded, err := marshalJSON(pair)
+		if err != nil {
+			return fmt.Errorf("failed to encode pair: %v", err)
+		}
+		if err := p.shards.write(append(encoded, '\n')); err != nil {
+			return err
+		}
+		p.Pairs++
+	}
+	return rows.Err()
+}
+
+// Close pairs the staged records, writes the shards and removes the temporary store
+func (p *PairsSink) Close() error {
+	defer os.Remove(p.dbPath)
+	defer p.db.Close()
+
+	if err := p.writePairs(); err != nil {
+		return err
+	}
+	if err := p.shards.close(); err != nil {
+		return err
+	}
+	loggerOrDefault(p.logger).Printf("💬 Pairs: %d written in %d shards, %d dropped by quality filters", p.Pairs, p.shards.shard, p.Skipped)
+	return nil
+}
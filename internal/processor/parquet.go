@@ -0,0 +1,89 @@
+package processor
+
+import (
+	"fmt"
+	"log"
+	"strings"
+)
+
+// ParquetOptions tunes the Parquet files for the engine that will read them (Trino, DuckDB,
+// Spark, ...). Zero values keep the converter's defaults.
+type ParquetOptions struct {
+	// Converter selects the primary converter: duckdb, the default, or native
+	Converter string
+	// DuckDBSQL is a text/template of the statements the DuckDB converter runs on each part,
+	// rendered with DuckDBSQLData; DefaultDuckDBSQL when empty
+	DuckDBSQL string
+	// RowGroupRows is the maximum number of rows per row group
+	RowGroupRows int64
+	// RowGroupBytes is the maximum size of a row group. DuckDB only honours it with insertion
+	// order preservation disabled, so setting it lets rows within a part be reordered.
+	RowGroupBytes int64
+	// Compression is the column codec: snappy, zstd, gzip, lz4, brotli or uncompressed
+	Compression string
+	// CompressionLevel applies to zstd, gzip and brotli
+	CompressionLevel int
+	// PageSize is the target data page size in bytes
+	PageSize int64
+	// DisableStatistics skips min/max column statistics
+	DisableStatistics bool
+}
+
+// parquetCodecs are the codecs accepted for ParquetOptions.Compression
+var parquetCodecs = map[string]bool{"snappy": true, "zstd": true, "gzip": true, "lz4": true, "brotli": true, "uncompressed": true}
+
+// Validate checks the options for values no converter accepts
+func (o ParquetOptions) Validate() error {
+	if o.Converter != "" && o.Converter != ConverterDuckDB && o.Converter != ConverterNative {
+		return fmt.Errorf("unsupported converter %q, expected %s or %s", o.Converter, ConverterDuckDB, ConverterNative)
+	}
+	if o.Compression != "" && !parquetCodecs[strings.ToLower(o.Compression)] {
+		return fmt.Errorf("unsupported Parquet compression %q, expected snappy, zstd, gzip, lz4, brotli or uncompressed", o.Compression)
+	}
+	if o.RowGroupRows < 0 || o.RowGroupBytes < 0 || o.PageSize < 0 || o.CompressionLevel < 0 {
+		return fmt.Errorf("Parquet sizes and levels cannot be negative")
+	}
+	if o.DuckDBSQL != "" {
+		if o.Converter == ConverterNative {
+			return fmt.Errorf("a DuckDB SQL template only applies to the duckdb converter")
+		}
+		if _, err := o.renderDuckDBSQL("part.jsonl", "part", nil); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// duckdbCopyOptions returns the extra COPY options and any settings that must precede the COPY
+// statement. Options DuckDB's writer does not support are reported once and ignored.
+func (o ParquetOptions) duckdbCopyOptions() (options, settings string) {
+	var parts []string
+	if o.RowGroupRows > 0 {
+		parts = append(parts, fmt.Sprintf("ROW_GROUP_SIZE %d", o.RowGroupRows))
+	}
+	if o.RowGroupBytes > 0 {
+		parts = append(parts, fmt.Sprintf("ROW_GROUP_SIZE_BYTES %d", o.RowGroupBytes))
+		settings = "SET preserve_insertion_order = false;"
+	}
+	if o.Compression != "" {
+		parts = append(parts, "COMPRESSION "+strings.ToLower(o.Compression))
+	}
+	if o.CompressionLevel > 0 {
+		parts = append(parts, fmt.Sprintf("COMPRESSION_LEVEL %d", o.CompressionLevel))
+	}
+	return strings.Join(parts, ", "), settings
+}
+
+// warnUnsupported logs the options the DuckDB converter cannot apply; the native converter
+// applies them all
+func (o ParquetOptions) warnUnsupported(logger *log.Logger) {
+	if o.Converter == ConverterNative {
+		return
+	}
+	if o.PageSize > 0 {
+		warnf(logger, "⚠️ Warning: the DuckDB converter does not support a data page size, ignoring it")
+	}
+	if o.DisableStatistics {
+		warnf(logger, "⚠️ Warning: the DuckDB converter always writes column statistics, ignoring the request to disable them")
+	}
+}
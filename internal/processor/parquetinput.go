@@ -0,0 +1,89 @@
+package processor
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// IsParquetDataset reports whether an input names converted Parquet outputs rather than a dump:
+// a .parquet file or glob, a directory, or an output prefix with a manifest or part files
+func IsParquetDataset(path string) bool {
+	if strings.HasSuffix(path, ".parquet") {
+		return true
+	}
+	info, err := os.Stat(path)
+	if err == nil {
+		return info.IsDir()
+	}
+	if _, err := os.Stat(ManifestPath(path)); err == nil {
+		return true
+	}
+	parts, _ := filepath.Glob(escapeGlob(path) + "_part_*.parquet")
+	return len(parts) > 0
+}
+
+// duckdbStream is the JSON lines output of a DuckDB query. Reading it reports the query's
+// failure instead of ending early, so a failed read is never mistaken for the end of the data.
+type duckdbStream struct {
+	*io.PipeReader
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// newDuckDBStream starts query and streams its rows as JSON lines
+func newDuckDBStream(query string) *duckdbStream {
+	ctx, cancel := context.WithCancel(context.Background())
+	r, w := io.Pipe()
+	stream := &duckdbStream{PipeReader: r, cancel: cancel, done: make(chan struct{})}
+	go func() {
+		defer close(stream.done)
+		err := queryDuckDBRows(ctx, query, func(row []byte) error {
+			if _, err := w.Write(row); err != nil {
+				return err
+			}
+			_, err := w.Write([]byte{'\n'})
+			return err
+		})
+		if err != nil {
+			err = fmt.Errorf("DuckDB failed reading the Parquet input: %v", err)
+		}
+		w.CloseWithError(err)
+	}()
+	return stream
+}
+
+// Close stops the query if it is still running and waits for it to end
+func (d *duckdbStream) Close() error {
+	d.cancel()
+	d.PipeReader.Close()
+	<-d.done
+	return nil
+}
+
+// openParquetInput reads a Parquet dataset as JSON lines decoded by DuckDB, one record per row in
+// file and row order. Columns keep the types of the dataset: timestamps converted to TIMESTAMP
+// come back as strings.
+func openParquetInput(dataset string, opts inputOptions) (*zstInput, error) {
+	logger := loggerOrDefault(opts.logger)
+	glob := datasetGlob(dataset)
+	query := fmt.Sprintf("SELECT * FROM read_parquet(%s, union_by_name=true)", sqlString(glob))
+	stream := newDuckDBStream(query)
+	logger.Printf("🦆 Reading the Parquet files %s through DuckDB (%s)", glob, duckdbEngine)
+	if opts.readAhead.Chunks > 0 || opts.ioHints || !opts.startAt.IsZero() {
+		warnf(logger, "⚠️ Warning: read-ahead, I/O hints and seeking to a start time are not applied to Parquet inputs")
+	}
+
+	decoded := &timedReader{r: stream}
+	return &zstInput{
+		file:         stream,
+		compressed:   &timedReader{r: strings.NewReader("")},
+		decompressed: decoded,
+		lines:        &lineSplitter{loneCR: opts.lineEndings != LineEndingsLF, logger: logger},
+		Reader:       bufio.NewReaderSize(decoded, bufferSize),
+	}, nil
+}
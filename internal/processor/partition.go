@@ -0,0 +1,680 @@
+package processor
+
+import (
+	"container/list"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+)
+
+// PartitionKeys are the keys Options.PartitionBy accepts. month and day imply the coarser date
+// keys, so month lays partitions out as year=2021/month=06.
+var PartitionKeys = []string{"subreddit", "author", "year", "month", "day"}
+
+// hiveDefaultPartition is the partition of records lacking a key's field, named as Hive, Spark
+// and DuckDB name it
+const hiveDefaultPartition = "__HIVE_DEFAULT_PARTITION__"
+
+// defaultMaxOpenFiles bounds the staging files a partitioned run keeps open at once when
+// Options.MaxOpenFiles is unset
+const defaultMaxOpenFiles = 256
+
+// defaultSpillBuffer is the memory a partitioned run buffers records in when
+// Options.SpillBufferBytes is unset
+const defaultSpillBuffer = 64 * 1024 * 1024
+
+// openFileHeadroom is the number of files left to the input, converters and side tables below the
+// process's open file limit
+const openFileHeadroom = 64
+
+// partitionColumns expands partition keys into the directory levels they lay out, in order:
+// month becomes year and month, and day year, month and day
+func partitionColumns(keys []string) ([]string, error) {
+	var columns []string
+	for _, key := range keys {
+		var expanded []string
+		switch key {
+		case "subreddit", "author", "year":
+			expanded = []string{key}
+		case "month":
+			expanded = []string{"year", "month"}
+		case "day":
+			expanded = []string{"year", "month", "day"}
+		default:
+			return nil, fmt.Errorf("unsupported partition key %q, expected %s", key, strings.Join(PartitionKeys, ", "))
+		}
+		for _, column := range expanded {
+			if !slices.Contains(columns, column) {
+				columns = append(columns, column)
+			} else if column == key {
+				return nil, fmt.Errorf("partition key %s is given twice or implied by another key", key)
+			}
+		}
+	}
+	if len(columns) == 0 {
+		return nil, fmt.Errorf("no partition keys given")
+	}
+	return columns, nil
+}
+
+// ValidatePartitionBy checks partition keys and the options partitioned output can't be combined
+// with
+func (o Options) ValidatePartitionBy() error {
+	if _, err := partitionColumns(o.PartitionBy); err != nil {
+		return err
+	}
+	return o.validateDataset("partitioned")
+}
+
+// validateDataset reports options that can't be combined with output written through a
+// partitionWriter, partitioned or routed as kind says
+func (o Options) validateDataset(kind string) error {
+	switch {
+	case o.SplitOnly:
+		return fmt.Errorf("%s output is written as Parquet and can't be combined with split", kind)
+	case o.Append || o.Resume:
+		return fmt.Errorf("%s output can't be appended to or resumed", kind)
+	case o.PartCompressionLevel > 0:
+		return fmt.Errorf("the staging files of %s output are not compressed, leave out -compress-parts", kind)
+	case o.MaxOpenFiles < 0:
+		return fmt.Errorf("the maximum number of open files can't be negative")
+	case o.SpillBufferBytes < 0:
+		return fmt.Errorf("the spill buffer size can't be negative")
+	}
+	return nil
+}
+
+// partitionPath returns the directory of a record's partition relative to the output root, with
+// values escaped as Hive escapes them. Subreddit and author names are normalized, so the records of
+// AskScience and askscience share a partition.
+func partitionPath(rec *Record, columns []string) string {
+	var created time.Time
+	hasCreated := false
+	if slices.ContainsFunc(columns, func(c string) bool { return c == "year" || c == "month" || c == "day" }) {
+		if utc, ok := rec.GetInt("created_utc"); ok {
+			created, hasCreated = time.Unix(utc, 0).UTC(), true
+		}
+	}
+	dirs := make([]string, len(columns))
+	for i, column := range columns {
+		value := ""
+		switch column {
+		case "subreddit", "author":
+			value, _ = rec.GetString(column)
+			value = nameNormalizer(column)(value)
+		case "year":
+			if hasCreated {
+				value = fmt.Sprintf("%04d", created.Year())
+			}
+		case "month":
+			if hasCreated {
+				value = fmt.Sprintf("%02d", created.Month())
+			}
+		case "day":
+			if hasCreated {
+				value = fmt.Sprintf("%02d", created.Day())
+			}
+		}
+		if value == "" {
+			value = hiveDefaultPartition
+		}
+		dirs[i] = column + "=" + escapePartitionValue(value)
+	}
+	return filepath.Join(dirs...)
+}
+
+// partitionDirs returns the dirs function of a partitionWriter writing each record to the partition
+// of its columns, or to the output root without any
+func partitionDirs(columns []string) func(rec *Record, dirs []string) []string {
+	return func(rec *Record, dirs []string) []string {
+		return append(dirs, partitionPath(rec, columns))
+	}
+}
+
+// escapePartitionValue percent-encodes the bytes of a partition value other than letters, digits,
+// '_' and '-', so values never name other directories
+func escapePartitionValue(value string) string {
+	var b strings.Builder
+	for i := 0; i < len(value); i++ {
+		c := value[i]
+		if c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '_' || c == '-' {
+			b.WriteByte(c)
+		} else {
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+// partition holds the JSONL records waiting to be converted into a partition's next Parquet
+// file: those buffered in memory and those already spilled to its staging file
+type partition struct {
+	dir string
+	// staging is the JSONL file's path, hidden so engines reading the dataset skip it
+	staging string
+	file    *os.File
+	// elem is the partition's entry in the writer's list of open staging files
+	elem *list.Element
+	// buf holds the records not yet spilled to the staging file
+	buf   []byte
+	bytes int64
+	lines int64
+	// files counts the Parquet files written to the partition
+	files int
+}
+
+// partitionWriter routes records to their partitions and converts each partition's records once
+// they reach the part size or the input ends. Records are buffered in memory, and when the buffers
+// outgrow the spill budget the largest are appended to their partitions' staging files, so
+// partitions receiving few records cost few writes. At most maxOpen staging files are open at
+// once: the least recently written one is closed when another must be opened, and reopened for
+// appending once its partition spills again.
+type partitionWriter struct {
+	s    *PushshiftProcessor
+	root string
+	// dirs appends the directories a record is written to, relative to root: its partition, under
+	// each of its outputs in routed runs. Records given none are dropped.
+	dirs       func(rec *Record, dirs []string) []string
+	scratch    []string
+	sizer      *partSizer
+	converting *convertPool
+	partitions map[string]*partition
+	maxOpen    int
+	// open lists the partitions with open staging files, most recently written first
+	open *list.List
+	// spillBudget bounds buffered, the records held in memory across partitions
+	spillBudget int64
+	buffered    int64
+	nextPart    int
+	// spills counts the times buffers were written out to stay within the budget
+	spills int64
+	// reopened counts the staging files reopened after being closed to stay under the limit
+	reopened int64
+	// staged is the JSONL held in buffers and staging files not yet handed to a converter
+	staged int64
+}
+
+// write buffers a record for each of its partitions and reports whether it was written to any
+func (w *partitionWriter) write(rec *Record) (bool, error) {
+	w.scratch = w.dirs(rec, w.scratch[:0])
+	for _, dir := range w.scratch {
+		if err := w.writeTo(dir, rec); err != nil {
+			return false, err
+		}
+	}
+	return len(w.scratch) > 0, nil
+}
+
+// writeTo buffers a record for a partition, converting the partition's records once they have
+// reached the part size and spilling the largest buffers once all of them outgrow the budget
+func (w *partitionWriter) writeTo(dir string, rec *Record) error {
+	p, ok := w.partitions[dir]
+	if !ok {
+		p = &partition{dir: filepath.Join(w.root, dir)}
+		w.partitions[dir] = p
+	}
+	line := rec.Bytes()
+	p.buf = append(append(p.buf, line...), '\n')
+	p.bytes += int64(len(line)) + 1
+	p.lines++
+	w.staged += int64(len(line)) + 1
+	w.buffered += int64(len(line)) + 1
+	if p.bytes >= w.sizer.limit() {
+		return w.convert(p)
+	}
+	if w.buffered > w.spillBudget {
+		return w.spillLargest(w.spillBudget / 2)
+	}
+	return nil
+}
+
+// spillLargest writes the largest buffers to their staging files until at most target bytes stay
+// buffered
+func (w *partitionWriter) spillLargest(target int64) error {
+	var full []*partition
+	for _, p := range w.partitions {
+		if len(p.buf) > 0 {
+			full = append(full, p)
+		}
+	}
+	sort.Slice(full, func(i, j int) bool { return len(full[i].buf) > len(full[j].buf) })
+	for _, p := range full {
+		if w.buffered <= target {
+			break
+		}
+		if err := w.spill(p); err != nil {
+			return err
+		}
+	}
+	if len(full) > 0 {
+		w.spills++
+	}
+	return nil
+}
+
+// spill appends the buffered records of a partition to its staging file and releases the buffer
+func (w *partitionWriter) spill(p *partition) error {
+	if len(p.buf) == 0 {
+		return nil
+	}
+	if p.file == nil {
+		if err := w.openStaging(p); err != nil {
+			return err
+		}
+	} else {
+		w.open.MoveToFront(p.elem)
+	}
+	if _, err := p.file.Write(p.buf); err != nil {
+		return fmt.Errorf("failed to write %s: %v", p.staging, err)
+	}
+	w.buffered -= int64(len(p.buf))
+	p.buf = nil
+	return nil
+}
+
+// openStaging opens the staging file of a partition for appending, first closing the least
+// recently written staging file when the limit of open files is reached
+func (w *partitionWriter) openStaging(p *partition) error {
+	if w.open.Len() >= w.maxOpen {
+		if err := w.closeStaging(w.open.Back().Value.(*partition)); err != nil {
+			return err
+		}
+	}
+	if p.staging == "" {
+		if err := os.MkdirAll(p.dir, 0o755); err != nil {
+			return fmt.Errorf("failed to create partition %s: %v", p.dir, err)
+		}
+		p.staging = filepath.Join(p.dir, fmt.Sprintf(".part-%05d.jsonl", p.files+1))
+	} else {
+		w.reopened++
+	}
+	file, err := os.OpenFile(p.staging, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return fmt.Errorf("failed to open %s: %v", p.staging, err)
+	}
+	p.file = file
+	p.elem = w.open.PushFront(p)
+	return nil
+}
+
+// closeStaging closes the staging file of a partition, keeping what it holds
+func (w *partitionWriter) closeStaging(p *partition) error {
+	if p.file == nil {
+		return nil
+	}
+	err := p.file.Close()
+	p.file = nil
+	w.open.Remove(p.elem)
+	p.elem = nil
+	if err != nil {
+		return fmt.Errorf("failed to write %s: %v", p.staging, err)
+	}
+	return nil
+}
+
+// convert writes out the records of a partition and hands its staging file to a converter,
+// starting a new staging file for the partition's next records
+func (w *partitionWriter) convert(p *partition) error {
+	if err := w.spill(p); err != nil {
+		return err
+	}
+	if err := w.closeStaging(p); err != nil {
+		return err
+	}
+	p.files++
+	part := PartInfo{
+		Number:     w.nextPart,
+		Path:       filepath.Join(p.dir, fmt.Sprintf("part-%05d.parquet", p.files)),
+		Lines:      p.lines,
+		JSONLBytes: p.bytes,
+	}
+	staging := p.staging
+	w.staged -= p.bytes
+	p.staging, p.bytes, p.lines = "", 0, 0
+	w.nextPart++
+	return w.converting.start(part, staging, part.JSONLBytes, 0, nil)
+}
+
+// flush spills every buffer, so no record is held only in memory
+func (w *partitionWriter) flush() error {
+	return w.spillLargest(0)
+}
+
+// finish converts the records of every partition still holding some, in path order
+func (w *partitionWriter) finish() error {
+	dirs := make([]string, 0, len(w.partitions))
+	for dir, p := range w.partitions {
+		if p.lines > 0 {
+			dirs = append(dirs, dir)
+		}
+	}
+	sort.Strings(dirs)
+	for _, dir := range dirs {
+		if err := w.convert(w.partitions[dir]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// consolidate merges the Parquet files of every partition holding more than one into a single
+// file, returning the parts with each partition's files replaced by the merged file
+func (w *partitionWriter) consolidate(parts []PartInfo, inputPath, sha string) ([]PartInfo, error) {
+	byDir := make(map[string][]PartInfo)
+	var dirs []string
+	for _, part := range parts {
+		dir := filepath.Dir(part.Path)
+		if _, ok := byDir[dir]; !ok {
+			dirs = append(dirs, dir)
+		}
+		byDir[dir] = append(byDir[dir], part)
+	}
+	merging := 0
+	for _, dir := range dirs {
+		if len(byDir[dir]) > 1 {
+			merging++
+		}
+	}
+	if merging == 0 {
+		return parts, nil
+	}
+	w.s.logger().Printf("🧱 Merging the files of %d partitions into one file each", merging)
+	consolidated := make([]PartInfo, 0, len(dirs))
+	for _, dir := range dirs {
+		group := byDir[dir]
+		if len(group) > 1 {
+			merged, err := w.s.mergePartFiles(group, inputPath, sha)
+			if err != nil {
+				return nil, err
+			}
+			group = merged
+		}
+		consolidated = append(consolidated, group...)
+	}
+	return consolidated, nil
+}
+
+// abandon closes and removes every staging file of a run that failed
+func (w *partitionWriter) abandon() {
+	for _, p := range w.partitions {
+		if p.file != nil {
+			p.file.Close()
+			p.file = nil
+		}
+		p.buf = nil
+		if p.staging != "" {
+			removeScratch(w.s.logger(), p.staging)
+		}
+	}
+}
+
+// maxOpenFiles returns the number of staging files a partitioned run may keep open: MaxOpenFiles
+// or its default, lowered to leave headroom below the process's open file limit
+func (s *PushshiftProcessor) maxOpenFiles() int {
+	n := s.Options.MaxOpenFiles
+	if n == 0 {
+		n = defaultMaxOpenFiles
+	}
+	if limit, ok := openFileLimit(); ok && n > limit-openFileHeadroom {
+		available := max(limit-openFileHeadroom, 1)
+		warnf(s.logger(), "⚠️ Warning: Keeping at most %d staging files open instead of %d, the process may only open %d files (see ulimit -n)", available, n, limit)
+		n = available
+	}
+	return n
+}
+
+// sweepOrphanedStaging removes staging files left in a partitioned output by a killed run
+func sweepOrphanedStaging(s *PushshiftProcessor, root string) error {
+	return filepath.WalkDir(root, func(path string, d os.DirEntry, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return fmt.Errorf("failed to list partitions: %v", err)
+		}
+		if !d.IsDir() && strings.HasPrefix(d.Name(), ".part-") && strings.HasSuffix(d.Name(), ".jsonl") {
+			s.logger().Printf("🧹 Removing orphaned staging file %s", path)
+			removeScratch(s.logger(), path)
+		}
+		return nil
+	})
+}
+
+// processToPartitions writes the input as a Hive-style partitioned Parquet dataset under
+// outputPath, one directory level per partition column. Routed runs write a directory per output
+// of Options.Routes, partitioned within when PartitionBy is set too.
+func (s *PushshiftProcessor) processToPartitions(inputPath, outputPath string) (ProcessStats, error) {
+	start := time.Now()
+	stats := s.newStats()
+
+	if len(s.Options.PartitionBy) > 0 {
+		if err := s.Options.ValidatePartitionBy(); err != nil {
+			return stats, err
+		}
+	}
+	var routes *router
+	if s.Options.Routes != nil {
+		var err error
+		if routes, err = s.Options.validateRoutes(); err != nil {
+			return stats, err
+		}
+	}
+	if err := ValidateBadRecords(s.Options.BadRecords, s.Options.BadRecordsPath); err != nil {
+		return stats, err
+	}
+	if err := ValidateOnBadLine(s.Options.OnBadLine, s.Options.BadLinesPath); err != nil {
+		return stats, err
+	}
+	var columns []string
+	if len(s.Options.PartitionBy) > 0 {
+		columns, _ = partitionColumns(s.Options.PartitionBy)
+	}
+	switch {
+	case routes != nil && len(columns) > 0:
+		s.logger().Printf("📖 Reading zst file into %s, partitioned by %s: %s", strings.Join(s.Options.Routes.Outputs(), ", "), strings.Join(columns, ", "), inputPath)
+	case routes != nil:
+		s.logger().Printf("📖 Reading zst file into %s: %s", strings.Join(s.Options.Routes.Outputs(), ", "), inputPath)
+	default:
+		s.logger().Printf("📖 Reading zst file into partitions by %s: %s", strings.Join(columns, ", "), inputPath)
+	}
+	stopWatchdog := s.startWatchdog()
+	defer stopWatchdog()
+	ctl := s.Options.Control
+
+	in, err := s.openInput(inputPath)
+	if err != nil {
+		return stats, err
+	}
+	defer in.Close()
+	if err := sweepOrphanedStaging(s, outputPath); err != nil {
+		return stats, err
+	}
+	s.Options.Parquet.warnUnsupported(s.logger())
+
+	sizer := &partSizer{target: s.Options.TargetParquetSize, fixed: s.Options.PartSize, logger: s.logger()}
+	converting := s.newConvertPool(&stats, sizer)
+	defer converting.wait()
+	knownSHA := s.knownInputSHA256(inputPath)
+	converting.metadata = func(part PartInfo) map[string]string {
+		return s.partMetadata(inputPath, knownSHA, part)
+	}
+	w := &partitionWriter{
+		s:           s,
+		root:        outputPath,
+		dirs:        partitionDirs(columns),
+		sizer:       sizer,
+		converting:  converting,
+		partitions:  make(map[string]*partition),
+		maxOpen:     s.maxOpenFiles(),
+		open:        list.New(),
+		spillBudget: s.Options.SpillBufferBytes,
+		nextPart:    1,
+	}
+	if w.spillBudget == 0 {
+		w.spillBudget = defaultSpillBuffer
+	}
+	routed := make(map[string]int64)
+	if routes != nil {
+		w.dirs = routes.dirs(columns, routed)
+	}
+	var unrouted int64
+	finished := false
+	defer func() {
+		if !finished {
+			w.abandon()
+		}
+	}()
+
+	batchSize := batchTransformSize(s.Options.BatchTransforms)
+	var pending, spare []*Record
+	flush := func() error {
+		if len(pending) == 0 {
+			return nil
+		}
+		kept, err := applyBatchTransforms(s.Options.BatchTransforms, pending)
+		if err != nil {
+			return fmt.Errorf("batch transform failed before line %d: %v", stats.TotalLines, err)
+		}
+		stats.DroppedLines += int64(len(pending) - len(kept))
+		for _, rec := range kept {
+			written, err := w.write(rec)
+			if err != nil {
+				return err
+			}
+			if !written {
+				unrouted++
+				stats.DroppedLines++
+				continue
+			}
+			if stats.Quantiles != nil {
+				observeQuantiles(stats.Quantiles, rec.Bytes())
+			}
+		}
+		stats.PeakScratchBytes = max(stats.PeakScratchBytes, w.staged-w.buffered+converting.scratch)
+		spare = append(spare, pending...)
+		pending = pending[:0]
+		return nil
+	}
+
+	scanner := in.scanner(scannerBufferSize)
+	input := s.startStages(in, scanner, s.lineSlice())
+	defer input.stop()
+	progress := func(lines int64) {
+		s.emit(Event{Kind: EventProgress, Lines: lines})
+	}
+	var logged int64
+	for {
+		if err := ctl.abortErr(); err != nil {
+			return stats, err
+		}
+		if ctl.Paused() {
+			if err := flush(); err != nil {
+				return stats, err
+			}
+			if err := w.flush(); err != nil {
+				return stats, err
+			}
+			s.logger().Printf("⏸️ Paused after %d lines, buffers flushed", stats.TotalLines)
+			ctl.waitIfPaused()
+			s.logger().Printf("▶️ Resumed")
+		}
+
+		staged, err := input.next(&stats, progress)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return stats, err
+		}
+		// The staged record is reused once its batch is consumed, so batches hold copies
+		var rec *Record
+		if n := len(spare); n > 0 {
+			rec, spare = spare[n-1], spare[:n-1]
+		} else {
+			rec = &Record{}
+		}
+		rec.Reset(staged.Bytes())
+		if pending = append(pending, rec); len(pending) >= max(batchSize, 1) {
+			if err := flush(); err != nil {
+				return stats, err
+			}
+		}
+
+		if stats.TotalLines/1000000 > logged {
+			logged = stats.TotalLines / 1000000
+			s.logger().Printf("🔄 Progress: Processed %d lines into %d partitions", stats.TotalLines, len(w.partitions))
+		}
+	}
+	if err := flush(); err != nil {
+		return stats, err
+	}
+	if err := w.finish(); err != nil {
+		return stats, err
+	}
+	ctl.setStage("converting")
+	if err := converting.collect(0); err != nil {
+		return stats, err
+	}
+	finished = true
+	if err := s.convertSideTables(&stats); err != nil {
+		return stats, err
+	}
+
+	stats.ExecutionTime = time.Since(start)
+	if !input.cut {
+		stats.InputSHA256 = in.SHA256()
+	}
+	if knownSHA == "" && stats.InputSHA256 != "" {
+		s.stampParts(stats.Parts, stats.InputSHA256)
+	}
+	if s.Options.SingleFile && len(stats.FailedParts) == 0 {
+		parts, err := w.consolidate(stats.Parts, inputPath, stats.InputSHA256)
+		if err != nil {
+			return stats, err
+		}
+		stats.Parts = parts
+	}
+	if routes != nil {
+		for _, output := range s.Options.Routes.Outputs() {
+			stats.Routes = append(stats.Routes, RouteStats{Output: output, Records: routed[output]})
+		}
+	}
+	stats.Members = in.Members()
+	in.lines.logNormalized()
+	in.frames.logChecksums()
+	in.addTelemetry(&stats.Stages)
+	stats.Stages.Records = stats.TotalLines
+	input.addTelemetry(&stats.Stages)
+
+	if err := writeManifest(outputPath, inputPath, stats, nil); err != nil {
+		warnf(s.logger(), "⚠️ Warning: Failed to write manifest: %v", err)
+	}
+	s.updateCache(inputPath, in, stats)
+
+	s.logger().Printf("🗂️ Wrote %d partitions in %d files under %s", len(w.partitions), len(stats.Parts), outputPath)
+	if unrouted > 0 {
+		s.logger().Printf("🧭 %d records met no route and have no default output, they were dropped", unrouted)
+	}
+	if w.spills > 0 {
+		s.logger().Printf("🗂️ Buffered records were spilled to staging files %d times to stay within %.2f MB", w.spills, float64(w.spillBudget)/1024/1024)
+	}
+	if w.reopened > 0 {
+		s.logger().Printf("🗂️ Staging files were reopened %d times to keep at most %d open", w.reopened, w.maxOpen)
+	}
+	s.logger().Printf("✅ Processing complete")
+	s.logger().Printf("%s", stats.String())
+
+	if len(stats.FailedParts) > 0 {
+		failed := &ErrPartsFailed{}
+		for _, part := range stats.FailedParts {
+			failed.Parts = append(failed.Parts, part.Number)
+		}
+		return stats, failed
+	}
+	return stats, nil
+}
@@ -0,0 +1,28 @@
+package processor
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestPartitionPath(t *testing.T) {
+	columns := []string{"subreddit", "author", "year", "month"}
+	tests := []struct {
+		name   string
+		record string
+		want   string
+	}{
+		{"lowercase", `{"subreddit":"askscience","author":"spez","created_utc":1622505600}`, "subreddit=askscience/author=spez/year=2021/month=06"},
+		{"mixed case", `{"subreddit":"AskScience","author":"Spez","created_utc":1622505600}`, "subreddit=askscience/author=spez/year=2021/month=06"},
+		{"prefixed", `{"subreddit":"r/AskScience","author":"/u/SPEZ","created_utc":1622505600}`, "subreddit=askscience/author=spez/year=2021/month=06"},
+		{"escaped", `{"subreddit":"pics","author":"Some.One","created_utc":1622505600}`, "subreddit=pics/author=some%2Eone/year=2021/month=06"},
+		{"missing fields", `{"subreddit":"pics"}`, "subreddit=pics/author=__HIVE_DEFAULT_PARTITION__/year=__HIVE_DEFAULT_PARTITION__/month=__HIVE_DEFAULT_PARTITION__"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := partitionPath(NewRecord([]byte(tt.record)), columns); got != filepath.FromSlash(tt.want) {
+				t.Errorf("got %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,47 @@
+package processor
+
+import "log"
+
+// assumedParquetRatio is the JSONL-to-Parquet size ratio used for the first part, before any
+// conversion has been observed; Reddit dumps typically shrink four to six times
+const assumedParquetRatio = 4.0
+
+// minPartSize keeps adaptive sizing from producing a flood of tiny parts after an odd estimate
+const minPartSize = 64 * 1024 * 1024
+
+// partSizer chooses JSONL part boundaries. Without a target every part is fixed bytes, or
+// partSizeThreshold when unset; with one, parts are sized from the observed JSONL-to-Parquet ratio so the Parquet files
+// land near the target.
+type partSizer struct {
+	logger       *log.Logger
+	target       int64
+	fixed        int64
+	jsonlBytes   int64
+	parquetBytes int64
+}
+
+// limit returns the JSONL size at which the next part should be closed
+func (p *partSizer) limit() int64 {
+	if p.target <= 0 {
+		if p.fixed > 0 {
+			return p.fixed
+		}
+		return partSizeThreshold
+	}
+	ratio := assumedParquetRatio
+	if p.parquetBytes > 0 {
+		ratio = float64(p.jsonlBytes) / float64(p.parquetBytes)
+	}
+	return max(int64(float64(p.target)*ratio), minPartSize)
+}
+
+// observe records a converted part, refining the ratio for the following parts
+func (p *partSizer) observe(jsonlBytes, parquetBytes int64) {
+	if p.target <= 0 || parquetBytes <= 0 {
+		return
+	}
+	p.jsonlBytes += jsonlBytes
+	p.parquetBytes += parquetBytes
+	p.logger.Printf("📐 Observed JSONL/Parquet ratio %.2fx, next part closes at %.0f MB of JSONL",
+		float64(p.jsonlBytes)/float64(p.parquetBytes), float64(p.limit())/1024/1024)
+}
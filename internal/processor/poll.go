@@ -0,0 +1,200 @@
+package processor
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync/atomic"
+)
+
+// PollTableName names the side table of poll options
+const PollTableName = "polls"
+
+// Poll columns added in columns mode
+const (
+	PollEndColumn         = "poll_end_utc"
+	PollTotalVotesColumn  = "poll_total_votes"
+	PollOptionsColumn     = "poll_options"
+	PollOptionVotesColumn = "poll_option_votes"
+)
+
+// pollColumns lists the poll columns in the order they are added
+var pollColumns = []string{PollEndColumn, PollTotalVotesColumn, PollOptionsColumn, PollOptionVotesColumn}
+
+// pollData is a submission's poll_data object. Vote counts are only present once voting has ended.
+type pollData struct {
+	VotingEnd  *int64 `json:"voting_end_timestamp"`
+	TotalVotes *int64 `json:"total_vote_count"`
+	Options    []struct {
+		ID        json.RawMessage `json:"id"`
+		Text      string          `json:"text"`
+		VoteCount *int64          `json:"vote_count"`
+	} `json:"options"`
+}
+
+// endUTC returns the poll's voting end in epoch seconds; Reddit stores it in milliseconds
+func (p pollData) endUTC() *int64 {
+	if p.VotingEnd == nil {
+		return nil
+	}
+	end := *p.VotingEnd
+	if end > 1e11 {
+		end /= 1000
+	}
+	return &end
+}
+
+// pollRow is a row of the polls table: one option of a poll
+type pollRow struct {
+	SubmissionID string `json:"submission_id"`
+	// Position is the option's place in the poll, starting at 1
+	Position       int    `json:"position"`
+	OptionID       string `json:"option_id"`
+	Text           string `json:"text"`
+	VoteCount      *int64 `json:"vote_count"`
+	TotalVoteCount *int64 `json:"total_vote_count"`
+	VotingEndUTC   *int64 `json:"voting_end_utc"`
+}
+
+// PollTransform parses the poll_data object of poll submissions.
+//
+// Modes:
+//
+//	columns - poll_end_utc, poll_total_votes, poll_options and poll_option_votes columns, null
+//	          for records without a poll
+//	table   - one row per poll option in the polls side table
+//	both    - columns and table
+type PollTransform struct {
+	Columns bool
+
+	table *SideTable
+	polls atomic.Int64
+
+	runLogger
+}
+
+// NewPollTransform creates the transform for a mode; keep needs no transform and returns nil.
+// The table is written to <outputPrefix>_polls.parquet.
+func NewPollTransform(mode, outputPrefix string) (*PollTransform, error) {
+	t := &PollTransform{}
+	switch mode {
+	case "keep":
+		return nil, nil
+	case "columns", "both":
+		t.Columns = true
+	case "table":
+	default:
+		return nil, fmt.Errorf("unsupported poll data mode %q, expected keep, columns, table or both", mode)
+	}
+	if mode == "table" || mode == "both" {
+		t.table = NewSideTable(outputPrefix, PollTableName, map[string]string{
+			"vote_count":       "CAST(vote_count AS BIGINT)",
+			"total_vote_count": "CAST(total_vote_count AS BIGINT)",
+			"voting_end_utc":   "CAST(voting_end_utc AS BIGINT)",
+		})
+	}
+	return t, nil
+}
+
+// Apply parses the record's poll and adds its columns and table rows
+func (t *PollTransform) Apply(rec *Record) (bool, error) {
+	var poll pollData
+	hasPoll := false
+	if id := submissionID(rec); id != "" {
+		if raw, ok := rec.Get("poll_data"); ok && json.Unmarshal(raw, &poll) == nil && len(poll.Options) > 0 {
+			hasPoll = true
+			t.polls.Add(1)
+			if t.table != nil {
+				if err := t.writeOptions(id, poll); err != nil {
+					return false, err
+				}
+			}
+		}
+	}
+	if !t.Columns {
+		return true, nil
+	}
+	if !hasPoll {
+		for _, name := range pollColumns {
+			if err := rec.SetRaw(name, json.RawMessage("null")); err != nil {
+				return false, err
+			}
+		}
+		return true, nil
+	}
+
+	texts := make([]string, len(poll.Options))
+	votes := make([]*int64, len(poll.Options))
+	for i, option := range poll.Options {
+		texts[i] = option.Text
+		votes[i] = option.VoteCount
+	}
+	values := []any{poll.endUTC(), poll.TotalVotes, texts, votes}
+	for i, name := range pollColumns {
+		if err := rec.Set(name, values[i]); err != nil {
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+// writeOptions writes a table row for each option of a submission's poll
+func (t *PollTransform) writeOptions(submissionID string, poll pollData) error {
+	for i, option := range poll.Options {
+		row := pollRow{
+			SubmissionID:   submissionID,
+			Position:       i + 1,
+			OptionID:       strings.Trim(string(option.ID), `"`),
+			Text:           option.Text,
+			VoteCount:      option.VoteCount,
+			TotalVoteCount: poll.TotalVotes,
+			VotingEndUTC:   poll.endUTC(),
+		}
+		if err := t.table.writeRow(row); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Fields returns the poll columns added in columns mode, which fixed schemas such as
+// -extra-json and -canonical-schema have to include
+func (t *PollTransform) Fields() map[string]string {
+	if !t.Columns {
+		return nil
+	}
+	return map[string]string{
+		PollEndColumn:         "BIGINT",
+		PollTotalVotesColumn:  "BIGINT",
+		PollOptionsColumn:     "VARCHAR[]",
+		PollOptionVotesColumn: "BIGINT[]",
+	}
+}
+
+// ParquetColumns types the poll columns, which are null for most records
+func (t *PollTransform) ParquetColumns() map[string]string {
+	columns := make(map[string]string)
+	for name, typ := range t.Fields() {
+		columns[name] = fmt.Sprintf("CAST(%s AS %s)", name, typ)
+	}
+	return columns
+}
+
+// SideTables returns the polls table in table mode
+func (t *PollTransform) SideTables() []*SideTable {
+	if t.table == nil {
+		return nil
+	}
+	return []*SideTable{t.table}
+}
+
+// Close reports how many polls were parsed and removes the table's intermediate file
+func (t *PollTransform) Close() error {
+	if n := t.polls.Load(); n > 0 {
+		loggerOrDefault(t.logger).Printf("🗳️ Parsed %d polls", n)
+	}
+	if t.table == nil {
+		return nil
+	}
+	return t.table.Close()
+}
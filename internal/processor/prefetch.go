@@ -0,0 +1,119 @@
+package processor
+
+import (
+	"io"
+	"log"
+)
+
+// DefaultReadAheadChunkSize is the size of each prefetched chunk when only -read-ahead is set
+const DefaultReadAheadChunkSize = 8 * 1024 * 1024
+
+// ReadAhead configures concurrent prefetching of the compressed input. The zero value reads the
+// file sequentially.
+type ReadAhead struct {
+	// Chunks is the number of chunks fetched concurrently ahead of the decompressor
+	Chunks int
+	// ChunkSize is the size of each chunk, DefaultReadAheadChunkSize when zero
+	ChunkSize int64
+}
+
+// prefetchChunk is the result of one positional read
+type prefetchChunk struct {
+	data []byte
+	err  error
+}
+
+// prefetchReader reads a file as fixed-size chunks with several positional reads in flight, and
+// hands them out in order. On high-latency storage such as NFS or a FUSE-mounted bucket, this
+// keeps the decompressor fed instead of paying the round-trip latency for every read.
+type prefetchReader struct {
+	pending <-chan chan prefetchChunk
+	free    chan []byte
+	stop    chan struct{}
+	cur     []byte
+	buf     []byte
+	err     error
+}
+
+// newPrefetchReader starts prefetching size bytes of r
+func newPrefetchReader(r io.ReaderAt, size int64, opts ReadAhead, logger *log.Logger) *prefetchReader {
+	chunkSize := opts.ChunkSize
+	if chunkSize <= 0 {
+		chunkSize = DefaultReadAheadChunkSize
+	}
+	pending := make(chan chan prefetchChunk, opts.Chunks)
+	p := &prefetchReader{
+		pending: pending,
+		free:    make(chan []byte, opts.Chunks+1),
+		stop:    make(chan struct{}),
+	}
+	logger.Printf("📡 Reading input ahead in %d concurrent chunks of %.2f MB", opts.Chunks, float64(chunkSize)/1024/1024)
+
+	go func() {
+		defer close(pending)
+		for offset := int64(0); offset < size; offset += chunkSize {
+			result := make(chan prefetchChunk, 1)
+			// The pending queue holds at most opts.Chunks reads, which bounds both concurrency
+			// and memory
+			select {
+			case pending <- result:
+			case <-p.stop:
+				return
+			}
+			var buf []byte
+			select {
+			case buf = <-p.free:
+			default:
+				buf = make([]byte, chunkSize)
+			}
+			go func(offset int64, buf []byte) {
+				want := min(chunkSize, size-offset)
+				n, err := r.ReadAt(buf[:want], offset)
+				if err == io.EOF {
+					// A file that shrank while being read ends early
+					err = nil
+					if int64(n) < want {
+						err = io.ErrUnexpectedEOF
+					}
+				}
+				result <- prefetchChunk{data: buf[:n], err: err}
+			}(offset, buf)
+		}
+	}()
+	return p
+}
+
+// Read implements io.Reader
+func (p *prefetchReader) Read(b []byte) (int, error) {
+	for len(p.cur) == 0 {
+		if p.err != nil {
+			return 0, p.err
+		}
+		if p.buf != nil {
+			select {
+			case p.free <- p.buf:
+			default:
+			}
+			p.buf = nil
+		}
+		result, ok := <-p.pending
+		if !ok {
+			p.err = io.EOF
+			continue
+		}
+		chunk := <-result
+		if chunk.err != nil {
+			p.err = chunk.err
+			continue
+		}
+		p.cur, p.buf = chunk.data, chunk.data
+	}
+	n := copy(b, p.cur)
+	p.cur = p.cur[n:]
+	return n, nil
+}
+
+// Close stops scheduling further reads; reads already in flight finish in the background
+func (p *prefetchReader) Close() {
+	close(p.stop)
+}
@@ -0,0 +1,110 @@
+package processor
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+//go:embed data/presets.json
+var bundledPresets []byte
+
+// Preset bundles the subreddits and date range of a common study setup
+type Preset struct {
+	Description string   `json:"description"`
+	Subreddits  []string `json:"subreddits"`
+	// Start and End bound created_utc as UTC dates or times; End is exclusive
+	Start string `json:"start,omitempty"`
+	End   string `json:"end,omitempty"`
+	// Source is "bundled" or the path of the presets file defining the preset
+	Source string `json:"-"`
+}
+
+// DefaultPresetsPath returns the per-user presets file, which extends and overrides the bundled presets
+func DefaultPresetsPath() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "pushshift_presets.json"
+	}
+	return filepath.Join(home, ".pushshift", "presets.json")
+}
+
+// LoadPresets returns the bundled presets, extended and overridden by name by the presets of an
+// optional JSON file of the same layout. A missing file is not an error.
+func LoadPresets(userPath string) (map[string]Preset, error) {
+	presets, err := parsePresets(bundledPresets, "bundled")
+	if err != nil {
+		return nil, fmt.Errorf("failed to load bundled presets: %v", err)
+	}
+	if userPath == "" {
+		return presets, nil
+	}
+	data, err := os.ReadFile(userPath)
+	if os.IsNotExist(err) {
+		return presets, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read presets: %v", err)
+	}
+	user, err := parsePresets(data, userPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load presets from %s: %v", userPath, err)
+	}
+	for name, preset := range user {
+		presets[name] = preset
+	}
+	return presets, nil
+}
+
+// parsePresets decodes and validates a presets file
+func parsePresets(data []byte, source string) (map[string]Preset, error) {
+	var presets map[string]Preset
+	if err := json.Unmarshal(data, &presets); err != nil {
+		return nil, err
+	}
+	for name, preset := range presets {
+		for _, bound := range []string{preset.Start, preset.End} {
+			if bound == "" {
+				continue
+			}
+			if _, err := ParseStartAt(bound); err != nil {
+				return nil, fmt.Errorf("preset %s: %v", name, err)
+			}
+		}
+		preset.Source = source
+		presets[name] = preset
+	}
+	return presets, nil
+}
+
+// PresetNames returns the names of presets in order, for listings and error messages
+func PresetNames(presets map[string]Preset) []string {
+	names := make([]string, 0, len(presets))
+	for name := range presets {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+// String describes the preset on one line
+func (p Preset) String() string {
+	period := "any time"
+	switch {
+	case p.Start != "" && p.End != "":
+		period = p.Start + " to " + p.End
+	case p.Start != "":
+		period = "from " + p.Start
+	case p.End != "":
+		period = "before " + p.End
+	}
+	subreddits := strings.Join(p.Subreddits, ", ")
+	if len(p.Subreddits) == 0 {
+		subreddits = "all subreddits"
+	}
+	return fmt.Sprintf("%s (%s): %s", p.Description, period, subreddits)
+}
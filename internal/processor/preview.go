@@ -0,0 +1,84 @@
+package processor
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+)
+
+// maxPreviewCellWidth truncates long values (e.g. comment bodies) in table previews
+const maxPreviewCellWidth = 60
+
+// PreviewRecords writes the first n records of a zst dump to w.
+// With no fields the records are pretty-printed JSON; otherwise a table of the selected fields is printed.
+func PreviewRecords(inputPath string, n int, fields []string, w io.Writer) error {
+	in, err := openZstInput(inputPath, inputOptions{})
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	scanner := in.scanner(scannerBufferSize)
+
+	var table *tabwriter.Writer
+	if len(fields) > 0 {
+		table = tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(table, strings.Join(fields, "\t"))
+	}
+
+	for i := 0; i < n && scanner.Scan(); i++ {
+		line := scanner.Bytes()
+
+		if table != nil {
+			cells := make([]string, len(fields))
+			for j, field := range fields {
+				cells[j] = previewCell(line, field)
+			}
+			fmt.Fprintln(table, strings.Join(cells, "\t"))
+			continue
+		}
+
+		var pretty bytes.Buffer
+		if err := json.Indent(&pretty, line, "", "  "); err != nil {
+			// Show malformed lines as-is rather than hiding them
+			pretty.Reset()
+			pretty.Write(line)
+		}
+		pretty.WriteString("\n")
+		if _, err := w.Write(pretty.Bytes()); err != nil {
+			return fmt.Errorf("error writing record: %v", err)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return inputError(err)
+	}
+
+	if table != nil {
+		return table.Flush()
+	}
+	return nil
+}
+
+// previewCell renders a single field of a record for table output
+func previewCell(line []byte, field string) string {
+	raw, ok := topLevelField(line, field)
+	if !ok {
+		return "-"
+	}
+
+	value := string(raw)
+	var s string
+	if raw[0] == '"' && json.Unmarshal(raw, &s) == nil {
+		value = s
+	}
+
+	// Keep each record on a single table row
+	value = strings.Join(strings.Fields(value), " ")
+	if len([]rune(value)) > maxPreviewCellWidth {
+		value = string([]rune(value)[:maxPreviewCellWidth-1]) + "…"
+	}
+	return value
+}
@@ -6,8 +6,9 @@ import (
 	"io"
 	"log"
 	"os"
-	"os/exec"
-	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/klauspost/compress/zstd"
@@ -21,192 +22,560 @@ const (
 
 // PushshiftProcessor represents the processor for processing Pushshift data
 // Process flow: Decompress file -> write to part files of 8GB -> convert each part to parquet using DuckDB
-type PushshiftProcessor struct{}
+//
+// A processor keeps all of its state in its Options, so any number of them can run concurrently
+// in one process as long as they write to different output prefixes.
+type PushshiftProcessor struct {
+	Options Options
+
+	bridgeOnce sync.Once
+	bridge     *log.Logger
+}
+
+// logger returns the logger of the run's messages, writing to Options.Logger when it is set
+func (s *PushshiftProcessor) logger() *log.Logger {
+	if s.Options.Logger == nil {
+		return log.Default()
+	}
+	s.bridgeOnce.Do(func() { s.bridge = newSlogBridge(s.Options.Logger) })
+	return s.bridge
+}
 
 // Process implements the processor interface
 // It decompresses the input zst file, splits it into parts, and converts each part to Parquet format
 func (s *PushshiftProcessor) Process(inputPath, outputPath string) (ProcessStats, error) {
+	s.emit(Event{Kind: EventRunStarted})
+	var stats ProcessStats
+	var err error
+	if s.Options.InputReader != nil {
+		err = s.Options.validateInputReader()
+	}
+	switch {
+	case err != nil:
+	case s.Options.CountOnly:
+		stats, err = s.countOnly(inputPath)
+	case s.Options.Sink != nil:
+		stats, err = s.processToSink(inputPath)
+	case len(s.Options.PartitionBy) > 0 || s.Options.Routes != nil:
+		stats, err = s.processToPartitions(inputPath, outputPath)
+	default:
+		stats, err = s.processToParts(inputPath, outputPath)
+	}
+	if err != nil {
+		s.emit(Event{Kind: EventError, Lines: stats.TotalLines, Err: err})
+		return stats, err
+	}
+	s.warnFilters(stats)
+	s.emit(Event{Kind: EventRunFinished, Lines: stats.TotalLines, Stats: &stats})
+	return stats, nil
+}
+
+// warnFilters warns about filters that dropped every record they saw, which usually means they
+// were misconfigured, e.g. with a misspelled subreddit or dates outside the dump
+func (s *PushshiftProcessor) warnFilters(stats ProcessStats) {
+	for _, f := range stats.Filters {
+		if !f.Transform && f.Seen > 0 && f.Dropped == f.Seen {
+			warnf(s.logger(), "⚠️ Warning: Filter %s dropped all %d records it saw, check its settings", f.Name, f.Seen)
+		}
+	}
+}
+
+// processToParts writes the input to JSONL part files and converts each of them to Parquet
+func (s *PushshiftProcessor) processToParts(inputPath, outputPath string) (ProcessStats, error) {
 	start := time.Now()
-	stats := ProcessStats{}
+	stats := s.newStats()
 
-	log.Printf("📖 Reading and processing zst file: %s", inputPath)
+	if s.Options.SplitOnly {
+		if err := s.Options.validateSplitOnly(); err != nil {
+			return stats, err
+		}
+	} else if err := ValidatePartCompressionLevel(s.Options.PartCompressionLevel); err != nil {
+		return stats, err
+	}
+	if err := ValidateBadRecords(s.Options.BadRecords, s.Options.BadRecordsPath); err != nil {
+		return stats, err
+	}
+	if err := ValidateOnBadLine(s.Options.OnBadLine, s.Options.BadLinesPath); err != nil {
+		return stats, err
+	}
+	if s.Options.SingleFile {
+		if err := s.Options.validateSingleFile(); err != nil {
+			return stats, err
+		}
+	}
 
-	// Open input file
-	inputFile, err := os.Open(inputPath)
-	if err != nil {
-		return stats, fmt.Errorf("failed to open input file: %v", err)
+	s.logger().Printf("📖 Reading and processing zst file: %s", inputPath)
+	stopWatchdog := s.startWatchdog()
+	defer stopWatchdog()
+
+	var resumed *Checkpoint
+	var err error
+	if s.Options.Resume {
+		if resumed, err = s.loadResume(inputPath, outputPath); err != nil {
+			return stats, err
+		}
 	}
-	defer inputFile.Close()
 
-	// Create zstd reader
-	zr, err := zstd.NewReader(inputFile)
+	// Open input file and wrap it in a buffered zstd decompressor, entering it after the
+	// checkpoint of a resumed run
+	var bufferedReader *zstInput
+	var ranges []lineRange
+	if resumed != nil {
+		bufferedReader, ranges, err = s.openResumed(inputPath, resumed)
+	} else {
+		bufferedReader, err = s.openInput(inputPath)
+		ranges = s.lineSlice()
+	}
 	if err != nil {
-		return stats, fmt.Errorf("failed to create zstd reader: %v", err)
+		return stats, err
+	}
+	defer bufferedReader.Close()
+	stats.StartOffset = bufferedReader.startOffset
+	if resumed != nil {
+		stats.StartOffset = resumed.StartOffset
 	}
-	defer zr.Close()
 
-	// Create a buffered reader around the decompressor for better performance
-	bufferedReader := bufio.NewReaderSize(zr, bufferSize)
+	// Intermediate parts are removed on every exit path, including errors, and leftovers of a
+	// killed run are swept before starting
+	if err := sweepOrphanedParts(s.logger(), outputPath); err != nil {
+		return stats, err
+	}
+	var scratchPath string
+	defer func() {
+		if scratchPath != "" {
+			removeScratch(s.logger(), scratchPath)
+		}
+	}()
 
 	partNum := 1
+	var previous *Manifest
+	if s.Options.Append {
+		if previous, err = readManifest(outputPath); err != nil {
+			return stats, err
+		}
+		if partNum, err = nextPartNumber(outputPath, previous); err != nil {
+			return stats, err
+		}
+		if partNum > 1 {
+			s.logger().Printf("➕ Appending to existing output, starting at part %d", partNum)
+		}
+	}
+	if resumed != nil {
+		if err := s.removePartsAfter(outputPath, resumed.Part); err != nil {
+			return stats, err
+		}
+		partNum = resumed.Part + 1
+		stats.TotalLines = resumed.Lines
+		stats.Parts = append(stats.Parts, resumed.Parts...)
+		stats.FailedParts = append(stats.FailedParts, resumed.FailedParts...)
+		if resumed.Stats != nil {
+			resumed.Stats.restore(&stats)
+			bufferedReader.countedLines = resumed.Stats.CountedLines
+		}
+	}
 	totalBytesProcessed := int64(0)
 	startTime := time.Now()
-	var lastPartWritten bool
+	lastPartWritten := resumed != nil && len(resumed.Parts)+len(resumed.FailedParts) > 0
+	s.Options.Parquet.warnUnsupported(s.logger())
+	if s.Options.IOHints {
+		warnIOHintsUnsupported(s.logger())
+	}
+	sizer := &partSizer{target: s.Options.TargetParquetSize, fixed: s.Options.PartSize, logger: s.logger()}
+	if resumed != nil {
+		// Parts are sized as the interrupted run would have sized them
+		for _, part := range resumed.Parts {
+			sizer.observe(part.JSONLBytes, part.ParquetBytes)
+		}
+	}
+	if level := s.Options.PartCompressionLevel; level > 0 && s.Options.SplitOnly {
+		s.logger().Printf("🗜️ Compressing JSONL parts with zstd level %d", level)
+	} else if level > 0 {
+		s.logger().Printf("🗜️ Compressing intermediate parts with zstd level %d", level)
+	}
+
+	converting := s.newConvertPool(&stats, sizer)
+	defer converting.wait()
+	converting.checkpoint = s.newCheckpointer(inputPath, outputPath, bufferedReader, resumed)
+	// The checksum is only known before the input is read through when the cache has it
+	knownSHA := s.knownInputSHA256(inputPath)
+	converting.metadata = func(part PartInfo) map[string]string {
+		return s.partMetadata(inputPath, knownSHA, part)
+	}
 
 	// Create scanner for reading line by line
 	scanner := bufio.NewScanner(bufferedReader)
 	// Set a larger buffer for scanner to handle potentially large JSON lines
 	scanBuf := make([]byte, scannerBufferSize)
 	scanner.Buffer(scanBuf, scannerBufferSize)
+	// Normalize CRLF and lone CR terminators of dumps mangled by transfer tools
+	scanner.Split(bufferedReader.lines.split)
+	// Reading and transforms run on their own goroutines, ahead of the part being written
+	input := s.startStages(bufferedReader, scanner, ranges)
+	defer input.stop()
 
 	for {
 		// Process one part file
-		partPath := fmt.Sprintf("%s_part_%03d.jsonl", outputPath, partNum)
-		bytesWritten, linesProcessed, err := processPartFile(scanner, partPath)
+		partPath := s.intermediatePartPath(outputPath, partNum)
+		s.Options.Control.startPart(partNum)
+		s.emit(Event{Kind: EventPartStarted, Part: partNum, Lines: stats.TotalLines})
+		scratchPath = partPath
+		firstLine := input.lastLine + 1
+		bytesWritten, linesProcessed, err := s.processPartFile(input, partPath, partNum, sizer.limit(), &stats)
+		scratchBytes := bytesWritten
+		if s.Options.PartCompressionLevel > 0 {
+			if info, err := os.Stat(partPath); err == nil {
+				scratchBytes = info.Size()
+			}
+		}
+		stats.PeakScratchBytes = max(stats.PeakScratchBytes, converting.scratch+scratchBytes)
+		if abortErr := s.Options.Control.abortErr(); abortErr != nil {
+			// Don't spend time converting a part of an aborted run
+			return stats, abortErr
+		}
 
 		// Only consider this a successful write if we wrote some data
 		if bytesWritten > 0 {
 			lastPartWritten = true
 			totalBytesProcessed += bytesWritten
-			stats.TotalLines += linesProcessed
 
 			// Log progress
 			elapsed := time.Since(startTime)
 			speed := float64(totalBytesProcessed) / elapsed.Seconds() / 1024 / 1024 // MB/s
-			log.Printf("📊 Part %d: Processed %d lines, %.2f MB/s, %.2f MB written",
+			s.logger().Printf("📊 Part %d: Processed %d lines, %.2f MB/s, %.2f MB written",
 				partNum, linesProcessed, speed, float64(bytesWritten)/1024/1024)
 
-			// Convert to Parquet using DuckDB
-			log.Printf("🔄 Converting part %d to Parquet format...", partNum)
-			parquetBaseName := fmt.Sprintf("%s_part_%03d", outputPath, partNum)
-			err = convertToParquet(partPath, parquetBaseName)
-			if err != nil {
-				return stats, fmt.Errorf("failed to convert part %d to parquet: %v", partNum, err)
-			}
-
-			// Remove the JSONL file after successful conversion
-			if err := os.Remove(partPath); err != nil {
-				log.Printf("⚠️ Warning: Failed to remove intermediate file %s: %v", partPath, err)
+			if s.Options.SplitOnly {
+				// The JSONL part is the output
+				s.logger().Printf("💾 Part %d kept as %s", partNum, partPath)
+				stats.Parts = append(stats.Parts, PartInfo{
+					Number:     partNum,
+					Path:       partPath,
+					Lines:      linesProcessed,
+					JSONLBytes: bytesWritten,
+					FirstLine:  firstLine,
+					LastLine:   input.lastLine,
+				})
+				s.emit(Event{Kind: EventPartFinished, Part: partNum, Lines: linesProcessed, Bytes: bytesWritten, Path: partPath})
+			} else {
+				// Convert to Parquet, concurrently with the next parts when there are several workers
+				part := PartInfo{
+					Number:     partNum,
+					Path:       fmt.Sprintf("%s_part_%03d.parquet", outputPath, partNum),
+					Lines:      linesProcessed,
+					JSONLBytes: bytesWritten,
+					FirstLine:  firstLine,
+					LastLine:   input.lastLine,
+				}
+				var counts *CheckpointStats
+				if converting.checkpoint != nil {
+					counts = stats.checkpointStats(input.counted)
+				}
+				if err := converting.start(part, partPath, scratchBytes, input.lastOffset, counts); err != nil {
+					return stats, err
+				}
 			}
+			scratchPath = ""
 
 			partNum++
-		} else if !lastPartWritten {
-			// If we didn't write anything and never wrote a part before, return an error
-			return stats, fmt.Errorf("no data was written from the input file")
+		} else {
+			// Nothing was written to this part, so don't leave an empty intermediate file behind
+			removeScratch(s.logger(), partPath)
+			scratchPath = ""
+
+			if !lastPartWritten && stats.TotalLines == 0 && err == io.EOF && s.Options.SkipLines > 0 {
+				return stats, fmt.Errorf("the input ended within the first %d lines skipped by -skip-lines", s.Options.SkipLines)
+			}
+			if !lastPartWritten && stats.TotalLines == 0 {
+				// If we didn't read anything and never wrote a part before, return an error
+				return stats, fmt.Errorf("no data was written from the input file")
+			}
+			if !lastPartWritten && err == io.EOF {
+				warnf(s.logger(), "⚠️ Warning: All %d lines were dropped, no parts were written", stats.TotalLines)
+			}
 		}
 
 		// Handle errors or EOF
 		if err != nil {
+			if err == io.EOF && input.cut {
+				s.logger().Printf("✅ Reached the last line selected by -take-lines")
+				break
+			}
 			if err == io.EOF {
-				log.Printf("✅ Reached end of input file")
+				s.logger().Printf("✅ Reached end of input file")
 				break
 			}
-			return stats, fmt.Errorf("failed to process part %d: %v", partNum, err)
+			return stats, fmt.Errorf("failed to process part %d: %w", partNum, err)
 		}
 	}
 
+	s.Options.Control.setStage("converting")
+	if err := converting.collect(0); err != nil {
+		return stats, err
+	}
+	if err := s.convertSideTables(&stats); err != nil {
+		return stats, err
+	}
+
 	// Calculate final stats
 	stats.ExecutionTime = time.Since(start)
-	log.Printf("✅ Processing complete")
-	log.Printf("%s", stats.String())
+	// The checksum of an input read only up to a slice of its lines would be wrong
+	if !input.cut {
+		stats.InputSHA256 = bufferedReader.SHA256()
+	}
+	if knownSHA == "" && stats.InputSHA256 != "" {
+		s.stampParts(stats.Parts, stats.InputSHA256)
+	}
+	switch {
+	case s.Options.SingleFile && len(stats.FailedParts) > 0:
+		warnf(s.logger(), "⚠️ Warning: %d parts failed to convert, keeping the converted parts instead of merging them into a single file", len(stats.FailedParts))
+	case s.Options.SingleFile:
+		if err := s.mergeParts(&stats, inputPath); err != nil {
+			return stats, err
+		}
+	}
+	stats.Members = bufferedReader.Members()
+	bufferedReader.lines.logNormalized()
+	bufferedReader.frames.logChecksums()
+	bufferedReader.addTelemetry(&stats.Stages)
+	stats.Stages.Records = stats.TotalLines
+	input.addTelemetry(&stats.Stages)
+
+	if err := writeManifest(outputPath, inputPath, stats, previous); err != nil {
+		warnf(s.logger(), "⚠️ Warning: Failed to write manifest: %v", err)
+	} else {
+		converting.checkpoint.remove()
+	}
+	s.updateCache(inputPath, bufferedReader, stats)
 
+	s.logger().Printf("✅ Processing complete")
+	s.logger().Printf("%s", stats.String())
+
+	if len(stats.FailedParts) > 0 {
+		failed := &ErrPartsFailed{}
+		for _, part := range stats.FailedParts {
+			failed.Parts = append(failed.Parts, part.Number)
+		}
+		return stats, failed
+	}
 	return stats, nil
 }
 
-// processPartFile processes one part file until it reaches the size threshold
-func processPartFile(scanner *bufio.Scanner, outputPath string) (int64, int64, error) {
+// newStats creates the statistics of a run, with digests when quantiles are requested
+func (s *PushshiftProcessor) newStats() ProcessStats {
+	stats := ProcessStats{Runs: 1}
+	if s.Options.Quantiles {
+		stats.Quantiles = make(map[string]*TDigest)
+	}
+	return stats
+}
+
+// processPartFile writes the records of the staged input to part partNum until it reaches
+// sizeLimit bytes of JSONL. It returns the bytes and lines written; lines read and dropped are
+// counted in stats.
+func (s *PushshiftProcessor) processPartFile(input *stagedInput, outputPath string, partNum int, sizeLimit int64, stats *ProcessStats) (int64, int64, error) {
+	ctl := s.Options.Control
+	input.part = partNum
+
 	outputFile, err := os.Create(outputPath)
 	if err != nil {
 		return 0, 0, err
 	}
 	defer outputFile.Close()
 
-	writer := bufio.NewWriterSize(outputFile, bufferSize)
-	defer writer.Flush()
+	// With write-behind, disk writes happen on a background goroutine and the write time only
+	// counts how long the pipeline was blocked by a full queue
+	var output io.Writer = outputFile
+	writeBufferSize := bufferSize
+	var async *asyncWriter
+	if s.Options.WriteBehind > 0 {
+		async = newAsyncWriter(outputFile, s.Options.WriteBehind)
+		defer async.Close()
+		output = async
+		writeBufferSize = writeBehindChunkSize
+	}
 
-	var bytesWritten int64
-	var linesProcessed int64
+	// Compression happens before write-behind queues the compressed chunks
+	var encoder *zstd.Encoder
+	if level := s.Options.PartCompressionLevel; level > 0 {
+		encoder, err = zstd.NewWriter(output, zstd.WithEncoderLevel(zstd.EncoderLevelFromZstd(level)))
+		if err != nil {
+			return 0, 0, fmt.Errorf("failed to create zstd writer: %v", err)
+		}
+		defer encoder.Close()
+		output = encoder
+	}
+
+	// Deferred before the flush below so the final flush is included in the write time
+	timed := &timedWriter{w: output}
+	defer func() {
+		stats.Stages.WrittenBytes += timed.bytes
+		stats.Stages.WriteTime += timed.time
+	}()
+	writer := bufio.NewWriterSize(timed, writeBufferSize)
+	defer writer.Flush()
 
-	for bytesWritten < partSizeThreshold {
-		if !scanner.Scan() {
-			// Check for errors
-			if err := scanner.Err(); err != nil {
-				return bytesWritten, linesProcessed, fmt.Errorf("scanner error: %v", err)
+	// finish flushes everything buffered to the part file
+	finish := func() error {
+		if err := writer.Flush(); err != nil {
+			return fmt.Errorf("error flushing buffer: %v", err)
+		}
+		if encoder != nil {
+			if err := encoder.Close(); err != nil {
+				return fmt.Errorf("error compressing part file: %v", err)
+			}
+		}
+		if async != nil {
+			drainStart := time.Now()
+			defer func() { timed.time += time.Since(drainStart) }()
+			if err := async.Close(); err != nil {
+				return fmt.Errorf("error writing part file: %v", err)
 			}
-			// No error means we've reached EOF
-			return bytesWritten, linesProcessed, io.EOF
 		}
+		return nil
+	}
 
-		// Get the line and add newline
-		line := scanner.Bytes()
+	var bytesWritten int64
+	var linesProcessed int64
 
+	// writeLine appends one record to the part file
+	writeLine := func(line []byte) error {
 		// Write the line with a newline character
 		written, err := writer.Write(line)
 		if err != nil {
-			return bytesWritten, linesProcessed, fmt.Errorf("error writing line: %v", err)
+			return fmt.Errorf("error writing line: %v", err)
 		}
 
 		// Add newline after each line
 		if _, err := writer.Write([]byte("\n")); err != nil {
-			return bytesWritten, linesProcessed, fmt.Errorf("error writing newline: %v", err)
+			return fmt.Errorf("error writing newline: %v", err)
 		}
 
 		bytesWritten += int64(written + 1) // +1 for newline
 		linesProcessed++
+		if stats.Quantiles != nil {
+			observeQuantiles(stats.Quantiles, line)
+		}
 
 		// Log progress occasionally
 		if linesProcessed%1000000 == 0 {
-			log.Printf("🔄 Progress: Processed %d lines, %.2f MB written",
+			s.logger().Printf("🔄 Progress: Processed %d lines, %.2f MB written",
 				linesProcessed, float64(bytesWritten)/1024/1024)
 		}
+		return nil
 	}
 
-	// Make sure to flush before returning
-	if err := writer.Flush(); err != nil {
-		return bytesWritten, linesProcessed, fmt.Errorf("error flushing buffer: %v", err)
+	// Records waiting for batch transforms, and spare records reused to avoid allocations
+	batchSize := batchTransformSize(s.Options.BatchTransforms)
+	var pending, spare []*Record
+	takeRecord := func() *Record {
+		if n := len(spare); n > 0 {
+			rec := spare[n-1]
+			spare = spare[:n-1]
+			return rec
+		}
+		return &Record{}
 	}
 
-	return bytesWritten, linesProcessed, nil
-}
-
-// convertToParquet converts a JSONL file to Parquet format using DuckDB
-func convertToParquet(jsonlPath, outputBaseName string) error {
-	// Use absolute path for the script - assuming it's in the project root
-	workingDir, err := os.Getwd()
-	if err != nil {
-		return fmt.Errorf("failed to get current working directory: %v", err)
+	// flushPending runs buffered records through the batch transforms and writes the survivors
+	flushPending := func() error {
+		if len(pending) == 0 {
+			return nil
+		}
+		kept, err := applyBatchTransforms(s.Options.BatchTransforms, pending)
+		if err != nil {
+			return fmt.Errorf("batch transform failed before line %d: %v", stats.TotalLines, err)
+		}
+		stats.DroppedLines += int64(len(pending) - len(kept))
+		for _, rec := range kept {
+			if err := writeLine(rec.Bytes()); err != nil {
+				return err
+			}
+		}
+		spare = append(spare, pending...)
+		pending = pending[:0]
+		return nil
 	}
 
-	scriptPath := filepath.Join(workingDir, "json_to_parquet_duckdb.sh")
-
-	// Verify the script exists
-	if _, err := os.Stat(scriptPath); os.IsNotExist(err) {
-		return fmt.Errorf("converter script not found at %s", scriptPath)
+	progress := func(lines int64) {
+		s.emit(Event{Kind: EventProgress, Part: partNum, Lines: lines, Bytes: bytesWritten})
 	}
 
-	log.Printf("🔧 Using converter script: %s", scriptPath)
-	log.Printf("🔧 Converting %s to %s.parquet", jsonlPath, outputBaseName)
+	for bytesWritten < sizeLimit {
+		if err := ctl.abortErr(); err != nil {
+			return bytesWritten, linesProcessed, err
+		}
+		if ctl.Paused() {
+			// Flush buffered output so nothing is held in memory while paused
+			if err := flushPending(); err != nil {
+				return bytesWritten, linesProcessed, err
+			}
+			if err := writer.Flush(); err != nil {
+				return bytesWritten, linesProcessed, fmt.Errorf("error flushing buffer: %v", err)
+			}
+			s.logger().Printf("⏸️ Paused after %d lines, buffers flushed", linesProcessed)
+			ctl.waitIfPaused()
+			s.logger().Printf("▶️ Resumed")
+		}
+		if ctl.takeSkipPart() {
+			s.logger().Printf("⏭️ Skipping ahead: closing current part early")
+			break
+		}
 
-	// Run the converter script
-	cmd := exec.Command("bash", scriptPath, jsonlPath, outputBaseName)
+		rec, err := input.next(stats, progress)
+		if err == io.EOF {
+			if err := flushPending(); err != nil {
+				return bytesWritten, linesProcessed, err
+			}
+			if err := finish(); err != nil {
+				return bytesWritten, linesProcessed, err
+			}
+			return bytesWritten, linesProcessed, io.EOF
+		}
+		if err != nil {
+			return bytesWritten, linesProcessed, err
+		}
 
-	// Capture both stdout and stderr
-	output, err := cmd.CombinedOutput()
-	outputStr := string(output)
+		if batchSize == 0 {
+			err = writeLine(rec.Bytes())
+		} else {
+			// The staged record is reused once its batch is consumed, so batch transforms get a copy
+			held := takeRecord()
+			held.Reset(rec.Bytes())
+			if pending = append(pending, held); len(pending) >= batchSize {
+				err = flushPending()
+			}
+		}
+		if err != nil {
+			return bytesWritten, linesProcessed, err
+		}
+	}
 
-	// Log the output regardless of error
-	log.Printf("🔄 DuckDB output: %s", outputStr)
+	if err := flushPending(); err != nil {
+		return bytesWritten, linesProcessed, err
+	}
 
-	if err != nil {
-		return fmt.Errorf("DuckDB conversion failed: %v\nOutput: %s", err, outputStr)
+	// Make sure to flush before returning
+	if err := finish(); err != nil {
+		return bytesWritten, linesProcessed, err
 	}
 
-	// Verify the parquet file was created
-	parquetPath := outputBaseName + ".parquet"
-	if _, err := os.Stat(parquetPath); os.IsNotExist(err) {
-		return fmt.Errorf("parquet file was not created at %s", parquetPath)
+	return bytesWritten, linesProcessed, nil
+}
+
+// replaceClause builds a DuckDB "SELECT * REPLACE (...)" clause from column expressions
+func replaceClause(columns map[string]string) string {
+	if len(columns) == 0 {
+		return ""
+	}
+	names := make([]string, 0, len(columns))
+	for name := range columns {
+		names = append(names, name)
 	}
+	sort.Strings(names)
 
-	log.Printf("✅ Successfully converted %s to %s", filepath.Base(jsonlPath), parquetPath)
-	return nil
+	exprs := make([]string, len(names))
+	for i, name := range names {
+		exprs[i] = fmt.Sprintf("%s AS %s", columns[name], quoteIdentifier(name))
+	}
+	return "REPLACE (" + strings.Join(exprs, ", ") + ")"
 }
@@ -0,0 +1,463 @@
+package processor
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+
+	"github.com/parquet-go/parquet-go/encoding/thrift"
+	"github.com/parquet-go/parquet-go/format"
+)
+
+// Provenance keys written into the key-value metadata of every Parquet part
+const (
+	MetadataToolVersion = "pushshift.tool_version"
+	MetadataFilters     = "pushshift.filters"
+	MetadataInput       = "pushshift.input"
+	MetadataInputSHA256 = "pushshift.input_sha256"
+	MetadataPart        = "pushshift.part"
+	MetadataFirstLine   = "pushshift.first_line"
+	MetadataLastLine    = "pushshift.last_line"
+)
+
+// parquetMagic ends every Parquet file, after the footer and its length
+const parquetMagic = "PAR1"
+
+// fileMetaDataKeyValues is the field of the key-value metadata in the thrift FileMetaData footer
+const fileMetaDataKeyValues = 5
+
+// provenanced is implemented by the filters and transforms whose settings the parts record under
+// MetadataFilters, such as a filter's subreddits or the columns -fields keeps
+type provenanced interface {
+	provenance() map[string]any
+}
+
+// filtersMetadata returns the settings of the run's filters and transforms that decide which
+// records and columns the parts hold, as a JSON object, or "" when none do
+func (s *PushshiftProcessor) filtersMetadata() string {
+	settings := make(map[string]any)
+	for _, t := range slices.Concat(s.Options.Filters, s.Options.Transforms) {
+		t, ok := t.(provenanced)
+		if !ok {
+			continue
+		}
+		for key, value := range t.provenance() {
+			// Columns dropped by several transforms, e.g. -drop-fields and -max-null-fraction, add up
+			if list, ok := value.([]string); ok {
+				if previous, ok := settings[key].([]string); ok {
+					value = slices.Concat(previous, list)
+				}
+			}
+			settings[key] = value
+		}
+	}
+	if len(settings) == 0 {
+		return ""
+	}
+	data, err := json.Marshal(settings)
+	if err != nil {
+		return ""
+	}
+	return string(data)
+}
+
+// partMetadata returns the provenance of a part: the settings of the run's filters and
+// transforms, Options.ParquetMetadata, the run's input with its checksum when already known, and
+// the part's number and input lines
+func (s *PushshiftProcessor) partMetadata(inputPath, sha string, part PartInfo) map[string]string {
+	kv := make(map[string]string, len(s.Options.ParquetMetadata)+7)
+	if filters := s.filtersMetadata(); filters != "" {
+		kv[MetadataFilters] = filters
+	}
+	for key, value := range s.Options.ParquetMetadata {
+		kv[key] = value
+	}
+	kv[MetadataInput] = inputPath
+	if sha != "" {
+		kv[MetadataInputSHA256] = sha
+	}
+	kv[MetadataPart] = strconv.Itoa(part.Number)
+	if part.LastLine > 0 {
+		kv[MetadataFirstLine] = strconv.FormatInt(part.FirstLine, 10)
+		kv[MetadataLastLine] = strconv.FormatInt(part.LastLine, 10)
+	}
+	return kv
+}
+
+// knownInputSHA256 returns the checksum of an input when the input cache already knows it, so
+// parts can carry it before the run has read the whole input
+func (s *PushshiftProcessor) knownInputSHA256(inputPath string) string {
+	if s.Options.InputReader != nil {
+		return ""
+	}
+	if entry, ok := s.Options.Cache.Lookup(inputPath); ok {
+		return entry.SHA256
+	}
+	return ""
+}
+
+// stampPart writes the provenance of a converted part into its metadata. A part that can't be
+// stamped only warns.
+func (s *PushshiftProcessor) stampPart(part PartInfo, kv map[string]string) {
+	if err := StampParquetMetadata(part.Path, kv); err != nil {
+		warnf(s.logger(), "⚠️ Warning: Failed to record the provenance of part %d: %v", part.Number, err)
+	}
+}
+
+// stampParts adds the input's checksum to the metadata of parts converted before it was known,
+// updating their sizes. A part that can't be stamped only warns.
+func (s *PushshiftProcessor) stampParts(parts []PartInfo, sha string) {
+	for i, part := range parts {
+		if err := StampParquetMetadata(part.Path, map[string]string{MetadataInputSHA256: sha}); err != nil {
+			warnf(s.logger(), "⚠️ Warning: Failed to record the input checksum in part %d: %v", part.Number, err)
+			continue
+		}
+		if info, err := os.Stat(part.Path); err == nil {
+			parts[i].ParquetBytes = info.Size()
+		}
+	}
+}
+
+// ReadParquetMetadata returns the key-value metadata of a Parquet file
+func ReadParquetMetadata(path string) (map[string]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s: %v", path, err)
+	}
+	defer file.Close()
+	footer, _, err := readParquetFooter(file, path)
+	if err != nil {
+		return nil, err
+	}
+	pairs, err := footerKeyValues(footer, path)
+	if err != nil {
+		return nil, err
+	}
+	kv := make(map[string]string, len(pairs))
+	for _, pair := range pairs {
+		kv[pair.Key] = pair.Value
+	}
+	return kv, nil
+}
+
+// StampParquetMetadata sets key-value metadata in the footer of a Parquet file written by any
+// converter, replacing the values of keys it already has. Only the footer is rewritten; the other
+// fields of the footer are copied as they are, so nothing a newer writer put there is lost. The
+// file is copied with its new footer to a temporary file that then replaces it, so a failure
+// leaves the original intact.
+func StampParquetMetadata(path string, kv map[string]string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to open %s: %v", path, err)
+	}
+	defer file.Close()
+	footer, footerStart, err := readParquetFooter(file, path)
+	if err != nil {
+		return err
+	}
+	pairs, err := footerKeyValues(footer, path)
+	if err != nil {
+		return err
+	}
+	keys := make([]string, 0, len(kv))
+	for key := range kv {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+	for _, key := range keys {
+		i := slices.IndexFunc(pairs, func(pair format.KeyValue) bool { return pair.Key == key })
+		if i < 0 {
+			pairs = append(pairs, format.KeyValue{Key: key, Value: kv[key]})
+		} else {
+			pairs[i].Value = kv[key]
+		}
+	}
+	updated, err := replaceThriftField(footer, fileMetaDataKeyValues, thriftList, encodeKeyValues(pairs))
+	if err != nil {
+		return fmt.Errorf("invalid Parquet footer in %s: %v", path, err)
+	}
+	tail := binary.LittleEndian.AppendUint32(updated, uint32(len(updated)))
+	tail = append(tail, parquetMagic...)
+
+	info, err := file.Stat()
+	if err != nil {
+		return fmt.Errorf("failed to stat %s: %v", path, err)
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp*")
+	if err != nil {
+		return fmt.Errorf("failed to write the footer of %s: %v", path, err)
+	}
+	defer os.Remove(tmp.Name())
+	_, err = io.Copy(tmp, io.NewSectionReader(file, 0, footerStart))
+	if err == nil {
+		_, err = tmp.Write(tail)
+	}
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Chmod(tmp.Name(), info.Mode().Perm())
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), path)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to write the footer of %s: %v", path, err)
+	}
+	return nil
+}
+
+// readParquetFooter returns the thrift footer of a Parquet file and the offset it starts at
+func readParquetFooter(file *os.File, path string) ([]byte, int64, error) {
+	info, err := file.Stat()
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to stat %s: %v", path, err)
+	}
+	size := info.Size()
+	trailer := make([]byte, 8)
+	if size < 12 {
+		return nil, 0, fmt.Errorf("%s is not a Parquet file", path)
+	}
+	if _, err := file.ReadAt(trailer, size-8); err != nil {
+		return nil, 0, fmt.Errorf("failed to read %s: %v", path, err)
+	}
+	if string(trailer[4:]) != parquetMagic {
+		return nil, 0, fmt.Errorf("%s is not a Parquet file", path)
+	}
+	length := int64(binary.LittleEndian.Uint32(trailer))
+	start := size - 8 - length
+	if start < 4 {
+		return nil, 0, fmt.Errorf("invalid Parquet footer length in %s", path)
+	}
+	footer := make([]byte, length)
+	if _, err := file.ReadAt(footer, start); err != nil && err != io.EOF {
+		return nil, 0, fmt.Errorf("failed to read %s: %v", path, err)
+	}
+	return footer, start, nil
+}
+
+// footerKeyValues decodes the key-value metadata of a Parquet footer
+func footerKeyValues(footer []byte, path string) ([]format.KeyValue, error) {
+	var metadata format.FileMetaData
+	if err := thrift.Unmarshal(new(thrift.CompactProtocol), footer, &metadata); err != nil {
+		return nil, fmt.Errorf("invalid Parquet footer in %s: %v", path, err)
+	}
+	return metadata.KeyValueMetadata, nil
+}
+
+// Thrift compact protocol types used when walking a footer
+const (
+	thriftTrue   = 1
+	thriftFalse  = 2
+	thriftByte   = 3
+	thriftI16    = 4
+	thriftI32    = 5
+	thriftI64    = 6
+	thriftDouble = 7
+	thriftBinary = 8
+	thriftList   = 9
+	thriftSet    = 10
+	thriftMap    = 11
+	thriftStruct = 12
+)
+
+// encodeKeyValues encodes a list of KeyValue structs in the thrift compact protocol
+func encodeKeyValues(pairs []format.KeyValue) []byte {
+	var b []byte
+	if len(pairs) < 15 {
+		b = append(b, byte(len(pairs))<<4|thriftStruct)
+	} else {
+		b = append(b, 0xf0|thriftStruct)
+		b = binary.AppendUvarint(b, uint64(len(pairs)))
+	}
+	for _, pair := range pairs {
+		for _, text := range []string{pair.Key, pair.Value} {
+			// Fields 1 and 2, each one after the previous
+			b = append(b, 1<<4|thriftBinary)
+			b = binary.AppendUvarint(b, uint64(len(text)))
+			b = append(b, text...)
+		}
+		b = append(b, 0)
+	}
+	return b
+}
+
+// thriftField is a field of a compact protocol struct: its id and type, and its encoded value
+type thriftField struct {
+	id    int
+	typ   byte
+	value []byte
+}
+
+// replaceThriftField returns a compact protocol struct with the value of one field replaced, or
+// the field added when the struct doesn't have it. The other fields keep their encoded values;
+// their headers are written again, as they encode the difference to the previous field's id.
+func replaceThriftField(data []byte, id int, typ byte, value []byte) ([]byte, error) {
+	r := compactReader{data: data}
+	fields, err := r.structFields()
+	if err != nil {
+		return nil, err
+	}
+	replaced := false
+	for i, field := range fields {
+		if field.id == id {
+			fields[i] = thriftField{id: id, typ: typ, value: value}
+			replaced = true
+		}
+	}
+	if !replaced {
+		i := slices.IndexFunc(fields, func(field thriftField) bool { return field.id > id })
+		if i < 0 {
+			i = len(fields)
+		}
+		fields = slices.Insert(fields, i, thriftField{id: id, typ: typ, value: value})
+	}
+	out := make([]byte, 0, len(data)+len(value))
+	last := 0
+	for _, field := range fields {
+		if delta := field.id - last; delta > 0 && delta <= 15 {
+			out = append(out, byte(delta)<<4|field.typ)
+		} else {
+			out = append(out, field.typ)
+			out = binary.AppendVarint(out, int64(field.id))
+		}
+		out = append(out, field.value...)
+		last = field.id
+	}
+	return append(out, 0), nil
+}
+
+// compactReader walks thrift compact protocol data without decoding it
+type compactReader struct {
+	data []byte
+	pos  int
+}
+
+// structFields reads the fields of a struct up to its stop byte
+func (r *compactReader) structFields() ([]thriftField, error) {
+	var fields []thriftField
+	last := 0
+	for {
+		header, err := r.byte()
+		if err != nil {
+			return nil, err
+		}
+		if header == 0 {
+			return fields, nil
+		}
+		typ, id := header&0x0f, last+int(header>>4)
+		if header>>4 == 0 {
+			n, err := r.varint()
+			if err != nil {
+				return nil, err
+			}
+			id = int(int64(n>>1) ^ -int64(n&1))
+		}
+		start := r.pos
+		// Booleans of struct fields are held by the type of their header
+		if typ != thriftTrue && typ != thriftFalse {
+			if err := r.skip(typ); err != nil {
+				return nil, err
+			}
+		}
+		fields = append(fields, thriftField{id: id, typ: typ, value: r.data[start:r.pos]})
+		last = id
+	}
+}
+
+// skip moves past a value of a type
+func (r *compactReader) skip(typ byte) error {
+	switch typ {
+	case thriftTrue, thriftFalse, thriftByte:
+		// Booleans outside struct fields, in lists and maps, take a byte
+		_, err := r.byte()
+		return err
+	case thriftI16, thriftI32, thriftI64:
+		_, err := r.varint()
+		return err
+	case thriftDouble:
+		return r.advance(8)
+	case thriftBinary:
+		n, err := r.varint()
+		if err != nil {
+			return err
+		}
+		return r.advance(n)
+	case thriftList, thriftSet:
+		header, err := r.byte()
+		if err != nil {
+			return err
+		}
+		size := uint64(header >> 4)
+		if size == 15 {
+			if size, err = r.varint(); err != nil {
+				return err
+			}
+		}
+		for range size {
+			if err := r.skip(header & 0x0f); err != nil {
+				return err
+			}
+		}
+		return nil
+	case thriftMap:
+		size, err := r.varint()
+		if err != nil || size == 0 {
+			return err
+		}
+		types, err := r.byte()
+		if err != nil {
+			return err
+		}
+		for range size {
+			if err := r.skip(types >> 4); err != nil {
+				return err
+			}
+			if err := r.skip(types & 0x0f); err != nil {
+				return err
+			}
+		}
+		return nil
+	case thriftStruct:
+		_, err := r.structFields()
+		return err
+	default:
+		return fmt.Errorf("unknown thrift type %d at offset %d", typ, r.pos)
+	}
+}
+
+// byte reads one byte
+func (r *compactReader) byte() (byte, error) {
+	if r.pos >= len(r.data) {
+		return 0, io.ErrUnexpectedEOF
+	}
+	r.pos++
+	return r.data[r.pos-1], nil
+}
+
+// varint reads an unsigned variable-length integer
+func (r *compactReader) varint() (uint64, error) {
+	n, size := binary.Uvarint(r.data[r.pos:])
+	if size <= 0 {
+		return 0, io.ErrUnexpectedEOF
+	}
+	r.pos += size
+	return n, nil
+}
+
+// advance moves past n bytes
+func (r *compactReader) advance(n uint64) error {
+	if n > uint64(len(r.data)-r.pos) {
+		return io.ErrUnexpectedEOF
+	}
+	r.pos += int(n)
+	return nil
+}
@@ -0,0 +1,152 @@
+package processor
+
+import (
+	"bytes"
+	"encoding/binary"
+	"maps"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/parquet-go/parquet-go"
+)
+
+// writeTestParquet writes a small Parquet file with the given key-value metadata
+func writeTestParquet(t *testing.T, path string, kv map[string]string) {
+	t.Helper()
+	type row struct {
+		ID    string `parquet:"id"`
+		Score int64  `parquet:"score"`
+	}
+	var options []parquet.WriterOption
+	for key, value := range kv {
+		options = append(options, parquet.KeyValueMetadata(key, value))
+	}
+	rows := []row{{"a", 1}, {"b", 2}, {"c", 3}}
+	if err := parquet.WriteFile(path, rows, options...); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestStampParquetMetadata(t *testing.T) {
+	long := string(bytes.Repeat([]byte("x"), 300))
+	tests := []struct {
+		name     string
+		existing map[string]string
+		stamps   []map[string]string
+		want     map[string]string
+	}{
+		{"adds to empty metadata", nil, []map[string]string{{"a": "1", "b": "2"}}, map[string]string{"a": "1", "b": "2"}},
+		{"keeps other keys", map[string]string{"writer": "x"}, []map[string]string{{"a": "1"}}, map[string]string{"writer": "x", "a": "1"}},
+		{"replaces values", map[string]string{"a": "old"}, []map[string]string{{"a": "new"}}, map[string]string{"a": "new"}},
+		{"stamped twice", nil, []map[string]string{{"a": "1"}, {"b": "2", "a": "3"}}, map[string]string{"a": "3", "b": "2"}},
+		{"long values", nil, []map[string]string{{"filters": long}}, map[string]string{"filters": long}},
+		{"many keys", nil, []map[string]string{{"k01": "", "k02": "", "k03": "", "k04": "", "k05": "", "k06": "", "k07": "", "k08": "", "k09": "", "k10": "", "k11": "", "k12": "", "k13": "", "k14": "", "k15": "", "k16": ""}},
+			map[string]string{"k01": "", "k02": "", "k03": "", "k04": "", "k05": "", "k06": "", "k07": "", "k08": "", "k09": "", "k10": "", "k11": "", "k12": "", "k13": "", "k14": "", "k15": "", "k16": ""}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "x_part_001.parquet")
+			writeTestParquet(t, path, tt.existing)
+			original, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, kv := range tt.stamps {
+				if err := StampParquetMetadata(path, kv); err != nil {
+					t.Fatal(err)
+				}
+			}
+			got, err := ReadParquetMetadata(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !maps.Equal(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+
+			// The data pages are untouched and the file still reads
+			stamped, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			footerStart := footerOffset(original)
+			if !bytes.Equal(stamped[:footerStart], original[:footerStart]) {
+				t.Error("stamping changed the data before the footer")
+			}
+			rows, err := parquet.ReadFile[struct {
+				ID string `parquet:"id"`
+			}](path)
+			if err != nil || len(rows) != 3 {
+				t.Errorf("got %d rows, %v", len(rows), err)
+			}
+			entries, _ := os.ReadDir(filepath.Dir(path))
+			if len(entries) != 1 {
+				t.Errorf("stamping left %d files", len(entries))
+			}
+		})
+	}
+}
+
+// footerOffset returns where the footer of a Parquet file starts
+func footerOffset(data []byte) int {
+	return len(data) - 8 - int(binary.LittleEndian.Uint32(data[len(data)-8:]))
+}
+
+func TestStampParquetMetadataInvalid(t *testing.T) {
+	dir := t.TempDir()
+	for name, data := range map[string]string{"empty": "", "not parquet": "PAR1 not really a parquet file", "bad footer": "PAR1\x01\x02\x03\x04\x04\x00\x00\x00PAR1"} {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := StampParquetMetadata(path, map[string]string{"a": "1"}); err == nil {
+			t.Errorf("%s: stamped an invalid file", name)
+		}
+		if got, _ := os.ReadFile(path); string(got) != data {
+			t.Errorf("%s: failed stamp changed the file", name)
+		}
+	}
+}
+
+func TestFiltersMetadata(t *testing.T) {
+	subreddits, err := NewSubredditFilter([]string{"AskScience", "ask*"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	fields, err := NewSelectFieldsTransform([]string{"id", "body"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	dropFields, err := NewDropFieldsTransform([]string{"edited"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	redaction, err := NewRedactionTransform(&RedactionPolicy{Salt: "secret", Source: "policy.json", Rules: []RedactionRule{{Columns: []string{"author"}, Action: RedactHash}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	deletion := NewDeletionFilter(DeletionLists{IDs: map[string]string{"abc": "t1", "def": ""}, Authors: map[string]bool{"someone": true}})
+	tests := []struct {
+		name       string
+		filters    []Transform
+		transforms []Transform
+		want       string
+	}{
+		{"nothing", nil, nil, ""},
+		{"unrecorded transforms", nil, []Transform{typedTransform{}}, ""},
+		{"filters", []Transform{&ScoreFilter{Min: 5}, subreddits, &OfficialContentFilter{ExcludeStickied: true}}, nil,
+			`{"exclude_stickied":true,"min_score":5,"subreddits":["AskScience","ask*"]}`},
+		{"deletion lists", []Transform{deletion}, nil, `{"deletion_lists":{"authors":1,"ids":2}}`},
+		{"fields and redaction", nil, []Transform{fields, redaction}, `{"fields":["id","body"],"redaction_policy":{"rules":[{"columns":["author"],"action":"hash"}],"source":"policy.json"}}`},
+		{"dropped columns add up", nil, []Transform{dropFields, NewDropColumnsTransform([]string{"gilded"}), NewDedupTransform()}, `{"dedup":true,"drop_fields":["edited","gilded"]}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &PushshiftProcessor{Options: Options{Filters: tt.filters, Transforms: tt.transforms}}
+			if got := s.filtersMetadata(); got != tt.want {
+				t.Errorf("got %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,193 @@
+package processor
+
+import (
+	"crypto/sha1"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// QdrantSink upserts vector rows ({id, metadata..., vector}) into a Qdrant collection over its REST API.
+// Point ids are derived from an idempotency key field, so re-runs, retries and dead-letter drains
+// overwrite points instead of duplicating them.
+type QdrantSink struct {
+	// KeyField is the record field used as idempotency key, "name" by default: its t1_/t3_
+	// fullnames keep comments and submissions sharing a base36 id apart. Keys become version 5
+	// UUIDs, except base36 Reddit ids in "id", which become numeric point ids.
+	KeyField string
+	// BatchSize caps the points sent per upsert request, splitting larger batches; 0 sends each
+	// batch in one request
+	BatchSize int
+	// Wait has Qdrant apply each upsert before answering, so a failure to apply it is reported
+	// and the batch retried or dead-lettered. Without it, Qdrant answers once the upsert is queued.
+	Wait bool
+
+	baseURL    string
+	collection string
+	apiKey     string
+	client     *http.Client
+	retries    int
+	ready      bool
+	runLogger
+}
+
+// NewQdrantSink creates a sink from a qdrant://host:port/collection URL
+// (qdrants:// uses HTTPS). The API key falls back to the QDRANT_API_KEY environment variable.
+func NewQdrantSink(rawURL, apiKey string) (*QdrantSink, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid qdrant URL: %v", err)
+	}
+	scheme := "http"
+	switch u.Scheme {
+	case "qdrant":
+	case "qdrants":
+		scheme = "https"
+	default:
+		return nil, fmt.Errorf("unsupported vector store %q, only Qdrant is supported: qdrant://host:port/collection", rawURL)
+	}
+	collection := strings.Trim(u.Path, "/")
+	if u.Host == "" || collection == "" {
+		return nil, fmt.Errorf("qdrant URL must include host and collection, e.g. qdrant://localhost:6333/reddit")
+	}
+
+	if apiKey == "" {
+		apiKey = os.Getenv("QDRANT_API_KEY")
+	}
+
+	return &QdrantSink{
+		KeyField:   "name",
+		Wait:       true,
+		baseURL:    scheme + "://" + u.Host,
+		collection: collection,
+		apiKey:     apiKey,
+		client:     &http.Client{Timeout: 60 * time.Second},
+		retries:    3,
+	}, nil
+}
+
+// qdrantPoint is a single point in an upsert request
+type qdrantPoint struct {
+	ID      any                        `json:"id"`
+	Vector  json.RawMessage            `json:"vector"`
+	Payload map[string]json.RawMessage `json:"payload"`
+}
+
+// WriteBatch upserts a batch of vector rows
+func (q *QdrantSink) WriteBatch(recs []*Record) error {
+	points := make([]qdrantPoint, 0, len(recs))
+	for _, rec := range recs {
+		id, ok := rec.GetString(q.KeyField)
+		if !ok || id == "" {
+			return fmt.Errorf("record without string %s cannot be stored in qdrant", q.KeyField)
+		}
+		vector, ok := rec.Get("vector")
+		if !ok {
+			return fmt.Errorf("record %s has no vector; qdrant output requires -embed-endpoint", id)
+		}
+
+		payload := make(map[string]json.RawMessage)
+		for _, key := range rec.Keys() {
+			if key != "vector" {
+				payload[key], _ = rec.Get(key)
+			}
+		}
+		points = append(points, qdrantPoint{ID: qdrantPointID(q.KeyField, id), Vector: vector, Payload: payload})
+	}
+
+	if !q.ready {
+		var dims []float64
+		if err := json.Unmarshal(points[0].Vector, &dims); err != nil {
+			return fmt.Errorf("invalid vector: %v", err)
+		}
+		if err := q.ensureCollection(len(dims)); err != nil {
+			return err
+		}
+		q.ready = true
+	}
+
+	size := q.BatchSize
+	if size <= 0 {
+		size = len(points)
+	}
+	endpoint := q.baseURL + "/collections/" + q.collection + "/points?wait=" + strconv.FormatBool(q.Wait)
+	for start := 0; start < len(points); start += size {
+		body, err := json.Marshal(map[string]any{"points": points[start:min(start+size, len(points))]})
+		if err != nil {
+			return fmt.Errorf("failed to encode qdrant points: %v", err)
+		}
+		// Points already upserted by an earlier request of a failed batch are overwritten when
+		// the batch is retried
+		if _, err := sendJSON(q.client, http.MethodPut, endpoint, q.headers(), body, q.retries); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// qdrantKeyNamespace is the namespace of the version 5 UUIDs derived from idempotency keys
+var qdrantKeyNamespace = [16]byte{0x8c, 0x2e, 0x5f, 0x4a, 0x1d, 0x37, 0x4b, 0x6e, 0x9a, 0x02, 0x7f, 0x3c, 0x51, 0xd8, 0xe4, 0x96}
+
+// qdrantPointID maps an idempotency key of a field to a stable point id: the numeric value of a
+// base36 Reddit id in the id field, or a version 5 UUID (RFC 4122, SHA-1 of the key in
+// qdrantKeyNamespace) for any other key. Parsing only ids keeps base36-looking values of other
+// fields, such as author names, from colliding with the ids of records.
+func qdrantPointID(field, key string) any {
+	if field == "id" {
+		if n, err := strconv.ParseUint(key, 36, 64); err == nil {
+			return n
+		}
+	}
+	h := sha1.New()
+	h.Write(qdrantKeyNamespace[:])
+	h.Write([]byte(key))
+	sum := h.Sum(nil)
+	sum[6] = sum[6]&0x0f | 0x50
+	sum[8] = sum[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", sum[0:4], sum[4:6], sum[6:8], sum[8:10], sum[10:16])
+}
+
+// ensureCollection creates the collection with cosine distance if it doesn't exist yet
+func (q *QdrantSink) ensureCollection(size int) error {
+	req, err := http.NewRequest(http.MethodGet, q.baseURL+"/collections/"+q.collection, nil)
+	if err != nil {
+		return err
+	}
+	for name, value := range q.headers() {
+		req.Header.Set(name, value)
+	}
+	resp, err := q.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to reach qdrant: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode == http.StatusOK {
+		return nil
+	}
+
+	loggerOrDefault(q.logger).Printf("🧭 Creating qdrant collection %s with %d dimensions", q.collection, size)
+	body, _ := json.Marshal(map[string]any{"vectors": map[string]any{"size": size, "distance": "Cosine"}})
+	_, err = sendJSON(q.client, http.MethodPut, q.baseURL+"/collections/"+q.collection, q.headers(), body, q.retries)
+	if err != nil {
+		return fmt.Errorf("failed to create qdrant collection: %v", err)
+	}
+	return nil
+}
+
+// headers returns the authentication headers for requests
+func (q *QdrantSink) headers() map[string]string {
+	if q.apiKey == "" {
+		return nil
+	}
+	return map[string]string{"api-key": q.apiKey}
+}
+
+// Close is a no-op; every batch is written synchronously
+func (q *QdrantSink) Close() error {
+	return nil
+}
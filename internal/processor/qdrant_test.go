@@ -0,0 +1,115 @@
+package processor
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestQdrantPointID(t *testing.T) {
+	tests := []struct {
+		field, key string
+		want       any
+	}{
+		{"id", "abc", uint64(13368)},
+		{"id", "t1_abc", "uuid"},
+		{"name", "t1_abc", "uuid"},
+		{"author", "spez", "uuid"},
+	}
+	for _, tt := range tests {
+		got := qdrantPointID(tt.field, tt.key)
+		if tt.want != "uuid" {
+			if got != tt.want {
+				t.Errorf("%s=%s: got %v, want %v", tt.field, tt.key, got, tt.want)
+			}
+			continue
+		}
+		id, ok := got.(string)
+		if !ok || len(id) != 36 || id[14] != '5' || !strings.ContainsRune("89ab", rune(id[19])) {
+			t.Errorf("%s=%s: got %v, want a version 5 UUID", tt.field, tt.key, got)
+		}
+		if again := qdrantPointID(tt.field, tt.key); again != got {
+			t.Errorf("%s=%s: got %v, then %v", tt.field, tt.key, got, again)
+		}
+	}
+	if qdrantPointID("author", "abc") == qdrantPointID("id", "abc") {
+		t.Error("an author name collided with a record id")
+	}
+}
+
+func TestQdrantBatches(t *testing.T) {
+	var sizes []int
+	var queries []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPut {
+			var req struct {
+				Points []json.RawMessage `json:"points"`
+			}
+			json.NewDecoder(r.Body).Decode(&req)
+			sizes = append(sizes, len(req.Points))
+			queries = append(queries, r.URL.RawQuery)
+		}
+		w.Write([]byte(`{"status":"ok"}`))
+	}))
+	defer server.Close()
+
+	sink, err := NewQdrantSink("qdrant://"+strings.TrimPrefix(server.URL, "http://")+"/reddit", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sink.BatchSize, sink.Wait = 2, false
+	var recs []*Record
+	for _, id := range []string{"a", "b", "c", "d", "e"} {
+		recs = append(recs, NewRecord([]byte(`{"id":"`+id+`","name":"t1_`+id+`","vector":[0.1,0.2]}`)))
+	}
+	if err := sink.WriteBatch(recs); err != nil {
+		t.Fatal(err)
+	}
+	if len(sizes) != 3 || sizes[0] != 2 || sizes[1] != 2 || sizes[2] != 1 {
+		t.Errorf("got requests of %v points, want [2 2 1]", sizes)
+	}
+	for _, query := range queries {
+		if query != "wait=false" {
+			t.Errorf("got query %q, want wait=false", query)
+		}
+	}
+}
+
+func TestQdrantDefaultKey(t *testing.T) {
+	var ids []any
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPut {
+			var req struct {
+				Points []struct {
+					ID any `json:"id"`
+				} `json:"points"`
+			}
+			json.NewDecoder(r.Body).Decode(&req)
+			for _, point := range req.Points {
+				ids = append(ids, point.ID)
+			}
+		}
+		w.Write([]byte(`{"status":"ok"}`))
+	}))
+	defer server.Close()
+
+	sink, err := NewQdrantSink("qdrant://"+strings.TrimPrefix(server.URL, "http://")+"/reddit", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	recs := []*Record{
+		NewRecord([]byte(`{"id":"abc","name":"t1_abc","parent_id":"t3_x","vector":[0.1,0.2]}`)),
+		NewRecord([]byte(`{"id":"abc","name":"t3_abc","vector":[0.1,0.2]}`)),
+	}
+	if err := sink.WriteBatch(recs); err != nil {
+		t.Fatal(err)
+	}
+	if len(ids) != 2 || ids[0] != qdrantPointID("name", "t1_abc") || ids[1] != qdrantPointID("name", "t3_abc") || ids[0] == ids[1] {
+		t.Errorf("got point ids %v, want distinct ids for t1_abc and t3_abc", ids)
+	}
+	if err := sink.WriteBatch([]*Record{NewRecord([]byte(`{"id":"abc","vector":[0.1,0.2]}`))}); err == nil {
+		t.Error("a record without a name was stored")
+	}
+}
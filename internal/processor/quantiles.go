@@ -0,0 +1,85 @@
+package processor
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"unicode/utf8"
+)
+
+// Distributions summarized by Options.Quantiles
+const (
+	QuantileScore       = "score"
+	QuantileNumComments = "num_comments"
+	QuantileBodyLength  = "body_length"
+)
+
+// QuantileSummary is the readable form of a distribution, as stored in the manifest
+type QuantileSummary struct {
+	Count int64   `json:"count"`
+	Min   float64 `json:"min"`
+	P50   float64 `json:"p50"`
+	P90   float64 `json:"p90"`
+	P99   float64 `json:"p99"`
+	Max   float64 `json:"max"`
+}
+
+// Summary returns the digest's count, extremes and main quantiles
+func (d *TDigest) Summary() QuantileSummary {
+	if d.Count() == 0 {
+		return QuantileSummary{}
+	}
+	return QuantileSummary{
+		Count: d.Count(),
+		Min:   d.Quantile(0),
+		P50:   d.Quantile(0.5),
+		P90:   d.Quantile(0.9),
+		P99:   d.Quantile(0.99),
+		Max:   d.Quantile(1),
+	}
+}
+
+// String formats the summary for the statistics output
+func (q QuantileSummary) String() string {
+	round := func(v float64) float64 { return math.Round(v*10) / 10 }
+	return fmt.Sprintf("p50 %g · p90 %g · p99 %g · max %g (%s values)",
+		round(q.P50), round(q.P90), round(q.P99), round(q.Max), formatCount(q.Count))
+}
+
+// observeQuantiles adds a written record's score, num_comments and text length (body for
+// comments, selftext for submissions, in characters) to the digests in one pass over the line
+func observeQuantiles(digests map[string]*TDigest, line []byte) {
+	forEachField(line, func(name, raw []byte) bool {
+		switch string(name) {
+		case QuantileScore, QuantileNumComments:
+			var n float64
+			if json.Unmarshal(raw, &n) == nil {
+				quantileDigest(digests, string(name)).Add(n)
+			}
+		case "body", "selftext":
+			if len(raw) >= 2 && raw[0] == '"' {
+				length := utf8.RuneCount(raw[1 : len(raw)-1])
+				if bytes.IndexByte(raw, '\\') >= 0 {
+					var text string
+					if json.Unmarshal(raw, &text) != nil {
+						return true
+					}
+					length = utf8.RuneCountInString(text)
+				}
+				quantileDigest(digests, QuantileBodyLength).Add(float64(length))
+			}
+		}
+		return true
+	})
+}
+
+// quantileDigest returns the named digest, creating it on first use
+func quantileDigest(digests map[string]*TDigest, name string) *TDigest {
+	d := digests[name]
+	if d == nil {
+		d = NewTDigest()
+		digests[name] = d
+	}
+	return d
+}
@@ -0,0 +1,128 @@
+package processor
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// maxEncoderWindowLog is the largest match window the zstd encoder supports, 512MB
+const maxEncoderWindowLog = 29
+
+// RecompressOptions configures Recompress
+type RecompressOptions struct {
+	// Level is the zstd level, 1 to 22. The encoder has four speeds, so ranges of levels share one.
+	Level int
+	// WindowLog is the base 2 logarithm of the match window, as with zstd --long, from 10 to 31.
+	// Windows beyond what the encoder supports are reduced to it. 0 keeps the encoder's default.
+	WindowLog int
+	// Logger receives the messages of the recompression; the standard logger when nil
+	Logger *log.Logger
+}
+
+// RecompressResult sizes the input and output of Recompress
+type RecompressResult struct {
+	InputBytes   int64
+	ContentBytes int64
+	OutputBytes  int64
+	// WindowLog is the window actually used, 0 for the encoder's default
+	WindowLog int
+	// Logger receives the messages of the recompression; the standard logger when nil
+	Logger *log.Logger
+}
+
+// Validate checks the level and window
+func (o RecompressOptions) Validate() error {
+	if o.Level < 1 || o.Level > 22 {
+		return fmt.Errorf("zstd level %d is out of range, expected 1 to 22", o.Level)
+	}
+	if o.WindowLog != 0 && (o.WindowLog < 10 || o.WindowLog > 31) {
+		return fmt.Errorf("window log %d is out of range, expected 10 to 31", o.WindowLog)
+	}
+	return nil
+}
+
+// Recompress decompresses an input as processing runs read it and compresses its content again
+// with zstd, for re-archiving filtered subsets at a higher level. The content is copied as-is,
+// without splitting it into records. outputPath is only replaced once the whole input has been
+// compressed.
+func Recompress(inputPath, outputPath string, opts RecompressOptions) (RecompressResult, error) {
+	result := RecompressResult{WindowLog: opts.WindowLog}
+	if err := opts.Validate(); err != nil {
+		return result, err
+	}
+	encoderOptions := []zstd.EOption{zstd.WithEncoderLevel(zstd.EncoderLevelFromZstd(opts.Level))}
+	if result.WindowLog > maxEncoderWindowLog {
+		warnf(opts.Logger, "⚠️ Warning: the zstd encoder supports windows up to 2^%d bytes, using --long=%d instead of %d", maxEncoderWindowLog, maxEncoderWindowLog, result.WindowLog)
+		result.WindowLog = maxEncoderWindowLog
+	}
+	if result.WindowLog > 0 {
+		encoderOptions = append(encoderOptions, zstd.WithWindowSize(1<<result.WindowLog))
+	}
+
+	in, err := openZstInput(inputPath, inputOptions{})
+	if err != nil {
+		return result, err
+	}
+	defer in.Close()
+
+	tmp := outputPath + ".recompressing"
+	file, err := os.Create(tmp)
+	if err != nil {
+		return result, fmt.Errorf("failed to create output file: %v", err)
+	}
+	defer os.Remove(tmp)
+	encoder, err := zstd.NewWriter(file, encoderOptions...)
+	if err != nil {
+		file.Close()
+		return result, fmt.Errorf("failed to create zstd writer: %v", err)
+	}
+
+	start := time.Now()
+	loggerOrDefault(opts.Logger).Printf("🗜️ Recompressing %s to %s at zstd level %d (%s)", inputPath, outputPath, opts.Level, zstd.EncoderLevelFromZstd(opts.Level))
+	buf := make([]byte, 4*1024*1024)
+	for {
+		n, readErr := in.Read(buf)
+		if n > 0 {
+			if _, err := encoder.Write(buf[:n]); err != nil {
+				encoder.Close()
+				file.Close()
+				return result, fmt.Errorf("failed to write output: %v", err)
+			}
+			result.ContentBytes += int64(n)
+		}
+		if readErr == io.EOF {
+			break
+		}
+		if readErr != nil {
+			encoder.Close()
+			file.Close()
+			return result, inputError(readErr)
+		}
+	}
+	if err := encoder.Close(); err != nil {
+		file.Close()
+		return result, fmt.Errorf("failed to write output: %v", err)
+	}
+	if err := file.Close(); err != nil {
+		return result, fmt.Errorf("failed to write output: %v", err)
+	}
+	if err := os.Rename(tmp, outputPath); err != nil {
+		return result, fmt.Errorf("failed to move the output into place: %v", err)
+	}
+
+	if info, err := os.Stat(inputPath); err == nil && !info.IsDir() {
+		result.InputBytes = info.Size()
+	}
+	if info, err := os.Stat(outputPath); err == nil {
+		result.OutputBytes = info.Size()
+	}
+	elapsed := time.Since(start)
+	loggerOrDefault(opts.Logger).Printf("✅ Recompressed %.2f MB of content in %v (%.2f MB/s)", float64(result.ContentBytes)/1024/1024,
+		elapsed.Round(time.Millisecond), float64(result.ContentBytes)/1024/1024/elapsed.Seconds())
+	return result, nil
+}
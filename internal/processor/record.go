@@ -0,0 +1,387 @@
+package processor
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+)
+
+// topLevelField returns the raw JSON value of a top-level key in a JSON object line without
+// decoding the whole record. Nested objects are skipped, so keys inside e.g.
+// crosspost_parent_list never shadow the record's own fields.
+func topLevelField(line []byte, key string) ([]byte, bool) {
+	var value []byte
+	found := false
+	forEachField(line, func(name, raw []byte) bool {
+		if string(name) == key {
+			value, found = raw, true
+			return false
+		}
+		return true
+	})
+	return value, found
+}
+
+// forEachField calls fn with the raw name and value of each top-level field of a JSON object
+// line, in order, until fn returns false. It reports whether the line was a well-formed object.
+func forEachField(line []byte, fn func(name, raw []byte) bool) bool {
+	i := skipSpace(line, 0)
+	if i >= len(line) || line[i] != '{' {
+		return false
+	}
+	i = skipSpace(line, i+1)
+	if i < len(line) && line[i] == '}' {
+		return true
+	}
+
+	for {
+		i = skipSpace(line, i)
+		if i >= len(line) || line[i] != '"' {
+			return false
+		}
+		keyEnd := skipString(line, i)
+		if keyEnd < 0 {
+			return false
+		}
+		name := line[i+1 : keyEnd-1]
+
+		i = skipSpace(line, keyEnd)
+		if i >= len(line) || line[i] != ':' {
+			return false
+		}
+		i = skipSpace(line, i+1)
+
+		valueEnd := skipValue(line, i)
+		if valueEnd < 0 || valueEnd == i {
+			return false
+		}
+		if !fn(name, line[i:valueEnd]) {
+			return true
+		}
+
+		i = skipSpace(line, valueEnd)
+		if i < len(line) && line[i] == '}' {
+			return true
+		}
+		if i >= len(line) || line[i] != ',' {
+			return false
+		}
+		i++
+	}
+}
+
+// skipSpace returns the index of the next non-whitespace byte at or after i
+func skipSpace(b []byte, i int) int {
+	for i < len(b) && (b[i] == ' ' || b[i] == '\t' || b[i] == '\n' || b[i] == '\r') {
+		i++
+	}
+	return i
+}
+
+// skipString returns the index just past the JSON string starting at i, or -1 if unterminated
+func skipString(b []byte, i int) int {
+	for j := i + 1; j < len(b); j++ {
+		switch b[j] {
+		case '\\':
+			j++
+		case '"':
+			return j + 1
+		}
+	}
+	return -1
+}
+
+// skipValue returns the index just past the JSON value starting at i, or -1 if malformed
+func skipValue(b []byte, i int) int {
+	if i >= len(b) {
+		return -1
+	}
+	switch b[i] {
+	case '"':
+		return skipString(b, i)
+	case '{', '[':
+		depth := 0
+		for j := i; j < len(b); j++ {
+			switch b[j] {
+			case '"':
+				end := skipString(b, j)
+				if end < 0 {
+					return -1
+				}
+				j = end - 1
+			case '{', '[':
+				depth++
+			case '}', ']':
+				depth--
+				if depth == 0 {
+					return j + 1
+				}
+			}
+		}
+		return -1
+	default:
+		// Numbers, booleans and null run until the next delimiter
+		j := i
+		for j < len(b) && b[j] != ',' && b[j] != '}' && b[j] != ']' && b[j] != ' ' && b[j] != '\n' && b[j] != '\r' && b[j] != '\t' {
+			j++
+		}
+		return j
+	}
+}
+
+// extractStringField returns the value of a top-level string field from a raw JSON line
+func extractStringField(line []byte, key string) (string, bool) {
+	raw, ok := topLevelField(line, key)
+	if !ok || len(raw) < 2 || raw[0] != '"' {
+		return "", false
+	}
+	// Fast path: no escapes, slice out the contents directly
+	if bytes.IndexByte(raw, '\\') < 0 {
+		return string(raw[1 : len(raw)-1]), true
+	}
+	var value string
+	if err := json.Unmarshal(raw, &value); err != nil {
+		return "", false
+	}
+	return value, true
+}
+
+// Record is a single JSON line flowing through the pipeline. Fields are decoded lazily and only
+// when a transform modifies the record is it re-encoded, preserving the original key order.
+type Record struct {
+	raw    []byte
+	keys   []string
+	fields map[string]json.RawMessage
+	dirty  bool
+	// line is the record's line number in the input, counting from 1, and offset where the line
+	// starts in the decompressed input, when read by the pipeline
+	line   int64
+	offset int64
+}
+
+// NewRecord wraps a raw JSON line. The line is copied so the record may outlive scanner buffers.
+func NewRecord(line []byte) *Record {
+	r := &Record{}
+	r.Reset(line)
+	return r
+}
+
+// Reset replaces the record's content with a new raw JSON line
+func (r *Record) Reset(line []byte) {
+	r.raw = append(r.raw[:0], line...)
+	r.keys = r.keys[:0]
+	r.fields = nil
+	r.dirty = false
+}
+
+// decode splits the raw line into its ordered top-level fields
+func (r *Record) decode() error {
+	if r.fields != nil {
+		return nil
+	}
+	r.fields = make(map[string]json.RawMessage)
+	ok := forEachField(r.raw, func(name, raw []byte) bool {
+		key := string(name)
+		if bytes.IndexByte(name, '\\') >= 0 {
+			json.Unmarshal(append(append([]byte{'"'}, name...), '"'), &key)
+		}
+		if _, seen := r.fields[key]; !seen {
+			r.keys = append(r.keys, key)
+		}
+		r.fields[key] = raw
+		return true
+	})
+	if !ok {
+		r.fields = nil
+		r.keys = r.keys[:0]
+		return fmt.Errorf("record is not a valid JSON object")
+	}
+	return nil
+}
+
+// Get returns the raw JSON value of a top-level field
+func (r *Record) Get(key string) (json.RawMessage, bool) {
+	if r.fields == nil {
+		raw, ok := topLevelField(r.raw, key)
+		return raw, ok
+	}
+	raw, ok := r.fields[key]
+	return raw, ok
+}
+
+// GetString returns a top-level string field
+func (r *Record) GetString(key string) (string, bool) {
+	raw, ok := r.Get(key)
+	if !ok || len(raw) < 2 || raw[0] != '"' {
+		return "", false
+	}
+	if bytes.IndexByte(raw, '\\') < 0 {
+		return string(raw[1 : len(raw)-1]), true
+	}
+	var value string
+	if err := json.Unmarshal(raw, &value); err != nil {
+		return "", false
+	}
+	return value, true
+}
+
+// GetInt returns a top-level numeric field, also accepting numbers encoded as strings
+// (older dumps store e.g. created_utc as "1234567890")
+func (r *Record) GetInt(key string) (int64, bool) {
+	raw, ok := r.Get(key)
+	if !ok || len(raw) == 0 {
+		return 0, false
+	}
+	if raw[0] == '"' {
+		raw = raw[1 : len(raw)-1]
+	}
+	n, err := json.Number(raw).Float64()
+	if err != nil {
+		return 0, false
+	}
+	return int64(n), true
+}
+
+// Has reports whether the record has a top-level field
+func (r *Record) Has(key string) bool {
+	_, ok := r.Get(key)
+	return ok
+}
+
+// Keys returns the record's top-level field names in their original order
+func (r *Record) Keys() []string {
+	if err := r.decode(); err != nil {
+		return nil
+	}
+	return r.keys
+}
+
+// Set assigns a top-level field, appending it if it is new
+func (r *Record) Set(key string, value any) error {
+	raw, err := marshalJSON(value)
+	if err != nil {
+		return fmt.Errorf("failed to encode field %s: %v", key, err)
+	}
+	return r.SetRaw(key, raw)
+}
+
+// SetRaw assigns a top-level field to an already-encoded JSON value
+func (r *Record) SetRaw(key string, raw json.RawMessage) error {
+	if err := r.decode(); err != nil {
+		return err
+	}
+	if _, ok := r.fields[key]; !ok {
+		r.keys = append(r.keys, key)
+	}
+	r.fields[key] = raw
+	r.dirty = true
+	return nil
+}
+
+// Delete removes a top-level field if present
+func (r *Record) Delete(key string) {
+	if err := r.decode(); err != nil {
+		return
+	}
+	if _, ok := r.fields[key]; !ok {
+		return
+	}
+	delete(r.fields, key)
+	for i, k := range r.keys {
+		if k == key {
+			r.keys = append(r.keys[:i], r.keys[i+1:]...)
+			break
+		}
+	}
+	r.dirty = true
+}
+
+// project keeps only the top-level fields keep accepts, in their original order. A record no
+// transform has decoded is rebuilt from its raw line without decoding the values.
+func (r *Record) project(keep func(name string) bool) error {
+	if r.fields != nil {
+		var drop []string
+		for _, key := range r.keys {
+			if !keep(key) {
+				drop = append(drop, key)
+			}
+		}
+		for _, key := range drop {
+			r.Delete(key)
+		}
+		return nil
+	}
+
+	// Each kept member runs from the opening quote of its name to the end of its value
+	var members [][]byte
+	size, dropped := 2, false
+	ok := forEachField(r.raw, func(name, raw []byte) bool {
+		key := string(name)
+		if bytes.IndexByte(name, '\\') >= 0 {
+			json.Unmarshal(append(append([]byte{'"'}, name...), '"'), &key)
+		}
+		if !keep(key) {
+			dropped = true
+			return true
+		}
+		start := cap(r.raw) - cap(name) - 1
+		end := cap(r.raw) - cap(raw) + len(raw)
+		members = append(members, r.raw[start:end])
+		size += end - start + 1
+		return true
+	})
+	if !ok {
+		return fmt.Errorf("record is not a valid JSON object")
+	}
+	if !dropped {
+		return nil
+	}
+	projected := make([]byte, 0, size)
+	projected = append(projected, '{')
+	for i, member := range members {
+		if i > 0 {
+			projected = append(projected, ',')
+		}
+		projected = append(projected, member...)
+	}
+	r.raw = append(projected, '}')
+	return nil
+}
+
+// Bytes returns the record as a JSON line (without trailing newline), re-encoding it if modified
+func (r *Record) Bytes() []byte {
+	if !r.dirty {
+		return r.raw
+	}
+
+	var buf bytes.Buffer
+	buf.Grow(len(r.raw) + 64)
+	buf.WriteByte('{')
+	for i, key := range r.keys {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		name, _ := json.Marshal(key)
+		buf.Write(name)
+		buf.WriteByte(':')
+		buf.Write(r.fields[key])
+	}
+	buf.WriteByte('}')
+
+	// Cache the encoding so repeated calls are cheap; fields stay decoded for further edits
+	r.raw = buf.Bytes()
+	r.dirty = false
+	return r.raw
+}
+
+// marshalJSON encodes v like json.Marshal but without escaping &, < and >, keeping record text
+// byte-identical to the dumps
+func marshalJSON(v any) ([]byte, error) {
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(v); err != nil {
+		return nil, err
+	}
+	return bytes.TrimRight(buf.Bytes(), "\n"), nil
+}
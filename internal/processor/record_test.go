@@ -0,0 +1,165 @@
+package processor
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestRecordProject(t *testing.T) {
+	keep := func(names ...string) func(string) bool {
+		return func(name string) bool {
+			for _, n := range names {
+				if n == name {
+					return true
+				}
+			}
+			return false
+		}
+	}
+	tests := []struct {
+		name string
+		line string
+		keep []string
+		want string
+	}{
+		{"keeps selected fields in order", `{"id":"a","body":"hi","score":3}`, []string{"score", "id"}, `{"id":"a","score":3}`},
+		{"keeps everything", `{"id":"a","score":3}`, []string{"id", "score"}, `{"id":"a","score":3}`},
+		{"keeps nothing", `{"id":"a","score":3}`, nil, `{}`},
+		{"nested values", `{"media":{"a":[1,{"b":"}"}]},"id":"x","tags":["a","b"]}`, []string{"media", "tags"}, `{"media":{"a":[1,{"b":"}"}]},"tags":["a","b"]}`},
+		{"whitespace between members", `{ "id" : "a" ,  "body" : "b c" , "score" : -1.5e3 }`, []string{"id", "score"}, `{"id" : "a","score" : -1.5e3}`},
+		{"escaped names", `{"a\u0062c":1,"d":2}`, []string{"abc"}, `{"a\u0062c":1}`},
+		{"strings holding quotes and braces", `{"body":"say \"}\" ok","id":"x"}`, []string{"body"}, `{"body":"say \"}\" ok"}`},
+		{"last member only", `{"a":1,"b":null,"c":true}`, []string{"c"}, `{"c":true}`},
+		{"empty object", `{}`, []string{"id"}, `{}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := NewRecord([]byte(tt.line))
+			if err := rec.project(keep(tt.keep...)); err != nil {
+				t.Fatalf("project: %v", err)
+			}
+			if got := string(rec.Bytes()); got != tt.want {
+				t.Errorf("got %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestRecordProjectSubslice projects a record whose line sits in the middle of a larger buffer,
+// as lines do in the scanner's buffer, so offsets computed from cap() must be relative to it
+func TestRecordProjectSubslice(t *testing.T) {
+	buf := []byte(`xx{"id":"a","body":"b","score":1}{"id":"next"}`)
+	line := buf[2:33]
+	rec := NewRecord(line)
+	if err := rec.project(func(name string) bool { return name != "body" }); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := string(rec.Bytes()), `{"id":"a","score":1}`; got != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+	if got, want := string(buf), `xx{"id":"a","body":"b","score":1}{"id":"next"}`; got != want {
+		t.Errorf("project modified the buffer: %s", got)
+	}
+}
+
+func TestRecordProjectDecoded(t *testing.T) {
+	rec := NewRecord([]byte(`{"id":"a","body":"b","score":1}`))
+	if err := rec.Set("extra", 2); err != nil {
+		t.Fatal(err)
+	}
+	if err := rec.project(func(name string) bool { return name != "body" }); err != nil {
+		t.Fatal(err)
+	}
+	var got map[string]any
+	if err := json.Unmarshal(rec.Bytes(), &got); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]any{"id": "a", "score": 1.0, "extra": 2.0}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestRecordProjectInvalid(t *testing.T) {
+	for _, line := range []string{`[1,2]`, `{"id":`, `not json`, `{"id" "a"}`} {
+		rec := NewRecord([]byte(line))
+		err := rec.project(func(string) bool { return false })
+		if err == nil || !strings.Contains(err.Error(), "not a valid JSON object") {
+			t.Errorf("%s: got %v, want an invalid object error", line, err)
+		}
+	}
+}
+
+func TestForEachField(t *testing.T) {
+	tests := []struct {
+		name  string
+		line  string
+		stop  string
+		want  []string
+		valid bool
+	}{
+		{"flat object", `{"id":"a","score":3,"stickied":false,"edited":null}`, "", []string{`id=` + `"a"`, `score=3`, `stickied=false`, `edited=null`}, true},
+		{"empty object", `{}`, "", nil, true},
+		{"whitespace", " { \"id\" :\t\"a\" , \"n\" : -1.5e3 }\r", "", []string{`id="a"`, `n=-1.5e3`}, true},
+		{"nested values are not descended into", `{"media":{"id":"inner","x":[1,{"id":2}]},"id":"outer"}`, "", []string{`media={"id":"inner","x":[1,{"id":2}]}`, `id="outer"`}, true},
+		{"strings holding delimiters", `{"body":"a \"}\" ] , b","id":"x"}`, "", []string{`body="a \"}\" ] , b"`, `id="x"`}, true},
+		{"escaped names stay raw", `{"a\u0062c":1}`, "", []string{`a\u0062c=1`}, true},
+		{"stops when asked", `{"a":1,"b":2,"c":3}`, "b", []string{`a=1`, `b=2`}, true},
+		{"stopping early skips the rest unchecked", `{"a":1,"b":`, "a", []string{`a=1`}, true},
+		{"array", `[1,2]`, "", nil, false},
+		{"not json", `id=a`, "", nil, false},
+		{"empty line", ``, "", nil, false},
+		{"missing colon", `{"id" "a"}`, "", nil, false},
+		{"missing value", `{"id":}`, "", nil, false},
+		{"unterminated string", `{"id":"a}`, "", nil, false},
+		{"unterminated object", `{"id":"a"`, "", []string{`id="a"`}, false},
+		{"unterminated nested value", `{"media":{"a":1`, "", nil, false},
+		{"trailing comma", `{"id":"a",}`, "", []string{`id="a"`}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			valid := forEachField([]byte(tt.line), func(name, raw []byte) bool {
+				got = append(got, string(name)+"="+string(raw))
+				return string(name) != tt.stop
+			})
+			if valid != tt.valid || !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %q, %v, want %q, %v", got, valid, tt.want, tt.valid)
+			}
+		})
+	}
+}
+
+func TestExtractStringField(t *testing.T) {
+	tests := []struct {
+		name   string
+		line   string
+		key    string
+		want   string
+		wantOK bool
+	}{
+		{"plain string", `{"id":"abc","subreddit":"pics"}`, "subreddit", "pics", true},
+		{"escapes", `{"body":"say \"hi\"\né"}`, "body", "say \"hi\"\né", true},
+		{"empty string", `{"author":""}`, "author", "", true},
+		{"top level only", `{"crosspost_parent_list":[{"subreddit":"inner"}],"subreddit":"outer"}`, "subreddit", "outer", true},
+		{"nested only", `{"media":{"subreddit":"inner"}}`, "subreddit", "", false},
+		{"missing", `{"id":"abc"}`, "author", "", false},
+		{"not a string", `{"score":5}`, "score", "", false},
+		{"null", `{"author":null}`, "author", "", false},
+		{"invalid escape", `{"body":"\x"}`, "body", "", false},
+		{"first of duplicates", `{"id":"a","id":"b"}`, "id", "a", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := extractStringField([]byte(tt.line), tt.key)
+			if got != tt.want || ok != tt.wantOK {
+				t.Errorf("got %q, %v, want %q, %v", got, ok, tt.want, tt.wantOK)
+			}
+			if raw, found := topLevelField([]byte(tt.line), tt.key); tt.wantOK && (!found || len(raw) == 0) {
+				t.Errorf("topLevelField did not find %s", tt.key)
+			}
+		})
+	}
+}
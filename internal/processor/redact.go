@@ -0,0 +1,311 @@
+package processor
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"hash"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"unicode/utf8"
+)
+
+// Redaction actions of a RedactionRule
+const (
+	// RedactDrop removes the column
+	RedactDrop = "drop"
+	// RedactHash replaces the value with a keyed hash, so records of the same author or link
+	// still group together without revealing it
+	RedactHash = "hash"
+	// RedactTruncate shortens text to MaxChars characters
+	RedactTruncate = "truncate"
+)
+
+// redactHashBytes is how much of the HMAC-SHA256 digest hashed values keep, hex encoded
+const redactHashBytes = 16
+
+// RedactionRule applies one action to the top-level columns matching any of its patterns
+type RedactionRule struct {
+	// Columns are names, globs (author_flair_*) or /regular expressions/
+	Columns []string `json:"columns"`
+	Action  string   `json:"action"`
+	// MaxChars is the length text is truncated to by RedactTruncate
+	MaxChars int `json:"max_chars,omitempty"`
+}
+
+// RedactionPolicy is a data-governance policy declared once in a JSON file and applied to every
+// run: which columns are dropped, hashed or truncated. A column takes the action of the first
+// rule matching it.
+type RedactionPolicy struct {
+	// Salt keys the hashes; SaltEnv names an environment variable holding it instead, so the
+	// policy file can be shared without the salt. Unsalted hashes of known values can be reversed
+	// by hashing candidates.
+	Salt    string          `json:"salt,omitempty"`
+	SaltEnv string          `json:"salt_env,omitempty"`
+	Rules   []RedactionRule `json:"rules"`
+	// Source is the path the policy was loaded from
+	Source string `json:"-"`
+}
+
+// DefaultRedactionPolicyPath returns the per-user redaction policy file, applied to every run
+// when it exists
+func DefaultRedactionPolicyPath() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "pushshift_redaction.json"
+	}
+	return filepath.Join(home, ".pushshift", "redaction.json")
+}
+
+// LoadRedactionPolicy reads and validates a redaction policy file. A missing file returns a nil
+// policy unless required is set.
+func LoadRedactionPolicy(path string, required bool) (*RedactionPolicy, error) {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) && !required {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read redaction policy: %v", err)
+	}
+	var policy RedactionPolicy
+	if err := json.Unmarshal(data, &policy); err != nil {
+		return nil, fmt.Errorf("failed to parse redaction policy %s: %v", path, err)
+	}
+	if err := policy.validate(); err != nil {
+		return nil, fmt.Errorf("invalid redaction policy %s: %v", path, err)
+	}
+	policy.Source = path
+	return &policy, nil
+}
+
+// validate checks the actions and patterns of the rules and resolves the salt
+func (p *RedactionPolicy) validate() error {
+	if p.SaltEnv != "" {
+		if p.Salt != "" {
+			return fmt.Errorf("salt and salt_env are mutually exclusive")
+		}
+		p.Salt = os.Getenv(p.SaltEnv)
+		if p.Salt == "" {
+			return fmt.Errorf("salt environment variable %s is not set", p.SaltEnv)
+		}
+	}
+	for i, rule := range p.Rules {
+		if len(rule.Columns) == 0 {
+			return fmt.Errorf("rule %d has no columns", i+1)
+		}
+		switch rule.Action {
+		case RedactDrop, RedactHash:
+		case RedactTruncate:
+			if rule.MaxChars <= 0 {
+				return fmt.Errorf("rule %d truncates without a positive max_chars", i+1)
+			}
+		default:
+			return fmt.Errorf("rule %d has unsupported action %q, expected drop, hash or truncate", i+1, rule.Action)
+		}
+		if _, err := parseNamePatterns(rule.Columns, "column"); err != nil {
+			return fmt.Errorf("rule %d: %v", i+1, err)
+		}
+	}
+	return nil
+}
+
+// RedactionTransform applies a RedactionPolicy to every record
+type RedactionTransform struct {
+	policy   *RedactionPolicy
+	patterns [][]namePattern
+	// rules caches the index of the rule matching each column, -1 for none
+	rules  sync.Map
+	hashes sync.Pool
+
+	dropped   atomic.Int64
+	hashed    atomic.Int64
+	truncated atomic.Int64
+
+	runLogger
+}
+
+// NewRedactionTransform compiles the rules of a validated policy
+func NewRedactionTransform(policy *RedactionPolicy) (*RedactionTransform, error) {
+	t := &RedactionTransform{policy: policy}
+	for _, rule := range policy.Rules {
+		patterns, err := parseNamePatterns(rule.Columns, "column")
+		if err != nil {
+			return nil, err
+		}
+		t.patterns = append(t.patterns, patterns)
+	}
+	salt := []byte(policy.Salt)
+	t.hashes.New = func() any { return hmac.New(sha256.New, salt) }
+	return t, nil
+}
+
+// rule returns the rule applying to a column, or nil
+func (t *RedactionTransform) rule(name string) *RedactionRule {
+	index := -1
+	if cached, ok := t.rules.Load(name); ok {
+		index = cached.(int)
+	} else {
+		for i, patterns := range t.patterns {
+			if matchesAny(patterns, name) {
+				index = i
+				break
+			}
+		}
+		t.rules.Store(name, index)
+	}
+	if index < 0 {
+		return nil
+	}
+	return &t.policy.Rules[index]
+}
+
+// Apply redacts the columns matched by the policy. Null values are left as they are.
+func (t *RedactionTransform) Apply(rec *Record) (bool, error) {
+	return true, t.redact(rec, true)
+}
+
+// redact applies the policy to a record, counting the redacted values in the run statistics
+// when counted is set
+func (t *RedactionTransform) redact(rec *Record, counted bool) error {
+	count := func(n *atomic.Int64) {
+		if counted {
+			n.Add(1)
+		}
+	}
+	for _, key := range rec.Keys() {
+		rule := t.rule(key)
+		if rule == nil {
+			continue
+		}
+		switch rule.Action {
+		case RedactDrop:
+			rec.Delete(key)
+			count(&t.dropped)
+		case RedactHash:
+			raw, _ := rec.Get(key)
+			if string(raw) == "null" {
+				continue
+			}
+			value := []byte(raw)
+			if s, ok := rec.GetString(key); ok {
+				value = []byte(s)
+			}
+			if err := rec.Set(key, t.hash(value)); err != nil {
+				return err
+			}
+			count(&t.hashed)
+		case RedactTruncate:
+			s, ok := rec.GetString(key)
+			if !ok || utf8.RuneCountInString(s) <= rule.MaxChars {
+				continue
+			}
+			if err := rec.Set(key, truncateChars(s, rule.MaxChars)); err != nil {
+				return err
+			}
+			count(&t.truncated)
+		}
+	}
+	return nil
+}
+
+// redactLine returns a JSON line with the policy applied, for side outputs written before the
+// transform runs. Lines that aren't JSON objects can't be redacted and return false.
+func (t *RedactionTransform) redactLine(line []byte) ([]byte, bool) {
+	rec := NewRecord(line)
+	if rec.decode() != nil {
+		return nil, false
+	}
+	if err := t.redact(rec, false); err != nil {
+		return nil, false
+	}
+	return rec.Bytes(), true
+}
+
+// rewrites reports whether the policy drops a column or hashes it into a string, so the types
+// transforms give the column no longer apply
+func (t *RedactionTransform) rewrites(name string) bool {
+	rule := t.rule(name)
+	return rule != nil && (rule.Action == RedactDrop || rule.Action == RedactHash)
+}
+
+// sideOutput returns a line for a side output, such as a quarantine or the dropped sample, caught
+// by filter or transform i of the run, whose policy's transform is at index at. Lines caught
+// before it are redacted and lines caught after it already are. Lines caught by the policy's own
+// transform, or that can't be redacted, are withheld and return false.
+func (t *RedactionTransform) sideOutput(at, i int, line []byte) ([]byte, bool) {
+	switch {
+	case t == nil || i > at:
+		return line, true
+	case i == at:
+		return nil, false
+	}
+	return t.redactLine(line)
+}
+
+// sideFiler is implemented by transforms writing the records they route out of the output to
+// side files
+type sideFiler interface {
+	sideFiles() []*sideFile
+}
+
+// redaction returns the run's redaction transform and its index among the filters and then the
+// transforms, or nil and -1 without one
+func (s *PushshiftProcessor) redaction() (*RedactionTransform, int) {
+	for i, t := range slices.Concat(s.Options.Filters, s.Options.Transforms) {
+		if t, ok := t.(*RedactionTransform); ok {
+			return t, i
+		}
+	}
+	return nil, -1
+}
+
+// redactSideFiles has the side files and side tables of the transforms running before the
+// redaction policy apply it to what they write, so no side output holds values the policy removes
+func (s *PushshiftProcessor) redactSideFiles() {
+	redaction, at := s.redaction()
+	if redaction == nil {
+		return
+	}
+	for _, t := range s.Options.Transforms[:max(at-len(s.Options.Filters), 0)] {
+		for _, file := range transformSideFiles(t) {
+			file.redact = redaction.redactLine
+		}
+	}
+}
+
+// hash returns the hex-encoded keyed hash of a value
+func (t *RedactionTransform) hash(value []byte) string {
+	h := t.hashes.Get().(hash.Hash)
+	defer t.hashes.Put(h)
+	h.Reset()
+	h.Write(value)
+	return hex.EncodeToString(h.Sum(nil)[:redactHashBytes])
+}
+
+// truncateChars returns the first n characters of s
+func truncateChars(s string, n int) string {
+	for i := range s {
+		if n == 0 {
+			return s[:i]
+		}
+		n--
+	}
+	return s
+}
+
+// Close reports how many values were redacted
+func (t *RedactionTransform) Close() error {
+	loggerOrDefault(t.logger).Printf("🕶️ Redaction policy %s: %d values dropped, %d hashed, %d truncated",
+		t.policy.Source, t.dropped.Load(), t.hashed.Load(), t.truncated.Load())
+	return nil
+}
+
+// provenance implements provenanced, without the salt
+func (t *RedactionTransform) provenance() map[string]any {
+	return map[string]any{"redaction_policy": map[string]any{"source": t.policy.Source, "rules": t.policy.Rules}}
+}
@@ -0,0 +1,43 @@
+package processor
+
+import "testing"
+
+func TestRedactionSideOutput(t *testing.T) {
+	redaction, err := NewRedactionTransform(&RedactionPolicy{Salt: "s", Rules: []RedactionRule{
+		{Columns: []string{"author"}, Action: RedactHash},
+		{Columns: []string{"email"}, Action: RedactDrop},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	hashed := `{"author":"` + redaction.hash([]byte("alice")) + `","body":"hi"}`
+	tests := []struct {
+		name      string
+		redaction *RedactionTransform
+		i         int
+		line      string
+		want      string
+		withheld  bool
+	}{
+		{"no policy", nil, 0, `{"author":"alice"}`, `{"author":"alice"}`, false},
+		{"caught before the policy", redaction, 1, `{"author":"alice","email":"a@b","body":"hi"}`, hashed, false},
+		{"caught by a filter", redaction, -1, `{"author":"alice","body":"hi"}`, hashed, false},
+		{"caught after the policy", redaction, 3, hashed, hashed, false},
+		{"caught by the policy", redaction, 2, `{"author":"alice"}`, "", true},
+		{"not a JSON object", redaction, 1, `{"author":"alice"`, "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := tt.redaction.sideOutput(2, tt.i, []byte(tt.line))
+			if ok == tt.withheld {
+				t.Fatalf("got withheld %v, want %v", !ok, tt.withheld)
+			}
+			if ok && string(got) != tt.want {
+				t.Errorf("got %s, want %s", got, tt.want)
+			}
+		})
+	}
+	if n := redaction.hashed.Load() + redaction.dropped.Load(); n != 0 {
+		t.Errorf("side outputs counted %d redacted values", n)
+	}
+}
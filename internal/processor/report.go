@@ -0,0 +1,179 @@
+package processor
+
+import (
+	"encoding/csv"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// subredditSummary accumulates the statistics of one subreddit
+type subredditSummary struct {
+	name         string
+	records      int64
+	authors      DistinctCounter
+	score        *TDigest
+	first, last  int64
+	hasCreatedAt bool
+}
+
+// SubredditReportRow is one line of the per-subreddit report
+type SubredditReportRow struct {
+	Subreddit     string   `json:"subreddit"`
+	Records       int64    `json:"records"`
+	UniqueAuthors int64    `json:"unique_authors"`
+	ScoreP50      *float64 `json:"score_p50"`
+	ScoreP90      *float64 `json:"score_p90"`
+	ScoreP99      *float64 `json:"score_p99"`
+	FirstCreated  string   `json:"first_created"`
+	LastCreated   string   `json:"last_created"`
+}
+
+// SubredditReport collects per-subreddit record counts, unique authors, score quantiles and date
+// ranges of the records written, and saves them as CSV or JSON when closed. It never drops records
+// and should be the last transform so it sees the output as written.
+type SubredditReport struct {
+	path string
+
+	mu   sync.Mutex
+	subs map[string]*subredditSummary
+
+	runLogger
+}
+
+// NewSubredditReport creates a report written to path; a .json extension selects JSON, anything
+// else CSV
+func NewSubredditReport(path string) *SubredditReport {
+	return &SubredditReport{path: path, subs: make(map[string]*subredditSummary)}
+}
+
+// Apply adds the record to its subreddit's summary
+func (r *SubredditReport) Apply(rec *Record) (bool, error) {
+	subreddit, _ := rec.GetString("subreddit")
+	author, _ := rec.GetString("author")
+	score, hasScore := rec.GetInt("score")
+	created, hasCreated := rec.GetInt("created_utc")
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	// Case variants of a subreddit are one community, reported under the first spelling seen
+	key := normalizeSubreddit(subreddit)
+	sub := r.subs[key]
+	if sub == nil {
+		sub = &subredditSummary{name: subreddit, score: NewTDigest()}
+		r.subs[key] = sub
+	}
+	sub.records++
+	if author != "" && author != "[deleted]" {
+		sub.authors.Add(normalizeAuthor(author))
+	}
+	if hasScore {
+		sub.score.Add(float64(score))
+	}
+	if hasCreated {
+		if !sub.hasCreatedAt || created < sub.first {
+			sub.first = created
+		}
+		if !sub.hasCreatedAt || created > sub.last {
+			sub.last = created
+		}
+		sub.hasCreatedAt = true
+	}
+	return true, nil
+}
+
+// Rows returns the report rows, largest subreddits first
+func (r *SubredditReport) Rows() []SubredditReportRow {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	rows := make([]SubredditReportRow, 0, len(r.subs))
+	for _, sub := range r.subs {
+		row := SubredditReportRow{
+			Subreddit:     sub.name,
+			Records:       sub.records,
+			UniqueAuthors: sub.authors.Count(),
+			ScoreP50:      quantilePtr(sub.score, 0.5),
+			ScoreP90:      quantilePtr(sub.score, 0.9),
+			ScoreP99:      quantilePtr(sub.score, 0.99),
+		}
+		if sub.hasCreatedAt {
+			row.FirstCreated = time.Unix(sub.first, 0).UTC().Format(time.RFC3339)
+			row.LastCreated = time.Unix(sub.last, 0).UTC().Format(time.RFC3339)
+		}
+		rows = append(rows, row)
+	}
+	sort.Slice(rows, func(i, j int) bool {
+		if rows[i].Records != rows[j].Records {
+			return rows[i].Records > rows[j].Records
+		}
+		return rows[i].Subreddit < rows[j].Subreddit
+	})
+	return rows
+}
+
+// quantilePtr returns a digest quantile rounded to two decimals, or nil when the digest is empty
+func quantilePtr(d *TDigest, q float64) *float64 {
+	if d.Count() == 0 {
+		return nil
+	}
+	v := math.Round(d.Quantile(q)*100) / 100
+	return &v
+}
+
+// formatQuantile renders an optional quantile for CSV, empty when missing
+func formatQuantile(v *float64) string {
+	if v == nil {
+		return ""
+	}
+	return strconv.FormatFloat(*v, 'f', -1, 64)
+}
+
+// Close writes the report file
+func (r *SubredditReport) Close() error {
+	rows := r.Rows()
+	f, err := os.Create(r.path)
+	if err != nil {
+		return fmt.Errorf("failed to create subreddit report: %v", err)
+	}
+	defer f.Close()
+
+	if strings.EqualFold(filepath.Ext(r.path), ".json") {
+		encoded, err := marshalJSON(rows)
+		if err != nil {
+			return fmt.Errorf("failed to encode subreddit report: %v", err)
+		}
+		if _, err := f.Write(append(encoded, '\n')); err != nil {
+			return fmt.Errorf("failed to write subreddit report: %v", err)
+		}
+	} else {
+		w := csv.NewWriter(f)
+		w.Write([]string{"subreddit", "records", "unique_authors", "score_p50", "score_p90", "score_p99", "first_created", "last_created"})
+		for _, row := range rows {
+			w.Write([]string{
+				row.Subreddit,
+				strconv.FormatInt(row.Records, 10),
+				strconv.FormatInt(row.UniqueAuthors, 10),
+				formatQuantile(row.ScoreP50),
+				formatQuantile(row.ScoreP90),
+				formatQuantile(row.ScoreP99),
+				row.FirstCreated,
+				row.LastCreated,
+			})
+		}
+		w.Flush()
+		if err := w.Error(); err != nil {
+			return fmt.Errorf("failed to write subreddit report: %v", err)
+		}
+	}
+
+	loggerOrDefault(r.logger).Printf("📊 Wrote statistics for %d subreddits to %s", len(rows), r.path)
+	return f.Close()
+}
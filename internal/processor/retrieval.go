@@ -0,0 +1,75 @@
+package processor
+
+import (
+	"encoding/json"
+	"strconv"
+	"sync/atomic"
+	"time"
+)
+
+// RetrievalLagColumn holds how long after creation a record was captured
+const RetrievalLagColumn = "retrieval_lag_seconds"
+
+// RetrievalLagTransform measures how long after creation each record was captured, as
+// retrieved_on (retrieved_utc in newer dumps) minus created_utc. Scores, comment counts and
+// removals of records captured long after creation reflect a different moment than those captured
+// right away, so such records can be flagged with a column, dropped, or both.
+type RetrievalLagTransform struct {
+	// Column adds retrieval_lag_seconds, null when either timestamp is missing
+	Column bool
+	// MaxLag, when positive, drops records captured more than this long after creation. Records
+	// without both timestamps are kept.
+	MaxLag time.Duration
+
+	dropped atomic.Int64
+
+	runLogger
+}
+
+// retrievalLag returns the record's retrieval lag in seconds
+func retrievalLag(rec *Record) (int64, bool) {
+	created, ok := rec.GetInt("created_utc")
+	if !ok {
+		return 0, false
+	}
+	retrieved, ok := rec.GetInt("retrieved_on")
+	if !ok {
+		if retrieved, ok = rec.GetInt("retrieved_utc"); !ok {
+			return 0, false
+		}
+	}
+	return retrieved - created, true
+}
+
+// Apply adds the lag column and drops records over the maximum lag
+func (t *RetrievalLagTransform) Apply(rec *Record) (bool, error) {
+	lag, ok := retrievalLag(rec)
+	if ok && t.MaxLag > 0 && lag > int64(t.MaxLag/time.Second) {
+		t.dropped.Add(1)
+		return false, nil
+	}
+	if !t.Column {
+		return true, nil
+	}
+	raw := json.RawMessage("null")
+	if ok {
+		raw = json.RawMessage(strconv.FormatInt(lag, 10))
+	}
+	return true, rec.SetRaw(RetrievalLagColumn, raw)
+}
+
+// ParquetColumns types the lag column
+func (t *RetrievalLagTransform) ParquetColumns() map[string]string {
+	if !t.Column {
+		return nil
+	}
+	return map[string]string{RetrievalLagColumn: "CAST(" + RetrievalLagColumn + " AS BIGINT)"}
+}
+
+// Close reports how many records were dropped for their retrieval lag
+func (t *RetrievalLagTransform) Close() error {
+	if n := t.dropped.Load(); n > 0 {
+		loggerOrDefault(t.logger).Printf("🕰️ Dropped %d records retrieved more than %s after creation", n, t.MaxLag)
+	}
+	return nil
+}
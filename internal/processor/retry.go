@@ -0,0 +1,241 @@
+package processor
+
+import (
+	"bufio"
+	"cmp"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"slices"
+	"time"
+)
+
+// RetryOptions selects the input RetryParts reads the parts from
+type RetryOptions struct {
+	// Input replaces the input recorded in the manifest, e.g. after the dump was moved
+	Input string
+	// SkipVerify skips comparing the input's checksum with the one recorded in the manifest
+	SkipVerify bool
+}
+
+// partsToRetry returns the parts of a manifest that failed to convert or whose Parquet file is
+// missing, in part order
+func partsToRetry(m *Manifest) []PartInfo {
+	parts := append([]PartInfo{}, m.FailedParts...)
+	for _, part := range m.Parts {
+		if _, err := os.Stat(part.Path); os.IsNotExist(err) {
+			parts = append(parts, part)
+		}
+	}
+	slices.SortFunc(parts, func(a, b PartInfo) int { return cmp.Compare(a.Number, b.Number) })
+	return parts
+}
+
+// runOfPart returns the run that wrote a part of the manifest, with its input, checksum and
+// start offset
+func (m *Manifest) runOfPart(number int) ManifestRun {
+	found := m.run()
+	for _, run := range m.Runs {
+		if run.FirstPart > 0 && run.FirstPart <= number {
+			found = run
+		}
+	}
+	return found
+}
+
+// RetryParts rebuilds the parts of an output that failed to convert in a run with
+// ContinueOnPartError, or whose Parquet file has gone missing. Each part is rewritten from the
+// input lines recorded for it in the manifest, with the processor's transforms, which should be
+// those of the original run. Reading stops after the last of them. The manifest is updated as
+// parts are rebuilt; parts failing again stay listed as failed and ErrPartsFailed is returned.
+func (s *PushshiftProcessor) RetryParts(outputPath string, opts RetryOptions) ([]PartInfo, error) {
+	manifest, err := readManifest(outputPath)
+	if err != nil {
+		return nil, err
+	}
+	if manifest == nil {
+		return nil, fmt.Errorf("no manifest found at %s", ManifestPath(outputPath))
+	}
+	if err := ValidatePartCompressionLevel(s.Options.PartCompressionLevel); err != nil {
+		return nil, err
+	}
+
+	// Parts of appended runs are grouped by the input they were read from
+	parts := partsToRetry(manifest)
+	if len(parts) == 0 {
+		s.logger().Printf("✅ No failed or missing parts in %s", ManifestPath(outputPath))
+		return nil, nil
+	}
+	var inputs []string
+	byInput := make(map[string][]PartInfo)
+	for _, part := range parts {
+		if part.LastLine == 0 {
+			return nil, fmt.Errorf("part %d has no input line range in the manifest, so only a full run can rebuild it", part.Number)
+		}
+		input := manifest.runOfPart(part.Number).Input
+		if _, ok := byInput[input]; !ok {
+			inputs = append(inputs, input)
+		}
+		byInput[input] = append(byInput[input], part)
+	}
+	if opts.Input != "" && len(inputs) > 1 {
+		return nil, fmt.Errorf("the parts to retry were read from %d different inputs, which one input can't replace", len(inputs))
+	}
+
+	defer s.discardSideTables()
+
+	var retried []PartInfo
+	failed := &ErrPartsFailed{}
+	for _, input := range inputs {
+		group := byInput[input]
+		run := manifest.runOfPart(group[0].Number)
+		sum := run.InputSHA256
+		if opts.Input != "" {
+			input = opts.Input
+		}
+		if !opts.SkipVerify && sum != "" {
+			s.logger().Printf("🔍 Verifying checksum of %s", input)
+			actual, err := inputSHA256(input)
+			if err != nil {
+				return retried, err
+			}
+			if actual != sum {
+				return retried, fmt.Errorf("input checksum mismatch: manifest has %s, %s has %s", sum, input, actual)
+			}
+		}
+
+		rebuilt, err := s.retryFromInput(input, sum, run.StartOffset, outputPath, group)
+		for _, part := range rebuilt {
+			manifest.replacePart(part)
+			if part.Error != "" {
+				failed.Parts = append(failed.Parts, part.Number)
+			} else {
+				retried = append(retried, part)
+			}
+		}
+		if len(rebuilt) > 0 {
+			if err := writeManifestFile(outputPath, *manifest); err != nil {
+				return retried, err
+			}
+		}
+		if err != nil {
+			return retried, err
+		}
+	}
+
+	s.logger().Printf("✅ Rebuilt %d of %d parts", len(retried), len(parts))
+	if len(failed.Parts) > 0 {
+		return retried, failed
+	}
+	return retried, nil
+}
+
+// retryFromInput rebuilds parts from their lines of one input, whose checksum is sha when known, in
+// a single pass. Reading begins at startOffset, where the original run began after seeking for
+// -start-at, since its line ranges count from there. It returns the parts it got to, with Error
+// set on those that failed to convert again.
+func (s *PushshiftProcessor) retryFromInput(inputPath, sha string, startOffset int64, outputPath string, parts []PartInfo) ([]PartInfo, error) {
+	slices.SortFunc(parts, func(a, b PartInfo) int { return cmp.Compare(a.FirstLine, b.FirstLine) })
+	ranges := make([]lineRange, len(parts))
+	for i, part := range parts {
+		ranges[i] = lineRange{first: part.FirstLine, last: part.LastLine}
+	}
+
+	s.logger().Printf("📖 Reading the lines of %d parts from %s", len(parts), inputPath)
+	in, err := s.openRetryInput(inputPath, startOffset)
+	if err != nil {
+		return nil, err
+	}
+	defer in.Close()
+	scanner := bufio.NewScanner(in)
+	scanner.Buffer(make([]byte, scannerBufferSize), scannerBufferSize)
+	scanner.Split(in.lines.split)
+	input := s.startStages(in, scanner, ranges)
+	defer input.stop()
+
+	stats := s.newStats()
+	var rebuilt []PartInfo
+	for _, part := range parts {
+		partPath := s.intermediatePartPath(outputPath, part.Number)
+		s.logger().Printf("🔁 Rebuilding part %d from input lines %d to %d", part.Number, part.FirstLine, part.LastLine)
+		bytesWritten, lines, err := s.processPartFile(input, partPath, part.Number, math.MaxInt64, &stats)
+		if err != io.EOF {
+			removeScratch(s.logger(), partPath)
+			return rebuilt, fmt.Errorf("failed to rebuild part %d: %w", part.Number, err)
+		}
+		input.nextRange()
+		if lines != part.Lines {
+			removeScratch(s.logger(), partPath)
+			return rebuilt, fmt.Errorf("part %d now has %d lines instead of %d: the input, filters or transforms differ from the original run", part.Number, lines, part.Lines)
+		}
+
+		// A Parquet file left over from the original run must not pass for the converted part
+		removeScratch(s.logger(), part.Path)
+		parquetBaseName := fmt.Sprintf("%s_part_%03d", outputPath, part.Number)
+		part.Lines, part.JSONLBytes, part.ParquetBytes, part.Error = lines, bytesWritten, 0, ""
+		convErr := s.convertPart(&part, partPath, parquetBaseName, s.parquetColumns())
+		removeScratch(s.logger(), partPath)
+		if convErr != nil {
+			errorf(s.logger(), "❌ Part %d failed again: %v", part.Number, convErr)
+			part.Error = (&ErrConversionFailed{Part: part.Number, Path: partPath, Err: convErr}).Error()
+		} else {
+			s.stampPart(part, s.partMetadata(inputPath, sha, part))
+			if info, err := os.Stat(part.Path); err == nil {
+				part.ParquetBytes = info.Size()
+			}
+			s.logger().Printf("✅ Rebuilt part %d: %d lines", part.Number, lines)
+		}
+		rebuilt = append(rebuilt, part)
+	}
+	return rebuilt, nil
+}
+
+// openRetryInput opens the input of a retry at the offset the original run began reading at.
+// The offset must start a frame of the input, which the cached frame index confirms when it has
+// the input.
+func (s *PushshiftProcessor) openRetryInput(inputPath string, startOffset int64) (*zstInput, error) {
+	if s.Options.InputReader != nil || len(s.Options.ConcatInputs) > 0 {
+		return s.openInput(inputPath)
+	}
+	// The original run's line ranges count from where it began, not from a new -start-at seek
+	opts := s.inputOptions()
+	opts.startAt = time.Time{}
+	if startOffset > 0 {
+		if entry, ok := s.Options.Cache.Lookup(inputPath); ok && entry.Frames != nil &&
+			!slices.ContainsFunc(entry.Frames, func(f frameSpan) bool { return f.Offset == startOffset }) {
+			return nil, fmt.Errorf("the manifest's start offset %d is not the start of a zstd frame of %s", startOffset, inputPath)
+		}
+		s.logger().Printf("⏩ Entering the input at offset %d, where the original run began after seeking for -start-at", startOffset)
+		opts.resumeAt = &resumePoint{offset: startOffset}
+	}
+	return openZstInput(inputPath, opts)
+}
+
+// replacePart records a retried part, as converted or as failed again
+func (m *Manifest) replacePart(part PartInfo) {
+	remove := func(parts []PartInfo) []PartInfo {
+		return slices.DeleteFunc(parts, func(p PartInfo) bool { return p.Number == part.Number })
+	}
+	m.Parts, m.FailedParts = remove(m.Parts), remove(m.FailedParts)
+	if part.Error != "" {
+		m.FailedParts = append(m.FailedParts, part)
+	} else {
+		m.Parts = append(m.Parts, part)
+	}
+	byNumber := func(a, b PartInfo) int { return cmp.Compare(a.Number, b.Number) }
+	slices.SortFunc(m.Parts, byNumber)
+	slices.SortFunc(m.FailedParts, byNumber)
+}
+
+// discardSideTables removes the rows side table writers extracted from the retried parts. Side
+// tables cover the whole input, so retrying parts doesn't rebuild them.
+func (s *PushshiftProcessor) discardSideTables() {
+	for _, transform := range s.Options.Transforms {
+		if writer, ok := transform.(SideTableWriter); ok {
+			for _, table := range writer.SideTables() {
+				table.Close()
+			}
+		}
+	}
+}
@@ -0,0 +1,303 @@
+package processor
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// RouteCondition selects the records a route takes. A record must meet every condition given;
+// a route without conditions takes every record offered to it.
+type RouteCondition struct {
+	// Subreddits are names or patterns, as -subreddits takes them
+	Subreddits []string `json:"subreddits,omitempty"`
+	// Authors are names, ignoring case
+	Authors []string `json:"authors,omitempty"`
+	// MinScore and MaxScore bound the score, inclusive. Records without a score meet neither.
+	MinScore *int64 `json:"min_score,omitempty"`
+	MaxScore *int64 `json:"max_score,omitempty"`
+	// Fields maps top-level fields to the value they must hold: a string, number, boolean or
+	// null, or a list of values any of which matches
+	Fields map[string]any `json:"fields,omitempty"`
+}
+
+// Route sends the records meeting its condition to the output named To
+type Route struct {
+	To   string         `json:"to"`
+	When RouteCondition `json:"when"`
+	// Stop keeps the records this route takes from the routes after it
+	Stop bool `json:"stop,omitempty"`
+}
+
+// RoutingConfig sends each record to every output whose routes it meets, in one pass over the
+// input. Outputs are directories of Parquet files under the output path, named by the routes.
+type RoutingConfig struct {
+	Routes []Route `json:"routes"`
+	// Default is the output of the records no route took, which are dropped when it is empty
+	Default string `json:"default,omitempty"`
+	// Source is the path the config was loaded from
+	Source string `json:"-"`
+}
+
+// LoadRoutingConfig reads and validates a routing config file
+func LoadRoutingConfig(path string) (*RoutingConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read routing config: %v", err)
+	}
+	var config RoutingConfig
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&config); err != nil {
+		return nil, fmt.Errorf("failed to parse routing config %s: %v", path, err)
+	}
+	if _, err := newRouter(&config); err != nil {
+		return nil, fmt.Errorf("invalid routing config %s: %v", path, err)
+	}
+	config.Source = path
+	return &config, nil
+}
+
+// Outputs returns the names of the outputs, in the order the routes name them, then the default
+func (c *RoutingConfig) Outputs() []string {
+	var outputs []string
+	for _, route := range c.Routes {
+		if !slices.Contains(outputs, route.To) {
+			outputs = append(outputs, route.To)
+		}
+	}
+	if c.Default != "" && !slices.Contains(outputs, c.Default) {
+		outputs = append(outputs, c.Default)
+	}
+	return outputs
+}
+
+// validateOutputName checks that an output names a single directory under the output path
+func validateOutputName(name string) error {
+	switch {
+	case name == "":
+		return fmt.Errorf("an output name is empty")
+	case strings.ContainsAny(name, `/\`) || name == "..":
+		return fmt.Errorf("output %q must name a directory directly under the output path", name)
+	case strings.HasPrefix(name, "."):
+		return fmt.Errorf("output %q can't start with a dot, which hides it from engines reading the dataset", name)
+	case strings.Contains(name, "="):
+		return fmt.Errorf("output %q can't contain '=', which engines read as a partition column", name)
+	}
+	return nil
+}
+
+// ValidateRoutes checks Options.Routes and the options routed output can't be combined with
+func (o Options) ValidateRoutes() error {
+	_, err := o.validateRoutes()
+	return err
+}
+
+// validateRoutes compiles Options.Routes and reports options routed output can't be combined
+// with
+func (o Options) validateRoutes() (*router, error) {
+	routes, err := newRouter(o.Routes)
+	if err != nil {
+		return nil, fmt.Errorf("invalid routing config: %v", err)
+	}
+	if len(o.PartitionBy) > 0 {
+		if _, err := partitionColumns(o.PartitionBy); err != nil {
+			return nil, err
+		}
+	}
+	if err := o.validateDataset("routed"); err != nil {
+		return nil, err
+	}
+	return routes, nil
+}
+
+// routeRule is a compiled Route
+type routeRule struct {
+	to    string
+	stop  bool
+	match []func(rec *Record) bool
+}
+
+// router decides the outputs of each record
+type router struct {
+	rules []routeRule
+	def   string
+}
+
+// newRouter compiles and checks the routes of a config
+func newRouter(config *RoutingConfig) (*router, error) {
+	if len(config.Routes) == 0 {
+		return nil, fmt.Errorf("no routes given")
+	}
+	r := &router{def: config.Default}
+	if config.Default != "" {
+		if err := validateOutputName(config.Default); err != nil {
+			return nil, fmt.Errorf("default: %v", err)
+		}
+	}
+	for i, route := range config.Routes {
+		if err := validateOutputName(route.To); err != nil {
+			return nil, fmt.Errorf("route %d: %v", i+1, err)
+		}
+		match, err := route.When.compile()
+		if err != nil {
+			return nil, fmt.Errorf("route %d: %v", i+1, err)
+		}
+		r.rules = append(r.rules, routeRule{to: route.To, stop: route.Stop, match: match})
+	}
+	return r, nil
+}
+
+// compile returns the tests of a condition's parts
+func (c RouteCondition) compile() ([]func(rec *Record) bool, error) {
+	var match []func(rec *Record) bool
+	if len(c.Subreddits) > 0 {
+		subreddits, err := NewSubredditFilter(c.Subreddits)
+		if err != nil {
+			return nil, err
+		}
+		match = append(match, func(rec *Record) bool {
+			subreddit, _ := rec.GetString("subreddit")
+			return subreddits.kept(subreddit)
+		})
+	}
+	if len(c.Authors) > 0 {
+		authors := make(map[string]bool, len(c.Authors))
+		for _, author := range c.Authors {
+			authors[strings.ToLower(strings.TrimPrefix(author, "u/"))] = true
+		}
+		match = append(match, func(rec *Record) bool {
+			author, _ := rec.GetString("author")
+			return authors[strings.ToLower(author)]
+		})
+	}
+	if c.MinScore != nil || c.MaxScore != nil {
+		if c.MinScore != nil && c.MaxScore != nil && *c.MinScore > *c.MaxScore {
+			return nil, fmt.Errorf("min_score %d is above max_score %d", *c.MinScore, *c.MaxScore)
+		}
+		minScore, maxScore := c.MinScore, c.MaxScore
+		match = append(match, func(rec *Record) bool {
+			score, ok := rec.GetInt("score")
+			return ok && (minScore == nil || score >= *minScore) && (maxScore == nil || score <= *maxScore)
+		})
+	}
+	names := make([]string, 0, len(c.Fields))
+	for name := range c.Fields {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	for _, name := range names {
+		given, ok := c.Fields[name].([]any)
+		if !ok {
+			given = []any{c.Fields[name]}
+		}
+		values := make([]any, len(given))
+		for i, value := range given {
+			switch v := value.(type) {
+			case nil, string, bool, json.Number:
+				values[i] = v
+			case int:
+				values[i] = json.Number(strconv.Itoa(v))
+			case int64:
+				values[i] = json.Number(strconv.FormatInt(v, 10))
+			case float64:
+				values[i] = json.Number(strconv.FormatFloat(v, 'g', -1, 64))
+			default:
+				return nil, fmt.Errorf("field %s must equal a string, number, boolean or null, or a list of them", name)
+			}
+		}
+		match = append(match, func(rec *Record) bool {
+			return slices.ContainsFunc(values, func(value any) bool { return fieldEquals(rec, name, value) })
+		})
+	}
+	return match, nil
+}
+
+// fieldEquals reports whether a record's top-level field holds value. Numbers are compared by
+// value, so 5 matches 5.0, and a missing field only matches null.
+func fieldEquals(rec *Record, name string, value any) bool {
+	raw, ok := rec.Get(name)
+	switch value := value.(type) {
+	case nil:
+		return !ok || string(raw) == "null"
+	case string:
+		got, ok := rec.GetString(name)
+		return ok && got == value
+	case bool:
+		return ok && string(raw) == fmt.Sprint(value)
+	case json.Number:
+		if !ok || len(raw) == 0 || raw[0] == '"' {
+			return false
+		}
+		want, err := value.Float64()
+		if err != nil {
+			return false
+		}
+		got, err := json.Number(raw).Float64()
+		return err == nil && got == want
+	}
+	return false
+}
+
+// route appends the outputs of a record to outputs, each once: those of every route it meets
+// up to the first stopping one, or the default when it meets none
+func (r *router) route(rec *Record, outputs []string) []string {
+	start := len(outputs)
+	for _, rule := range r.rules {
+		if !matchesAll(rule.match, rec) {
+			continue
+		}
+		if !slices.Contains(outputs[start:], rule.to) {
+			outputs = append(outputs, rule.to)
+		}
+		if rule.stop {
+			break
+		}
+	}
+	if len(outputs) == start && r.def != "" {
+		outputs = append(outputs, r.def)
+	}
+	return outputs
+}
+
+// dirs returns the dirs function of a partitionWriter writing each record to its outputs, in
+// the partition of its columns within each, and counting the records of each output in routed
+func (r *router) dirs(columns []string, routed map[string]int64) func(rec *Record, dirs []string) []string {
+	var partition string
+	return func(rec *Record, dirs []string) []string {
+		start := len(dirs)
+		dirs = r.route(rec, dirs)
+		if len(dirs) > start && len(columns) > 0 {
+			partition = partitionPath(rec, columns)
+		}
+		for i := start; i < len(dirs); i++ {
+			routed[dirs[i]]++
+			if len(columns) > 0 {
+				dirs[i] = filepath.Join(dirs[i], partition)
+			}
+		}
+		return dirs
+	}
+}
+
+// matchesAll reports whether a record passes every test
+func matchesAll(match []func(rec *Record) bool, rec *Record) bool {
+	for _, m := range match {
+		if !m(rec) {
+			return false
+		}
+	}
+	return true
+}
+
+// RouteStats counts the records written to an output of a routed run
+type RouteStats struct {
+	Output  string `json:"output"`
+	Records int64  `json:"records"`
+}
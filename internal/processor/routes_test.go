@@ -0,0 +1,149 @@
+package processor
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestRouteCondition(t *testing.T) {
+	minScore, maxScore := int64(10), int64(100)
+	tests := []struct {
+		name   string
+		when   RouteCondition
+		record string
+		want   bool
+	}{
+		{"no condition", RouteCondition{}, `{}`, true},
+		{"subreddit", RouteCondition{Subreddits: []string{"golang"}}, `{"subreddit":"GoLang"}`, true},
+		{"subreddit pattern", RouteCondition{Subreddits: []string{"ask*"}}, `{"subreddit":"golang"}`, false},
+		{"author", RouteCondition{Authors: []string{"u/Spez"}}, `{"author":"spez"}`, true},
+		{"other author", RouteCondition{Authors: []string{"spez"}}, `{"author":"bob"}`, false},
+		{"within scores", RouteCondition{MinScore: &minScore, MaxScore: &maxScore}, `{"score":100}`, true},
+		{"below min score", RouteCondition{MinScore: &minScore}, `{"score":9}`, false},
+		{"above max score", RouteCondition{MaxScore: &maxScore}, `{"score":101}`, false},
+		{"no score", RouteCondition{MaxScore: &maxScore}, `{"ups":1}`, false},
+		{"string field", RouteCondition{Fields: map[string]any{"domain": "i.redd.it"}}, `{"domain":"i.redd.it"}`, true},
+		{"string field of a number", RouteCondition{Fields: map[string]any{"gilded": "1"}}, `{"gilded":1}`, false},
+		{"number field", RouteCondition{Fields: map[string]any{"gilded": 1}}, `{"gilded":1.0}`, true},
+		{"number field of a string", RouteCondition{Fields: map[string]any{"gilded": 1}}, `{"gilded":"1"}`, false},
+		{"float field", RouteCondition{Fields: map[string]any{"ratio": 0.5}}, `{"ratio":5e-1}`, true},
+		{"boolean field", RouteCondition{Fields: map[string]any{"over_18": true}}, `{"over_18":true}`, true},
+		{"boolean field mismatch", RouteCondition{Fields: map[string]any{"over_18": true}}, `{"over_18":false}`, false},
+		{"null field", RouteCondition{Fields: map[string]any{"removed_by": nil}}, `{"removed_by":null}`, true},
+		{"null field missing", RouteCondition{Fields: map[string]any{"removed_by": nil}}, `{}`, true},
+		{"null field set", RouteCondition{Fields: map[string]any{"removed_by": nil}}, `{"removed_by":"mod"}`, false},
+		{"field missing", RouteCondition{Fields: map[string]any{"domain": "i.redd.it"}}, `{}`, false},
+		{"any of a list", RouteCondition{Fields: map[string]any{"domain": []any{"i.redd.it", "v.redd.it"}}}, `{"domain":"v.redd.it"}`, true},
+		{"none of a list", RouteCondition{Fields: map[string]any{"domain": []any{"i.redd.it", "v.redd.it"}}}, `{"domain":"imgur.com"}`, false},
+		{
+			"every part",
+			RouteCondition{Subreddits: []string{"pics"}, MinScore: &minScore, Fields: map[string]any{"over_18": false}},
+			`{"subreddit":"pics","score":50,"over_18":false}`,
+			true,
+		},
+		{
+			"one part failing",
+			RouteCondition{Subreddits: []string{"pics"}, MinScore: &minScore, Fields: map[string]any{"over_18": false}},
+			`{"subreddit":"pics","score":5,"over_18":false}`,
+			false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			match, err := tt.when.compile()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := matchesAll(match, NewRecord([]byte(tt.record))); got != tt.want {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRouter(t *testing.T) {
+	config := &RoutingConfig{
+		Routes: []Route{
+			{To: "nsfw", When: RouteCondition{Fields: map[string]any{"over_18": true}}, Stop: true},
+			{To: "pics", When: RouteCondition{Subreddits: []string{"pics", "aww"}}},
+			{To: "popular", When: RouteCondition{Fields: map[string]any{"stickied": true}}},
+			{To: "pics", When: RouteCondition{Subreddits: []string{"aww"}}},
+		},
+		Default: "rest",
+	}
+	r, err := newRouter(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		record string
+		want   []string
+	}{
+		{`{"over_18":true,"subreddit":"pics"}`, []string{"nsfw"}},
+		{`{"subreddit":"pics"}`, []string{"pics"}},
+		{`{"subreddit":"aww","stickied":true}`, []string{"pics", "popular"}},
+		{`{"subreddit":"golang","stickied":true}`, []string{"popular"}},
+		{`{"subreddit":"golang"}`, []string{"rest"}},
+	}
+	for _, tt := range tests {
+		if got := r.route(NewRecord([]byte(tt.record)), nil); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: got %v, want %v", tt.record, got, tt.want)
+		}
+	}
+	if got, want := config.Outputs(), []string{"nsfw", "pics", "popular", "rest"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got outputs %v, want %v", got, want)
+	}
+
+	config.Default = ""
+	if r, err = newRouter(config); err != nil {
+		t.Fatal(err)
+	}
+	if got := r.route(NewRecord([]byte(`{"subreddit":"golang"}`)), nil); len(got) != 0 {
+		t.Errorf("got %v without a default, want no outputs", got)
+	}
+}
+
+func TestLoadRoutingConfig(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  string
+		wantErr string
+	}{
+		{"valid", `{"routes":[{"to":"big","when":{"min_score":100,"fields":{"gilded":1}}}],"default":"small"}`, ""},
+		{"no routes", `{"routes":[]}`, "no routes given"},
+		{"unknown field", `{"routes":[{"to":"a","when":{"score":1}}]}`, "unknown field"},
+		{"empty output", `{"routes":[{"to":""}]}`, "route 1: an output name is empty"},
+		{"nested output", `{"routes":[{"to":"a/b"}]}`, "directly under the output path"},
+		{"parent output", `{"routes":[{"to":".."}]}`, "directly under the output path"},
+		{"hidden output", `{"routes":[{"to":".a"}]}`, "can't start with a dot"},
+		{"partition-like output", `{"routes":[{"to":"year=2020"}]}`, "can't contain '='"},
+		{"bad default", `{"routes":[{"to":"a"}],"default":"b/c"}`, "default:"},
+		{"inverted scores", `{"routes":[{"to":"a","when":{"min_score":5,"max_score":1}}]}`, "min_score 5 is above max_score 1"},
+		{"object field value", `{"routes":[{"to":"a","when":{"fields":{"media":{"a":1}}}}]}`, "field media must equal"},
+		{"bad subreddit pattern", `{"routes":[{"to":"a","when":{"subreddits":["/(/"]}}]}`, "route 1:"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "routes.json")
+			if err := os.WriteFile(path, []byte(tt.config), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			config, err := LoadRoutingConfig(path)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatal(err)
+				}
+				if config.Source != path {
+					t.Errorf("got source %s, want %s", config.Source, path)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("got error %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
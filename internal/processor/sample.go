@@ -0,0 +1,146 @@
+package processor
+
+import (
+	"bufio"
+	"bytes"
+	"cmp"
+	"encoding/json"
+	"fmt"
+	"math/rand/v2"
+	"os"
+	"slices"
+)
+
+// droppedRecord is a sampled record as written to the dropped sample file
+type droppedRecord struct {
+	Filter string `json:"filter"`
+	// Transform is set when a transform rather than a filter dropped the record
+	Transform bool            `json:"transform,omitempty"`
+	Line      int64           `json:"line"`
+	Record    json.RawMessage `json:"record"`
+	// Withheld is set when the record is left out because the redaction policy couldn't be
+	// applied to it
+	Withheld bool `json:"withheld,omitempty"`
+}
+
+// droppedSampler keeps a uniform sample of up to size records dropped by each filter and
+// transform, replacing earlier ones at random as more are dropped (reservoir sampling). It is
+// only used by the transform stage.
+type droppedSampler struct {
+	size    int
+	filters []FilterStats
+	// samples and dropped hold the sample and the number of drops of each filter, in the order
+	// of filters
+	samples [][]droppedRecord
+	dropped []int64
+	// excluded marks the filters whose records are never sampled, such as the deletion lists
+	excluded []bool
+	// redaction is the run's redaction policy, applied to the records dropped before its
+	// transform at index redactionAt
+	redaction   *RedactionTransform
+	redactionAt int
+	rng         *rand.Rand
+}
+
+// newDroppedSampler samples up to size records for each of filters
+func newDroppedSampler(size int, filters []FilterStats) *droppedSampler {
+	return &droppedSampler{
+		size:     size,
+		filters:  filters,
+		samples:  make([][]droppedRecord, len(filters)),
+		dropped:  make([]int64, len(filters)),
+		excluded: make([]bool, len(filters)),
+		rng:      rand.New(rand.NewPCG(rand.Uint64(), rand.Uint64())),
+	}
+}
+
+// add offers a record dropped by filter i to its sample
+func (d *droppedSampler) add(i int, rec *Record) {
+	if d.excluded[i] {
+		return
+	}
+	d.dropped[i]++
+	slot := len(d.samples[i])
+	if slot >= d.size {
+		if slot = int(d.rng.Int64N(d.dropped[i])); slot >= d.size {
+			return
+		}
+	}
+	sampled := droppedRecord{Filter: d.filters[i].Name, Transform: d.filters[i].Transform, Line: rec.line}
+	if line, ok := d.redaction.sideOutput(d.redactionAt, i, rec.Bytes()); ok {
+		sampled.Record = sampleJSON(line)
+	} else {
+		sampled.Withheld = true
+	}
+	if slot == len(d.samples[i]) {
+		d.samples[i] = append(d.samples[i], sampled)
+	} else {
+		d.samples[i][slot] = sampled
+	}
+}
+
+// sampleJSON copies a record for the sample, quoting lines that aren't valid JSON so the sample
+// file stays readable
+func sampleJSON(line []byte) json.RawMessage {
+	if json.Valid(line) {
+		return bytes.Clone(line)
+	}
+	quoted, _ := json.Marshal(string(line))
+	return quoted
+}
+
+// write writes the samples to path as JSON lines, grouped by filter and in input order within
+// each group, and returns how many records it wrote
+func (d *droppedSampler) write(path string) (int, error) {
+	file, err := os.Create(path)
+	if err != nil {
+		return 0, fmt.Errorf("failed to create the dropped record sample: %v", err)
+	}
+	defer file.Close()
+	w := bufio.NewWriter(file)
+	written := 0
+	for _, sample := range d.samples {
+		slices.SortFunc(sample, func(a, b droppedRecord) int { return cmp.Compare(a.Line, b.Line) })
+		for _, rec := range sample {
+			line, err := json.Marshal(rec)
+			if err != nil {
+				return written, fmt.Errorf("failed to encode a dropped record: %v", err)
+			}
+			w.Write(line)
+			w.WriteByte('\n')
+			written++
+		}
+	}
+	if err := w.Flush(); err != nil {
+		return written, fmt.Errorf("failed to write the dropped record sample: %v", err)
+	}
+	return written, file.Close()
+}
+
+// droppedSampler returns the sampler of the records filters drop, as configured by
+// Options.DroppedSample, and the function writing the sample out once the stages are done. Both
+// are nil without a sample size or without filters.
+func (s *PushshiftProcessor) droppedSampler(filters []FilterStats) (*droppedSampler, func()) {
+	if s.Options.DroppedSample <= 0 {
+		return nil, nil
+	}
+	if len(filters) == 0 {
+		warnf(s.logger(), "⚠️ Warning: The run has no filters or transforms dropping records, so no dropped records are sampled")
+		return nil, nil
+	}
+	sampler := newDroppedSampler(s.Options.DroppedSample, filters)
+	// Records removed to honor deletion requests must not be kept anywhere
+	for i, f := range slices.Concat(s.Options.Filters, s.Options.Transforms) {
+		_, sampler.excluded[i] = f.(*DeletionFilter)
+	}
+	sampler.redaction, sampler.redactionAt = s.redaction()
+	logger := s.logger()
+	return sampler, func() {
+		written, err := sampler.write(s.Options.DroppedSamplePath)
+		if err != nil {
+			warnf(logger, "⚠️ Warning: %v", err)
+			return
+		}
+		logger.Printf("🧪 Wrote a sample of %d dropped records to %s", written, s.Options.DroppedSamplePath)
+	}
+}
@@ -0,0 +1,263 @@
+package processor
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+)
+
+// schemaMaxFields is how many distinct keys an object may have before it is reported as a map,
+// as objects keyed by ids (media_metadata) would otherwise list every id of the dump
+const schemaMaxFields = 200
+
+// JSON types reported in schemas
+const (
+	schemaString  = "string"
+	schemaInteger = "integer"
+	schemaNumber  = "number"
+	schemaBoolean = "boolean"
+	schemaNull    = "null"
+	schemaObject  = "object"
+	schemaArray   = "array"
+)
+
+// SchemaField describes the values seen for a field: their JSON types and, for objects and
+// arrays, what they contain
+type SchemaField struct {
+	Types []string `json:"types"`
+	// Optional is set when some objects holding the field lacked it
+	Optional bool `json:"optional,omitempty"`
+	// Fields describes the fields of object values
+	Fields map[string]*SchemaField `json:"fields,omitempty"`
+	// Values describes the values of objects with too many distinct keys to list, such as maps
+	// keyed by ids
+	Values *SchemaField `json:"values,omitempty"`
+	// Items describes the elements of array values
+	Items *SchemaField `json:"items,omitempty"`
+}
+
+// Schema is the inferred schema of the records of a run
+type Schema struct {
+	Fields map[string]*SchemaField `json:"fields"`
+}
+
+// schemaNode accumulates the values seen at one position of the records
+type schemaNode struct {
+	types map[string]bool
+	// present counts the values seen, and objects the object values among them
+	present int64
+	objects int64
+	fields  map[string]*schemaNode
+	values  *schemaNode
+	items   *schemaNode
+	// root lists every field however many there are: the record's own fields
+	root bool
+}
+
+// newSchemaNode creates an empty node
+func newSchemaNode() *schemaNode {
+	return &schemaNode{types: make(map[string]bool)}
+}
+
+// observe adds a raw JSON value
+func (n *schemaNode) observe(raw []byte) {
+	n.present++
+	if len(raw) == 0 {
+		return
+	}
+	typ := jsonType(raw)
+	n.types[typ] = true
+	switch typ {
+	case schemaObject:
+		n.objects++
+		forEachField(raw, func(name, value []byte) bool {
+			n.child(fieldName(name)).observe(value)
+			return true
+		})
+	case schemaArray:
+		forEachElement(raw, func(value []byte) {
+			if n.items == nil {
+				n.items = newSchemaNode()
+			}
+			n.items.observe(value)
+		})
+	}
+}
+
+// jsonType returns the schema type of a non-empty raw JSON value
+func jsonType(raw []byte) string {
+	switch raw[0] {
+	case '"':
+		return schemaString
+	case 't', 'f':
+		return schemaBoolean
+	case 'n':
+		return schemaNull
+	case '{':
+		return schemaObject
+	case '[':
+		return schemaArray
+	}
+	if bytes.ContainsAny(raw, ".eE") {
+		return schemaNumber
+	}
+	return schemaInteger
+}
+
+// child returns the node of an object field, folding all fields into values once there are too
+// many to list
+func (n *schemaNode) child(name string) *schemaNode {
+	if n.values != nil {
+		return n.values
+	}
+	if n.fields == nil {
+		n.fields = make(map[string]*schemaNode)
+	}
+	if child, ok := n.fields[name]; ok {
+		return child
+	}
+	if len(n.fields) < schemaMaxFields || n.root {
+		child := newSchemaNode()
+		n.fields[name] = child
+		return child
+	}
+	n.foldFields()
+	return n.values
+}
+
+// foldFields merges the fields listed so far into values
+func (n *schemaNode) foldFields() {
+	n.values = newSchemaNode()
+	for _, child := range n.fields {
+		n.values.merge(child)
+	}
+	n.fields = nil
+}
+
+// merge adds the values seen by another node
+func (n *schemaNode) merge(other *schemaNode) {
+	for t := range other.types {
+		n.types[t] = true
+	}
+	n.present += other.present
+	n.objects += other.objects
+	for name, child := range other.fields {
+		n.child(name).merge(child)
+	}
+	if other.values != nil {
+		if n.values == nil {
+			n.foldFields()
+		}
+		n.values.merge(other.values)
+	}
+	if other.items != nil {
+		if n.items == nil {
+			n.items = newSchemaNode()
+		}
+		n.items.merge(other.items)
+	}
+}
+
+// field returns the description of the node, optional when it was seen in fewer objects than
+// its parent
+func (n *schemaNode) field(parentObjects int64) *SchemaField {
+	f := &SchemaField{Optional: n.present < parentObjects}
+	for t := range n.types {
+		f.Types = append(f.Types, t)
+	}
+	sort.Strings(f.Types)
+	if len(n.fields) > 0 {
+		f.Fields = make(map[string]*SchemaField, len(n.fields))
+		for name, child := range n.fields {
+			f.Fields[name] = child.field(n.objects)
+		}
+	}
+	if n.values != nil {
+		f.Values = n.values.field(0)
+	}
+	if n.items != nil {
+		f.Items = n.items.field(0)
+	}
+	return f
+}
+
+// fieldName decodes a raw JSON object key
+func fieldName(raw []byte) string {
+	name := string(raw)
+	if bytes.IndexByte(raw, '\\') >= 0 {
+		json.Unmarshal(append(append([]byte{'"'}, raw...), '"'), &name)
+	}
+	return name
+}
+
+// forEachElement calls fn with each raw element of a JSON array
+func forEachElement(raw []byte, fn func(value []byte)) {
+	i := skipSpace(raw, 1)
+	for i < len(raw) && raw[i] != ']' {
+		end := skipValue(raw, i)
+		if end < 0 || end == i {
+			return
+		}
+		fn(raw[i:end])
+		i = skipSpace(raw, end)
+		if i < len(raw) && raw[i] == ',' {
+			i = skipSpace(raw, i+1)
+		}
+	}
+}
+
+// SchemaReport infers the schema of the records written, every field with its JSON types down
+// to nested objects and arrays, and saves it when closed as an indented JSON document with sorted
+// keys, so schemas of different dumps can be diffed. It never drops records and should be the last
+// transform so it sees the output as written.
+type SchemaReport struct {
+	path string
+
+	mu   sync.Mutex
+	root *schemaNode
+
+	runLogger
+}
+
+// NewSchemaReport creates a schema report written to path
+func NewSchemaReport(path string) *SchemaReport {
+	root := newSchemaNode()
+	root.root = true
+	return &SchemaReport{path: path, root: root}
+}
+
+// Apply adds the record's fields to the schema
+func (r *SchemaReport) Apply(rec *Record) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.root.observe(rec.Bytes())
+	return true, nil
+}
+
+// Schema returns the schema inferred so far
+func (r *SchemaReport) Schema() Schema {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	root := r.root.field(0)
+	if root.Fields == nil {
+		root.Fields = make(map[string]*SchemaField)
+	}
+	return Schema{Fields: root.Fields}
+}
+
+// Close writes the schema file
+func (r *SchemaReport) Close() error {
+	schema := r.Schema()
+	data, err := json.MarshalIndent(schema, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode schema: %v", err)
+	}
+	if err := os.WriteFile(r.path, append(data, '\n'), 0644); err != nil {
+		return fmt.Errorf("failed to write schema: %v", err)
+	}
+	loggerOrDefault(r.logger).Printf("🧬 Wrote the schema of %d top-level fields to %s", len(schema.Fields), r.path)
+	return nil
+}
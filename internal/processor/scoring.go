@@ -0,0 +1,222 @@
+package processor
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+// ScoringOptions configures the external text scoring enrichment
+type ScoringOptions struct {
+	// Endpoint receives POST {"texts": [...]} and returns {"scores": [{"name": value, ...}, ...]}
+	// with one score object per text, in order
+	Endpoint string
+	// TextFields are tried in order to find the text to score (e.g. body, then selftext)
+	TextFields []string
+	// ColumnPrefix is prepended to every returned score name
+	ColumnPrefix string
+	// BatchSize is the number of texts sent per request
+	BatchSize int
+	// Concurrency is the number of requests in flight at once
+	Concurrency int
+	// CacheSize is the number of text hashes whose scores are remembered
+	CacheSize int
+	// Timeout bounds each request
+	Timeout time.Duration
+	// Retries is how often a failed request is retried before the run fails
+	Retries int
+}
+
+// scoringRequest and scoringResponse are the endpoint's wire format
+type scoringRequest struct {
+	Texts []string `json:"texts"`
+}
+
+type scoringResponse struct {
+	Scores []map[string]json.RawMessage `json:"scores"`
+}
+
+// ScoringTransform appends score columns (sentiment, toxicity, ...) from an external HTTP endpoint.
+// Texts are batched, deduplicated and cached by hash so repeated texts (e.g. "[deleted]") are scored once.
+type ScoringTransform struct {
+	opts   ScoringOptions
+	client *http.Client
+	cache  *scoreCache
+}
+
+// NewScoringTransform creates a scoring enrichment, filling in defaults for unset options
+func NewScoringTransform(opts ScoringOptions) *ScoringTransform {
+	if len(opts.TextFields) == 0 {
+		opts.TextFields = []string{"body", "selftext", "title"}
+	}
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = 64
+	}
+	if opts.Concurrency <= 0 {
+		opts.Concurrency = 4
+	}
+	if opts.CacheSize <= 0 {
+		opts.CacheSize = 100000
+	}
+	// Scores of a whole buffered batch must stay cached until they are applied
+	opts.CacheSize = max(opts.CacheSize, opts.BatchSize*opts.Concurrency)
+	if opts.Timeout <= 0 {
+		opts.Timeout = 30 * time.Second
+	}
+	return &ScoringTransform{
+		opts:   opts,
+		client: &http.Client{Timeout: opts.Timeout},
+		cache:  newScoreCache(opts.CacheSize),
+	}
+}
+
+// BatchSize buffers enough records to keep every concurrent request busy
+func (t *ScoringTransform) BatchSize() int {
+	return t.opts.BatchSize * t.opts.Concurrency
+}
+
+// ApplyBatch scores the text of each record and adds the score columns
+func (t *ScoringTransform) ApplyBatch(recs []*Record) ([]bool, error) {
+	keep := make([]bool, len(recs))
+	hashes := make([][32]byte, len(recs))
+	hasText := make([]bool, len(recs))
+
+	// Collect distinct texts that are not cached yet
+	var texts []string
+	var textHashes [][32]byte
+	queued := make(map[[32]byte]bool)
+	for i, rec := range recs {
+		keep[i] = true
+		text, ok := t.text(rec)
+		if !ok {
+			continue
+		}
+		hasText[i] = true
+		hashes[i] = sha256.Sum256([]byte(text))
+		if _, cached := t.cache.get(hashes[i]); cached || queued[hashes[i]] {
+			continue
+		}
+		queued[hashes[i]] = true
+		texts = append(texts, text)
+		textHashes = append(textHashes, hashes[i])
+	}
+
+	if err := t.scoreAll(texts, textHashes); err != nil {
+		return nil, err
+	}
+
+	for i, rec := range recs {
+		if !hasText[i] {
+			continue
+		}
+		scores, ok := t.cache.get(hashes[i])
+		if !ok {
+			continue
+		}
+		names := make([]string, 0, len(scores))
+		for name := range scores {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			if err := rec.SetRaw(t.opts.ColumnPrefix+name, scores[name]); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return keep, nil
+}
+
+// text returns the first non-empty configured text field of a record
+func (t *ScoringTransform) text(rec *Record) (string, bool) {
+	for _, field := range t.opts.TextFields {
+		if text, ok := rec.GetString(field); ok && text != "" {
+			return text, true
+		}
+	}
+	return "", false
+}
+
+// scoreAll sends texts to the endpoint in concurrent batches and caches the results
+func (t *ScoringTransform) scoreAll(texts []string, hashes [][32]byte) error {
+	return forEachBatch(len(texts), t.opts.BatchSize, t.opts.Concurrency, func(start, end int) error {
+		scores, err := t.request(texts[start:end])
+		if err != nil {
+			return err
+		}
+		for i, s := range scores {
+			t.cache.put(hashes[start+i], s)
+		}
+		return nil
+	})
+}
+
+// request scores one batch of texts
+func (t *ScoringTransform) request(texts []string) ([]map[string]json.RawMessage, error) {
+	body, err := json.Marshal(scoringRequest{Texts: texts})
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode scoring request: %v", err)
+	}
+
+	data, err := sendJSON(t.client, http.MethodPost, t.opts.Endpoint, nil, body, t.opts.Retries)
+	if err != nil {
+		return nil, fmt.Errorf("scoring failed: %v", err)
+	}
+
+	var parsed scoringResponse
+	if err := json.Unmarshal(data, &parsed); err != nil {
+		return nil, fmt.Errorf("invalid scoring response: %v", err)
+	}
+	if len(parsed.Scores) != len(texts) {
+		return nil, fmt.Errorf("scoring endpoint returned %d scores for %d texts", len(parsed.Scores), len(texts))
+	}
+	return parsed.Scores, nil
+}
+
+// scoreCache is a bounded LRU cache of scores keyed by text hash
+type scoreCache struct {
+	mu      sync.Mutex
+	limit   int
+	order   *list.List
+	entries map[[32]byte]*list.Element
+}
+
+type scoreEntry struct {
+	hash   [32]byte
+	scores map[string]json.RawMessage
+}
+
+func newScoreCache(limit int) *scoreCache {
+	return &scoreCache{limit: limit, order: list.New(), entries: make(map[[32]byte]*list.Element)}
+}
+
+func (c *scoreCache) get(hash [32]byte) (map[string]json.RawMessage, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if el, ok := c.entries[hash]; ok {
+		c.order.MoveToFront(el)
+		return el.Value.(*scoreEntry).scores, true
+	}
+	return nil, false
+}
+
+func (c *scoreCache) put(hash [32]byte, scores map[string]json.RawMessage) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if el, ok := c.entries[hash]; ok {
+		el.Value.(*scoreEntry).scores = scores
+		c.order.MoveToFront(el)
+		return
+	}
+	c.entries[hash] = c.order.PushFront(&scoreEntry{hash: hash, scores: scores})
+	if c.order.Len() > c.limit {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*scoreEntry).hash)
+	}
+}
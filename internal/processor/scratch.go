@@ -0,0 +1,110 @@
+package processor
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// intermediatePartPattern matches the JSONL part files, compressed or not, that exist only until
+// their conversion
+var intermediatePartPattern = regexp.MustCompile(`^_part_\d+\.jsonl(\.zst)?$`)
+
+// ValidatePartCompressionLevel checks the zstd level of intermediate parts, 0 leaving them
+// uncompressed
+func ValidatePartCompressionLevel(level int) error {
+	if level < 0 || level > 3 {
+		return fmt.Errorf("part compression level %d is out of range, expected 1 to 3 (or 0 to disable)", level)
+	}
+	return nil
+}
+
+// validateSplitOnly checks the options of a run keeping its JSONL parts, whose compression only
+// costs time once rather than on every part
+func (o Options) validateSplitOnly() error {
+	if o.PartCompressionLevel < 0 || o.PartCompressionLevel > 22 {
+		return fmt.Errorf("part compression level %d is out of range, expected 1 to 22 (or 0 to disable)", o.PartCompressionLevel)
+	}
+	if o.TargetParquetSize > 0 {
+		return fmt.Errorf("a target Parquet size does not apply to JSONL parts")
+	}
+	if o.Append {
+		return fmt.Errorf("appending is not supported for JSONL parts")
+	}
+	return nil
+}
+
+// intermediatePartPath returns the JSONL file a part is written to before its conversion, or
+// the JSONL part a split run keeps. Kept parts are named _split_NNN so they are never mistaken for
+// the leftovers of an interrupted conversion.
+func (s *PushshiftProcessor) intermediatePartPath(outputPrefix string, partNum int) string {
+	if s.Options.SplitOnly {
+		return splitPartPath(outputPrefix, partNum, s.Options.PartCompressionLevel > 0)
+	}
+	if s.Options.PartCompressionLevel > 0 {
+		return fmt.Sprintf("%s_part_%03d.jsonl.zst", outputPrefix, partNum)
+	}
+	return fmt.Sprintf("%s_part_%03d.jsonl", outputPrefix, partNum)
+}
+
+// splitPartPath returns the JSONL part a split run keeps as its output
+func splitPartPath(outputPrefix string, partNum int, compressed bool) string {
+	if compressed {
+		return fmt.Sprintf("%s_split_%03d.jsonl.zst", outputPrefix, partNum)
+	}
+	return fmt.Sprintf("%s_split_%03d.jsonl", outputPrefix, partNum)
+}
+
+// sweepOrphanedParts removes intermediate JSONL parts left behind by an earlier run with the same
+// output prefix that was killed before it could clean up. Files the manifest lists as outputs,
+// such as the parts of split runs made before they were named _split_NNN, are kept.
+func sweepOrphanedParts(logger *log.Logger, outputPrefix string) error {
+	matches, err := filepath.Glob(escapeGlob(outputPrefix) + "_part_*.jsonl*")
+	if err != nil {
+		return fmt.Errorf("failed to list intermediate parts: %v", err)
+	}
+	if len(matches) == 0 {
+		return nil
+	}
+	manifest, err := readManifest(outputPrefix)
+	if err != nil {
+		return err
+	}
+	kept := make(map[string]bool)
+	if manifest != nil {
+		for _, part := range slices.Concat(manifest.Parts, manifest.FailedParts) {
+			kept[filepath.Clean(part.Path)] = true
+		}
+	}
+	var removed int
+	var removedBytes int64
+	for _, path := range matches {
+		if !intermediatePartPattern.MatchString(strings.TrimPrefix(path, outputPrefix)) || kept[filepath.Clean(path)] {
+			continue
+		}
+		info, err := os.Stat(path)
+		if err != nil {
+			continue
+		}
+		if err := os.Remove(path); err != nil {
+			return fmt.Errorf("failed to remove orphaned intermediate part %s: %v", path, err)
+		}
+		removed++
+		removedBytes += info.Size()
+	}
+	if removed > 0 {
+		logger.Printf("🧹 Removed %d orphaned intermediate parts (%.2f MB) left by an interrupted run", removed, float64(removedBytes)/1024/1024)
+	}
+	return nil
+}
+
+// removeScratch deletes an intermediate file, warning when it cannot be removed
+func removeScratch(logger *log.Logger, path string) {
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		warnf(logger, "⚠️ Warning: Failed to remove intermediate file %s: %v", path, err)
+	}
+}
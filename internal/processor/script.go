@@ -0,0 +1,396 @@
+package processor
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"math"
+	"os"
+	"runtime"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	lua "github.com/yuin/gopher-lua"
+)
+
+// DefaultScriptBudget is the default time a script may spend on a single record
+const DefaultScriptBudget = 100 * time.Millisecond
+
+// sandboxedGlobals are removed from every interpreter so scripts cannot touch the filesystem
+// or load further code
+var sandboxedGlobals = []string{"dofile", "loadfile", "load", "loadstring", "require", "module", "collectgarbage"}
+
+// ScriptTransform runs per-record Lua functions from a user script.
+//
+// The script may define either or both of:
+//
+//	function filter(record)    -- return false to drop the record
+//	function transform(record) -- return a table to replace the record, nil/false to drop it,
+//	                           -- or true to keep it unchanged
+//
+// record is the decoded JSON object as a Lua table. Interpreters are pooled and sandboxed:
+// only the base, string, table and math libraries are available.
+type ScriptTransform struct {
+	path   string
+	budget time.Duration
+	pool   chan *lua.LState
+}
+
+// LoadScriptTransform compiles a Lua script into a pool of sandboxed interpreters.
+// budget limits the time spent per record; poolSize defaults to GOMAXPROCS.
+func LoadScriptTransform(path string, budget time.Duration, poolSize int) (*ScriptTransform, error) {
+	source, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read script: %v", err)
+	}
+	if budget <= 0 {
+		budget = DefaultScriptBudget
+	}
+	if poolSize <= 0 {
+		poolSize = runtime.GOMAXPROCS(0)
+	}
+
+	t := &ScriptTransform{
+		path:   path,
+		budget: budget,
+		pool:   make(chan *lua.LState, poolSize),
+	}
+	for i := 0; i < poolSize; i++ {
+		L, err := newSandboxedState(string(source), path)
+		if err != nil {
+			t.Close()
+			return nil, err
+		}
+		t.pool <- L
+	}
+	return t, nil
+}
+
+// newSandboxedState creates an interpreter with a restricted standard library and loads the script
+func newSandboxedState(source, name string) (*lua.LState, error) {
+	L := lua.NewState(lua.Options{SkipOpenLibs: true})
+	for _, lib := range []struct {
+		name string
+		open lua.LGFunction
+	}{
+		{lua.BaseLibName, lua.OpenBase},
+		{lua.TabLibName, lua.OpenTable},
+		{lua.StringLibName, lua.OpenString},
+		{lua.MathLibName, lua.OpenMath},
+	} {
+		L.Push(L.NewFunction(lib.open))
+		L.Push(lua.LString(lib.name))
+		L.Call(1, 0)
+	}
+	for _, name := range sandboxedGlobals {
+		L.SetGlobal(name, lua.LNil)
+	}
+
+	fn, err := L.Load(strings.NewReader(source), name)
+	if err != nil {
+		L.Close()
+		return nil, fmt.Errorf("failed to compile script %s: %v", name, err)
+	}
+	L.Push(fn)
+	if err := L.PCall(0, 0, nil); err != nil {
+		L.Close()
+		return nil, fmt.Errorf("failed to run script %s: %v", name, err)
+	}
+
+	if L.GetGlobal("filter") == lua.LNil && L.GetGlobal("transform") == lua.LNil {
+		L.Close()
+		return nil, fmt.Errorf("script %s must define a filter or transform function", name)
+	}
+	return L, nil
+}
+
+// Apply runs the script's filter and transform functions on the record
+func (t *ScriptTransform) Apply(rec *Record) (bool, error) {
+	L := <-t.pool
+	defer func() { t.pool <- L }()
+
+	conv := newScriptRecord(L)
+	table, err := conv.decode(rec.Bytes())
+	if err != nil {
+		return false, fmt.Errorf("record is not a valid JSON object: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), t.budget)
+	defer cancel()
+	L.SetContext(ctx)
+	defer L.RemoveContext()
+
+	if filter := L.GetGlobal("filter"); filter != lua.LNil {
+		result, err := t.call(L, filter, table)
+		if err != nil {
+			return false, err
+		}
+		if !lua.LVAsBool(result) {
+			return false, nil
+		}
+	}
+
+	if transform := L.GetGlobal("transform"); transform != lua.LNil {
+		result, err := t.call(L, transform, table)
+		if err != nil {
+			return false, err
+		}
+		switch result := result.(type) {
+		case *lua.LTable:
+			encoded, err := conv.encode(result)
+			if err != nil {
+				return false, fmt.Errorf("script returned a record that cannot be encoded: %v", err)
+			}
+			rec.Reset(encoded)
+		case lua.LBool:
+			return bool(result), nil
+		default:
+			if result == lua.LNil {
+				return false, nil
+			}
+			return false, fmt.Errorf("script transform returned unsupported %s", result.Type())
+		}
+	}
+	return true, nil
+}
+
+// call invokes a script function with one argument and returns its first result
+func (t *ScriptTransform) call(L *lua.LState, fn lua.LValue, arg lua.LValue) (lua.LValue, error) {
+	if err := L.CallByParam(lua.P{Fn: fn, NRet: 1, Protect: true}, arg); err != nil {
+		if L.Context().Err() == context.DeadlineExceeded {
+			return nil, fmt.Errorf("script %s exceeded its %s per-record budget", t.path, t.budget)
+		}
+		return nil, fmt.Errorf("script %s failed: %v", t.path, err)
+	}
+	result := L.Get(-1)
+	L.Pop(1)
+	return result, nil
+}
+
+// Close shuts down all pooled interpreters
+func (t *ScriptTransform) Close() error {
+	for {
+		select {
+		case L := <-t.pool:
+			L.Close()
+		default:
+			return nil
+		}
+	}
+}
+
+// scriptRecord converts a record between JSON and a Lua table for one call of the script. Lua
+// tables don't tell arrays from objects, don't keep the order of their keys and hold numbers as
+// float64, so it remembers the tables decoded from arrays, the key order of decoded objects and
+// the exact text of integers a float64 rounds, and puts them back when encoding.
+type scriptRecord struct {
+	L      *lua.LState
+	arrays map[*lua.LTable]bool
+	keys   map[*lua.LTable][]string
+	// numbers maps integers beyond the 53 bits a float64 holds exactly to their text, or to ""
+	// when integers differing in their text round to the same number
+	numbers map[lua.LNumber]string
+}
+
+// newScriptRecord returns a converter creating tables in L
+func newScriptRecord(L *lua.LState) *scriptRecord {
+	return &scriptRecord{L: L, arrays: make(map[*lua.LTable]bool), keys: make(map[*lua.LTable][]string), numbers: make(map[lua.LNumber]string)}
+}
+
+// decode converts a JSON object into a Lua table
+func (c *scriptRecord) decode(data []byte) (*lua.LTable, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	value, err := c.decodeValue(dec)
+	if err != nil {
+		return nil, err
+	}
+	table, ok := value.(*lua.LTable)
+	if !ok || c.arrays[table] {
+		return nil, fmt.Errorf("not an object")
+	}
+	return table, nil
+}
+
+// decodeValue converts the next JSON value of dec into a Lua value; null becomes nil
+func (c *scriptRecord) decodeValue(dec *json.Decoder) (lua.LValue, error) {
+	token, err := dec.Token()
+	if err != nil {
+		return nil, err
+	}
+	switch v := token.(type) {
+	case nil:
+		return lua.LNil, nil
+	case bool:
+		return lua.LBool(v), nil
+	case string:
+		return lua.LString(v), nil
+	case json.Number:
+		return c.decodeNumber(v)
+	case json.Delim:
+		table := c.L.NewTable()
+		if v == '[' {
+			c.arrays[table] = true
+			for i := 1; dec.More(); i++ {
+				item, err := c.decodeValue(dec)
+				if err != nil {
+					return nil, err
+				}
+				table.RawSetInt(i, item)
+			}
+		} else {
+			var keys []string
+			for dec.More() {
+				key, err := dec.Token()
+				if err != nil {
+					return nil, err
+				}
+				item, err := c.decodeValue(dec)
+				if err != nil {
+					return nil, err
+				}
+				if table.RawGetString(key.(string)) == lua.LNil {
+					keys = append(keys, key.(string))
+				}
+				table.RawSetString(key.(string), item)
+			}
+			c.keys[table] = keys
+		}
+		// The closing delimiter
+		if _, err := dec.Token(); err != nil {
+			return nil, err
+		}
+		return table, nil
+	}
+	return nil, fmt.Errorf("unexpected %v", token)
+}
+
+// decodeNumber converts a JSON number, remembering the text of integers it may round
+func (c *scriptRecord) decodeNumber(n json.Number) (lua.LValue, error) {
+	if i, err := n.Int64(); err == nil {
+		number := lua.LNumber(i)
+		if i > 1<<53 || i < -1<<53 {
+			if text, seen := c.numbers[number]; seen && text != n.String() {
+				c.numbers[number] = ""
+			} else {
+				c.numbers[number] = n.String()
+			}
+		}
+		return number, nil
+	}
+	f, err := n.Float64()
+	if err != nil {
+		return nil, err
+	}
+	return lua.LNumber(f), nil
+}
+
+// encode converts a table returned by the script into a JSON object
+func (c *scriptRecord) encode(table *lua.LTable) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := c.encodeValue(&buf, table); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// encodeValue appends a Lua value as JSON. Tables decoded from arrays, and tables whose keys are
+// the integers from 1 up, are arrays; other tables are objects, whose decoded keys keep their
+// order and whose added keys follow in sorted order. Values JSON can't hold, such as functions,
+// become null.
+func (c *scriptRecord) encodeValue(buf *bytes.Buffer, value lua.LValue) error {
+	switch v := value.(type) {
+	case lua.LBool:
+		buf.WriteString(strconv.FormatBool(bool(v)))
+	case lua.LNumber:
+		return c.encodeNumber(buf, v)
+	case lua.LString:
+		encoded, err := marshalJSON(string(v))
+		if err != nil {
+			return err
+		}
+		buf.Write(encoded)
+	case *lua.LTable:
+		if n := v.MaxN(); c.arrays[v] || (n > 0 && n == countTableKeys(v)) {
+			buf.WriteByte('[')
+			for i := 1; i <= n; i++ {
+				if i > 1 {
+					buf.WriteByte(',')
+				}
+				if err := c.encodeValue(buf, v.RawGetInt(i)); err != nil {
+					return err
+				}
+			}
+			buf.WriteByte(']')
+			return nil
+		}
+		return c.encodeObject(buf, v)
+	default:
+		buf.WriteString("null")
+	}
+	return nil
+}
+
+// encodeObject appends a table as a JSON object
+func (c *scriptRecord) encodeObject(buf *bytes.Buffer, table *lua.LTable) error {
+	items := make(map[string]lua.LValue)
+	table.ForEach(func(key, item lua.LValue) {
+		items[key.String()] = item
+	})
+	var keys []string
+	for _, key := range c.keys[table] {
+		if _, ok := items[key]; ok {
+			keys = append(keys, key)
+		}
+	}
+	added := slices.DeleteFunc(slices.Sorted(maps.Keys(items)), func(key string) bool {
+		return slices.Contains(keys, key)
+	})
+
+	buf.WriteByte('{')
+	for i, key := range append(keys, added...) {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		encoded, err := marshalJSON(key)
+		if err != nil {
+			return err
+		}
+		buf.Write(encoded)
+		buf.WriteByte(':')
+		if err := c.encodeValue(buf, items[key]); err != nil {
+			return err
+		}
+	}
+	buf.WriteByte('}')
+	return nil
+}
+
+// encodeNumber appends a number, as an integer when it has no fraction
+func (c *scriptRecord) encodeNumber(buf *bytes.Buffer, n lua.LNumber) error {
+	if text := c.numbers[n]; text != "" {
+		buf.WriteString(text)
+		return nil
+	}
+	f := float64(n)
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		return fmt.Errorf("unsupported number %v", f)
+	}
+	if f == math.Trunc(f) && math.Abs(f) < 1<<63 {
+		buf.WriteString(strconv.FormatInt(int64(f), 10))
+		return nil
+	}
+	buf.WriteString(strconv.FormatFloat(f, 'g', -1, 64))
+	return nil
+}
+
+// countTableKeys counts all keys of a Lua table
+func countTableKeys(t *lua.LTable) int {
+	n := 0
+	t.ForEach(func(lua.LValue, lua.LValue) { n++ })
+	return n
+}
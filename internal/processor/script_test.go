@@ -0,0 +1,65 @@
+package processor
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestScriptRoundTrip(t *testing.T) {
+	tests := []struct {
+		name   string
+		script string
+		record string
+		want   string
+	}{
+		{"unchanged", `function transform(r) return r end`,
+			`{"id":"abc","score":5,"ratio":0.25,"stickied":false,"tags":["a","b"],"media":{"type":"image","width":640}}`,
+			`{"id":"abc","score":5,"ratio":0.25,"stickied":false,"tags":["a","b"],"media":{"type":"image","width":640}}`},
+		{"empty arrays and objects", `function transform(r) return r end`,
+			`{"all_awardings":[],"gildings":{},"nested":[[],{}]}`,
+			`{"all_awardings":[],"gildings":{},"nested":[[],{}]}`},
+		{"key order", `function transform(r) return r end`,
+			`{"z":1,"a":2,"m":{"y":1,"b":2}}`,
+			`{"z":1,"a":2,"m":{"y":1,"b":2}}`},
+		{"added and removed keys", `function transform(r) r.a = nil; r.new2 = 1; r.new1 = true; return r end`,
+			`{"z":1,"a":2,"m":3}`,
+			`{"z":1,"m":3,"new1":true,"new2":1}`},
+		{"large integers", `function transform(r) return r end`,
+			`{"id":1234567890123456789,"other":9007199254740993,"neg":-9007199254740993,"small":-7}`,
+			`{"id":1234567890123456789,"other":9007199254740993,"neg":-9007199254740993,"small":-7}`},
+		{"computed integers", `function transform(r) r.score = r.score * 2; r.big = 2^60; return r end`,
+			`{"score":21}`,
+			`{"score":42,"big":1152921504606846976}`},
+		{"arrays built by the script", `function transform(r) r.list = {3, 2, 1}; table.insert(r.tags, "c"); return r end`,
+			`{"tags":["a"]}`,
+			`{"tags":["a","c"],"list":[3,2,1]}`},
+		{"nulls left out", `function transform(r) return r end`,
+			`{"a":null,"b":1}`,
+			`{"b":1}`},
+		{"escaping", `function transform(r) r.html = "<b>&</b>"; return r end`,
+			`{"body":"line\nbreak \"quoted\""}`,
+			`{"body":"line\nbreak \"quoted\"","html":"<b>&</b>"}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "script.lua")
+			if err := os.WriteFile(path, []byte(tt.script), 0644); err != nil {
+				t.Fatal(err)
+			}
+			script, err := LoadScriptTransform(path, 0, 1)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer script.Close()
+			rec := NewRecord([]byte(tt.record))
+			keep, err := script.Apply(rec)
+			if err != nil || !keep {
+				t.Fatalf("got %v, %v", keep, err)
+			}
+			if got := string(rec.Bytes()); got != tt.want {
+				t.Errorf("got %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,180 @@
+package processor
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+// sevenZipCommands are the names the 7-Zip command line tool is installed under, preferred first
+var sevenZipCommands = []string{"7zz", "7z", "7za"}
+
+// sevenZipEntry is a file listed in a 7z archive
+type sevenZipEntry struct {
+	name string
+	size int64
+}
+
+// sevenZipIterator walks the files of a 7z archive. There is no 7z decoder in Go's standard
+// library, so the 7-Zip tool extracts every file to its standard output in archive order, and
+// the stream is cut into members by the sizes the archive lists.
+type sevenZipIterator struct {
+	cmd     *exec.Cmd
+	stdout  io.ReadCloser
+	stderr  bytes.Buffer
+	entries []sevenZipEntry
+	current *io.LimitedReader
+	waited  bool
+}
+
+// findSevenZip returns the path of the 7-Zip command line tool
+func findSevenZip() (string, error) {
+	for _, name := range sevenZipCommands {
+		if path, err := exec.LookPath(name); err == nil {
+			return path, nil
+		}
+	}
+	return "", fmt.Errorf("reading 7z archives needs the 7-Zip command line tool (%s) on the PATH", strings.Join(sevenZipCommands, ", "))
+}
+
+// newSevenZipIterator lists the 7z archive at path and starts extracting it. The archive is read
+// by the tool itself, which also finds the later volumes of an archive split into .001, .002, ...
+func newSevenZipIterator(path string) (*sevenZipIterator, error) {
+	tool, err := findSevenZip()
+	if err != nil {
+		return nil, err
+	}
+	out, err := exec.Command(tool, "l", "-slt", "-ba", "--", path).Output()
+	if err != nil {
+		if exit, ok := err.(*exec.ExitError); ok {
+			return nil, fmt.Errorf("failed to list 7z archive %s: %v: %s", path, err, bytes.TrimSpace(exit.Stderr))
+		}
+		return nil, fmt.Errorf("failed to list 7z archive %s: %v", path, err)
+	}
+	entries, err := parseSevenZipListing(out)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list 7z archive %s: %v", path, err)
+	}
+
+	it := &sevenZipIterator{cmd: exec.Command(tool, "x", "-so", "-bd", "--", path), entries: entries}
+	it.cmd.Stderr = &it.stderr
+	if it.stdout, err = it.cmd.StdoutPipe(); err != nil {
+		return nil, fmt.Errorf("failed to extract 7z archive %s: %v", path, err)
+	}
+	if err := it.cmd.Start(); err != nil {
+		return nil, fmt.Errorf("failed to extract 7z archive %s: %v", path, err)
+	}
+	return it, nil
+}
+
+// parseSevenZipListing reads the files of the technical listing (7z l -slt) of an archive, in
+// archive order, skipping directories
+func parseSevenZipListing(out []byte) ([]sevenZipEntry, error) {
+	// Without -ba support the listing starts with a block describing the archive itself
+	if i := bytes.Index(out, []byte("\n----------\n")); i >= 0 {
+		out = out[i+len("\n----------\n"):]
+	}
+
+	var entries []sevenZipEntry
+	var entry sevenZipEntry
+	var dir, listed bool
+	flush := func() {
+		if listed && !dir {
+			entries = append(entries, entry)
+		}
+		entry, dir, listed = sevenZipEntry{}, false, false
+	}
+	scanner := bufio.NewScanner(bytes.NewReader(out))
+	for scanner.Scan() {
+		key, value, ok := strings.Cut(scanner.Text(), " = ")
+		if !ok {
+			continue
+		}
+		switch key {
+		case "Path":
+			flush()
+			entry.name, listed = value, true
+		case "Size":
+			size, err := strconv.ParseInt(value, 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("bad size of %s: %q", entry.name, value)
+			}
+			entry.size = size
+		case "Folder":
+			dir = dir || value == "+"
+		case "Attributes":
+			dir = dir || strings.HasPrefix(value, "D")
+		}
+	}
+	flush()
+	return entries, nil
+}
+
+// next implements memberIterator
+func (s *sevenZipIterator) next() (string, io.Reader, error) {
+	// Skip what remains of the previous file in the stream
+	if s.current != nil && s.current.N > 0 {
+		if _, err := io.Copy(io.Discard, s.current); err != nil {
+			return "", nil, s.failed(err)
+		}
+	}
+	if len(s.entries) == 0 {
+		if err := s.wait(); err != nil {
+			return "", nil, err
+		}
+		return "", nil, io.EOF
+	}
+	entry := s.entries[0]
+	s.entries = s.entries[1:]
+	s.current = &io.LimitedReader{R: s.stdout, N: entry.size}
+	return entry.name, &sevenZipMember{it: s}, nil
+}
+
+// sevenZipMember reads the current file of the extracted stream, failing if the stream ends early
+type sevenZipMember struct {
+	it *sevenZipIterator
+}
+
+// Read implements io.Reader
+func (m *sevenZipMember) Read(p []byte) (int, error) {
+	n, err := m.it.current.Read(p)
+	if err == io.EOF && m.it.current.N > 0 {
+		return n, m.it.failed(io.ErrUnexpectedEOF)
+	}
+	return n, err
+}
+
+// failed explains a read error with what the tool reported
+func (s *sevenZipIterator) failed(err error) error {
+	if waitErr := s.wait(); waitErr != nil {
+		return waitErr
+	}
+	return fmt.Errorf("failed to extract 7z archive: %w", err)
+}
+
+// wait waits for the tool to exit and returns its failure
+func (s *sevenZipIterator) wait() error {
+	if s.waited {
+		return nil
+	}
+	s.waited = true
+	if err := s.cmd.Wait(); err != nil {
+		return fmt.Errorf("failed to extract 7z archive: %v: %s", err, bytes.TrimSpace(s.stderr.Bytes()))
+	}
+	return nil
+}
+
+// Close implements memberIterator, stopping the tool if the archive wasn't read to its end
+func (s *sevenZipIterator) Close() error {
+	if !s.waited {
+		s.stdout.Close()
+		s.cmd.Process.Kill()
+		s.waited = true
+		s.cmd.Wait()
+	}
+	return nil
+}
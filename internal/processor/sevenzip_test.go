@@ -0,0 +1,93 @@
+package processor
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseSevenZipListing(t *testing.T) {
+	tests := []struct {
+		name    string
+		listing string
+		want    []sevenZipEntry
+		wantErr bool
+	}{
+		{
+			name: "archive block",
+			listing: `7-Zip [64] 16.02 : Copyright (c) 1999-2016 Igor Pavlov : 2016-05-21
+
+Listing archive: dump.7z
+
+--
+Path = dump.7z
+Type = 7z
+Physical Size = 1234
+Headers Size = 200
+
+----------
+Path = data
+Size = 0
+Folder = +
+Attributes = D_ drwxr-xr-x
+
+Path = data/RC_2020-01.ndjson
+Size = 1048576
+Packed Size = 1000
+Folder = -
+Attributes = A_ -rw-r--r--
+
+Path = data/RS_2020-01.ndjson
+Size = 2048
+Folder = -
+Attributes = A_ -rw-r--r--
+`,
+			want: []sevenZipEntry{{"data/RC_2020-01.ndjson", 1048576}, {"data/RS_2020-01.ndjson", 2048}},
+		},
+		{
+			name: "without the archive block",
+			listing: `Path = a.json
+Size = 10
+Attributes = A
+
+Path = dir
+Size = 0
+Attributes = D
+
+Path = b = c.json
+Size = 20
+`,
+			want: []sevenZipEntry{{"a.json", 10}, {"b = c.json", 20}},
+		},
+		{
+			name: "directory flagged by folder only",
+			listing: `Path = dir
+Folder = +
+Attributes = 
+`,
+			want: nil,
+		},
+		{
+			name:    "empty",
+			listing: "",
+			want:    nil,
+		},
+		{
+			name: "bad size",
+			listing: `Path = a.json
+Size = big
+`,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseSevenZipListing([]byte(tt.listing))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("got error %v, want error %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
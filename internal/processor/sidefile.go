@@ -0,0 +1,84 @@
+package processor
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"sync"
+)
+
+// sideFile is a JSONL file next to the main output for records routed out of it, created when the
+// first record arrives
+type sideFile struct {
+	path string
+	// kind names the file in errors, e.g. "quarantine file"
+	kind string
+	// announce is logged with the path when the file is created
+	announce string
+	// redact applies the run's redaction policy to lines written before the policy's transform
+	// runs, see redactSideFiles
+	redact func([]byte) ([]byte, bool)
+	runLogger
+
+	mu     sync.Mutex
+	file   *os.File
+	writer *bufio.Writer
+}
+
+// write appends a record line
+func (f *sideFile) write(line []byte) error {
+	if f.redact != nil {
+		redacted, ok := f.redact(line)
+		if !ok {
+			return fmt.Errorf("failed to apply the redaction policy to a line of the %s", f.kind)
+		}
+		line = redacted
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.writer == nil {
+		file, err := os.Create(f.path)
+		if err != nil {
+			return fmt.Errorf("failed to create %s: %v", f.kind, err)
+		}
+		loggerOrDefault(f.logger).Printf("%s %s", f.announce, f.path)
+		f.file = file
+		f.writer = bufio.NewWriter(file)
+	}
+	if _, err := f.writer.Write(line); err != nil {
+		return fmt.Errorf("failed to write %s: %v", f.kind, err)
+	}
+	return f.writer.WriteByte('\n')
+}
+
+// Close flushes and closes the file if it was created; later calls do nothing
+func (f *sideFile) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.writer == nil {
+		return nil
+	}
+	writer, file := f.writer, f.file
+	f.writer, f.file = nil, nil
+	if err := writer.Flush(); err != nil {
+		file.Close()
+		return fmt.Errorf("failed to flush %s: %v", f.kind, err)
+	}
+	return file.Close()
+}
+
+// transformSideFiles returns the side files and side table files a transform writes
+func transformSideFiles(t Transform) []*sideFile {
+	var files []*sideFile
+	if t, ok := t.(sideFiler); ok {
+		files = t.sideFiles()
+	}
+	if t, ok := t.(SideTableWriter); ok {
+		for _, table := range t.SideTables() {
+			files = append(files, table.side)
+		}
+	}
+	return files
+}
@@ -0,0 +1,121 @@
+package processor
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"sync/atomic"
+	"time"
+)
+
+// SideTable is a secondary table of rows extracted from records, such as the media items of
+// gallery posts, or aggregated over them. Rows are written to <output>_<name>.jsonl while the
+// input is read, or when it ends for aggregates, and converted to <output>_<name>.parquet when
+// the run finishes.
+type SideTable struct {
+	Name string
+	// Columns types columns the conversion could not infer, e.g. ones that may be all null
+	Columns map[string]string
+
+	prefix string
+	side   *sideFile
+	rows   atomic.Int64
+}
+
+// SideTableInfo describes a converted side table in the run statistics and manifest
+type SideTableInfo struct {
+	Name         string `json:"name"`
+	Path         string `json:"path"`
+	Rows         int64  `json:"rows"`
+	ParquetBytes int64  `json:"parquet_bytes"`
+}
+
+// SideTableWriter is implemented by transforms that extract rows into side tables. The
+// processor converts their tables after the last part.
+type SideTableWriter interface {
+	SideTables() []*SideTable
+}
+
+// sideTableFinisher is implemented by side table writers that aggregate over the whole input and
+// write their rows once it has been read
+type sideTableFinisher interface {
+	finishSideTables() error
+}
+
+// sideTableNames lists the side tables transforms can write, so their files count as outputs
+var sideTableNames = []string{"media", "polls", "awards", "threads"}
+
+// NewSideTable creates a side table of an output prefix
+func NewSideTable(outputPrefix, name string, columns map[string]string) *SideTable {
+	return &SideTable{
+		Name:    name,
+		Columns: columns,
+		prefix:  outputPrefix,
+		side:    &sideFile{path: outputPrefix + "_" + name + ".jsonl", kind: name + " table", announce: "🗂️ Writing " + name + " table rows to"},
+	}
+}
+
+// baseName returns the table's output path without the .parquet extension
+func (t *SideTable) baseName() string {
+	return t.prefix + "_" + t.Name
+}
+
+// writeRow appends a row, encoded as a JSON object
+func (t *SideTable) writeRow(row any) error {
+	line, err := json.Marshal(row)
+	if err != nil {
+		return fmt.Errorf("failed to encode %s table row: %v", t.Name, err)
+	}
+	if err := t.side.write(line); err != nil {
+		return err
+	}
+	t.rows.Add(1)
+	return nil
+}
+
+// Close closes the table's intermediate JSONL file and removes it; rows not converted by then
+// are discarded along with the intermediate parts
+func (t *SideTable) Close() error {
+	err := t.side.Close()
+	removeScratch(log.Default(), t.side.path)
+	return err
+}
+
+// convertSideTables converts the side tables written by the transforms to Parquet
+func (s *PushshiftProcessor) convertSideTables(stats *ProcessStats) error {
+	for _, transform := range s.Options.Transforms {
+		writer, ok := transform.(SideTableWriter)
+		if !ok {
+			continue
+		}
+		if finisher, ok := writer.(sideTableFinisher); ok {
+			if err := finisher.finishSideTables(); err != nil {
+				return err
+			}
+		}
+		for _, table := range writer.SideTables() {
+			if err := table.side.Close(); err != nil {
+				return err
+			}
+			rows := table.rows.Load()
+			if rows == 0 {
+				s.logger().Printf("🗂️ No rows for the %s table", table.Name)
+				continue
+			}
+			s.logger().Printf("🔄 Converting the %s table (%d rows) to Parquet format...", table.Name, rows)
+			convertStart := time.Now()
+			if err := s.primaryConverter().convert(table.side.path, table.baseName(), table.Columns); err != nil {
+				return &ErrConversionFailed{Path: table.side.path, Err: err}
+			}
+			stats.Stages.ConvertTime += time.Since(convertStart)
+			removeScratch(s.logger(), table.side.path)
+			info := SideTableInfo{Name: table.Name, Path: table.baseName() + ".parquet", Rows: rows}
+			if fi, err := os.Stat(info.Path); err == nil {
+				info.ParquetBytes = fi.Size()
+			}
+			stats.SideTables = append(stats.SideTables, info)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,380 @@
+package processor
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+
+	"github.com/parquet-go/parquet-go"
+	"github.com/parquet-go/parquet-go/format"
+)
+
+// validateSingleFile reports options that can't be combined with SingleFile
+func (o Options) validateSingleFile() error {
+	if o.SplitOnly {
+		return fmt.Errorf("a single Parquet file can't be written when splitting into JSONL parts")
+	}
+	if o.Append {
+		return fmt.Errorf("a single Parquet file can't be appended to, leave out -append or -single-file")
+	}
+	return nil
+}
+
+// mergeParts rewrites the converted parts of a run as one Parquet file and replaces the parts'
+// statistics with the file's. The file takes the place of the first part, so tools reading part
+// files find it.
+func (s *PushshiftProcessor) mergeParts(stats *ProcessStats, inputPath string) error {
+	if len(stats.Parts) < 2 {
+		return nil
+	}
+	s.logger().Printf("🧱 Merging %d parts into a single Parquet file", len(stats.Parts))
+	merged, err := s.mergePartFiles(stats.Parts, inputPath, stats.InputSHA256)
+	if err != nil {
+		return err
+	}
+	stats.Parts = merged
+	return nil
+}
+
+// mergePartFiles rewrites parts as one Parquet file in place of the first, holding their row
+// groups in order under a schema reconciling theirs, and returns the file's statistics. Parts whose
+// columns have types no single column holds, such as text in one and numbers in another, are
+// kept and returned as they are.
+func (s *PushshiftProcessor) mergePartFiles(parts []PartInfo, inputPath, sha string) ([]PartInfo, error) {
+	files := make([]*parquet.File, len(parts))
+	for i, part := range parts {
+		file, err := os.Open(part.Path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open part %d: %v", part.Number, err)
+		}
+		defer file.Close()
+		info, err := file.Stat()
+		if err != nil {
+			return nil, fmt.Errorf("failed to open part %d: %v", part.Number, err)
+		}
+		if files[i], err = parquet.OpenFile(file, info.Size(), parquet.SkipBloomFilters(true)); err != nil {
+			return nil, fmt.Errorf("failed to read part %d: %v", part.Number, err)
+		}
+	}
+	schema, err := reconcileParquetSchemas(files)
+	if err != nil {
+		// The parts are still a complete output, so the run doesn't fail over their layout
+		warnf(s.logger(), "⚠️ Warning: Keeping the %d parts as they are, they can't be merged into a single file: %v", len(parts), err)
+		return parts, nil
+	}
+
+	merged := PartInfo{
+		Number:    parts[0].Number,
+		Path:      parts[0].Path,
+		FirstLine: parts[0].FirstLine,
+		LastLine:  parts[len(parts)-1].LastLine,
+		Converter: parts[0].Converter,
+	}
+	for _, part := range parts {
+		merged.Lines += part.Lines
+		merged.JSONLBytes += part.JSONLBytes
+		merged.FailedConverters = append(merged.FailedConverters, part.FailedConverters...)
+		if part.Converter != merged.Converter {
+			merged.Converter = ""
+		}
+		for _, column := range part.NestedAsJSON {
+			if !slices.Contains(merged.NestedAsJSON, column) {
+				merged.NestedAsJSON = append(merged.NestedAsJSON, column)
+			}
+		}
+	}
+	sort.Strings(merged.NestedAsJSON)
+
+	codec, err := s.Options.Parquet.nativeCodec()
+	if err != nil {
+		return nil, err
+	}
+	options := []parquet.WriterOption{schema, parquet.Compression(codec)}
+	for key, value := range s.partMetadata(inputPath, sha, merged) {
+		options = append(options, parquet.KeyValueMetadata(key, value))
+	}
+	if len(merged.NestedAsJSON) > 0 {
+		encoded, err := json.Marshal(merged.NestedAsJSON)
+		if err != nil {
+			return nil, err
+		}
+		options = append(options, parquet.KeyValueMetadata(MetadataNestedAsJSON, string(encoded)))
+	}
+	// Written next to the parts and renamed over the first once complete
+	out, err := os.CreateTemp(filepath.Dir(merged.Path), filepath.Base(merged.Path)+".*.tmp")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create the single Parquet file: %v", err)
+	}
+	defer os.Remove(out.Name())
+	defer out.Close()
+	writer := parquet.NewWriter(out, options...)
+	groups := 0
+	for i, file := range files {
+		conv, err := parquet.Convert(schema, file.Schema())
+		if err != nil {
+			return nil, fmt.Errorf("failed to reconcile the schema of part %d: %v", parts[i].Number, err)
+		}
+		for _, rowGroup := range file.RowGroups() {
+			if _, err := writer.WriteRowGroup(parquet.ConvertRowGroup(rowGroup, conv)); err != nil {
+				return nil, fmt.Errorf("failed to copy part %d into the single Parquet file: %v", parts[i].Number, err)
+			}
+			groups++
+		}
+	}
+	if err := writer.Close(); err != nil {
+		return nil, fmt.Errorf("failed to finish the single Parquet file: %v", err)
+	}
+	if err := out.Close(); err != nil {
+		return nil, fmt.Errorf("failed to write the single Parquet file: %v", err)
+	}
+	info, err := os.Stat(out.Name())
+	if err != nil {
+		return nil, fmt.Errorf("failed to write the single Parquet file: %v", err)
+	}
+	merged.ParquetBytes = info.Size()
+	if err := os.Rename(out.Name(), merged.Path); err != nil {
+		return nil, fmt.Errorf("failed to replace part %d with the single Parquet file: %v", merged.Number, err)
+	}
+	for _, part := range parts[1:] {
+		if err := os.Remove(part.Path); err != nil {
+			return nil, fmt.Errorf("failed to remove merged part %d: %v", part.Number, err)
+		}
+	}
+	s.logger().Printf("✅ Wrote %d lines in %d row groups to %s (%.2f MB)", merged.Lines, groups, merged.Path, float64(merged.ParquetBytes)/1024/1024)
+	return []PartInfo{merged}, nil
+}
+
+// reconcileParquetSchemas returns a schema the rows of every file convert to: the union of their
+// columns, with integers widened to BIGINT or DOUBLE and strings to JSON where the files disagree,
+// as ReconcileSchemas does for sources. Columns a file only holds nulls in don't constrain the
+// type, so parts converted before a field first had a value don't conflict with later ones.
+func reconcileParquetSchemas(files []*parquet.File) (*parquet.Schema, error) {
+	columns := make(map[string]parquet.Node)
+	var order []string
+	for _, file := range files {
+		nulls := nullColumns(file)
+		for _, field := range file.Schema().Fields() {
+			name := field.Name()
+			previous, seen := columns[name]
+			if !seen {
+				order = append(order, name)
+			}
+			if nulls[name] && field.Leaf() {
+				if !seen {
+					columns[name] = nil
+				}
+				continue
+			}
+			if previous == nil {
+				columns[name] = field
+				continue
+			}
+			node, err := mergeParquetNodes(previous, field)
+			if err != nil {
+				return nil, fmt.Errorf("column %s can't be merged across parts: %v", name, err)
+			}
+			columns[name] = node
+		}
+	}
+	group := make(parquet.Group, len(order))
+	for _, name := range order {
+		node := columns[name]
+		if node == nil {
+			node = parquet.String()
+		}
+		if !node.Repeated() {
+			// Columns some parts lack are null in their rows
+			node = parquet.Optional(node)
+		}
+		group[name] = node
+	}
+	return parquet.NewSchema("schema", group), nil
+}
+
+// nullColumns returns the top-level leaf columns of a file that hold no values but nulls
+func nullColumns(file *parquet.File) map[string]bool {
+	nulls := make(map[string]bool)
+	leaves := file.Schema().Columns()
+	for i, path := range leaves {
+		if len(path) != 1 {
+			continue
+		}
+		allNull := true
+		for _, rowGroup := range file.Metadata().RowGroups {
+			chunk := rowGroup.Columns[i].MetaData
+			if chunk.Statistics.NullCount != chunk.NumValues {
+				allNull = false
+				break
+			}
+		}
+		nulls[path[0]] = allNull
+	}
+	return nulls
+}
+
+// mergeParquetNodes returns a node holding the values of two columns of the same name
+func mergeParquetNodes(a, b parquet.Node) (parquet.Node, error) {
+	if parquet.EqualNodes(a, b) {
+		return a, nil
+	}
+	if a.Repeated() != b.Repeated() {
+		return nil, fmt.Errorf("repeated in one part only")
+	}
+	var merged parquet.Node
+	switch {
+	case a.Leaf() && b.Leaf():
+		typ, err := mergeLeafTypes(a.Type(), b.Type())
+		if err != nil {
+			return nil, err
+		}
+		merged = parquet.Leaf(typ)
+	case a.Leaf() != b.Leaf():
+		return nil, fmt.Errorf("nested in one part and not in another")
+	case isListNode(a) && isListNode(b):
+		element, err := mergeParquetNodes(listElement(a), listElement(b))
+		if err != nil {
+			return nil, err
+		}
+		merged = parquet.List(element)
+	default:
+		if logicalValue(a.Type()) != nil || logicalValue(b.Type()) != nil {
+			return nil, fmt.Errorf("nested types %s and %s differ", a.Type(), b.Type())
+		}
+		fields := make(map[string]parquet.Node)
+		for _, field := range a.Fields() {
+			fields[field.Name()] = field
+		}
+		for _, field := range b.Fields() {
+			previous, ok := fields[field.Name()]
+			if !ok {
+				fields[field.Name()] = field
+				continue
+			}
+			node, err := mergeParquetNodes(previous, field)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %v", field.Name(), err)
+			}
+			fields[field.Name()] = node
+		}
+		group := make(parquet.Group, len(fields))
+		for name, node := range fields {
+			if !node.Repeated() {
+				node = parquet.Optional(node)
+			}
+			group[name] = node
+		}
+		merged = group
+	}
+	switch {
+	case a.Repeated():
+		return parquet.Repeated(merged), nil
+	case a.Optional() || b.Optional():
+		return parquet.Optional(merged), nil
+	}
+	return parquet.Required(merged), nil
+}
+
+// mergeLeafTypes widens two leaf types as mergeColumnTypes widens DuckDB types: integers to the
+// wider integer or to DOUBLE, numbers and text to text, and strings to JSON when either is JSON
+func mergeLeafTypes(a, b parquet.Type) (parquet.Type, error) {
+	numeric := map[parquet.Kind]int{parquet.Int32: 1, parquet.Int64: 2, parquet.Float: 3, parquet.Double: 3}
+	plainNumber := func(t parquet.Type) bool {
+		switch logicalValue(t).(type) {
+		case nil, *format.IntType:
+			return numeric[t.Kind()] > 0
+		}
+		return false
+	}
+	switch {
+	case plainNumber(a) && plainNumber(b):
+		switch max(numeric[a.Kind()], numeric[b.Kind()]) {
+		case 3:
+			return parquet.DoubleType, nil
+		case 2:
+			return parquet.Int(64).Type(), nil
+		}
+		return parquet.Int(32).Type(), nil
+	case plainNumber(a) && b.Kind() == parquet.ByteArray && isTextType(b),
+		plainNumber(b) && a.Kind() == parquet.ByteArray && isTextType(a):
+		return parquet.String().Type(), nil
+	case a.Kind() == parquet.ByteArray && b.Kind() == parquet.ByteArray:
+		if isJSONType(a) || isJSONType(b) {
+			return parquet.JSON().Type(), nil
+		}
+		if isStringType(a) && isStringType(b) {
+			return parquet.String().Type(), nil
+		}
+	}
+	return nil, fmt.Errorf("types %s and %s differ", a, b)
+}
+
+// logicalValue returns the logical type annotation of a type, such as *format.StringType, nil
+// when it has none
+func logicalValue(t parquet.Type) any {
+	return logicalTypeValue(t.LogicalType())
+}
+
+// logicalTypeValue returns the annotation of a logical type union the processor tells apart, the
+// union itself for other annotations and nil when none is set
+func logicalTypeValue(lt *format.LogicalType) any {
+	switch {
+	case lt == nil:
+		return nil
+	case lt.UTF8 != nil:
+		return lt.UTF8
+	case lt.Json != nil:
+		return lt.Json
+	case lt.List != nil:
+		return lt.List
+	case lt.Timestamp != nil:
+		return lt.Timestamp
+	case lt.Date != nil:
+		return lt.Date
+	case lt.Decimal != nil:
+		return lt.Decimal
+	case lt.Integer != nil:
+		return lt.Integer
+	}
+	return lt
+}
+
+// isJSONType reports whether a leaf type is annotated as JSON
+func isJSONType(t parquet.Type) bool {
+	_, ok := logicalValue(t).(*format.JsonType)
+	return ok
+}
+
+// isTextType reports whether a byte array type holds plain or STRING text
+func isTextType(t parquet.Type) bool {
+	switch logicalValue(t).(type) {
+	case nil, *format.StringType:
+		return true
+	}
+	return false
+}
+
+// isStringType reports whether a byte array type holds text: plain, STRING or JSON
+func isStringType(t parquet.Type) bool {
+	switch logicalValue(t).(type) {
+	case nil, *format.StringType, *format.JsonType:
+		return true
+	}
+	return false
+}
+
+// isListNode reports whether a node is a LIST of the standard three-level layout
+func isListNode(node parquet.Node) bool {
+	if _, ok := logicalValue(node.Type()).(*format.ListType); !ok {
+		return false
+	}
+	fields := node.Fields()
+	return len(fields) == 1 && fields[0].Repeated() && !fields[0].Leaf() && len(fields[0].Fields()) == 1
+}
+
+// listElement returns the element node of a LIST of the standard layout
+func listElement(node parquet.Node) parquet.Node {
+	return node.Fields()[0].Fields()[0]
+}
@@ -0,0 +1,160 @@
+package processor
+
+import (
+	"fmt"
+	"io"
+	"time"
+)
+
+// sinkBatchSize is the number of records handed to a sink per write when no batch transform
+// requests a larger buffer
+const sinkBatchSize = 500
+
+// Sink receives processed records instead of the JSONL part files and Parquet conversion,
+// for outputs such as vector databases
+type Sink interface {
+	// WriteBatch stores a batch of records
+	WriteBatch(recs []*Record) error
+	// Close flushes and releases the sink
+	Close() error
+}
+
+// processToSink streams the input through the transforms into the configured sink
+func (s *PushshiftProcessor) processToSink(inputPath string) (ProcessStats, error) {
+	start := time.Now()
+	stats := s.newStats()
+
+	if s.Options.Resume {
+		return stats, fmt.Errorf("resuming is only supported for Parquet output, sinks keep their state in memory")
+	}
+	if err := ValidateBadRecords(s.Options.BadRecords, s.Options.BadRecordsPath); err != nil {
+		return stats, err
+	}
+	if err := ValidateOnBadLine(s.Options.OnBadLine, s.Options.BadLinesPath); err != nil {
+		return stats, err
+	}
+	s.logger().Printf("📖 Reading zst file into sink: %s", inputPath)
+	stopWatchdog := s.startWatchdog()
+	defer stopWatchdog()
+	ctl := s.Options.Control
+
+	in, err := s.openInput(inputPath)
+	if err != nil {
+		return stats, err
+	}
+	defer in.Close()
+
+	// Close the sink on error paths too, so it removes its staging files
+	closed := false
+	defer func() {
+		if !closed {
+			if err := s.Options.Sink.Close(); err != nil {
+				warnf(s.logger(), "⚠️ Warning: Failed to close sink: %v", err)
+			}
+		}
+	}()
+
+	scanner := in.scanner(scannerBufferSize)
+	batchSize := max(batchTransformSize(s.Options.BatchTransforms), sinkBatchSize)
+
+	var pending, spare []*Record
+	var written int64
+	flush := func() error {
+		if len(pending) == 0 {
+			return nil
+		}
+		kept, err := applyBatchTransforms(s.Options.BatchTransforms, pending)
+		if err != nil {
+			return fmt.Errorf("batch transform failed before line %d: %v", stats.TotalLines, err)
+		}
+		stats.DroppedLines += int64(len(pending) - len(kept))
+		if len(kept) > 0 {
+			writeStart := time.Now()
+			if err := s.Options.Sink.WriteBatch(kept); err != nil {
+				return fmt.Errorf("%w: write failed before line %d: %w", ErrSinkUnavailable, stats.TotalLines, err)
+			}
+			stats.Stages.WriteTime += time.Since(writeStart)
+		}
+		written += int64(len(kept))
+		if stats.Quantiles != nil {
+			for _, rec := range kept {
+				observeQuantiles(stats.Quantiles, rec.Bytes())
+			}
+		}
+		spare = append(spare, pending...)
+		pending = pending[:0]
+		return nil
+	}
+
+	// Reading and transforms run on their own goroutines; a slow sink fills their queues and
+	// holds them back
+	input := s.startStages(in, scanner, s.lineSlice())
+	defer input.stop()
+	progress := func(lines int64) {
+		s.emit(Event{Kind: EventProgress, Lines: lines})
+	}
+	var logged int64
+	for {
+		if err := ctl.abortErr(); err != nil {
+			return stats, err
+		}
+		if ctl.Paused() {
+			if err := flush(); err != nil {
+				return stats, err
+			}
+			ctl.waitIfPaused()
+		}
+
+		staged, err := input.next(&stats, progress)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return stats, err
+		}
+		// The staged record is reused once its batch is consumed, so the sink gets a copy
+		var rec *Record
+		if n := len(spare); n > 0 {
+			rec, spare = spare[n-1], spare[:n-1]
+		} else {
+			rec = &Record{}
+		}
+		rec.Reset(staged.Bytes())
+
+		if pending = append(pending, rec); len(pending) >= batchSize {
+			if err := flush(); err != nil {
+				return stats, err
+			}
+		}
+
+		if stats.TotalLines/1000000 > logged {
+			logged = stats.TotalLines / 1000000
+			s.logger().Printf("🔄 Progress: Processed %d lines, %d records sent to sink", stats.TotalLines, written)
+		}
+	}
+	if err := flush(); err != nil {
+		return stats, err
+	}
+	closeStart := time.Now()
+	closed = true
+	if err := s.Options.Sink.Close(); err != nil {
+		return stats, fmt.Errorf("%w: failed to close sink: %w", ErrSinkUnavailable, err)
+	}
+	stats.Stages.WriteTime += time.Since(closeStart)
+
+	stats.ExecutionTime = time.Since(start)
+	// The checksum of an input read only up to a slice of its lines would be wrong
+	if !input.cut {
+		stats.InputSHA256 = in.SHA256()
+	}
+	stats.Members = in.Members()
+	in.lines.logNormalized()
+	in.frames.logChecksums()
+	in.addTelemetry(&stats.Stages)
+	stats.Stages.Records = stats.TotalLines
+	input.addTelemetry(&stats.Stages)
+	s.updateCache(inputPath, in, stats)
+	s.logger().Printf("✅ Processing complete, %d records sent to sink", written)
+	s.logger().Printf("%s", stats.String())
+	return stats, nil
+}
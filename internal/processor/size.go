@@ -0,0 +1,118 @@
+package processor
+
+import (
+	"fmt"
+	"sync/atomic"
+	"unicode/utf8"
+)
+
+// truncationMarker is appended to text fields shortened by the truncate policy
+const truncationMarker = "…[truncated]"
+
+// SizeLimitTransform enforces a maximum encoded record size, protecting downstream systems with
+// row-size limits from pathological records such as selftexts with embedded data.
+//
+// Policies:
+//
+//	drop       - oversized records are dropped
+//	truncate   - the largest top-level string fields are shortened until the record fits;
+//	             records that still do not fit are dropped
+//	quarantine - oversized records are written unchanged to a side file and dropped
+type SizeLimitTransform struct {
+	MaxBytes int
+	Policy   string
+
+	quarantine *sideFile
+
+	dropped     atomic.Int64
+	truncated   atomic.Int64
+	quarantined atomic.Int64
+
+	runLogger
+}
+
+// NewSizeLimitTransform creates a size limit with the given policy. quarantinePath is only used
+// by the quarantine policy and is created on the first oversized record.
+func NewSizeLimitTransform(maxBytes int, policy, quarantinePath string) (*SizeLimitTransform, error) {
+	if maxBytes <= 0 {
+		return nil, fmt.Errorf("record size limit must be positive")
+	}
+	switch policy {
+	case "drop", "truncate", "quarantine":
+	default:
+		return nil, fmt.Errorf("unsupported oversized-record policy %q, expected drop, truncate or quarantine", policy)
+	}
+	quarantine := &sideFile{path: quarantinePath, kind: "quarantine file", announce: "🚧 Quarantining oversized records to"}
+	return &SizeLimitTransform{MaxBytes: maxBytes, Policy: policy, quarantine: quarantine}, nil
+}
+
+// Apply keeps records within the limit and handles oversized ones according to the policy
+func (t *SizeLimitTransform) Apply(rec *Record) (bool, error) {
+	if len(rec.Bytes()) <= t.MaxBytes {
+		return true, nil
+	}
+
+	switch t.Policy {
+	case "truncate":
+		if t.truncate(rec) {
+			t.truncated.Add(1)
+			return true, nil
+		}
+	case "quarantine":
+		if err := t.quarantine.write(rec.Bytes()); err != nil {
+			return false, err
+		}
+		t.quarantined.Add(1)
+		return false, nil
+	}
+	t.dropped.Add(1)
+	return false, nil
+}
+
+// truncate shortens the largest string fields until the record fits, reporting whether it does
+func (t *SizeLimitTransform) truncate(rec *Record) bool {
+	for range rec.Keys() {
+		excess := len(rec.Bytes()) - t.MaxBytes
+		if excess <= 0 {
+			return true
+		}
+
+		largest, value := "", ""
+		for _, key := range rec.Keys() {
+			if s, ok := rec.GetString(key); ok && len(s) > len(value) {
+				largest, value = key, s
+			}
+		}
+		if value == "" {
+			return false
+		}
+
+		// Cutting the decoded text by the excess removes at least as many encoded bytes
+		keep := max(len(value)-excess-len(truncationMarker), 0)
+		for keep > 0 && !utf8.RuneStart(value[keep]) {
+			keep--
+		}
+		shortened := ""
+		if keep > 0 {
+			shortened = value[:keep] + truncationMarker
+		}
+		if rec.Set(largest, shortened) != nil {
+			return false
+		}
+	}
+	return len(rec.Bytes()) <= t.MaxBytes
+}
+
+// Close reports the oversized-record counts and closes the quarantine file
+func (t *SizeLimitTransform) Close() error {
+	if n := t.dropped.Load() + t.truncated.Load() + t.quarantined.Load(); n > 0 {
+		loggerOrDefault(t.logger).Printf("📏 Oversized records (> %d bytes): %d dropped, %d truncated, %d quarantined",
+			t.MaxBytes, t.dropped.Load(), t.truncated.Load(), t.quarantined.Load())
+	}
+	return t.quarantine.Close()
+}
+
+// sideFiles returns the quarantine file of oversized records
+func (t *SizeLimitTransform) sideFiles() []*sideFile {
+	return []*sideFile{t.quarantine}
+}
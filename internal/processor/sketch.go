@@ -0,0 +1,220 @@
+package processor
+
+import (
+	"encoding/json"
+	"hash/maphash"
+	"math"
+	"math/bits"
+	"sort"
+)
+
+// tdigestCompression trades accuracy for size; 100 keeps quantile errors well below 1% with at
+// most a few hundred centroids
+const tdigestCompression = 100
+
+// centroid is a cluster of values in a t-digest
+type centroid struct {
+	Mean   float64 `json:"m"`
+	Weight float64 `json:"w"`
+}
+
+// TDigest is a merging t-digest for streaming quantile estimates in bounded memory
+type TDigest struct {
+	centroids []centroid
+	buffer    []centroid
+	count     float64
+	min, max  float64
+}
+
+// NewTDigest creates an empty digest
+func NewTDigest() *TDigest {
+	return &TDigest{min: math.Inf(1), max: math.Inf(-1)}
+}
+
+// Add records a value
+func (d *TDigest) Add(x float64) {
+	d.buffer = append(d.buffer, centroid{Mean: x, Weight: 1})
+	d.count++
+	d.min = math.Min(d.min, x)
+	d.max = math.Max(d.max, x)
+	if len(d.buffer) >= 5*tdigestCompression {
+		d.compress()
+	}
+}
+
+// Merge adds every value summarized by other
+func (d *TDigest) Merge(other *TDigest) {
+	if other == nil || other.count == 0 {
+		return
+	}
+	d.buffer = append(d.buffer, other.centroids...)
+	d.buffer = append(d.buffer, other.buffer...)
+	d.count += other.count
+	d.min = math.Min(d.min, other.min)
+	d.max = math.Max(d.max, other.max)
+	d.compress()
+}
+
+// Count returns the number of values added
+func (d *TDigest) Count() int64 {
+	return int64(d.count)
+}
+
+// compress merges buffered values into the centroids, keeping clusters small near the tails
+func (d *TDigest) compress() {
+	if len(d.buffer) == 0 {
+		return
+	}
+	all := append(d.centroids, d.buffer...)
+	sort.Slice(all, func(i, j int) bool { return all[i].Mean < all[j].Mean })
+
+	merged := make([]centroid, 0, 2*tdigestCompression)
+	current := all[0]
+	weightSoFar := 0.0
+	for _, next := range all[1:] {
+		proposed := current.Weight + next.Weight
+		q0 := weightSoFar / d.count
+		q2 := (weightSoFar + proposed) / d.count
+		limit := d.count * 4 * math.Min(q0*(1-q0), q2*(1-q2)) / tdigestCompression
+		if proposed <= limit {
+			current.Mean += (next.Mean - current.Mean) * next.Weight / proposed
+			current.Weight = proposed
+			continue
+		}
+		merged = append(merged, current)
+		weightSoFar += current.Weight
+		current = next
+	}
+	d.centroids = append(merged, current)
+	d.buffer = d.buffer[:0]
+}
+
+// Quantile returns the estimated value at quantile q in [0, 1], or NaN for an empty digest
+func (d *TDigest) Quantile(q float64) float64 {
+	d.compress()
+	if len(d.centroids) == 0 {
+		return math.NaN()
+	}
+	if q <= 0 {
+		return d.min
+	}
+	if q >= 1 {
+		return d.max
+	}
+
+	// Interpolate between centroid centers, anchored at the exact min and max
+	index := q * d.count
+	prevMean, prevCenter, cumulative := d.min, 0.0, 0.0
+	for _, c := range d.centroids {
+		center := cumulative + c.Weight/2
+		if index <= center {
+			if center == prevCenter {
+				return c.Mean
+			}
+			return prevMean + (index-prevCenter)/(center-prevCenter)*(c.Mean-prevMean)
+		}
+		prevMean, prevCenter = c.Mean, center
+		cumulative += c.Weight
+	}
+	if d.count == prevCenter {
+		return d.max
+	}
+	return prevMean + (index-prevCenter)/(d.count-prevCenter)*(d.max-prevMean)
+}
+
+// hllPrecision gives 4096 registers, about 1.6% standard error
+const hllPrecision = 12
+
+// exactDistinctLimit is the number of distinct values tracked exactly before switching to HyperLogLog
+const exactDistinctLimit = 1024
+
+// distinctSeed is shared by all counters of a run so they hash values identically
+var distinctSeed = maphash.MakeSeed()
+
+// DistinctCounter counts distinct strings exactly while there are few of them and with a
+// HyperLogLog sketch afterwards, so thousands of counters (e.g. one per subreddit) stay small
+type DistinctCounter struct {
+	exact     map[uint64]struct{}
+	registers []uint8
+}
+
+// Add records a value
+func (c *DistinctCounter) Add(value string) {
+	h := maphash.String(distinctSeed, value)
+	if c.registers != nil {
+		c.addHash(h)
+		return
+	}
+	if c.exact == nil {
+		c.exact = make(map[uint64]struct{})
+	}
+	c.exact[h] = struct{}{}
+	if len(c.exact) > exactDistinctLimit {
+		c.registers = make([]uint8, 1<<hllPrecision)
+		for h := range c.exact {
+			c.addHash(h)
+		}
+		c.exact = nil
+	}
+}
+
+// addHash updates the HyperLogLog register selected by the hash
+func (c *DistinctCounter) addHash(h uint64) {
+	index := h >> (64 - hllPrecision)
+	rank := uint8(bits.LeadingZeros64(h<<hllPrecision|1<<(hllPrecision-1))) + 1
+	if rank > c.registers[index] {
+		c.registers[index] = rank
+	}
+}
+
+// Count returns the (estimated) number of distinct values
+func (c *DistinctCounter) Count() int64 {
+	if c.registers == nil {
+		return int64(len(c.exact))
+	}
+
+	m := float64(len(c.registers))
+	sum, zeros := 0.0, 0
+	for _, r := range c.registers {
+		sum += math.Ldexp(1, -int(r))
+		if r == 0 {
+			zeros++
+		}
+	}
+	estimate := 0.7213 / (1 + 1.079/m) * m * m / sum
+	if estimate <= 2.5*m && zeros > 0 {
+		// Linear counting is more accurate for small cardinalities
+		estimate = m * math.Log(m/float64(zeros))
+	}
+	return int64(estimate + 0.5)
+}
+
+// tdigestJSON is the serialized form of a TDigest
+type tdigestJSON struct {
+	Count     float64    `json:"count"`
+	Min       float64    `json:"min"`
+	Max       float64    `json:"max"`
+	Centroids []centroid `json:"centroids"`
+}
+
+// MarshalJSON serializes the digest so it can be stored and merged later
+func (d *TDigest) MarshalJSON() ([]byte, error) {
+	d.compress()
+	if d.count == 0 {
+		return json.Marshal(tdigestJSON{Centroids: []centroid{}})
+	}
+	return json.Marshal(tdigestJSON{Count: d.count, Min: d.min, Max: d.max, Centroids: d.centroids})
+}
+
+// UnmarshalJSON restores a serialized digest
+func (d *TDigest) UnmarshalJSON(data []byte) error {
+	var decoded tdigestJSON
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		return err
+	}
+	*d = TDigest{centroids: decoded.Centroids, count: decoded.Count, min: decoded.Min, max: decoded.Max}
+	if d.count == 0 {
+		d.min, d.max = math.Inf(1), math.Inf(-1)
+	}
+	return nil
+}
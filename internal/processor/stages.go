@@ -0,0 +1,574 @@
+package processor
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"math"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// stageBatchLines is how many input lines travel between stages in one batch
+	stageBatchLines = 1024
+	// stageQueueDepth is how many batches each queue between stages holds before the stage
+	// feeding it blocks
+	stageQueueDepth = 16
+)
+
+// Names of the queues between pipeline stages
+const (
+	QueueLines   = "lines"
+	QueueRecords = "records"
+)
+
+// recordBatch carries consecutive input lines between stages. The first kept records passed the
+// transforms; the rest were dropped and are only kept for reuse.
+type recordBatch struct {
+	recs []*Record
+	kept int
+	// dropped counts the lines of the batch removed by the filters or transforms
+	dropped int64
+	// matched and skipped count the lines of the batch the filters kept and removed
+	matched int64
+	skipped int64
+	// seen and removed count the records each filter and then each transform saw and dropped
+	seen    []int64
+	removed []int64
+	// bad holds the records of the batch a transform failed on, when they are quarantined
+	bad []*ErrBadRecord
+	// badLines holds the lines of the batch that are not valid JSON, when they are skipped or
+	// quarantined
+	badLines []*ErrBadLine
+	// err ends the stream after the batch's records
+	err error
+	// last is the number of the last input line read into the batch
+	last int64
+}
+
+// QueueDepth is the current fill of a queue between two pipeline stages
+type QueueDepth struct {
+	Name     string `json:"name"`
+	Depth    int    `json:"depth"`
+	Capacity int    `json:"capacity"`
+}
+
+// QueueTelemetry describes how full a queue between two pipeline stages was over a run and how
+// long the stages on either side waited for each other
+type QueueTelemetry struct {
+	Name     string `json:"name"`
+	Capacity int    `json:"capacity"`
+	// Batches counts the batches that went through the queue
+	Batches   int64   `json:"batches"`
+	PeakDepth int     `json:"peak_depth"`
+	MeanDepth float64 `json:"mean_depth"`
+	// ProducerWait is how long the upstream stage was blocked on a full queue, i.e. the
+	// backpressure applied by the stages after it
+	ProducerWait time.Duration `json:"producer_wait_ns"`
+	// ConsumerWait is how long the downstream stage waited on an empty queue for the stages
+	// before it
+	ConsumerWait time.Duration `json:"consumer_wait_ns"`
+}
+
+// add merges the telemetry of the same queue in another run
+func (q *QueueTelemetry) add(other QueueTelemetry) {
+	if batches := q.Batches + other.Batches; batches > 0 {
+		q.MeanDepth = (q.MeanDepth*float64(q.Batches) + other.MeanDepth*float64(other.Batches)) / float64(batches)
+	}
+	q.Batches += other.Batches
+	q.Capacity = max(q.Capacity, other.Capacity)
+	q.PeakDepth = max(q.PeakDepth, other.PeakDepth)
+	q.ProducerWait += other.ProducerWait
+	q.ConsumerWait += other.ConsumerWait
+}
+
+// stageQueue is a bounded channel between two stages, measuring its depth and the time each
+// side spent blocked on the other
+type stageQueue struct {
+	name     string
+	ch       chan *recordBatch
+	batches  atomic.Int64
+	depthSum atomic.Int64
+	peak     atomic.Int64
+	full     atomic.Int64
+	empty    atomic.Int64
+}
+
+// newStageQueue creates a queue holding up to depth batches
+func newStageQueue(name string, depth int) *stageQueue {
+	return &stageQueue{name: name, ch: make(chan *recordBatch, depth)}
+}
+
+// send queues a batch, blocking while the queue is full. It returns false when the pipeline
+// was stopped first.
+func (q *stageQueue) send(b *recordBatch, done <-chan struct{}) bool {
+	depth := int64(len(q.ch))
+	q.batches.Add(1)
+	q.depthSum.Add(depth)
+	if depth > q.peak.Load() {
+		q.peak.Store(depth)
+	}
+	select {
+	case q.ch <- b:
+		return true
+	default:
+	}
+	start := time.Now()
+	defer func() { q.full.Add(int64(time.Since(start))) }()
+	select {
+	case q.ch <- b:
+		return true
+	case <-done:
+		return false
+	}
+}
+
+// receive takes the next batch, blocking while the queue is empty. It returns false once the
+// queue is closed and drained.
+func (q *stageQueue) receive() (*recordBatch, bool) {
+	select {
+	case b, ok := <-q.ch:
+		return b, ok
+	default:
+	}
+	start := time.Now()
+	b, ok := <-q.ch
+	q.empty.Add(int64(time.Since(start)))
+	return b, ok
+}
+
+// depth returns the current fill of the queue
+func (q *stageQueue) depth() QueueDepth {
+	return QueueDepth{Name: q.name, Depth: len(q.ch), Capacity: cap(q.ch)}
+}
+
+// telemetry returns the queue's counters for the run's statistics
+func (q *stageQueue) telemetry() QueueTelemetry {
+	t := QueueTelemetry{
+		Name:         q.name,
+		Capacity:     cap(q.ch),
+		Batches:      q.batches.Load(),
+		PeakDepth:    int(q.peak.Load()),
+		ProducerWait: time.Duration(q.full.Load()),
+		ConsumerWait: time.Duration(q.empty.Load()),
+	}
+	if t.Batches > 0 {
+		t.MeanDepth = float64(q.depthSum.Load()) / float64(t.Batches)
+	}
+	return t
+}
+
+// stagedInput runs the first stages of the pipeline on their own goroutines, connected to each
+// other and to the output stage by bounded queues:
+//
+//	read (decompress and split lines) → lines → transform → records → output (parts or sink)
+//
+// A slow output fills the records queue, which blocks the transform stage, which fills the lines
+// queue and blocks reading, so memory stays bounded and the queue telemetry shows where runs
+// wait.
+type stagedInput struct {
+	lines   *stageQueue
+	records *stageQueue
+	free    chan *recordBatch
+	done    chan struct{}
+	stopped sync.Once
+	wg      sync.WaitGroup
+	ctl     *Control
+	// ranges, when set, limits the stages to these input lines
+	ranges []lineRange
+	// splitter splits the input, and tells where each line starts
+	splitter *lineSplitter
+	// onBad, when set, reports the records a transform fails on. With quarantine they are
+	// dropped after it reports them, instead of failing the run.
+	onBad      func(*ErrBadRecord) error
+	closeBad   func()
+	quarantine bool
+	// checkLines validates each line as JSON before the filters, failing on invalid ones unless
+	// onBadLine takes them
+	checkLines   bool
+	onBadLine    func(*ErrBadLine) error
+	closeBadLine func()
+	// part is the part being written, for reporting bad records
+	part int
+	// filters names the filters and transforms in the order batches count them
+	filters []FilterStats
+	// sample, when set, samples the records each of filters drops, and closeSample writes it out
+	sample      *droppedSampler
+	closeSample func()
+	// redaction is the run's redaction policy, applied to the bad records and lines caught before
+	// its transform at index redactionAt of the filters and transforms
+	redaction   *RedactionTransform
+	redactionAt int
+
+	// transformNanos is the time the transform stage spent on records rather than waiting
+	transformNanos atomic.Int64
+
+	// batch is the batch the output stage is consuming, at record pos
+	batch *recordBatch
+	pos   int
+	// lastLine is the input line number of the last record returned by next, and lastOffset where
+	// the line starts in the decompressed input
+	lastLine   int64
+	lastOffset int64
+	// lineBase is the number of input lines before the first one read
+	lineBase int64
+	// counted is the last input line whose counts next has added to the statistics, and
+	// uncounted the last line the statistics of a resumed run already count: lines up to it are
+	// processed again without being counted twice
+	counted   int64
+	uncounted int64
+	// cut is set when reading stopped at the end of the last range, before the input ended
+	cut bool
+}
+
+// lineRange is an inclusive range of input line numbers, counting from 1
+type lineRange struct {
+	first, last int64
+	// partial lets the input end before last instead of failing
+	partial bool
+}
+
+// startStages starts the read and transform stages over scanner, which splits in's lines. They
+// only pass on the input lines within ranges, which must be sorted and disjoint, or every line
+// when ranges is nil. next returns io.EOF at the end of each range; nextRange moves on to the
+// following one.
+func (s *PushshiftProcessor) startStages(in *zstInput, scanner *bufio.Scanner, ranges []lineRange) *stagedInput {
+	ctl := s.Options.Control
+	p := &stagedInput{
+		lines:     newStageQueue(QueueLines, stageQueueDepth),
+		records:   newStageQueue(QueueRecords, stageQueueDepth),
+		free:      make(chan *recordBatch, 2*stageQueueDepth+2),
+		done:      make(chan struct{}),
+		ctl:       ctl,
+		ranges:    ranges,
+		splitter:  in.lines,
+		lineBase:  in.lineBase,
+		lastLine:  in.lineBase,
+		uncounted: in.countedLines,
+	}
+	for _, f := range s.Options.Filters {
+		p.filters = append(p.filters, FilterStats{Name: transformName(f)})
+	}
+	for _, t := range s.Options.Transforms {
+		p.filters = append(p.filters, FilterStats{Name: transformName(t), Transform: true})
+	}
+	p.redaction, p.redactionAt = s.redaction()
+	s.redactSideFiles()
+	s.setLoggers()
+	p.onBad, p.closeBad = s.badRecordHandler()
+	p.sample, p.closeSample = s.droppedSampler(p.filters)
+	if p.checkLines = s.Options.OnBadLine != ""; p.checkLines && s.Options.OnBadLine != BadLineFail {
+		p.onBadLine, p.closeBadLine = s.badLineHandler()
+	}
+	p.quarantine = s.Options.BadRecords == BadRecordsQuarantine
+	if len(ranges) > 0 {
+		// Parts start after the last line returned, so the lines skipped count as returned
+		p.lastLine = ranges[0].first - 1
+	}
+	ctl.setQueues([]*stageQueue{p.lines, p.records})
+	p.wg.Add(2)
+	go p.read(scanner)
+	go p.transform(s.Options.Filters, s.Options.Transforms)
+	return p
+}
+
+// stop ends the stages and waits for them to exit, so the input can be closed safely
+func (p *stagedInput) stop() {
+	p.stopped.Do(func() { close(p.done) })
+	p.wg.Wait()
+	p.ctl.setQueues(nil)
+	if p.closeBad != nil {
+		p.closeBad()
+		p.closeBad = nil
+	}
+	if p.closeSample != nil {
+		p.closeSample()
+		p.closeSample = nil
+	}
+	if p.closeBadLine != nil {
+		p.closeBadLine()
+		p.closeBadLine = nil
+	}
+}
+
+// takeBatch returns a batch released by the output stage, or a new one
+func (p *stagedInput) takeBatch() *recordBatch {
+	select {
+	case b := <-p.free:
+		b.recs, b.kept, b.dropped, b.matched, b.skipped, b.bad, b.badLines, b.err, b.last = b.recs[:0], 0, 0, 0, 0, b.bad[:0], b.badLines[:0], nil, 0
+		return b
+	default:
+		return &recordBatch{recs: make([]*Record, 0, stageBatchLines)}
+	}
+}
+
+// lineSlice returns the input lines selected by SkipLines and TakeLines, or nil for all of them
+func (s *PushshiftProcessor) lineSlice() []lineRange {
+	if s.Options.SkipLines <= 0 && s.Options.TakeLines <= 0 {
+		return nil
+	}
+	r := lineRange{first: s.Options.SkipLines + 1, last: math.MaxInt64, partial: true}
+	if s.Options.TakeLines > 0 {
+		r.last = s.Options.SkipLines + s.Options.TakeLines
+		s.logger().Printf("✂️ Processing input lines %d to %d", r.first, r.last)
+	} else {
+		s.logger().Printf("✂️ Processing input lines from %d", r.first)
+	}
+	return []lineRange{r}
+}
+
+// read splits the decompressed input into batches of lines
+func (p *stagedInput) read(scanner *bufio.Scanner) {
+	defer p.wg.Done()
+	defer close(p.lines.ch)
+	lineNum := p.lineBase
+	ranges := p.ranges
+	for {
+		if p.ctl.Paused() {
+			p.ctl.wait(p.done)
+		}
+		select {
+		case <-p.done:
+			return
+		default:
+		}
+		b := p.takeBatch()
+		end, rangeEnd := false, false
+		// Skipped lines count toward the batch too, so skipping still checks for stops
+		for n, skipped := 0, 0; n+skipped < stageBatchLines && !rangeEnd; {
+			if !scanner.Scan() {
+				end = true
+				break
+			}
+			line := scanner.Bytes()
+			lineNum++
+			p.ctl.addLine(int64(len(line) + 1))
+			if ranges != nil && lineNum < ranges[0].first {
+				skipped++
+				continue
+			}
+			// Records of earlier uses of the batch are kept beyond its length for reuse
+			if b.recs = b.recs[:n+1]; b.recs[n] == nil {
+				b.recs[n] = &Record{}
+			}
+			b.recs[n].Reset(line)
+			b.recs[n].line, b.recs[n].offset = lineNum, p.splitter.lineStart
+			n++
+			if ranges != nil && lineNum == ranges[0].last {
+				ranges, rangeEnd = ranges[1:], true
+			}
+		}
+		switch {
+		case end && scanner.Err() != nil:
+			b.err = inputError(scanner.Err())
+		case end && len(ranges) > 0 && !ranges[0].partial:
+			b.err = fmt.Errorf("input ended at line %d, before line %d", lineNum, ranges[0].last)
+		case rangeEnd:
+			// The batch ends the range; the last range also ends the input
+			b.err = io.EOF
+			end = len(ranges) == 0
+			p.cut = end
+		}
+		b.last = lineNum
+		if len(b.recs) > 0 || b.err != nil {
+			if !p.lines.send(b, p.done) {
+				return
+			}
+		}
+		if end {
+			return
+		}
+	}
+}
+
+// transform runs each batch through the filters and then the transforms, moving the records kept
+// to its front. With checkLines, lines that are not valid JSON are set aside before the filters.
+func (p *stagedInput) transform(filters, transforms []Transform) {
+	defer p.wg.Done()
+	defer close(p.records.ch)
+	n := len(filters) + len(transforms)
+	uncountedSeen, uncountedRemoved := make([]int64, n), make([]int64, n)
+	for {
+		b, ok := p.lines.receive()
+		if !ok {
+			return
+		}
+		start := time.Now()
+		if len(b.seen) != n {
+			b.seen, b.removed = make([]int64, n), make([]int64, n)
+		} else {
+			clear(b.seen)
+			clear(b.removed)
+		}
+		nf := len(filters)
+		for i, rec := range b.recs {
+			// Lines a resumed run's statistics already count go through uncounted
+			count, seen, removed := int64(1), b.seen, b.removed
+			if rec.line <= p.uncounted {
+				count, seen, removed = 0, uncountedSeen, uncountedRemoved
+			}
+			if p.checkLines {
+				if bad := checkLine(rec); bad != nil {
+					if p.onBadLine == nil {
+						b.recs = b.recs[:i]
+						b.err = bad
+						break
+					}
+					p.redact(&bad.ErrBadRecord, -1)
+					b.badLines = append(b.badLines, bad)
+					b.dropped += count
+					continue
+				}
+			}
+			drop, err := applyCounted(filters, rec, seen[:nf], removed[:nf])
+			if err == nil && len(filters) > 0 {
+				if drop >= 0 {
+					if p.sample != nil && count > 0 {
+						p.sample.add(drop, rec)
+					}
+					b.skipped += count
+					b.dropped += count
+					continue
+				}
+				b.matched += count
+			}
+			if err == nil {
+				if drop, err = applyCounted(transforms, rec, seen[nf:], removed[nf:]); drop >= 0 {
+					drop += nf
+				}
+			}
+			if err != nil {
+				bad := &ErrBadRecord{Line: rec.line, Offset: rec.offset, Record: bytes.Clone(rec.Bytes()), Err: err}
+				p.redact(bad, drop)
+				if p.quarantine {
+					b.bad = append(b.bad, bad)
+					continue
+				}
+				b.recs = b.recs[:i]
+				b.err = bad
+				break
+			}
+			if drop >= 0 {
+				if p.sample != nil && count > 0 {
+					p.sample.add(drop, rec)
+				}
+				b.dropped += count
+				continue
+			}
+			b.recs[b.kept], b.recs[i] = b.recs[i], b.recs[b.kept]
+			b.kept++
+		}
+		p.transformNanos.Add(int64(time.Since(start)))
+		if !p.records.send(b, p.done) {
+			return
+		}
+	}
+}
+
+// redact applies the redaction policy to a bad record caught by filter or transform i, or
+// withholds it, before it is logged or quarantined
+func (p *stagedInput) redact(bad *ErrBadRecord, i int) {
+	if p.redaction == nil {
+		return
+	}
+	var ok bool
+	if bad.Record, ok = p.redaction.sideOutput(p.redactionAt, i, bad.Record); !ok {
+		bad.Record, bad.Withheld = nil, true
+	}
+}
+
+// next returns the next record kept by the transforms, counting the lines read and dropped in
+// stats as batches arrive. It returns io.EOF at the end of the input. The record is only valid
+// until the following call.
+func (p *stagedInput) next(stats *ProcessStats, emit func(lines int64)) (*Record, error) {
+	for p.batch == nil || p.pos >= p.batch.kept {
+		if p.batch != nil {
+			if err := p.batch.err; err != nil {
+				switch bad := err.(type) {
+				case *ErrBadRecord:
+					if p.onBad != nil {
+						bad.Part = p.part
+						p.onBad(bad)
+					}
+				case *ErrBadLine:
+					bad.Part = p.part
+				}
+				return nil, err
+			}
+			p.release(p.batch)
+			p.batch = nil
+		}
+		b, ok := p.records.receive()
+		if !ok {
+			return nil, io.EOF
+		}
+		p.counted = max(p.counted, b.last)
+		before := stats.TotalLines
+		stats.TotalLines += int64(len(b.recs))
+		stats.DroppedLines += b.dropped
+		stats.MatchedLines += b.matched
+		stats.SkippedLines += b.skipped
+		if len(b.seen) > 0 {
+			if len(stats.Filters) != len(p.filters) {
+				stats.Filters = slices.Clone(p.filters)
+			}
+			for i := range b.seen {
+				stats.Filters[i].Seen += b.seen[i]
+				stats.Filters[i].Dropped += b.removed[i]
+			}
+		}
+		for _, bad := range b.bad {
+			bad.Part = p.part
+			if bad.Line > p.uncounted {
+				stats.BadRecords++
+			}
+			if err := p.onBad(bad); err != nil {
+				return nil, err
+			}
+		}
+		for _, bad := range b.badLines {
+			bad.Part = p.part
+			if bad.Line > p.uncounted {
+				stats.BadLines++
+			}
+			if err := p.onBadLine(bad); err != nil {
+				return nil, err
+			}
+		}
+		if stats.TotalLines/progressEventLines > before/progressEventLines {
+			emit(stats.TotalLines)
+		}
+		p.batch, p.pos = b, 0
+	}
+	rec := p.batch.recs[p.pos]
+	p.pos++
+	p.lastLine, p.lastOffset = rec.line, rec.offset
+	return rec, nil
+}
+
+// nextRange moves on to the next line range after next returned io.EOF at the end of one
+func (p *stagedInput) nextRange() {
+	if p.batch != nil && p.batch.err == io.EOF {
+		p.release(p.batch)
+		p.batch = nil
+	}
+}
+
+// release hands a consumed batch back for reuse
+func (p *stagedInput) release(b *recordBatch) {
+	select {
+	case p.free <- b:
+	default:
+	}
+}
+
+// addTelemetry records the transform stage's busy time and the queues' telemetry in t
+func (p *stagedInput) addTelemetry(t *StageTelemetry) {
+	t.ProcessTime += time.Duration(p.transformNanos.Load())
+	t.addQueue(p.lines.telemetry())
+	t.addQueue(p.records.telemetry())
+}
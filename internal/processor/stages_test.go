@@ -0,0 +1,206 @@
+package processor
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// failTransform fails on records with a bad field
+type failTransform struct{}
+
+func (failTransform) Apply(rec *Record) (bool, error) {
+	if _, ok := rec.Get("bad"); ok {
+		return false, fmt.Errorf("bad record")
+	}
+	return true, nil
+}
+
+// runStages runs input through the read and transform stages and returns the lines of the
+// records kept, the statistics, the lines of the bad records reported and the error that ended
+// the stream
+func runStages(input string, ranges []lineRange, filters, transforms []Transform, quarantine bool) ([]int64, ProcessStats, []int64, error) {
+	p := &stagedInput{
+		lines:      newStageQueue(QueueLines, stageQueueDepth),
+		records:    newStageQueue(QueueRecords, stageQueueDepth),
+		free:       make(chan *recordBatch, 2*stageQueueDepth+2),
+		done:       make(chan struct{}),
+		ranges:     ranges,
+		splitter:   &lineSplitter{},
+		quarantine: quarantine,
+	}
+	var reported []int64
+	p.onBad = func(bad *ErrBadRecord) error {
+		reported = append(reported, bad.Line)
+		return nil
+	}
+	for _, f := range filters {
+		p.filters = append(p.filters, FilterStats{Name: transformName(f)})
+	}
+	for _, t := range transforms {
+		p.filters = append(p.filters, FilterStats{Name: transformName(t), Transform: true})
+	}
+	scanner := bufio.NewScanner(strings.NewReader(input))
+	scanner.Split(p.splitter.split)
+	p.wg.Add(2)
+	go p.read(scanner)
+	go p.transform(filters, transforms)
+	defer p.stop()
+
+	var lines []int64
+	var stats ProcessStats
+	for {
+		rec, err := p.next(&stats, func(int64) {})
+		if err == io.EOF && p.batch != nil {
+			p.nextRange()
+			continue
+		}
+		if err == io.EOF {
+			return lines, stats, reported, nil
+		}
+		if err != nil {
+			return lines, stats, reported, err
+		}
+		lines = append(lines, rec.line)
+	}
+}
+
+// scoredLines returns n records scored by their line number, with a bad field on the lines in bad
+func scoredLines(n int, bad ...int) string {
+	var b strings.Builder
+	for i := 1; i <= n; i++ {
+		fmt.Fprintf(&b, `{"score":%d`, i)
+		for _, line := range bad {
+			if line == i {
+				b.WriteString(`,"bad":true`)
+			}
+		}
+		b.WriteString("}\n")
+	}
+	return b.String()
+}
+
+// lineNumbers returns the numbers from first to last
+func lineNumbers(first, last int64) []int64 {
+	var lines []int64
+	for i := first; i <= last; i++ {
+		lines = append(lines, i)
+	}
+	return lines
+}
+
+func TestStages(t *testing.T) {
+	tests := []struct {
+		name        string
+		input       string
+		ranges      []lineRange
+		filters     []Transform
+		transforms  []Transform
+		quarantine  bool
+		want        []int64
+		reported    []int64
+		total       int64
+		dropped     int64
+		filterStats []FilterStats
+		wantErr     string
+	}{
+		{
+			name:  "every line",
+			input: scoredLines(5),
+			want:  lineNumbers(1, 5),
+			total: 5,
+		},
+		{
+			name:    "filtered",
+			input:   scoredLines(5),
+			filters: []Transform{&ScoreFilter{Min: 3}},
+			want:    lineNumbers(3, 5),
+			total:   5,
+			dropped: 2,
+			filterStats: []FilterStats{
+				{Name: "min-score", Seen: 5, Dropped: 2},
+			},
+		},
+		{
+			name:       "filters before transforms",
+			input:      scoredLines(5, 1, 4),
+			filters:    []Transform{&ScoreFilter{Min: 2}},
+			transforms: []Transform{failTransform{}},
+			quarantine: true,
+			want:       []int64{2, 3, 5},
+			reported:   []int64{4},
+			total:      5,
+			dropped:    1,
+			filterStats: []FilterStats{
+				{Name: "min-score", Seen: 5, Dropped: 1},
+				{Name: transformName(failTransform{}), Transform: true, Seen: 4},
+			},
+		},
+		{
+			name:       "failing transform",
+			input:      scoredLines(5, 3),
+			transforms: []Transform{failTransform{}},
+			want:       lineNumbers(1, 2),
+			reported:   []int64{3},
+			total:      2,
+			filterStats: []FilterStats{
+				{Name: transformName(failTransform{}), Transform: true, Seen: 3},
+			},
+			wantErr: "bad record",
+		},
+		{
+			name:   "line ranges",
+			input:  scoredLines(6),
+			ranges: []lineRange{{first: 2, last: 3}, {first: 5, last: 5}},
+			want:   []int64{2, 3, 5},
+			total:  3,
+		},
+		{
+			name:   "ranges across batches",
+			input:  scoredLines(3000),
+			ranges: []lineRange{{first: 1000, last: 2100}, {first: 2500, last: math.MaxInt64, partial: true}},
+			want:   append(lineNumbers(1000, 2100), lineNumbers(2500, 3000)...),
+			total:  1602,
+		},
+		{
+			name:    "input ending inside a range",
+			input:   scoredLines(4),
+			ranges:  []lineRange{{first: 3, last: 6}},
+			want:    lineNumbers(3, 4),
+			total:   2,
+			wantErr: "input ended at line 4, before line 6",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, stats, reported, err := runStages(tt.input, tt.ranges, tt.filters, tt.transforms, tt.quarantine)
+			if tt.wantErr == "" && err != nil || tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+				t.Fatalf("got error %v, want %q", err, tt.wantErr)
+			}
+			var bad *ErrBadRecord
+			if errors.As(err, &bad) && bad.Line != tt.want[len(tt.want)-1]+1 {
+				t.Errorf("failed on line %d, want %d", bad.Line, tt.want[len(tt.want)-1]+1)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got lines %v, want %v", got, tt.want)
+			}
+			if !reflect.DeepEqual(reported, tt.reported) {
+				t.Errorf("reported lines %v, want %v", reported, tt.reported)
+			}
+			if stats.TotalLines != tt.total || stats.DroppedLines != tt.dropped {
+				t.Errorf("counted %d lines and dropped %d, want %d and %d", stats.TotalLines, stats.DroppedLines, tt.total, tt.dropped)
+			}
+			if tt.quarantine && int64(len(tt.reported)) != stats.BadRecords {
+				t.Errorf("counted %d bad records, want %d", stats.BadRecords, len(tt.reported))
+			}
+			if !reflect.DeepEqual(stats.Filters, tt.filterStats) {
+				t.Errorf("got filter stats %+v, want %+v", stats.Filters, tt.filterStats)
+			}
+		})
+	}
+}
@@ -0,0 +1,197 @@
+package processor
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"log"
+	"sort"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// startAtSampleBytes bounds how much of a frame is decompressed looking for a timestamp
+const startAtSampleBytes = 16 * 1024 * 1024
+
+// frameSpan is the position of one zstd frame in the compressed input
+type frameSpan struct {
+	Offset int64 `json:"offset"`
+	Size   int64 `json:"size"`
+	// Content is the decompressed size declared in the frame header, 0 when it declares none
+	Content int64 `json:"content,omitempty"`
+}
+
+// indexFrames walks the frame and block headers of a zstd file without decompressing anything,
+// returning where each data frame starts. Only a few bytes per block are read.
+func indexFrames(r io.ReaderAt, size int64) ([]frameSpan, error) {
+	var spans []frameSpan
+	header := make([]byte, 8)
+	padding := make([]byte, 64*1024)
+	offset := int64(0)
+	for offset < size {
+		// Skip zero padding between frames
+		n, err := r.ReadAt(padding[:min(int64(len(padding)), size-offset)], offset)
+		if n == 0 {
+			return nil, fmt.Errorf("failed to read zstd frame header at offset %d: %v", offset, err)
+		}
+		zeros := len(padding[:n]) - len(bytes.TrimLeft(padding[:n], "\x00"))
+		offset += int64(zeros)
+		if zeros == n {
+			continue
+		}
+
+		if _, err := r.ReadAt(header, offset); err != nil && (err != io.EOF || size-offset < 5) {
+			return nil, fmt.Errorf("truncated zstd frame header at offset %d", offset)
+		}
+		magic := binary.LittleEndian.Uint32(header)
+		switch {
+		case magic == zstdFrameMagic:
+			start := offset
+			headerSize, trailer := frameHeaderSize(header[4])
+			offset += headerSize
+			for last := false; !last; {
+				if _, err := r.ReadAt(header[:3], offset); err != nil {
+					return nil, fmt.Errorf("truncated zstd block header at offset %d", offset)
+				}
+				var blockBytes int64
+				var ok bool
+				blockBytes, last, ok = blockSize(header[:3])
+				if !ok {
+					return nil, fmt.Errorf("corrupt zstd block header at offset %d", offset)
+				}
+				offset += blockBytes
+			}
+			offset += trailer
+			spans = append(spans, frameSpan{Offset: start, Size: offset - start})
+		case magic&zstdSkippableMagicMask == zstdSkippableMagic:
+			offset += 8 + int64(binary.LittleEndian.Uint32(header[4:]))
+		default:
+			return nil, fmt.Errorf("unexpected data at offset %d of the compressed input: not a zstd frame", offset)
+		}
+	}
+	if offset > size {
+		return nil, fmt.Errorf("truncated zstd frame at the end of the input")
+	}
+	return spans, nil
+}
+
+// frameStartTime returns the created_utc of the first complete record in a frame. A frame usually
+// starts partway through a line, so the first line is always skipped.
+func frameStartTime(r io.ReaderAt, span frameSpan) (int64, bool) {
+	zr, err := zstd.NewReader(io.NewSectionReader(r, span.Offset, span.Size), zstd.WithDecoderConcurrency(1))
+	if err != nil {
+		return 0, false
+	}
+	defer zr.Close()
+
+	scanner := newLineScanner(io.LimitReader(zr, startAtSampleBytes), startAtSampleBytes)
+	scanner.Scan()
+	for scanner.Scan() {
+		if created, ok := NewRecord(scanner.Bytes()).GetInt("created_utc"); ok {
+			return created, true
+		}
+	}
+	return 0, false
+}
+
+// seekStartAt finds the offset of the last frame starting before t, by binary search over the
+// frames' first timestamps. Dumps are only roughly sorted, so records before t may follow; the
+// caller still has to filter them. It returns 0 when the input can't be entered mid-stream.
+// spans is a cached frame index, built when nil.
+func seekStartAt(logger *log.Logger, r io.ReaderAt, size int64, t time.Time, spans []frameSpan) (int64, error) {
+	if spans == nil {
+		start := time.Now()
+		var err error
+		if spans, err = indexFrames(r, size); err != nil {
+			return 0, fmt.Errorf("failed to index zstd frames: %v", err)
+		}
+		logger.Printf("🗂️ Indexed %d zstd frames in %s", len(spans), time.Since(start).Round(time.Millisecond))
+	} else {
+		logger.Printf("🗃️ Using the cached index of %d zstd frames", len(spans))
+	}
+	if len(spans) < 2 {
+		warnf(logger, "⚠️ Warning: the input is a single zstd frame, which can only be decoded from the start; records before %s are skipped while reading", t.UTC().Format(time.RFC3339))
+		return 0, nil
+	}
+
+	target := t.Unix()
+	i := sort.Search(len(spans), func(i int) bool {
+		created, ok := frameStartTime(r, spans[i])
+		return ok && created >= target
+	})
+	if i == 0 {
+		return 0, nil
+	}
+	span := spans[i-1]
+	logger.Printf("⏩ Starting at zstd frame %d of %d (offset %d, %.1f%% into the input)",
+		i, len(spans), span.Offset, float64(span.Offset)*100/float64(size))
+	return span.Offset, nil
+}
+
+// ParseStartAt parses a -start-at value: a date, a date and time, or an RFC 3339 timestamp, in UTC
+// unless it carries a zone
+func ParseStartAt(value string) (time.Time, error) {
+	for _, layout := range []string{time.DateOnly, "2006-01-02T15:04", "2006-01-02T15:04:05", time.RFC3339} {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid start time %q, expected e.g. 2023-06-15, 2023-06-15T12:00 or an RFC 3339 timestamp", value)
+}
+
+// StartAtFilter drops records created before Start, which remain after seeking because a frame
+// holds earlier records as well and dumps are not strictly sorted
+type StartAtFilter struct {
+	Start time.Time
+}
+
+// Apply keeps records created at or after Start, and records without a usable created_utc
+func (f *StartAtFilter) Apply(rec *Record) (bool, error) {
+	created, ok := rec.GetInt("created_utc")
+	return !ok || created >= f.Start.Unix(), nil
+}
+
+// Name implements Named
+func (f *StartAtFilter) Name() string {
+	return "start-at"
+}
+
+// EndAtFilter drops records created at or after End
+type EndAtFilter struct {
+	End time.Time
+}
+
+// Apply keeps records created before End, and records without a usable created_utc
+func (f *EndAtFilter) Apply(rec *Record) (bool, error) {
+	created, ok := rec.GetInt("created_utc")
+	return !ok || created < f.End.Unix(), nil
+}
+
+// Name implements Named
+func (f *EndAtFilter) Name() string {
+	return "end-at"
+}
+
+// skipPartialLine discards input up to and including the first newline, for a stream that
+// starts partway through a record
+func skipPartialLine(r *bufio.Reader) error {
+	for {
+		_, err := r.ReadSlice('\n')
+		if err != bufio.ErrBufferFull {
+			return err
+		}
+	}
+}
+
+// provenance implements provenanced
+func (f *StartAtFilter) provenance() map[string]any {
+	return map[string]any{"start_at": f.Start.UTC().Format(time.RFC3339)}
+}
+
+// provenance implements provenanced
+func (f *EndAtFilter) provenance() map[string]any {
+	return map[string]any{"end_at": f.End.UTC().Format(time.RFC3339)}
+}
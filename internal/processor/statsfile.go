@@ -0,0 +1,74 @@
+package processor
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+)
+
+// WriteStatsFile saves the statistics of a run as JSON, including the distribution sketches, so
+// the statistics of many runs can be merged later
+func WriteStatsFile(path string, stats ProcessStats) error {
+	data, err := json.MarshalIndent(stats, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode statistics: %v", err)
+	}
+	if err := writeFileAtomic(path, append(data, '\n')); err != nil {
+		return fmt.Errorf("failed to write statistics: %v", err)
+	}
+	return nil
+}
+
+// ReadStatsFile loads statistics written by WriteStatsFile or, for runs that only left their
+// output behind, the statistics recorded in a manifest. Manifests hold quantile summaries rather
+// than sketches, so their distributions can't be merged and are left out.
+func ReadStatsFile(path string) (ProcessStats, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return ProcessStats{}, fmt.Errorf("failed to read statistics: %v", err)
+	}
+	var probe struct {
+		OutputPrefix *string `json:"output_prefix"`
+	}
+	if err := json.Unmarshal(data, &probe); err != nil {
+		return ProcessStats{}, fmt.Errorf("failed to parse statistics %s: %v", path, err)
+	}
+	if probe.OutputPrefix == nil {
+		var stats ProcessStats
+		if err := json.Unmarshal(data, &stats); err != nil {
+			return ProcessStats{}, fmt.Errorf("failed to parse statistics %s: %v", path, err)
+		}
+		stats.Runs = max(stats.Runs, 1)
+		return stats, nil
+	}
+
+	var m Manifest
+	if err := json.Unmarshal(data, &m); err != nil {
+		return ProcessStats{}, fmt.Errorf("failed to parse manifest %s: %v", path, err)
+	}
+	stats := ProcessStats{
+		TotalLines:       m.TotalLines,
+		MatchedLines:     m.MatchedLines,
+		SkippedLines:     m.SkippedLines,
+		Filters:          m.Filters,
+		BadLines:         m.BadLines,
+		Routes:           m.Routes,
+		InputSHA256:      m.InputSHA256,
+		Parts:            m.Parts,
+		PeakScratchBytes: m.PeakScratchBytes,
+		Stages:           m.Stages,
+		SideTables:       m.SideTables,
+		Runs:             1,
+	}
+	stats.ExecutionTime, _ = time.ParseDuration(m.ExecutionTime)
+	// An appended output's manifest describes its latest run, except for the parts and run list
+	if len(m.Runs) > 0 {
+		stats.MatchedLines, stats.SkippedLines, stats.Filters, stats.BadLines, stats.Routes = 0, 0, nil, 0, nil
+		stats.TotalLines, stats.Runs = 0, len(m.Runs)
+		for _, run := range m.Runs {
+			stats.TotalLines += run.TotalLines
+		}
+	}
+	return stats, nil
+}
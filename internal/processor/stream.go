@@ -0,0 +1,102 @@
+package processor
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"io"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// validateInputReader reports options that need an input file and can't read Options.InputReader
+func (o Options) validateInputReader() error {
+	switch {
+	case len(o.ConcatInputs) > 0:
+		return fmt.Errorf("an input stream can't be read along with concat inputs")
+	case o.Resume:
+		return fmt.Errorf("an input stream can't be resumed, as it can't be entered at a checkpoint")
+	case o.ReadAhead.Chunks > 0 || o.IOHints || o.WaitForData > 0:
+		return fmt.Errorf("read-ahead, I/O hints and waiting for data only apply to input files")
+	}
+	return nil
+}
+
+// nopCloser closes nothing, for streams the caller owns
+type nopCloser struct{}
+
+func (nopCloser) Close() error { return nil }
+
+// openStreamInput reads JSON lines from a stream, decompressing it when it starts with a zstd
+// frame. The stream is hashed as it is read, like an input file, and is left open for the caller.
+func openStreamInput(r io.Reader, opts inputOptions) (*zstInput, error) {
+	logger := loggerOrDefault(opts.logger)
+	hasher := sha256.New()
+	compressed := &timedReader{r: r}
+	peek := bufio.NewReaderSize(io.TeeReader(compressed, hasher), 256*1024)
+	magic, err := peek.Peek(4)
+	if err != nil && err != io.EOF {
+		return nil, fmt.Errorf("failed to read input: %v", err)
+	}
+
+	var content io.Reader = peek
+	var frames *frameReader
+	var zr *zstd.Decoder
+	if len(magic) == 4 && binary.LittleEndian.Uint32(magic) == zstdFrameMagic {
+		frames = newFrameReader(peek, logger)
+		if zr, err = zstd.NewReader(frames); err != nil {
+			return nil, fmt.Errorf("failed to create zstd reader: %v", err)
+		}
+		content = zr
+	}
+	if !opts.startAt.IsZero() {
+		warnf(logger, "⚠️ Warning: An input stream can't be seeked to the start time, its earlier records are only filtered out")
+	}
+	decompressed := &timedReader{r: content}
+	return &zstInput{
+		file:         nopCloser{},
+		frames:       frames,
+		zr:           zr,
+		hasher:       hasher,
+		compressed:   compressed,
+		decompressed: decompressed,
+		lines:        &lineSplitter{loneCR: opts.lineEndings != LineEndingsLF, logger: logger},
+		Reader:       bufio.NewReaderSize(decompressed, bufferSize),
+	}, nil
+}
+
+// JSONLSink writes records as JSON lines to a stream, for callers consuming the processed records
+// directly instead of Parquet parts
+type JSONLSink struct {
+	w *bufio.Writer
+	// Written counts the records written
+	Written int64
+}
+
+// NewJSONLSink returns a sink writing to w. Closing the sink flushes it but leaves w open.
+func NewJSONLSink(w io.Writer) *JSONLSink {
+	return &JSONLSink{w: bufio.NewWriterSize(w, 1024*1024)}
+}
+
+// WriteBatch writes each record on a line of its own
+func (j *JSONLSink) WriteBatch(recs []*Record) error {
+	for _, rec := range recs {
+		if _, err := j.w.Write(rec.Bytes()); err != nil {
+			return fmt.Errorf("failed to write records: %v", err)
+		}
+		if err := j.w.WriteByte('\n'); err != nil {
+			return fmt.Errorf("failed to write records: %v", err)
+		}
+		j.Written++
+	}
+	return nil
+}
+
+// Close flushes the records still buffered
+func (j *JSONLSink) Close() error {
+	if err := j.w.Flush(); err != nil {
+		return fmt.Errorf("failed to write records: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,70 @@
+package processor
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+)
+
+// Subreddit metadata columns
+const (
+	SubredditSubscribersColumn = "subreddit_subscribers"
+	SubredditCreatedColumn     = "subreddit_created_utc"
+	SubredditDescriptionColumn = "subreddit_description"
+)
+
+// SubredditMetadataTransform annotates records with the subscriber count, creation time and
+// public description of their subreddit, from a Pushshift subreddit metadata dump joined on
+// subreddit_id. The dump is a snapshot, so subscriber counts are those at its capture time.
+type SubredditMetadataTransform struct {
+	*JoinTransform
+}
+
+// LoadSubredditMetadata reads a subreddit metadata dump (.zst JSON lines as in the Pushshift
+// subreddits files), moving it to an on-disk index once it exceeds memoryLimit bytes. Messages go
+// to logger, or the standard logger when nil.
+func LoadSubredditMetadata(dumpPath string, memoryLimit int64, logger *log.Logger) (*SubredditMetadataTransform, error) {
+	columns := []string{SubredditSubscribersColumn, SubredditCreatedColumn, SubredditDescriptionColumn}
+	var subreddits int64
+	join, err := newJoinTransform("subreddit_id", columns, memoryLimit, func(fn func(key string, values []string) error) error {
+		return scanRecords(dumpPath, logger, func(rec *Record) error {
+			key, _ := rec.GetString("name")
+			if key == "" {
+				id, _ := rec.GetString("id")
+				if id == "" {
+					return nil
+				}
+				key = "t5_" + id
+			}
+			values := make([]string, len(columns))
+			if n, ok := rec.GetInt("subscribers"); ok {
+				values[0] = strconv.FormatInt(n, 10)
+			}
+			if n, ok := rec.GetInt("created_utc"); ok {
+				values[1] = strconv.FormatInt(n, 10)
+			}
+			values[2], _ = rec.GetString("public_description")
+			subreddits++
+			return fn(key, values)
+		})
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to load subreddit metadata %s: %v", dumpPath, err)
+	}
+	where := "memory"
+	if join.memory == nil {
+		where = "an on-disk index"
+	}
+	loggerOrDefault(logger).Printf("🏘️ Loaded metadata of %d subreddits from %s into %s", subreddits, dumpPath, where)
+	return &SubredditMetadataTransform{JoinTransform: join}, nil
+}
+
+// ParquetColumns types the numeric columns, which the join stores as text and leaves empty when
+// the dump lacks them
+func (t *SubredditMetadataTransform) ParquetColumns() map[string]string {
+	return map[string]string{
+		SubredditSubscribersColumn: "TRY_CAST(" + SubredditSubscribersColumn + " AS BIGINT)",
+		SubredditCreatedColumn:     "TRY_CAST(" + SubredditCreatedColumn + " AS BIGINT)",
+		SubredditDescriptionColumn: "CAST(" + SubredditDescriptionColumn + " AS VARCHAR)",
+	}
+}
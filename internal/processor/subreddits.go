@@ -0,0 +1,99 @@
+package processor
+
+import (
+	"slices"
+	"strings"
+	"sync"
+)
+
+// maxLoggedSubreddits limits how many pattern-matched subreddits are listed when the filter closes
+const maxLoggedSubreddits = 20
+
+// SubredditFilter keeps only records of the listed subreddits, ignoring case. Entries with glob
+// characters (ask*, *politics*) or written as /expr/ are patterns, resolved against the
+// subreddits streamed from the input: each distinct subreddit is matched once and the decision
+// cached.
+type SubredditFilter struct {
+	// entries are the subreddits and patterns the filter was created with
+	entries    []string
+	subreddits map[string]struct{}
+	patterns   []namePattern
+	// matches caches the decision per subreddit seen by the patterns
+	matches sync.Map
+
+	runLogger
+}
+
+// NewSubredditFilter creates the filter for the subreddit names and patterns given
+func NewSubredditFilter(entries []string) (*SubredditFilter, error) {
+	f := &SubredditFilter{entries: entries, subreddits: make(map[string]struct{}, len(entries))}
+	var patterns []string
+	for _, entry := range entries {
+		switch {
+		case len(entry) > 2 && strings.HasPrefix(entry, "/") && strings.HasSuffix(entry, "/"):
+			patterns = append(patterns, "/(?i)"+entry[1:])
+		case strings.ContainsAny(entry, "*?["):
+			patterns = append(patterns, strings.ToLower(entry))
+		default:
+			f.subreddits[normalizeSubreddit(entry)] = struct{}{}
+		}
+	}
+	var err error
+	if f.patterns, err = parseNamePatterns(patterns, "subreddit"); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// kept reports whether records of a subreddit are kept
+func (f *SubredditFilter) kept(subreddit string) bool {
+	subreddit = normalizeSubreddit(subreddit)
+	if _, ok := f.subreddits[subreddit]; ok {
+		return true
+	}
+	if len(f.patterns) == 0 {
+		return false
+	}
+	if cached, ok := f.matches.Load(subreddit); ok {
+		return cached.(bool)
+	}
+	keep := matchesAny(f.patterns, subreddit)
+	f.matches.Store(subreddit, keep)
+	return keep
+}
+
+// Apply drops records of other subreddits
+func (f *SubredditFilter) Apply(rec *Record) (bool, error) {
+	subreddit, _ := rec.GetString("subreddit")
+	return f.kept(subreddit), nil
+}
+
+// Name implements Named
+func (f *SubredditFilter) Name() string {
+	return "subreddits"
+}
+
+// Close reports which subreddits the patterns matched
+func (f *SubredditFilter) Close() error {
+	if len(f.patterns) > 0 {
+		var matched []string
+		f.matches.Range(func(name, keep any) bool {
+			if keep.(bool) {
+				matched = append(matched, name.(string))
+			}
+			return true
+		})
+		slices.Sort(matched)
+		list := strings.Join(matched[:min(len(matched), maxLoggedSubreddits)], ", ")
+		if len(matched) > maxLoggedSubreddits {
+			list += ", ..."
+		}
+		loggerOrDefault(f.logger).Printf("🏷️ Subreddit patterns matched %d subreddits: %s", len(matched), list)
+	}
+	return nil
+}
+
+// provenance implements provenanced
+func (f *SubredditFilter) provenance() map[string]any {
+	return map[string]any{"subreddits": f.entries}
+}
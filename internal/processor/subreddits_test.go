@@ -0,0 +1,56 @@
+package processor
+
+import "testing"
+
+func TestSubredditFilter(t *testing.T) {
+	tests := []struct {
+		name      string
+		entries   []string
+		subreddit string
+		keep      bool
+	}{
+		{"name", []string{"golang"}, "golang", true},
+		{"name ignoring case", []string{"GoLang"}, "golang", true},
+		{"record ignoring case", []string{"golang"}, "GoLang", true},
+		{"r/ prefix", []string{"r/golang"}, "golang", true},
+		{"/r/ prefix", []string{"/r/golang"}, "golang", true},
+		{"other name", []string{"golang"}, "rust", false},
+		{"name is not a prefix", []string{"go"}, "golang", false},
+		{"glob", []string{"ask*"}, "AskReddit", true},
+		{"glob miss", []string{"ask*"}, "reddit", false},
+		{"glob inside", []string{"*politics*"}, "worldpolitics", true},
+		{"single character glob", []string{"pic?"}, "pics", true},
+		{"character class", []string{"r[0-9]"}, "r2", true},
+		{"regexp", []string{"/^(cats|dogs)$/"}, "Cats", true},
+		{"regexp miss", []string{"/^(cats|dogs)$/"}, "catsanddogs", false},
+		{"names and patterns", []string{"golang", "ask*"}, "askscience", true},
+		{"missing subreddit", []string{"golang"}, "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, err := NewSubredditFilter(tt.entries)
+			if err != nil {
+				t.Fatal(err)
+			}
+			record := `{"id":"a"}`
+			if tt.subreddit != "" {
+				record = `{"subreddit":"` + tt.subreddit + `"}`
+			}
+			// The second call answers from the cache of pattern matches
+			for range 2 {
+				keep, err := f.Apply(NewRecord([]byte(record)))
+				if err != nil || keep != tt.keep {
+					t.Errorf("got %v, %v, want %v", keep, err, tt.keep)
+				}
+			}
+		})
+	}
+}
+
+func TestSubredditFilterInvalid(t *testing.T) {
+	for _, entries := range [][]string{{"/(/"}, {"ask["}} {
+		if _, err := NewSubredditFilter(entries); err == nil {
+			t.Errorf("%q: got no error", entries)
+		}
+	}
+}
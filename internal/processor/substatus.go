@@ -0,0 +1,118 @@
+package processor
+
+import (
+	_ "embed"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+//go:embed data/subreddit_status.csv
+var bundledSubredditStatus string
+
+// SubredditStatusColumn holds a record's subreddit status when it was created
+const SubredditStatusColumn = "subreddit_status_at_post"
+
+// subredditEvent is a change of a subreddit's status
+type subredditEvent struct {
+	at     int64
+	status string
+}
+
+// SubredditStatusTransform adds subreddit_status_at_post: the status (quarantined, banned, ...)
+// the record's subreddit had when the record was created, from dated status changes. Subreddits
+// without a change by then are "active"; a change back to active can be listed as well.
+type SubredditStatusTransform struct {
+	events map[string][]subredditEvent
+}
+
+// NewSubredditStatusTransform builds the transform from the bundled list of status changes. A
+// user-supplied subreddit,status,effective_date CSV adds subreddits and replaces the bundled
+// history of those it lists.
+func NewSubredditStatusTransform(userListPath string) (*SubredditStatusTransform, error) {
+	t := &SubredditStatusTransform{events: make(map[string][]subredditEvent)}
+	bundled, err := loadSubredditEvents(strings.NewReader(bundledSubredditStatus))
+	if err != nil {
+		return nil, fmt.Errorf("failed to load bundled subreddit status list: %v", err)
+	}
+	for subreddit, events := range bundled {
+		t.events[subreddit] = events
+	}
+
+	if userListPath != "" {
+		f, err := os.Open(userListPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open subreddit status list: %v", err)
+		}
+		defer f.Close()
+		user, err := loadSubredditEvents(f)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load subreddit status list %s: %v", userListPath, err)
+		}
+		for subreddit, events := range user {
+			t.events[subreddit] = events
+		}
+	}
+	return t, nil
+}
+
+// loadSubredditEvents reads subreddit,status,effective_date rows, skipping a header row if
+// present, into each subreddit's changes in date order
+func loadSubredditEvents(r io.Reader) (map[string][]subredditEvent, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = 3
+	events := make(map[string][]subredditEvent)
+	for first := true; ; first = false {
+		row, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if first && strings.EqualFold(row[0], "subreddit") {
+			continue
+		}
+		date, err := time.Parse(time.DateOnly, strings.TrimSpace(row[2]))
+		if err != nil {
+			return nil, fmt.Errorf("invalid effective date %q for %s, expected YYYY-MM-DD", row[2], row[0])
+		}
+		subreddit := normalizeSubreddit(row[0])
+		events[subreddit] = append(events[subreddit], subredditEvent{at: date.Unix(), status: strings.TrimSpace(row[1])})
+	}
+	for _, list := range events {
+		sort.SliceStable(list, func(i, j int) bool { return list[i].at < list[j].at })
+	}
+	return events, nil
+}
+
+// Status returns a subreddit's status at a Unix time
+func (t *SubredditStatusTransform) Status(subreddit string, at int64) string {
+	status := "active"
+	for _, event := range t.events[normalizeSubreddit(subreddit)] {
+		if event.at > at {
+			break
+		}
+		status = event.status
+	}
+	return status
+}
+
+// Apply sets subreddit_status_at_post, null when the subreddit or creation time is missing
+func (t *SubredditStatusTransform) Apply(rec *Record) (bool, error) {
+	subreddit, ok := rec.GetString("subreddit")
+	created, hasCreated := rec.GetInt("created_utc")
+	if !ok || !hasCreated {
+		return true, rec.SetRaw(SubredditStatusColumn, []byte("null"))
+	}
+	return true, rec.Set(SubredditStatusColumn, t.Status(subreddit, created))
+}
+
+// ParquetColumns types the status column, which may be all null in a part
+func (t *SubredditStatusTransform) ParquetColumns() map[string]string {
+	return map[string]string{SubredditStatusColumn: "CAST(" + SubredditStatusColumn + " AS VARCHAR)"}
+}
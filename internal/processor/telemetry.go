@@ -0,0 +1,145 @@
+package processor
+
+import (
+	"fmt"
+	"io"
+	"sync/atomic"
+	"time"
+)
+
+// StageTelemetry breaks a run's time down by pipeline stage so bottlenecks are visible instead of
+// hiding behind one blended throughput number
+type StageTelemetry struct {
+	// ReadTime is spent reading the compressed input from disk. The decoder reads ahead in the
+	// background, so this overlaps with the other stages.
+	CompressedBytes int64         `json:"compressed_bytes"`
+	ReadTime        time.Duration `json:"read_ns"`
+	// DecompressTime is how long the pipeline waited for decompressed data, disk reads included;
+	// it stays small when decompression keeps up with processing
+	DecompressedBytes int64         `json:"decompressed_bytes"`
+	DecompressTime    time.Duration `json:"decompress_ns"`
+	// ProcessTime covers line scanning, parsing and transforms
+	Records     int64         `json:"records"`
+	ProcessTime time.Duration `json:"process_ns"`
+	// WriteTime is spent writing output (part files or sink batches)
+	WrittenBytes int64         `json:"written_bytes"`
+	WriteTime    time.Duration `json:"write_ns"`
+	// ConvertTime is spent converting parts to Parquet
+	PartsConverted int           `json:"parts_converted"`
+	ConvertTime    time.Duration `json:"convert_ns"`
+	// Queues describe the bounded queues between the read, transform and output stages
+	Queues []QueueTelemetry `json:"queues,omitempty"`
+}
+
+// addQueue merges the telemetry of a queue into that of the queue with the same name
+func (t *StageTelemetry) addQueue(q QueueTelemetry) {
+	for i := range t.Queues {
+		if t.Queues[i].Name == q.Name {
+			t.Queues[i].add(q)
+			return
+		}
+	}
+	t.Queues = append(t.Queues, q)
+}
+
+// Add adds the counters and times of another run
+func (t *StageTelemetry) Add(other StageTelemetry) {
+	t.CompressedBytes += other.CompressedBytes
+	t.ReadTime += other.ReadTime
+	t.DecompressedBytes += other.DecompressedBytes
+	t.DecompressTime += other.DecompressTime
+	t.Records += other.Records
+	t.ProcessTime += other.ProcessTime
+	t.WrittenBytes += other.WrittenBytes
+	t.WriteTime += other.WriteTime
+	t.PartsConverted += other.PartsConverted
+	t.ConvertTime += other.ConvertTime
+	for _, q := range other.Queues {
+		t.addQueue(q)
+	}
+}
+
+// CompressionRatio returns decompressed bytes per compressed byte
+func (t StageTelemetry) CompressionRatio() float64 {
+	if t.CompressedBytes == 0 {
+		return 0
+	}
+	return float64(t.DecompressedBytes) / float64(t.CompressedBytes)
+}
+
+// rate returns amount per second, or 0 for an empty duration
+func rate(amount float64, d time.Duration) float64 {
+	if d <= 0 {
+		return 0
+	}
+	return amount / d.Seconds()
+}
+
+// String formats one line per stage for the statistics output
+func (t StageTelemetry) String() string {
+	const mb = 1024 * 1024
+	out := fmt.Sprintf("    read: %.0f MB in %s (%.1f MB/s)",
+		float64(t.CompressedBytes)/mb, t.ReadTime.Round(time.Millisecond), rate(float64(t.CompressedBytes)/mb, t.ReadTime))
+	out += fmt.Sprintf("\n    decompress: %.0f MB, waited %s (%.1f MB/s, ratio %.1fx)",
+		float64(t.DecompressedBytes)/mb, t.DecompressTime.Round(time.Millisecond),
+		rate(float64(t.DecompressedBytes)/mb, t.DecompressTime), t.CompressionRatio())
+	out += fmt.Sprintf("\n    parse/transform: %s records in %s (%.0f records/s)",
+		formatCount(t.Records), t.ProcessTime.Round(time.Millisecond), rate(float64(t.Records), t.ProcessTime))
+	if t.WrittenBytes > 0 {
+		out += fmt.Sprintf("\n    write: %.0f MB in %s (%.1f MB/s)",
+			float64(t.WrittenBytes)/mb, t.WriteTime.Round(time.Millisecond), rate(float64(t.WrittenBytes)/mb, t.WriteTime))
+	} else {
+		out += fmt.Sprintf("\n    write: %s", t.WriteTime.Round(time.Millisecond))
+	}
+	if t.PartsConverted > 0 {
+		out += fmt.Sprintf("\n    convert: %d parts in %s (%s per part)", t.PartsConverted,
+			t.ConvertTime.Round(time.Millisecond), (t.ConvertTime / time.Duration(t.PartsConverted)).Round(time.Millisecond))
+	}
+	for _, q := range t.Queues {
+		out += fmt.Sprintf("\n    %s queue: mean %.1f, peak %d of %d batches, producer blocked %s, consumer waited %s",
+			q.Name, q.MeanDepth, q.PeakDepth, q.Capacity, q.ProducerWait.Round(time.Millisecond), q.ConsumerWait.Round(time.Millisecond))
+	}
+	return out
+}
+
+// timedReader measures the bytes read and time spent in an underlying reader. Counters are
+// atomic because the zstd decoder reads its input from background goroutines.
+type timedReader struct {
+	r     io.Reader
+	bytes atomic.Int64
+	nanos atomic.Int64
+}
+
+// Read implements io.Reader
+func (t *timedReader) Read(p []byte) (int, error) {
+	start := time.Now()
+	n, err := t.r.Read(p)
+	t.nanos.Add(int64(time.Since(start)))
+	t.bytes.Add(int64(n))
+	return n, err
+}
+
+// ReadAt implements io.ReaderAt when the underlying reader does
+func (t *timedReader) ReadAt(p []byte, offset int64) (int, error) {
+	start := time.Now()
+	n, err := t.r.(io.ReaderAt).ReadAt(p, offset)
+	t.nanos.Add(int64(time.Since(start)))
+	t.bytes.Add(int64(n))
+	return n, err
+}
+
+// timedWriter measures the bytes written and time spent in an underlying writer
+type timedWriter struct {
+	w     io.Writer
+	bytes int64
+	time  time.Duration
+}
+
+// Write implements io.Writer
+func (t *timedWriter) Write(p []byte) (int, error) {
+	start := time.Now()
+	n, err := t.w.Write(p)
+	t.time += time.Since(start)
+	t.bytes += int64(n)
+	return n, err
+}
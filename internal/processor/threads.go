@@ -0,0 +1,244 @@
+package processor
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+)
+
+// ThreadTableName names the side table of per-thread aggregates
+const ThreadTableName = "threads"
+
+// threadRow is a row of the threads table: the comments of one submission, aggregated
+type threadRow struct {
+	LinkID           string   `json:"link_id"`
+	Subreddit        string   `json:"subreddit"`
+	Comments         int64    `json:"comments"`
+	TopLevelComments int64    `json:"top_level_comments"`
+	UniqueCommenters int64    `json:"unique_commenters"`
+	FirstCommentUTC  *int64   `json:"first_comment_utc"`
+	LastCommentUTC   *int64   `json:"last_comment_utc"`
+	ScoreSum         *int64   `json:"score_sum"`
+	ScoreMean        *float64 `json:"score_mean"`
+	ScoreMin         *int64   `json:"score_min"`
+	ScoreMax         *int64   `json:"score_max"`
+}
+
+// threadSpillEntries is how many threads and distinct thread commenters are aggregated in memory
+// before the partial aggregates are moved to the on-disk store
+const threadSpillEntries = 1 << 21
+
+// threadAggregate holds the partial aggregates of one thread
+type threadAggregate struct {
+	subreddit  string
+	comments   int64
+	topLevel   int64
+	first      *int64
+	last       *int64
+	scoreSum   int64
+	scoreCount int64
+	scoreMin   *int64
+	scoreMax   *int64
+	authors    map[string]struct{}
+}
+
+// ThreadTableTransform aggregates comments by link_id into the threads side table: comment
+// counts, unique commenters, first and last comment time and score statistics per thread. It
+// sees the records as they are written, after filters, and passes them through unchanged.
+//
+// Threads are aggregated in memory; when too many are open the partial aggregates move to a
+// temporary on-disk store, as a monthly dump has millions of threads, and are merged into the
+// table once the input has been read.
+type ThreadTableTransform struct {
+	table *SideTable
+
+	mu      sync.Mutex
+	open    map[string]*threadAggregate
+	entries int
+	db      *sql.DB
+	dbPath  string
+	threads int64
+
+	runLogger
+}
+
+// NewThreadTableTransform creates the transform writing <outputPrefix>_threads.parquet
+func NewThreadTableTransform(outputPrefix string) (*ThreadTableTransform, error) {
+	t := &ThreadTableTransform{
+		table: NewSideTable(outputPrefix, ThreadTableName, map[string]string{
+			"first_comment_utc": "CAST(first_comment_utc AS BIGINT)",
+			"last_comment_utc":  "CAST(last_comment_utc AS BIGINT)",
+			"score_sum":         "CAST(score_sum AS BIGINT)",
+			"score_mean":        "CAST(score_mean AS DOUBLE)",
+			"score_min":         "CAST(score_min AS BIGINT)",
+			"score_max":         "CAST(score_max AS BIGINT)",
+		}),
+		open: make(map[string]*threadAggregate),
+	}
+	var err error
+	t.db, t.dbPath, err = openTempSQLite("pushshift-threads-*.db", `CREATE TABLE partials (
+		link_id TEXT NOT NULL, subreddit TEXT NOT NULL, comments INTEGER NOT NULL, top_level INTEGER NOT NULL,
+		first_utc INTEGER, last_utc INTEGER, score_sum INTEGER NOT NULL, score_count INTEGER NOT NULL,
+		score_min INTEGER, score_max INTEGER);
+		CREATE TABLE authors (link_id TEXT NOT NULL, author TEXT NOT NULL)`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create thread store: %v", err)
+	}
+	return t, nil
+}
+
+// Apply adds a comment to its thread's aggregates; submissions are ignored
+func (t *ThreadTableTransform) Apply(rec *Record) (bool, error) {
+	linkID, ok := rec.GetString("link_id")
+	if !ok || linkID == "" {
+		return true, nil
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	thread := t.open[linkID]
+	if thread == nil {
+		thread = &threadAggregate{authors: make(map[string]struct{})}
+		thread.subreddit, _ = rec.GetString("subreddit")
+		t.open[linkID] = thread
+		t.entries++
+	}
+	thread.comments++
+	if parent, _ := rec.GetString("parent_id"); strings.HasPrefix(parent, "t3_") {
+		thread.topLevel++
+	}
+	if created, ok := rec.GetInt("created_utc"); ok {
+		thread.first = minPtr(thread.first, created)
+		thread.last = maxPtr(thread.last, created)
+	}
+	if score, ok := rec.GetInt("score"); ok {
+		thread.scoreSum += score
+		thread.scoreCount++
+		thread.scoreMin = minPtr(thread.scoreMin, score)
+		thread.scoreMax = maxPtr(thread.scoreMax, score)
+	}
+	if author, _ := rec.GetString("author"); !removedTexts[author] {
+		author = normalizeAuthor(author)
+		if _, seen := thread.authors[author]; !seen {
+			thread.authors[author] = struct{}{}
+			t.entries++
+		}
+	}
+	if t.entries >= threadSpillEntries {
+		if err := t.spill(); err != nil {
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+// minPtr returns the smaller of an optional current value and v
+func minPtr(current *int64, v int64) *int64 {
+	if current != nil && *current <= v {
+		return current
+	}
+	return &v
+}
+
+// maxPtr returns the larger of an optional current value and v
+func maxPtr(current *int64, v int64) *int64 {
+	if current != nil && *current >= v {
+		return current
+	}
+	return &v
+}
+
+// spill moves the open partial aggregates to the on-disk store
+func (t *ThreadTableTransform) spill() error {
+	tx, err := t.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to write thread store: %v", err)
+	}
+	defer tx.Rollback()
+	partial, err := tx.Prepare(`INSERT INTO partials VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`)
+	if err != nil {
+		return fmt.Errorf("failed to write thread store: %v", err)
+	}
+	author, err := tx.Prepare(`INSERT INTO authors VALUES (?, ?)`)
+	if err != nil {
+		return fmt.Errorf("failed to write thread store: %v", err)
+	}
+	for linkID, thread := range t.open {
+		if _, err := partial.Exec(linkID, thread.subreddit, thread.comments, thread.topLevel, thread.first, thread.last,
+			thread.scoreSum, thread.scoreCount, thread.scoreMin, thread.scoreMax); err != nil {
+			return fmt.Errorf("failed to write thread store: %v", err)
+		}
+		for name := range thread.authors {
+			if _, err := author.Exec(linkID, name); err != nil {
+				return fmt.Errorf("failed to write thread store: %v", err)
+			}
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to write thread store: %v", err)
+	}
+	t.open = make(map[string]*threadAggregate)
+	t.entries = 0
+	return nil
+}
+
+// finishSideTables merges the partial aggregates and writes a table row per thread, ordered by
+// the time of its first comment
+func (t *ThreadTableTransform) finishSideTables() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	loggerOrDefault(t.logger).Printf("🧵 Aggregating threads")
+	if err := t.spill(); err != nil {
+		return err
+	}
+	rows, err := t.db.Query(`SELECT p.link_id, p.subreddit, p.comments, p.top_level, coalesce(a.authors, 0),
+			p.first_utc, p.last_utc, p.score_sum, p.score_count, p.score_min, p.score_max
+		FROM (SELECT link_id, min(subreddit) AS subreddit, sum(comments) AS comments, sum(top_level) AS top_level,
+				min(first_utc) AS first_utc, max(last_utc) AS last_utc, sum(score_sum) AS score_sum,
+				sum(score_count) AS score_count, min(score_min) AS score_min, max(score_max) AS score_max
+			FROM partials GROUP BY link_id) AS p
+		LEFT JOIN (SELECT link_id, count(DISTINCT author) AS authors FROM authors GROUP BY link_id) AS a
+			ON a.link_id = p.link_id
+		ORDER BY p.first_utc, p.link_id`)
+	if err != nil {
+		return fmt.Errorf("failed to read thread store: %v", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var row threadRow
+		var scoreSum, scoreCount int64
+		if err := rows.Scan(&row.LinkID, &row.Subreddit, &row.Comments, &row.TopLevelComments, &row.UniqueCommenters,
+			&row.FirstCommentUTC, &row.LastCommentUTC, &scoreSum, &scoreCount, &row.ScoreMin, &row.ScoreMax); err != nil {
+			return fmt.Errorf("failed to read thread store: %v", err)
+		}
+		if scoreCount > 0 {
+			mean := float64(scoreSum) / float64(scoreCount)
+			row.ScoreSum, row.ScoreMean = &scoreSum, &mean
+		}
+		if err := t.table.writeRow(row); err != nil {
+			return err
+		}
+		t.threads++
+	}
+	return rows.Err()
+}
+
+// SideTables returns the threads table
+func (t *ThreadTableTransform) SideTables() []*SideTable {
+	return []*SideTable{t.table}
+}
+
+// Close reports how many threads were written and removes the temporary store
+func (t *ThreadTableTransform) Close() error {
+	if t.threads > 0 {
+		loggerOrDefault(t.logger).Printf("🧵 Aggregated %d threads", t.threads)
+	}
+	err := t.db.Close()
+	os.Remove(t.dbPath)
+	return errors.Join(err, t.table.Close())
+}
@@ -0,0 +1,94 @@
+package processor
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"os"
+	"syscall"
+	"time"
+)
+
+// tolerantPollInterval is how often a stalled read checks for new data
+const tolerantPollInterval = 2 * time.Second
+
+// tolerantFile reads an input that is still being downloaded, such as a file on a torrent
+// streaming mount or a sparse file a torrent client fills in piece by piece. Both report the full
+// file size up front, so the end of the file is final; instead of failing or decoding zeros it
+// waits for data on:
+//
+//   - EAGAIN, EINTR and EIO errors, which FUSE mounts return for pieces not yet available
+//   - holes in a sparse file, detected with SEEK_DATA where the filesystem supports it
+//
+// A stall that lasts longer than maxWait fails the read.
+type tolerantFile struct {
+	f       *os.File
+	maxWait time.Duration
+	logger  *log.Logger
+}
+
+// ReadAt implements io.ReaderAt, filling p unless the file ends first
+func (t *tolerantFile) ReadAt(p []byte, offset int64) (int, error) {
+	total := 0
+	for total < len(p) {
+		n, err := t.readSome(p[total:], offset+int64(total))
+		total += n
+		if err != nil {
+			return total, err
+		}
+	}
+	return total, nil
+}
+
+// readSome reads at least one byte at offset, waiting for missing data
+func (t *tolerantFile) readSome(p []byte, offset int64) (int, error) {
+	var stalled time.Time
+	for {
+		n, reason, err := t.tryRead(p, offset)
+		if reason == "" {
+			if !stalled.IsZero() {
+				t.logger.Printf("▶️ Input data at offset %d available after %s", offset, time.Since(stalled).Round(time.Second))
+			}
+			return n, err
+		}
+
+		if stalled.IsZero() {
+			stalled = time.Now()
+			t.logger.Printf("⏳ Waiting for input data at offset %d (%s)", offset, reason)
+		} else if time.Since(stalled) > t.maxWait {
+			// A file error, so the input isn't reported as corrupt
+			return n, &fs.PathError{Op: "read", Path: t.f.Name(), Err: fmt.Errorf("no input data at offset %d after waiting %s (%s)", offset, t.maxWait, reason)}
+		}
+		time.Sleep(tolerantPollInterval)
+	}
+}
+
+// tryRead attempts one read, returning a non-empty reason when it should be retried later
+func (t *tolerantFile) tryRead(p []byte, offset int64) (int, string, error) {
+	if present, end, ok := dataExtent(t.f, offset); ok {
+		if !present {
+			if info, err := t.f.Stat(); err == nil && offset >= info.Size() {
+				return 0, "", io.EOF
+			}
+			return 0, "hole in a sparse file", nil
+		}
+		// Stop at the next hole so its zeros are never returned as data
+		if end-offset < int64(len(p)) {
+			p = p[:end-offset]
+		}
+	}
+
+	n, err := t.f.ReadAt(p, offset)
+	switch {
+	case n > 0:
+		if err == io.EOF {
+			err = nil
+		}
+		return n, "", err
+	case errors.Is(err, syscall.EAGAIN), errors.Is(err, syscall.EINTR), errors.Is(err, syscall.EIO):
+		return 0, err.Error(), err
+	}
+	return n, "", err
+}
@@ -0,0 +1,134 @@
+package processor
+
+import (
+	"errors"
+	"io"
+	"maps"
+	"reflect"
+)
+
+// Transform modifies or drops records on their way from the input to the part files
+type Transform interface {
+	// Apply may modify rec in place and reports whether the record should be kept
+	Apply(rec *Record) (bool, error)
+}
+
+// BatchTransform processes records in groups, for enrichments that call external services
+// where per-record round trips would be too slow
+type BatchTransform interface {
+	// ApplyBatch may modify records in place and reports for each whether it should be kept
+	ApplyBatch(recs []*Record) ([]bool, error)
+	// BatchSize is the number of records the processor should buffer before calling ApplyBatch
+	BatchSize() int
+}
+
+// ParquetTyped is implemented by transforms that add columns needing an explicit Parquet type.
+// The processor merges their expressions into Options.ParquetColumns during conversion.
+type ParquetTyped interface {
+	ParquetColumns() map[string]string
+}
+
+// parquetColumns merges the configured column expressions with those of the transforms. Columns
+// that -fields, -drop-fields or the redaction policy remove after the transform adding them are
+// left out, since the conversion would fail on an expression naming a column the parts don't
+// have, and so are columns the policy hashes into strings, which the expressions couldn't cast.
+func (s *PushshiftProcessor) parquetColumns() map[string]string {
+	columns := make(map[string]string)
+	for _, t := range s.Options.Transforms {
+		switch t := t.(type) {
+		case *SelectFieldsTransform:
+			maps.DeleteFunc(columns, func(name, _ string) bool { return !t.Keeps(name) })
+		case *DropFieldsTransform:
+			maps.DeleteFunc(columns, func(name, _ string) bool { return t.Drops(name) })
+		case *RedactionTransform:
+			maps.DeleteFunc(columns, func(name, _ string) bool { return t.rewrites(name) })
+		}
+		if typed, ok := t.(ParquetTyped); ok {
+			for name, expr := range typed.ParquetColumns() {
+				columns[name] = expr
+			}
+		}
+	}
+	for name, expr := range s.Options.ParquetColumns {
+		columns[name] = expr
+	}
+	return columns
+}
+
+// Named is implemented by filters and transforms that name themselves in statistics, usually
+// after the option configuring them. Others are named after their type.
+type Named interface {
+	Name() string
+}
+
+// transformName returns the name of a filter or transform in statistics
+func transformName(t Transform) string {
+	if named, ok := t.(Named); ok {
+		return named.Name()
+	}
+	typ := reflect.TypeOf(t)
+	for typ.Kind() == reflect.Pointer {
+		typ = typ.Elem()
+	}
+	if typ.Name() == "" {
+		return typ.String()
+	}
+	return typ.Name()
+}
+
+// applyCounted runs rec through each transform in order, stopping at the first drop. It counts
+// the records each transform saw in seen and those it dropped in dropped, and returns the index of
+// the transform that dropped rec or failed on it, or -1 when all of them kept it.
+func applyCounted(transforms []Transform, rec *Record, seen, dropped []int64) (int, error) {
+	for i, t := range transforms {
+		seen[i]++
+		keep, err := t.Apply(rec)
+		if err != nil {
+			return i, err
+		}
+		if !keep {
+			dropped[i]++
+			return i, nil
+		}
+	}
+	return -1, nil
+}
+
+// applyBatchTransforms runs recs through each batch transform in order and returns the records kept
+func applyBatchTransforms(transforms []BatchTransform, recs []*Record) ([]*Record, error) {
+	kept := recs
+	for _, t := range transforms {
+		keep, err := t.ApplyBatch(kept)
+		if err != nil {
+			return nil, err
+		}
+		next := make([]*Record, 0, len(kept))
+		for i, rec := range kept {
+			if keep[i] {
+				next = append(next, rec)
+			}
+		}
+		kept = next
+	}
+	return kept, nil
+}
+
+// batchTransformSize returns the largest batch size requested by the transforms, or 0 without any
+func batchTransformSize(transforms []BatchTransform) int {
+	size := 0
+	for _, t := range transforms {
+		size = max(size, t.BatchSize(), 1)
+	}
+	return size
+}
+
+// CloseTransforms releases resources held by transforms that implement io.Closer
+func CloseTransforms[T any](transforms []T) error {
+	var errs []error
+	for _, t := range transforms {
+		if c, ok := any(t).(io.Closer); ok {
+			errs = append(errs, c.Close())
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,56 @@
+package processor
+
+import (
+	"reflect"
+	"testing"
+)
+
+// typedTransform adds columns with the given expressions
+type typedTransform map[string]string
+
+func (t typedTransform) Apply(*Record) (bool, error)       { return true, nil }
+func (t typedTransform) ParquetColumns() map[string]string { return t }
+
+func TestParquetColumns(t *testing.T) {
+	selectFields := func(patterns ...string) Transform {
+		t, err := NewSelectFieldsTransform(patterns)
+		if err != nil {
+			panic(err)
+		}
+		return t
+	}
+	redact := func(rules ...RedactionRule) Transform {
+		t, err := NewRedactionTransform(&RedactionPolicy{Rules: rules})
+		if err != nil {
+			panic(err)
+		}
+		return t
+	}
+	created := typedTransform{"created_iso": "CAST(created_iso AS TIMESTAMP)"}
+	score := typedTransform{"score": "TRY_CAST(score AS BIGINT)"}
+	tests := []struct {
+		name       string
+		transforms []Transform
+		configured map[string]string
+		want       map[string]string
+	}{
+		{"no transforms", nil, nil, map[string]string{}},
+		{"typed transforms", []Transform{created, score}, nil, map[string]string{"created_iso": created["created_iso"], "score": score["score"]}},
+		{"fields removes columns added before it", []Transform{created, score, selectFields("id", "score")}, nil, map[string]string{"score": score["score"]}},
+		{"fields keeps columns added after it", []Transform{selectFields("id"), created}, nil, map[string]string{"created_iso": created["created_iso"]}},
+		{"fields patterns", []Transform{created, score, selectFields("created_*")}, nil, map[string]string{"created_iso": created["created_iso"]}},
+		{"drop fields", []Transform{created, score, NewDropColumnsTransform([]string{"created_iso"})}, nil, map[string]string{"score": score["score"]}},
+		{"redaction removes hashed and dropped columns", []Transform{created, score, redact(RedactionRule{Columns: []string{"score"}, Action: RedactHash}, RedactionRule{Columns: []string{"created_*"}, Action: RedactDrop})}, nil, map[string]string{}},
+		{"redaction keeps truncated columns", []Transform{score, redact(RedactionRule{Columns: []string{"score"}, Action: RedactTruncate, MaxChars: 3})}, nil, map[string]string{"score": score["score"]}},
+		{"redaction keeps columns added after it", []Transform{redact(RedactionRule{Columns: []string{"score"}, Action: RedactHash}), score}, nil, map[string]string{"score": score["score"]}},
+		{"configured columns win", []Transform{score}, map[string]string{"score": "CAST(score AS INTEGER)"}, map[string]string{"score": "CAST(score AS INTEGER)"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &PushshiftProcessor{Options: Options{Transforms: tt.transforms, ParquetColumns: tt.configured}}
+			if got := s.parquetColumns(); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
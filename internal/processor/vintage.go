@@ -0,0 +1,240 @@
+package processor
+
+import (
+	_ "embed"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+//go:embed data/field_maps.csv
+var bundledFieldMaps string
+
+// Dump vintages, named after the years whose records share a field layout
+const (
+	// Vintage2005 is the original Pushshift ingest: no awards, scores sometimes only in ups, and
+	// no permalink on comments
+	Vintage2005 = "2005-2017"
+	// Vintage2018 adds awards (all_awardings, gildings) and author_fullname
+	Vintage2018 = "2018-2022"
+	// Vintage2023 is the post-API-change layout: retrieved_utc replaces retrieved_on and gilded is gone
+	Vintage2023 = "2023+"
+)
+
+// vintageSampleSize is the number of records inspected to detect a dump's vintage
+const vintageSampleSize = 1000
+
+// Vintages lists the known dump vintages, oldest first
+var Vintages = []string{Vintage2005, Vintage2018, Vintage2023}
+
+// recordVintage guesses the vintage of a single record from the fields it carries
+func recordVintage(rec *Record) string {
+	switch {
+	case rec.Has("retrieved_utc"):
+		return Vintage2023
+	case rec.Has("all_awardings") || rec.Has("gildings") || rec.Has("author_fullname"):
+		return Vintage2018
+	default:
+		return Vintage2005
+	}
+}
+
+// DetectVintage samples the start of a zst input and returns the vintage most of its records
+// belong to. A cached result is reused. Messages go to logger, or the standard logger when nil.
+func DetectVintage(inputPath string, cache *InputCache, logger *log.Logger) (string, error) {
+	if entry, ok := cache.Lookup(inputPath); ok && entry.Vintage != "" {
+		loggerOrDefault(logger).Printf("🗃️ Using the cached dump vintage of this input")
+		return entry.Vintage, nil
+	}
+
+	in, err := openZstInput(inputPath, inputOptions{})
+	if err != nil {
+		return "", err
+	}
+	defer in.Close()
+
+	votes := make(map[string]int)
+	sampled := 0
+	scanner := in.scanner(scannerBufferSize)
+	for sampled < vintageSampleSize && scanner.Scan() {
+		rec := NewRecord(scanner.Bytes())
+		if len(rec.Keys()) == 0 {
+			continue
+		}
+		votes[recordVintage(rec)]++
+		sampled++
+	}
+	if err := scanner.Err(); err != nil {
+		return "", fmt.Errorf("failed to sample input: %v", err)
+	}
+
+	vintage := Vintage2005
+	for _, v := range Vintages {
+		if votes[v] > votes[vintage] {
+			vintage = v
+		}
+	}
+	err = cache.Update(inputPath, "", func(entry *CacheEntry) {
+		entry.Vintage = vintage
+	})
+	if err != nil {
+		warnf(logger, "⚠️ Warning: failed to update the input cache: %v", err)
+	}
+	return vintage, nil
+}
+
+// fieldSource is where a vintage stores a canonical field: another field, a dotted path to a count
+// in an object field, or a derivation prefixed with =
+type fieldSource struct {
+	field  string
+	source string
+}
+
+// CanonicalSchemaTransform normalizes records of a given dump vintage to a canonical schema:
+// fields the vintage stores elsewhere are moved or derived into their canonical names, epoch
+// fields become integers, missing canonical fields become null, and all other fields are dropped
+// unless KeepExtra is set (for -extra-json, which runs next and collects them).
+type CanonicalSchemaTransform struct {
+	Vintage   string
+	KeepExtra bool
+	schema    map[string]string
+	sources   []fieldSource
+	order     []string
+}
+
+// NewCanonicalSchemaTransform creates the transform for a canonical schema version, using the
+// bundled field maps of the vintage. fields adds typed columns derived by earlier transforms.
+func NewCanonicalSchemaTransform(version, vintage string, keepExtra bool, fields map[string]string) (*CanonicalSchemaTransform, error) {
+	if version != "v1" {
+		return nil, fmt.Errorf("unsupported canonical schema %q, expected v1", version)
+	}
+	if !slices.Contains(Vintages, vintage) {
+		return nil, fmt.Errorf("unsupported dump vintage %q, expected one of %s", vintage, strings.Join(Vintages, ", "))
+	}
+	t := &CanonicalSchemaTransform{Vintage: vintage, KeepExtra: keepExtra, schema: maps.Clone(CanonicalFields)}
+	maps.Copy(t.schema, fields)
+	if err := t.load(strings.NewReader(bundledFieldMaps), version); err != nil {
+		return nil, fmt.Errorf("failed to load bundled field maps: %v", err)
+	}
+	for name := range t.schema {
+		t.order = append(t.order, name)
+	}
+	slices.Sort(t.order)
+	return t, nil
+}
+
+// load reads the schema,vintage,field,source rows that apply to the transform
+func (t *CanonicalSchemaTransform) load(r io.Reader, version string) error {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = 4
+	rows, err := reader.ReadAll()
+	if err != nil {
+		return err
+	}
+	for _, row := range rows[1:] {
+		if row[0] == version && (row[1] == "*" || row[1] == t.Vintage) {
+			t.sources = append(t.sources, fieldSource{field: row[2], source: row[3]})
+		}
+	}
+	return nil
+}
+
+// Apply maps the record onto the canonical schema
+func (t *CanonicalSchemaTransform) Apply(rec *Record) (bool, error) {
+	for _, fs := range t.sources {
+		if raw, ok := rec.Get(fs.field); ok && string(raw) != "null" {
+			continue
+		}
+		value, ok := resolveFieldSource(rec, fs.source)
+		if !ok {
+			continue
+		}
+		if err := rec.SetRaw(fs.field, value); err != nil {
+			return false, err
+		}
+		// A field renamed away no longer exists under its old name, unless that is canonical too
+		if _, canonical := t.schema[fs.source]; !canonical && !strings.ContainsAny(fs.source, "=.") {
+			rec.Delete(fs.source)
+		}
+	}
+
+	for _, field := range []string{"created_utc", "retrieved_on"} {
+		if n, ok := rec.GetInt(field); ok {
+			if raw, _ := rec.Get(field); string(raw) != strconv.FormatInt(n, 10) {
+				rec.SetRaw(field, json.RawMessage(strconv.FormatInt(n, 10)))
+			}
+		}
+	}
+
+	if !t.KeepExtra {
+		for _, key := range slices.Clone(rec.Keys()) {
+			if _, ok := t.schema[key]; !ok {
+				rec.Delete(key)
+			}
+		}
+	}
+	for _, name := range t.order {
+		if !rec.Has(name) {
+			if err := rec.SetRaw(name, json.RawMessage("null")); err != nil {
+				return false, err
+			}
+		}
+	}
+	return true, nil
+}
+
+// resolveFieldSource reads a field, a derivation, or a count in an object field by dotted path,
+// which is 0 when the object lacks the key
+func resolveFieldSource(rec *Record, source string) (json.RawMessage, bool) {
+	switch source {
+	case "=fullname":
+		id, ok := rec.GetString("id")
+		if !ok || id == "" {
+			return nil, false
+		}
+		prefix := "t3_"
+		if rec.Has("link_id") {
+			prefix = "t1_"
+		}
+		return jsonString(prefix + id), true
+	}
+
+	field, path, nested := strings.Cut(source, ".")
+	raw, ok := rec.Get(field)
+	if !ok || string(raw) == "null" {
+		return nil, false
+	}
+	if !nested {
+		return raw, true
+	}
+	var object map[string]json.RawMessage
+	if err := json.Unmarshal(raw, &object); err != nil {
+		return nil, false
+	}
+	if value, ok := object[path]; ok {
+		return value, true
+	}
+	return json.RawMessage("0"), true
+}
+
+// jsonString encodes s as a JSON string
+func jsonString(s string) json.RawMessage {
+	encoded, _ := json.Marshal(s)
+	return encoded
+}
+
+// ParquetColumns casts every canonical field to its type, so columns keep the same type even in
+// parts where a field is always null
+func (t *CanonicalSchemaTransform) ParquetColumns() map[string]string {
+	columns := make(map[string]string, len(t.schema))
+	for name, typ := range t.schema {
+		columns[name] = fmt.Sprintf("CAST(%s AS %s)", quoteIdentifier(name), typ)
+	}
+	return columns
+}
@@ -0,0 +1,37 @@
+package processor
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestCanonicalSchemaPermalink(t *testing.T) {
+	transform, err := NewCanonicalSchemaTransform("v1", Vintage2005, false, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{"comment without one", `{"id":"c1","link_id":"t3_s1","parent_id":"t3_s1","body":"x"}`, `null`},
+		{"comment with one", `{"id":"c1","link_id":"t3_s1","permalink":"/r/pics/comments/s1/title/c1/"}`, `"/r/pics/comments/s1/title/c1/"`},
+		{"submission", `{"id":"s1","title":"x","permalink":"/r/pics/comments/s1/title/"}`, `"/r/pics/comments/s1/title/"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := NewRecord([]byte(tt.in))
+			if keep, err := transform.Apply(rec); !keep || err != nil {
+				t.Fatalf("got %v, %v", keep, err)
+			}
+			if got, _ := rec.Get("permalink"); string(got) != tt.want {
+				t.Errorf("got %s, want %s", got, tt.want)
+			}
+			var fields map[string]json.RawMessage
+			if err := json.Unmarshal(rec.Bytes(), &fields); err != nil || len(fields) != len(CanonicalFields) {
+				t.Errorf("got %d fields, want %d: %v", len(fields), len(CanonicalFields), err)
+			}
+		})
+	}
+}
@@ -0,0 +1,122 @@
+package processor
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sync"
+
+	"github.com/tetratelabs/wazero"
+	"github.com/tetratelabs/wazero/api"
+	"github.com/tetratelabs/wazero/imports/wasi_snapshot_preview1"
+)
+
+// WASMTransform runs a user-provided WebAssembly module on every record.
+//
+// The module must export its linear memory as "memory" and the functions:
+//
+//	alloc(size i32) -> i32              reserve size bytes for the input record
+//	transform(ptr i32, len i32) -> i64  process the record at ptr
+//
+// transform returns -1 to drop the record, otherwise the output record's pointer in the
+// upper 32 bits and its length in the lower 32 bits. An optional dealloc(ptr i32, size i32)
+// export is called to release the input and output buffers after each record.
+type WASMTransform struct {
+	mu        sync.Mutex
+	ctx       context.Context
+	runtime   wazero.Runtime
+	module    api.Module
+	alloc     api.Function
+	dealloc   api.Function
+	transform api.Function
+}
+
+// LoadWASMTransform compiles and instantiates a transform module from a .wasm file
+func LoadWASMTransform(path string) (*WASMTransform, error) {
+	wasm, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read wasm module: %v", err)
+	}
+
+	ctx := context.Background()
+	runtime := wazero.NewRuntime(ctx)
+
+	// Modules built with TinyGo, Rust or Go's wasip1 target expect WASI to be available
+	if _, err := wasi_snapshot_preview1.Instantiate(ctx, runtime); err != nil {
+		runtime.Close(ctx)
+		return nil, fmt.Errorf("failed to instantiate WASI: %v", err)
+	}
+
+	// Reactor-style modules initialize through _initialize rather than running main
+	config := wazero.NewModuleConfig().WithStartFunctions("_initialize").WithStderr(os.Stderr)
+	module, err := runtime.InstantiateWithConfig(ctx, wasm, config)
+	if err != nil {
+		runtime.Close(ctx)
+		return nil, fmt.Errorf("failed to instantiate wasm module %s: %v", path, err)
+	}
+
+	t := &WASMTransform{
+		ctx:       ctx,
+		runtime:   runtime,
+		module:    module,
+		alloc:     module.ExportedFunction("alloc"),
+		dealloc:   module.ExportedFunction("dealloc"),
+		transform: module.ExportedFunction("transform"),
+	}
+	if t.alloc == nil || t.transform == nil || module.Memory() == nil {
+		runtime.Close(ctx)
+		return nil, fmt.Errorf("wasm module %s must export memory, alloc and transform", path)
+	}
+	return t, nil
+}
+
+// Apply passes the record to the module's transform function
+func (t *WASMTransform) Apply(rec *Record) (bool, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	in := rec.Bytes()
+	res, err := t.alloc.Call(t.ctx, uint64(len(in)))
+	if err != nil {
+		return false, fmt.Errorf("wasm alloc failed: %v", err)
+	}
+	inPtr := uint32(res[0])
+	memory := t.module.Memory()
+	if !memory.Write(inPtr, in) {
+		return false, fmt.Errorf("wasm alloc returned out-of-range pointer %d", inPtr)
+	}
+
+	res, err = t.transform.Call(t.ctx, uint64(inPtr), uint64(len(in)))
+	if err != nil {
+		return false, fmt.Errorf("wasm transform failed: %v", err)
+	}
+	result := int64(res[0])
+
+	keep := result >= 0
+	if keep {
+		outPtr, outLen := uint32(uint64(result)>>32), uint32(result)
+		out, ok := memory.Read(outPtr, outLen)
+		if !ok {
+			return false, fmt.Errorf("wasm transform returned out-of-range buffer %d+%d", outPtr, outLen)
+		}
+		// Reset copies the bytes out of guest memory before they are released
+		rec.Reset(out)
+		if outPtr != inPtr {
+			t.free(outPtr, outLen)
+		}
+	}
+	t.free(inPtr, uint32(len(in)))
+	return keep, nil
+}
+
+// free releases a guest buffer when the module exports dealloc
+func (t *WASMTransform) free(ptr, size uint32) {
+	if t.dealloc != nil {
+		t.dealloc.Call(t.ctx, uint64(ptr), uint64(size))
+	}
+}
+
+// Close releases the WebAssembly runtime
+func (t *WASMTransform) Close() error {
+	return t.runtime.Close(t.ctx)
+}
@@ -0,0 +1,102 @@
+package processor
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// wasmSection encodes a module section of fewer than 128 bytes
+func wasmSection(id byte, content ...byte) []byte {
+	return append([]byte{id, byte(len(content))}, content...)
+}
+
+// testWASMModule is a transform module dropping "{}" and replacing every other record with
+// {"tagged":true}, which it keeps in a data segment at offset 16
+func testWASMModule() []byte {
+	output := `{"tagged":true}`
+	module := []byte{0x00, 0x61, 0x73, 0x6d, 0x01, 0x00, 0x00, 0x00}
+	// (i32) -> i32 and (i32, i32) -> i64
+	module = append(module, wasmSection(1, 0x02, 0x60, 0x01, 0x7f, 0x01, 0x7f, 0x60, 0x02, 0x7f, 0x7f, 0x01, 0x7e)...)
+	module = append(module, wasmSection(3, 0x02, 0x00, 0x01)...)
+	module = append(module, wasmSection(5, 0x01, 0x00, 0x01)...)
+	exports := []byte{0x03}
+	for i, name := range []string{"memory", "alloc", "transform"} {
+		kind, index := byte(0x00), byte(i-1)
+		if name == "memory" {
+			kind, index = 0x02, 0x00
+		}
+		exports = append(append(append(exports, byte(len(name))), name...), kind, index)
+	}
+	module = append(module, wasmSection(7, exports...)...)
+	// alloc returns 1024; transform returns -1 for 2-byte records, otherwise 16<<32 | len(output)
+	alloc := []byte{0x05, 0x00, 0x41, 0x80, 0x08, 0x0b}
+	transform := []byte{0x14, 0x00, 0x20, 0x01, 0x41, 0x02, 0x46, 0x04, 0x7e, 0x42, 0x7f, 0x05, 0x42, 0x8f, 0x80, 0x80, 0x80, 0x80, 0x02, 0x0b, 0x0b}
+	module = append(module, wasmSection(10, append(append([]byte{0x02}, alloc...), transform...)...)...)
+	data := append([]byte{0x01, 0x00, 0x41, 0x10, 0x0b, byte(len(output))}, output...)
+	return append(module, wasmSection(11, data...)...)
+}
+
+func TestWASMTransform(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "transform.wasm")
+	if err := os.WriteFile(path, testWASMModule(), 0644); err != nil {
+		t.Fatal(err)
+	}
+	transform, err := LoadWASMTransform(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer transform.Close()
+
+	tests := []struct {
+		record string
+		keep   bool
+		want   string
+	}{
+		{`{"id":"abc","body":"text"}`, true, `{"tagged":true}`},
+		{`{}`, false, ``},
+		{`{"id":"def"}`, true, `{"tagged":true}`},
+	}
+	for _, tt := range tests {
+		rec := NewRecord([]byte(tt.record))
+		keep, err := transform.Apply(rec)
+		if err != nil || keep != tt.keep {
+			t.Fatalf("%s: got %v, %v, want %v", tt.record, keep, err, tt.keep)
+		}
+		if keep {
+			if got := string(rec.Bytes()); got != tt.want {
+				t.Errorf("%s: got %s, want %s", tt.record, got, tt.want)
+			}
+			if tagged, ok := rec.Get("tagged"); !ok || string(tagged) != "true" {
+				t.Errorf("%s: the output record can't be read", tt.record)
+			}
+		}
+	}
+}
+
+func TestLoadWASMTransformInvalid(t *testing.T) {
+	dir := t.TempDir()
+	tests := []struct {
+		name   string
+		module []byte
+		want   string
+	}{
+		{"not wasm", []byte("not a module"), "failed to instantiate"},
+		{"missing exports", []byte{0x00, 0x61, 0x73, 0x6d, 0x01, 0x00, 0x00, 0x00}, "must export memory, alloc and transform"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(dir, strings.ReplaceAll(tt.name, " ", "_")+".wasm")
+			if err := os.WriteFile(path, tt.module, 0644); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := LoadWASMTransform(path); err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("got %v, want %q", err, tt.want)
+			}
+		})
+	}
+	if _, err := LoadWASMTransform(filepath.Join(dir, "missing.wasm")); err == nil {
+		t.Error("loaded a missing module")
+	}
+}
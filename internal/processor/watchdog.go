@@ -0,0 +1,82 @@
+package processor
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+)
+
+// ThroughputFloor aborts a run whose input throughput stays below BytesPerSecond for Window.
+// Only time spent reading counts: conversions and pauses don't drag the rate down.
+type ThroughputFloor struct {
+	BytesPerSecond int64
+	Window         time.Duration
+}
+
+// throughputSample is the input read after some amount of active time
+type throughputSample struct {
+	active time.Duration
+	bytes  int64
+}
+
+// watchThroughput checks the floor every tick until ctx is done, aborting the run through ctl
+// with diagnostics when the rate over the last window is too low
+func watchThroughput(ctx context.Context, logger *log.Logger, ctl *Control, floor ThroughputFloor, tick time.Duration) {
+	ticker := time.NewTicker(tick)
+	defer ticker.Stop()
+
+	var active time.Duration
+	samples := []throughputSample{{}}
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		snap := ctl.Snapshot()
+		if snap.Paused || snap.Stage == "converting" {
+			continue
+		}
+		active += tick
+		samples = append(samples, throughputSample{active: active, bytes: snap.BytesRead})
+
+		// Keep the newest sample at least one window old as the baseline
+		for len(samples) > 1 && active-samples[1].active >= floor.Window {
+			samples = samples[1:]
+		}
+		base := samples[0]
+		if active-base.active < floor.Window {
+			continue
+		}
+		rate := float64(snap.BytesRead-base.bytes) / (active - base.active).Seconds()
+		if rate >= float64(floor.BytesPerSecond) {
+			continue
+		}
+
+		err := fmt.Errorf("throughput %.2f MB/s stayed below the floor of %.2f MB/s for %s "+
+			"(stage %s, part %d, %d lines and %.0f MB read, %d parts converted, last conversion took %s)",
+			rate/1024/1024, float64(floor.BytesPerSecond)/1024/1024, floor.Window, snap.Stage, snap.PartNumber,
+			snap.LinesProcessed, float64(snap.BytesRead)/1024/1024, snap.PartsConverted, snap.LastConvertTime.Round(time.Millisecond))
+		logger.Printf("🐢 %v", err)
+		ctl.abort(err)
+		return
+	}
+}
+
+// startWatchdog starts the throughput watchdog when a floor is configured and returns the
+// function that stops it
+func (s *PushshiftProcessor) startWatchdog() func() {
+	floor := s.Options.MinThroughput
+	if floor.BytesPerSecond <= 0 || floor.Window <= 0 {
+		return func() {}
+	}
+	if s.Options.Control == nil {
+		s.Options.Control = NewControl()
+	}
+	s.logger().Printf("⏱️ Aborting if throughput stays below %.2f MB/s for %s", float64(floor.BytesPerSecond)/1024/1024, floor.Window)
+	ctx, cancel := context.WithCancel(context.Background())
+	go watchThroughput(ctx, s.logger(), s.Options.Control, floor, min(time.Second, floor.Window/10))
+	return cancel
+}
@@ -0,0 +1,146 @@
+package processor
+
+import (
+	"os"
+	"strings"
+	"time"
+)
+
+// partConversion is a part being converted to Parquet on a worker goroutine
+type partConversion struct {
+	part      PartInfo
+	jsonlPath string
+	// lastOffset is where the part's last line starts in the decompressed input
+	lastOffset int64
+	// counts are the run's statistics when the part was written, for its checkpoint
+	counts *CheckpointStats
+	// scratchBytes is the size of the JSONL part on disk until the conversion removes it
+	scratchBytes int64
+	elapsed      time.Duration
+	err          error
+	done         chan struct{}
+}
+
+// convertPool converts parts on up to workers goroutines while the parts after them are written.
+// Results are recorded in part order on the goroutine writing the parts, so statistics, events
+// and part sizing see the same sequence as a serial run. With one worker each part is converted
+// before the next is written.
+type convertPool struct {
+	s       *PushshiftProcessor
+	workers int
+	stats   *ProcessStats
+	sizer   *partSizer
+	pending []*partConversion
+	// scratch is the JSONL held by the pending conversions
+	scratch int64
+	// checkpoint, when set, is rewritten as each part is recorded
+	checkpoint *checkpointer
+	// metadata, when set, returns the key-value metadata stamped into each converted part
+	metadata func(part PartInfo) map[string]string
+}
+
+// newConvertPool creates a pool of Options.Workers converters recording into stats
+func (s *PushshiftProcessor) newConvertPool(stats *ProcessStats, sizer *partSizer) *convertPool {
+	workers := max(s.Options.Workers, 1)
+	if workers > 1 {
+		s.logger().Printf("👷 Converting up to %d parts at once", workers)
+	}
+	return &convertPool{s: s, workers: workers, stats: stats, sizer: sizer}
+}
+
+// start converts a written part on a new goroutine, first waiting for a worker to free up. The
+// part's last line starts at lastOffset in the decompressed input, and counts are the statistics
+// its checkpoint saves. It returns the error of a part that failed while waiting, unless the run
+// continues past failed parts.
+func (p *convertPool) start(part PartInfo, jsonlPath string, scratchBytes, lastOffset int64, counts *CheckpointStats) error {
+	if err := p.collect(p.workers - 1); err != nil {
+		return err
+	}
+	s := p.s
+	s.logger().Printf("🔄 Converting part %d to Parquet format...", part.Number)
+	conv := &partConversion{part: part, jsonlPath: jsonlPath, scratchBytes: scratchBytes, lastOffset: lastOffset, counts: counts, done: make(chan struct{})}
+	parquetBaseName := strings.TrimSuffix(part.Path, ".parquet")
+	columns := s.parquetColumns()
+	go func() {
+		defer close(conv.done)
+		convertStart := time.Now()
+		conv.err = s.convertPart(&conv.part, jsonlPath, parquetBaseName, columns)
+		if conv.err == nil && p.metadata != nil {
+			s.stampPart(part, p.metadata(part))
+		}
+		conv.elapsed = time.Since(convertStart)
+		// Remove the JSONL file once it has been converted, or has failed to
+		removeScratch(s.logger(), jsonlPath)
+	}()
+	p.pending = append(p.pending, conv)
+	p.scratch += scratchBytes
+	if p.workers == 1 {
+		return p.collect(0)
+	}
+	return nil
+}
+
+// collect records finished conversions in part order, waiting for the oldest ones until no more
+// than limit are pending
+func (p *convertPool) collect(limit int) error {
+	for len(p.pending) > 0 {
+		conv := p.pending[0]
+		select {
+		case <-conv.done:
+		default:
+			if len(p.pending) <= limit {
+				return nil
+			}
+			p.s.Options.Control.setStage("converting")
+			<-conv.done
+		}
+		p.pending = p.pending[1:]
+		p.scratch -= conv.scratchBytes
+		if err := p.record(conv); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// record adds a finished conversion to the run's statistics
+func (p *convertPool) record(conv *partConversion) error {
+	s, stats, part := p.s, p.stats, conv.part
+	if conv.err != nil {
+		convErr := &ErrConversionFailed{Part: part.Number, Path: conv.jsonlPath, Err: conv.err}
+		if !s.Options.ContinueOnPartError {
+			return convErr
+		}
+		// The part is left out of the output and recorded for a later retry
+		errorf(s.logger(), "❌ Part %d failed, continuing with the next part: %v", part.Number, convErr)
+		removeScratch(s.logger(), part.Path)
+		part.Error = convErr.Error()
+		stats.FailedParts = append(stats.FailedParts, part)
+		s.emit(Event{Kind: EventPartFailed, Part: part.Number, Lines: part.Lines, Bytes: part.JSONLBytes, Err: convErr})
+		p.checkpoint.record(stats, part, conv.lastOffset, conv.counts)
+		return nil
+	}
+	s.Options.Control.finishConvert(conv.elapsed)
+	stats.Stages.PartsConverted++
+	stats.Stages.ConvertTime += conv.elapsed
+	if info, err := os.Stat(part.Path); err == nil {
+		part.ParquetBytes = info.Size()
+	}
+	if s.Options.IOHints {
+		dropFromCache(part.Path)
+	}
+	p.sizer.observe(part.JSONLBytes, part.ParquetBytes)
+	stats.Parts = append(stats.Parts, part)
+	p.checkpoint.record(stats, part, conv.lastOffset, conv.counts)
+	s.emit(Event{Kind: EventPartFinished, Part: part.Number, Lines: part.Lines, Bytes: part.JSONLBytes, Path: part.Path})
+	return nil
+}
+
+// wait blocks until every pending conversion has finished, without recording them, so a run
+// returning early leaves no converter running
+func (p *convertPool) wait() {
+	for _, conv := range p.pending {
+		<-conv.done
+	}
+	p.pending = nil
+}
@@ -0,0 +1,102 @@
+package processor
+
+import (
+	"io"
+	"sync"
+)
+
+// writeBehindChunkSize is the size of each buffer queued by the write-behind writer
+const writeBehindChunkSize = 8 * 1024 * 1024
+
+// asyncWriter queues writes and performs them on a background goroutine, so a slow flush of the
+// output volume doesn't stall decompression. When depth chunks are queued, Write blocks until
+// the oldest is written. The first write error is returned by every later Write and by Close.
+type asyncWriter struct {
+	w     io.Writer
+	queue chan []byte
+	free  chan []byte
+	done  chan struct{}
+	buf   []byte
+
+	mu     sync.Mutex
+	err    error
+	closed bool
+}
+
+// newAsyncWriter starts a write-behind writer over w with up to depth queued chunks
+func newAsyncWriter(w io.Writer, depth int) *asyncWriter {
+	a := &asyncWriter{
+		w:     w,
+		queue: make(chan []byte, depth),
+		free:  make(chan []byte, depth+1),
+		done:  make(chan struct{}),
+	}
+	go a.run()
+	return a
+}
+
+// run writes queued chunks in order
+func (a *asyncWriter) run() {
+	defer close(a.done)
+	for chunk := range a.queue {
+		if a.error() == nil {
+			if _, err := a.w.Write(chunk); err != nil {
+				a.mu.Lock()
+				a.err = err
+				a.mu.Unlock()
+			}
+		}
+		select {
+		case a.free <- chunk[:0]:
+		default:
+		}
+	}
+}
+
+// error returns the first write error, if any
+func (a *asyncWriter) error() error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.err
+}
+
+// Write implements io.Writer; data is copied, so p may be reused as soon as it returns
+func (a *asyncWriter) Write(p []byte) (int, error) {
+	if err := a.error(); err != nil {
+		return 0, err
+	}
+	n := 0
+	for len(p) > 0 {
+		if a.buf == nil {
+			select {
+			case a.buf = <-a.free:
+			default:
+				a.buf = make([]byte, 0, writeBehindChunkSize)
+			}
+		}
+		k := copy(a.buf[len(a.buf):cap(a.buf)], p)
+		a.buf = a.buf[:len(a.buf)+k]
+		p = p[k:]
+		n += k
+		if len(a.buf) == cap(a.buf) {
+			a.queue <- a.buf
+			a.buf = nil
+		}
+	}
+	return n, nil
+}
+
+// Close queues the last partial chunk, waits until everything is written and returns the first
+// write error. It is safe to call more than once.
+func (a *asyncWriter) Close() error {
+	if !a.closed {
+		a.closed = true
+		if len(a.buf) > 0 {
+			a.queue <- a.buf
+			a.buf = nil
+		}
+		close(a.queue)
+		<-a.done
+	}
+	return a.error()
+}
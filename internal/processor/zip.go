@@ -0,0 +1,56 @@
+package processor
+
+import (
+	"archive/zip"
+	"fmt"
+	"io"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// zipIterator walks the files of a zip archive. Its directory is at the end of the file, so
+// zip inputs need random access and can't be streamed.
+type zipIterator struct {
+	files []*zip.File
+	open  io.ReadCloser
+}
+
+// newZipIterator reads the directory of the zip archive r of the given size
+func newZipIterator(r io.ReaderAt, size int64) (*zipIterator, error) {
+	zr, err := zip.NewReader(r, size)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read zip archive: %w", err)
+	}
+	zr.RegisterDecompressor(zstd.ZipMethodWinZip, zstd.ZipDecompressor())
+	return &zipIterator{files: zr.File}, nil
+}
+
+// next implements memberIterator
+func (z *zipIterator) next() (string, io.Reader, error) {
+	if z.open != nil {
+		z.open.Close()
+		z.open = nil
+	}
+	for len(z.files) > 0 {
+		f := z.files[0]
+		z.files = z.files[1:]
+		if !f.Mode().IsRegular() {
+			continue
+		}
+		r, err := f.Open()
+		if err != nil {
+			return "", nil, fmt.Errorf("failed to open zip member %s: %w", f.Name, err)
+		}
+		z.open = r
+		return f.Name, r, nil
+	}
+	return "", nil, io.EOF
+}
+
+// Close implements memberIterator
+func (z *zipIterator) Close() error {
+	if z.open != nil {
+		return z.open.Close()
+	}
+	return nil
+}
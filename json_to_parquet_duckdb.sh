@@ -2,7 +2,7 @@
 
 # Check if a filename was provided
 if [ $# -lt 1 ]; then
-    echo "Usage: $0 <jsonl_file> [output_name]"
+    echo "Usage: $0 <jsonl_file> [output_name] [replace_clause] [copy_options] [settings]"
     exit 1
 fi
 
@@ -17,15 +17,24 @@ else
     output_name=$2
 fi
 
+# Optional "REPLACE (expr AS column, ...)" clause giving columns types JSON cannot express
+replace_clause=$3
+
+# Optional extra COPY options (e.g. "ROW_GROUP_SIZE 100000, COMPRESSION zstd") and settings
+# statements that must run first
+copy_options=${4:+, $4}
+settings=$5
+
 # Run duckdb commands
 duckdb -c "
+${settings}
 
 -- Create a table from the JSONL file
 CREATE TABLE temp_table AS
   SELECT * FROM read_json('$input_file', union_by_name=true, maximum_object_size=256000000);
 
 -- Export to Parquet format
-COPY temp_table TO '${output_name}.parquet' (FORMAT PARQUET);
+COPY (SELECT * ${replace_clause} FROM temp_table) TO '${output_name}.parquet' (FORMAT PARQUET${copy_options});
 
 -- Drop the temporary table
 DROP TABLE temp_table;
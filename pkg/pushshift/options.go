@@ -0,0 +1,162 @@
+package pushshift
+
+import (
+	"fmt"
+	"log/slog"
+
+	"github.com/bhupixb/pushshift-go/internal/processor"
+)
+
+// Option configures a Processor
+type Option func(*Options) error
+
+// WithOptions starts from a complete set of options, such as those the command line builds;
+// options after it adjust them
+func WithOptions(opts Options) Option {
+	return func(o *Options) error {
+		*o = opts
+		return nil
+	}
+}
+
+// WithPartSize closes parts at this many bytes of JSON lines instead of 8GB
+func WithPartSize(bytes int64) Option {
+	return func(o *Options) error {
+		if bytes <= 0 {
+			return fmt.Errorf("the part size must be positive")
+		}
+		o.PartSize = bytes
+		return nil
+	}
+}
+
+// WithTargetParquetSize sizes parts so each Parquet file comes out near this many bytes. It takes
+// precedence over WithPartSize.
+func WithTargetParquetSize(bytes int64) Option {
+	return func(o *Options) error {
+		if bytes <= 0 {
+			return fmt.Errorf("the target Parquet size must be positive")
+		}
+		o.TargetParquetSize = bytes
+		return nil
+	}
+}
+
+// WithFilter adds filters deciding which records are kept, run before any transform
+func WithFilter(filters ...Transform) Option {
+	return func(o *Options) error {
+		o.Filters = append(o.Filters, filters...)
+		return nil
+	}
+}
+
+// WithTransform adds transforms applied in order to every record kept by the filters
+func WithTransform(transforms ...Transform) Option {
+	return func(o *Options) error {
+		o.Transforms = append(o.Transforms, transforms...)
+		return nil
+	}
+}
+
+// WithBatchTransform adds transforms applied to buffered groups of records after the others
+func WithBatchTransform(transforms ...BatchTransform) Option {
+	return func(o *Options) error {
+		o.BatchTransforms = append(o.BatchTransforms, transforms...)
+		return nil
+	}
+}
+
+// WithRoutes sends each record to the outputs of the routes it meets, writing a directory of
+// Parquet files per output under the output prefix
+func WithRoutes(config *RoutingConfig) Option {
+	return func(o *Options) error {
+		if config == nil {
+			return fmt.Errorf("no routing config given")
+		}
+		o.Routes = config
+		return nil
+	}
+}
+
+// WithOnBadLine checks that each input line is valid JSON, handling invalid ones by policy:
+// "skip" drops them, "quarantine" also writes them to path, and "fail" stops the run
+func WithOnBadLine(policy, path string) Option {
+	return func(o *Options) error {
+		if err := processor.ValidateOnBadLine(policy, path); err != nil {
+			return err
+		}
+		o.OnBadLine, o.BadLinesPath = policy, path
+		return nil
+	}
+}
+
+// WithDroppedSample writes a random sample of up to size records dropped by each filter and
+// transform to path as JSON lines, each with the name of the filter that dropped it
+func WithDroppedSample(size int, path string) Option {
+	return func(o *Options) error {
+		if size <= 0 || path == "" {
+			return fmt.Errorf("a dropped record sample needs a positive size and a file")
+		}
+		o.DroppedSample, o.DroppedSamplePath = size, path
+		return nil
+	}
+}
+
+// WithConverter selects the converter turning parts into Parquet, ConverterDuckDB or
+// ConverterNative. A part the native converter fails on is retried with DuckDB. Fallbacks are
+// shell commands tried in order on a part that still fails, given the JSONL part as $1 and the
+// Parquet file to write as $2.
+func WithConverter(name string, fallbacks ...string) Option {
+	return func(o *Options) error {
+		if name != ConverterDuckDB && name != ConverterNative {
+			return fmt.Errorf("unsupported converter %q, expected %s or %s", name, ConverterDuckDB, ConverterNative)
+		}
+		o.Parquet.Converter = name
+		o.FallbackConverters = append(o.FallbackConverters, fallbacks...)
+		return nil
+	}
+}
+
+// WithWorkers converts up to n parts at once while the next part is written
+func WithWorkers(n int) Option {
+	return func(o *Options) error {
+		if n < 1 {
+			return fmt.Errorf("the number of workers must be at least 1")
+		}
+		o.Workers = n
+		return nil
+	}
+}
+
+// WithSink sends the processed records to sink instead of Parquet parts
+func WithSink(sink Sink) Option {
+	return func(o *Options) error {
+		o.Sink = sink
+		return nil
+	}
+}
+
+// WithLogger sends the progress messages of runs to logger instead of the standard logger
+func WithLogger(logger *slog.Logger) Option {
+	return func(o *Options) error {
+		o.Logger = logger
+		return nil
+	}
+}
+
+// WithEvents calls fn with each Event of a run, on the processing goroutine
+func WithEvents(fn func(Event)) Option {
+	return func(o *Options) error {
+		o.OnEvent = fn
+		return nil
+	}
+}
+
+// WithControl lets control observe, pause and cancel runs. Runs without one are still cancelled
+// through their context.
+func WithControl(control *Control) Option {
+	return func(o *Options) error {
+		o.Control = control
+		return nil
+	}
+}
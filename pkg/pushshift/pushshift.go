@@ -0,0 +1,241 @@
+// Package pushshift processes Pushshift Reddit dumps: it decompresses a zst dump, runs its records
+// through filters and transforms, and writes them as Parquet parts or as JSON lines. It is the
+// library the pushshift-processor command is built on.
+//
+//	p, err := pushshift.New(
+//		pushshift.WithFilter(pushshift.FilterFunc(func(rec *pushshift.Record) bool {
+//			subreddit, _ := rec.GetString("subreddit")
+//			return subreddit == "golang"
+//		})),
+//		pushshift.WithConverter(pushshift.ConverterNative),
+//	)
+//	if err != nil {
+//		return err
+//	}
+//	stats, err := p.ProcessFile(ctx, "RC_2023-01.zst", "out/RC_2023-01")
+package pushshift
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sync/atomic"
+
+	"github.com/bhupixb/pushshift-go/internal/processor"
+)
+
+type (
+	// Record is one record of a dump. Fields are read from the JSON line as they are asked for,
+	// so filters reading a few fields never decode the rest.
+	Record = processor.Record
+	// Transform modifies or drops records. Filters are transforms that only decide whether a
+	// record is kept.
+	Transform = processor.Transform
+	// BatchTransform processes buffered groups of records, for enrichments calling services
+	BatchTransform = processor.BatchTransform
+	// Sink receives the processed records in place of Parquet parts
+	Sink = processor.Sink
+	// Options holds every setting of a run; the Option functions set the common ones
+	Options = processor.Options
+	// Named is implemented by filters and transforms naming themselves in Stats.Filters; others
+	// are named after their type
+	Named = processor.Named
+	// Stats reports what a run read, kept and wrote
+	Stats = processor.ProcessStats
+	// FilterStats counts the records a filter or transform saw and dropped
+	FilterStats = processor.FilterStats
+	// RoutingConfig sends records to the outputs of the routes they meet, in one pass
+	RoutingConfig = processor.RoutingConfig
+	// Route sends the records meeting its condition to an output
+	Route = processor.Route
+	// RouteCondition selects the records a route takes
+	RouteCondition = processor.RouteCondition
+	// RouteStats counts the records written to an output
+	RouteStats = processor.RouteStats
+	// Event is a step of a run: parts started and finished, progress and the outcome
+	Event = processor.Event
+	// Control observes, pauses and cancels a running call
+	Control = processor.Control
+	// RetryOptions adjusts how RetryParts finds the input of the parts it rebuilds
+	RetryOptions = processor.RetryOptions
+	// PartInfo describes a part written by a run
+	PartInfo = processor.PartInfo
+	// ErrPartsFailed is returned when parts failed to convert in a run that went on
+	ErrPartsFailed = processor.ErrPartsFailed
+)
+
+// ErrProcessorUsed is returned by a Processor asked to run again. Its filters, transforms and sink
+// keep their state and are closed by the run, so each input needs a Processor of its own.
+var ErrProcessorUsed = errors.New("the processor has already run, create another for each input")
+
+// NewControl returns a Control for WithControl
+func NewControl() *Control {
+	return processor.NewControl()
+}
+
+// Names of the converters turning JSON lines into Parquet
+const (
+	// ConverterDuckDB runs DuckDB in process, the default; its SQL is set by Options.Parquet.DuckDBSQL
+	ConverterDuckDB = processor.ConverterDuckDB
+	// ConverterNative writes Parquet with parquet-go, needing no DuckDB
+	ConverterNative = processor.ConverterNative
+)
+
+// FilterFunc is a filter keeping the records the function returns true for
+type FilterFunc func(rec *Record) bool
+
+// Apply implements Transform
+func (f FilterFunc) Apply(rec *Record) (bool, error) {
+	return f(rec), nil
+}
+
+// TransformFunc is a transform made of a function, which may modify the record and reports
+// whether to keep it
+type TransformFunc func(rec *Record) (bool, error)
+
+// Apply implements Transform
+func (f TransformFunc) Apply(rec *Record) (bool, error) {
+	return f(rec)
+}
+
+// streamName names inputs read from an io.Reader in messages and manifests
+const streamName = "-"
+
+// Processor runs a dump through its filters and transforms. A Processor runs once: filters and
+// transforms count and remember what they saw and the sink is closed when the run ends, so later
+// calls return ErrProcessorUsed. Create a Processor with fresh filters, transforms and sink for
+// each input.
+type Processor struct {
+	opts Options
+	used atomic.Bool
+}
+
+// New returns a Processor configured by options, applied in order
+func New(options ...Option) (*Processor, error) {
+	p := &Processor{}
+	for _, option := range options {
+		if err := option(&p.opts); err != nil {
+			return nil, err
+		}
+	}
+	if err := validate(p.opts); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// validate checks settings no option checks on its own, such as those set through WithOptions
+func validate(opts Options) error {
+	if err := opts.Parquet.Validate(); err != nil {
+		return err
+	}
+	if err := processor.ValidateLineEndings(opts.LineEndings); err != nil {
+		return err
+	}
+	// Split runs keep their parts, which may be compressed harder, and check the level themselves
+	if err := processor.ValidatePartCompressionLevel(opts.PartCompressionLevel); err != nil && !opts.SplitOnly {
+		return err
+	}
+	if err := processor.ValidateBadRecords(opts.BadRecords, opts.BadRecordsPath); err != nil {
+		return err
+	}
+	if err := processor.ValidateOnBadLine(opts.OnBadLine, opts.BadLinesPath); err != nil {
+		return err
+	}
+	if len(opts.PartitionBy) > 0 {
+		if err := opts.ValidatePartitionBy(); err != nil {
+			return fmt.Errorf("invalid partitioning: %v", err)
+		}
+	}
+	if opts.DroppedSample < 0 {
+		return fmt.Errorf("the dropped record sample size can't be negative")
+	}
+	if opts.DroppedSample > 0 && opts.DroppedSamplePath == "" {
+		return fmt.Errorf("sampling dropped records needs a file to write them to")
+	}
+	if opts.Routes != nil {
+		if err := opts.ValidateRoutes(); err != nil {
+			return err
+		}
+	}
+	if opts.Workers < 0 {
+		return fmt.Errorf("the number of workers can't be negative")
+	}
+	if opts.SkipLines < 0 || opts.TakeLines < 0 {
+		return fmt.Errorf("the lines to skip and take can't be negative")
+	}
+	return nil
+}
+
+// Options returns the settings of the processor's runs
+func (p *Processor) Options() Options {
+	return p.opts
+}
+
+// ProcessFile processes a dump into Parquet parts named after outputPrefix, such as
+// <outputPrefix>_part_001.parquet, with a manifest next to them. Directories and globs of dumps
+// are not expanded; process each with a Processor of its own. Cancelling ctx stops the run at the
+// next line, keeping the parts converted so far.
+func (p *Processor) ProcessFile(ctx context.Context, inputPath, outputPrefix string) (Stats, error) {
+	return p.run(ctx, p.opts, inputPath, outputPrefix)
+}
+
+// ProcessReader processes a dump read from r, zstd-compressed or plain JSON lines, into Parquet
+// parts named after outputPrefix, as ProcessFile does. r is not closed.
+func (p *Processor) ProcessReader(ctx context.Context, r io.Reader, outputPrefix string) (Stats, error) {
+	opts := p.opts
+	opts.InputReader = r
+	return p.run(ctx, opts, streamName, outputPrefix)
+}
+
+// Stream processes a dump read from r, zstd-compressed or plain JSON lines, and writes the
+// records it keeps to w as JSON lines, for services consuming them directly. Neither r nor w is
+// closed.
+func (p *Processor) Stream(ctx context.Context, r io.Reader, w io.Writer) (Stats, error) {
+	opts := p.opts
+	if opts.Sink != nil || len(opts.PartitionBy) > 0 || opts.Routes != nil || opts.SplitOnly || opts.CountOnly {
+		return Stats{}, fmt.Errorf("a stream is written as JSON lines, without sinks, partitions, routes, parts or counting only")
+	}
+	opts.InputReader = r
+	opts.Sink = processor.NewJSONLSink(w)
+	return p.run(ctx, opts, streamName, "")
+}
+
+// RetryParts rebuilds the parts of an earlier run's output that failed to convert or went
+// missing, reading only their lines of the input. The processor should have the filters and
+// transforms of the original run, so the parts come out the same. It returns the rebuilt parts.
+func (p *Processor) RetryParts(ctx context.Context, outputPrefix string, retry RetryOptions) ([]PartInfo, error) {
+	proc, stop, err := p.start(ctx, p.opts)
+	if err != nil {
+		return nil, err
+	}
+	defer stop()
+	return proc.RetryParts(outputPrefix, retry)
+}
+
+// run processes an input with opts, cancelling the run once ctx is done
+func (p *Processor) run(ctx context.Context, opts Options, inputPath, outputPrefix string) (Stats, error) {
+	proc, stop, err := p.start(ctx, opts)
+	if err != nil {
+		return Stats{}, err
+	}
+	defer stop()
+	return proc.Process(inputPath, outputPrefix)
+}
+
+// start claims the processor's single run and returns the processor running it with opts, with a
+// function releasing the cancellation by ctx
+func (p *Processor) start(ctx context.Context, opts Options) (*processor.PushshiftProcessor, func() bool, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, nil, err
+	}
+	if !p.used.CompareAndSwap(false, true) {
+		return nil, nil, ErrProcessorUsed
+	}
+	if opts.Control == nil {
+		opts.Control = processor.NewControl()
+	}
+	stop := context.AfterFunc(ctx, opts.Control.Cancel)
+	return &processor.PushshiftProcessor{Options: opts}, stop, nil
+}
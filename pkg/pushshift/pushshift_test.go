@@ -0,0 +1,34 @@
+package pushshift
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestProcessorRunsOnce(t *testing.T) {
+	p, err := New(WithFilter(FilterFunc(func(rec *Record) bool {
+		subreddit, _ := rec.GetString("subreddit")
+		return subreddit == "golang"
+	})))
+	if err != nil {
+		t.Fatal(err)
+	}
+	input := "{\"id\":\"a\",\"subreddit\":\"golang\"}\n{\"id\":\"b\",\"subreddit\":\"pics\"}\n"
+	var out bytes.Buffer
+	stats, err := p.Stream(context.Background(), strings.NewReader(input), &out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.TotalLines != 2 || out.String() != "{\"id\":\"a\",\"subreddit\":\"golang\"}\n" {
+		t.Fatalf("got %d lines and %q", stats.TotalLines, out.String())
+	}
+	if _, err := p.Stream(context.Background(), strings.NewReader(input), &out); !errors.Is(err, ErrProcessorUsed) {
+		t.Errorf("got %v on the second run, want ErrProcessorUsed", err)
+	}
+	if _, err := p.ProcessFile(context.Background(), "RC_2023-01.zst", t.TempDir()+"/RC_2023-01"); !errors.Is(err, ErrProcessorUsed) {
+		t.Errorf("got %v on the third run, want ErrProcessorUsed", err)
+	}
+}